@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/monishSR/veclite/pkg/ingest"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// ingestFlags configures the optional ingestion worker
+type ingestFlags struct {
+	kind  *string
+	url   *string
+	topic *string
+	group *string
+	codec *string
+	batch *int
+}
+
+// addIngestFlags registers the flags of the ingestion worker
+func addIngestFlags(flags *flag.FlagSet) ingestFlags {
+	return ingestFlags{
+		kind:  flags.String("ingest", "", "Consume vector records from a stream: kafka or nats (default: none)"),
+		url:   flags.String("ingest-url", "", "Ingest: Kafka brokers (comma-separated) or NATS server URL"),
+		topic: flags.String("ingest-topic", "", "Ingest: Kafka topic or NATS JetStream subject"),
+		group: flags.String("ingest-group", "veclite", "Ingest: Kafka consumer group or NATS durable consumer"),
+		codec: flags.String("ingest-codec", "json", "Ingest: message codec, json or binary (see package ingest)"),
+		batch: flags.Int("ingest-batch", 500, "Ingest: messages written per batch"),
+	}
+}
+
+// source is an ingest.Source holding a broker connection
+type source interface {
+	ingest.Source
+	Close() error
+}
+
+// check validates the flags, returning an error for a usage mistake
+func (f ingestFlags) check() error {
+	switch *f.kind {
+	case "":
+		return nil
+	case "kafka", "nats":
+	default:
+		return fmt.Errorf("unknown -ingest %q: use kafka or nats", *f.kind)
+	}
+	if *f.url == "" || *f.topic == "" {
+		return errors.New("-ingest requires -ingest-url and -ingest-topic")
+	}
+	if _, err := f.codecValue(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// codecValue returns the codec named by -ingest-codec
+func (f ingestFlags) codecValue() (ingest.Codec, error) {
+	switch *f.codec {
+	case "json":
+		return ingest.JSONCodec, nil
+	case "binary":
+		return ingest.BinaryCodec, nil
+	}
+	return nil, fmt.Errorf("unknown -ingest-codec %q: use json or binary", *f.codec)
+}
+
+// open connects to the broker
+func (f ingestFlags) open() (source, error) {
+	if *f.kind == "kafka" {
+		return newKafkaSource(*f.url, *f.topic, *f.group), nil
+	}
+	return newNATSSource(*f.url, *f.topic, *f.group)
+}
+
+var (
+	ingestVarsOnce sync.Once
+	ingestVars     *expvar.Map // "veclite_ingest": the Stats of the running consumer
+)
+
+// publishIngest publishes the stats of consumer, including its lag, under the
+// "veclite_ingest" expvar map
+func publishIngest(consumer *ingest.Consumer) {
+	ingestVarsOnce.Do(func() { ingestVars = expvar.NewMap("veclite_ingest") })
+	ingestVars.Set("stats", expvar.Func(func() any { return consumer.Stats() }))
+}
+
+// startIngest runs the ingestion worker configured by f until ctx is done
+// The returned channel receives the error that stopped it (nil once ctx is
+// done); an invalid message or a failure to connect stops it
+func startIngest(ctx context.Context, db *veclite.VecLite, f ingestFlags, stderr io.Writer) <-chan error {
+	done := make(chan error, 1)
+	if *f.kind == "" {
+		done <- nil
+		return done
+	}
+	codec, _ := f.codecValue() // Checked by check
+	src, err := f.open()
+	if err != nil {
+		done <- fmt.Errorf("ingest: %w", err)
+		return done
+	}
+	consumer, err := ingest.NewConsumer(db, src, ingest.Config{
+		Codec:     codec,
+		BatchSize: *f.batch,
+		OnError:   func(err error) { fmt.Fprintf(stderr, "veclite-server: ingest: %v\n", err) },
+	})
+	if err != nil {
+		src.Close()
+		done <- fmt.Errorf("ingest: %w", err)
+		return done
+	}
+	publishIngest(consumer)
+	fmt.Fprintf(stderr, "veclite-server: ingesting %s %s as %s\n", *f.kind, *f.topic, *f.group)
+	go func() {
+		err := consumer.Run(ctx)
+		src.Close()
+		if ctx.Err() != nil {
+			err = nil
+		}
+		if err != nil {
+			err = fmt.Errorf("ingest: %w", err)
+		}
+		done <- err
+	}()
+	return done
+}
+
+// serveMetrics serves the expvar variables, among them the database counters
+// ("veclite") and the ingestion stats ("veclite_ingest"), at /debug/vars on
+// addr until ctx is done
+func serveMetrics(ctx context.Context, addr string, stderr io.Writer) (net.Addr, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(stderr, "veclite-server: metrics: %v\n", err)
+		}
+	}()
+	return lis.Addr(), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/monishSR/veclite/pkg/ingest"
+)
+
+// kafkaSource consumes a Kafka topic as a member of a consumer group,
+// committing partition offsets with the group coordinator
+type kafkaSource struct {
+	reader *kafka.Reader
+}
+
+// newKafkaSource joins group on topic at the comma-separated brokers
+func newKafkaSource(brokers, topic, group string) *kafkaSource {
+	return &kafkaSource{reader: kafka.NewReader(kafka.ReaderConfig{
+		Brokers: strings.Split(brokers, ","),
+		Topic:   topic,
+		GroupID: group,
+	})}
+}
+
+// kafkaDrain bounds the wait for more messages once a batch has one
+const kafkaDrain = 50 * time.Millisecond
+
+// Fetch waits for one message, then takes those already buffered up to max
+func (s *kafkaSource) Fetch(ctx context.Context, max int) ([]ingest.Message, error) {
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []ingest.Message{fromKafka(msg)}
+	for len(msgs) < max {
+		drainCtx, cancel := context.WithTimeout(ctx, kafkaDrain)
+		msg, err := s.reader.FetchMessage(drainCtx)
+		cancel()
+		if err != nil {
+			break // Nothing buffered; the batch is complete
+		}
+		msgs = append(msgs, fromKafka(msg))
+	}
+	return msgs, nil
+}
+
+// Commit commits the offsets of msgs with the group
+func (s *kafkaSource) Commit(ctx context.Context, msgs []ingest.Message) error {
+	commits := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		commits[i] = kafka.Message{Topic: msg.Topic, Partition: int(msg.Partition), Offset: msg.Offset}
+	}
+	return s.reader.CommitMessages(ctx, commits...)
+}
+
+// Lag returns the lag the reader reported for the last fetched message
+func (s *kafkaSource) Lag(ctx context.Context) (int64, error) {
+	return s.reader.Stats().Lag, nil
+}
+
+// Close leaves the consumer group
+func (s *kafkaSource) Close() error {
+	return s.reader.Close()
+}
+
+// fromKafka converts a Kafka message
+func fromKafka(msg kafka.Message) ingest.Message {
+	return ingest.Message{Topic: msg.Topic, Partition: int32(msg.Partition), Offset: msg.Offset, Key: msg.Key, Value: msg.Value}
+}
@@ -0,0 +1,157 @@
+// Command veclite-server serves a VecLite database over gRPC
+//
+// Usage:
+//
+//	veclite-server [flags] <db>
+//
+// The service is defined in pkg/server/veclitepb/veclite.proto. The server
+// stops gracefully on SIGINT or SIGTERM, closing the database
+//
+// With -ingest the server also consumes vector records from a Kafka topic or
+// a NATS JetStream subject (see package ingest), committing offsets only once
+// the writes are durable; -metrics serves expvar counters, among them the
+// consumer's lag, at /debug/vars
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"google.golang.org/grpc"
+
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/pkg/server"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stderr, nil))
+}
+
+// run serves the database named in args until ctx is done and returns the
+// process exit code; ready, if not nil, receives the listening address
+func run(ctx context.Context, args []string, stderr io.Writer, ready chan<- net.Addr) int {
+	flags := flag.NewFlagSet("veclite-server", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", ":50051", "Address to listen on")
+	indexType := flags.String("index", "hnsw", "Index type: flat, hnsw, ivf or ivfpq")
+	dimension := flags.Int("dim", 0, "Vector dimension (default: read from the footer index of an existing database)")
+	m := flags.Int("m", 16, "HNSW: max connections per node")
+	efConstruction := flags.Int("ef-construction", 200, "HNSW: candidate list size during construction")
+	efSearch := flags.Int("ef-search", 50, "HNSW: candidate list size during search")
+	nClusters := flags.Int("nclusters", 100, "IVF: number of clusters")
+	metricsAddr := flags.String("metrics", "", "Address serving expvar metrics at /debug/vars (default: none)")
+	ingestFlags := addIngestFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite-server [flags] <db>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Serves <db> over gRPC, creating it if it does not exist (which requires -dim).")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if err := ingestFlags.check(); err != nil {
+		fmt.Fprintf(stderr, "veclite-server: %v\n", err)
+		return 2
+	}
+
+	config := veclite.DefaultConfig()
+	config.DataPath = flags.Arg(0)
+	config.IndexType = *indexType
+	config.Dimension = *dimension
+	if config.Dimension <= 0 {
+		dim, err := storage.FooterDimension(config.DataPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "veclite-server: cannot read dimension from footer (%v); pass -dim\n", err)
+			return 2
+		}
+		config.Dimension = dim
+	}
+	config.M = *m
+	config.EfConstruction = *efConstruction
+	config.EfSearch = *efSearch
+	config.NClusters = *nClusters
+	config.Expvar = *metricsAddr != ""
+
+	db, err := veclite.New(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite-server: %v\n", err)
+		return 1
+	}
+
+	// The worker stops the server when it fails, and the server the worker
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if *metricsAddr != "" {
+		metrics, err := serveMetrics(ctx, *metricsAddr, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "veclite-server: metrics: %v\n", err)
+			db.Close()
+			return 1
+		}
+		fmt.Fprintf(stderr, "veclite-server: metrics on %s\n", metrics)
+	}
+	ingested := startIngest(ctx, db, ingestFlags, stderr)
+	ingestErr := make(chan error, 1)
+	go func() {
+		err := <-ingested
+		if err != nil {
+			fmt.Fprintf(stderr, "veclite-server: %v\n", err)
+			cancel()
+		}
+		ingestErr <- err
+	}()
+
+	code := serve(ctx, db, config.DataPath, *addr, stderr, ready)
+	cancel()
+	if err := <-ingestErr; err != nil && code == 0 {
+		code = 1
+	}
+	if err := db.Close(); err != nil {
+		fmt.Fprintf(stderr, "veclite-server: close failed: %v\n", err)
+		return 1
+	}
+	return code
+}
+
+// serve runs the gRPC server for the database at dbPath on addr until ctx is done
+func serve(ctx context.Context, db *veclite.VecLite, dbPath, addr string, stderr io.Writer, ready chan<- net.Addr) int {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite-server: %v\n", err)
+		return 1
+	}
+	gs := grpc.NewServer()
+	server.New(db).Register(gs)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		gs.GracefulStop() // Lets in-flight calls finish before the database closes
+	}()
+	if ready != nil {
+		ready <- lis.Addr()
+	}
+	fmt.Fprintf(stderr, "veclite-server: serving %s on %s\n", dbPath, lis.Addr())
+	if err := gs.Serve(lis); err != nil {
+		fmt.Fprintf(stderr, "veclite-server: %v\n", err)
+		return 1
+	}
+	<-stopped
+	return 0
+}
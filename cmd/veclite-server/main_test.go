@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/monishSR/veclite/pkg/ingest"
+	"github.com/monishSR/veclite/pkg/server/veclitepb"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func TestRun_Usage(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run(context.Background(), nil, &stderr, nil); code != 2 {
+		t.Errorf("Expected exit code 2 without a database, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: veclite-server") {
+		t.Errorf("Expected usage, got %q", stderr.String())
+	}
+
+	stderr.Reset()
+	dbPath := filepath.Join(t.TempDir(), "new.db")
+	if code := run(context.Background(), []string{dbPath}, &stderr, nil); code != 2 {
+		t.Errorf("Expected exit code 2 for a new database without -dim, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "pass -dim") {
+		t.Errorf("Expected a hint to pass -dim, got %q", stderr.String())
+	}
+}
+
+func TestRun_IngestFlags(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ingest.db")
+	for _, args := range [][]string{
+		{"-dim", "4", "-ingest", "amqp", "-ingest-url", "localhost", "-ingest-topic", "vectors", dbPath},
+		{"-dim", "4", "-ingest", "kafka", "-ingest-url", "localhost:9092", dbPath},
+		{"-dim", "4", "-ingest", "nats", "-ingest-url", "nats://localhost", "-ingest-topic", "vectors", "-ingest-codec", "xml", dbPath},
+	} {
+		var stderr bytes.Buffer
+		if code := run(context.Background(), args, &stderr, nil); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d (%s)", args, code, stderr.String())
+		}
+	}
+}
+
+// stubSource serves no messages
+type stubSource struct{}
+
+func (stubSource) Fetch(ctx context.Context, max int) ([]ingest.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stubSource) Commit(ctx context.Context, msgs []ingest.Message) error { return nil }
+
+func TestServeMetrics(t *testing.T) {
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "metrics.db")
+	config.Dimension = 4
+	config.IndexType = "flat"
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	consumer, err := ingest.NewConsumer(db, stubSource{}, ingest.Config{})
+	if err != nil {
+		t.Fatalf("NewConsumer failed: %v", err)
+	}
+	publishIngest(consumer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, err := serveMetrics(ctx, "127.0.0.1:0", io.Discard)
+	if err != nil {
+		t.Fatalf("serveMetrics failed: %v", err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var vars struct {
+		Ingest struct {
+			Stats ingest.Stats `json:"stats"`
+		} `json:"veclite_ingest"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("Failed to decode metrics: %v", err)
+	}
+	if vars.Ingest.Stats.Lag != -1 {
+		t.Errorf("Expected the unknown lag of a fresh consumer, got %+v", vars.Ingest.Stats)
+	}
+}
+
+func TestRun_Serve(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "served.db")
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan net.Addr, 1)
+	done := make(chan int, 1)
+	var stderr bytes.Buffer
+	go func() {
+		done <- run(ctx, []string{"-addr", "127.0.0.1:0", "-dim", "4", "-index", "flat", dbPath}, &stderr, ready)
+	}()
+
+	var addr net.Addr
+	select {
+	case addr = <-ready:
+	case code := <-done:
+		t.Fatalf("Server exited with code %d: %s", code, stderr.String())
+	}
+	conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	client := veclitepb.NewVecLiteClient(conn)
+	if _, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 1, Vector: []float32{1, 2, 3, 4}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	cancel()
+
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Server did not stop")
+	}
+
+	// The database was closed with the vector
+	stderr.Reset()
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		done <- run(ctx, []string{"-addr", "127.0.0.1:0", "-index", "flat", dbPath}, &stderr, ready)
+	}()
+	select {
+	case addr = <-ready:
+	case code := <-done:
+		t.Fatalf("Server exited with code %d: %s", code, stderr.String())
+	}
+	conn2, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn2.Close()
+	resp, err := veclitepb.NewVecLiteClient(conn2).Get(ctx, &veclitepb.GetRequest{Id: 1})
+	if err != nil || len(resp.Vector) != 4 || resp.Vector[3] != 4 {
+		t.Errorf("Expected vector 1 after a restart, got %v (%v)", resp, err)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/monishSR/veclite/pkg/ingest"
+)
+
+// natsSource consumes a NATS JetStream subject through a durable pull
+// consumer, acknowledging messages on commit
+// Messages are identified by their stream sequence number, which serves as
+// the offset of partition 0
+type natsSource struct {
+	conn *nats.Conn
+	sub  *nats.Subscription
+
+	mu      sync.Mutex
+	pending map[uint64]*nats.Msg // Fetched, unacknowledged messages by stream sequence
+}
+
+// newNATSSource binds the durable pull consumer durable to subject on the
+// JetStream server at url
+func newNATSSource(url, subject, durable string) (*natsSource, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sub, err := js.PullSubscribe(subject, durable)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsSource{conn: conn, sub: sub, pending: make(map[uint64]*nats.Msg)}, nil
+}
+
+// natsPoll bounds one pull request; Fetch returns no messages when it expires
+const natsPoll = 5 * time.Second
+
+// Fetch pulls up to max messages, returning those available as soon as there is one
+func (s *natsSource) Fetch(ctx context.Context, max int) ([]ingest.Message, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, natsPoll)
+	defer cancel()
+	fetched, err := s.sub.Fetch(max, nats.Context(pollCtx))
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := make([]ingest.Message, 0, len(fetched))
+	for _, msg := range fetched {
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, fmt.Errorf("message on %s without JetStream metadata: %w", msg.Subject, err)
+		}
+		s.pending[meta.Sequence.Stream] = msg
+		msgs = append(msgs, ingest.Message{Topic: msg.Subject, Offset: int64(meta.Sequence.Stream), Value: msg.Data})
+	}
+	return msgs, nil
+}
+
+// Commit acknowledges msgs, waiting for the server to confirm each
+func (s *natsSource) Commit(ctx context.Context, msgs []ingest.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range msgs {
+		seq := uint64(msg.Offset)
+		pending, ok := s.pending[seq]
+		if !ok {
+			continue // Acknowledged by an earlier attempt
+		}
+		if err := pending.AckSync(nats.Context(ctx)); err != nil {
+			return err
+		}
+		delete(s.pending, seq)
+	}
+	return nil
+}
+
+// Lag returns the messages of the consumer not yet delivered
+func (s *natsSource) Lag(ctx context.Context) (int64, error) {
+	info, err := s.sub.ConsumerInfo()
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.NumPending), nil
+}
+
+// Close closes the connection to the server
+func (s *natsSource) Close() error {
+	s.conn.Close()
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// dbFlags are the flags describing how a database was created, shared by the
+// commands that open one
+type dbFlags struct {
+	indexType      *string
+	dimension      *int
+	m              *int
+	efConstruction *int
+	nClusters      *int
+}
+
+// addDBFlags registers the database flags on flags
+func addDBFlags(flags *flag.FlagSet) *dbFlags {
+	return &dbFlags{
+		indexType:      flags.String("index", "", "Index type: flat, hnsw, ivf or ivfpq (default: detected from existing sidecars)"),
+		dimension:      flags.Int("dim", 0, "Vector dimension (default: read from the footer index)"),
+		m:              flags.Int("m", 16, "HNSW: max connections per node"),
+		efConstruction: flags.Int("ef-construction", 200, "HNSW: candidate list size during construction"),
+		nClusters:      flags.Int("nclusters", 100, "IVF: number of clusters (at least the old index's)"),
+	}
+}
+
+// config builds the configuration of the database at dbPath from the flags,
+// detecting the index type and dimension if they were not given
+func (f *dbFlags) config(dbPath string) (*veclite.Config, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+
+	config := f.newConfig(dbPath, *f.dimension)
+	if *f.indexType == "" {
+		config.IndexType = detectIndexType(dbPath)
+	}
+	if config.Dimension <= 0 {
+		dim, err := storage.FooterDimension(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read dimension from footer (%v); pass -dim", err)
+		}
+		config.Dimension = dim
+	}
+	return config, nil
+}
+
+// newConfig builds the configuration of a new database at dbPath from the
+// flags, with dimension unless -dim is given and a flat index unless -index is
+func (f *dbFlags) newConfig(dbPath string, dimension int) *veclite.Config {
+	config := veclite.DefaultConfig()
+	config.DataPath = dbPath
+	if *f.indexType != "" {
+		config.IndexType = *f.indexType
+	}
+	config.Dimension = dimension
+	if *f.dimension > 0 {
+		config.Dimension = *f.dimension
+	}
+	config.M = *f.m
+	config.EfConstruction = *f.efConstruction
+	config.EfSearch = *f.efConstruction
+	config.NClusters = *f.nClusters
+	return config
+}
+
+// detectIndexType guesses the index type of dbPath from the sidecars next to it
+func detectIndexType(dbPath string) string {
+	if _, err := os.Stat(dbPath + ".graph"); err == nil {
+		return "hnsw"
+	}
+	if _, err := os.Stat(dbPath + ".ivfpq"); err == nil {
+		return "ivfpq"
+	}
+	if _, err := os.Stat(dbPath + ".ivf"); err == nil {
+		return "ivf"
+	}
+	return "flat"
+}
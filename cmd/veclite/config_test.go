@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDetectIndexType(t *testing.T) {
+	dir := t.TempDir()
+	if got := detectIndexType(dir + "/db"); got != "flat" {
+		t.Errorf("Expected flat without sidecars, got %s", got)
+	}
+	if err := os.WriteFile(dir+"/db.ivf", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectIndexType(dir + "/db"); got != "ivf" {
+		t.Errorf("Expected ivf, got %s", got)
+	}
+}
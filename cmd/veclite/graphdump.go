@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runGraphDump implements "veclite graph-dump"
+func runGraphDump(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("graph-dump", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	db := addDBFlags(flags)
+	format := flags.String("format", "json", "Output format: json or dot")
+	level := flags.Int("level", -1, "Only export edges of this graph level (default: all levels)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite graph-dump [flags] <db>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Exports the HNSW graph of <db> (entry point, node levels and neighbor lists)")
+		fmt.Fprintln(stderr, "as JSON or Graphviz DOT, with edge distances if the graph was saved with")
+		fmt.Fprintln(stderr, "Config.EdgeWeights. The database must not be open.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*format != "json" && *format != "dot") {
+		flags.Usage()
+		return 2
+	}
+	config, err := db.config(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	if config.IndexType != "hnsw" {
+		fmt.Fprintf(stderr, "veclite: graph-dump requires an hnsw database, got %s\n", config.IndexType)
+		return 1
+	}
+
+	topology, err := veclite.DumpGraph(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: graph-dump failed: %v\n", err)
+		return 1
+	}
+	if *level >= 0 {
+		topology = filterLevel(topology, *level)
+	}
+
+	w := bufio.NewWriter(stdout)
+	if *format == "dot" {
+		writeDOT(w, topology)
+	} else {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(topology); err != nil {
+			fmt.Fprintf(stderr, "veclite: %v\n", err)
+			return 1
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// filterLevel keeps only the nodes present at level and their edges at that level
+// Neighbors of the returned nodes are indexed by level with lower levels left empty
+func filterLevel(topology *veclite.GraphTopology, level int) *veclite.GraphTopology {
+	filtered := *topology
+	filtered.Nodes = nil
+	for _, node := range topology.Nodes {
+		if node.Level < level {
+			continue
+		}
+		filteredNode := veclite.GraphNode{ID: node.ID, Level: node.Level, Neighbors: make([][]uint64, level+1)}
+		if level < len(node.Neighbors) {
+			filteredNode.Neighbors[level] = node.Neighbors[level]
+		}
+		if level < len(node.Distances) {
+			filteredNode.Distances = make([][]float32, level+1)
+			filteredNode.Distances[level] = node.Distances[level]
+		}
+		filtered.Nodes = append(filtered.Nodes, filteredNode)
+	}
+	return &filtered
+}
+
+// writeDOT writes topology as a Graphviz digraph
+// The entry point is drawn as a double circle; edges above level 0 are labeled
+// with their level, and edges with a persisted weight with their distance
+func writeDOT(w io.Writer, topology *veclite.GraphTopology) {
+	fmt.Fprintln(w, "digraph hnsw {")
+	fmt.Fprintln(w, "  node [shape=circle];")
+	for _, node := range topology.Nodes {
+		shape := ""
+		if node.ID == topology.EntryPoint {
+			shape = ", shape=doublecircle"
+		}
+		fmt.Fprintf(w, "  %d [label=\"%d (L%d)\"%s];\n", node.ID, node.ID, node.Level, shape)
+	}
+	for _, node := range topology.Nodes {
+		for level, neighbors := range node.Neighbors {
+			for i, neighbor := range neighbors {
+				var labels []string
+				if level > 0 {
+					labels = append(labels, fmt.Sprintf("L%d", level))
+				}
+				if level < len(node.Distances) && node.Distances[level][i] >= 0 {
+					labels = append(labels, strconv.FormatFloat(float64(node.Distances[level][i]), 'g', 4, 32))
+				}
+				if len(labels) == 0 {
+					fmt.Fprintf(w, "  %d -> %d;\n", node.ID, neighbor)
+				} else {
+					fmt.Fprintf(w, "  %d -> %d [label=\"%s\"];\n", node.ID, neighbor, strings.Join(labels, " "))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func TestGraphDump_JSON(t *testing.T) {
+	config := createHNSWDB(t, 10)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"graph-dump", config.DataPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var topology veclite.GraphTopology
+	if err := json.Unmarshal(stdout.Bytes(), &topology); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, stdout.String())
+	}
+	if len(topology.Nodes) != 10 || topology.M != 8 {
+		t.Errorf("Expected 10 nodes with M=8, got %d nodes with M=%d", len(topology.Nodes), topology.M)
+	}
+}
+
+func TestGraphDump_DOT(t *testing.T) {
+	config := createHNSWDB(t, 10)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"graph-dump", "-format", "dot", "-level", "0", config.DataPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "digraph hnsw {") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("Expected a digraph, got:\n%s", out)
+	}
+	if !strings.Contains(out, "shape=doublecircle") || !strings.Contains(out, " -> ") {
+		t.Errorf("Expected entry point and edges, got:\n%s", out)
+	}
+	if strings.Contains(out, "[label=\"L") {
+		t.Errorf("Expected only level 0 edges, got:\n%s", out)
+	}
+	for i := 1; i <= 10; i++ {
+		if !strings.Contains(out, fmt.Sprintf("  %d [label=", i)) {
+			t.Errorf("Missing node %d", i)
+		}
+	}
+}
+
+func TestFilterLevel(t *testing.T) {
+	topology := &veclite.GraphTopology{
+		EntryPoint: 1,
+		MaxLevel:   1,
+		Nodes: []veclite.GraphNode{
+			{ID: 1, Level: 1, Neighbors: [][]uint64{{2}, {3}}},
+			{ID: 2, Level: 0, Neighbors: [][]uint64{{1}}},
+			{ID: 3, Level: 1, Neighbors: [][]uint64{{1}, {1}}},
+		},
+	}
+	filtered := filterLevel(topology, 1)
+	if len(filtered.Nodes) != 2 || filtered.Nodes[0].ID != 1 || filtered.Nodes[1].ID != 3 {
+		t.Fatalf("Expected nodes 1 and 3, got %+v", filtered.Nodes)
+	}
+	if len(filtered.Nodes[0].Neighbors[0]) != 0 || filtered.Nodes[0].Neighbors[1][0] != 3 {
+		t.Errorf("Expected only level 1 edges, got %v", filtered.Nodes[0].Neighbors)
+	}
+	if len(topology.Nodes) != 3 {
+		t.Error("filterLevel should not modify its input")
+	}
+}
+
+func TestGraphDump_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"graph-dump", "-format", "svg", "db"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown format, got %d", code)
+	}
+}
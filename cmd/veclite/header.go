@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runHeader implements "veclite header"
+func runHeader(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("header", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "Print the header as JSON")
+	formats := flags.Bool("formats", false, "Print the documentation of every header format instead of reading a file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite header [flags] <file>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Prints the header of a data, .graph or .ivf file: the format version, the")
+		fmt.Fprintln(stderr, "fixed fields and the file info block (creator, creation time, parameters).")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *formats {
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return 2
+		}
+		return printHeaderFormats(stdout, stderr, *asJSON)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	header, err := veclite.ReadFileHeader(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		return writeJSON(stdout, stderr, header)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	if header.Version == 0 {
+		fmt.Fprintf(w, "%s file without a header (legacy layout)\n", header.Format)
+	} else {
+		fmt.Fprintf(w, "%s file, version %d\n", header.Format, header.Version)
+	}
+	for _, field := range header.Fields {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", field.Name, field.Value, field.Doc)
+	}
+	if header.Info == nil {
+		fmt.Fprintln(w, "no file info (written by a version that predates it)")
+	} else {
+		fmt.Fprintf(w, "creator\t%s\n", header.Info.Creator)
+		if !header.Info.Created.IsZero() {
+			fmt.Fprintf(w, "created\t%s\n", header.Info.Created.Format(time.RFC3339))
+		}
+		if len(header.Info.Params) > 0 {
+			fmt.Fprintln(w, "params")
+		}
+		for _, param := range header.Info.Params {
+			fmt.Fprintf(w, "  %s\t%s\n", param.Name, param.Value)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printHeaderFormats prints the fixed fields of every header format
+func printHeaderFormats(stdout, stderr io.Writer, asJSON bool) int {
+	formats := veclite.HeaderFormats()
+	if asJSON {
+		return writeJSON(stdout, stderr, formats)
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for n, format := range formats {
+		if n > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s file header\n", format.Format)
+		for _, field := range format.Fields {
+			fmt.Fprintf(w, "  %s\t%s\n", field.Name, field.Doc)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "A file info block of tag (uint16), length (uint32), value entries follows the")
+	fmt.Fprintln(w, "fixed fields: creator (1), creation time in Unix nanoseconds (2), name=value")
+	fmt.Fprintln(w, "parameter (3). Data files hold it in a record of type 2, the first record.")
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeJSON prints v as indented JSON
+func writeJSON(stdout, stderr io.Writer, v any) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func TestHeader(t *testing.T) {
+	config := createHNSWDB(t, 10)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"header", config.DataPath + ".graph"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"graph file, version 3", "node_count", "creator", "veclite", "ef_construction"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, out)
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"header", "-json", config.DataPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var header veclite.FileHeader
+	if err := json.Unmarshal(stdout.Bytes(), &header); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, stdout.String())
+	}
+	if header.Format != "data" || header.Version != 5 || header.Info == nil {
+		t.Fatalf("Expected a version 5 data file with file info, got %+v", header)
+	}
+	if index, _ := header.Info.Param("index"); index != "hnsw" || header.Info.Created.IsZero() {
+		t.Errorf("Expected the index type and creation time in the file info, got %+v", header.Info)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"header", "-formats"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, want := range []string{"data file header", "graph file header", "ivf file header", "id_limit"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected %q in the format documentation, got:\n%s", want, stdout.String())
+		}
+	}
+
+	if code := run([]string{"header", config.DataPath + ".missing"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for a missing file, got %d", code)
+	}
+}
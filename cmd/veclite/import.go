@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+	vecio "github.com/monishSR/veclite/pkg/veclite/io"
+)
+
+// runImport implements "veclite import"
+// Exit codes: 0 = imported, 1 = import failed, 2 = usage
+func runImport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	db := addDBFlags(flags)
+	format := flags.String("format", "", "File format: npy, npz, jsonl or csv (default: detected from the extension)")
+	firstID := flags.Uint64("first-id", 1, "NumPy: ID of the first vector when the file has no IDs")
+	batchSize := flags.Int("batch", 1000, "Records applied per batch")
+	upsert := flags.Bool("upsert", false, "Replace vectors whose ID is already stored instead of failing")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite import [flags] <db> <file>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Inserts the vectors of a NumPy .npy/.npz, JSON Lines or CSV file into <db>,")
+		fmt.Fprintln(stderr, "creating it (flat index and the file's dimension by default) if it does not exist.")
+		fmt.Fprintln(stderr, "The database must not be open.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	dbPath, path := flags.Arg(0), flags.Arg(1)
+
+	file, err := vecio.OpenFile(path, *format, *firstID)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	config, err := db.config(dbPath)
+	var reader vecio.Reader = file
+	if errors.Is(err, os.ErrNotExist) {
+		// Size a new database by the first record
+		first, readErr := file.Read()
+		if readErr == io.EOF {
+			fmt.Fprintf(stderr, "veclite: %s has no vectors\n", path)
+			return 1
+		}
+		if readErr != nil {
+			fmt.Fprintf(stderr, "veclite: %v\n", readErr)
+			return 1
+		}
+		config, err = db.newConfig(dbPath, len(first.Vector)), nil
+		reader = &peekedReader{Reader: file, first: &first}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+
+	database, err := veclite.New(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	imported, err := vecio.Import(database, reader, vecio.ImportOptions{BatchSize: *batchSize, Upsert: *upsert})
+	if closeErr := database.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close database: %w", closeErr)
+	}
+	fmt.Fprintf(stdout, "Imported %d vectors from %s into %s\n", imported, path, dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// peekedReader returns a record read ahead of r before the rest of r
+type peekedReader struct {
+	vecio.Reader
+	first *vecio.Record // Not yet returned (nil = returned)
+}
+
+func (r *peekedReader) Read() (vecio.Record, error) {
+	if first := r.first; first != nil {
+		r.first = nil
+		return *first, nil
+	}
+	return r.Reader.Read()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "vectors.csv")
+	if err := os.WriteFile(csvPath, []byte("id,a,b,c\n1,1,0,0\n2,0,1,0\n3,0,0,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "import.db")
+
+	// Creates the database with the file's dimension
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"import", "-batch", "2", dbPath, csvPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("import failed with %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Imported 3 vectors") {
+		t.Errorf("Unexpected output: %s", stdout.String())
+	}
+
+	// Stored IDs fail unless upserting
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"import", dbPath, csvPath}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "already exists") {
+		t.Errorf("Expected an already exists failure, got %d: %s", code, stderr.String())
+	}
+	if code := run([]string{"import", "-upsert", dbPath, csvPath}, &stdout, &stderr); code != 0 {
+		t.Errorf("import -upsert failed with %d: %s", code, stderr.String())
+	}
+
+	config := veclite.DefaultConfig()
+	config.DataPath = dbPath
+	config.Dimension = 3
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 3 {
+		t.Errorf("Size = %d, want 3", db.Size())
+	}
+	if vector, err := db.Get(2); err != nil || vector[1] != 1 {
+		t.Errorf("Get(2) = %v, %v", vector, err)
+	}
+
+	if code := run([]string{"import", dbPath}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected usage exit code 2, got %d", code)
+	}
+}
@@ -0,0 +1,67 @@
+// Command veclite provides maintenance and recovery tools for VecLite databases
+//
+// Usage:
+//
+//	veclite <command> [flags] <db>
+//
+// Run "veclite help" for the list of commands
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is one veclite subcommand
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands maps subcommand names to their implementations
+var commands = map[string]command{
+	"graph-dump":    {"Export the HNSW graph as JSON or Graphviz DOT", runGraphDump},
+	"header":        {"Print the self-describing header of a data, .graph or .ivf file", runHeader},
+	"import":        {"Insert vectors from a NumPy, JSON Lines or CSV file", runImport},
+	"rebuild-index": {"Regenerate the footer index and index sidecars from the data file", runRebuildIndex},
+	"replay":        {"Re-run a query log and compare results and latency", runReplay},
+	"verify":        {"Check the structure of the data file and sidecars (JSON report)", runVerify},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches args to a subcommand and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "veclite: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdout, stderr)
+}
+
+// usage lists the available commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: veclite <command> [flags] <db>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-15s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "veclite <command> -h" for the flags of a command`)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "rebuild-index") {
+		t.Errorf("Expected usage to list commands, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"bogus"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 for unknown command, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "bogus"`) {
+		t.Errorf("Expected unknown command error, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runRebuildIndex implements "veclite rebuild-index"
+func runRebuildIndex(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rebuild-index", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	db := addDBFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite rebuild-index [flags] <db>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Regenerates the footer index and the HNSW/IVF sidecar of <db> from its data file.")
+		fmt.Fprintln(stderr, "The database must not be open.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	dbPath := flags.Arg(0)
+	config, err := db.config(dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Rebuilding %s (index: %s, dimension: %d)\n", dbPath, config.IndexType, config.Dimension)
+	lastPercent := map[string]int64{}
+	config.Progress = func(p veclite.Progress) {
+		if p.Total == 0 {
+			return
+		}
+		// Report every 10% per stage
+		percent := p.Done * 100 / p.Total
+		if last, seen := lastPercent[p.Stage]; seen && percent < last+10 && (p.Done < p.Total || last == 100) {
+			return
+		}
+		lastPercent[p.Stage] = percent
+		line := fmt.Sprintf("  %-6s %d/%d (%d%%)", p.Stage, p.Done, p.Total, percent)
+		if p.ETA > 0 {
+			line += fmt.Sprintf(", ETA %v", p.ETA.Round(time.Second))
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	if err := veclite.RebuildIndex(config, nil); err != nil {
+		fmt.Fprintf(stderr, "veclite: rebuild failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "Done")
+	return 0
+}
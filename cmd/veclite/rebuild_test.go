@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// createHNSWDB creates a closed HNSW database with n vectors of dimension 4
+func createHNSWDB(t *testing.T, n int) *veclite.Config {
+	t.Helper()
+	config := veclite.DefaultConfig()
+	config.DataPath = t.TempDir() + "/rebuild.db"
+	config.Dimension = 4
+	config.IndexType = "hnsw"
+	config.M = 8
+	config.EfConstruction = 50
+	config.EfSearch = 50
+
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := db.Insert(uint64(i), []float32{float32(i), 1, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return config
+}
+
+func TestRebuildIndex(t *testing.T) {
+	config := createHNSWDB(t, 20)
+
+	// Corrupt the graph sidecar and lose the footer index (20 entries + metadata),
+	// as after a crash before Close
+	if err := os.WriteFile(config.DataPath+".graph", []byte("garbage"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt graph: %v", err)
+	}
+	info, err := os.Stat(config.DataPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.Truncate(config.DataPath, info.Size()-(20*16+12)); err != nil {
+		t.Fatalf("Failed to drop footer: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"rebuild-index", "-m", "8", "-ef-construction", "50", config.DataPath}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "pass -dim") {
+		t.Fatalf("Expected failure asking for -dim without a footer, got %d: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"rebuild-index", "-dim", "4", "-m", "8", "-ef-construction", "50", config.DataPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("rebuild-index failed with %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "index: hnsw") || !strings.Contains(out, "index  20/20 (100%)") || !strings.Contains(out, "Done") {
+		t.Errorf("Unexpected progress output:\n%s", out)
+	}
+
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Opening rebuilt database failed: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 20 {
+		t.Errorf("Expected 20 vectors after rebuild, got %d", db.Size())
+	}
+	results, err := db.Search([]float32{7, 1, 0, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 7 {
+		t.Errorf("Expected ID 7 from rebuilt graph, got %v (err %v)", results, err)
+	}
+}
+
+func TestRebuildIndex_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"rebuild-index"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 without a database, got %d", code)
+	}
+	if code := run([]string{"rebuild-index", "/does/not/exist.db"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for a missing database, got %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runReplay implements "veclite replay"
+// Exit codes: 0 = replayed, 1 = replay failed or overlap below -min-overlap, 2 = usage
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	db := addDBFlags(flags)
+	logPath := flags.String("log", "", "Query log to replay (default: <db>.queries)")
+	efSearch := flags.Int("ef-search", 0, "HNSW: candidate list size during search (default: -ef-construction)")
+	nProbe := flags.Int("nprobe", 0, "IVF: clusters probed per search (default: the index default)")
+	minOverlap := flags.Float64("min-overlap", 0, "Fail if the mean result overlap is below this fraction (0..1)")
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite replay [flags] <db>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Re-runs the searches of a query log (recorded with QueryLogConfig.FullVectors)")
+		fmt.Fprintln(stderr, "against <db> and compares result overlap and latency with the log.")
+		fmt.Fprintln(stderr, "The database must not be open.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	dbPath := flags.Arg(0)
+	config, err := db.config(dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	if *efSearch > 0 {
+		config.EfSearch = *efSearch
+	}
+	config.NProbe = *nProbe
+	if *logPath == "" {
+		*logPath = dbPath + ".queries"
+	}
+
+	entries, err := veclite.ReadQueryLog(*logPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	database, err := veclite.New(config)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	report, err := database.Replay(context.Background(), entries)
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: replay failed: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "veclite: %v\n", err)
+			return 1
+		}
+	} else {
+		printReplayReport(stdout, report)
+	}
+	if report.Overlap < *minOverlap {
+		fmt.Fprintf(stderr, "veclite: result overlap %.1f%% is below %.1f%%\n", report.Overlap*100, *minOverlap*100)
+		return 1
+	}
+	return 0
+}
+
+// printReplayReport prints report as a table
+func printReplayReport(w io.Writer, report *veclite.ReplayReport) {
+	fmt.Fprintf(w, "Replayed %d of %d queries (%d skipped, %d errors)\n", report.Replayed, report.Queries, report.Skipped, report.Errors)
+	fmt.Fprintf(w, "Result overlap: %.1f%% (%d identical, Kendall tau %.2f)\n", report.Overlap*100, report.Identical, report.KendallTau)
+	fmt.Fprintf(w, "Latency  %10s %10s %10s %10s %10s\n", "mean", "p50", "p95", "p99", "max")
+	for _, row := range []struct {
+		name    string
+		summary veclite.LatencySummary
+	}{{"logged", report.Logged}, {"replay", report.Replay}} {
+		s := row.summary
+		fmt.Fprintf(w, "  %-6s %10v %10v %10v %10v %10v\n", row.name, s.Mean, s.P50, s.P95, s.P99, s.Max)
+	}
+}
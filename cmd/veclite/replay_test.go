@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// recordQueries runs n searches against the HNSW database of config with the
+// query log enabled
+func recordQueries(t *testing.T, config *veclite.Config, n int) {
+	t.Helper()
+	logged := *config
+	logged.QueryLog = &veclite.QueryLogConfig{FullVectors: true}
+	db, err := veclite.New(&logged)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if _, err := db.Search([]float32{float32(i) + 0.1, 1, 0, 0}, 3); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	config := createHNSWDB(t, 30)
+	recordQueries(t, config, 10)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"replay", "-dim", "4", "-m", "8", "-ef-construction", "50", "-ef-search", "20", "-min-overlap", "0.9", config.DataPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("replay failed with %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "Replayed 10 of 10 queries") || !strings.Contains(out, "Result overlap: 100.0%") || !strings.Contains(out, "replay") {
+		t.Errorf("Unexpected report:\n%s", out)
+	}
+
+	stdout.Reset()
+	code = run([]string{"replay", "-json", "-log", config.DataPath + ".queries", config.DataPath}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("replay -json failed with %d: %s", code, stderr.String())
+	}
+	var report veclite.ReplayReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, stdout.String())
+	}
+	if report.Replayed != 10 || report.Overlap != 1 {
+		t.Errorf("Unexpected JSON report: %+v", report)
+	}
+}
+
+func TestReplay_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"replay"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 without a database, got %d", code)
+	}
+	config := createHNSWDB(t, 5)
+	if code := run([]string{"replay", config.DataPath}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), ".queries") {
+		t.Errorf("Expected exit code 1 for a missing query log, got %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runVerify implements "veclite verify"
+// Exit codes: 0 = no issues left, 1 = issues left or verification failed, 2 = usage
+func runVerify(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	db := addDBFlags(flags)
+	deep := flags.Bool("deep", false, "Read every record and check its framing, its checksum and the graph structure")
+	repair := flags.Bool("repair", false, "Apply safe fixes for repairable issues")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: veclite verify [flags] <db>")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Checks <db> for inconsistencies between the data file, its footer index and")
+		fmt.Fprintln(stderr, "the sidecars, and prints a JSON report. The database must not be open.")
+		fmt.Fprintln(stderr, "With -deep, the CRC32C checksum of every record is validated too; data")
+		fmt.Fprintln(stderr, "files written before version 5 have none until they are compacted.")
+		fmt.Fprintln(stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	config, err := db.config(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+
+	report, err := veclite.Verify(config, veclite.VerifyOptions{Deep: *deep, Repair: *repair})
+	if err != nil {
+		fmt.Fprintf(stderr, "veclite: verify failed: %v\n", err)
+		return 1
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintf(stderr, "veclite: %v\n", err)
+		return 1
+	}
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
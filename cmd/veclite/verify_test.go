@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// runVerifyJSON runs "veclite verify" with args and decodes its report
+func runVerifyJSON(t *testing.T, args ...string) (int, veclite.VerifyReport) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"verify"}, args...), &stdout, &stderr)
+	var report veclite.VerifyReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON output (exit %d, stderr %q): %v\n%s", code, stderr.String(), err, stdout.String())
+	}
+	return code, report
+}
+
+func TestVerify(t *testing.T) {
+	config := createHNSWDB(t, 10)
+
+	code, report := runVerifyJSON(t, "--deep", "-m", "8", "-ef-construction", "50", config.DataPath)
+	if code != 0 || !report.OK || !report.Deep || report.Vectors != 10 || report.Checksums < report.Records || report.Records < 10 {
+		t.Fatalf("Expected clean report, got exit %d: %+v", code, report)
+	}
+
+	if err := os.WriteFile(config.DataPath+".graph", []byte("garbage"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt graph: %v", err)
+	}
+	code, report = runVerifyJSON(t, "-m", "8", "-ef-construction", "50", config.DataPath)
+	if code != 1 || report.OK || len(report.Issues) != 1 || report.Issues[0].Check != "graph" || !report.Issues[0].Repairable {
+		t.Fatalf("Expected a repairable graph issue, got exit %d: %+v", code, report)
+	}
+
+	code, report = runVerifyJSON(t, "--repair", "-m", "8", "-ef-construction", "50", config.DataPath)
+	if code != 0 || !report.OK || !report.Issues[0].Repaired {
+		t.Fatalf("Expected repair to succeed, got exit %d: %+v", code, report)
+	}
+	if code, _ := runVerifyJSON(t, "--deep", config.DataPath); code != 0 {
+		t.Errorf("Expected clean database after repair, got exit %d", code)
+	}
+}
+
+func TestVerify_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"verify"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 without a database, got %d", code)
+	}
+}
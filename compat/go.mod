@@ -0,0 +1,11 @@
+module github.com/msr23/veclite
+
+go 1.21
+
+require github.com/monishSR/veclite v0.0.0
+
+require github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+
+// The forwarding API builds against the tree it ships in; a published release
+// pins the matching github.com/monishSR/veclite version instead
+replace github.com/monishSR/veclite => ../
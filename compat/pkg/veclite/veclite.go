@@ -0,0 +1,45 @@
+// Package veclite forwards the API of VecLite under its old module path,
+// github.com/msr23/veclite, so builds importing that path keep working
+//
+// Deprecated: import github.com/monishSR/veclite instead. Types are aliases,
+// so values are interchangeable between the two paths during the move
+package veclite
+
+import (
+	"github.com/monishSR/veclite"
+)
+
+// VecLite is an embedded vector database, see New
+type VecLite = veclite.VecLite
+
+// Config configures a database, see DefaultConfig
+type Config = veclite.Config
+
+// SearchResult is one result of a search: ID and distance to the query
+type SearchResult = veclite.SearchResult
+
+// Batch is a list of writes applied together by VecLite.Apply
+type Batch = veclite.Batch
+
+// Errors matched with errors.Is; they are the errors of the new module path
+var (
+	ErrNotFound          = veclite.ErrNotFound
+	ErrAlreadyExists     = veclite.ErrAlreadyExists
+	ErrDimensionMismatch = veclite.ErrDimensionMismatch
+	ErrNoMetadata        = veclite.ErrNoMetadata
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return veclite.DefaultConfig()
+}
+
+// New creates or opens the database described by config (nil = DefaultConfig)
+func New(config *Config) (*VecLite, error) {
+	return veclite.New(config)
+}
+
+// Open opens the database at dataPath with the default configuration
+func Open(dataPath string) (*VecLite, error) {
+	return veclite.Open(dataPath)
+}
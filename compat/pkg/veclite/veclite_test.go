@@ -0,0 +1,33 @@
+package veclite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/monishSR/veclite"
+)
+
+func TestForwarding(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "old.db")
+	config.Dimension = 2
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	// Values are those of the new module path
+	var _ *veclite.VecLite = db
+	if err := db.Insert(1, []float32{1, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	results, err := db.Search([]float32{1, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Fatalf("Expected vector 1, got %v (%v)", results, err)
+	}
+	if err := db.Insert(1, []float32{1, 0}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+}
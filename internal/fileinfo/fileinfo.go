@@ -0,0 +1,187 @@
+// Package fileinfo implements the self-describing block of VecLite file
+// headers: the creator, the creation time and the parameters a file was
+// written with, encoded as tag-length-value entries
+// Readers skip tags they don't know, so entries can be added without a new
+// file version
+package fileinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Tags of the entries of an info block: tag (uint16), value length (uint32), value
+const (
+	tagCreator = uint16(1) // Value: creator string
+	tagCreated = uint16(2) // Value: creation time, int64 Unix nanoseconds
+	tagParam   = uint16(3) // Value: "name=value"
+)
+
+// MaxSize bounds the encoded size of an info block, so a corrupted or hostile
+// length fails to read instead of driving a huge allocation
+const MaxSize = 64 << 10
+
+// modulePath is the module whose version identifies the creator
+const modulePath = "github.com/monishSR/veclite"
+
+// Info is the self-describing block of a file header
+type Info struct {
+	Creator string    // Module version and Go version of the binary that wrote the file
+	Created time.Time // When the file was written
+	Params  []Param   // Parameters the file was written with, in order
+}
+
+// Param is one named parameter of an info block
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Header is the decoded header of a VecLite file, for display
+type Header struct {
+	Format  string  // "data", "graph" or "ivf"
+	Version int     // Version of the format (0 = legacy headerless data file)
+	Fields  []Field // Fixed fields in file order
+	Info    *Info   // Self-describing block (nil = the file predates it)
+}
+
+// Field is one fixed field of a file header
+type Field struct {
+	Name  string
+	Value string
+	Doc   string // Layout and meaning of the field
+}
+
+// Fields returns docs, the fields of a header format, with the values decoded
+// from a file in the same order
+func Fields(docs []Field, values ...string) []Field {
+	fields := make([]Field, len(docs))
+	for n, field := range docs {
+		if n < len(values) {
+			field.Value = values[n]
+		}
+		fields[n] = field
+	}
+	return fields
+}
+
+// New returns the info block of a file written now by this binary
+func New(params ...Param) *Info {
+	return &Info{Creator: Creator(), Created: time.Now().UTC(), Params: params}
+}
+
+// Creator describes this binary: the VecLite module version it was built
+// from ("(devel)" outside of a tagged build) and the Go version
+func Creator() string {
+	version := "(devel)"
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "veclite " + version
+	}
+	if build.Main.Path == modulePath && build.Main.Version != "" {
+		version = build.Main.Version
+	}
+	for _, dep := range build.Deps {
+		if dep.Path == modulePath && dep.Version != "" {
+			version = dep.Version
+		}
+	}
+	return fmt.Sprintf("veclite %s (%s)", version, build.GoVersion)
+}
+
+// Param returns the value of the parameter name
+func (i *Info) Param(name string) (string, bool) {
+	for _, p := range i.Params {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Marshal encodes the entries of the block
+func (i *Info) Marshal() []byte {
+	var buf []byte
+	entry := func(tag uint16, value []byte) {
+		buf = binary.LittleEndian.AppendUint16(buf, tag)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(value)))
+		buf = append(buf, value...)
+	}
+	entry(tagCreator, []byte(i.Creator))
+	if !i.Created.IsZero() {
+		entry(tagCreated, binary.LittleEndian.AppendUint64(nil, uint64(i.Created.UnixNano())))
+	}
+	for _, p := range i.Params {
+		entry(tagParam, []byte(p.Name+"="+p.Value))
+	}
+	return buf
+}
+
+// Unmarshal decodes the entries of a block encoded by Marshal
+func Unmarshal(data []byte) (*Info, error) {
+	info := &Info{}
+	for len(data) > 0 {
+		if len(data) < 6 {
+			return nil, errors.New("truncated info entry")
+		}
+		tag := binary.LittleEndian.Uint16(data)
+		length := binary.LittleEndian.Uint32(data[2:])
+		data = data[6:]
+		if uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("info entry of %d bytes exceeds the %d bytes left", length, len(data))
+		}
+		value := data[:length]
+		data = data[length:]
+		switch tag {
+		case tagCreator:
+			info.Creator = string(value)
+		case tagCreated:
+			if len(value) != 8 {
+				return nil, fmt.Errorf("invalid creation time of %d bytes", len(value))
+			}
+			info.Created = time.Unix(0, int64(binary.LittleEndian.Uint64(value))).UTC()
+		case tagParam:
+			name, v, _ := strings.Cut(string(value), "=")
+			info.Params = append(info.Params, Param{Name: name, Value: v})
+		}
+	}
+	return info, nil
+}
+
+// Write writes the block with a uint32 length prefix and returns the bytes written
+func Write(w io.Writer, info *Info) (int64, error) {
+	data := info.Marshal()
+	if len(data) > MaxSize {
+		return 0, fmt.Errorf("info block of %d bytes exceeds %d", len(data), MaxSize)
+	}
+	buf := binary.LittleEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	if _, err := w.Write(append(buf, data...)); err != nil {
+		return 0, fmt.Errorf("failed to write info block: %w", err)
+	}
+	return int64(len(buf) + len(data)), nil
+}
+
+// Read reads a block written by Write and returns the bytes read
+func Read(r io.Reader) (*Info, int64, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, 0, fmt.Errorf("failed to read info block length: %w", err)
+	}
+	if length > MaxSize {
+		return nil, 0, fmt.Errorf("info block of %d bytes exceeds %d", length, MaxSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 0, fmt.Errorf("failed to read info block: %w", err)
+	}
+	info, err := Unmarshal(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid info block: %w", err)
+	}
+	return info, 4 + int64(length), nil
+}
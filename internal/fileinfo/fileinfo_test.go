@@ -0,0 +1,54 @@
+package fileinfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInfo_RoundTrip(t *testing.T) {
+	info := New(Param{Name: "index", Value: "hnsw"}, Param{Name: "expr", Value: "a=b"})
+	if !strings.HasPrefix(info.Creator, "veclite ") {
+		t.Errorf("Expected a veclite creator, got %q", info.Creator)
+	}
+
+	var buf bytes.Buffer
+	n, err := Write(&buf, info)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("Write returned %d, %v for %d bytes", n, err, buf.Len())
+	}
+	// Entries of unknown tags are skipped
+	data := buf.Bytes()
+	data = binary.LittleEndian.AppendUint16(data, 99)
+	data = binary.LittleEndian.AppendUint32(data, 3)
+	data = append(data, "new"...)
+	binary.LittleEndian.PutUint32(data, uint32(len(data)-4))
+
+	got, n, err := Read(bytes.NewReader(data))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("Read returned %d, %v for %d bytes", n, err, len(data))
+	}
+	if got.Creator != info.Creator || !got.Created.Equal(info.Created) || !reflect.DeepEqual(got.Params, info.Params) {
+		t.Errorf("Expected %+v, got %+v", info, got)
+	}
+	if value, ok := got.Param("expr"); !ok || value != "a=b" {
+		t.Errorf("Expected parameter expr = a=b, got %q (%v)", value, ok)
+	}
+}
+
+func TestInfo_Hostile(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"huge block":     binary.LittleEndian.AppendUint32(nil, MaxSize+1),
+		"short block":    binary.LittleEndian.AppendUint32(nil, 10),
+		"long entry":     {10, 0, 0, 0, 1, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0},
+		"short entry":    {3, 0, 0, 0, 1, 0, 0},
+		"creation time":  {7, 0, 0, 0, 2, 0, 1, 0, 0, 0, 0},
+		"missing length": {1, 0},
+	} {
+		if _, _, err := Read(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
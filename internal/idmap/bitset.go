@@ -0,0 +1,65 @@
+package idmap
+
+import "math/bits"
+
+// Bitset is a set of dense internal IDs, e.g. the visited set of a graph search
+// The zero value is an empty set; Set and Test need the ID to be below Len()
+type Bitset struct {
+	words []uint64
+}
+
+// NewBitset creates a set able to hold IDs below n
+func NewBitset(n int) *Bitset {
+	b := &Bitset{}
+	b.Grow(n)
+	return b
+}
+
+// Grow makes the set able to hold IDs below n
+func (b *Bitset) Grow(n int) {
+	if words := (n + 63) / 64; words > len(b.words) {
+		b.words = append(b.words, make([]uint64, words-len(b.words))...)
+	}
+}
+
+// Len returns the number of IDs the set can hold
+func (b *Bitset) Len() int {
+	return len(b.words) * 64
+}
+
+// Set adds id
+func (b *Bitset) Set(id uint32) {
+	b.words[id/64] |= 1 << (id % 64)
+}
+
+// Clear removes id
+func (b *Bitset) Clear(id uint32) {
+	b.words[id/64] &^= 1 << (id % 64)
+}
+
+// Test reports whether id is in the set
+func (b *Bitset) Test(id uint32) bool {
+	return int(id/64) < len(b.words) && b.words[id/64]&(1<<(id%64)) != 0
+}
+
+// TestAndSet adds id and reports whether it was already in the set
+func (b *Bitset) TestAndSet(id uint32) bool {
+	word, mask := id/64, uint64(1)<<(id%64)
+	seen := b.words[word]&mask != 0
+	b.words[word] |= mask
+	return seen
+}
+
+// Count returns the number of IDs in the set
+func (b *Bitset) Count() int {
+	n := 0
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// Reset empties the set, keeping its capacity
+func (b *Bitset) Reset() {
+	clear(b.words)
+}
@@ -0,0 +1,75 @@
+// Package idmap assigns dense internal IDs to sparse external IDs, so index
+// structures can use arrays and bitsets whatever ID scheme callers use
+package idmap
+
+// Dict maps external uint64 IDs to dense internal IDs (0..Cap()-1)
+// An internal ID stays assigned to its external ID until released; released
+// internal IDs are reused by later assignments so the range stays compact
+// Not safe for concurrent use
+type Dict struct {
+	internal map[uint64]uint32 // External -> internal
+	external []uint64          // Internal -> external (valid where used)
+	used     Bitset            // Internal IDs currently assigned
+	free     []uint32          // Released internal IDs, reused last-in first-out
+}
+
+// New creates an empty dictionary
+func New() *Dict {
+	return &Dict{internal: make(map[uint64]uint32)}
+}
+
+// Assign returns the internal ID of external, assigning one if needed
+func (d *Dict) Assign(external uint64) uint32 {
+	if id, ok := d.internal[external]; ok {
+		return id
+	}
+	var id uint32
+	if n := len(d.free); n > 0 {
+		id = d.free[n-1]
+		d.free = d.free[:n-1]
+		d.external[id] = external
+	} else {
+		id = uint32(len(d.external))
+		d.external = append(d.external, external)
+		d.used.Grow(len(d.external))
+	}
+	d.internal[external] = id
+	d.used.Set(id)
+	return id
+}
+
+// Lookup returns the internal ID of external
+func (d *Dict) Lookup(external uint64) (uint32, bool) {
+	id, ok := d.internal[external]
+	return id, ok
+}
+
+// External returns the external ID assigned to internal
+func (d *Dict) External(internal uint32) (uint64, bool) {
+	if int(internal) >= len(d.external) || !d.used.Test(internal) {
+		return 0, false
+	}
+	return d.external[internal], true
+}
+
+// Release frees the internal ID of external for reuse
+func (d *Dict) Release(external uint64) {
+	id, ok := d.internal[external]
+	if !ok {
+		return
+	}
+	delete(d.internal, external)
+	d.used.Clear(id)
+	d.free = append(d.free, id)
+}
+
+// Len returns the number of assigned IDs
+func (d *Dict) Len() int {
+	return len(d.internal)
+}
+
+// Cap returns one past the highest internal ID ever assigned, i.e. the size
+// of an array indexed by internal ID
+func (d *Dict) Cap() int {
+	return len(d.external)
+}
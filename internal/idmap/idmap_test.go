@@ -0,0 +1,58 @@
+package idmap
+
+import "testing"
+
+func TestDict_AssignAndRelease(t *testing.T) {
+	d := New()
+	if id := d.Assign(1 << 60); id != 0 {
+		t.Errorf("Expected internal ID 0, got %d", id)
+	}
+	if id := d.Assign(7); id != 1 {
+		t.Errorf("Expected internal ID 1, got %d", id)
+	}
+	if id := d.Assign(1 << 60); id != 0 {
+		t.Errorf("Expected the existing internal ID, got %d", id)
+	}
+	if external, ok := d.External(1); !ok || external != 7 {
+		t.Errorf("Expected external ID 7, got %d, %v", external, ok)
+	}
+
+	d.Release(1 << 60)
+	if _, ok := d.Lookup(1 << 60); ok {
+		t.Error("Expected released ID to be gone")
+	}
+	if _, ok := d.External(0); ok {
+		t.Error("Expected released internal ID to be unassigned")
+	}
+	if id := d.Assign(42); id != 0 {
+		t.Errorf("Expected released internal ID to be reused, got %d", id)
+	}
+	if d.Len() != 2 || d.Cap() != 2 {
+		t.Errorf("Expected len 2 and cap 2, got %d and %d", d.Len(), d.Cap())
+	}
+}
+
+func TestBitset(t *testing.T) {
+	b := NewBitset(100)
+	if b.Len() != 128 {
+		t.Errorf("Expected capacity 128, got %d", b.Len())
+	}
+	if b.TestAndSet(70) {
+		t.Error("Expected 70 to be new")
+	}
+	if !b.TestAndSet(70) || !b.Test(70) {
+		t.Error("Expected 70 to be set")
+	}
+	b.Set(3)
+	if b.Count() != 2 {
+		t.Errorf("Expected 2 IDs, got %d", b.Count())
+	}
+	if b.Test(1000) {
+		t.Error("Expected IDs beyond the capacity to be absent")
+	}
+	b.Clear(3)
+	b.Reset()
+	if b.Count() != 0 || b.Len() != 128 {
+		t.Errorf("Expected empty set keeping capacity, got %d IDs, capacity %d", b.Count(), b.Len())
+	}
+}
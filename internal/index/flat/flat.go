@@ -1,11 +1,15 @@
 package flat
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 
 	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/index/utils"
+	"github.com/monishSR/veclite/internal/profile"
 	"github.com/monishSR/veclite/internal/storage"
 	"github.com/monishSR/veclite/internal/vector"
 )
@@ -31,6 +35,13 @@ func NewFlatIndex(dimension int, storage *storage.Storage) *FlatIndex {
 
 // OpenFlatIndex opens an existing flat index and loads all vector IDs from storage.
 func OpenFlatIndex(dimension int, storage *storage.Storage) (*FlatIndex, error) {
+	return OpenFlatIndexFunc(dimension, storage, nil)
+}
+
+// OpenFlatIndexFunc is OpenFlatIndex over the IDs for which keep returns true
+// (nil keeps all). Used to search a storage file shared with another index's
+// internal records, e.g. IVF centroids.
+func OpenFlatIndexFunc(dimension int, storage *storage.Storage, keep func(id uint64) bool) (*FlatIndex, error) {
 	if storage == nil {
 		return nil, errors.New("storage is required for OpenFlatIndex")
 	}
@@ -49,6 +60,9 @@ func OpenFlatIndex(dimension int, storage *storage.Storage) (*FlatIndex, error)
 
 	// Validate dimension and populate IDs
 	for id, vec := range vectors {
+		if keep != nil && !keep(id) {
+			continue
+		}
 		if len(vec) != dimension {
 			return nil, fmt.Errorf("vector dimension mismatch: expected %d, got %d for ID %d", dimension, len(vec), id)
 		}
@@ -58,6 +72,23 @@ func OpenFlatIndex(dimension int, storage *storage.Storage) (*FlatIndex, error)
 	return f, nil
 }
 
+// OpenFlatIndexIDs is OpenFlatIndexFunc taking the IDs from the storage index
+// without reading any vectors, so it opens in time independent of the data size
+// Vector dimensions are not validated up front
+func OpenFlatIndexIDs(dimension int, storage *storage.Storage, keep func(id uint64) bool) (*FlatIndex, error) {
+	if storage == nil {
+		return nil, errors.New("storage is required for OpenFlatIndex")
+	}
+
+	f := NewFlatIndex(dimension, storage)
+	for _, id := range storage.IDs() {
+		if keep == nil || keep(id) {
+			f.ids[id] = true
+		}
+	}
+	return f, nil
+}
+
 // Insert adds a vector to the index.
 // It writes the vector to storage and records its ID.
 func (f *FlatIndex) Insert(id uint64, vec []float32) error {
@@ -75,60 +106,244 @@ func (f *FlatIndex) Insert(id uint64, vec []float32) error {
 	return nil
 }
 
+// InsertBatch adds vectors[i] under ids[i] like repeated Inserts, writing
+// them to storage in one append
+func (f *FlatIndex) InsertBatch(ids []uint64, vectors [][]float32) error {
+	for _, vec := range vectors {
+		if len(vec) != f.dimension {
+			return types.ErrDimensionMismatch
+		}
+	}
+	if f.storage == nil {
+		return errors.New("storage not available for FlatIndex")
+	}
+
+	if err := f.storage.WriteVectors(ids, vectors); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		f.ids[id] = true
+	}
+	return nil
+}
+
+// IndexExisting records the ID of a vector already persisted in storage
+func (f *FlatIndex) IndexExisting(id uint64, vec []float32) error {
+	if len(vec) != f.dimension {
+		return types.ErrDimensionMismatch
+	}
+	f.ids[id] = true
+	return nil
+}
+
 // Search finds the k nearest neighbors using brute force.
 // It reads vectors from storage (which uses the cache).
 func (f *FlatIndex) Search(query []float32, k int) ([]types.SearchResult, error) {
+	return f.SearchContext(context.Background(), query, k)
+}
+
+// SearchContext is Search with cancellation: the scan stops and ctx.Err() is
+// returned once ctx is done
+func (f *FlatIndex) SearchContext(ctx context.Context, query []float32, k int) ([]types.SearchResult, error) {
 	if len(query) != f.dimension {
 		return nil, types.ErrDimensionMismatch
 	}
 	if k <= 0 {
 		return nil, types.ErrInvalidK
 	}
+
+	return f.nearest(ctx, query, k, types.WantVectors(ctx))
+}
+
+// Candidates returns the n nearest vectors with their exact distances, nearest
+// first, without copying their vectors (n <= 0 = every vector)
+func (f *FlatIndex) Candidates(ctx context.Context, query []float32, n int) ([]types.SearchResult, error) {
+	if len(query) != f.dimension {
+		return nil, types.ErrDimensionMismatch
+	}
+	if n > 0 {
+		return f.nearest(ctx, query, n, false)
+	}
+	return f.scan(ctx, query, false)
+}
+
+// nearest returns the k nearest vectors sorted by distance, with a copy of
+// each vector if withVectors is set
+// When storage keeps block bounds (see storage.EnableBlockBounds), blocks are
+// visited nearest box first and the scan stops at the first box farther than
+// the k-th best distance so far. When it keeps pivots (see
+// storage.EnablePivots), vectors whose pivot bound is farther than that
+// distance are skipped without being read. The results are the same as a full
+// scan's
+func (f *FlatIndex) nearest(ctx context.Context, query []float32, k int, withVectors bool) ([]types.SearchResult, error) {
 	if f.storage == nil {
 		return nil, errors.New("storage not available for FlatIndex")
 	}
+	blocks := f.storage.Blocks()
+	pivots := f.storage.PivotQuery(query)
+	if blocks == nil && pivots != nil {
+		blocks = []storage.Block{{IDs: f.pivotOrder(pivots)}}
+	}
+	if blocks == nil {
+		results, err := f.scan(ctx, query, withVectors)
+		if err != nil {
+			return nil, err
+		}
+		if k < len(results) {
+			results = results[:k]
+		}
+		return results, nil
+	}
+
+	type boxDistance struct {
+		block int
+		bound float32
+	}
+	order := make([]boxDistance, len(blocks))
+	for i, b := range blocks {
+		order[i] = boxDistance{block: i}
+		if b.Min != nil {
+			order[i].bound = boxLowerBound(query, b.Min, b.Max)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].bound < order[j].bound })
+
+	p := profile.FromContext(ctx)
+	endStage := p.Start("flat.scan")
+	best := utils.NewCandidateHeap(k)
+	scanned := 0
+	for _, box := range order {
+		// Boxes are sorted, so no later box can hold a result either; a box at
+		// exactly the k-th distance may still hold a tie with a smaller ID
+		if best.Len() == k && box.bound > best.Peek().Distance {
+			break
+		}
+		for _, id := range blocks[box.block].IDs {
+			if !f.ids[id] {
+				continue
+			}
+			if pivots != nil && best.Len() == k {
+				if bound, ok := pivots.LowerBound(id); ok && bound > best.Peek().Distance {
+					continue
+				}
+			}
+			// Check for cancellation periodically rather than per vector
+			if scanned%256 == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+			scanned++
+			vec, err := f.storage.ReadVectorProfiled(id, p)
+			if err != nil {
+				fmt.Printf("Warning: Failed to read vector %d from storage during search: %v\n", id, err)
+				continue
+			}
+			p.AddDistances(1)
+			p.AddCandidates(1)
+			best.AddCandidate(utils.Candidate{ID: id, Distance: vector.L2Distance(query, vec)}, k)
+		}
+	}
+	endStage()
 
-	type result struct {
-		id       uint64
-		distance float32
-		vec      []float32
+	top := best.ExtractTop(k)
+	results := make([]types.SearchResult, len(top))
+	for i, c := range top {
+		results[i] = types.SearchResult{ID: c.ID, Distance: c.Distance}
+		if withVectors {
+			vec, err := f.storage.ReadVector(c.ID)
+			if err != nil {
+				return nil, err
+			}
+			results[i].Vector = vec
+		}
 	}
+	return results, nil
+}
 
-	results := make([]result, 0, len(f.ids))
+// pivotOrder returns the IDs by ascending pivot bound, so that the k-th best
+// distance tightens early and more of the later vectors are skipped
+func (f *FlatIndex) pivotOrder(pivots *storage.PivotQuery) []uint64 {
+	type idBound struct {
+		id    uint64
+		bound float32
+	}
+	bounds := make([]idBound, 0, len(f.ids))
 	for id := range f.ids {
-		vec, err := f.storage.ReadVector(id)
+		bound, _ := pivots.LowerBound(id)
+		bounds = append(bounds, idBound{id: id, bound: bound})
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].bound < bounds[j].bound })
+	ids := make([]uint64, len(bounds))
+	for i, b := range bounds {
+		ids[i] = b.id
+	}
+	return ids
+}
+
+// boxLowerBound returns the L2 distance from query to the nearest point of the
+// box [lo, hi], computed like vector.L2Distance so that rounding never makes
+// it exceed the distance to a vector in the box
+func boxLowerBound(query, lo, hi []float32) float32 {
+	var sum float32
+	for i, q := range query {
+		var diff float32
+		if q < lo[i] {
+			diff = lo[i] - q
+		} else if q > hi[i] {
+			diff = q - hi[i]
+		}
+		sum += diff * diff
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+// scan computes the distance from query to every vector and returns them
+// sorted by distance, with a copy of each vector if withVectors is set
+func (f *FlatIndex) scan(ctx context.Context, query []float32, withVectors bool) ([]types.SearchResult, error) {
+	if f.storage == nil {
+		return nil, errors.New("storage not available for FlatIndex")
+	}
+
+	p := profile.FromContext(ctx)
+	endStage := p.Start("flat.scan")
+	results := make([]types.SearchResult, 0, len(f.ids))
+	for id := range f.ids {
+		// Check for cancellation periodically rather than per vector
+		if len(results)%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		vec, err := f.storage.ReadVectorProfiled(id, p)
 		if err != nil {
 			// Log error but continue if a single vector read fails
 			fmt.Printf("Warning: Failed to read vector %d from storage during search: %v\n", id, err)
 			continue
 		}
 		dist := vector.L2Distance(query, vec)
-		// Copy vector to avoid external modifications
-		vecCopy := make([]float32, len(vec))
-		copy(vecCopy, vec)
-		results = append(results, result{id: id, distance: dist, vec: vecCopy})
+		p.AddDistances(1)
+		p.AddCandidates(1)
+		result := types.SearchResult{ID: id, Distance: dist}
+		if withVectors {
+			// Copy vector to avoid external modifications
+			result.Vector = make([]float32, len(vec))
+			copy(result.Vector, vec)
+		}
+		results = append(results, result)
 	}
 
-	// Sort by distance
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].distance < results[j].distance
-	})
+	endStage()
 
-	// Return top k
-	if k > len(results) {
-		k = len(results)
-	}
-
-	searchResults := make([]types.SearchResult, k)
-	for i := 0; i < k; i++ {
-		searchResults[i] = types.SearchResult{
-			ID:       results[i].id,
-			Distance: results[i].distance,
-			Vector:   results[i].vec,
+	// Sort by distance, breaking ties by ID so equal distances come back in a stable order
+	defer p.Start("flat.sort")()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
 		}
-	}
-
-	return searchResults, nil
+		return results[i].ID < results[j].ID
+	})
+	return results, nil
 }
 
 // ReadVector retrieves a vector by ID from storage.
@@ -137,7 +352,7 @@ func (f *FlatIndex) ReadVector(id uint64) ([]float32, error) {
 		return nil, errors.New("storage not available for FlatIndex")
 	}
 	if _, exists := f.ids[id]; !exists {
-		return nil, fmt.Errorf("vector with ID %d not found in index", id)
+		return nil, fmt.Errorf("vector with ID %d %w in index", id, types.ErrNotFound)
 	}
 	return f.storage.ReadVector(id)
 }
@@ -171,3 +386,15 @@ func (f *FlatIndex) Clear() error {
 
 	return nil
 }
+
+// LocalityOrder returns vector IDs in ascending order
+// A flat scan visits every vector, so ID order is as good as any and keeps
+// compaction output deterministic
+func (f *FlatIndex) LocalityOrder() []uint64 {
+	order := make([]uint64, 0, len(f.ids))
+	for id := range f.ids {
+		order = append(order, id)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	return order
+}
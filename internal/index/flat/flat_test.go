@@ -1,10 +1,15 @@
 package flat
 
 import (
+	"context"
+	"errors"
+	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/profile"
 	"github.com/monishSR/veclite/internal/storage"
 )
 
@@ -38,6 +43,38 @@ func TestFlatIndex_Insert(t *testing.T) {
 	}
 }
 
+func TestFlatIndex_InsertBatch(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	index := NewFlatIndex(3, store)
+	ids := []uint64{1, 2, 3}
+	vectors := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if err := index.InsertBatch(ids, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if index.Size() != 3 {
+		t.Errorf("Expected size 3, got %d", index.Size())
+	}
+	results, err := index.Search([]float32{0, 0.9, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 2 {
+		t.Errorf("Expected ID 2 to be nearest, got %v (%v)", results, err)
+	}
+
+	if err := index.InsertBatch([]uint64{4}, [][]float32{{1, 2}}); err != types.ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
 func TestFlatIndex_Search(t *testing.T) {
 	tmpFile := createTempFile(t)
 	defer os.Remove(tmpFile)
@@ -819,7 +856,6 @@ func TestFlatIndex_ReadVector_StorageReadError(t *testing.T) {
 	}
 }
 
-
 // Helper function to create a temporary file
 func createTempFile(t *testing.T) string {
 	tmpFile, err := os.CreateTemp("", "veclite_test_*.db")
@@ -829,3 +865,274 @@ func createTempFile(t *testing.T) string {
 	tmpFile.Close()
 	return tmpFile.Name()
 }
+
+func TestFlatIndex_LocalityOrder(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	index := NewFlatIndex(3, store)
+	for _, id := range []uint64{5, 1, 3} {
+		if err := index.Insert(id, []float32{1, 2, 3}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	order := index.LocalityOrder()
+	expected := []uint64{1, 3, 5}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, order)
+			break
+		}
+	}
+}
+
+func TestFlatIndex_SearchContext_Cancelled(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	index := NewFlatIndex(3, store)
+	for i := uint64(1); i <= 10; i++ {
+		if err := index.Insert(i, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := index.SearchContext(ctx, []float32{0, 0, 0}, 3); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	results, err := index.SearchContext(context.Background(), []float32{0, 0, 0}, 3)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != 1 {
+		t.Errorf("Unexpected results: %v", results)
+	}
+}
+
+func TestOpenFlatIndexFunc_FiltersIDs(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	for i := uint64(1); i <= 4; i++ {
+		if err := store.WriteVector(i, []float32{float32(i), 0, 0}); err != nil {
+			t.Fatalf("Failed to write vector: %v", err)
+		}
+	}
+
+	index, err := OpenFlatIndexFunc(3, store, func(id uint64) bool { return id%2 == 0 })
+	if err != nil {
+		t.Fatalf("OpenFlatIndexFunc failed: %v", err)
+	}
+	if index.Size() != 2 {
+		t.Errorf("Expected 2 kept vectors, got %d", index.Size())
+	}
+	results, err := index.Search([]float32{1, 0, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 2 {
+		t.Errorf("Expected nearest kept ID 2, got %v (%v)", results, err)
+	}
+
+	if err := index.IndexExisting(1, []float32{1, 0, 0}); err != nil {
+		t.Fatalf("IndexExisting failed: %v", err)
+	}
+	if err := index.IndexExisting(1, []float32{1}); err != types.ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	results, err = index.Search([]float32{1, 0, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("Expected ID 1 after IndexExisting, got %v (%v)", results, err)
+	}
+}
+
+func TestFlatIndex_Candidates(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	index := NewFlatIndex(4, store)
+	for i := uint64(1); i <= 10; i++ {
+		if err := index.Insert(i, []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	candidates, err := index.Candidates(context.Background(), []float32{3, 0, 0, 0}, 0)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	if len(candidates) != 10 || candidates[0].ID != 3 || candidates[0].Vector != nil {
+		t.Errorf("Expected all 10 vectors without vectors, nearest 3, got %+v", candidates)
+	}
+	if top, _ := index.Candidates(context.Background(), []float32{3, 0, 0, 0}, 3); len(top) != 3 {
+		t.Errorf("Expected 3 candidates, got %d", len(top))
+	}
+}
+
+func TestFlatIndex_BlockPruning(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	// Clustered vectors in insertion order, so each block covers one region
+	full := NewFlatIndex(4, store)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		center := float32(i / 100 * 10)
+		vec := []float32{center + rng.Float32(), center + rng.Float32(), rng.Float32(), rng.Float32()}
+		if err := full.Insert(uint64(i+1), vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	// Duplicate a vector so a tie at the k-th distance is resolved by ID
+	dup, _ := store.ReadVector(950)
+	if err := full.Insert(2000, dup); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := full.Delete(17); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	want, err := full.Search([]float32{45, 45, 0.5, 0.5}, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if err := store.EnableBlockBounds(50); err != nil {
+		t.Fatalf("EnableBlockBounds failed: %v", err)
+	}
+	pruned, err := OpenFlatIndexIDs(4, store, nil)
+	if err != nil {
+		t.Fatalf("OpenFlatIndexIDs failed: %v", err)
+	}
+	for _, query := range [][]float32{{45, 45, 0.5, 0.5}, dup, {-100, 0, 0, 0}} {
+		p := profile.New(false)
+		got, err := pruned.SearchContext(profile.NewContext(context.Background(), p), query, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		exact, _ := full.scan(context.Background(), query, true)
+		if !reflect.DeepEqual(got, exact[:10]) {
+			t.Errorf("Pruned results for %v differ from a full scan:\n%v\n%v", query, got, exact[:10])
+		}
+		if p.Counters.Distances >= 500 {
+			t.Errorf("Expected pruning to skip most blocks for %v, computed %d distances", query, p.Counters.Distances)
+		}
+	}
+	if got, _ := pruned.Search([]float32{45, 45, 0.5, 0.5}, 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pruned search differs from the search before pruning")
+	}
+	if got, _ := pruned.Candidates(context.Background(), dup, 3); len(got) != 3 || got[0].ID != 950 || got[1].ID != 2000 {
+		t.Errorf("Expected the duplicate pair first in ID order, got %v", got)
+	}
+}
+
+func TestFlatIndex_PivotPruning(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	// Clusters in random order, so block bounds would not help
+	index := NewFlatIndex(4, store)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		center := float32(rng.Intn(10) * 10)
+		vec := []float32{center + rng.Float32(), rng.Float32(), center + rng.Float32(), rng.Float32()}
+		if err := index.Insert(uint64(i+1), vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	queries := [][]float32{{45, 0.5, 45, 0.5}, {90.2, 0.1, 90.7, 0.3}, {-5, 3, 7, 1}}
+	var want [][]types.SearchResult
+	for _, query := range queries {
+		exact, _ := index.scan(context.Background(), query, true)
+		want = append(want, exact[:10])
+	}
+
+	if err := store.EnablePivots(4); err != nil {
+		t.Fatalf("EnablePivots failed: %v", err)
+	}
+	for i, query := range queries {
+		p := profile.New(false)
+		got, err := index.SearchContext(profile.NewContext(context.Background(), p), query, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Pivot-pruned results for %v differ from a full scan:\n%v\n%v", query, got, want[i])
+		}
+		if p.Counters.Distances >= 500 {
+			t.Errorf("Expected pivots to skip most vectors for %v, computed %d distances", query, p.Counters.Distances)
+		}
+	}
+
+	// Pivots combine with block bounds, and follow deletes
+	if err := store.EnableBlockBounds(100); err != nil {
+		t.Fatalf("EnableBlockBounds failed: %v", err)
+	}
+	if err := index.Delete(want[0][0].ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	got, err := index.Search(queries[0], 9)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want[0][1:]) {
+		t.Errorf("Expected the results without the deleted vector, got %v", got)
+	}
+}
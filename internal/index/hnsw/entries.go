@@ -0,0 +1,104 @@
+package hnsw
+
+import (
+	"context"
+	"sort"
+)
+
+// Entry-point redundancy: besides the entry point, searches may start from up
+// to numEntries-1 alternates, the next highest nodes of the graph, whichever
+// is closest to the query. A query far from the entry point (e.g. in another
+// cluster) then descends from a nearby node instead of crossing the graph
+// A search can also be seeded with a node known to be near the query (see
+// WithSeed), which usually skips the descent through the upper layers
+
+// higherEntry reports whether node a ranks before node b as an alternate
+// entry: higher level first, then lower ID
+func higherEntry(a, b *HNSWNode) bool {
+	if a.Level != b.Level {
+		return a.Level > b.Level
+	}
+	return a.ID < b.ID
+}
+
+// refreshEntries recomputes the alternate entries from all nodes
+func (h *HNSWIndex) refreshEntries() {
+	h.entries = nil
+	if h.numEntries <= 1 {
+		return
+	}
+	for id, node := range h.nodes {
+		if id != h.entryPoint {
+			h.offerEntry(node)
+		}
+	}
+}
+
+// offerEntry adds node to the alternate entries if it ranks among the highest
+func (h *HNSWIndex) offerEntry(node *HNSWNode) {
+	limit := h.numEntries - 1
+	if limit <= 0 || node.ID == h.entryPoint {
+		return
+	}
+	if len(h.entries) == limit && !higherEntry(node, h.nodes[h.entries[limit-1]]) {
+		return
+	}
+	i := sort.Search(len(h.entries), func(i int) bool { return higherEntry(node, h.nodes[h.entries[i]]) })
+	h.entries = append(h.entries, 0)
+	copy(h.entries[i+1:], h.entries[i:])
+	h.entries[i] = node.ID
+	if len(h.entries) > limit {
+		h.entries = h.entries[:limit]
+	}
+}
+
+// isEntry reports whether id is the entry point or an alternate entry
+func (h *HNSWIndex) isEntry(id uint64) bool {
+	return id == h.entryPoint || containsID(h.entries, id)
+}
+
+// closestEntry returns the entry point or alternate nearest to the query of s
+// and the level to descend from
+func (h *HNSWIndex) closestEntry(s scorer) (uint64, int) {
+	best, bestLevel := h.entryPoint, h.maxLevel
+	if len(h.entries) == 0 {
+		return best, bestLevel
+	}
+	bestDist := float32(-1)
+	for _, id := range append([]uint64{h.entryPoint}, h.entries...) {
+		node, exists := h.nodes[id]
+		if !exists {
+			continue
+		}
+		dist, ok := s.distance(id)
+		if !ok {
+			continue
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestLevel, bestDist = id, node.Level, dist
+		}
+	}
+	return best, bestLevel
+}
+
+// seedKey is the context key of the search seed
+type seedKey struct{}
+
+// WithSeed returns a copy of ctx starting HNSW searches from node id instead
+// of the entry point, e.g. the previous result of related queries
+// Seeds that are not in the graph are ignored
+func WithSeed(ctx context.Context, id uint64) context.Context {
+	return context.WithValue(ctx, seedKey{}, id)
+}
+
+// startNode returns the node a search scored by s starts from and the level
+// to descend from: the seed carried by ctx if it is in the graph, else the
+// closest entry
+func (h *HNSWIndex) startNode(ctx context.Context, s scorer) (uint64, int) {
+	if seed, ok := ctx.Value(seedKey{}).(uint64); ok {
+		if node, exists := h.nodes[seed]; exists {
+			return seed, node.Level
+		}
+	}
+	return h.closestEntry(s)
+}
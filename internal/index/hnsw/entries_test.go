@@ -0,0 +1,198 @@
+package hnsw
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// createClusteredHNSW creates an index with the given number of entry points
+// over two grids of n 4-dimensional vectors each, far apart: IDs 1..n near
+// the origin and n+1..2n near (1000, 0, 0, 0)
+func createClusteredHNSW(t *testing.T, n, entryPoints int) *HNSWIndex {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	t.Cleanup(func() {
+		os.Remove(tmpFile)
+		os.Remove(tmpFile + ".graph")
+	})
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	index, err := NewHNSWIndex(4, map[string]any{"M": 8, "EfConstruction": 50, "EfSearch": 20, "EntryPoints": entryPoints}, store)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+	for i := 1; i <= 2*n; i++ {
+		j := (i - 1) % n
+		vec := []float32{float32(j % 10), float32(j / 10), 0, 0}
+		if i > n {
+			vec[0] += 1000
+		}
+		if err := index.Insert(uint64(i), vec); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	return index
+}
+
+// checkEntries verifies the alternates are the highest nodes besides the
+// entry point, highest first
+func checkEntries(t *testing.T, index *HNSWIndex) {
+	t.Helper()
+	want := min(index.numEntries-1, len(index.nodes)-1)
+	if len(index.entries) != want {
+		t.Fatalf("Expected %d alternate entries, got %v", want, index.entries)
+	}
+	for i, id := range index.entries {
+		node, exists := index.nodes[id]
+		if !exists || id == index.entryPoint {
+			t.Fatalf("Alternate entry %d is deleted or the entry point", id)
+		}
+		if i > 0 && !higherEntry(index.nodes[index.entries[i-1]], node) {
+			t.Errorf("Expected alternates highest first, got %v", index.entries)
+		}
+	}
+	last := index.nodes[index.entries[len(index.entries)-1]]
+	for id, node := range index.nodes {
+		if id != index.entryPoint && !containsID(index.entries, id) && higherEntry(node, last) {
+			t.Errorf("Node %d (level %d) ranks above alternate %d (level %d)", id, node.Level, last.ID, last.Level)
+		}
+	}
+}
+
+func TestHNSWIndex_EntryPoints_Maintained(t *testing.T) {
+	index := createClusteredHNSW(t, 50, 4)
+	checkEntries(t, index)
+
+	// Deleting the entry point and an alternate picks new ones
+	if err := index.Delete(index.entryPoint); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := index.Delete(index.entries[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	checkEntries(t, index)
+
+	index.Repair()
+	checkEntries(t, index)
+
+	// Back to the single entry point
+	index.SetSearchParams(map[string]any{"EntryPoints": 1})
+	if index.entries != nil || index.Params()["EntryPoints"] != 1 {
+		t.Errorf("Expected no alternates with one entry point, got %v", index.entries)
+	}
+	index.SetSearchParams(map[string]any{"EntryPoints": 6})
+	checkEntries(t, index)
+
+	if err := index.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if index.entries != nil {
+		t.Errorf("Expected Clear to drop the alternates, got %v", index.entries)
+	}
+}
+
+func TestHNSWIndex_EntryPoints_ClosestEntry(t *testing.T) {
+	// Every node is an entry: the closest entry is the nearest neighbor
+	index := createClusteredHNSW(t, 20, 40)
+	for _, query := range [][]float32{{3.2, 1.1, 0, 0}, {1003.2, 1.1, 0, 0}} {
+		id, level := index.closestEntry(index.exactScorer(query, nil))
+		results, err := index.Search(query, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if id != results[0].ID || level != index.nodes[id].Level {
+			t.Errorf("Expected closest entry %d for %v, got %d (level %d)", results[0].ID, query, id, level)
+		}
+	}
+}
+
+func TestHNSWIndex_EntryPoints_Search(t *testing.T) {
+	// With enough alternates both clusters have one: searches start in the
+	// query's cluster instead of wherever the entry point landed
+	index := createClusteredHNSW(t, 100, 32)
+	for _, query := range [][]float32{{3.2, 1.1, 0, 0}, {1003.2, 1.1, 0, 0}} {
+		far := query[0] > 500
+		if id, _ := index.closestEntry(index.exactScorer(query, nil)); (id > 100) != far {
+			t.Errorf("Expected to start in the cluster of %v, got entry %d", query, id)
+		}
+		results, err := index.Search(query, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 5 {
+			t.Fatalf("Expected 5 results, got %d", len(results))
+		}
+		if (results[0].ID > 100) != far {
+			t.Errorf("Nearest result %d is in the other cluster than %v", results[0].ID, query)
+		}
+	}
+}
+
+func TestHNSWIndex_EntryPoints_Reload(t *testing.T) {
+	index := createClusteredHNSW(t, 30, 3)
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	loaded, err := OpenHNSWIndex(index.storage)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if loaded.entries != nil {
+		t.Errorf("Expected the entry point only until EntryPoints is set, got %v", loaded.entries)
+	}
+	loaded.SetSearchParams(map[string]any{"EntryPoints": 3})
+	checkEntries(t, loaded)
+}
+
+func TestHNSWIndex_SeededSearch(t *testing.T) {
+	index := createClusteredHNSW(t, 50, 1)
+	query := []float32{1004, 1, 0, 0} // Vector of ID 65
+
+	if index.entryPoint == 65 {
+		t.Skip("ID 65 became the entry point")
+	}
+
+	// Unlink ID 65 so only a search starting there can find it
+	for _, node := range index.nodes {
+		for level := range node.Neighbors {
+			kept := node.Neighbors[level][:0]
+			for _, neighborID := range node.Neighbors[level] {
+				if neighborID != 65 {
+					kept = append(kept, neighborID)
+				}
+			}
+			node.Neighbors[level] = kept
+		}
+	}
+
+	results, err := index.Search(query, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results[0].ID == 65 {
+		t.Fatalf("Expected the unlinked node to be unreachable without a seed")
+	}
+
+	results, err = index.SearchContext(WithSeed(context.Background(), 65), query, 3)
+	if err != nil {
+		t.Fatalf("Seeded search failed: %v", err)
+	}
+	if results[0].ID != 65 || results[0].Distance != 0 {
+		t.Errorf("Expected the seed 65 first, got %v", results)
+	}
+
+	// Unknown seeds fall back to the entry point
+	results, err = index.SearchContext(WithSeed(context.Background(), 999), query, 1)
+	if err != nil || len(results) != 1 || results[0].ID == 65 {
+		t.Errorf("Expected an unseeded search for an unknown seed, got %v (%v)", results, err)
+	}
+}
@@ -0,0 +1,156 @@
+package hnsw
+
+import (
+	"container/heap"
+	"context"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// Frontier is the retained state of a search that Next continues, so more
+// results can be loaded without repeating the traversal: the candidates
+// reached but not returned yet, and the nodes whose neighbors were not
+// explored yet
+// Each result Next returns is explored first, so results come nearest first
+// at a cost of one neighbor list per result
+// Nodes deleted since the search are skipped. Next takes the read lock of the
+// index like a search, but a Frontier is not safe for concurrent use
+type Frontier struct {
+	index   *HNSWIndex
+	query   []float32
+	scorer  scorer              // Distances of the search (PQ or exact)
+	seen    map[uint64]struct{} // Nodes whose distance is known, by ID (slots are reused)
+	pending candidateQueue      // Reached, not returned yet
+	open    candidateQueue      // Neighbors not explored yet
+}
+
+// SearchFrontier is SearchContext also returning the frontier of the search,
+// whose Next yields the following results
+func (h *HNSWIndex) SearchFrontier(ctx context.Context, query []float32, k int) ([]types.SearchResult, types.Frontier, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(query) != h.dimension {
+		return nil, nil, types.ErrDimensionMismatch
+	}
+	if k <= 0 {
+		return nil, nil, types.ErrInvalidK
+	}
+
+	f := &Frontier{index: h, query: append([]float32(nil), query...), seen: make(map[uint64]struct{})}
+	if len(h.nodes) == 0 {
+		return []types.SearchResult{}, f, nil
+	}
+	p := profile.FromContext(ctx)
+	candidates, err := h.searchCandidates(ctx, query, max(h.efSearch, k), p)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := h.topResults(query, candidates, k, types.WantVectors(ctx), p)
+	f.scorer = h.searchScorer(f.query, nil)
+
+	returned := make(map[uint64]struct{}, len(results))
+	for _, result := range results {
+		returned[result.ID] = struct{}{}
+	}
+	for _, cand := range candidates {
+		f.seen[cand.id] = struct{}{}
+		f.open = append(f.open, cand)
+		if _, done := returned[cand.id]; !done {
+			f.pending = append(f.pending, cand)
+		}
+	}
+	heap.Init(&f.open)
+	heap.Init(&f.pending)
+	return results, f, nil
+}
+
+// Next returns up to n further results, nearest first; fewer once every node
+// reachable from the search has been returned
+func (f *Frontier) Next(ctx context.Context, n int) ([]types.SearchResult, error) {
+	h := f.index
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	p := profile.FromContext(ctx)
+	results := make([]types.SearchResult, 0, max(n, 0))
+	for len(results) < n {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Explore while an unexplored node is at least as near as the best
+		// pending candidate, which then can't be beaten by a node not reached yet
+		for f.open.Len() > 0 && (f.pending.Len() == 0 || f.open[0].distance <= f.pending[0].distance) {
+			f.explore(heap.Pop(&f.open).(candidate), p)
+		}
+		if f.pending.Len() == 0 {
+			break
+		}
+		cand := heap.Pop(&f.pending).(candidate)
+		if _, exists := h.nodes[cand.id]; !exists {
+			continue // Deleted since
+		}
+		vec, err := h.storage.ReadVectorProfiled(cand.id, p)
+		if err != nil {
+			continue
+		}
+		distance := cand.distance
+		if f.scorer.table != nil {
+			distance = vector.L2Distance(f.query, vec) // Exact, as the vector is read anyway
+		}
+		results = append(results, types.SearchResult{
+			ID:       cand.id,
+			Distance: distance,
+			Vector:   append([]float32(nil), vec...),
+		})
+	}
+	return results, nil
+}
+
+// explore reaches the bottom-layer neighbors of cand not seen yet
+func (f *Frontier) explore(cand candidate, p *profile.Profile) {
+	node, exists := f.index.nodes[cand.id]
+	if !exists {
+		return
+	}
+	for _, neighborID := range node.Neighbors[0] {
+		if _, seen := f.seen[neighborID]; seen {
+			continue
+		}
+		f.seen[neighborID] = struct{}{}
+		s := f.scorer
+		s.p = p
+		dist, ok := s.distance(neighborID)
+		if !ok {
+			continue
+		}
+		p.AddCandidates(1)
+		heap.Push(&f.pending, candidate{id: neighborID, distance: dist})
+		heap.Push(&f.open, candidate{id: neighborID, distance: dist})
+	}
+}
+
+// candidateQueue is a min-heap of candidates, nearest first
+type candidateQueue []candidate
+
+func (q candidateQueue) Len() int { return len(q) }
+
+func (q candidateQueue) Less(i, j int) bool {
+	if q[i].distance != q[j].distance {
+		return q[i].distance < q[j].distance
+	}
+	return q[i].id < q[j].id
+}
+
+func (q candidateQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *candidateQueue) Push(x any) { *q = append(*q, x.(candidate)) }
+
+func (q *candidateQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,100 @@
+package hnsw
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+func TestHNSWIndex_SearchFrontier(t *testing.T) {
+	index := createClusteredHNSW(t, 100, 1)
+	query := []float32{4.2, 3.1, 0, 0}
+
+	results, frontier, err := index.SearchFrontier(context.Background(), query, 5)
+	if err != nil {
+		t.Fatalf("SearchFrontier failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+
+	// Continuing in pages yields every reachable vector once, nearest first
+	// within the cluster of the query
+	seen := make(map[uint64]bool)
+	all := results
+	for {
+		page, err := frontier.Next(context.Background(), 7)
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		all = append(all, page...)
+	}
+	for i, result := range all {
+		if seen[result.ID] {
+			t.Fatalf("ID %d returned twice", result.ID)
+		}
+		seen[result.ID] = true
+		if want := vector.L2Distance(query, result.Vector); result.Distance != want {
+			t.Errorf("Expected distance %f for ID %d, got %f", want, result.ID, result.Distance)
+		}
+		if i > 0 && i < 100 && result.Distance < all[i-1].Distance {
+			t.Errorf("Expected results nearest first within the cluster, %d came after %d", result.ID, all[i-1].ID)
+		}
+	}
+	if len(all) < 100 {
+		t.Errorf("Expected at least the 100 vectors of the cluster to be reached, got %d", len(all))
+	}
+
+	// The first pages match an exact ranking of the near cluster
+	exact := make([]float32, 0, 100)
+	for id := uint64(1); id <= 100; id++ {
+		vec, _ := index.ReadVector(id)
+		exact = append(exact, vector.L2Distance(query, vec))
+	}
+	sort.Slice(exact, func(i, j int) bool { return exact[i] < exact[j] })
+	for i := 0; i < 30; i++ {
+		if all[i].Distance != exact[i] {
+			t.Errorf("Expected distance %f at rank %d, got %f", exact[i], i, all[i].Distance)
+		}
+	}
+}
+
+func TestHNSWIndex_SearchFrontier_Deleted(t *testing.T) {
+	index := createClusteredHNSW(t, 20, 1)
+	query := []float32{1, 1, 0, 0}
+
+	results, frontier, err := index.SearchFrontier(context.Background(), query, 1)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SearchFrontier failed: %v (%v)", results, err)
+	}
+	// ID 12 is the second nearest
+	if err := index.Delete(12); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	page, err := frontier.Next(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	for _, result := range page {
+		if result.ID == 12 || result.ID == results[0].ID {
+			t.Errorf("Expected neither the deleted nor a returned ID, got %v", page)
+		}
+	}
+
+	// An empty index yields an exhausted frontier
+	if err := index.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	results, frontier, err = index.SearchFrontier(context.Background(), query, 3)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("Expected no results, got %v (%v)", results, err)
+	}
+	if page, err := frontier.Next(context.Background(), 3); err != nil || len(page) != 0 {
+		t.Errorf("Expected an exhausted frontier, got %v (%v)", page, err)
+	}
+}
@@ -5,10 +5,39 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+
+	"github.com/monishSR/veclite/internal/fileinfo"
+	"github.com/monishSR/veclite/internal/idmap"
+)
+
+// Graph file versions: version 2 follows every neighbor ID with the float32
+// distance of the edge (see Config "EdgeWeights"). Versions 3 and 4 are
+// versions 1 and 2 with a file info block (see fileinfo) after the header;
+// graph journals record the version without it
+const (
+	graphVersion        = 1
+	graphVersionWeights = 2
+	graphVersionInfo    = 2 // Added to the version of files with an info block
+)
+
+// Limits on the values read from graph files, so a corrupted or hostile file
+// fails to load instead of driving huge allocations
+const (
+	graphHeaderSize   = 48 // Magic through node count
+	minGraphNodeSize  = 20 // ID, level, and level 0 with its neighbor count
+	maxGraphDimension = 1 << 20
+	maxGraphM         = 1 << 16
+	maxGraphEf        = 1 << 24
+	maxGraphLevel     = 1 << 10
+	maxGraphNeighbors = 1 << 20
 )
 
-// writeGraphHeader writes the graph file header (magic, version, parameters, metadata)
+// writeGraphHeader writes the graph file header (magic, version, parameters,
+// metadata, info block)
 func (h *HNSWIndex) writeGraphHeader(w io.Writer) error {
 	// Write magic number for validation
 	magic := uint32(0x48534E57) // "HNSW" in ASCII
@@ -17,7 +46,11 @@ func (h *HNSWIndex) writeGraphHeader(w io.Writer) error {
 	}
 
 	// Write version (for future compatibility)
-	version := uint32(1)
+	version := uint32(graphVersion)
+	if h.edgeWeights {
+		version = graphVersionWeights
+	}
+	version += graphVersionInfo
 	if err := binary.Write(w, binary.LittleEndian, version); err != nil {
 		return fmt.Errorf("failed to write version: %w", err)
 	}
@@ -50,7 +83,29 @@ func (h *HNSWIndex) writeGraphHeader(w io.Writer) error {
 		return fmt.Errorf("failed to write node count: %w", err)
 	}
 
-	return nil
+	// Write the info block describing the file
+	_, err := fileinfo.Write(w, h.graphInfo())
+	return err
+}
+
+// graphInfo returns the info block of the graph file, with the creation time
+// of its first save so that saving the same graph writes the same bytes
+// Note: Assumes saveMu is held
+func (h *HNSWIndex) graphInfo() *fileinfo.Info {
+	info := fileinfo.New(
+		fileinfo.Param{Name: "index", Value: "hnsw"},
+		fileinfo.Param{Name: "dimension", Value: strconv.Itoa(h.dimension)},
+		fileinfo.Param{Name: "m", Value: strconv.Itoa(h.M)},
+		fileinfo.Param{Name: "ef_construction", Value: strconv.Itoa(h.efConstruction)},
+		fileinfo.Param{Name: "ef_search", Value: strconv.Itoa(h.efSearch)},
+		fileinfo.Param{Name: "edge_weights", Value: strconv.FormatBool(h.edgeWeights)},
+		fileinfo.Param{Name: "pq_subspaces", Value: strconv.Itoa(h.pqSubspaces)},
+	)
+	if h.created.IsZero() {
+		h.created = info.Created
+	}
+	info.Created = h.created
+	return info
 }
 
 // writeGraphNode writes a single node and its neighbors to the writer
@@ -78,16 +133,28 @@ func (h *HNSWIndex) writeGraphNode(w io.Writer, id uint64, node *HNSWNode) error
 			if err := binary.Write(w, binary.LittleEndian, neighborID); err != nil {
 				return fmt.Errorf("failed to write neighbor %d for node %d level %d: %w", neighborID, id, level, err)
 			}
+			if !h.edgeWeights {
+				continue
+			}
+			if err := binary.Write(w, binary.LittleEndian, h.edgeDistance(id, neighborID)); err != nil {
+				return fmt.Errorf("failed to write edge weight %d -> %d: %w", id, neighborID, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-// writeGraphNodes writes all nodes to the writer
+// writeGraphNodes writes all nodes to the writer in ID order, so the same
+// graph always produces the same file
 func (h *HNSWIndex) writeGraphNodes(w io.Writer) error {
-	for id, node := range h.nodes {
-		if err := h.writeGraphNode(w, id, node); err != nil {
+	ids := make([]uint64, 0, len(h.nodes))
+	for id := range h.nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if err := h.writeGraphNode(w, id, h.nodes[id]); err != nil {
 			return err
 		}
 	}
@@ -96,7 +163,15 @@ func (h *HNSWIndex) writeGraphNodes(w io.Writer) error {
 
 // SaveGraph saves the HNSW graph structure to disk
 // Graph file path is automatically derived from storage file path by appending ".graph"
+// With GraphJournal, the nodes changed since the last save are appended to a
+// journal (".graph.journal") instead, until it outgrows half the graph file
+// A trained PQ codebook is saved next to it (".pq")
 func (h *HNSWIndex) SaveGraph() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+
 	if h.storage == nil {
 		return errors.New("storage is required to save graph")
 	}
@@ -105,6 +180,19 @@ func (h *HNSWIndex) SaveGraph() error {
 	storagePath := h.storage.GetFilePath()
 	graphPath := storagePath + ".graph"
 
+	if h.journal && !h.rewrite {
+		appended, err := h.appendJournal(graphPath)
+		if err != nil {
+			return err
+		}
+		if appended {
+			return h.savePQ()
+		}
+	}
+	if err := h.removeJournal(graphPath); err != nil {
+		return err
+	}
+
 	file, err := os.Create(graphPath)
 	if err != nil {
 		return fmt.Errorf("failed to create graph file: %w", err)
@@ -120,13 +208,19 @@ func (h *HNSWIndex) SaveGraph() error {
 	if err := h.writeGraphNodes(file); err != nil {
 		return err
 	}
+	h.rewrite = false
+	h.dirty, h.removed = nil, nil
 
-	return nil
+	// PQ codebook and codes go to their own sidecar
+	return h.savePQ()
 }
 
 // LoadGraph loads the HNSW graph structure from disk
 // Graph file path is automatically derived from storage file path by appending ".graph"
 func (h *HNSWIndex) LoadGraph() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if h.storage == nil {
 		return errors.New("storage is required to load graph")
 	}
@@ -140,6 +234,10 @@ func (h *HNSWIndex) LoadGraph() error {
 		return fmt.Errorf("failed to open graph file: %w", err)
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat graph file: %w", err)
+	}
 
 	// Read and validate magic number
 	var magic uint32
@@ -155,9 +253,15 @@ func (h *HNSWIndex) LoadGraph() error {
 	if err := binary.Read(file, binary.LittleEndian, &version); err != nil {
 		return fmt.Errorf("failed to read version: %w", err)
 	}
-	if version != 1 {
+	hasInfo := version > graphVersionWeights
+	if hasInfo {
+		version -= graphVersionInfo
+	}
+	if version != graphVersion && version != graphVersionWeights {
 		return fmt.Errorf("unsupported graph file version: %d", version)
 	}
+	h.edgeWeights = version == graphVersionWeights
+	h.weights, h.damaged = nil, nil
 
 	// Read parameters
 	var dim, M, efConstruction, efSearch uint32
@@ -177,6 +281,16 @@ func (h *HNSWIndex) LoadGraph() error {
 	if err := binary.Read(file, binary.LittleEndian, &mL); err != nil {
 		return fmt.Errorf("failed to read mL: %w", err)
 	}
+	switch {
+	case dim == 0 || dim > maxGraphDimension:
+		return fmt.Errorf("invalid graph file: dimension %d", dim)
+	case M > maxGraphM:
+		return fmt.Errorf("invalid graph file: M %d exceeds %d", M, maxGraphM)
+	case efConstruction > maxGraphEf || efSearch > maxGraphEf:
+		return fmt.Errorf("invalid graph file: ef %d/%d exceeds %d", efConstruction, efSearch, maxGraphEf)
+	case math.IsNaN(mL) || mL < 0: // +Inf is the mL of M = 1
+		return fmt.Errorf("invalid graph file: mL %g", mL)
+	}
 
 	// Set all parameters from graph file (source of truth)
 	h.dimension = int(dim)
@@ -206,57 +320,213 @@ func (h *HNSWIndex) LoadGraph() error {
 	if err := binary.Read(file, binary.LittleEndian, &nodeCount); err != nil {
 		return fmt.Errorf("failed to read node count: %w", err)
 	}
+	if maxLevel < -1 || maxLevel > maxGraphLevel {
+		return fmt.Errorf("invalid graph file: max level %d", maxLevel)
+	}
+	headerSize := int64(graphHeaderSize)
+	if hasInfo {
+		fileInfo, n, err := fileinfo.Read(file)
+		if err != nil {
+			return fmt.Errorf("invalid graph file: %w", err)
+		}
+		headerSize += n
+		h.created = fileInfo.Created
+	}
+	if limit := (info.Size() - headerSize) / minGraphNodeSize; int64(nodeCount) > limit {
+		return fmt.Errorf("invalid graph file: %d nodes claimed, %d bytes hold at most %d", nodeCount, info.Size(), max(limit, 0))
+	}
 
 	h.entryPoint = entryPoint
 	h.maxLevel = int(maxLevel)
 	h.nodes = make(map[uint64]*HNSWNode, nodeCount)
+	h.slots = idmap.New()
 
 	// Read each node
 	for i := uint32(0); i < nodeCount; i++ {
-		var id uint64
-		var level int32
-		if err := binary.Read(file, binary.LittleEndian, &id); err != nil {
-			if err == io.EOF {
-				return fmt.Errorf("unexpected EOF while reading node %d", i)
-			}
-			return fmt.Errorf("failed to read node ID: %w", err)
+		node, err := h.readGraphNode(file)
+		if err == io.EOF {
+			return fmt.Errorf("unexpected EOF while reading node %d", i)
+		}
+		if err != nil {
+			return err
+		}
+		h.putNode(node)
+	}
+
+	// Changes saved since the graph file was written
+	if err := h.replayJournal(graphPath); err != nil {
+		return err
+	}
+	h.rewrite = false
+	h.dirty, h.removed = nil, nil
+
+	h.size = len(h.nodes)
+	h.refreshEntries()
+	if err := h.loadPQ(); err != nil {
+		h.pqSubspaces, h.codebook, h.codes = 0, nil, nil // Retrained from storage, see SetSearchParams
+	}
+	return nil
+}
+
+// GraphHeaderFields documents the fixed fields of the graph file header
+var GraphHeaderFields = []fileinfo.Field{
+	{Name: "magic", Doc: "uint32 0x48534E57 (\"HNSW\")"},
+	{Name: "version", Doc: "uint32: 1, 2 with edge weights; 2 more with an info block"},
+	{Name: "dimension", Doc: "uint32 vector dimension"},
+	{Name: "m", Doc: "uint32 neighbors per node and level"},
+	{Name: "ef_construction", Doc: "uint32 candidate list size of inserts"},
+	{Name: "ef_search", Doc: "uint32 default candidate list size of searches"},
+	{Name: "ml", Doc: "float64 level generation factor"},
+	{Name: "entry_point", Doc: "uint64 ID of the node searches start from"},
+	{Name: "max_level", Doc: "int32 highest level of the graph (-1 = empty)"},
+	{Name: "node_count", Doc: "uint32 nodes following the header"},
+}
+
+// ReadGraphHeader reads the header of the graph file at path, without the nodes
+func ReadGraphHeader(path string) (*fileinfo.Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open graph file: %w", err)
+	}
+	defer file.Close()
+
+	var buf [graphHeaderSize]byte
+	if _, err := io.ReadFull(file, buf[:]); err != nil {
+		return nil, fmt.Errorf("failed to read graph header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(buf[0:]) != 0x48534E57 { // "HNSW"
+		return nil, errors.New("invalid graph file: magic number mismatch")
+	}
+	u32 := func(offset int) string {
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf[offset:])), 10)
+	}
+	version := binary.LittleEndian.Uint32(buf[4:])
+	header := &fileinfo.Header{
+		Format:  "graph",
+		Version: int(version),
+		Fields: fileinfo.Fields(GraphHeaderFields,
+			"0x48534E57", u32(4), u32(8), u32(12), u32(16), u32(20),
+			strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(buf[24:])), 'g', -1, 64),
+			strconv.FormatUint(binary.LittleEndian.Uint64(buf[32:]), 10),
+			strconv.Itoa(int(int32(binary.LittleEndian.Uint32(buf[40:])))),
+			u32(44)),
+	}
+	if version > graphVersionWeights {
+		if header.Info, _, err = fileinfo.Read(file); err != nil {
+			return nil, fmt.Errorf("invalid graph file: %w", err)
 		}
-		if err := binary.Read(file, binary.LittleEndian, &level); err != nil {
-			return fmt.Errorf("failed to read node level: %w", err)
+	}
+	return header, nil
+}
+
+// readGraphNode reads a single node and its neighbors as written by
+// writeGraphNode, recording the edge weights if the graph has them
+// Returns io.EOF unwrapped if r is at its end
+func (h *HNSWIndex) readGraphNode(r io.Reader) (*HNSWNode, error) {
+	var id uint64
+	var level int32
+	if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+		if err == io.EOF {
+			return nil, err
 		}
+		return nil, fmt.Errorf("failed to read node ID: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &level); err != nil {
+		return nil, fmt.Errorf("failed to read node level: %w", err)
+	}
+	if level < 0 || level > maxGraphLevel {
+		return nil, fmt.Errorf("invalid level %d for node %d", level, id)
+	}
+
+	node := &HNSWNode{
+		ID:        id,
+		Level:     int(level),
+		Neighbors: make([][]uint64, level+1),
+	}
 
-		node := &HNSWNode{
-			ID:        id,
-			Level:     int(level),
-			Neighbors: make([][]uint64, level+1),
+	// Read neighbors for each level
+	for l := int32(0); l <= level; l++ {
+		var actualLevel int32
+		var neighborCount uint32
+		if err := binary.Read(r, binary.LittleEndian, &actualLevel); err != nil {
+			return nil, fmt.Errorf("failed to read level for node %d: %w", id, err)
+		}
+		if actualLevel != l {
+			return nil, fmt.Errorf("level mismatch for node %d: expected %d, got %d", id, l, actualLevel)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &neighborCount); err != nil {
+			return nil, fmt.Errorf("failed to read neighbor count: %w", err)
+		}
+		if neighborCount > maxGraphNeighbors {
+			return nil, fmt.Errorf("invalid neighbor count %d for node %d", neighborCount, id)
 		}
 
-		// Read neighbors for each level
-		for l := int32(0); l <= level; l++ {
-			var actualLevel int32
-			var neighborCount uint32
-			if err := binary.Read(file, binary.LittleEndian, &actualLevel); err != nil {
-				return fmt.Errorf("failed to read level for node %d: %w", id, err)
+		neighbors := make([]uint64, neighborCount)
+		for j := uint32(0); j < neighborCount; j++ {
+			if err := binary.Read(r, binary.LittleEndian, &neighbors[j]); err != nil {
+				return nil, fmt.Errorf("failed to read neighbor %d for node %d: %w", j, id, err)
 			}
-			if actualLevel != l {
-				return fmt.Errorf("level mismatch for node %d: expected %d, got %d", id, l, actualLevel)
+			if !h.edgeWeights {
+				continue
 			}
-			if err := binary.Read(file, binary.LittleEndian, &neighborCount); err != nil {
-				return fmt.Errorf("failed to read neighbor count: %w", err)
+			var weight float32
+			if err := binary.Read(r, binary.LittleEndian, &weight); err != nil {
+				return nil, fmt.Errorf("failed to read edge weight for node %d: %w", id, err)
 			}
+			if weight != unknownWeight {
+				h.setWeight(id, neighbors[j], weight)
+			}
+		}
+		node.Neighbors[int(l)] = neighbors
+	}
+	return node, nil
+}
 
-			neighbors := make([]uint64, neighborCount)
-			for j := uint32(0); j < neighborCount; j++ {
-				if err := binary.Read(file, binary.LittleEndian, &neighbors[j]); err != nil {
-					return fmt.Errorf("failed to read neighbor %d for node %d: %w", j, id, err)
+// Topology is a snapshot of the graph structure for inspection and export
+type Topology struct {
+	EntryPoint uint64         `json:"entry_point"`
+	MaxLevel   int            `json:"max_level"`
+	M          int            `json:"m"`
+	Nodes      []TopologyNode `json:"nodes"` // Ascending by ID
+}
+
+// TopologyNode is one node of a Topology
+type TopologyNode struct {
+	ID        uint64      `json:"id"`
+	Level     int         `json:"level"`
+	Neighbors [][]uint64  `json:"neighbors"`           // Neighbors[level] = neighbor IDs at that level
+	Distances [][]float32 `json:"distances,omitempty"` // Distances[level][i] = length of the edge to Neighbors[level][i] (-1 = unknown); only with edge weights
+}
+
+// Topology returns a copy of the graph structure, with edge distances if edge
+// weights are enabled
+func (h *HNSWIndex) Topology() Topology {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	t := Topology{
+		EntryPoint: h.entryPoint,
+		MaxLevel:   h.maxLevel,
+		M:          h.M,
+		Nodes:      make([]TopologyNode, 0, len(h.nodes)),
+	}
+	for id, node := range h.nodes {
+		neighbors := make([][]uint64, len(node.Neighbors))
+		for level, ids := range node.Neighbors {
+			neighbors[level] = append([]uint64{}, ids...)
+		}
+		topologyNode := TopologyNode{ID: id, Level: node.Level, Neighbors: neighbors}
+		if h.edgeWeights {
+			topologyNode.Distances = make([][]float32, len(node.Neighbors))
+			for level, ids := range node.Neighbors {
+				topologyNode.Distances[level] = make([]float32, len(ids))
+				for i, neighborID := range ids {
+					topologyNode.Distances[level][i] = h.edgeDistance(id, neighborID)
 				}
 			}
-			node.Neighbors[int(l)] = neighbors
 		}
-
-		h.nodes[id] = node
+		t.Nodes = append(t.Nodes, topologyNode)
 	}
-
-	h.size = len(h.nodes)
-	return nil
+	sort.Slice(t.Nodes, func(i, j int) bool { return t.Nodes[i].ID < t.Nodes[j].ID })
+	return t
 }
@@ -1,13 +1,21 @@
 package hnsw
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/monishSR/veclite/internal/idmap"
 	"github.com/monishSR/veclite/internal/index/types"
 	"github.com/monishSR/veclite/internal/index/utils"
+	"github.com/monishSR/veclite/internal/pq"
+	"github.com/monishSR/veclite/internal/profile"
 	"github.com/monishSR/veclite/internal/storage"
 	"github.com/monishSR/veclite/internal/vector"
 )
@@ -19,6 +27,7 @@ type HNSWNode struct {
 	ID        uint64     // Vector ID
 	Level     int        // Maximum level this node appears in (0 = bottom layer)
 	Neighbors [][]uint64 // Neighbors[level] = neighbor IDs at that level
+	slot      uint32     // Dense internal ID, assigned by putNode
 }
 
 // candidate represents a potential nearest neighbor during search or insert
@@ -30,23 +39,68 @@ type candidate struct {
 
 // HNSWIndex implements Hierarchical Navigable Small World index
 // Memory-efficient: only stores graph structure (IDs and connections)
+// Safe for concurrent use: searches and other reads share a read lock and run
+// in parallel, while inserts, deletes and other changes to the graph take the
+// write lock
 type HNSWIndex struct {
+	mu sync.RWMutex // Guards the graph and parameters below; unexported methods assume it is held
+
 	dimension int
 	config    map[string]any
 	storage   *storage.Storage // Storage for vectors (vectors NOT in memory)
 
 	// Graph structure (memory-efficient: only IDs and connections)
 	nodes      map[uint64]*HNSWNode // All nodes by ID
-	entryPoint uint64               // Top-level entry point ID
+	entryPoint uint64               // Top-level entry point ID (meaningless while the graph is empty)
 	maxLevel   int                  // Highest layer level
 	size       int                  // Number of vectors
 
+	// Dense slots decouple per-search bookkeeping from the ID scheme: visited
+	// sets are bitsets over slots instead of maps over sparse IDs
+	slots      *idmap.Dict   // Node ID -> slot
+	visited    sync.Pool     // *visitedSet reused across searches
+	visitedGen atomic.Uint64 // Generation of pooled visited sets, see ReleaseScratch
+
 	// HNSW parameters
 	M              int     // Maximum number of connections per node
 	efConstruction int     // Search width during construction
 	efSearch       int     // Search width during query
 	mL             float64 // Level generation parameter (typically 1/ln(2))
 	// NOTE: Cache is now handled by storage layer
+
+	// Edge weights: distances persisted with the edges in the graph file, for
+	// offline audits of link quality and to refill neighbor lists thinned out
+	// by deletes worst first (see Repair)
+	edgeWeights bool
+	weights     map[uint64]map[uint64]float32 // Known distances by node pair, both directions
+	damaged     map[uint64]struct{}           // Nodes that lost bottom-layer neighbors to deletes
+
+	// Entry points searches may start from (see entries.go)
+	numEntries int      // Entry point plus alternates (<= 1 = the entry point only)
+	entries    []uint64 // Alternates: the next highest nodes, highest first
+
+	// Product quantization for scoring search candidates in memory (see pq.go)
+	pqSubspaces int          // Code size in bytes (0 = disabled)
+	codebook    *pq.Codebook // Trained codebook (nil = searches are exact)
+	codes       []byte       // Code of the node in slot s at codes[s*pqSubspaces:]
+	pqTrainedOn int          // Vectors the codebook was trained on
+
+	// Codebook training, run without the lock (see TrainPQ)
+	pqTraining chan struct{} // Closed when the running training ends (nil = none)
+	pqStale    []uint32      // Slots encoded while a training runs, re-encoded when it is swapped in
+	pqGen      uint64        // Bumped when the codebook is dropped, discarding a running training
+	pqAttempt  int           // Graph size the last training started at
+	pqErr      error         // Error of the last training (nil = none or it succeeded)
+
+	// Graph journal (see journal.go): changes are tracked under the write lock
+	// and saved under the read lock and saveMu
+	journal     bool                // Saves append changes to the journal (GraphJournal)
+	rewrite     bool                // The next save rewrites the graph file
+	dirty       map[uint64]struct{} // Nodes whose neighbors changed since the last save
+	removed     map[uint64]struct{} // Nodes deleted since the last save
+	journalSize int64               // Bytes of complete batches in the journal
+	saveMu      sync.Mutex          // Serializes saves
+	created     time.Time           // Creation time of the graph file, kept by rewrites (zero = not saved yet)
 }
 
 // NewHNSWIndex creates a new HNSW index
@@ -68,6 +122,11 @@ func NewHNSWIndex(dimension int, config map[string]any, storage *storage.Storage
 		efSearch = ef
 	}
 
+	edgeWeights, _ := config["EdgeWeights"].(bool)
+	numEntries, _ := config["EntryPoints"].(int)
+	pqSubspaces, _ := config["PQSubspaces"].(int)
+	journal, _ := config["GraphJournal"].(bool)
+
 	// mL is typically 1/ln(2) ≈ 1.44
 	mL := 1.0 / math.Log(2.0)
 
@@ -76,6 +135,7 @@ func NewHNSWIndex(dimension int, config map[string]any, storage *storage.Storage
 		config:         config,
 		storage:        storage,
 		nodes:          make(map[uint64]*HNSWNode),
+		slots:          idmap.New(),
 		entryPoint:     0, // Will be set on first insert
 		maxLevel:       -1,
 		size:           0,
@@ -83,6 +143,11 @@ func NewHNSWIndex(dimension int, config map[string]any, storage *storage.Storage
 		efConstruction: efConstruction,
 		efSearch:       efSearch,
 		mL:             mL,
+		edgeWeights:    edgeWeights,
+		numEntries:     numEntries,
+		pqSubspaces:    max(pqSubspaces, 0),
+		journal:        journal,
+		rewrite:        true, // Nothing saved yet
 	}, nil
 }
 
@@ -99,6 +164,7 @@ func OpenHNSWIndex(storage *storage.Storage) (*HNSWIndex, error) {
 	h := &HNSWIndex{
 		storage: storage,
 		nodes:   make(map[uint64]*HNSWNode),
+		slots:   idmap.New(),
 		config:  make(map[string]any),
 	}
 
@@ -110,6 +176,45 @@ func OpenHNSWIndex(storage *storage.Storage) (*HNSWIndex, error) {
 	return h, nil
 }
 
+// Params returns the parameters in effect, keyed like the config map
+func (h *HNSWIndex) Params() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return map[string]int{"M": h.M, "EfConstruction": h.efConstruction, "EfSearch": h.efSearch, "EntryPoints": max(h.numEntries, 1)}
+}
+
+// SetSearchParams applies query-time parameters (EfSearch, EntryPoints, PQSubspaces) and
+// whether edge weights are persisted (EdgeWeights, from the next save) and
+// saves append to the graph journal (GraphJournal) from config
+// Build parameters (M, EfConstruction) only take effect on a rebuild
+func (h *HNSWIndex) SetSearchParams(config map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if subspaces, ok := config["PQSubspaces"].(int); ok {
+		h.setPQSubspaces(subspaces)
+	}
+	if enabled, ok := config["EdgeWeights"].(bool); ok && enabled != h.edgeWeights {
+		h.edgeWeights = enabled
+		h.weights, h.damaged = nil, nil
+		h.resetJournal() // The journal must match the graph file version
+	}
+	if enabled, ok := config["GraphJournal"].(bool); ok {
+		h.journal = enabled
+	}
+	if ef, ok := config["EfSearch"].(int); ok && ef > 0 {
+		h.efSearch = ef
+		if h.config != nil {
+			h.config["EfSearch"] = ef
+		}
+	}
+	if n, ok := config["EntryPoints"].(int); ok && n > 0 && n != h.numEntries {
+		h.numEntries = n
+		h.refreshEntries()
+	}
+}
+
 // Insert adds a vector to the HNSW index
 // Algorithm:
 // 1. Write vector to storage
@@ -120,22 +225,16 @@ func OpenHNSWIndex(storage *storage.Storage) (*HNSWIndex, error) {
 // 6. Connect new node to selected neighbors
 // 7. Update neighbors' connections (prune to maintain max M connections)
 // 8. Update entry point if new node is at higher level
+// An existing ID is unlinked first and re-inserted with the new vector, so its
+// edges follow the vector instead of pointing at its old neighborhood
 func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if len(vec) != h.dimension {
 		return types.ErrDimensionMismatch
 	}
 
-	// Check if node already exists
-	if _, exists := h.nodes[id]; exists {
-		// Node exists, update the vector in storage
-		if h.storage != nil {
-			if err := h.storage.WriteVector(id, vec); err != nil {
-				return fmt.Errorf("failed to update vector in storage: %w", err)
-			}
-		}
-		return nil
-	}
-
 	// Step 1: Write vector to storage
 	if h.storage != nil {
 		if err := h.storage.WriteVector(id, vec); err != nil {
@@ -143,6 +242,57 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 		}
 	}
 
+	if _, exists := h.nodes[id]; exists {
+		h.unlink(id)
+	}
+	return h.addNode(id, vec)
+}
+
+// InsertBatch adds vectors[i] under ids[i] like repeated Inserts, writing
+// them to storage in one append before linking the new nodes into the graph
+func (h *HNSWIndex) InsertBatch(ids []uint64, vectors [][]float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, vec := range vectors {
+		if len(vec) != h.dimension {
+			return types.ErrDimensionMismatch
+		}
+	}
+	if h.storage != nil {
+		if err := h.storage.WriteVectors(ids, vectors); err != nil {
+			return fmt.Errorf("failed to write vectors to storage: %w", err)
+		}
+	}
+	for i, id := range ids {
+		if _, exists := h.nodes[id]; exists {
+			h.unlink(id) // Updated vector, relinked as in Insert
+		}
+		if err := h.addNode(id, vectors[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexExisting adds a vector that is already persisted in storage to the graph
+// Used to rebuild the graph from storage without rewriting any records
+func (h *HNSWIndex) IndexExisting(id uint64, vec []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(vec) != h.dimension {
+		return types.ErrDimensionMismatch
+	}
+	if _, exists := h.nodes[id]; exists {
+		return nil
+	}
+	return h.addNode(id, vec)
+}
+
+// addNode links a new node into the graph (steps 2-8 of Insert)
+// The vector must already be in storage
+func (h *HNSWIndex) addNode(id uint64, vec []float32) error {
 	// Step 2: Generate random level using exponential distribution
 	// Level = floor(-ln(U) / mL) where U is uniform random in (0,1)
 	u := rand.Float64()
@@ -155,7 +305,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 	}
 
 	// Step 3: If this is the first node, set as entry point
-	if h.entryPoint == 0 || len(h.nodes) == 0 {
+	if _, exists := h.nodes[h.entryPoint]; !exists {
 		node := &HNSWNode{
 			ID:        id,
 			Level:     level,
@@ -165,7 +315,9 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 		for l := 0; l <= level; l++ {
 			node.Neighbors[l] = make([]uint64, 0)
 		}
-		h.nodes[id] = node
+		h.putNode(node)
+		h.encode(node.slot, vec)
+		h.touch(id)
 		h.entryPoint = id
 		h.maxLevel = level
 		h.size++
@@ -186,7 +338,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 	// Storage cache handles caching efficiently (lookup before lock)
 	for searchLevel := h.maxLevel; searchLevel > maxSearchLevel; searchLevel-- {
 		// Find nearest neighbor at this level (greedy: ef=1)
-		candidates := h.searchLevel(vec, currentNode, searchLevel, 1)
+		candidates := h.searchLevel(context.Background(), h.exactScorer(vec, nil), currentNode, searchLevel, 1)
 		if len(candidates) > 0 {
 			currentNode = candidates[0].id
 		}
@@ -196,7 +348,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 	// Storage cache handles caching efficiently
 	for l := maxSearchLevel; l >= 0; l-- {
 		// Search for efConstruction candidates at this level
-		candidates := h.searchLevel(vec, currentNode, l, h.efConstruction)
+		candidates := h.searchLevel(context.Background(), h.exactScorer(vec, nil), currentNode, l, h.efConstruction)
 		if len(candidates) == 0 {
 			selectedNeighbors[l] = []uint64{}
 			continue
@@ -211,6 +363,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 		selectedNeighbors[l] = make([]uint64, numNeighbors)
 		for i := 0; i < numNeighbors; i++ {
 			selectedNeighbors[l][i] = candidates[i].id
+			h.setWeight(id, candidates[i].id, candidates[i].distance)
 		}
 
 		// Update currentNode for next level (use closest candidate)
@@ -233,7 +386,9 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 			newNode.Neighbors[l] = make([]uint64, 0)
 		}
 	}
-	h.nodes[id] = newNode
+	h.putNode(newNode)
+	h.encode(newNode.slot, vec)
+	h.touch(id)
 
 	// Step 7: Update neighbors' connections (bidirectional)
 	// For each selected neighbor at each level, add new node as neighbor
@@ -253,6 +408,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 
 			// Add new node as neighbor (bidirectional connection)
 			neighborNode.Neighbors[l] = append(neighborNode.Neighbors[l], id)
+			h.touch(neighborID)
 
 			// Prune if neighbor has more than M connections
 			if len(neighborNode.Neighbors[l]) > h.M {
@@ -274,6 +430,7 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 						continue
 					}
 					dist := vector.L2Distance(neighborVec, nVec)
+					h.setWeight(neighborID, nID, dist)
 					_ = candidateHeap.AddCandidate(utils.Candidate{ID: nID, Distance: dist}, h.M)
 				}
 
@@ -290,12 +447,18 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 	}
 
 	// Step 8: Update entry point if new node is at higher level
+	// The old entry point becomes an alternate entry
 	if level > h.maxLevel {
+		previous := h.nodes[h.entryPoint]
 		h.entryPoint = id
 		h.maxLevel = level
+		h.offerEntry(previous)
+	} else {
+		h.offerEntry(h.nodes[id])
 	}
 
 	h.size++
+	h.maybeTrainPQ()
 	return nil
 }
 
@@ -307,6 +470,15 @@ func (h *HNSWIndex) Insert(id uint64, vec []float32) error {
 // 4. Return top k results
 // Optimized: Pre-allocated slices, early termination, storage-level cache handles vector caching
 func (h *HNSWIndex) Search(query []float32, k int) ([]types.SearchResult, error) {
+	return h.SearchContext(context.Background(), query, k)
+}
+
+// SearchContext is Search with cancellation: traversal stops and ctx.Err() is
+// returned once ctx is done
+func (h *HNSWIndex) SearchContext(ctx context.Context, query []float32, k int) ([]types.SearchResult, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	if len(query) != h.dimension {
 		return nil, types.ErrDimensionMismatch
 	}
@@ -316,86 +488,151 @@ func (h *HNSWIndex) Search(query []float32, k int) ([]types.SearchResult, error)
 	}
 
 	// Empty index
-	if h.entryPoint == 0 || len(h.nodes) == 0 {
+	if len(h.nodes) == 0 {
 		return []types.SearchResult{}, nil
 	}
 
-	// Step 1: Navigate down from top level to level 1 (greedy search)
-	currentNode := h.entryPoint
-	for level := h.maxLevel; level > 0; level-- {
-		// Find nearest neighbor at this level (greedy: ef=1, just find closest)
-		// Storage cache handles caching efficiently (lookup before lock)
-		candidates := h.searchLevel(query, currentNode, level, 1)
-		if len(candidates) > 0 {
-			currentNode = candidates[0].id
-		} else {
-			// No candidates found, stay at current node
-			break
-		}
-	}
-
-	// Step 2: Search at level 0 with efSearch candidates (thorough search)
-	// Storage cache handles caching efficiently
-	candidates := h.searchLevel(query, currentNode, 0, h.efSearch)
-	if len(candidates) == 0 {
-		return []types.SearchResult{}, nil
+	p := profile.FromContext(ctx)
+	candidates, err := h.searchCandidates(ctx, query, h.efSearch, p)
+	if err != nil {
+		return nil, err
 	}
 
 	// Step 3: Extract top k results
+	return h.topResults(query, candidates, k, types.WantVectors(ctx), p), nil
+}
+
+// topResults reads the vectors of the first k candidates that can be read and
+// returns them as results, with a copy of each vector if withVectors is set
+// With PQ the candidates carry approximate distances: the best
+// pqRerankFactor*k of them are re-ranked by their exact distances first
+func (h *HNSWIndex) topResults(query []float32, candidates []candidate, k int, withVectors bool, p *profile.Profile) []types.SearchResult {
 	if k > len(candidates) {
 		k = len(candidates)
 	}
 
 	// Build results - pre-allocate with exact capacity for better performance
 	// Storage cache handles caching efficiently (lookup before lock)
+	defer p.Start("hnsw.results")()
+	if h.codebook != nil {
+		return h.rerank(query, candidates[:min(len(candidates), pqRerankFactor*k)], k, withVectors, p)
+	}
 	results := make([]types.SearchResult, 0, k)
 	for i := 0; i < len(candidates) && len(results) < k; i++ {
 		cand := candidates[i]
 		// Storage cache handles caching (lookup before lock, very efficient)
-		vec, err := h.storage.ReadVector(cand.id)
+		vec, err := h.storage.ReadVectorProfiled(cand.id, p)
 		if err != nil {
 			// Skip this result if vector can't be read (inconsistent state)
 			continue
 		}
-		// Copy vector to avoid external modifications
-		vecCopy := make([]float32, len(vec))
-		copy(vecCopy, vec)
+		result := types.SearchResult{ID: cand.id, Distance: cand.distance}
+		if withVectors {
+			// Copy vector to avoid external modifications
+			result.Vector = make([]float32, len(vec))
+			copy(result.Vector, vec)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Candidates returns up to n of the nearest nodes found by a search with
+// max(efSearch, n) candidates, nearest first, without reading their vectors
+// (n <= 0 = all efSearch candidates)
+// With PQ the distances are the approximate ones the traversal computed
+func (h *HNSWIndex) Candidates(ctx context.Context, query []float32, n int) ([]types.SearchResult, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-		results = append(results, types.SearchResult{
-			ID:       cand.id,
-			Distance: cand.distance,
-			Vector:   vecCopy,
-		})
+	if len(query) != h.dimension {
+		return nil, types.ErrDimensionMismatch
+	}
+	if len(h.nodes) == 0 {
+		return []types.SearchResult{}, nil
 	}
 
+	candidates, err := h.searchCandidates(ctx, query, max(h.efSearch, n), profile.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && n < len(candidates) {
+		candidates = candidates[:n]
+	}
+	results := make([]types.SearchResult, len(candidates))
+	for i, cand := range candidates {
+		results[i] = types.SearchResult{ID: cand.id, Distance: cand.distance}
+	}
 	return results, nil
 }
 
+// searchCandidates descends greedily to level 0 and returns the ef nearest
+// candidates found there, best first
+func (h *HNSWIndex) searchCandidates(ctx context.Context, query []float32, ef int, p *profile.Profile) ([]candidate, error) {
+	// Step 1: Navigate down from top level to level 1 (greedy search)
+	// Start from the seed, or with alternate entries the one closest to the query
+	endStage := p.Start("hnsw.descend")
+	s := h.searchScorer(query, p)
+	currentNode, topLevel := h.startNode(ctx, s)
+	for level := topLevel; level > 0; level-- {
+		// Find nearest neighbor at this level (greedy: ef=1, just find closest)
+		// Storage cache handles caching efficiently (lookup before lock)
+		candidates := h.searchLevel(ctx, s, currentNode, level, 1)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(candidates) > 0 {
+			currentNode = candidates[0].id
+		} else {
+			// No candidates found, stay at current node
+			break
+		}
+	}
+	endStage()
+
+	// Step 2: Search at level 0 with ef candidates (thorough search)
+	// Storage cache handles caching efficiently
+	endStage = p.Start("hnsw.layer0")
+	candidates := h.searchLevel(ctx, s, currentNode, 0, ef)
+	endStage()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
 // searchLevel searches for nearest neighbors at a specific level
 // Returns candidates sorted by distance (best first)
 // Used by Insert to find neighbors at different levels
 // Storage handles caching automatically
-func (h *HNSWIndex) searchLevel(query []float32, entryNode uint64, level int, ef int) []candidate {
+// Distances come from s: exact for inserts, PQ codes for searches if trained
+// Exploration stops early when ctx is done; callers check ctx.Err()
+func (h *HNSWIndex) searchLevel(ctx context.Context, s scorer, entryNode uint64, level int, ef int) []candidate {
 	if ef <= 0 {
 		return nil
 	}
 
 	// Initialize candidate heap (max-heap to keep worst at top)
 	candidateHeap := utils.NewCandidateHeap(ef)
-	visited := make(map[uint64]bool, ef*2) // Pre-allocate for better performance
+	visited := h.acquireVisited()
+	defer h.releaseVisited(visited)
 	// Use pre-allocated slice for toVisit to avoid repeated allocations
 	toVisit := make([]uint64, 0, ef*2)
 	toVisit = append(toVisit, entryNode)
+	p := s.p // Counts the work of searches (nil during Insert)
 
-	// Get entry node vector for initial distance
+	// Get entry node distance
 	// Storage handles caching automatically
-	entryVector, err := h.storage.ReadVector(entryNode)
-	if err != nil {
+	entryDist, ok := s.distance(entryNode)
+	if !ok {
 		return nil // Entry node not found in storage
 	}
-	entryDist := vector.L2Distance(query, entryVector)
+	p.AddCandidates(1)
 	_ = candidateHeap.AddCandidate(utils.Candidate{ID: entryNode, Distance: entryDist}, ef)
-	visited[entryNode] = true
+	if node, exists := h.nodes[entryNode]; exists {
+		visited.visit(node.slot)
+	}
 
 	// Explore graph using greedy search at specified level
 	// Reduced max iterations for better performance on large datasets
@@ -406,6 +643,9 @@ func (h *HNSWIndex) searchLevel(query []float32, entryNode uint64, level int, ef
 	maxNoImprovement := ef  // Early termination if no improvement for this many iterations
 
 	for visitIdx < len(toVisit) && iterations < maxIterations {
+		if ctx.Err() != nil {
+			break
+		}
 		currentID := toVisit[visitIdx]
 		visitIdx++
 		iterations++
@@ -434,18 +674,18 @@ func (h *HNSWIndex) searchLevel(query []float32, entryNode uint64, level int, ef
 
 		// Explore neighbors
 		for _, neighborID := range neighbors {
-			if visited[neighborID] {
-				continue
+			neighborNode, exists := h.nodes[neighborID]
+			if !exists || !visited.visit(neighborNode.slot) {
+				continue // Dangling reference or already visited
 			}
-			visited[neighborID] = true
 
-			// Get neighbor vector and calculate distance
+			// Get neighbor distance
 			// Storage cache handles caching efficiently (lookup before lock)
-			neighborVector, err := h.storage.ReadVector(neighborID)
-			if err != nil {
+			dist, ok := s.distance(neighborID)
+			if !ok {
 				continue // Skip if vector not found
 			}
-			dist := vector.L2Distance(query, neighborVector)
+			p.AddCandidates(1)
 
 			// Add to candidate heap
 			wasAdded := candidateHeap.AddCandidate(utils.Candidate{ID: neighborID, Distance: dist}, ef)
@@ -492,13 +732,16 @@ func (h *HNSWIndex) searchLevel(query []float32, entryNode uint64, level int, ef
 // ReadVector retrieves a vector by ID from storage
 // Storage handles caching automatically
 func (h *HNSWIndex) ReadVector(id uint64) ([]float32, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	if h.storage == nil {
 		return nil, errors.New("storage not available")
 	}
 	// Optional: Check if node exists in graph (fast map lookup, similar to Flat)
 	// This provides consistency but doesn't affect performance significantly
 	if _, exists := h.nodes[id]; !exists {
-		return nil, fmt.Errorf("vector with ID %d not found in index", id)
+		return nil, fmt.Errorf("vector with ID %d %w in index", id, types.ErrNotFound)
 	}
 	// Storage handles caching automatically (same as Flat)
 	return h.storage.ReadVector(id)
@@ -510,6 +753,9 @@ func (h *HNSWIndex) ReadVector(id uint64) ([]float32, error) {
 // 3. Removes all references to this node from other nodes' neighbor lists
 // 4. Updates entry point if it was the deleted node
 func (h *HNSWIndex) Delete(id uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	// Check if node exists in graph
 	_, exists := h.nodes[id]
 	if !exists {
@@ -528,7 +774,15 @@ func (h *HNSWIndex) Delete(id uint64) error {
 		}
 	}
 
-	// Step 2: Remove this node from all other nodes' neighbor lists
+	h.unlink(id)
+	return nil
+}
+
+// unlink removes a node and all references to it from the graph (steps 2-4 of
+// Delete), leaving its vector in storage
+// Note: Assumes lock is already held
+func (h *HNSWIndex) unlink(id uint64) {
+	// Remove this node from all other nodes' neighbor lists
 	// Iterate through all nodes and remove references to the deleted node
 	for otherID, otherNode := range h.nodes {
 		if otherID == id {
@@ -545,13 +799,20 @@ func (h *HNSWIndex) Delete(id uint64) error {
 					lastIdx := len(neighbors) - 1
 					neighbors[i] = neighbors[lastIdx]
 					otherNode.Neighbors[level] = neighbors[:lastIdx]
+					h.touch(otherID)
+					if level == 0 && h.edgeWeights {
+						if h.damaged == nil {
+							h.damaged = make(map[uint64]struct{})
+						}
+						h.damaged[otherID] = struct{}{}
+					}
 					break // Found and removed, no need to continue
 				}
 			}
 		}
 	}
 
-	// Step 3: Update entry point if it was the deleted node
+	// Update entry point if it was the deleted node
 	if h.entryPoint == id {
 		// Find a new entry point from remaining nodes
 		// Prefer a node at the highest level
@@ -570,15 +831,24 @@ func (h *HNSWIndex) Delete(id uint64) error {
 		}
 	}
 
-	// Step 4: Remove node from graph
+	// Remove node from graph
+	wasEntry := h.isEntry(id)
 	delete(h.nodes, id)
+	delete(h.damaged, id)
+	h.forget(id)
+	h.dropWeights(id)
+	h.slots.Release(id)
 	h.size = len(h.nodes)
-
-	return nil
+	if wasEntry {
+		h.refreshEntries()
+	}
 }
 
 // Size returns the number of vectors in the index
 func (h *HNSWIndex) Size() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
 	return len(h.nodes) // Use map length instead of maintaining separate counter
 }
 
@@ -587,9 +857,18 @@ func (h *HNSWIndex) Size() int {
 // 2. Removes all vectors from storage (clears db file)
 // 3. Resets entryPoint to 0 and maxLevel to -1
 func (h *HNSWIndex) Clear() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	// Step 1: Clear all nodes from graph
 	h.nodes = make(map[uint64]*HNSWNode)
+	h.slots = idmap.New()
 	h.size = 0
+	h.weights, h.damaged = nil, nil
+	h.entries = nil
+	h.codebook, h.codes, h.pqErr = nil, nil, nil
+	h.pqGen++
+	h.resetJournal()
 
 	// Step 2: Clear all vectors from storage
 	if h.storage != nil {
@@ -606,3 +885,214 @@ func (h *HNSWIndex) Clear() error {
 
 	return nil
 }
+
+// Histogram returns the number of nodes whose top level is each level
+func (h *HNSWIndex) Histogram() (string, []int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make([]int, max(h.maxLevel+1, 0))
+	for _, node := range h.nodes {
+		if node.Level < len(counts) {
+			counts[node.Level]++
+		}
+	}
+	return "level", counts
+}
+
+// LocalityOrder returns node IDs in breadth-first order over the bottom layer,
+// starting at the entry point, so graph neighbors end up physically close when
+// storage is compacted in this order. Nodes unreachable from the entry point follow.
+func (h *HNSWIndex) LocalityOrder() []uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	order := make([]uint64, 0, len(h.nodes))
+	visited := make(map[uint64]bool, len(h.nodes))
+
+	bfs := func(start uint64) {
+		queue := []uint64{start}
+		visited[start] = true
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			order = append(order, id)
+			node := h.nodes[id]
+			if len(node.Neighbors) == 0 {
+				continue
+			}
+			for _, neighborID := range node.Neighbors[0] {
+				if _, exists := h.nodes[neighborID]; exists && !visited[neighborID] {
+					visited[neighborID] = true
+					queue = append(queue, neighborID)
+				}
+			}
+		}
+	}
+
+	if _, exists := h.nodes[h.entryPoint]; exists {
+		bfs(h.entryPoint)
+	}
+
+	// Disconnected components (e.g. after deletes) in ascending ID order
+	remaining := make([]uint64, 0, len(h.nodes)-len(order))
+	for id := range h.nodes {
+		if !visited[id] {
+			remaining = append(remaining, id)
+		}
+	}
+	sort.Slice(remaining, func(i, j int) bool { return remaining[i] < remaining[j] })
+	for _, id := range remaining {
+		if !visited[id] {
+			bfs(id)
+		}
+	}
+
+	return order
+}
+
+// Repair fixes structural damage to the graph and returns the number of fixes made
+//  1. Drops neighbor references to missing nodes or to nodes below that level
+//  2. Replaces a missing or non-top-level entry point
+//  3. Relinks nodes unreachable from the entry point on the bottom layer to their
+//     nearest reachable neighbors, so searches can find them again
+//  4. With edge weights, refills bottom-layer neighbor lists that deletes thinned
+//     out, those with the longest remaining edge first (at most
+//     repairRefillLimit per pass)
+func (h *HNSWIndex) Repair() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fixes := 0
+
+	// Step 1: Drop dangling neighbor references
+	for id, node := range h.nodes {
+		before := fixes
+		for len(node.Neighbors) < node.Level+1 {
+			node.Neighbors = append(node.Neighbors, make([]uint64, 0))
+			fixes++
+		}
+		for l := 0; l <= node.Level; l++ {
+			kept := node.Neighbors[l][:0]
+			for _, neighborID := range node.Neighbors[l] {
+				if neighbor, exists := h.nodes[neighborID]; exists && neighborID != id && neighbor.Level >= l {
+					kept = append(kept, neighborID)
+				} else {
+					fixes++
+				}
+			}
+			node.Neighbors[l] = kept
+		}
+		if fixes > before {
+			h.touch(id)
+		}
+	}
+	h.size = len(h.nodes)
+
+	// Step 2: Entry point must be a node on the highest level
+	if len(h.nodes) == 0 {
+		if h.entryPoint != 0 || h.maxLevel != -1 {
+			h.entryPoint = 0
+			h.maxLevel = -1
+			fixes++
+		}
+		h.entries = nil
+		return fixes
+	}
+	topLevel := -1
+	for _, node := range h.nodes {
+		topLevel = max(topLevel, node.Level)
+	}
+	if entry, exists := h.nodes[h.entryPoint]; !exists || entry.Level != topLevel || h.maxLevel != topLevel {
+		top, found := uint64(0), false
+		for id, node := range h.nodes {
+			if node.Level == topLevel && (!found || id < top) {
+				top, found = id, true
+			}
+		}
+		h.entryPoint = top
+		h.maxLevel = topLevel
+		h.touch(top) // Saved with the entry point
+		fixes++
+	}
+	h.refreshEntries()
+
+	// Step 3: Relink nodes unreachable on the bottom layer
+	if h.storage == nil {
+		return fixes
+	}
+	reachable := make(map[uint64]bool, len(h.nodes))
+	var mark func(start uint64)
+	mark = func(start uint64) {
+		queue := []uint64{start}
+		reachable[start] = true
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, neighborID := range h.nodes[id].Neighbors[0] {
+				if !reachable[neighborID] {
+					reachable[neighborID] = true
+					queue = append(queue, neighborID)
+				}
+			}
+		}
+	}
+	mark(h.entryPoint)
+
+	orphans := make([]uint64, 0)
+	for id := range h.nodes {
+		if !reachable[id] {
+			orphans = append(orphans, id)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i] < orphans[j] })
+
+	for _, id := range orphans {
+		if reachable[id] { // Reached through an orphan relinked earlier
+			continue
+		}
+		vec, err := h.storage.ReadVector(id)
+		if err != nil {
+			continue
+		}
+		node := h.nodes[id]
+		linked := false
+		for _, cand := range h.searchLevel(context.Background(), h.exactScorer(vec, nil), h.entryPoint, 0, h.efConstruction) {
+			if cand.id == id || !reachable[cand.id] {
+				continue
+			}
+			if len(node.Neighbors[0]) < h.M && !containsID(node.Neighbors[0], cand.id) {
+				node.Neighbors[0] = append(node.Neighbors[0], cand.id)
+				h.touch(id)
+			}
+			// Incoming link from the reachable side makes the node findable
+			if !linked {
+				neighbor := h.nodes[cand.id]
+				neighbor.Neighbors[0] = append(neighbor.Neighbors[0], id)
+				h.touch(cand.id)
+				linked = true
+			}
+		}
+		if linked {
+			mark(id)
+			fixes++
+		}
+	}
+
+	// Step 4: Refill neighbor lists thinned out by deletes, worst first
+	if h.edgeWeights {
+		fixes += h.refillDamaged()
+	}
+
+	return fixes
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []uint64, id uint64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,10 @@
 package hnsw
 
 import (
+	"context"
+	"errors"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/monishSR/veclite/internal/index/types"
@@ -97,6 +100,40 @@ func TestHNSWIndex_Insert_Multiple(t *testing.T) {
 	}
 }
 
+func TestHNSWIndex_InsertBatch(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	if err := index.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	ids := make([]uint64, 50)
+	vectors := make([][]float32, 50)
+	for i := range ids {
+		ids[i] = uint64(i + 1) // ID 1 is an update
+		vectors[i] = make([]float32, 128)
+		vectors[i][0] = float32(i)
+		vectors[i][1] = float32(i % 3)
+	}
+	if err := index.InsertBatch(ids, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	if index.Size() != 50 {
+		t.Errorf("Expected size 50, got %d", index.Size())
+	}
+	for i, id := range ids {
+		results, err := index.Search(vectors[i], 1)
+		if err != nil || len(results) != 1 || results[0].ID != id {
+			t.Errorf("Expected ID %d to be nearest to its own vector, got %v (%v)", id, results, err)
+		}
+	}
+
+	if err := index.InsertBatch([]uint64{51}, [][]float32{make([]float32, 64)}); err != types.ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
 func TestHNSWIndex_Insert_DimensionMismatch(t *testing.T) {
 	index, cleanup := createTestHNSW(t)
 	defer cleanup()
@@ -141,6 +178,42 @@ func TestHNSWIndex_Insert_UpdateExisting(t *testing.T) {
 	}
 }
 
+func TestHNSWIndex_Insert_Relinks(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for id := uint64(1); id <= 50; id++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(id)
+		if err := index.Insert(id, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Move ID 1 to the far end, next to ID 50
+	vec := make([]float32, 128)
+	vec[0] = 50.5
+	if err := index.Insert(1, vec); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if index.Size() != 50 {
+		t.Errorf("Expected size 50 after update, got %d", index.Size())
+	}
+	for _, neighbor := range index.nodes[1].Neighbors[0] {
+		if neighbor < 10 {
+			t.Errorf("Expected ID 1 to be relinked away from its old neighbors, got neighbor %d", neighbor)
+		}
+	}
+
+	results, err := index.Search(vec, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != 1 || results[1].ID != 50 {
+		t.Errorf("Expected IDs 1 and 50, got %v", results)
+	}
+}
+
 func TestHNSWIndex_Search_EmptyIndex(t *testing.T) {
 	index, cleanup := createTestHNSW(t)
 	defer cleanup()
@@ -531,3 +604,422 @@ func TestHNSWIndex_MultipleLevels(t *testing.T) {
 	}
 }
 
+func TestHNSWIndex_LocalityOrder(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	if order := index.LocalityOrder(); len(order) != 0 {
+		t.Errorf("Expected empty order for empty index, got %v", order)
+	}
+
+	for i := uint64(1); i <= 50; i++ {
+		vec := make([]float32, 128)
+		vec[i%128] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := index.Delete(7); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	order := index.LocalityOrder()
+	if len(order) != index.Size() {
+		t.Fatalf("Expected %d IDs, got %d", index.Size(), len(order))
+	}
+	if order[0] != index.entryPoint {
+		t.Errorf("Expected order to start at entry point %d, got %d", index.entryPoint, order[0])
+	}
+	seen := make(map[uint64]bool)
+	for _, id := range order {
+		if seen[id] {
+			t.Errorf("ID %d appears twice", id)
+		}
+		seen[id] = true
+		if _, exists := index.nodes[id]; !exists {
+			t.Errorf("Order contains unknown ID %d", id)
+		}
+	}
+}
+
+func TestHNSWIndex_SearchContext_Cancelled(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 50; i++ {
+		vec := make([]float32, 128)
+		vec[i%128] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := index.SearchContext(ctx, make([]float32, 128), 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	results, err := index.SearchContext(context.Background(), make([]float32, 128), 5)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("Expected 5 results, got %d", len(results))
+	}
+}
+
+func TestHNSWIndex_IndexExisting(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 20; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.storage.WriteVector(i, vec); err != nil {
+			t.Fatalf("Failed to write vector: %v", err)
+		}
+		if err := index.IndexExisting(i, vec); err != nil {
+			t.Fatalf("IndexExisting failed: %v", err)
+		}
+	}
+	// Re-indexing an existing node is a no-op
+	if err := index.IndexExisting(1, make([]float32, 128)); err != nil {
+		t.Fatalf("IndexExisting on existing node failed: %v", err)
+	}
+	if err := index.IndexExisting(21, make([]float32, 3)); err != types.ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if index.Size() != 20 {
+		t.Errorf("Expected size 20, got %d", index.Size())
+	}
+
+	usage, err := index.storage.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.DeadBytes != 0 {
+		t.Errorf("IndexExisting rewrote records: %d dead bytes", usage.DeadBytes)
+	}
+
+	query := make([]float32, 128)
+	query[0] = 5
+	results, err := index.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 5 {
+		t.Errorf("Expected nearest ID 5, got %v (%v)", results, err)
+	}
+}
+
+func TestHNSWIndex_Params(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	params := index.Params()
+	if params["M"] != 16 || params["EfConstruction"] != 200 || params["EfSearch"] != 50 {
+		t.Errorf("Unexpected params: %v", params)
+	}
+
+	index.SetSearchParams(map[string]any{"EfSearch": 120, "M": 32})
+	params = index.Params()
+	if params["EfSearch"] != 120 {
+		t.Errorf("Expected EfSearch 120, got %d", params["EfSearch"])
+	}
+	if params["M"] != 16 {
+		t.Errorf("SetSearchParams must not change build parameter M, got %d", params["M"])
+	}
+
+	index.SetSearchParams(map[string]any{"EfSearch": 0})
+	if ef := index.Params()["EfSearch"]; ef != 120 {
+		t.Errorf("Zero EfSearch should be ignored, got %d", ef)
+	}
+}
+
+func TestHNSWIndex_Repair(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 30; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if fixes := index.Repair(); fixes != 0 {
+		t.Errorf("Expected healthy graph to need no fixes, got %d", fixes)
+	}
+
+	// Damage the graph: a dangling reference and an orphaned node
+	const orphan = uint64(15)
+	for id, node := range index.nodes {
+		if id == orphan {
+			continue
+		}
+		for l := range node.Neighbors {
+			kept := node.Neighbors[l][:0]
+			for _, n := range node.Neighbors[l] {
+				if n != orphan {
+					kept = append(kept, n)
+				}
+			}
+			node.Neighbors[l] = kept
+		}
+	}
+	index.nodes[1].Neighbors[0] = append(index.nodes[1].Neighbors[0], 999)
+	if orphan == index.entryPoint {
+		t.Skip("Orphan is the entry point")
+	}
+
+	if fixes := index.Repair(); fixes < 2 {
+		t.Errorf("Expected at least 2 fixes, got %d", fixes)
+	}
+	for _, n := range index.nodes[1].Neighbors[0] {
+		if n == 999 {
+			t.Error("Dangling neighbor reference survived Repair")
+		}
+	}
+
+	query := make([]float32, 128)
+	query[0] = float32(orphan)
+	results, err := index.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != orphan {
+		t.Errorf("Expected repaired orphan %d to be found, got %v (%v)", orphan, results, err)
+	}
+}
+
+func TestHNSWIndex_Repair_EntryPoint(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Drop the entry point node without updating the graph
+	delete(index.nodes, index.entryPoint)
+	if fixes := index.Repair(); fixes == 0 {
+		t.Error("Expected fixes for missing entry point")
+	}
+	if _, exists := index.nodes[index.entryPoint]; !exists {
+		t.Error("Entry point still missing after Repair")
+	}
+	if index.Size() != 9 {
+		t.Errorf("Expected size 9, got %d", index.Size())
+	}
+	if _, err := index.Search(make([]float32, 128), 3); err != nil {
+		t.Errorf("Search after Repair failed: %v", err)
+	}
+
+	index.nodes = make(map[uint64]*HNSWNode)
+	index.Repair()
+	if index.entryPoint != 0 || index.maxLevel != -1 {
+		t.Error("Expected empty graph to reset entry point")
+	}
+}
+
+func TestHNSWIndex_IDZero(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	// ID 0 as the entry point must not look like an empty graph
+	for i := uint64(0); i < 20; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if index.Size() != 20 {
+		t.Fatalf("Expected size 20, got %d", index.Size())
+	}
+	results, err := index.Search(make([]float32, 128), 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != 0 {
+		t.Errorf("Expected ID 0 as the nearest result, got %v", results)
+	}
+}
+
+func TestHNSWIndex_SlotsReused(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i<<40, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if err := index.Delete(i << 40); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+	}
+	for i := uint64(11); i <= 15; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i<<40, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Sparse IDs still map to a compact slot range
+	if index.slots.Cap() != 10 || index.slots.Len() != 10 {
+		t.Errorf("Expected 10 slots in use out of 10, got %d of %d", index.slots.Len(), index.slots.Cap())
+	}
+	seen := make(map[uint32]bool)
+	for id, node := range index.nodes {
+		if seen[node.slot] {
+			t.Errorf("Slot %d assigned twice", node.slot)
+		}
+		seen[node.slot] = true
+		if slot, ok := index.slots.Lookup(id); !ok || slot != node.slot {
+			t.Errorf("Node %d: slot %d does not match dictionary %d", id, node.slot, slot)
+		}
+	}
+	results, err := index.Search(make([]float32, 128), 10)
+	if err != nil || len(results) != 10 {
+		t.Errorf("Expected 10 results, got %d, %v", len(results), err)
+	}
+}
+
+func TestHNSWIndex_Histogram(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	if label, counts := index.Histogram(); label != "level" || len(counts) != 0 {
+		t.Errorf("Expected an empty level histogram, got %q %v", label, counts)
+	}
+	for i := uint64(1); i <= 100; i++ {
+		vec := make([]float32, 128)
+		vec[i%128] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	_, counts := index.Histogram()
+	if len(counts) != index.maxLevel+1 {
+		t.Fatalf("Expected %d levels, got %v", index.maxLevel+1, counts)
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total != 100 || counts[0] < counts[len(counts)-1] {
+		t.Errorf("Expected 100 nodes, most on level 0, got %v", counts)
+	}
+}
+
+func TestHNSWIndex_Candidates(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 200; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	query := make([]float32, 128)
+	query[0] = 100
+
+	candidates, err := index.Candidates(context.Background(), query, 0)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	if len(candidates) != 50 {
+		t.Fatalf("Expected efSearch candidates, got %d", len(candidates))
+	}
+	for i, c := range candidates {
+		if c.Vector != nil {
+			t.Fatal("Expected candidates without vectors")
+		}
+		if i > 0 && c.Distance < candidates[i-1].Distance {
+			t.Fatalf("Candidates not sorted at %d", i)
+		}
+	}
+	results, _ := index.Search(query, 5)
+	for i := range results {
+		if results[i].ID != candidates[i].ID {
+			t.Errorf("Expected candidates to start with the search results, got %d at %d", candidates[i].ID, i)
+		}
+	}
+
+	// More candidates than efSearch widens the search
+	if more, _ := index.Candidates(context.Background(), query, 80); len(more) != 80 {
+		t.Errorf("Expected 80 candidates, got %d", len(more))
+	}
+}
+
+func TestHNSWIndex_ConcurrentAccess(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	vec := func(id uint64) []float32 {
+		v := make([]float32, 128)
+		for i := range v {
+			v[i] = float32(id) + float32(i)*0.001
+		}
+		return v
+	}
+	for id := uint64(1); id <= 50; id++ {
+		if err := index.Insert(id, vec(id)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Searches run in parallel with each other and with writes, without an outer lock
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if _, err := index.Search(vec(uint64(g*10+i%10+1)), 5); err != nil {
+					errs <- err
+				}
+				index.Size()
+				index.Topology()
+			}
+		}(g)
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for id := uint64(51); id <= 100; id++ {
+			if err := index.Insert(id, vec(id)); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for id := uint64(1); id <= 20; id++ {
+			if err := index.Delete(id); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent operation failed: %v", err)
+	}
+
+	if size := index.Size(); size != 80 {
+		t.Errorf("Expected 80 vectors, got %d", size)
+	}
+	results, err := index.Search(vec(60), 1)
+	if err != nil || len(results) != 1 || results[0].ID != 60 {
+		t.Errorf("Expected ID 60, got %v, %v", results, err)
+	}
+}
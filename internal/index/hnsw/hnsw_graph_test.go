@@ -1,7 +1,9 @@
 package hnsw
 
 import (
+	"bytes"
 	"encoding/binary"
+	"math"
 	"os"
 	"testing"
 
@@ -1253,3 +1255,127 @@ func TestHNSWIndex_SaveGraph_LoadGraph_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestHNSWIndex_Topology(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 30; i++ {
+		vector := make([]float32, 128)
+		for j := range vector {
+			vector[j] = float32(i) + float32(j)*0.001
+		}
+		if err := index.Insert(i, vector); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+
+	topo := index.Topology()
+	if topo.EntryPoint != index.entryPoint || topo.MaxLevel != index.maxLevel || topo.M != 16 {
+		t.Errorf("Unexpected header: %+v", topo)
+	}
+	if len(topo.Nodes) != 30 {
+		t.Fatalf("Expected 30 nodes, got %d", len(topo.Nodes))
+	}
+	for i, node := range topo.Nodes {
+		if node.ID != uint64(i+1) {
+			t.Fatalf("Nodes not sorted: position %d has ID %d", i, node.ID)
+		}
+		if len(node.Neighbors) != node.Level+1 {
+			t.Errorf("Node %d: expected %d neighbor levels, got %d", node.ID, node.Level+1, len(node.Neighbors))
+		}
+	}
+
+	// The snapshot must not alias the live graph
+	topo.Nodes[0].Neighbors[0] = append(topo.Nodes[0].Neighbors[0][:0], 999)
+	if index.nodes[1].Neighbors[0][0] == 999 {
+		t.Error("Topology should copy neighbor lists")
+	}
+}
+
+// hostileGraph returns a graph file header with the given values followed by
+// one node of level level with neighborCount neighbors, and nothing else
+func hostileGraph(dim, M uint32, mL float64, maxLevel int32, nodeCount uint32, level int32, neighborCount uint32) []byte {
+	var buf bytes.Buffer
+	for _, v := range []any{uint32(0x48534E57), uint32(graphVersion), dim, M, uint32(200), uint32(50), mL,
+		uint64(1), maxLevel, nodeCount, uint64(1), level, int32(0), neighborCount} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestHNSWIndex_LoadGraph_HostileCounts(t *testing.T) {
+	tmpFile := createTempFile(t)
+	graphFile := tmpFile + ".graph"
+	defer os.Remove(tmpFile)
+	defer os.Remove(graphFile)
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+	index, err := NewHNSWIndex(4, map[string]any{"M": 16}, store)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"node count":     hostileGraph(4, 16, 0.36, 0, 0xFFFFFFFF, 0, 0),
+		"neighbor count": hostileGraph(4, 16, 0.36, 0, 1, 0, 0xFFFFFFFF),
+		"node level":     hostileGraph(4, 16, 0.36, 0, 1, 0x7FFFFFFF, 0),
+		"negative level": hostileGraph(4, 16, 0.36, 0, 1, -5, 0),
+		"max level":      hostileGraph(4, 16, 0.36, 0x7FFFFFFF, 1, 0, 0),
+		"dimension":      hostileGraph(0xFFFFFFFF, 16, 0.36, 0, 1, 0, 0),
+		"M":              hostileGraph(4, 0xFFFFFFFF, 0.36, 0, 1, 0, 0),
+		"mL":             hostileGraph(4, 16, math.NaN(), 0, 1, 0, 0),
+	}
+	for name, data := range cases {
+		if err := os.WriteFile(graphFile, data, 0644); err != nil {
+			t.Fatalf("Failed to write graph file: %v", err)
+		}
+		if err := index.LoadGraph(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// The same layout with sane values loads
+	if err := os.WriteFile(graphFile, hostileGraph(4, 16, 0.36, 0, 1, 0, 0), 0644); err != nil {
+		t.Fatalf("Failed to write graph file: %v", err)
+	}
+	if err := index.LoadGraph(); err != nil {
+		t.Fatalf("Expected the sane graph to load: %v", err)
+	}
+}
+
+func FuzzHNSWIndex_LoadGraph(f *testing.F) {
+	f.Add(hostileGraph(4, 16, 0.36, 0, 1, 0, 0))
+	f.Add(hostileGraph(4, 16, 0.36, 0, 0xFFFFFFFF, 0, 0xFFFFFFFF))
+	tmpFile, err := os.CreateTemp("", "veclite_hnsw_fuzz_*.db")
+	if err != nil {
+		f.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(tmpFile.Name() + ".graph")
+	store, err := storage.NewStorage(tmpFile.Name(), 4, 0)
+	if err != nil {
+		f.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		f.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(tmpFile.Name()+".graph", data, 0644); err != nil {
+			t.Fatalf("Failed to write graph file: %v", err)
+		}
+		index, err := NewHNSWIndex(4, map[string]any{"M": 16}, store)
+		if err != nil {
+			t.Fatalf("Failed to create HNSW index: %v", err)
+		}
+		index.LoadGraph() // Must fail or succeed without panicking
+	})
+}
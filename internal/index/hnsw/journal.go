@@ -0,0 +1,283 @@
+package hnsw
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Graph journal: with GraphJournal enabled, SaveGraph appends the nodes changed
+// since the last save to a journal next to the graph file instead of rewriting
+// the whole graph, and LoadGraph replays it over the graph file
+// Layout: magic, version, graph file version (edge weights must match), then
+// batches of records, each ending with a commit record. A batch torn by a
+// crash is ignored on load and overwritten by the next append
+const (
+	journalSuffix  = ".journal"
+	journalMagic   = uint32(0x4A534E48) // "HNSJ" in ASCII
+	journalVersion = 1
+
+	journalNode   = byte(1) // Node ID and neighbors, as in the graph file
+	journalDelete = byte(2) // Deleted node ID
+	journalCommit = byte(3) // Entry point, max level and node count after the batch
+
+	// journalMaxRatio is the size of the journal, relative to the graph file,
+	// beyond which SaveGraph rewrites the graph file and drops the journal
+	journalMaxRatio = 0.5
+)
+
+// journalPath returns the path of the journal of the graph file graphPath
+func journalPath(graphPath string) string {
+	return graphPath + journalSuffix
+}
+
+// touch records that the neighbors of id changed since the last save
+// Without GraphJournal, the next save rewrites the graph file instead
+func (h *HNSWIndex) touch(id uint64) {
+	if h.rewrite || !h.journal {
+		h.resetJournal()
+		return
+	}
+	if h.dirty == nil {
+		h.dirty = make(map[uint64]struct{})
+	}
+	h.dirty[id] = struct{}{}
+}
+
+// forget records that id was deleted since the last save
+// Without GraphJournal, the next save rewrites the graph file instead
+func (h *HNSWIndex) forget(id uint64) {
+	if h.rewrite || !h.journal {
+		h.resetJournal()
+		return
+	}
+	delete(h.dirty, id)
+	if h.removed == nil {
+		h.removed = make(map[uint64]struct{})
+	}
+	h.removed[id] = struct{}{}
+}
+
+// resetJournal makes the next save rewrite the graph file, e.g. after Clear or
+// a change of the graph file format
+func (h *HNSWIndex) resetJournal() {
+	h.rewrite = true
+	h.dirty, h.removed = nil, nil
+}
+
+// appendJournal appends the changes since the last save to the journal of
+// graphPath and reports whether it did; false means the graph file must be
+// rewritten instead because it is missing or the journal would grow too large
+// Note: Assumes the read lock and saveMu are held
+func (h *HNSWIndex) appendJournal(graphPath string) (bool, error) {
+	info, err := os.Stat(graphPath)
+	if err != nil {
+		return false, nil
+	}
+	if len(h.dirty) == 0 && len(h.removed) == 0 {
+		return true, nil
+	}
+
+	var batch bytes.Buffer
+	if h.journalSize == 0 {
+		version := uint32(graphVersion)
+		if h.edgeWeights {
+			version = graphVersionWeights
+		}
+		for _, v := range []uint32{journalMagic, journalVersion, version} {
+			if err := binary.Write(&batch, binary.LittleEndian, v); err != nil {
+				return false, fmt.Errorf("failed to write graph journal header: %w", err)
+			}
+		}
+	}
+	for _, id := range sortedIDs(h.removed) {
+		if _, exists := h.nodes[id]; exists {
+			continue // Deleted and inserted again: written as a node below
+		}
+		batch.WriteByte(journalDelete)
+		if err := binary.Write(&batch, binary.LittleEndian, id); err != nil {
+			return false, fmt.Errorf("failed to write deleted node %d: %w", id, err)
+		}
+	}
+	for _, id := range sortedIDs(h.dirty) {
+		node, exists := h.nodes[id]
+		if !exists {
+			continue
+		}
+		batch.WriteByte(journalNode)
+		if err := h.writeGraphNode(&batch, id, node); err != nil {
+			return false, err
+		}
+	}
+	batch.WriteByte(journalCommit)
+	if err := binary.Write(&batch, binary.LittleEndian, h.entryPoint); err != nil {
+		return false, fmt.Errorf("failed to write entry point: %w", err)
+	}
+	if err := binary.Write(&batch, binary.LittleEndian, int32(h.maxLevel)); err != nil {
+		return false, fmt.Errorf("failed to write max level: %w", err)
+	}
+	if err := binary.Write(&batch, binary.LittleEndian, uint32(len(h.nodes))); err != nil {
+		return false, fmt.Errorf("failed to write node count: %w", err)
+	}
+
+	if float64(h.journalSize+int64(batch.Len())) > journalMaxRatio*float64(info.Size()) {
+		return false, nil
+	}
+
+	file, err := os.OpenFile(journalPath(graphPath), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open graph journal: %w", err)
+	}
+	defer file.Close()
+	// Drops a batch torn by a crash after the last complete one
+	if err := file.Truncate(h.journalSize); err != nil {
+		return false, fmt.Errorf("failed to truncate graph journal: %w", err)
+	}
+	if _, err := file.WriteAt(batch.Bytes(), h.journalSize); err != nil {
+		return false, fmt.Errorf("failed to append to graph journal: %w", err)
+	}
+	h.journalSize += int64(batch.Len())
+	h.dirty, h.removed = nil, nil
+	return true, nil
+}
+
+// removeJournal deletes the journal of graphPath before the graph file is
+// rewritten, so it is never replayed over a newer graph file
+func (h *HNSWIndex) removeJournal(graphPath string) error {
+	if err := os.Remove(journalPath(graphPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove graph journal: %w", err)
+	}
+	h.journalSize = 0
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// replayJournal applies the complete batches of the journal of graphPath to
+// the nodes just loaded from it
+// Note: Assumes lock is already held
+func (h *HNSWIndex) replayJournal(graphPath string) error {
+	h.journalSize = 0
+	file, err := os.Open(journalPath(graphPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open graph journal: %w", err)
+	}
+	defer file.Close()
+	r := &countingReader{r: bufio.NewReader(file)}
+
+	var header [3]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil // Torn while being created: nothing was committed
+	}
+	version := uint32(graphVersion)
+	if h.edgeWeights {
+		version = graphVersionWeights
+	}
+	if header[0] != journalMagic {
+		return errors.New("invalid graph journal: magic number mismatch")
+	}
+	if header[1] != journalVersion {
+		return fmt.Errorf("unsupported graph journal version: %d", header[1])
+	}
+	if header[2] != version {
+		return fmt.Errorf("graph journal version %d does not match graph file version %d", header[2], version)
+	}
+	h.journalSize = r.n
+
+	var nodes []*HNSWNode
+	var deleted []uint64
+	for {
+		var kind byte
+		err := binary.Read(r, binary.LittleEndian, &kind)
+		if err == nil {
+			switch kind {
+			case journalNode:
+				var node *HNSWNode
+				if node, err = h.readGraphNode(r); err == nil {
+					nodes = append(nodes, node)
+				}
+			case journalDelete:
+				var id uint64
+				if err = binary.Read(r, binary.LittleEndian, &id); err == nil {
+					deleted = append(deleted, id)
+				}
+			case journalCommit:
+				err = h.commitJournal(r, deleted, nodes)
+				nodes, deleted = nil, nil
+				if err == nil {
+					h.journalSize = r.n
+				}
+			default:
+				return fmt.Errorf("invalid graph journal record type %d at offset %d", kind, r.n-1)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil // End of the journal or a torn batch
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// commitJournal reads a commit record and applies the batch it ends
+// Note: Assumes lock is already held
+func (h *HNSWIndex) commitJournal(r io.Reader, deleted []uint64, nodes []*HNSWNode) error {
+	var entryPoint uint64
+	var maxLevel int32
+	var nodeCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &entryPoint); err != nil {
+		return fmt.Errorf("failed to read journal entry point: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &maxLevel); err != nil {
+		return fmt.Errorf("failed to read journal max level: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &nodeCount); err != nil {
+		return fmt.Errorf("failed to read journal node count: %w", err)
+	}
+
+	for _, id := range deleted {
+		if _, exists := h.nodes[id]; exists {
+			delete(h.nodes, id)
+			h.dropWeights(id)
+			h.slots.Release(id)
+		}
+	}
+	for _, node := range nodes {
+		h.putNode(node)
+	}
+	h.entryPoint = entryPoint
+	h.maxLevel = int(maxLevel)
+	if len(h.nodes) != int(nodeCount) {
+		return fmt.Errorf("graph journal does not match graph file: %d nodes, journal expects %d", len(h.nodes), nodeCount)
+	}
+	return nil
+}
+
+// sortedIDs returns the IDs of set in ascending order
+func sortedIDs(set map[uint64]struct{}) []uint64 {
+	ids := make([]uint64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,203 @@
+package hnsw
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// createJournaledHNSW creates an index saving to the graph journal over n
+// 4-dimensional vectors, and saves it once
+func createJournaledHNSW(t *testing.T, n int) (*HNSWIndex, *storage.Storage, string) {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	t.Cleanup(func() {
+		os.Remove(tmpFile)
+		os.Remove(tmpFile + ".graph")
+		os.Remove(tmpFile + ".graph" + journalSuffix)
+	})
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	index, err := NewHNSWIndex(4, map[string]any{"M": 4, "EfConstruction": 20, "EfSearch": 20, "GraphJournal": true}, store)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := index.Insert(uint64(i), journalVector(i)); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	return index, store, tmpFile
+}
+
+// journalVector returns the i-th test vector
+func journalVector(i int) []float32 {
+	return []float32{float32(i % 17), float32(i % 5), float32(i % 3), float32(i)}
+}
+
+// reopenGraph loads the saved graph of tmpFile into a new index
+func reopenGraph(t *testing.T, tmpFile string) *HNSWIndex {
+	t.Helper()
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	loaded, err := OpenHNSWIndex(store)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	return loaded
+}
+
+func TestHNSWIndex_GraphJournal(t *testing.T) {
+	index, _, tmpFile := createJournaledHNSW(t, 200)
+	graphPath := tmpFile + ".graph"
+	base, err := os.ReadFile(graphPath)
+	if err != nil {
+		t.Fatalf("Failed to read graph file: %v", err)
+	}
+	if _, err := os.Stat(journalPath(graphPath)); !os.IsNotExist(err) {
+		t.Fatalf("Expected no journal after the first save, got %v", err)
+	}
+
+	// A few changes are appended to the journal, leaving the graph file alone
+	for i := 201; i <= 205; i++ {
+		if err := index.Insert(uint64(i), journalVector(i)); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	for _, id := range []uint64{3, 50, index.entryPoint} {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Failed to delete %d: %v", id, err)
+		}
+	}
+	if err := index.Insert(50, journalVector(1000)); err != nil {
+		t.Fatalf("Failed to re-insert 50: %v", err)
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if after, _ := os.ReadFile(graphPath); !bytes.Equal(after, base) {
+		t.Fatalf("Expected the graph file to be left alone")
+	}
+	first, err := os.Stat(journalPath(graphPath))
+	if err != nil {
+		t.Fatalf("Expected a journal: %v", err)
+	}
+	if !reflect.DeepEqual(reopenGraph(t, tmpFile).Topology(), index.Topology()) {
+		t.Fatalf("Graph loaded with the journal differs from the saved graph")
+	}
+
+	// Later saves append to it
+	if err := index.Insert(206, journalVector(206)); err != nil {
+		t.Fatalf("Failed to insert vector 206: %v", err)
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	second, err := os.Stat(journalPath(graphPath))
+	if err != nil || second.Size() <= first.Size() {
+		t.Fatalf("Expected the journal to grow past %d bytes, got %v (%v)", first.Size(), second, err)
+	}
+	if !reflect.DeepEqual(reopenGraph(t, tmpFile).Topology(), index.Topology()) {
+		t.Fatalf("Graph loaded with the journal differs from the saved graph")
+	}
+
+	// Once the journal would outgrow half the graph file, the graph file is rewritten
+	for i := 207; i <= 300; i++ {
+		if err := index.Insert(uint64(i), journalVector(i)); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if _, err := os.Stat(journalPath(graphPath)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the journal to be dropped by a rewrite, got %v", err)
+	}
+	if !reflect.DeepEqual(reopenGraph(t, tmpFile).Topology(), index.Topology()) {
+		t.Fatalf("Rewritten graph differs from the saved graph")
+	}
+}
+
+func TestHNSWIndex_GraphJournal_TornBatch(t *testing.T) {
+	index, _, tmpFile := createJournaledHNSW(t, 200)
+	graphPath := tmpFile + ".graph"
+	if err := index.Insert(201, journalVector(201)); err != nil {
+		t.Fatalf("Failed to insert vector 201: %v", err)
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	want := index.Topology()
+
+	// A crash in the middle of the next append
+	file, err := os.OpenFile(journalPath(graphPath), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	file.Write([]byte{journalNode, 1, 0, 0})
+	file.Close()
+
+	loaded := reopenGraph(t, tmpFile)
+	if !reflect.DeepEqual(loaded.Topology(), want) {
+		t.Fatalf("Expected the torn batch to be ignored")
+	}
+
+	// The next append replaces the torn batch
+	loaded.SetSearchParams(map[string]any{"GraphJournal": true})
+	if err := loaded.Insert(202, journalVector(202)); err != nil {
+		t.Fatalf("Failed to insert vector 202: %v", err)
+	}
+	if err := loaded.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if !reflect.DeepEqual(reopenGraph(t, tmpFile).Topology(), loaded.Topology()) {
+		t.Fatalf("Graph loaded with the journal differs from the saved graph")
+	}
+}
+
+func TestHNSWIndex_GraphJournal_Disabled(t *testing.T) {
+	index, _, tmpFile := createJournaledHNSW(t, 50)
+	graphPath := tmpFile + ".graph"
+	if err := index.Insert(51, journalVector(51)); err != nil {
+		t.Fatalf("Failed to insert vector 51: %v", err)
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if _, err := os.Stat(journalPath(graphPath)); err != nil {
+		t.Fatalf("Expected a journal: %v", err)
+	}
+
+	// Without GraphJournal saves rewrite the graph file and drop the journal
+	index.SetSearchParams(map[string]any{"GraphJournal": false})
+	if err := index.Insert(52, journalVector(52)); err != nil {
+		t.Fatalf("Failed to insert vector 52: %v", err)
+	}
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if _, err := os.Stat(journalPath(graphPath)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the journal to be dropped, got %v", err)
+	}
+	if !reflect.DeepEqual(reopenGraph(t, tmpFile).Topology(), index.Topology()) {
+		t.Fatalf("Rewritten graph differs from the saved graph")
+	}
+}
@@ -0,0 +1,465 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/index/utils"
+	"github.com/monishSR/veclite/internal/pq"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// Product quantization (Config "PQSubspaces"): searches score the nodes they
+// traverse with PQ codes kept in memory instead of reading every vector from
+// storage, and only read the vectors of the best candidates to re-rank them
+// exactly. Inserts keep linking nodes with exact distances
+const (
+	// pqMinTrain is the number of nodes at which a codebook is trained
+	// automatically; smaller graphs are searched exactly
+	pqMinTrain = 1024
+
+	// pqTrainSample is the maximum number of vectors read to train a codebook
+	pqTrainSample = 65536
+
+	// pqRetrainGrowth retrains a codebook trained on fewer than pqTrainSample
+	// vectors once the graph has grown by this factor, so a codebook learned
+	// from the first inserts does not stay in use for a much larger graph
+	pqRetrainGrowth = 8
+
+	// pqRerankFactor is how many candidates per result are re-ranked with
+	// their full-precision vectors
+	pqRerankFactor = 2
+
+	pqMagic = uint32(0x51504E48) // "HNPQ" in ASCII, little-endian
+)
+
+// ErrPQDisabled is returned by TrainPQ when PQSubspaces is not set
+var ErrPQDisabled = errors.New("product quantization is not enabled")
+
+// scorer computes the distances from a query to nodes during a traversal:
+// exactly from the stored vectors, or approximately from the PQ codes
+type scorer struct {
+	h        *HNSWIndex
+	query    []float32
+	codebook *pq.Codebook // Codebook the table was computed with
+	table    *pq.Table    // Distances to the codebook centroids (nil = exact)
+	p        *profile.Profile
+}
+
+// exactScorer scores nodes by their stored vectors, e.g. to link new nodes
+func (h *HNSWIndex) exactScorer(query []float32, p *profile.Profile) scorer {
+	return scorer{h: h, query: query, p: p}
+}
+
+// searchScorer scores nodes by their PQ codes if a codebook is trained
+func (h *HNSWIndex) searchScorer(query []float32, p *profile.Profile) scorer {
+	s := h.exactScorer(query, p)
+	if h.codebook != nil {
+		s.codebook, s.table = h.codebook, h.codebook.Table(query)
+	}
+	return s
+}
+
+// distance returns the distance from the query to node id, false if its
+// vector can't be read
+// Falls back to exact distances once the codebook was retrained or dropped,
+// e.g. for a Frontier kept across writes
+func (s scorer) distance(id uint64) (float32, bool) {
+	if s.table != nil && s.codebook == s.h.codebook {
+		node, exists := s.h.nodes[id]
+		if !exists {
+			return 0, false
+		}
+		s.p.AddDistances(1)
+		return s.table.Distance(s.h.code(node.slot)), true
+	}
+	vec, err := s.h.storage.ReadVectorProfiled(id, s.p)
+	if err != nil {
+		return 0, false
+	}
+	s.p.AddDistances(1)
+	return vector.L2Distance(s.query, vec), true
+}
+
+// rerank reads the vectors of candidates, sorts them by exact distance to
+// query and returns the k nearest as results, with a copy of each vector if
+// withVectors is set
+// With storage pivots (see storage.EnablePivots), candidates whose pivot bound
+// is farther than the k-th exact distance so far are skipped without being read
+func (h *HNSWIndex) rerank(query []float32, candidates []candidate, k int, withVectors bool, p *profile.Profile) []types.SearchResult {
+	pivots := h.storage.PivotQuery(query)
+	kth := utils.NewCandidateHeap(k) // k nearest exact distances so far
+	results := make([]types.SearchResult, 0, len(candidates))
+	for _, cand := range candidates {
+		if pivots != nil && kth.Len() == k {
+			if bound, ok := pivots.LowerBound(cand.id); ok && bound > kth.Peek().Distance {
+				continue
+			}
+		}
+		vec, err := h.storage.ReadVectorProfiled(cand.id, p)
+		if err != nil {
+			continue // Skip this result if vector can't be read (inconsistent state)
+		}
+		p.AddDistances(1)
+		dist := vector.L2Distance(query, vec)
+		kth.AddCandidate(utils.Candidate{ID: cand.id, Distance: dist}, k)
+		result := types.SearchResult{ID: cand.id, Distance: dist}
+		if withVectors {
+			result.Vector = append([]float32(nil), vec...)
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// code returns the PQ code of the node in slot
+func (h *HNSWIndex) code(slot uint32) []byte {
+	m := h.codebook.Subspaces()
+	return h.codes[int(slot)*m : (int(slot)+1)*m]
+}
+
+// encode stores the PQ code of the node in slot (no-op without a codebook)
+// While a training runs the slot is noted to be encoded again by it
+func (h *HNSWIndex) encode(slot uint32, vec []float32) {
+	if h.pqTraining != nil {
+		h.pqStale = append(h.pqStale, slot)
+	}
+	if h.codebook == nil {
+		return
+	}
+	m := h.codebook.Subspaces()
+	if need := (int(slot) + 1) * m; need > len(h.codes) {
+		h.codes = append(h.codes, make([]byte, max(need, 2*len(h.codes))-len(h.codes))...)
+	}
+	h.codebook.Encode(vec, h.code(slot))
+}
+
+// pqJob is a training of the codebook from a snapshot of the graph
+type pqJob struct {
+	ids       []uint64 // Nodes of the snapshot, sorted
+	slots     []uint32 // Slot of ids[n] at the snapshot
+	dimension int
+	subspaces int
+	gen       uint64 // pqGen at the snapshot
+	done      chan struct{}
+}
+
+// errPQReset is returned by TrainPQ when the codebook was dropped while it trained
+var errPQReset = errors.New("PQ codebook was reset during training")
+
+// maybeTrainPQ starts a background training once PQ is enabled and the graph
+// is large enough, and as the graph grows (see pqRetrainGrowth)
+// Inserts never wait for it; a failed training (see PQError) is retried once
+// pqMinTrain more nodes were added
+// Note: Assumes write lock is already held
+func (h *HNSWIndex) maybeTrainPQ() {
+	if h.pqSubspaces <= 0 || h.storage == nil || h.pqTraining != nil || len(h.nodes) < pqMinTrain {
+		return
+	}
+	if h.pqErr != nil && len(h.nodes) < h.pqAttempt+pqMinTrain {
+		return
+	}
+	if h.codebook == nil || (h.pqTrainedOn < pqTrainSample && len(h.nodes) >= pqRetrainGrowth*h.pqTrainedOn) {
+		job := h.startPQ()
+		go h.runPQ(job) // Its error is kept for PQError
+	}
+}
+
+// TrainPQ (re)trains the PQ codebook from up to pqTrainSample stored vectors
+// and encodes every node with it, after waiting for a background training
+// Searches and writes go on while it trains: the write lock is only held to
+// snapshot the nodes and to swap the codebook in
+func (h *HNSWIndex) TrainPQ() error {
+	h.mu.Lock()
+	for h.pqTraining != nil {
+		done := h.pqTraining
+		h.mu.Unlock()
+		<-done
+		h.mu.Lock()
+	}
+	if h.pqSubspaces <= 0 {
+		h.mu.Unlock()
+		return ErrPQDisabled
+	}
+	if h.storage == nil {
+		h.mu.Unlock()
+		return errors.New("storage is required to train PQ")
+	}
+	job := h.startPQ()
+	h.mu.Unlock()
+	return h.runPQ(job)
+}
+
+// startPQ snapshots the nodes for a training and marks it running
+// Note: Assumes write lock is already held
+func (h *HNSWIndex) startPQ() *pqJob {
+	job := &pqJob{dimension: h.dimension, subspaces: h.pqSubspaces, gen: h.pqGen, done: make(chan struct{})}
+	job.ids = make([]uint64, 0, len(h.nodes))
+	for id := range h.nodes {
+		job.ids = append(job.ids, id)
+	}
+	sort.Slice(job.ids, func(i, j int) bool { return job.ids[i] < job.ids[j] })
+	job.slots = make([]uint32, len(job.ids))
+	for n, id := range job.ids {
+		job.slots[n] = h.nodes[id].slot
+	}
+	h.pqTraining, h.pqStale, h.pqAttempt = job.done, nil, len(h.nodes)
+	return job
+}
+
+// runPQ trains the codebook of job without holding the lock, then swaps it in
+// and records the outcome for PQError
+// A retraining the graph became due for in the meantime is started next
+func (h *HNSWIndex) runPQ(job *pqJob) error {
+	defer close(job.done)
+	codebook, codes, trainedOn, err := pq.TrainIDs(job.ids, h.readStored, job.dimension, job.subspaces, pqTrainSample)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		err = h.installPQ(job, codebook, codes, trainedOn)
+	}
+	h.pqTraining, h.pqStale = nil, nil
+	if job.gen == h.pqGen {
+		h.pqErr = err
+	}
+	h.maybeTrainPQ()
+	return err
+}
+
+// readStored reads the vector of id for a training, nil if it was deleted
+func (h *HNSWIndex) readStored(id uint64) ([]float32, error) {
+	vec, err := h.storage.ReadVector(id)
+	if err != nil && !h.storage.Has(id) {
+		return nil, nil
+	}
+	return vec, err
+}
+
+// installPQ swaps in a codebook trained by job with the codes of its
+// snapshot, encoding the nodes inserted since
+// Note: Assumes write lock is already held
+func (h *HNSWIndex) installPQ(job *pqJob, codebook *pq.Codebook, codes []byte, trainedOn int) error {
+	if job.gen != h.pqGen {
+		return errPQReset
+	}
+	m := job.subspaces
+	slotCodes := make([]byte, h.slots.Cap()*m)
+	for n, slot := range job.slots {
+		if int(slot) < h.slots.Cap() {
+			copy(slotCodes[int(slot)*m:], codes[n*m:(n+1)*m])
+		}
+	}
+	for _, slot := range h.pqStale {
+		id, exists := h.slots.External(slot)
+		if !exists {
+			continue // Deleted again
+		}
+		vec, err := h.storage.ReadVector(id)
+		if err != nil {
+			return fmt.Errorf("failed to read vector %d for PQ encoding: %w", id, err)
+		}
+		codebook.Encode(vec, slotCodes[int(slot)*m:(int(slot)+1)*m])
+	}
+	h.codebook, h.codes, h.pqTrainedOn = codebook, slotCodes, trainedOn
+	return nil
+}
+
+// PQError returns the error of the last codebook training, nil if it
+// succeeded or none ran
+func (h *HNSWIndex) PQError() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.pqErr
+}
+
+// WaitPQ waits for background trainings to end and returns PQError
+func (h *HNSWIndex) WaitPQ() error {
+	for {
+		h.mu.RLock()
+		done, err := h.pqTraining, h.pqErr
+		h.mu.RUnlock()
+		if done == nil {
+			return err
+		}
+		<-done
+	}
+}
+
+// PQTrained reports whether searches score candidates with PQ codes
+func (h *HNSWIndex) PQTrained() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.codebook != nil
+}
+
+// setPQSubspaces enables PQ with the given code size (<= 0 disables it)
+// A codebook of another size is dropped and retrained
+func (h *HNSWIndex) setPQSubspaces(subspaces int) {
+	subspaces = max(subspaces, 0)
+	if subspaces == h.pqSubspaces {
+		return
+	}
+	h.pqSubspaces = subspaces
+	h.codebook, h.codes, h.pqErr = nil, nil, nil
+	h.pqGen++
+	h.maybeTrainPQ()
+}
+
+// pqPath returns the path of the PQ sidecar (codebook and node codes)
+func (h *HNSWIndex) pqPath() string {
+	return h.storage.GetFilePath() + ".pq"
+}
+
+// savePQ writes the codebook and the codes of all nodes to the PQ sidecar,
+// or removes it if no codebook is trained
+// The file is written next to the sidecar and renamed over it, so a crash
+// leaves the previous one intact
+// Layout: magic, training sample size, codebook (see pq.Codebook.WriteTo),
+// node count, then per node in ID order its ID and code
+func (h *HNSWIndex) savePQ() error {
+	path := h.pqPath()
+	if h.codebook == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove PQ file: %w", err)
+		}
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create PQ file: %w", err)
+	}
+	if err := h.writePQ(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync PQ file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close PQ file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename PQ file: %w", err)
+	}
+	return nil
+}
+
+// writePQ writes the contents of the PQ sidecar (see savePQ) to file
+func (h *HNSWIndex) writePQ(file io.Writer) error {
+	w := bufio.NewWriter(file)
+	if err := binary.Write(w, binary.LittleEndian, pqMagic); err != nil {
+		return fmt.Errorf("failed to write PQ magic: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(h.pqTrainedOn)); err != nil {
+		return fmt.Errorf("failed to write PQ training size: %w", err)
+	}
+	if _, err := h.codebook.WriteTo(w); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(h.nodes))); err != nil {
+		return fmt.Errorf("failed to write PQ node count: %w", err)
+	}
+	ids := make([]uint64, 0, len(h.nodes))
+	for id := range h.nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if err := binary.Write(w, binary.LittleEndian, id); err != nil {
+			return fmt.Errorf("failed to write PQ code of node %d: %w", id, err)
+		}
+		if _, err := w.Write(h.code(h.nodes[id].slot)); err != nil {
+			return fmt.Errorf("failed to write PQ code of node %d: %w", id, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write PQ file: %w", err)
+	}
+	return nil
+}
+
+// loadPQ reads the PQ sidecar if it exists; nodes must be loaded first
+// Without the sidecar, or with one missing nodes, the codebook is retrained
+// once the graph qualifies
+func (h *HNSWIndex) loadPQ() error {
+	h.codebook, h.codes = nil, nil
+	h.pqGen++
+	file, err := os.Open(h.pqPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open PQ file: %w", err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	var magic, trainedOn, count uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return fmt.Errorf("failed to read PQ magic: %w", err)
+	}
+	if magic != pqMagic {
+		return errors.New("invalid PQ file: magic number mismatch")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &trainedOn); err != nil {
+		return fmt.Errorf("failed to read PQ training size: %w", err)
+	}
+	codebook, err := pq.Read(r)
+	if err != nil {
+		return err
+	}
+	if codebook.Dimension() != h.dimension {
+		return fmt.Errorf("PQ codebook dimension %d does not match index dimension %d", codebook.Dimension(), h.dimension)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return fmt.Errorf("failed to read PQ node count: %w", err)
+	}
+
+	m := codebook.Subspaces()
+	codes := make([]byte, h.slots.Cap()*m)
+	coded := 0
+	code := make([]byte, m)
+	for i := uint32(0); i < count; i++ {
+		var id uint64
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return fmt.Errorf("failed to read PQ code %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, code); err != nil {
+			return fmt.Errorf("failed to read PQ code of node %d: %w", id, err)
+		}
+		if node, exists := h.nodes[id]; exists {
+			copy(codes[int(node.slot)*m:], code)
+			coded++
+		}
+	}
+	if coded != len(h.nodes) {
+		return nil // Stale sidecar: retrain
+	}
+	h.pqSubspaces, h.codebook, h.codes, h.pqTrainedOn = m, codebook, codes, int(trainedOn)
+	return nil
+}
@@ -0,0 +1,257 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// createPQHNSW creates an index with PQ over n random 8-dimensional vectors
+func createPQHNSW(t *testing.T, n int) (*HNSWIndex, *storage.Storage, string, [][]float32) {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	t.Cleanup(func() {
+		os.Remove(tmpFile)
+		os.Remove(tmpFile + ".graph")
+		os.Remove(tmpFile + ".pq")
+	})
+	store, err := storage.NewStorage(tmpFile, 8, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	index, err := NewHNSWIndex(8, map[string]any{"M": 8, "EfConstruction": 40, "EfSearch": 40, "PQSubspaces": 4}, store)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, 8)
+		for d := range vectors[i] {
+			vectors[i][d] = rng.Float32()
+		}
+		if err := index.Insert(uint64(i+1), vectors[i]); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i+1, err)
+		}
+	}
+	if err := index.WaitPQ(); err != nil {
+		t.Fatalf("Background PQ training failed: %v", err)
+	}
+	return index, store, tmpFile, vectors
+}
+
+func TestHNSWIndex_PQ(t *testing.T) {
+	index, _, _, vectors := createPQHNSW(t, pqMinTrain+200)
+	if !index.PQTrained() {
+		t.Fatal("Expected a codebook once the graph reached pqMinTrain nodes")
+	}
+
+	query := vectors[10]
+	results, err := index.Search(query, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 10 || results[0].ID != 11 || results[0].Distance != 0 {
+		t.Fatalf("Expected 10 results starting with the query's own vector, got %v", results)
+	}
+	// Results carry exact distances, sorted
+	for i, r := range results {
+		if exact := vector.L2Distance(query, vectors[r.ID-1]); r.Distance != exact {
+			t.Errorf("Result %d: expected exact distance %v, got %v", i, exact, r.Distance)
+		}
+		if i > 0 && r.Distance < results[i-1].Distance {
+			t.Errorf("Expected results sorted by distance, got %v after %v", r.Distance, results[i-1].Distance)
+		}
+	}
+
+	// Recall against brute force
+	ids := make([]int, len(vectors))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return vector.L2Distance(query, vectors[ids[i]]) < vector.L2Distance(query, vectors[ids[j]])
+	})
+	truth := make(map[uint64]bool)
+	for _, i := range ids[:10] {
+		truth[uint64(i+1)] = true
+	}
+	found := 0
+	for _, r := range results {
+		if truth[r.ID] {
+			found++
+		}
+	}
+	if found < 7 {
+		t.Errorf("Expected a recall of at least 7/10 with PQ, got %d/10", found)
+	}
+}
+
+func TestHNSWIndex_PQ_RoundTrip(t *testing.T) {
+	index, store, tmpFile, vectors := createPQHNSW(t, pqMinTrain+10)
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if _, err := os.Stat(tmpFile + ".pq"); err != nil {
+		t.Fatalf("Expected a PQ file: %v", err)
+	}
+	codebook := index.codebook
+	store.Close()
+
+	store2, err := storage.NewStorage(tmpFile, 8, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store2.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store2.Close()
+	index2, err := NewHNSWIndex(8, map[string]any{"M": 8, "EfConstruction": 40, "EfSearch": 40, "PQSubspaces": 4}, store2)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+	if err := index2.LoadGraph(); err != nil {
+		t.Fatalf("Failed to load graph: %v", err)
+	}
+	if !index2.PQTrained() || index2.codebook.Subspaces() != codebook.Subspaces() {
+		t.Fatal("Expected the codebook to be loaded")
+	}
+	for id, node := range index.nodes {
+		if string(index.code(node.slot)) != string(index2.code(index2.nodes[id].slot)) {
+			t.Fatalf("Expected the code of node %d to survive reloading", id)
+		}
+	}
+	results, err := index2.Search(vectors[0], 1)
+	if err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("Expected ID 1 after reloading, got %v, %v", results, err)
+	}
+
+	// Disabling PQ drops the codebook and the sidecar on the next save
+	index2.SetSearchParams(map[string]any{"PQSubspaces": 0})
+	if index2.PQTrained() {
+		t.Error("Expected disabling PQ to drop the codebook")
+	}
+	if err := index2.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if _, err := os.Stat(tmpFile + ".pq"); !os.IsNotExist(err) {
+		t.Errorf("Expected the PQ file to be removed, got %v", err)
+	}
+	if err := index2.TrainPQ(); !errors.Is(err, ErrPQDisabled) {
+		t.Errorf("Expected ErrPQDisabled, got %v", err)
+	}
+}
+
+func TestHNSWIndex_PQ_Small(t *testing.T) {
+	index, _, _, _ := createPQHNSW(t, 100)
+	if index.PQTrained() {
+		t.Error("Expected no codebook below pqMinTrain nodes")
+	}
+	// TrainPQ trains on demand regardless of size
+	if err := index.TrainPQ(); err != nil {
+		t.Fatalf("TrainPQ failed: %v", err)
+	}
+	if !index.PQTrained() {
+		t.Error("Expected a codebook after TrainPQ")
+	}
+}
+
+func TestHNSWIndex_PQ_PivotRerank(t *testing.T) {
+	index, store, _, vectors := createPQHNSW(t, pqMinTrain+200)
+	queries := [][]float32{vectors[10], vectors[500], {0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5}}
+	var want [][]types.SearchResult
+	var exact uint64
+	for _, query := range queries {
+		p := profile.New(false)
+		results, err := index.SearchContext(profile.NewContext(context.Background(), p), query, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		want = append(want, results)
+		exact += p.Counters.Distances
+	}
+
+	if err := store.EnablePivots(8); err != nil {
+		t.Fatalf("EnablePivots failed: %v", err)
+	}
+	var pruned uint64
+	for i, query := range queries {
+		p := profile.New(false)
+		results, err := index.SearchContext(profile.NewContext(context.Background(), p), query, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if !reflect.DeepEqual(results, want[i]) {
+			t.Errorf("Query %d: pivot re-ranking changed the results:\n%v\n%v", i, results, want[i])
+		}
+		pruned += p.Counters.Distances
+	}
+	if pruned > exact {
+		t.Errorf("Expected pivots to skip re-ranking reads, computed %d distances instead of %d", pruned, exact)
+	}
+}
+
+func TestHNSWIndex_PQ_Background(t *testing.T) {
+	index, _, tmpFile, vectors := createPQHNSW(t, pqMinTrain+10)
+
+	// Nodes inserted and replaced while a training runs are encoded when it is swapped in
+	index.mu.Lock()
+	job := index.startPQ()
+	index.mu.Unlock()
+	rng := rand.New(rand.NewSource(8))
+	for i, end := len(vectors), len(vectors)+50; i < end; i++ {
+		vec := make([]float32, 8)
+		for d := range vec {
+			vec[d] = rng.Float32()
+		}
+		vectors = append(vectors, vec)
+		if err := index.Insert(uint64(i+1), vec); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i+1, err)
+		}
+	}
+	vectors[4] = []float32{1, 1, 1, 1, 0, 0, 0, 0}
+	if err := index.Insert(5, vectors[4]); err != nil {
+		t.Fatalf("Failed to replace vector 5: %v", err)
+	}
+	if err := index.runPQ(job); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	code := make([]byte, 4)
+	for id, node := range index.nodes {
+		index.codebook.Encode(vectors[id-1], code)
+		if string(index.code(node.slot)) != string(code) {
+			t.Fatalf("Expected node %d to be encoded with the new codebook", id)
+		}
+	}
+
+	// Saves are deterministic
+	var saved [][]byte
+	for n := 0; n < 2; n++ {
+		if err := index.SaveGraph(); err != nil {
+			t.Fatalf("Failed to save graph: %v", err)
+		}
+		data, err := os.ReadFile(tmpFile + ".pq")
+		if err != nil {
+			t.Fatalf("Failed to read PQ file: %v", err)
+		}
+		saved = append(saved, data)
+	}
+	if string(saved[0]) != string(saved[1]) {
+		t.Error("Expected identical PQ files from identical saves")
+	}
+	if _, err := os.Stat(tmpFile + ".pq.tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary PQ file after a save, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package hnsw
+
+import "github.com/monishSR/veclite/internal/idmap"
+
+// visitedSet is the visited set of one searchLevel call, a bitset over node slots
+// Only the bits that were set are cleared on release, so reuse costs nothing
+// proportional to the graph size
+type visitedSet struct {
+	bits  idmap.Bitset
+	slots []uint32 // Set bits, for clearing
+	gen   uint64   // Pool generation the set belongs to
+}
+
+// visit marks slot and reports whether it was not visited before
+func (s *visitedSet) visit(slot uint32) bool {
+	if s.bits.TestAndSet(slot) {
+		return false
+	}
+	s.slots = append(s.slots, slot)
+	return true
+}
+
+// putNode adds node to the graph, assigning its slot
+func (h *HNSWIndex) putNode(node *HNSWNode) {
+	if h.slots == nil {
+		h.slots = idmap.New()
+	}
+	node.slot = h.slots.Assign(node.ID)
+	h.nodes[node.ID] = node
+}
+
+// acquireVisited returns an empty visited set able to hold every slot
+// Callers hold at least the read lock of the index, so no slot is assigned
+// while it is used
+func (h *HNSWIndex) acquireVisited() *visitedSet {
+	gen := h.visitedGen.Load()
+	s, _ := h.visited.Get().(*visitedSet)
+	for s != nil && s.gen != gen {
+		s, _ = h.visited.Get().(*visitedSet) // Released by ReleaseScratch
+	}
+	if s == nil {
+		s = &visitedSet{gen: gen}
+	}
+	if h.slots != nil {
+		s.bits.Grow(h.slots.Cap())
+	}
+	return s
+}
+
+// releaseVisited empties s and returns it to the pool
+func (h *HNSWIndex) releaseVisited(s *visitedSet) {
+	for _, slot := range s.slots {
+		s.bits.Clear(slot)
+	}
+	s.slots = s.slots[:0]
+	if s.gen == h.visitedGen.Load() {
+		h.visited.Put(s)
+	}
+}
+
+// ReleaseScratch drops the pooled visited sets, which grow with the graph:
+// sets of older generations are discarded instead of reused, so their memory
+// is freed by garbage collection
+// Safe to call concurrently with searches
+func (h *HNSWIndex) ReleaseScratch() {
+	h.visitedGen.Add(1)
+}
@@ -0,0 +1,146 @@
+package hnsw
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// repairRefillLimit is the maximum number of neighbor lists one Repair pass
+// refills; the rest wait for the next pass, worst first
+const repairRefillLimit = 1024
+
+// unknownWeight is persisted for edges whose distance can't be computed (a
+// vector missing from storage)
+const unknownWeight = float32(-1)
+
+// setWeight records the distance between a and b, for both edge directions
+// No-op unless edge weights are enabled
+func (h *HNSWIndex) setWeight(a, b uint64, distance float32) {
+	if !h.edgeWeights {
+		return
+	}
+	if h.weights == nil {
+		h.weights = make(map[uint64]map[uint64]float32)
+	}
+	for _, pair := range [2][2]uint64{{a, b}, {b, a}} {
+		if h.weights[pair[0]] == nil {
+			h.weights[pair[0]] = make(map[uint64]float32)
+		}
+		h.weights[pair[0]][pair[1]] = distance
+	}
+}
+
+// dropWeights forgets the recorded distances of id, whose vector was deleted
+// or replaced
+func (h *HNSWIndex) dropWeights(id uint64) {
+	for other := range h.weights[id] {
+		delete(h.weights[other], id)
+	}
+	delete(h.weights, id)
+}
+
+// edgeDistance returns the distance of the edge from -> to, computed from the
+// stored vectors if it is not recorded (unknownWeight if they can't be read)
+// Never records what it computes, so read-locked callers can use it
+func (h *HNSWIndex) edgeDistance(from, to uint64) float32 {
+	if distance, ok := h.weights[from][to]; ok {
+		return distance
+	}
+	if h.storage == nil {
+		return unknownWeight
+	}
+	a, err := h.storage.ReadVector(from)
+	if err != nil {
+		return unknownWeight
+	}
+	b, err := h.storage.ReadVector(to)
+	if err != nil {
+		return unknownWeight
+	}
+	return vector.L2Distance(a, b)
+}
+
+// refillDamaged reconnects nodes that lost bottom-layer neighbors to deletes,
+// those whose longest remaining edge is worst first, and returns how many
+// neighbor lists changed
+// A node left without neighbors counts as the worst
+func (h *HNSWIndex) refillDamaged() int {
+	type damagedNode struct {
+		id    uint64
+		worst float32
+	}
+	queue := make([]damagedNode, 0, len(h.damaged))
+	for id := range h.damaged {
+		node, exists := h.nodes[id]
+		if !exists {
+			delete(h.damaged, id)
+			continue
+		}
+		worst := float32(math.Inf(1))
+		if len(node.Neighbors[0]) > 0 {
+			worst = 0
+			for _, neighborID := range node.Neighbors[0] {
+				worst = max(worst, h.edgeDistance(id, neighborID))
+			}
+		}
+		queue = append(queue, damagedNode{id: id, worst: worst})
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if queue[i].worst != queue[j].worst {
+			return queue[i].worst > queue[j].worst
+		}
+		return queue[i].id < queue[j].id
+	})
+
+	refilled := 0
+	for _, damaged := range queue[:min(len(queue), repairRefillLimit)] {
+		delete(h.damaged, damaged.id)
+		if h.refill(damaged.id) {
+			refilled++
+		}
+	}
+	return refilled
+}
+
+// refill replaces the bottom-layer neighbors of id with the M nearest among
+// them and a fresh search, and reports whether the list changed
+func (h *HNSWIndex) refill(id uint64) bool {
+	vec, err := h.storage.ReadVector(id)
+	if err != nil {
+		return false
+	}
+	node := h.nodes[id]
+	found := make([]candidate, 0, len(node.Neighbors[0]))
+	seen := map[uint64]bool{id: true}
+	for _, neighborID := range node.Neighbors[0] {
+		seen[neighborID] = true
+		found = append(found, candidate{id: neighborID, distance: h.edgeDistance(id, neighborID)})
+	}
+	for _, cand := range h.searchLevel(context.Background(), h.exactScorer(vec, nil), h.entryPoint, 0, h.efConstruction) {
+		if !seen[cand.id] {
+			seen[cand.id] = true
+			found = append(found, cand)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].id < found[j].id
+	})
+	found = found[:min(len(found), h.M)]
+
+	changed := len(found) != len(node.Neighbors[0])
+	neighbors := make([]uint64, len(found))
+	for i, cand := range found {
+		neighbors[i] = cand.id
+		changed = changed || !containsID(node.Neighbors[0], cand.id)
+		h.setWeight(id, cand.id, cand.distance)
+	}
+	node.Neighbors[0] = neighbors
+	h.touch(id)
+	return changed
+}
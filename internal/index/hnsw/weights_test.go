@@ -0,0 +1,159 @@
+package hnsw
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// createWeightedHNSW creates an index with edge weights over n 4-dimensional
+// vectors on a line
+func createWeightedHNSW(t *testing.T, n int) (*HNSWIndex, *storage.Storage, string) {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	t.Cleanup(func() {
+		os.Remove(tmpFile)
+		os.Remove(tmpFile + ".graph")
+	})
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	index, err := NewHNSWIndex(4, map[string]any{"M": 4, "EfConstruction": 20, "EfSearch": 20, "EdgeWeights": true}, store)
+	if err != nil {
+		t.Fatalf("Failed to create HNSW index: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := index.Insert(uint64(i), []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	return index, store, tmpFile
+}
+
+func TestHNSWIndex_EdgeWeights_RoundTrip(t *testing.T) {
+	index, store, tmpFile := createWeightedHNSW(t, 30)
+	if err := index.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	store.Close()
+
+	header := make([]byte, 8)
+	file, err := os.Open(tmpFile + ".graph")
+	if err != nil {
+		t.Fatalf("Failed to open graph file: %v", err)
+	}
+	_, err = file.Read(header)
+	file.Close()
+	if want := uint32(graphVersionWeights + graphVersionInfo); err != nil || binary.LittleEndian.Uint32(header[4:]) != want {
+		t.Fatalf("Expected graph file version %d, got header %v (%v)", want, header, err)
+	}
+
+	store2, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store2.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store2.Close()
+	loaded, err := OpenHNSWIndex(store2)
+	if err != nil {
+		t.Fatalf("Failed to open index: %v", err)
+	}
+	if !loaded.edgeWeights {
+		t.Fatalf("Expected edge weights to be enabled by the graph file")
+	}
+
+	topology := loaded.Topology()
+	for _, node := range topology.Nodes {
+		for level, neighbors := range node.Neighbors {
+			for i, neighborID := range neighbors {
+				want := vector.L2Distance([]float32{float32(node.ID), 0, 0, 0}, []float32{float32(neighborID), 0, 0, 0})
+				if weight, ok := loaded.weights[node.ID][neighborID]; !ok || weight != want {
+					t.Fatalf("Expected persisted weight %f for %d -> %d, got %f (%v)", want, node.ID, neighborID, weight, ok)
+				}
+				if node.Distances[level][i] != want {
+					t.Fatalf("Expected topology distance %f for %d -> %d, got %f", want, node.ID, neighborID, node.Distances[level][i])
+				}
+			}
+		}
+	}
+
+	// Disabling the weights saves the original format again
+	loaded.SetSearchParams(map[string]any{"EdgeWeights": false})
+	if err := loaded.SaveGraph(); err != nil {
+		t.Fatalf("Failed to save graph: %v", err)
+	}
+	if topology := loaded.Topology(); topology.Nodes[0].Distances != nil {
+		t.Errorf("Expected no distances without edge weights")
+	}
+	reloaded, err := OpenHNSWIndex(store2)
+	if err != nil || reloaded.edgeWeights || reloaded.Size() != 30 {
+		t.Errorf("Expected a version %d graph of 30 nodes, got %v", graphVersion, err)
+	}
+}
+
+func TestHNSWIndex_EdgeWeights_RepairRefills(t *testing.T) {
+	index, _, _ := createWeightedHNSW(t, 40)
+
+	for id := uint64(10); id <= 20; id++ {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if len(index.damaged) == 0 {
+		t.Fatalf("Expected deletes to mark the nodes that lost neighbors")
+	}
+	for id := range index.weights {
+		if id >= 10 && id <= 20 {
+			t.Fatalf("Expected the weights of deleted node %d to be dropped", id)
+		}
+	}
+
+	if fixes := index.Repair(); fixes == 0 {
+		t.Fatalf("Expected Repair to refill damaged neighbor lists")
+	}
+	if len(index.damaged) != 0 {
+		t.Errorf("Expected every damaged node to be refilled, %d left", len(index.damaged))
+	}
+
+	// Node 9 lost its right-hand neighbors: its refilled list holds live nodes,
+	// nearest first, with the neighbors on its own side of the gap
+	neighbors := index.nodes[9].Neighbors[0]
+	if len(neighbors) < 2 || len(neighbors) > index.M || neighbors[0] != 8 || neighbors[1] != 7 {
+		t.Fatalf("Expected up to M neighbors starting with 8 and 7, got %v", neighbors)
+	}
+	for i, neighborID := range neighbors {
+		if _, exists := index.nodes[neighborID]; !exists {
+			t.Errorf("Neighbor %d of node 9 was deleted", neighborID)
+		}
+		if i > 0 && index.edgeDistance(9, neighborID) < index.edgeDistance(9, neighbors[i-1]) {
+			t.Errorf("Expected neighbors nearest first, got %v", neighbors)
+		}
+	}
+}
+
+func TestHNSWIndex_NoEdgeWeights(t *testing.T) {
+	index, cleanup := createTestHNSW(t)
+	defer cleanup()
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := index.Delete(5); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if index.weights != nil || index.damaged != nil {
+		t.Errorf("Expected no weight bookkeeping without edge weights")
+	}
+}
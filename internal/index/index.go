@@ -1,6 +1,7 @@
 package index
 
 import (
+	"context"
 	"errors"
 	"os"
 
@@ -13,30 +14,101 @@ import (
 
 // Index is the interface for vector indexing structures
 type Index interface {
-	Insert(id uint64, vector []float32) error
+	Insert(id uint64, vector []float32) error // Insert or replace; a replaced vector is re-indexed
 	Search(query []float32, k int) ([]types.SearchResult, error)
-	ReadVector(id uint64) ([]float32, error) // Read vector by ID
-	Delete(id uint64) error                  // Delete vector by ID
-	Size() int                               // Get number of vectors
-	Clear() error                            // Clear all vectors
+	SearchContext(ctx context.Context, query []float32, k int) ([]types.SearchResult, error) // Search with cancellation
+	ReadVector(id uint64) ([]float32, error)                                                 // Read vector by ID
+	Delete(id uint64) error                                                                  // Delete vector by ID
+	Size() int                                                                               // Get number of vectors
+	Clear() error                                                                            // Clear all vectors
+}
+
+// LocalityOrderer is implemented by indexes that can suggest a physical record order
+// in which vectors accessed together (graph neighbors, cluster members) are adjacent
+type LocalityOrderer interface {
+	LocalityOrder() []uint64
+}
+
+// ExistingIndexer is implemented by indexes that can index a vector already persisted
+// in storage without writing it again, so the index can be rebuilt from the data file
+type ExistingIndexer interface {
+	IndexExisting(id uint64, vector []float32) error
+}
+
+// BatchInserter is implemented by indexes that can insert many vectors with a
+// single storage append (see storage.WriteVectors) instead of one per vector
+type BatchInserter interface {
+	InsertBatch(ids []uint64, vectors [][]float32) error
+}
+
+// Parameterized is implemented by indexes whose parameters are persisted with the
+// index structure and may differ from the ones a database is reopened with
+type Parameterized interface {
+	Params() map[string]int                // Parameters in effect, keyed like the config map
+	SetSearchParams(config map[string]any) // Apply query-time parameters that need no rebuild
+}
+
+// Histogrammer is implemented by indexes that can describe how vectors are
+// spread over their structure: counts[i] is the number of vectors in bucket i
+// of the unit named by label (HNSW: nodes by top "level"; IVF: vectors per "cluster")
+type Histogrammer interface {
+	Histogram() (label string, counts []int)
+}
+
+// CandidateSearcher is implemented by indexes that can return the candidates a
+// search ranks before truncating to k, with the distances the index computed,
+// without reading or copying their vectors (n <= 0 = every candidate visited)
+type CandidateSearcher interface {
+	Candidates(ctx context.Context, query []float32, n int) ([]types.SearchResult, error)
+}
+
+// FrontierSearcher is implemented by indexes that can continue a search from
+// its retained candidate frontier, e.g. to load more results, instead of
+// restarting it with a larger k
+type FrontierSearcher interface {
+	SearchFrontier(ctx context.Context, query []float32, k int) ([]types.SearchResult, types.Frontier, error)
+}
+
+// ScratchReleaser is implemented by indexes that keep reusable per-search
+// buffers, so the buffers can be dropped under memory pressure
+type ScratchReleaser interface {
+	ReleaseScratch()
+}
+
+// PQTrainer is implemented by indexes that train a PQ codebook in the
+// background as they grow, so writes never wait for the training
+type PQTrainer interface {
+	TrainPQ() error // Train now, waiting for the training
+	WaitPQ() error  // Wait for a background training to be swapped in; returns PQError
+	PQError() error // Error of the last training (nil = none ran or it succeeded)
 }
 
 // SearchResult is an alias to types.SearchResult for convenience
 type SearchResult = types.SearchResult
 
+// Frontier is an alias to types.Frontier for convenience
+type Frontier = types.Frontier
+
 // Re-export errors for convenience
 var (
 	ErrDimensionMismatch = types.ErrDimensionMismatch
 	ErrInvalidK          = types.ErrInvalidK
+	ErrNotFound          = types.ErrNotFound
 )
 
+// WithoutVectors re-exports types.WithoutVectors for convenience
+func WithoutVectors(ctx context.Context) context.Context {
+	return types.WithoutVectors(ctx)
+}
+
 // IndexType represents the type of index
 type IndexType string
 
 const (
-	IndexTypeHNSW IndexType = "hnsw"
-	IndexTypeIVF  IndexType = "ivf"
-	IndexTypeFlat IndexType = "flat"
+	IndexTypeHNSW  IndexType = "hnsw"
+	IndexTypeIVF   IndexType = "ivf"
+	IndexTypeIVFPQ IndexType = "ivfpq" // IVF with PQ codes in the inverted lists
+	IndexTypeFlat  IndexType = "flat"
 )
 
 // NewIndex creates a new index based on the index type
@@ -73,6 +145,32 @@ func NewIndex(indexType IndexType, dimension int, config map[string]any, storage
 		}
 		// No existing IVF file, create new index
 		return ivf.NewIVFIndex(dimension, config, storage)
+	case IndexTypeIVFPQ:
+		// The IVF structure is shared with IVF; codes are kept in a ".ivfpq" file
+		if storage != nil {
+			ivfPath := storage.GetFilePath() + ".ivf"
+			if _, err := os.Stat(ivfPath); err == nil {
+				return ivf.OpenIVFPQIndex(storage)
+			}
+		}
+		return ivf.NewIVFPQIndex(dimension, config, storage)
+	default:
+		return nil, errors.New("unknown index type")
+	}
+}
+
+// NewEmptyIndex creates a new, empty index of the given type over storage,
+// ignoring any persisted graph/IVF file. Used to rebuild an index from storage.
+func NewEmptyIndex(indexType IndexType, dimension int, config map[string]any, storage *storage.Storage) (Index, error) {
+	switch indexType {
+	case IndexTypeHNSW:
+		return hnsw.NewHNSWIndex(dimension, config, storage)
+	case IndexTypeFlat:
+		return flat.NewFlatIndex(dimension, storage), nil
+	case IndexTypeIVF:
+		return ivf.NewIVFIndex(dimension, config, storage)
+	case IndexTypeIVFPQ:
+		return ivf.NewIVFPQIndex(dimension, config, storage)
 	default:
 		return nil, errors.New("unknown index type")
 	}
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -341,3 +342,97 @@ func TestNewIndex_HNSW_OpenHNSWIndexError(t *testing.T) {
 	}
 }
 
+func TestNewEmptyIndex_IgnoresSidecar(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+	defer os.Remove(tmpFile + ".graph")
+
+	// A corrupt graph file makes NewIndex fail but must not affect NewEmptyIndex
+	if err := os.WriteFile(tmpFile+".graph", []byte("garbage"), 0644); err != nil {
+		t.Fatalf("Failed to write graph file: %v", err)
+	}
+
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+	if err := store.WriteVector(7, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Failed to write vector: %v", err)
+	}
+
+	config := map[string]any{"M": 16, "EfConstruction": 200, "EfSearch": 50, "NClusters": 2, "NProbe": 2}
+	if _, err := NewIndex(IndexTypeHNSW, 4, config, store); err == nil {
+		t.Fatal("Expected NewIndex to fail on corrupt graph file")
+	}
+
+	for _, indexType := range []IndexType{IndexTypeHNSW, IndexTypeIVF, IndexTypeFlat} {
+		idx, err := NewEmptyIndex(indexType, 4, config, store)
+		if err != nil {
+			t.Fatalf("NewEmptyIndex(%s) failed: %v", indexType, err)
+		}
+		if idx.Size() != 0 {
+			t.Errorf("Expected empty %s index, got size %d", indexType, idx.Size())
+		}
+
+		indexer, ok := idx.(ExistingIndexer)
+		if !ok {
+			t.Fatalf("%s index does not implement ExistingIndexer", indexType)
+		}
+		if err := indexer.IndexExisting(7, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("IndexExisting(%s) failed: %v", indexType, err)
+		}
+		results, err := idx.Search([]float32{1, 2, 3, 4}, 1)
+		if err != nil || len(results) != 1 || results[0].ID != 7 {
+			t.Errorf("Expected %s to find indexed vector 7, got %v (%v)", indexType, results, err)
+		}
+	}
+
+	if _, err := NewEmptyIndex(IndexType("unknown"), 4, nil, store); err == nil {
+		t.Error("Expected error for unknown index type")
+	}
+}
+
+func TestSearch_WithoutVectors(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	store, err := storage.NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	config := map[string]any{"M": 16, "EfConstruction": 200, "EfSearch": 50, "NClusters": 2, "NProbe": 2, "PQSubspaces": 2}
+	for _, indexType := range []IndexType{IndexTypeFlat, IndexTypeHNSW, IndexTypeIVF, IndexTypeIVFPQ} {
+		idx, err := NewEmptyIndex(indexType, 4, config, store)
+		if err != nil {
+			t.Fatalf("NewEmptyIndex(%s) failed: %v", indexType, err)
+		}
+		for id := uint64(1); id <= 3; id++ {
+			if err := idx.Insert(id, []float32{float32(id), 0, 0, 0}); err != nil {
+				t.Fatalf("Insert(%s) failed: %v", indexType, err)
+			}
+		}
+
+		query := []float32{1, 0, 0, 0}
+		results, err := idx.SearchContext(WithoutVectors(context.Background()), query, 2)
+		if err != nil || len(results) != 2 || results[0].ID != 1 {
+			t.Fatalf("Expected %s to find vector 1 first, got %v (%v)", indexType, results, err)
+		}
+		for _, result := range results {
+			if result.Vector != nil {
+				t.Errorf("Expected %s to skip copying vector %d", indexType, result.ID)
+			}
+		}
+		if results, _ := idx.SearchContext(context.Background(), query, 1); len(results) != 1 || len(results[0].Vector) != 4 {
+			t.Errorf("Expected %s to return vectors by default, got %v", indexType, results)
+		}
+	}
+}
@@ -5,30 +5,29 @@ import (
 	"math"
 	"sort"
 
+	"github.com/monishSR/veclite/internal/idmap"
+	"github.com/monishSR/veclite/internal/profile"
 	"github.com/monishSR/veclite/internal/vector"
 )
 
 // Centroid represents a cluster center
-// Memory-efficient: only stores ID, vector stored in storage
+// Its vector is kept in memory and saved in the IVF file, so centroids take
+// no ID of the data file
 type Centroid struct {
-	ID       int    // Cluster ID
-	VectorID uint64 // ID of the vector in storage that represents this centroid
+	ID     int       // Cluster ID
+	Vector []float32 // Center of the cluster
 }
 
 // initializeFirstCentroid uses the first vector as the first centroid
 func (i *IVFIndex) initializeFirstCentroid(id uint64, vector []float32) error {
-	centroidID := i.allocateCentroidID(0)
-	if err := i.storage.WriteVector(centroidID, vector); err != nil {
-		return fmt.Errorf("failed to write first centroid: %w", err)
-	}
-
 	i.centroids = []Centroid{
-		{ID: 0, VectorID: centroidID},
+		{ID: 0, Vector: append([]float32(nil), vector...)},
 	}
-	i.clusters = make(map[int][]uint64)
-	i.clusters[0] = []uint64{id}
-	i.vectorToCluster = make(map[uint64]int)
-	i.vectorToCluster[id] = 0
+	i.slots, i.clusterOf = idmap.New(), nil
+	i.clusters = make(map[int][]uint32)
+	i.place(id, 0)
+	i.codes = make(map[int][]byte)
+	i.appendCode(0, vector)
 	i.size = 1
 	return nil
 }
@@ -36,18 +35,12 @@ func (i *IVFIndex) initializeFirstCentroid(id uint64, vector []float32) error {
 // addCentroidFromVector uses the vector as a new centroid
 func (i *IVFIndex) addCentroidFromVector(id uint64, vector []float32) error {
 	clusterID := len(i.centroids)
-	centroidID := i.allocateCentroidID(clusterID)
-
-	if err := i.storage.WriteVector(centroidID, vector); err != nil {
-		return fmt.Errorf("failed to write centroid: %w", err)
-	}
-
 	i.centroids = append(i.centroids, Centroid{
-		ID:       clusterID,
-		VectorID: centroidID,
+		ID:     clusterID,
+		Vector: append([]float32(nil), vector...),
 	})
-	i.clusters[clusterID] = []uint64{id}
-	i.vectorToCluster[id] = clusterID
+	i.place(id, clusterID)
+	i.appendCode(clusterID, vector)
 	i.size++
 	return nil
 }
@@ -61,13 +54,8 @@ func (i *IVFIndex) findNearestCentroid(vec []float32) int {
 	minDist := float32(math.MaxFloat32)
 	nearestClusterID := 0
 
-	// Load each centroid vector from storage and compute distance
-	for clusterID := range i.centroids {
-		centroidVec, err := i.getCentroidVector(clusterID)
-		if err != nil {
-			continue // Skip if can't load
-		}
-		dist := vector.L2Distance(vec, centroidVec)
+	for clusterID, centroid := range i.centroids {
+		dist := vector.L2Distance(vec, centroid.Vector)
 		if dist < minDist {
 			minDist = dist
 			nearestClusterID = clusterID
@@ -78,7 +66,8 @@ func (i *IVFIndex) findNearestCentroid(vec []float32) int {
 }
 
 // findNearestClusters finds the nProbe nearest centroids to the query
-func (i *IVFIndex) findNearestClusters(query []float32, nProbe int) []int {
+// The work is counted in p (may be nil)
+func (i *IVFIndex) findNearestClusters(query []float32, nProbe int, p *profile.Profile) []int {
 	if len(i.centroids) == 0 {
 		return nil
 	}
@@ -90,39 +79,37 @@ func (i *IVFIndex) findNearestClusters(query []float32, nProbe int) []int {
 	}
 	distances := make([]clusterDist, 0, len(i.centroids))
 
-	for clusterID := range i.centroids {
-		centroidVec, err := i.getCentroidVector(clusterID)
-		if err != nil {
-			continue // Skip if can't load
-		}
-		dist := vector.L2Distance(query, centroidVec)
+	for clusterID, centroid := range i.centroids {
+		dist := vector.L2Distance(query, centroid.Vector)
+		p.AddDistances(1)
 		distances = append(distances, clusterDist{
 			clusterID: clusterID,
 			distance:  dist,
 		})
 	}
 
-	// Sort by distance (best first)
+	// Sort by distance (best first), breaking ties by cluster ID
 	sort.Slice(distances, func(i, j int) bool {
-		return distances[i].distance < distances[j].distance
+		if distances[i].distance != distances[j].distance {
+			return distances[i].distance < distances[j].distance
+		}
+		return distances[i].clusterID < distances[j].clusterID
 	})
 
 	// Return top nProbe clusters
-	result := make([]int, 0, nProbe)
+	result := make([]int, 0, min(nProbe, len(distances)))
 	for j := 0; j < nProbe && j < len(distances); j++ {
 		result = append(result, distances[j].clusterID)
 	}
 	return result
 }
 
-// getCentroidVector loads the centroid vector from storage
+// getCentroidVector returns the centroid vector of the cluster
 func (i *IVFIndex) getCentroidVector(clusterID int) ([]float32, error) {
 	if clusterID < 0 || clusterID >= len(i.centroids) {
 		return nil, fmt.Errorf("invalid cluster ID: %d", clusterID)
 	}
-	centroid := i.centroids[clusterID]
-	// Load vector from storage (cache handles caching automatically)
-	return i.storage.ReadVector(centroid.VectorID)
+	return i.centroids[clusterID].Vector, nil
 }
 
 // updateCentroid updates the centroid incrementally using moving average
@@ -151,8 +138,7 @@ func (i *IVFIndex) updateCentroid(clusterID int, newVector []float32) {
 		newCentroid[j] = (currentVec[j]*float32(clusterSize-1) + newVector[j]) / float32(clusterSize)
 	}
 
-	// Update centroid vector in storage
-	i.storage.WriteVector(centroid.VectorID, newCentroid)
+	centroid.Vector = newCentroid
 }
 
 // recomputeCentroid recomputes the centroid from all vectors in the cluster
@@ -163,7 +149,7 @@ func (i *IVFIndex) recomputeCentroid(clusterID int) {
 	}
 
 	centroid := &i.centroids[clusterID]
-	clusterVectors := i.clusters[clusterID]
+	clusterVectors := i.vectorIDs(clusterID)
 
 	if len(clusterVectors) == 0 {
 		return
@@ -173,12 +159,6 @@ func (i *IVFIndex) recomputeCentroid(clusterID int) {
 	sum := make([]float32, i.dimension)
 	validCount := 0
 	for _, vecID := range clusterVectors {
-		// Skip centroid IDs
-		const centroidIDBase = ^uint64(0)
-		if vecID >= centroidIDBase-uint64(len(i.centroids)) {
-			continue
-		}
-
 		vec, err := i.storage.ReadVector(vecID)
 		if err != nil {
 			continue // Skip if can't load
@@ -200,14 +180,5 @@ func (i *IVFIndex) recomputeCentroid(clusterID int) {
 		newCentroid[j] = sum[j] / float32(validCount)
 	}
 
-	// Update centroid vector in storage
-	i.storage.WriteVector(centroid.VectorID, newCentroid)
-}
-
-// allocateCentroidID allocates a unique ID for a centroid
-// Uses high ID range to avoid conflicts with data vectors
-func (i *IVFIndex) allocateCentroidID(clusterID int) uint64 {
-	const centroidIDBase = ^uint64(0) // Max uint64
-	return centroidIDBase - uint64(clusterID)
+	centroid.Vector = newCentroid
 }
-
@@ -52,8 +52,8 @@ func TestIVFIndex_InitializeFirstCentroid(t *testing.T) {
 		t.Errorf("Expected cluster 0 to have 1 vector, got %d", len(index.clusters[0]))
 	}
 
-	if index.vectorToCluster[1] != 0 {
-		t.Errorf("Expected vector 1 to be in cluster 0, got %d", index.vectorToCluster[1])
+	if c, ok := index.clusterOfID(1); !ok || c != 0 {
+		t.Errorf("Expected vector 1 to be in cluster 0, got %d", c)
 	}
 }
 
@@ -112,8 +112,8 @@ func TestIVFIndex_AddCentroidFromVector(t *testing.T) {
 		t.Errorf("Expected cluster 1 to have 1 vector, got %d", len(index.clusters[1]))
 	}
 
-	if index.vectorToCluster[2] != 1 {
-		t.Errorf("Expected vector 2 to be in cluster 1, got %d", index.vectorToCluster[2])
+	if c, ok := index.clusterOfID(2); !ok || c != 1 {
+		t.Errorf("Expected vector 2 to be in cluster 1, got %d", c)
 	}
 }
 
@@ -247,7 +247,7 @@ func TestIVFIndex_FindNearestClusters(t *testing.T) {
 		query[j] = float32(j) + 10.0
 	}
 
-	nearest := index.findNearestClusters(query, 2)
+	nearest := index.findNearestClusters(query, 2, nil)
 	if len(nearest) != 2 {
 		t.Errorf("Expected 2 nearest clusters, got %d", len(nearest))
 	}
@@ -278,7 +278,7 @@ func TestIVFIndex_FindNearestClusters_Empty(t *testing.T) {
 	}
 
 	query := make([]float32, 128)
-	nearest := index.findNearestClusters(query, 2)
+	nearest := index.findNearestClusters(query, 2, nil)
 	if nearest != nil {
 		t.Errorf("Expected nil for empty centroids, got %v", nearest)
 	}
@@ -391,8 +391,7 @@ func TestIVFIndex_UpdateCentroid(t *testing.T) {
 	for i := range vector2 {
 		vector2[i] = float32(i) + 100.0
 	}
-	index.clusters[0] = append(index.clusters[0], 2)
-	index.vectorToCluster[2] = 0
+	index.place(2, 0)
 	if err := store.WriteVector(2, vector2); err != nil {
 		t.Fatalf("Failed to write vector: %v", err)
 	}
@@ -454,8 +453,7 @@ func TestIVFIndex_RecomputeCentroid(t *testing.T) {
 		for j := range vector {
 			vector[j] = float32(i) + float32(j)
 		}
-		index.clusters[0] = append(index.clusters[0], i)
-		index.vectorToCluster[i] = 0
+		index.place(i, 0)
 		index.size++ // Update size
 		if err := store.WriteVector(i, vector); err != nil {
 			t.Fatalf("Failed to write vector: %v", err)
@@ -499,44 +497,29 @@ func TestIVFIndex_RecomputeCentroid(t *testing.T) {
 	}
 }
 
-func TestIVFIndex_AllocateCentroidID(t *testing.T) {
-	tmpFile := createTempFile(t)
-	defer os.Remove(tmpFile)
-
-	store, err := storage.NewStorage(tmpFile, 128, 0)
-	if err != nil {
-		t.Fatalf("Failed to create storage: %v", err)
+func TestIVFIndex_CentroidsTakeNoStorageID(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	// The largest IDs are data IDs like any other
+	ids := []uint64{^uint64(0), ^uint64(0) - 1, 1, 2, 3}
+	for n, id := range ids {
+		vec := make([]float32, 128)
+		vec[0] = float32(n)
+		if err := index.Insert(id, vec); err != nil {
+			t.Fatalf("Insert %d failed: %v", id, err)
+		}
 	}
-	if err := store.Open(); err != nil {
-		t.Fatalf("Failed to open storage: %v", err)
+	if stored := index.storage.IDs(); len(stored) != len(ids) {
+		t.Errorf("Expected only the %d inserted vectors in storage, got %v", len(ids), stored)
 	}
-	defer store.Close()
 
-	config := make(map[string]any)
-	index, err := NewIVFIndex(128, config, store)
+	index.SetSearchParams(map[string]any{"NProbe": 10})
+	results, err := index.Search(make([]float32, 128), len(ids))
 	if err != nil {
-		t.Fatalf("Failed to create IVF index: %v", err)
-	}
-
-	// Allocate IDs for different clusters
-	id0 := index.allocateCentroidID(0)
-	id1 := index.allocateCentroidID(1)
-	id2 := index.allocateCentroidID(2)
-
-	// IDs should be in high range and unique
-	if id0 == id1 || id0 == id2 || id1 == id2 {
-		t.Error("Centroid IDs should be unique")
+		t.Fatalf("Search failed: %v", err)
 	}
-
-	// IDs should be very large (near max uint64)
-	const maxUint64 = ^uint64(0)
-	if id0 < maxUint64-100 {
-		t.Errorf("Centroid ID should be in high range, got %d", id0)
-	}
-
-	// IDs should decrease as cluster ID increases
-	if id0 <= id1 || id1 <= id2 {
-		t.Error("Centroid IDs should decrease as cluster ID increases")
+	if len(results) != len(ids) || results[0].ID != ^uint64(0) {
+		t.Errorf("Expected every vector with ID %d nearest, got %v", ^uint64(0), results)
 	}
 }
-
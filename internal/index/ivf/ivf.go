@@ -1,31 +1,59 @@
 package ivf
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
+	"github.com/monishSR/veclite/internal/idmap"
 	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/pq"
+	"github.com/monishSR/veclite/internal/profile"
 	"github.com/monishSR/veclite/internal/storage"
 	"github.com/monishSR/veclite/internal/vector"
 )
 
 // IVFIndex implements Inverted File index
-// Memory-efficient: only stores cluster structure, vectors in storage
+// Memory-efficient: only stores cluster structure and centroids, vectors in storage
 type IVFIndex struct {
 	dimension int
 	config    map[string]any
-	storage   *storage.Storage // Storage for all vectors (including centroids)
-
-	// IVF-specific structures (memory-efficient: only IDs)
-	centroids       []Centroid       // Cluster centroids (only IDs, vectors in storage)
-	clusters        map[int][]uint64 // clusterID -> vector IDs in this cluster
-	vectorToCluster map[uint64]int   // vectorID -> clusterID (for fast lookup)
-	size            int              // Total number of vectors
+	storage   *storage.Storage // Storage for the indexed vectors
+
+	// IVF-specific structures (memory-efficient: only IDs and centroids)
+	// Vectors are tracked by dense slots, so the lists hold 4 bytes per vector
+	// whatever ID scheme callers use
+	centroids []Centroid       // Cluster centroids, saved in the IVF file
+	slots     *idmap.Dict      // Vector ID -> slot
+	clusterOf []int32          // Slot -> clusterID (valid for assigned slots)
+	clusters  map[int][]uint32 // clusterID -> slots of the vectors in this cluster
+	size      int              // Total number of vectors
+
+	// Storage IDs of the centroid records of an IVF file of version 2 or
+	// earlier, which kept centroids in the data file; the next SaveIVF
+	// deletes them (see LegacyCentroidIDs)
+	legacyCentroids []uint64
 
 	// IVF parameters
 	nClusters int // Number of clusters (typically √N to N/10)
 	nProbe    int // Number of clusters to search during query (default: 1)
+
+	// Product quantization (IVF-PQ, see NewIVFPQIndex; 0 subspaces = plain IVF)
+	pqSubspaces int            // Code size in bytes
+	rerankK     int            // Candidates re-ranked exactly (0 = pqRerankFactor per result)
+	codebook    *pq.Codebook   // nil until trained
+	codes       map[int][]byte // clusterID -> codes of clusters[clusterID], in the same order
+	pqTrainedOn int            // Number of vectors the codebook was trained on
+
+	// Codebook training, run in the background (see maybeTrainPQ)
+	pqJob     *pqJob              // Running or finished training, swapped in by the next write or save (nil = none)
+	pqStale   map[uint64]struct{} // Vectors assigned since the snapshot of pqJob
+	pqAttempt int                 // Index size the last training started at
+	pqErr     error               // Error of the last training (nil = none or it succeeded)
+
+	created time.Time // Creation time of the IVF file, kept by rewrites (zero = not saved yet)
 }
 
 // NewIVFIndex creates a new IVF index
@@ -47,15 +75,15 @@ func NewIVFIndex(dimension int, config map[string]any, storage *storage.Storage)
 	}
 
 	return &IVFIndex{
-		dimension:       dimension,
-		config:          config,
-		storage:         storage,
-		centroids:       make([]Centroid, 0),
-		clusters:        make(map[int][]uint64),
-		vectorToCluster: make(map[uint64]int),
-		size:            0,
-		nClusters:       nClusters,
-		nProbe:          nProbe,
+		dimension: dimension,
+		config:    config,
+		storage:   storage,
+		centroids: make([]Centroid, 0),
+		slots:     idmap.New(),
+		clusters:  make(map[int][]uint32),
+		size:      0,
+		nClusters: nClusters,
+		nProbe:    nProbe,
 	}, nil
 }
 
@@ -82,7 +110,39 @@ func OpenIVFIndex(storage *storage.Storage) (*IVFIndex, error) {
 	return i, nil
 }
 
+// Params returns the parameters in effect, keyed like the config map
+func (i *IVFIndex) Params() map[string]int {
+	params := map[string]int{"NClusters": i.nClusters, "NProbe": i.nProbe}
+	if i.pqSubspaces > 0 {
+		params["PQSubspaces"] = i.pqSubspaces
+		params["RerankK"] = i.rerankK
+	}
+	return params
+}
+
+// SetSearchParams applies query-time parameters (NProbe and, for IVF-PQ,
+// PQSubspaces and RerankK) from config
+// The build parameter NClusters only takes effect on a rebuild
+func (i *IVFIndex) SetSearchParams(config map[string]any) {
+	if np, ok := config["NProbe"].(int); ok && np > 0 {
+		i.nProbe = np
+		if i.config != nil {
+			i.config["NProbe"] = np
+		}
+	}
+	if i.pqSubspaces <= 0 {
+		return
+	}
+	if rk, ok := config["RerankK"].(int); ok && rk >= 0 {
+		i.rerankK = rk
+	}
+	if m, ok := config["PQSubspaces"].(int); ok && m > 0 {
+		i.setPQSubspaces(m)
+	}
+}
+
 // Insert adds a vector to the IVF index
+// An existing ID is moved out of its cluster and reassigned with the new vector
 func (i *IVFIndex) Insert(id uint64, vector []float32) error {
 	if len(vector) != i.dimension {
 		return types.ErrDimensionMismatch
@@ -97,6 +157,69 @@ func (i *IVFIndex) Insert(id uint64, vector []float32) error {
 		return fmt.Errorf("failed to write vector to storage: %w", err)
 	}
 
+	if clusterID, exists := i.clusterOfID(id); exists {
+		i.unassign(id, clusterID)
+	}
+	if err := i.assign(id, vector); err != nil {
+		return err
+	}
+	i.maybeTrainPQ()
+	return nil
+}
+
+// InsertBatch adds vectors[i] under ids[i] like repeated Inserts, writing
+// them to storage in one append before assigning them to clusters
+func (i *IVFIndex) InsertBatch(ids []uint64, vectors [][]float32) error {
+	for _, vector := range vectors {
+		if len(vector) != i.dimension {
+			return types.ErrDimensionMismatch
+		}
+	}
+	if i.storage == nil {
+		return errors.New("storage not available")
+	}
+
+	if err := i.storage.WriteVectors(ids, vectors); err != nil {
+		return fmt.Errorf("failed to write vectors to storage: %w", err)
+	}
+	for n, id := range ids {
+		if clusterID, exists := i.clusterOfID(id); exists {
+			i.unassign(id, clusterID) // Updated vector, reassigned as in Insert
+		}
+		if err := i.assign(id, vectors[n]); err != nil {
+			return err
+		}
+	}
+	i.maybeTrainPQ()
+	return nil
+}
+
+// IndexExisting assigns a vector that is already persisted in storage to a cluster
+// Used to rebuild the IVF structure from storage without rewriting any records
+func (i *IVFIndex) IndexExisting(id uint64, vector []float32) error {
+	if len(vector) != i.dimension {
+		return types.ErrDimensionMismatch
+	}
+	if i.storage == nil {
+		return errors.New("storage not available")
+	}
+	if _, exists := i.slots.Lookup(id); exists {
+		return nil
+	}
+	if err := i.assign(id, vector); err != nil {
+		return err
+	}
+	i.maybeTrainPQ()
+	return nil
+}
+
+// assign places a stored vector into a cluster, creating centroids while
+// fewer than nClusters exist
+func (i *IVFIndex) assign(id uint64, vector []float32) error {
+	if i.pqJob != nil {
+		i.pqStale[id] = struct{}{} // Encoded when the training is swapped in
+	}
+
 	// Handle initialization phase: no centroids exist yet
 	if len(i.centroids) == 0 {
 		return i.initializeFirstCentroid(id, vector)
@@ -109,20 +232,75 @@ func (i *IVFIndex) Insert(id uint64, vector []float32) error {
 
 	// Normal insertion: centroids exist, find nearest and assign
 	clusterID := i.findNearestCentroid(vector)
-	i.clusters[clusterID] = append(i.clusters[clusterID], id)
-	i.vectorToCluster[id] = clusterID
+	i.place(id, clusterID)
+	i.appendCode(clusterID, vector)
 	i.updateCentroid(clusterID, vector)
 	i.size++
 	return nil
 }
 
+// place appends vector id to cluster clusterID, assigning it a slot
+func (i *IVFIndex) place(id uint64, clusterID int) {
+	slot := i.slots.Assign(id)
+	if n := int(slot) + 1; n > len(i.clusterOf) {
+		i.clusterOf = append(i.clusterOf, make([]int32, n-len(i.clusterOf))...)
+	}
+	i.clusterOf[slot] = int32(clusterID)
+	i.clusters[clusterID] = append(i.clusters[clusterID], slot)
+}
+
+// clusterOfID returns the cluster vector id is assigned to
+func (i *IVFIndex) clusterOfID(id uint64) (int, bool) {
+	slot, ok := i.slots.Lookup(id)
+	if !ok {
+		return 0, false
+	}
+	return int(i.clusterOf[slot]), true
+}
+
+// vectorID returns the ID of the vector in slot
+func (i *IVFIndex) vectorID(slot uint32) uint64 {
+	id, _ := i.slots.External(slot)
+	return id
+}
+
+// vectorIDs returns the IDs of the vectors in cluster clusterID, in list order
+func (i *IVFIndex) vectorIDs(clusterID int) []uint64 {
+	members := i.clusters[clusterID]
+	ids := make([]uint64, len(members))
+	for j, slot := range members {
+		ids[j] = i.vectorID(slot)
+	}
+	return ids
+}
+
+// indexedIDs returns the IDs of all indexed vectors in ascending order
+func (i *IVFIndex) indexedIDs() []uint64 {
+	ids := make([]uint64, 0, i.slots.Len())
+	for slot := 0; slot < i.slots.Cap(); slot++ {
+		if id, ok := i.slots.External(uint32(slot)); ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	return ids
+}
+
 // Search finds the k nearest neighbors using IVF
 // Algorithm:
 // 1. Find nProbe nearest centroids to the query
 // 2. Search vectors in those selected clusters
 // 3. Compute distances to all vectors in those clusters
 // 4. Sort and return top k results
+// IVF-PQ computes the distances of step 3 from PQ codes and re-ranks the
+// nearest RerankK candidates with their stored vectors
 func (i *IVFIndex) Search(query []float32, k int) ([]types.SearchResult, error) {
+	return i.SearchContext(context.Background(), query, k)
+}
+
+// SearchContext is Search with cancellation: probing stops and ctx.Err() is
+// returned once ctx is done
+func (i *IVFIndex) SearchContext(ctx context.Context, query []float32, k int) ([]types.SearchResult, error) {
 	if len(query) != i.dimension {
 		return nil, types.ErrDimensionMismatch
 	}
@@ -131,6 +309,47 @@ func (i *IVFIndex) Search(query []float32, k int) ([]types.SearchResult, error)
 		return nil, types.ErrInvalidK
 	}
 
+	withVectors := types.WantVectors(ctx)
+	candidates, err := i.scanClusters(ctx, query, withVectors && i.codebook == nil)
+	if err != nil {
+		return nil, err
+	}
+	if i.codebook != nil {
+		candidates = i.rerank(query, candidates[:min(len(candidates), i.rerankCount(k))], withVectors, profile.FromContext(ctx))
+	}
+
+	// Return top k
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+
+	return candidates[:k], nil
+}
+
+// Candidates returns the n nearest vectors in the nProbe nearest clusters with
+// their exact distances (IVF-PQ: approximate distances from their codes),
+// nearest first, without copying their vectors (n <= 0 = every vector in
+// those clusters)
+func (i *IVFIndex) Candidates(ctx context.Context, query []float32, n int) ([]types.SearchResult, error) {
+	if len(query) != i.dimension {
+		return nil, types.ErrDimensionMismatch
+	}
+	candidates, err := i.scanClusters(ctx, query, false)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && n < len(candidates) {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+// scanClusters computes the distance from query to every vector in the nProbe
+// nearest clusters and returns them sorted by distance, with a copy of each
+// vector if withVectors is set
+// With a PQ codebook the distances are approximated from the codes and no
+// vector is read or copied
+func (i *IVFIndex) scanClusters(ctx context.Context, query []float32, withVectors bool) ([]types.SearchResult, error) {
 	if i.storage == nil {
 		return nil, errors.New("storage not available")
 	}
@@ -140,56 +359,70 @@ func (i *IVFIndex) Search(query []float32, k int) ([]types.SearchResult, error)
 		return []types.SearchResult{}, nil
 	}
 
+	p := profile.FromContext(ctx)
+
 	// Find nProbe nearest clusters
-	nearestClusters := i.findNearestClusters(query, i.nProbe)
+	endStage := p.Start("ivf.probe")
+	nearestClusters := i.findNearestClusters(query, i.nProbe, p)
+	endStage()
 	if len(nearestClusters) == 0 {
 		return []types.SearchResult{}, nil
 	}
 
 	// Search vectors in selected clusters
+	endStage = p.Start("ivf.scan")
 	candidates := make([]types.SearchResult, 0)
+	var table *pq.Table
+	if i.codebook != nil {
+		table = i.codebook.Table(query)
+	}
 
 	for _, clusterID := range nearestClusters {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		// Get all vector IDs in this cluster
 		clusterVectors := i.clusters[clusterID]
-		for _, vecID := range clusterVectors {
-			// Skip centroid IDs (they're in high ID range)
-			// Centroids are stored with IDs from allocateCentroidID
-			const centroidIDBase = ^uint64(0)
-			if vecID >= centroidIDBase-uint64(len(i.centroids)) {
-				continue // Skip centroid vectors
+		for j, slot := range clusterVectors {
+			vecID := i.vectorID(slot)
+			if table != nil {
+				p.AddDistances(1)
+				p.AddCandidates(1)
+				candidates = append(candidates, types.SearchResult{ID: vecID, Distance: table.Distance(i.code(clusterID, j))})
+				continue
 			}
 
 			// Load vector from storage (cache handles caching automatically)
-			vec, err := i.storage.ReadVector(vecID)
+			vec, err := i.storage.ReadVectorProfiled(vecID, p)
 			if err != nil {
 				// Log error but continue if a single vector read fails
 				continue
 			}
 
 			dist := vector.L2Distance(query, vec)
-			// Copy vector to avoid external modifications
-			vecCopy := make([]float32, len(vec))
-			copy(vecCopy, vec)
-			candidates = append(candidates, types.SearchResult{
-				ID:       vecID,
-				Distance: dist,
-				Vector:   vecCopy,
-			})
+			p.AddDistances(1)
+			p.AddCandidates(1)
+			candidate := types.SearchResult{ID: vecID, Distance: dist}
+			if withVectors {
+				// Copy vector to avoid external modifications
+				candidate.Vector = make([]float32, len(vec))
+				copy(candidate.Vector, vec)
+			}
+			candidates = append(candidates, candidate)
 		}
 	}
 
-	// Sort by distance (best first)
+	endStage()
+
+	// Sort by distance (best first), breaking ties by ID for a stable order
+	defer p.Start("ivf.sort")()
 	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].Distance < candidates[j].Distance
+		if candidates[i].Distance != candidates[j].Distance {
+			return candidates[i].Distance < candidates[j].Distance
+		}
+		return candidates[i].ID < candidates[j].ID
 	})
-
-	// Return top k
-	if k > len(candidates) {
-		k = len(candidates)
-	}
-
-	return candidates[:k], nil
+	return candidates, nil
 }
 
 // ReadVector retrieves a vector by ID from storage
@@ -198,25 +431,24 @@ func (i *IVFIndex) ReadVector(id uint64) ([]float32, error) {
 		return nil, errors.New("storage not available")
 	}
 	// Check if vector exists in index (fast map lookup)
-	if _, exists := i.vectorToCluster[id]; !exists {
-		return nil, fmt.Errorf("vector with ID %d not found in index", id)
+	if _, exists := i.slots.Lookup(id); !exists {
+		return nil, fmt.Errorf("vector with ID %d %w in index", id, types.ErrNotFound)
 	}
 	// Storage handles caching automatically
 	return i.storage.ReadVector(id)
 }
 
 // Delete removes a vector from the IVF index
-// 1. Removes vector from cluster assignment
+// 1. Removes vector from its cluster and releases its slot
 // 2. Updates centroid (recomputes without deleted vector)
 // 3. Deletes vector from storage
-// 4. Removes from vectorToCluster map
 func (i *IVFIndex) Delete(id uint64) error {
 	if i.storage == nil {
 		return errors.New("storage not available")
 	}
 
 	// Check if vector exists in index
-	clusterID, exists := i.vectorToCluster[id]
+	clusterID, exists := i.clusterOfID(id)
 	if !exists {
 		// Vector doesn't exist in index, but try to delete from storage anyway
 		// (in case storage has it but index doesn't)
@@ -224,34 +456,40 @@ func (i *IVFIndex) Delete(id uint64) error {
 		return nil
 	}
 
-	// Step 1: Remove vector from cluster
+	// Step 1: Remove vector from its cluster and update the centroid
+	i.unassign(id, clusterID)
+
+	// Step 2: Delete vector from storage
+	if err := i.storage.DeleteVector(id); err != nil {
+		return fmt.Errorf("failed to delete vector from storage: %w", err)
+	}
+
+	return nil
+}
+
+// unassign removes a vector from its cluster and recomputes the centroid
+// without it, leaving the vector in storage
+func (i *IVFIndex) unassign(id uint64, clusterID int) {
+	slot, _ := i.slots.Lookup(id)
 	cluster := i.clusters[clusterID]
-	for j, vecID := range cluster {
-		if vecID == id {
+	for j, member := range cluster {
+		if member == slot {
 			// Remove from cluster (swap with last element and truncate)
 			lastIdx := len(cluster) - 1
 			cluster[j] = cluster[lastIdx]
 			i.clusters[clusterID] = cluster[:lastIdx]
+			i.removeCode(clusterID, j)
 			break
 		}
 	}
 
-	// Step 2: Update centroid (recompute without deleted vector)
-	// Load all remaining vectors in cluster and recompute centroid
+	// Recompute centroid without the removed vector
 	if len(i.clusters[clusterID]) > 0 {
 		i.recomputeCentroid(clusterID)
 	}
 
-	// Step 3: Delete vector from storage
-	if err := i.storage.DeleteVector(id); err != nil {
-		return fmt.Errorf("failed to delete vector from storage: %w", err)
-	}
-
-	// Step 4: Remove from vectorToCluster map
-	delete(i.vectorToCluster, id)
+	i.slots.Release(id)
 	i.size--
-
-	return nil
 }
 
 // Size returns the number of vectors in the index
@@ -273,9 +511,41 @@ func (i *IVFIndex) Clear() error {
 
 	// Clear all IVF structures
 	i.centroids = make([]Centroid, 0)
-	i.clusters = make(map[int][]uint64)
-	i.vectorToCluster = make(map[uint64]int)
+	i.slots, i.clusterOf = idmap.New(), nil
+	i.clusters = make(map[int][]uint32)
+	i.legacyCentroids = nil // Cleared with storage
 	i.size = 0
+	i.codebook, i.codes, i.pqTrainedOn = nil, nil, 0
+	i.pqJob, i.pqStale, i.pqErr = nil, nil, nil
 
 	return nil
 }
+
+// LegacyCentroidIDs returns the storage IDs of the centroid records left in
+// the data file by an IVF file of version 2 or earlier; they are not data and
+// are deleted by the next SaveIVF
+func (i *IVFIndex) LegacyCentroidIDs() []uint64 {
+	return i.legacyCentroids
+}
+
+// Histogram returns the number of vectors in each cluster, in centroid order
+func (i *IVFIndex) Histogram() (string, []int) {
+	counts := make([]int, len(i.centroids))
+	for c, centroid := range i.centroids {
+		counts[c] = len(i.clusters[centroid.ID])
+	}
+	return "cluster", counts
+}
+
+// LocalityOrder returns vector IDs grouped by cluster (in centroid order, the
+// members of each in ascending ID order) so a cluster probe reads a contiguous
+// region when storage is compacted in this order
+func (i *IVFIndex) LocalityOrder() []uint64 {
+	order := make([]uint64, 0, i.slots.Len())
+	for _, centroid := range i.centroids {
+		members := i.vectorIDs(centroid.ID)
+		sort.Slice(members, func(a, b int) bool { return members[a] < members[b] })
+		order = append(order, members...)
+	}
+	return order
+}
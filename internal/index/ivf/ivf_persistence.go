@@ -6,9 +6,31 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+
+	"github.com/monishSR/veclite/internal/fileinfo"
+	"github.com/monishSR/veclite/internal/idmap"
+)
+
+// IVF file versions: version 2 adds a file info block (see fileinfo) after
+// the header, version 3 stores the centroid vectors instead of the IDs of
+// centroid records in the data file
+const (
+	ivfVersion          = 1
+	ivfVersionInfo      = 2
+	ivfVersionCentroids = 3
 )
 
-// writeIVFHeader writes the IVF file header (magic, version, metadata)
+// Limits on the values read from IVF files, so a corrupted or hostile file
+// fails to load instead of driving huge allocations
+const (
+	ivfHeaderSize     = 24      // Magic through size
+	ivfCentroidSize   = 12      // Cluster ID and centroid vector ID (versions 1 and 2; version 3: the vector)
+	ivfAssignmentSize = 12      // Vector ID and cluster ID
+	maxIVFClusters    = 1 << 24 // Also bounds NProbe
+)
+
+// writeIVFHeader writes the IVF file header (magic, version, metadata, info block)
 func (i *IVFIndex) writeIVFHeader(w io.Writer) error {
 	// Write magic number for validation
 	magic := uint32(0x49564620) // "IVF " in ASCII
@@ -17,7 +39,7 @@ func (i *IVFIndex) writeIVFHeader(w io.Writer) error {
 	}
 
 	// Write version (for future compatibility)
-	version := uint32(1)
+	version := uint32(ivfVersionCentroids)
 	if err := binary.Write(w, binary.LittleEndian, version); err != nil {
 		return fmt.Errorf("failed to write version: %w", err)
 	}
@@ -38,7 +60,30 @@ func (i *IVFIndex) writeIVFHeader(w io.Writer) error {
 		return fmt.Errorf("failed to write size: %w", err)
 	}
 
-	return nil
+	// Info block describing the file
+	_, err := fileinfo.Write(w, i.ivfInfo())
+	return err
+}
+
+// ivfInfo returns the info block of the IVF file, with the creation time of
+// its first save so that saving the same clusters writes the same bytes
+func (i *IVFIndex) ivfInfo() *fileinfo.Info {
+	index := "ivf"
+	if i.pqSubspaces > 0 {
+		index = "ivfpq"
+	}
+	info := fileinfo.New(
+		fileinfo.Param{Name: "index", Value: index},
+		fileinfo.Param{Name: "dimension", Value: strconv.Itoa(i.dimension)},
+		fileinfo.Param{Name: "n_clusters", Value: strconv.Itoa(i.nClusters)},
+		fileinfo.Param{Name: "n_probe", Value: strconv.Itoa(i.nProbe)},
+		fileinfo.Param{Name: "pq_subspaces", Value: strconv.Itoa(i.pqSubspaces)},
+	)
+	if i.created.IsZero() {
+		i.created = info.Created
+	}
+	info.Created = i.created
+	return info
 }
 
 // writeCentroids writes all centroids to the writer
@@ -48,9 +93,9 @@ func (i *IVFIndex) writeCentroids(w io.Writer) error {
 		if err := binary.Write(w, binary.LittleEndian, int32(centroid.ID)); err != nil {
 			return fmt.Errorf("failed to write centroid ID %d: %w", centroid.ID, err)
 		}
-		// Write centroid vector ID
-		if err := binary.Write(w, binary.LittleEndian, centroid.VectorID); err != nil {
-			return fmt.Errorf("failed to write centroid vector ID for cluster %d: %w", centroid.ID, err)
+		// Write centroid vector
+		if err := binary.Write(w, binary.LittleEndian, centroid.Vector); err != nil {
+			return fmt.Errorf("failed to write centroid vector for cluster %d: %w", centroid.ID, err)
 		}
 	}
 	return nil
@@ -59,12 +104,14 @@ func (i *IVFIndex) writeCentroids(w io.Writer) error {
 // writeClusterAssignments writes all cluster assignments (vectorID -> clusterID)
 func (i *IVFIndex) writeClusterAssignments(w io.Writer) error {
 	// Write number of assignments
-	if err := binary.Write(w, binary.LittleEndian, uint32(len(i.vectorToCluster))); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, uint32(i.slots.Len())); err != nil {
 		return fmt.Errorf("failed to write assignment count: %w", err)
 	}
 
-	// Write each assignment
-	for vecID, clusterID := range i.vectorToCluster {
+	// Write each assignment in vector ID order, so the same assignments always
+	// produce the same file; slots are not saved
+	for _, vecID := range i.indexedIDs() {
+		clusterID, _ := i.clusterOfID(vecID)
 		if err := binary.Write(w, binary.LittleEndian, vecID); err != nil {
 			return fmt.Errorf("failed to write vector ID %d: %w", vecID, err)
 		}
@@ -77,6 +124,7 @@ func (i *IVFIndex) writeClusterAssignments(w io.Writer) error {
 
 // SaveIVF saves the IVF structure to disk
 // IVF file path is automatically derived from storage file path by appending ".ivf"
+// An IVF-PQ codebook is saved next to it (".ivfpq")
 func (i *IVFIndex) SaveIVF() error {
 	if i.storage == nil {
 		return errors.New("storage is required to save IVF")
@@ -107,6 +155,28 @@ func (i *IVFIndex) SaveIVF() error {
 		return err
 	}
 
+	// Centroid records of an older IVF file are dropped once the centroids
+	// are saved in this one
+	if len(i.legacyCentroids) > 0 {
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync IVF file: %w", err)
+		}
+		for _, id := range i.legacyCentroids {
+			if !i.storage.Has(id) {
+				continue
+			}
+			if err := i.storage.DeleteVector(id); err != nil {
+				return fmt.Errorf("failed to delete old centroid record %d: %w", id, err)
+			}
+		}
+		i.legacyCentroids = nil
+	}
+
+	// IVF-PQ codes go to their own sidecar, with a finished training swapped in
+	if i.pqSubspaces > 0 {
+		i.collectPQ(false)
+		return i.savePQ()
+	}
 	return nil
 }
 
@@ -132,6 +202,10 @@ func (i *IVFIndex) LoadIVF() error {
 		return fmt.Errorf("failed to open IVF file: %w", err)
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat IVF file: %w", err)
+	}
 
 	// Read and validate magic number
 	var magic uint32
@@ -147,7 +221,7 @@ func (i *IVFIndex) LoadIVF() error {
 	if err := binary.Read(file, binary.LittleEndian, &version); err != nil {
 		return fmt.Errorf("failed to read version: %w", err)
 	}
-	if version != 1 {
+	if version != ivfVersion && version != ivfVersionInfo && version != ivfVersionCentroids {
 		return fmt.Errorf("unsupported IVF file version: %d", version)
 	}
 
@@ -160,6 +234,9 @@ func (i *IVFIndex) LoadIVF() error {
 	if err := binary.Read(file, binary.LittleEndian, &nProbe); err != nil {
 		return fmt.Errorf("failed to read nProbe: %w", err)
 	}
+	if nClusters > maxIVFClusters || nProbe > maxIVFClusters {
+		return fmt.Errorf("invalid IVF file: %d clusters, %d probes", nClusters, nProbe)
+	}
 
 	// Set configuration parameters from IVF file
 	i.nClusters = int(nClusters)
@@ -181,23 +258,56 @@ func (i *IVFIndex) LoadIVF() error {
 		return fmt.Errorf("failed to read size: %w", err)
 	}
 
+	remaining := info.Size() - ivfHeaderSize
+	if version >= ivfVersionInfo {
+		fileInfo, n, err := fileinfo.Read(file)
+		if err != nil {
+			return fmt.Errorf("invalid IVF file: %w", err)
+		}
+		remaining -= n
+		i.created = fileInfo.Created
+	}
+	centroidSize := int64(ivfCentroidSize)
+	if version >= ivfVersionCentroids {
+		centroidSize = 4 + 4*int64(i.dimension)
+	}
+	if centroidCount > nClusters || int64(centroidCount)*centroidSize > remaining {
+		return fmt.Errorf("invalid IVF file: %d centroids claimed for %d clusters in %d bytes", centroidCount, nClusters, info.Size())
+	}
+	remaining -= int64(centroidCount) * centroidSize
+
 	i.size = int(size)
 	i.centroids = make([]Centroid, 0, centroidCount)
+	i.legacyCentroids = nil
 
-	// Read centroids
+	// Read centroids; older versions point at centroid records in the data
+	// file, which are read from there and dropped by the next save
 	for j := uint32(0); j < centroidCount; j++ {
 		var clusterID int32
-		var vectorID uint64
 		if err := binary.Read(file, binary.LittleEndian, &clusterID); err != nil {
 			return fmt.Errorf("failed to read centroid ID: %w", err)
 		}
-		if err := binary.Read(file, binary.LittleEndian, &vectorID); err != nil {
-			return fmt.Errorf("failed to read centroid vector ID: %w", err)
+		if clusterID != int32(j) {
+			return fmt.Errorf("invalid IVF file: centroid %d has cluster ID %d", j, clusterID)
+		}
+		centroid := Centroid{ID: int(clusterID), Vector: make([]float32, i.dimension)}
+		if version >= ivfVersionCentroids {
+			if err := binary.Read(file, binary.LittleEndian, centroid.Vector); err != nil {
+				return fmt.Errorf("failed to read centroid vector: %w", err)
+			}
+		} else {
+			var vectorID uint64
+			if err := binary.Read(file, binary.LittleEndian, &vectorID); err != nil {
+				return fmt.Errorf("failed to read centroid vector ID: %w", err)
+			}
+			vec, err := i.storage.ReadVector(vectorID)
+			if err != nil {
+				return fmt.Errorf("failed to read centroid %d from storage: %w", j, err)
+			}
+			centroid.Vector = vec
+			i.legacyCentroids = append(i.legacyCentroids, vectorID)
 		}
-		i.centroids = append(i.centroids, Centroid{
-			ID:       int(clusterID),
-			VectorID: vectorID,
-		})
+		i.centroids = append(i.centroids, centroid)
 	}
 
 	// Read cluster assignments
@@ -205,9 +315,12 @@ func (i *IVFIndex) LoadIVF() error {
 	if err := binary.Read(file, binary.LittleEndian, &assignmentCount); err != nil {
 		return fmt.Errorf("failed to read assignment count: %w", err)
 	}
+	if int64(assignmentCount)*ivfAssignmentSize > remaining-4 {
+		return fmt.Errorf("invalid IVF file: %d assignments claimed in %d bytes", assignmentCount, info.Size())
+	}
 
-	i.vectorToCluster = make(map[uint64]int, assignmentCount)
-	i.clusters = make(map[int][]uint64)
+	i.slots, i.clusterOf = idmap.New(), make([]int32, 0, assignmentCount)
+	i.clusters = make(map[int][]uint32)
 
 	// Read each assignment and rebuild clusters map
 	for j := uint32(0); j < assignmentCount; j++ {
@@ -223,11 +336,60 @@ func (i *IVFIndex) LoadIVF() error {
 			return fmt.Errorf("failed to read cluster ID: %w", err)
 		}
 
-		clusterIDInt := int(clusterID)
-		i.vectorToCluster[vecID] = clusterIDInt
+		if clusterID < 0 || clusterID >= int32(centroidCount) {
+			return fmt.Errorf("invalid IVF file: vector %d assigned to cluster %d of %d", vecID, clusterID, centroidCount)
+		}
+		if _, exists := i.slots.Lookup(vecID); exists {
+			return fmt.Errorf("invalid IVF file: vector %d assigned twice", vecID)
+		}
 		// Rebuild clusters map
-		i.clusters[clusterIDInt] = append(i.clusters[clusterIDInt], vecID)
+		i.place(vecID, int(clusterID))
 	}
 
+	// IVF-PQ codes follow the order of the clusters just loaded
+	i.openPQ()
 	return nil
 }
+
+// IVFHeaderFields documents the fixed fields of the IVF file header
+var IVFHeaderFields = []fileinfo.Field{
+	{Name: "magic", Doc: "uint32 0x49564620 (\"IVF \")"},
+	{Name: "version", Doc: "uint32: 1, 2 with an info block, 3 with centroid vectors"},
+	{Name: "n_clusters", Doc: "uint32 configured number of clusters"},
+	{Name: "n_probe", Doc: "uint32 default clusters probed per search"},
+	{Name: "centroid_count", Doc: "uint32 centroids following the header"},
+	{Name: "size", Doc: "uint32 vectors indexed"},
+}
+
+// ReadIVFHeader reads the header of the IVF file at path, without the
+// centroids and assignments
+func ReadIVFHeader(path string) (*fileinfo.Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open IVF file: %w", err)
+	}
+	defer file.Close()
+
+	var buf [ivfHeaderSize]byte
+	if _, err := io.ReadFull(file, buf[:]); err != nil {
+		return nil, fmt.Errorf("failed to read IVF header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(buf[0:]) != 0x49564620 { // "IVF "
+		return nil, errors.New("invalid IVF file: magic number mismatch")
+	}
+	u32 := func(offset int) string {
+		return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf[offset:])), 10)
+	}
+	version := binary.LittleEndian.Uint32(buf[4:])
+	header := &fileinfo.Header{
+		Format:  "ivf",
+		Version: int(version),
+		Fields:  fileinfo.Fields(IVFHeaderFields, "0x49564620", u32(4), u32(8), u32(12), u32(16), u32(20)),
+	}
+	if version >= ivfVersionInfo {
+		if header.Info, _, err = fileinfo.Read(file); err != nil {
+			return nil, fmt.Errorf("invalid IVF file: %w", err)
+		}
+	}
+	return header, nil
+}
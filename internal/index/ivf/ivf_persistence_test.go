@@ -1,6 +1,7 @@
 package ivf
 
 import (
+	"bytes"
 	"encoding/binary"
 	"os"
 	"testing"
@@ -284,8 +285,8 @@ func TestIVFIndex_LoadIVF_VersionMismatch(t *testing.T) {
 		t.Fatalf("Failed to write magic: %v", err)
 	}
 
-	// Write wrong version (2 instead of 1)
-	version := uint32(2)
+	// Write an unsupported version
+	version := uint32(3)
 	if err := binary.Write(file, binary.LittleEndian, version); err != nil {
 		file.Close()
 		t.Fatalf("Failed to write version: %v", err)
@@ -629,3 +630,172 @@ func TestIVFIndex_SaveIVF_LoadIVF_MultipleClusters(t *testing.T) {
 	}
 }
 
+// hostileIVF returns an IVF file with the given header counts, centroids
+// (cluster ID, vector ID) and assignments (vector ID, cluster ID)
+func hostileIVF(nClusters, centroidCount uint32, centroids []int32, assignmentCount uint32, assignments []int32) []byte {
+	var buf bytes.Buffer
+	for _, v := range []any{uint32(0x49564620), uint32(1), nClusters, uint32(1), centroidCount, uint32(len(assignments))} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	for n, clusterID := range centroids {
+		binary.Write(&buf, binary.LittleEndian, clusterID)
+		binary.Write(&buf, binary.LittleEndian, uint64(n+1))
+	}
+	binary.Write(&buf, binary.LittleEndian, assignmentCount)
+	for n, clusterID := range assignments {
+		binary.Write(&buf, binary.LittleEndian, uint64(n+100))
+		binary.Write(&buf, binary.LittleEndian, clusterID)
+	}
+	return buf.Bytes()
+}
+
+func TestIVFIndex_LoadIVF_HostileCounts(t *testing.T) {
+	tmpFile := createTempFile(t)
+	ivfFile := tmpFile + ".ivf"
+	defer os.Remove(tmpFile)
+	defer os.Remove(ivfFile)
+
+	store, err := storage.NewStorage(tmpFile, 128, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+	index, err := NewIVFIndex(128, make(map[string]any), store)
+	if err != nil {
+		t.Fatalf("Failed to create IVF index: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"clusters":           hostileIVF(0xFFFFFFFF, 1, []int32{0}, 0, nil),
+		"centroid count":     hostileIVF(2, 0xFFFFFF, []int32{0}, 0, nil),
+		"centroids":          hostileIVF(2, 3, []int32{0, 1, 2}, 0, nil),
+		"centroid order":     hostileIVF(2, 2, []int32{1, 0}, 0, nil),
+		"assignment count":   hostileIVF(2, 2, []int32{0, 1}, 0xFFFFFFFF, []int32{0}),
+		"assigned cluster":   hostileIVF(2, 2, []int32{0, 1}, 1, []int32{7}),
+		"negative cluster":   hostileIVF(2, 2, []int32{0, 1}, 1, []int32{-1}),
+		"truncated centroid": hostileIVF(2, 2, []int32{0}, 0, nil)[:40],
+	}
+	for name, data := range cases {
+		if err := os.WriteFile(ivfFile, data, 0644); err != nil {
+			t.Fatalf("Failed to write IVF file: %v", err)
+		}
+		if err := index.LoadIVF(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// The same layout with sane values loads, reading the centroids from storage
+	for id := uint64(1); id <= 2; id++ {
+		if err := store.WriteVector(id, make([]float32, 128)); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(ivfFile, hostileIVF(2, 2, []int32{0, 1}, 2, []int32{0, 1}), 0644); err != nil {
+		t.Fatalf("Failed to write IVF file: %v", err)
+	}
+	if err := index.LoadIVF(); err != nil {
+		t.Fatalf("Expected the sane IVF file to load: %v", err)
+	}
+	if index.slots.Len() != 2 {
+		t.Fatalf("Expected 2 assignments, got %d", index.slots.Len())
+	}
+}
+
+func TestIVFIndex_LoadIVF_LegacyCentroids(t *testing.T) {
+	tmpFile := createTempFile(t)
+	ivfFile := tmpFile + ".ivf"
+	defer os.Remove(tmpFile)
+	defer os.Remove(ivfFile)
+
+	store, err := storage.NewStorage(tmpFile, 128, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	// A version 1 file pointing at centroid records 1 and 2 of the data file,
+	// with vectors 100 and 101 assigned to them
+	for id := uint64(1); id <= 2; id++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(id)
+		if err := store.WriteVector(id, vec); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+		if err := store.WriteVector(id+99, vec); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(ivfFile, hostileIVF(2, 2, []int32{0, 1}, 2, []int32{0, 1}), 0644); err != nil {
+		t.Fatalf("Failed to write IVF file: %v", err)
+	}
+	index, err := OpenIVFIndex(store)
+	if err != nil {
+		t.Fatalf("OpenIVFIndex failed: %v", err)
+	}
+	if legacy := index.LegacyCentroidIDs(); len(legacy) != 2 || legacy[0] != 1 || legacy[1] != 2 {
+		t.Errorf("Expected centroid records 1 and 2, got %v", legacy)
+	}
+	if index.centroids[1].Vector[0] != 2 {
+		t.Errorf("Expected the centroid read from record 2, got %v", index.centroids[1].Vector[:2])
+	}
+
+	// Saving moves the centroids into the IVF file and drops their records
+	if err := index.SaveIVF(); err != nil {
+		t.Fatalf("SaveIVF failed: %v", err)
+	}
+	if store.Has(1) || store.Has(2) || !store.Has(100) || index.LegacyCentroidIDs() != nil {
+		t.Errorf("Expected only the data vectors in storage, got %v", store.IDs())
+	}
+	header, err := ReadIVFHeader(ivfFile)
+	if err != nil || header.Version != ivfVersionCentroids {
+		t.Fatalf("Expected a version %d IVF file, got %+v (%v)", ivfVersionCentroids, header, err)
+	}
+	reopened, err := OpenIVFIndex(store)
+	if err != nil {
+		t.Fatalf("OpenIVFIndex failed: %v", err)
+	}
+	if reopened.LegacyCentroidIDs() != nil || reopened.centroids[1].Vector[0] != 2 {
+		t.Errorf("Expected the centroids from the IVF file, got %v", reopened.centroids[1].Vector[:2])
+	}
+	results, err := reopened.Search(index.centroids[1].Vector, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 101 {
+		t.Errorf("Expected vector 101, got %v (%v)", results, err)
+	}
+}
+
+func FuzzIVFIndex_LoadIVF(f *testing.F) {
+	f.Add(hostileIVF(2, 2, []int32{0, 1}, 2, []int32{0, 1}))
+	f.Add(hostileIVF(2, 0xFFFFFF, []int32{0}, 0xFFFFFFFF, []int32{7}))
+	tmpFile, err := os.CreateTemp("", "veclite_ivf_fuzz_*.db")
+	if err != nil {
+		f.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(tmpFile.Name() + ".ivf")
+	store, err := storage.NewStorage(tmpFile.Name(), 4, 0)
+	if err != nil {
+		f.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		f.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(tmpFile.Name()+".ivf", data, 0644); err != nil {
+			t.Fatalf("Failed to write IVF file: %v", err)
+		}
+		index, err := NewIVFIndex(4, make(map[string]any), store)
+		if err != nil {
+			t.Fatalf("Failed to create IVF index: %v", err)
+		}
+		index.LoadIVF() // Must fail or succeed without panicking
+	})
+}
@@ -1,6 +1,8 @@
 package ivf
 
 import (
+	"context"
+	"errors"
 	"os"
 	"testing"
 
@@ -97,6 +99,35 @@ func TestIVFIndex_Insert_Multiple(t *testing.T) {
 	}
 }
 
+func TestIVFIndex_InsertBatch(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	ids := make([]uint64, 30)
+	vectors := make([][]float32, 30)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+		vectors[i] = make([]float32, 128)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(i) + float32(j)*0.001
+		}
+	}
+	if err := index.InsertBatch(ids, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	if index.Size() != 30 {
+		t.Errorf("Expected size 30, got %d", index.Size())
+	}
+	if len(index.centroids) != 10 {
+		t.Errorf("Expected 10 centroids, got %d", len(index.centroids))
+	}
+	results, err := index.Search(vectors[20], 1)
+	if err != nil || len(results) != 1 || results[0].ID != 21 {
+		t.Errorf("Expected ID 21 to be nearest, got %v (%v)", results, err)
+	}
+}
+
 func TestIVFIndex_Insert_DimensionMismatch(t *testing.T) {
 	index, cleanup := createTestIVF(t)
 	defer cleanup()
@@ -146,6 +177,44 @@ func TestIVFIndex_Insert_UpdateExisting(t *testing.T) {
 	}
 }
 
+func TestIVFIndex_Insert_Reassigns(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for id := uint64(1); id <= 20; id++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(id)
+		if err := index.Insert(id, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// Move ID 15 next to the seed of cluster 0
+	vec := make([]float32, 128)
+	vec[0] = 1
+	if err := index.Insert(15, vec); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if index.Size() != 20 {
+		t.Errorf("Expected size 20 after update, got %d", index.Size())
+	}
+	members := 0
+	mapped, _ := index.clusterOfID(15)
+	for clusterID := range index.clusters {
+		for _, id := range index.vectorIDs(clusterID) {
+			if id == 15 {
+				members++
+				if clusterID != mapped {
+					t.Errorf("ID 15 is in cluster %d, mapped to %d", clusterID, mapped)
+				}
+			}
+		}
+	}
+	if members != 1 || mapped != 0 {
+		t.Errorf("Expected ID 15 once in cluster 0, found %d times (cluster %d)", members, mapped)
+	}
+}
+
 func TestIVFIndex_Insert_NoStorage(t *testing.T) {
 	config := make(map[string]any)
 	index, err := NewIVFIndex(128, config, nil)
@@ -414,6 +483,41 @@ func TestIVFIndex_Delete(t *testing.T) {
 	}
 }
 
+func TestIVFIndex_SparseIDsUseDenseSlots(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	// Sparse IDs across the whole range take consecutive slots, and a deleted
+	// vector's slot is reused
+	ids := []uint64{^uint64(0), 1 << 63, 7, 1 << 40}
+	for n, id := range ids {
+		vector := make([]float32, 128)
+		vector[0] = float32(n)
+		if err := index.Insert(id, vector); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", id, err)
+		}
+	}
+	if err := index.Delete(1 << 63); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	reused := make([]float32, 128)
+	reused[0] = 10
+	if err := index.Insert(42, reused); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if index.slots.Cap() != len(ids) || index.slots.Len() != len(ids) {
+		t.Errorf("Expected %d slots in use, got %d of %d", len(ids), index.slots.Len(), index.slots.Cap())
+	}
+
+	results, err := index.Search(make([]float32, 128), 1)
+	if err != nil || len(results) != 1 || results[0].ID != ^uint64(0) {
+		t.Errorf("Expected the highest ID, got %v (%v)", results, err)
+	}
+	if _, err := index.ReadVector(1 << 63); err == nil {
+		t.Error("Expected error when reading deleted vector")
+	}
+}
+
 func TestIVFIndex_Delete_NonExistent(t *testing.T) {
 	index, cleanup := createTestIVF(t)
 	defer cleanup()
@@ -570,3 +674,159 @@ func TestIVFIndex_NewIVFIndex_WithConfig(t *testing.T) {
 	}
 }
 
+func TestIVFIndex_LocalityOrder(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 40; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i % 4)
+		vec[1] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	order := index.LocalityOrder()
+	if len(order) != index.Size() {
+		t.Fatalf("Expected %d IDs, got %d", index.Size(), len(order))
+	}
+
+	// Clusters follow each other in centroid order, each with exactly its own members
+	pos := 0
+	for _, centroid := range index.centroids {
+		for range index.clusters[centroid.ID] {
+			if c, _ := index.clusterOfID(order[pos]); c != centroid.ID {
+				t.Errorf("ID %d placed in cluster %d block", order[pos], centroid.ID)
+			}
+			pos++
+		}
+	}
+}
+
+func TestIVFIndex_SearchContext_Cancelled(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 50; i++ {
+		vec := make([]float32, 128)
+		vec[i%128] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := index.SearchContext(ctx, make([]float32, 128), 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	results, err := index.SearchContext(context.Background(), make([]float32, 128), 5)
+	if err != nil {
+		t.Fatalf("SearchContext failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("Expected 5 results, got %d", len(results))
+	}
+}
+
+func TestIVFIndex_IndexExisting(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 20; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := index.storage.WriteVector(i, vec); err != nil {
+			t.Fatalf("Failed to write vector: %v", err)
+		}
+		if err := index.IndexExisting(i, vec); err != nil {
+			t.Fatalf("IndexExisting failed: %v", err)
+		}
+	}
+	// Re-indexing an assigned vector is a no-op
+	if err := index.IndexExisting(1, make([]float32, 128)); err != nil {
+		t.Fatalf("IndexExisting on existing vector failed: %v", err)
+	}
+	if err := index.IndexExisting(21, make([]float32, 3)); err != types.ErrDimensionMismatch {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if index.Size() != 20 {
+		t.Errorf("Expected size 20, got %d", index.Size())
+	}
+}
+
+func TestIVFIndex_Params(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	before := index.Params()
+	index.SetSearchParams(map[string]any{"NProbe": 7, "NClusters": 99})
+	params := index.Params()
+	if params["NProbe"] != 7 {
+		t.Errorf("Expected NProbe 7, got %d", params["NProbe"])
+	}
+	if params["NClusters"] != before["NClusters"] {
+		t.Errorf("SetSearchParams must not change build parameter NClusters, got %d", params["NClusters"])
+	}
+}
+
+func TestIVFIndex_Histogram(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 40; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i % 4)
+		vec[1] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	label, counts := index.Histogram()
+	if label != "cluster" || len(counts) != len(index.centroids) {
+		t.Fatalf("Expected one count per cluster, got %q %v", label, counts)
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	if total != index.Size() {
+		t.Errorf("Expected counts to add up to %d, got %v", index.Size(), counts)
+	}
+}
+
+func TestIVFIndex_Candidates(t *testing.T) {
+	index, cleanup := createTestIVF(t)
+	defer cleanup()
+
+	for i := uint64(1); i <= 40; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i % 4)
+		vec[1] = float32(i)
+		if err := index.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	query := make([]float32, 128)
+	query[1] = 20
+
+	candidates, err := index.Candidates(context.Background(), query, 0)
+	if err != nil {
+		t.Fatalf("Candidates failed: %v", err)
+	}
+	results, _ := index.Search(query, len(candidates)+10)
+	if len(candidates) != len(results) {
+		t.Fatalf("Expected every scanned vector as a candidate, got %d of %d", len(candidates), len(results))
+	}
+	for i := range candidates {
+		if candidates[i].ID != results[i].ID || candidates[i].Vector != nil {
+			t.Fatalf("Candidate %d differs from the search result or carries a vector", i)
+		}
+	}
+	if two, _ := index.Candidates(context.Background(), query, 2); len(two) != min(2, len(candidates)) {
+		t.Errorf("Expected 2 candidates, got %d", len(two))
+	}
+}
@@ -0,0 +1,446 @@
+package ivf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/pq"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// IVF-PQ: every inverted list keeps the product-quantized codes of its
+// vectors in memory next to their IDs, so searches score the probed clusters
+// from the codes instead of reading every vector from storage, and only read
+// the vectors of the best candidates to re-rank them exactly
+// Codes encode the vectors themselves rather than their residuals to the
+// centroid, as centroids move with every insert and delete
+const (
+	// pqMinTrain is the number of vectors at which a codebook is trained
+	// automatically; smaller indexes are searched exactly
+	pqMinTrain = 1024
+
+	// pqTrainSample is the maximum number of vectors read to train a codebook
+	pqTrainSample = 65536
+
+	// pqRetrainGrowth retrains a codebook trained on fewer than pqTrainSample
+	// vectors once the index has grown by this factor
+	pqRetrainGrowth = 8
+
+	// pqRerankFactor is how many candidates per result are re-ranked with
+	// their full-precision vectors unless RerankK is set
+	pqRerankFactor = 2
+
+	// pqCodeBytes is the default code size: one byte per 4 dimensions
+	pqCodeBytes = 4
+
+	pqMagic = uint32(0x51504649) // "IFPQ" in ASCII, little-endian
+)
+
+// ErrPQDisabled is returned by TrainPQ for a plain IVF index
+var ErrPQDisabled = errors.New("product quantization is not enabled")
+
+// NewIVFPQIndex creates a new IVF-PQ index: an IVF index whose inverted lists
+// hold PQ codes of config["PQSubspaces"] bytes per vector (default: one per 4
+// dimensions), re-ranking the config["RerankK"] nearest candidates by code
+// (default: 2 per result) with their stored vectors
+func NewIVFPQIndex(dimension int, config map[string]any, storage *storage.Storage) (*IVFIndex, error) {
+	i, err := NewIVFIndex(dimension, config, storage)
+	if err != nil {
+		return nil, err
+	}
+	i.pqSubspaces = max(dimension/pqCodeBytes, 1)
+	i.SetSearchParams(config)
+	return i, nil
+}
+
+// OpenIVFPQIndex opens an existing IVF-PQ index like OpenIVFIndex, loading the
+// codes from the ".ivfpq" sidecar
+// Opening never trains: without usable codes searches are exact until the
+// next insert retrains them in the background, or TrainPQ
+func OpenIVFPQIndex(storage *storage.Storage) (*IVFIndex, error) {
+	i, err := OpenIVFIndex(storage)
+	if err != nil {
+		return nil, err
+	}
+	i.pqSubspaces = max(i.dimension/pqCodeBytes, 1)
+	i.openPQ()
+	return i, nil
+}
+
+// code returns the PQ code of the j-th vector of cluster clusterID
+func (i *IVFIndex) code(clusterID, j int) []byte {
+	m := i.codebook.Subspaces()
+	return i.codes[clusterID][j*m : (j+1)*m]
+}
+
+// appendCode appends the code of vec to the codes of clusterID, as its ID was
+// appended to the cluster (no-op without a codebook)
+func (i *IVFIndex) appendCode(clusterID int, vec []float32) {
+	if i.codebook == nil {
+		return
+	}
+	m := i.codebook.Subspaces()
+	list := append(i.codes[clusterID], make([]byte, m)...)
+	i.codebook.Encode(vec, list[len(list)-m:])
+	i.codes[clusterID] = list
+}
+
+// removeCode moves the last code of clusterID over the j-th one, as unassign
+// does with the IDs (no-op without a codebook)
+func (i *IVFIndex) removeCode(clusterID, j int) {
+	if i.codebook == nil {
+		return
+	}
+	m := i.codebook.Subspaces()
+	list := i.codes[clusterID]
+	last := len(list) - m
+	copy(list[j*m:(j+1)*m], list[last:])
+	i.codes[clusterID] = list[:last]
+}
+
+// rerankCount returns the number of candidates re-ranked for k results
+func (i *IVFIndex) rerankCount(k int) int {
+	if i.rerankK > 0 {
+		return max(i.rerankK, k)
+	}
+	return pqRerankFactor * k
+}
+
+// rerank reads the vectors of candidates and returns them with their exact
+// distances to query, sorted like scanClusters, with a copy of each vector if
+// withVectors is set
+func (i *IVFIndex) rerank(query []float32, candidates []types.SearchResult, withVectors bool, p *profile.Profile) []types.SearchResult {
+	results := make([]types.SearchResult, 0, len(candidates))
+	for _, cand := range candidates {
+		vec, err := i.storage.ReadVectorProfiled(cand.ID, p)
+		if err != nil {
+			continue // Skip this result if vector can't be read (inconsistent state)
+		}
+		p.AddDistances(1)
+		result := types.SearchResult{ID: cand.ID, Distance: vector.L2Distance(query, vec)}
+		if withVectors {
+			result.Vector = append([]float32(nil), vec...)
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Distance != results[b].Distance {
+			return results[a].Distance < results[b].Distance
+		}
+		return results[a].ID < results[b].ID
+	})
+	return results
+}
+
+// pqJob is a codebook training over a snapshot of the indexed IDs, run in
+// the background without touching the index
+type pqJob struct {
+	ids  []uint64 // Snapshot, sorted
+	done chan struct{}
+
+	// Set before done is closed
+	codebook  *pq.Codebook
+	codes     []byte // Code of ids[n] at codes[n*subspaces:]
+	trainedOn int
+	err       error
+}
+
+// maybeTrainPQ swaps in the codebook of a finished background training, and
+// starts one once PQ is enabled and the index is large enough, and as the
+// index grows (see pqRetrainGrowth)
+// Writes never wait for a training; a failed one (see PQError) is retried
+// once pqMinTrain more vectors were added
+func (i *IVFIndex) maybeTrainPQ() {
+	i.collectPQ(false)
+	if i.pqSubspaces <= 0 || i.storage == nil || i.pqJob != nil || i.size < pqMinTrain {
+		return
+	}
+	if i.pqErr != nil && i.size < i.pqAttempt+pqMinTrain {
+		return
+	}
+	if i.codebook == nil || (i.pqTrainedOn < pqTrainSample && i.size >= pqRetrainGrowth*i.pqTrainedOn) {
+		i.startPQ()
+	}
+}
+
+// TrainPQ (re)trains the PQ codebook from up to pqTrainSample stored vectors
+// and encodes every vector with it, waiting for the training
+func (i *IVFIndex) TrainPQ() error {
+	if i.pqSubspaces <= 0 {
+		return ErrPQDisabled
+	}
+	if i.storage == nil {
+		return errors.New("storage is required to train PQ")
+	}
+	i.collectPQ(true)
+	i.startPQ()
+	i.collectPQ(true)
+	return i.pqErr
+}
+
+// startPQ snapshots the indexed IDs and trains a codebook over them in the background
+func (i *IVFIndex) startPQ() {
+	job := &pqJob{ids: i.indexedIDs(), done: make(chan struct{})}
+	i.pqJob, i.pqStale, i.pqAttempt = job, make(map[uint64]struct{}), i.size
+
+	store, dimension, subspaces := i.storage, i.dimension, i.pqSubspaces
+	read := func(id uint64) ([]float32, error) {
+		vec, err := store.ReadVector(id)
+		if err != nil && !store.Has(id) {
+			return nil, nil // Deleted since the snapshot
+		}
+		return vec, err
+	}
+	go func() {
+		defer close(job.done)
+		job.codebook, job.codes, job.trainedOn, job.err = pq.TrainIDs(job.ids, read, dimension, subspaces, pqTrainSample)
+	}()
+}
+
+// collectPQ swaps in the codebook of a finished training and records its
+// outcome for PQError; with wait it first waits for a running one
+func (i *IVFIndex) collectPQ(wait bool) {
+	job := i.pqJob
+	if job == nil {
+		return
+	}
+	if wait {
+		<-job.done
+	} else {
+		select {
+		case <-job.done:
+		default:
+			return
+		}
+	}
+	stale := i.pqStale
+	i.pqJob, i.pqStale = nil, nil
+	i.pqErr = job.err
+	if job.err == nil {
+		i.pqErr = i.installPQ(job, stale)
+	}
+}
+
+// installPQ swaps in the codebook of job with the codes of its snapshot,
+// encoding the vectors assigned since
+func (i *IVFIndex) installPQ(job *pqJob, stale map[uint64]struct{}) error {
+	m := job.codebook.Subspaces()
+	codes := make(map[int][]byte, len(i.clusters))
+	for clusterID, members := range i.clusters {
+		list := make([]byte, len(members)*m)
+		for j, slot := range members {
+			id := i.vectorID(slot)
+			code := list[j*m : (j+1)*m]
+			n := sort.Search(len(job.ids), func(n int) bool { return job.ids[n] >= id })
+			if _, changed := stale[id]; !changed && n < len(job.ids) && job.ids[n] == id {
+				copy(code, job.codes[n*m:(n+1)*m])
+				continue
+			}
+			vec, err := i.storage.ReadVector(id)
+			if err != nil {
+				return fmt.Errorf("failed to read vector %d for PQ encoding: %w", id, err)
+			}
+			job.codebook.Encode(vec, code)
+		}
+		codes[clusterID] = list
+	}
+	i.codebook, i.codes, i.pqTrainedOn = job.codebook, codes, job.trainedOn
+	return nil
+}
+
+// PQError returns the error of the last codebook training, nil if it
+// succeeded or none ran
+func (i *IVFIndex) PQError() error {
+	return i.pqErr
+}
+
+// WaitPQ waits for a background training, swaps its codebook in and returns PQError
+func (i *IVFIndex) WaitPQ() error {
+	i.collectPQ(true)
+	return i.pqErr
+}
+
+// PQTrained reports whether searches score candidates with PQ codes
+func (i *IVFIndex) PQTrained() bool {
+	return i.codebook != nil
+}
+
+// setPQSubspaces changes the code size of an IVF-PQ index
+// A codebook of another size is dropped and retrained
+func (i *IVFIndex) setPQSubspaces(subspaces int) {
+	subspaces = min(subspaces, i.dimension)
+	if subspaces == i.pqSubspaces {
+		return
+	}
+	i.pqSubspaces = subspaces
+	i.codebook, i.codes = nil, nil
+	i.pqJob, i.pqStale, i.pqErr = nil, nil, nil // A running training is of the old size
+	i.maybeTrainPQ()
+}
+
+// pqPath returns the path of the IVF-PQ sidecar (codebook and codes)
+func (i *IVFIndex) pqPath() string {
+	return i.storage.GetFilePath() + ".ivfpq"
+}
+
+// savePQ writes the codebook and the codes of all vectors to the IVF-PQ
+// sidecar, or removes it if no codebook is trained
+// The file is written next to the sidecar and renamed over it, so a crash
+// leaves the previous one intact
+// Layout: magic, training sample size, codebook (see pq.Codebook.WriteTo),
+// vector count, then per vector its ID and code
+func (i *IVFIndex) savePQ() error {
+	path := i.pqPath()
+	if i.codebook == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove IVF-PQ file: %w", err)
+		}
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create IVF-PQ file: %w", err)
+	}
+	if err := i.writePQ(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync IVF-PQ file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close IVF-PQ file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename IVF-PQ file: %w", err)
+	}
+	return nil
+}
+
+// writePQ writes the contents of the IVF-PQ sidecar (see savePQ) to file
+func (i *IVFIndex) writePQ(file io.Writer) error {
+	w := bufio.NewWriter(file)
+	if err := binary.Write(w, binary.LittleEndian, pqMagic); err != nil {
+		return fmt.Errorf("failed to write IVF-PQ magic: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(i.pqTrainedOn)); err != nil {
+		return fmt.Errorf("failed to write IVF-PQ training size: %w", err)
+	}
+	if _, err := i.codebook.WriteTo(w); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(i.slots.Len())); err != nil {
+		return fmt.Errorf("failed to write IVF-PQ vector count: %w", err)
+	}
+	for _, centroid := range i.centroids {
+		for j, id := range i.vectorIDs(centroid.ID) {
+			if err := binary.Write(w, binary.LittleEndian, id); err != nil {
+				return fmt.Errorf("failed to write PQ code of vector %d: %w", id, err)
+			}
+			if _, err := w.Write(i.code(centroid.ID, j)); err != nil {
+				return fmt.Errorf("failed to write PQ code of vector %d: %w", id, err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write IVF-PQ file: %w", err)
+	}
+	return nil
+}
+
+// openPQ loads the codes of an IVF-PQ index after its clusters were loaded,
+// leaving the codebook untrained if the sidecar is missing, unusable or stale
+func (i *IVFIndex) openPQ() {
+	i.codebook, i.codes = nil, nil
+	if i.pqSubspaces <= 0 {
+		return
+	}
+	if err := i.loadPQ(); err != nil {
+		i.codebook, i.codes = nil, nil
+	}
+}
+
+// loadPQ reads the IVF-PQ sidecar if it exists and codes every vector of the
+// loaded clusters; otherwise the codebook is left untrained
+func (i *IVFIndex) loadPQ() error {
+	file, err := os.Open(i.pqPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open IVF-PQ file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat IVF-PQ file: %w", err)
+	}
+	r := bufio.NewReader(file)
+
+	var magic, trainedOn, count uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return fmt.Errorf("failed to read IVF-PQ magic: %w", err)
+	}
+	if magic != pqMagic {
+		return errors.New("invalid IVF-PQ file: magic number mismatch")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &trainedOn); err != nil {
+		return fmt.Errorf("failed to read IVF-PQ training size: %w", err)
+	}
+	codebook, err := pq.Read(r)
+	if err != nil {
+		return err
+	}
+	if codebook.Dimension() != i.dimension {
+		return fmt.Errorf("PQ codebook dimension %d does not match index dimension %d", codebook.Dimension(), i.dimension)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return fmt.Errorf("failed to read IVF-PQ vector count: %w", err)
+	}
+	m := codebook.Subspaces()
+	if int64(count)*int64(8+m) > info.Size() {
+		return fmt.Errorf("invalid IVF-PQ file: %d codes claimed in %d bytes", count, info.Size())
+	}
+
+	byID := make(map[uint64][]byte, count)
+	for n := uint32(0); n < count; n++ {
+		var id uint64
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return fmt.Errorf("failed to read PQ code %d: %w", n, err)
+		}
+		code := make([]byte, m)
+		if _, err := io.ReadFull(r, code); err != nil {
+			return fmt.Errorf("failed to read PQ code of vector %d: %w", id, err)
+		}
+		byID[id] = code
+	}
+
+	codes := make(map[int][]byte, len(i.clusters))
+	for clusterID, members := range i.clusters {
+		list := make([]byte, 0, len(members)*m)
+		for _, slot := range members {
+			code, exists := byID[i.vectorID(slot)]
+			if !exists {
+				return nil // Stale sidecar: retrain
+			}
+			list = append(list, code...)
+		}
+		codes[clusterID] = list
+	}
+	i.pqSubspaces, i.codebook, i.codes, i.pqTrainedOn = m, codebook, codes, int(trainedOn)
+	return nil
+}
@@ -0,0 +1,236 @@
+package ivf
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// createPQIVF creates an IVF-PQ index over n random 8-dimensional vectors
+func createPQIVF(t *testing.T, n int) (*IVFIndex, *storage.Storage, string, [][]float32) {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	t.Cleanup(func() {
+		os.Remove(tmpFile)
+		os.Remove(tmpFile + ".ivf")
+		os.Remove(tmpFile + ".ivfpq")
+	})
+	store, err := storage.NewStorage(tmpFile, 8, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	index, err := NewIVFPQIndex(8, map[string]any{"NClusters": 8, "NProbe": 8, "PQSubspaces": 4, "RerankK": 40}, store)
+	if err != nil {
+		t.Fatalf("Failed to create IVF-PQ index: %v", err)
+	}
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, 8)
+		for d := range vectors[i] {
+			vectors[i][d] = rng.Float32()
+		}
+		if err := index.Insert(uint64(i+1), vectors[i]); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i+1, err)
+		}
+	}
+	if err := index.WaitPQ(); err != nil {
+		t.Fatalf("Background PQ training failed: %v", err)
+	}
+	return index, store, tmpFile, vectors
+}
+
+// checkCodes fails unless every inverted list holds the codes of its vectors
+func checkCodes(t *testing.T, index *IVFIndex) {
+	t.Helper()
+	m := index.codebook.Subspaces()
+	code := make([]byte, m)
+	for clusterID := range index.clusters {
+		members := index.vectorIDs(clusterID)
+		if len(index.codes[clusterID]) != len(members)*m {
+			t.Fatalf("Cluster %d: %d bytes of codes for %d vectors", clusterID, len(index.codes[clusterID]), len(members))
+		}
+		for j, id := range members {
+			vec, err := index.storage.ReadVector(id)
+			if err != nil {
+				t.Fatalf("Failed to read vector %d: %v", id, err)
+			}
+			index.codebook.Encode(vec, code)
+			if !bytes.Equal(index.code(clusterID, j), code) {
+				t.Fatalf("Cluster %d: wrong code for vector %d", clusterID, id)
+			}
+		}
+	}
+}
+
+func TestIVFIndex_PQ(t *testing.T) {
+	index, _, _, vectors := createPQIVF(t, pqMinTrain+200)
+	if !index.PQTrained() {
+		t.Fatal("Expected a codebook once the index reached pqMinTrain vectors")
+	}
+	checkCodes(t, index)
+
+	// Only the re-ranked candidates are read from storage
+	query := vectors[10]
+	p := profile.New(false)
+	results, err := index.SearchContext(profile.NewContext(context.Background(), p), query, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 10 || results[0].ID != 11 || results[0].Distance != 0 {
+		t.Fatalf("Expected 10 results starting with the query's own vector, got %v", results)
+	}
+	if reads := p.Reads; reads > 40+8 {
+		t.Errorf("Expected at most RerankK vectors and the centroids to be read, got %d reads", reads)
+	}
+	for i, r := range results {
+		if exact := vector.L2Distance(query, vectors[r.ID-1]); r.Distance != exact {
+			t.Errorf("Result %d: expected exact distance %v, got %v", i, exact, r.Distance)
+		}
+		if i > 0 && r.Distance < results[i-1].Distance {
+			t.Errorf("Expected results sorted by distance, got %v after %v", r.Distance, results[i-1].Distance)
+		}
+	}
+
+	// Deletes and updates keep the codes in step with the inverted lists
+	for id := uint64(1); id <= 100; id++ {
+		if err := index.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	for id := uint64(101); id <= 200; id++ {
+		if err := index.Insert(id, vectors[id+99]); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	checkCodes(t, index)
+	if err := index.Retrain(0); err != nil {
+		t.Fatalf("Retrain failed: %v", err)
+	}
+	checkCodes(t, index)
+}
+
+func TestIVFIndex_PQ_RoundTrip(t *testing.T) {
+	index, store, tmpFile, vectors := createPQIVF(t, pqMinTrain+10)
+	if err := index.SaveIVF(); err != nil {
+		t.Fatalf("Failed to save IVF: %v", err)
+	}
+	if _, err := os.Stat(tmpFile + ".ivfpq"); err != nil {
+		t.Fatalf("Expected an IVF-PQ file: %v", err)
+	}
+	store.Close()
+
+	store2, err := storage.NewStorage(tmpFile, 8, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store2.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store2.Close()
+	index2, err := OpenIVFPQIndex(store2)
+	if err != nil {
+		t.Fatalf("Failed to open IVF-PQ index: %v", err)
+	}
+	if !index2.PQTrained() || index2.pqSubspaces != 4 || index2.pqTrainedOn != index.pqTrainedOn {
+		t.Fatal("Expected the codebook to be loaded")
+	}
+	checkCodes(t, index2)
+	results, err := index2.Search(vectors[0], 1)
+	if err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("Expected ID 1 after reloading, got %v, %v", results, err)
+	}
+
+	// Without the sidecar the index opens untrained, and the next insert
+	// retrains the codes in the background
+	if err := os.Remove(tmpFile + ".ivfpq"); err != nil {
+		t.Fatalf("Failed to remove IVF-PQ file: %v", err)
+	}
+	index3, err := OpenIVFPQIndex(store2)
+	if err != nil {
+		t.Fatalf("Failed to open IVF-PQ index: %v", err)
+	}
+	if index3.PQTrained() {
+		t.Fatal("Expected opening not to train a codebook")
+	}
+	if err := index3.Insert(uint64(len(vectors)+1), vectors[0]); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := index3.WaitPQ(); err != nil || !index3.PQTrained() {
+		t.Fatalf("Expected the codebook to be retrained, got %v", err)
+	}
+	checkCodes(t, index3)
+
+	// A plain IVF index opens the same files, ignoring the codes
+	plain, err := OpenIVFIndex(store2)
+	if err != nil {
+		t.Fatalf("Failed to open IVF index: %v", err)
+	}
+	if plain.PQTrained() || plain.Size() != index.Size() {
+		t.Errorf("Expected a plain IVF index of %d vectors", index.Size())
+	}
+	if err := plain.TrainPQ(); err != ErrPQDisabled {
+		t.Errorf("Expected ErrPQDisabled, got %v", err)
+	}
+}
+
+func TestIVFIndex_PQ_Small(t *testing.T) {
+	index, _, _, vectors := createPQIVF(t, 100)
+	if index.PQTrained() {
+		t.Error("Expected no codebook below pqMinTrain vectors")
+	}
+	results, err := index.Search(vectors[5], 1)
+	if err != nil || len(results) != 1 || results[0].ID != 6 {
+		t.Errorf("Expected an exact search to find ID 6, got %v, %v", results, err)
+	}
+	// TrainPQ trains on demand regardless of size
+	if err := index.TrainPQ(); err != nil {
+		t.Fatalf("TrainPQ failed: %v", err)
+	}
+	checkCodes(t, index)
+}
+
+func TestIVFIndex_PQ_Background(t *testing.T) {
+	index, _, _, vectors := createPQIVF(t, pqMinTrain+10)
+
+	// Vectors inserted, replaced and deleted while a training runs are coded
+	// when it is swapped in
+	index.startPQ()
+	trained := index.pqJob
+	<-trained.done
+	job := *trained // Still running as far as writes can tell
+	job.done = make(chan struct{})
+	index.pqJob = &job
+	for n := 0; n < 50; n++ {
+		vec := []float32{float32(n) / 50, 0, 1, 0, 0, 1, 0, float32(n) / 100}
+		if err := index.Insert(uint64(len(vectors)+n+1), vec); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if err := index.Insert(5, []float32{1, 1, 1, 1, 0, 0, 0, 0}); err != nil {
+		t.Fatalf("Failed to replace vector 5: %v", err)
+	}
+	if err := index.Delete(6); err != nil {
+		t.Fatalf("Failed to delete vector 6: %v", err)
+	}
+	if index.pqJob != &job {
+		t.Fatal("Expected a running training not to be swapped in")
+	}
+	close(job.done)
+	if err := index.WaitPQ(); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if index.codebook != job.codebook {
+		t.Fatal("Expected the trained codebook to be swapped in")
+	}
+	checkCodes(t, index)
+}
@@ -0,0 +1,92 @@
+package ivf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/monishSR/veclite/internal/pq"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// Retrain re-runs k-means over a random sample of up to sampleSize indexed
+// vectors (0 = all of them), reassigns every vector to its nearest new
+// centroid and swaps in the new inverted lists
+// Centroids chosen incrementally on insert drift away from the data as it
+// changes; retraining restores recall without rewriting any data vector
+// On error the old clusters stay in effect
+func (i *IVFIndex) Retrain(sampleSize int) error {
+	if i.storage == nil {
+		return errors.New("storage not available")
+	}
+	ids := i.indexedIDs()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(1)) // Deterministic clusters for the same data
+	sample := ids
+	if sampleSize > 0 && sampleSize < len(ids) {
+		sample = append([]uint64(nil), ids...)
+		rng.Shuffle(len(sample), func(a, b int) { sample[a], sample[b] = sample[b], sample[a] })
+		sample = sample[:sampleSize]
+	}
+	vectors := make([][]float32, len(sample))
+	for n, id := range sample {
+		vec, err := i.storage.ReadVector(id)
+		if err != nil {
+			return fmt.Errorf("failed to read training vector %d: %w", id, err)
+		}
+		vectors[n] = vec
+	}
+	k := min(i.nClusters, len(vectors))
+	trained := pq.KMeans(vectors, k, rng)
+	centroidVecs := make([][]float32, k)
+	for c := range centroidVecs {
+		centroidVecs[c] = trained[c*i.dimension : (c+1)*i.dimension]
+	}
+
+	// Reassign every vector to the new centroids before anything is swapped in,
+	// moving IVF-PQ codes along with their slots, which stay assigned
+	clusters := make(map[int][]uint32, k)
+	codes := make(map[int][]byte, k)
+	clusterOf := make([]int32, len(i.clusterOf))
+	for _, id := range ids {
+		vec, err := i.storage.ReadVector(id)
+		if err != nil {
+			return fmt.Errorf("failed to read vector %d: %w", id, err)
+		}
+		clusterID := nearestCentroid(centroidVecs, vec)
+		slot, _ := i.slots.Lookup(id)
+		clusters[clusterID] = append(clusters[clusterID], slot)
+		if i.codebook != nil {
+			list := append(codes[clusterID], make([]byte, i.codebook.Subspaces())...)
+			i.codebook.Encode(vec, list[len(list)-i.codebook.Subspaces():])
+			codes[clusterID] = list
+		}
+		clusterOf[slot] = int32(clusterID)
+	}
+
+	// The new centroids and lists are swapped in together
+	centroids := make([]Centroid, k)
+	for c := range centroids {
+		centroids[c] = Centroid{ID: c, Vector: centroidVecs[c]}
+	}
+	i.centroids = centroids
+	i.clusters = clusters
+	i.codes = codes
+	i.clusterOf = clusterOf
+	return nil
+}
+
+// nearestCentroid returns the index of the vector of centroids nearest to vec
+func nearestCentroid(centroids [][]float32, vec []float32) int {
+	best, bestDist := 0, float32(math.MaxFloat32)
+	for c, centroid := range centroids {
+		if dist := vector.L2Distance(vec, centroid); dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,84 @@
+package ivf
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+func TestIVFIndex_Retrain(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+	defer os.Remove(tmpFile + ".ivf")
+	store, err := storage.NewStorage(tmpFile, 2, 0)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := store.Open(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+	index, err := NewIVFIndex(2, map[string]any{"NClusters": 4, "NProbe": 1}, store)
+	if err != nil {
+		t.Fatalf("Failed to create IVF index: %v", err)
+	}
+
+	// The first vectors, which become the centroids, all come from one blob
+	blobs := [][2]float32{{0, 0}, {100, 0}, {0, 100}, {100, 100}}
+	id := uint64(1)
+	for i := 0; i < 4; i++ {
+		if err := index.Insert(id, []float32{float32(i) * 0.1, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		id++
+	}
+	for _, blob := range blobs {
+		for i := 0; i < 25; i++ {
+			vec := []float32{blob[0] + float32(i%5), blob[1] + float32(i/5)}
+			if err := index.Insert(id, vec); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			id++
+		}
+	}
+	size := index.Size()
+
+	if err := index.Retrain(50); err != nil {
+		t.Fatalf("Retrain failed: %v", err)
+	}
+	if index.Size() != size {
+		t.Fatalf("Expected size %d after retraining, got %d", size, index.Size())
+	}
+	_, counts := index.Histogram()
+	sort.Ints(counts)
+	if !reflect.DeepEqual(counts, []int{25, 25, 25, 29}) {
+		t.Fatalf("Expected one cluster per blob, got %v", counts)
+	}
+
+	// With one probe, every blob is found
+	for _, blob := range blobs {
+		results, err := index.Search([]float32{blob[0] + 2, blob[1] + 2}, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Distance != 0 {
+			t.Fatalf("Expected an exact match near %v, got %+v", blob, results)
+		}
+	}
+
+	// The retrained clusters are saved and loaded like built ones
+	if err := index.SaveIVF(); err != nil {
+		t.Fatalf("SaveIVF failed: %v", err)
+	}
+	loaded, err := OpenIVFIndex(store)
+	if err != nil {
+		t.Fatalf("Failed to open IVF index: %v", err)
+	}
+	_, loadedCounts := loaded.Histogram()
+	if _, want := index.Histogram(); !reflect.DeepEqual(loadedCounts, want) {
+		t.Fatalf("Expected loaded clusters %v, got %v", want, loadedCounts)
+	}
+}
@@ -1,17 +1,46 @@
 package types
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 // SearchResult represents a search result with ID, distance, and vector
+// Metadata and Key are attached by the database layer; indexes leave them empty
 type SearchResult struct {
 	ID       uint64
+	Key      string // String ID the vector was inserted under ("" for numeric IDs)
 	Distance float32
 	Vector   []float32
+	Metadata map[string]any
+}
+
+// Frontier continues a search from its retained state, see
+// index.FrontierSearcher
+type Frontier interface {
+	// Next returns up to n further results, nearest first, none returned
+	// before; fewer once no more vectors can be reached
+	Next(ctx context.Context, n int) ([]SearchResult, error)
+}
+
+// vectorsKey is the context key of WithoutVectors
+type vectorsKey struct{}
+
+// WithoutVectors returns a copy of ctx for a search whose results are returned
+// without their vectors, so the index skips copying them
+func WithoutVectors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, vectorsKey{}, false)
+}
+
+// WantVectors reports whether a search run with ctx returns result vectors
+func WantVectors(ctx context.Context) bool {
+	want, ok := ctx.Value(vectorsKey{}).(bool)
+	return !ok || want
 }
 
 // Common errors used by all index implementations
 var (
 	ErrDimensionMismatch = errors.New("vector dimension mismatch")
 	ErrInvalidK          = errors.New("k must be greater than 0")
+	ErrNotFound          = errors.New("not found") // Wrapped by ReadVector for unknown IDs
 )
-
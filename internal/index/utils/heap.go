@@ -19,8 +19,17 @@ func (h CandidateHeap) Len() int { return len(h) }
 
 // Less defines the ordering: larger distance = higher priority (max-heap)
 // This allows us to easily remove the worst candidate (at index 0)
+// Equal distances rank the larger ID as worse, so results are stable
 func (h CandidateHeap) Less(i, j int) bool {
-	return h[i].Distance > h[j].Distance // Max-heap: larger distance = higher priority
+	return h[j].nearer(h[i]) // Max-heap: larger distance = higher priority
+}
+
+// nearer reports whether c ranks before other: by distance, then by smaller ID
+func (c Candidate) nearer(other Candidate) bool {
+	if c.Distance != other.Distance {
+		return c.Distance < other.Distance
+	}
+	return c.ID < other.ID
 }
 
 // Swap swaps two elements in the heap
@@ -127,7 +136,7 @@ func (h *CandidateHeap) AddCandidate(cand Candidate, maxSize int) bool {
 		return true
 	}
 	// Only peek if heap is full (avoid expensive peek when not needed)
-	if cand.Distance < h.Peek().Distance {
+	if cand.nearer(h.Peek()) {
 		// New candidate is better than worst, replace it
 		h.PopCandidate()
 		h.PushCandidate(cand)
@@ -136,4 +145,3 @@ func (h *CandidateHeap) AddCandidate(cand Candidate, maxSize int) bool {
 	// Candidate is worse than all in heap, ignore it
 	return false
 }
-
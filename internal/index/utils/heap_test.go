@@ -477,3 +477,14 @@ func TestCandidateHeap_ConcurrentOperations(t *testing.T) {
 	}
 }
 
+func TestCandidateHeap_TieBreaking(t *testing.T) {
+	h := NewCandidateHeap(3)
+	for _, id := range []uint64{5, 2, 9, 1, 7} {
+		h.AddCandidate(Candidate{ID: id, Distance: 1.0}, 3)
+	}
+
+	top := h.ExtractTop(3)
+	if len(top) != 3 || top[0].ID != 1 || top[1].ID != 2 || top[2].ID != 5 {
+		t.Errorf("Expected the smallest IDs 1, 2, 5 among equal distances, got %+v", top)
+	}
+}
@@ -0,0 +1,253 @@
+// Package pq implements product quantization: vectors are split into
+// subspaces and each part is replaced by the index of its nearest centroid in
+// a per-subspace codebook, so a vector costs one byte per subspace in memory.
+// Distances from a query to coded vectors are sums of table lookups
+package pq
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+)
+
+const (
+	codebookMagic   = uint32(0x56505143) // "CQPV" in ASCII, little-endian
+	codebookVersion = uint16(1)
+
+	// MaxCentroids is the number of centroids per subspace, so codes fit a byte
+	MaxCentroids = 256
+
+	// trainIterations is the number of k-means passes per subspace
+	trainIterations = 12
+)
+
+// Codebook holds the centroids of every subspace
+// Subspace i covers dimensions [bounds[i], bounds[i+1]); the dimension need
+// not be a multiple of the number of subspaces
+// A Codebook is immutable once trained and safe for concurrent use
+type Codebook struct {
+	dim       int
+	subspaces int
+	centroids int         // Centroids per subspace (<= MaxCentroids)
+	bounds    []int       // subspaces+1 dimension offsets
+	data      [][]float32 // data[i] = centroids*width(i) values of subspace i
+}
+
+// Train learns a codebook for vectors (all of dimension dim) with the given
+// number of subspaces, by k-means in each subspace
+// Up to MaxCentroids centroids are learned per subspace, fewer if there are
+// fewer vectors
+func Train(vectors [][]float32, dim, subspaces int) (*Codebook, error) {
+	if subspaces <= 0 || subspaces > dim {
+		return nil, fmt.Errorf("pq: %d subspaces for dimension %d", subspaces, dim)
+	}
+	if len(vectors) == 0 {
+		return nil, errors.New("pq: no training vectors")
+	}
+	for i, vec := range vectors {
+		if len(vec) != dim {
+			return nil, fmt.Errorf("pq: training vector %d has dimension %d, want %d", i, len(vec), dim)
+		}
+	}
+
+	c := newCodebook(dim, subspaces, min(len(vectors), MaxCentroids))
+	rng := rand.New(rand.NewSource(1)) // Deterministic codebooks for the same data
+	for s := 0; s < subspaces; s++ {
+		c.data[s] = kmeans(vectors, c.bounds[s], c.bounds[s+1], c.centroids, rng)
+	}
+	return c, nil
+}
+
+// newCodebook allocates an untrained codebook
+func newCodebook(dim, subspaces, centroids int) *Codebook {
+	c := &Codebook{dim: dim, subspaces: subspaces, centroids: centroids, bounds: make([]int, subspaces+1), data: make([][]float32, subspaces)}
+	for s := 0; s <= subspaces; s++ {
+		c.bounds[s] = s * dim / subspaces
+	}
+	return c
+}
+
+// KMeans clusters vectors (all of the same dimension, at least k of them)
+// into k centroids and returns them as k*dimension values, e.g. to train IVF
+// centroids
+func KMeans(vectors [][]float32, k int, rng *rand.Rand) []float32 {
+	return kmeans(vectors, 0, len(vectors[0]), k, rng)
+}
+
+// kmeans clusters dimensions [lo, hi) of vectors into k centroids and returns
+// them as k*(hi-lo) values
+func kmeans(vectors [][]float32, lo, hi, k int, rng *rand.Rand) []float32 {
+	width := hi - lo
+	centroids := make([]float32, k*width)
+	for i, j := range rng.Perm(len(vectors))[:k] {
+		copy(centroids[i*width:], vectors[j][lo:hi])
+	}
+
+	assign := make([]int, len(vectors))
+	sums := make([]float64, k*width)
+	counts := make([]int, k)
+	for iter := 0; iter < trainIterations; iter++ {
+		changed := false
+		for i, vec := range vectors {
+			best := nearest(centroids, width, vec[lo:hi])
+			if best != assign[i] || iter == 0 {
+				changed = true
+			}
+			assign[i] = best
+		}
+		if !changed {
+			break
+		}
+
+		clear(sums)
+		clear(counts)
+		for i, vec := range vectors {
+			c := assign[i]
+			counts[c]++
+			for d, x := range vec[lo:hi] {
+				sums[c*width+d] += float64(x)
+			}
+		}
+		for c := 0; c < k; c++ {
+			if counts[c] == 0 {
+				// Reseed an empty cluster with a random vector
+				copy(centroids[c*width:(c+1)*width], vectors[rng.Intn(len(vectors))][lo:hi])
+				continue
+			}
+			for d := 0; d < width; d++ {
+				centroids[c*width+d] = float32(sums[c*width+d] / float64(counts[c]))
+			}
+		}
+	}
+	return centroids
+}
+
+// nearest returns the index of the centroid nearest to part
+func nearest(centroids []float32, width int, part []float32) int {
+	best, bestDist := 0, float32(math.MaxFloat32)
+	for c := 0; c*width < len(centroids); c++ {
+		var dist float32
+		for d, x := range part {
+			diff := x - centroids[c*width+d]
+			dist += diff * diff
+		}
+		if dist < bestDist {
+			best, bestDist = c, dist
+		}
+	}
+	return best
+}
+
+// Dimension returns the dimension of the vectors the codebook encodes
+func (c *Codebook) Dimension() int {
+	return c.dim
+}
+
+// Subspaces returns the number of subspaces, which is the code size in bytes
+func (c *Codebook) Subspaces() int {
+	return c.subspaces
+}
+
+// Encode writes the code of vec (of the codebook's dimension) to code, which
+// must hold Subspaces bytes
+func (c *Codebook) Encode(vec []float32, code []byte) {
+	for s := 0; s < c.subspaces; s++ {
+		lo, hi := c.bounds[s], c.bounds[s+1]
+		code[s] = byte(nearest(c.data[s], hi-lo, vec[lo:hi]))
+	}
+}
+
+// Table holds the squared distances from one query to every centroid of
+// every subspace, for scoring codes against that query
+type Table struct {
+	centroids int
+	dists     []float32 // dists[s*centroids+c]
+}
+
+// Table precomputes the distances from query to the centroids
+func (c *Codebook) Table(query []float32) *Table {
+	t := &Table{centroids: c.centroids, dists: make([]float32, c.subspaces*c.centroids)}
+	for s := 0; s < c.subspaces; s++ {
+		lo, hi := c.bounds[s], c.bounds[s+1]
+		width := hi - lo
+		for k := 0; k < c.centroids; k++ {
+			var dist float32
+			for d, x := range query[lo:hi] {
+				diff := x - c.data[s][k*width+d]
+				dist += diff * diff
+			}
+			t.dists[s*c.centroids+k] = dist
+		}
+	}
+	return t
+}
+
+// Distance returns the approximate L2 distance from the query of the table
+// to the vector with code
+func (t *Table) Distance(code []byte) float32 {
+	var sum float32
+	for s, k := range code {
+		sum += t.dists[s*t.centroids+int(k)]
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+// WriteTo writes the codebook in its binary format: magic, version, dimension,
+// subspaces, centroids per subspace, then the centroid values of every
+// subspace in order
+func (c *Codebook) WriteTo(w io.Writer) (int64, error) {
+	header := []any{codebookMagic, codebookVersion, uint32(c.dim), uint32(c.subspaces), uint32(c.centroids)}
+	var n int64
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return n, fmt.Errorf("failed to write codebook header: %w", err)
+		}
+		n += int64(binary.Size(field))
+	}
+	for s, centroids := range c.data {
+		if err := binary.Write(w, binary.LittleEndian, centroids); err != nil {
+			return n, fmt.Errorf("failed to write centroids of subspace %d: %w", s, err)
+		}
+		n += int64(4 * len(centroids))
+	}
+	return n, nil
+}
+
+// Read reads a codebook written by WriteTo
+func Read(r io.Reader) (*Codebook, error) {
+	var magic uint32
+	var version uint16
+	var dim, subspaces, centroids uint32
+	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
+		return nil, fmt.Errorf("failed to read codebook magic: %w", err)
+	}
+	if magic != codebookMagic {
+		return nil, errors.New("invalid codebook: magic number mismatch")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("failed to read codebook version: %w", err)
+	}
+	if version != codebookVersion {
+		return nil, fmt.Errorf("unsupported codebook version: %d", version)
+	}
+	for _, field := range []*uint32{&dim, &subspaces, &centroids} {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("failed to read codebook header: %w", err)
+		}
+	}
+	if dim == 0 || subspaces == 0 || subspaces > dim || centroids == 0 || centroids > MaxCentroids {
+		return nil, fmt.Errorf("invalid codebook: dimension %d, %d subspaces, %d centroids", dim, subspaces, centroids)
+	}
+
+	c := newCodebook(int(dim), int(subspaces), int(centroids))
+	for s := range c.data {
+		c.data[s] = make([]float32, c.centroids*(c.bounds[s+1]-c.bounds[s]))
+		if err := binary.Read(r, binary.LittleEndian, c.data[s]); err != nil {
+			return nil, fmt.Errorf("failed to read centroids of subspace %d: %w", s, err)
+		}
+	}
+	return c, nil
+}
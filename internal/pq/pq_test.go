@@ -0,0 +1,143 @@
+package pq
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomVectors returns n random vectors of dimension dim
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for d := range vectors[i] {
+			vectors[i][d] = rng.Float32()
+		}
+	}
+	return vectors
+}
+
+// l2 is the exact L2 distance
+func l2(a, b []float32) float32 {
+	var sum float64
+	for i := range a {
+		diff := float64(a[i] - b[i])
+		sum += diff * diff
+	}
+	return float32(math.Sqrt(sum))
+}
+
+func TestCodebook_Distance(t *testing.T) {
+	vectors := randomVectors(2000, 16, 1)
+	codebook, err := Train(vectors, 16, 8)
+	if err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	if codebook.Subspaces() != 8 || codebook.Dimension() != 16 {
+		t.Fatalf("Expected 8 subspaces of dimension 16, got %d, %d", codebook.Subspaces(), codebook.Dimension())
+	}
+
+	query := randomVectors(1, 16, 2)[0]
+	table := codebook.Table(query)
+	code := make([]byte, 8)
+	var errSum, distSum float64
+	for _, vec := range vectors[:200] {
+		codebook.Encode(vec, code)
+		exact := l2(query, vec)
+		errSum += math.Abs(float64(table.Distance(code) - exact))
+		distSum += float64(exact)
+	}
+	if rel := errSum / distSum; rel > 0.15 {
+		t.Errorf("Expected PQ distances within 15%% of exact on average, got %.1f%%", rel*100)
+	}
+}
+
+func TestCodebook_UnevenSubspaces(t *testing.T) {
+	// 10 dimensions over 3 subspaces; fewer vectors than centroids
+	vectors := randomVectors(50, 10, 3)
+	codebook, err := Train(vectors, 10, 3)
+	if err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	code := make([]byte, 3)
+	codebook.Encode(vectors[7], code)
+	// With one centroid per training vector, training vectors encode exactly
+	if dist := codebook.Table(vectors[7]).Distance(code); dist > 1e-3 {
+		t.Errorf("Expected a training vector to encode exactly, got distance %v", dist)
+	}
+}
+
+func TestCodebook_RoundTrip(t *testing.T) {
+	vectors := randomVectors(300, 12, 4)
+	codebook, err := Train(vectors, 12, 4)
+	if err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	var buf bytes.Buffer
+	n, err := codebook.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo failed: %d bytes of %d, %v", n, buf.Len(), err)
+	}
+	loaded, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	a, b := make([]byte, 4), make([]byte, 4)
+	for _, vec := range vectors[:20] {
+		codebook.Encode(vec, a)
+		loaded.Encode(vec, b)
+		if !bytes.Equal(a, b) {
+			t.Fatalf("Expected the same codes after reload, got %v and %v", a, b)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("garbage!"))); err == nil {
+		t.Error("Expected an invalid codebook to fail")
+	}
+}
+
+func TestTrain_Invalid(t *testing.T) {
+	if _, err := Train(nil, 4, 2); err == nil {
+		t.Error("Expected training without vectors to fail")
+	}
+	if _, err := Train(randomVectors(10, 4, 5), 4, 5); err == nil {
+		t.Error("Expected more subspaces than dimensions to fail")
+	}
+	if _, err := Train([][]float32{{1, 2, 3}}, 4, 2); err == nil {
+		t.Error("Expected a dimension mismatch to fail")
+	}
+}
+
+func TestTrainIDs(t *testing.T) {
+	vectors := randomVectors(300, 8, 4)
+	ids := make([]uint64, len(vectors))
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+	}
+	// Every 10th vector was deleted since the snapshot
+	read := func(id uint64) ([]float32, error) {
+		if id%10 == 0 {
+			return nil, nil
+		}
+		return vectors[id-1], nil
+	}
+	codebook, codes, trainedOn, err := TrainIDs(ids, read, 8, 4, 100)
+	if err != nil {
+		t.Fatalf("TrainIDs failed: %v", err)
+	}
+	if trainedOn != 90 || len(codes) != len(ids)*4 {
+		t.Fatalf("Expected 90 training vectors and %d code bytes, got %d, %d", len(ids)*4, trainedOn, len(codes))
+	}
+	want := make([]byte, 4)
+	codebook.Encode(vectors[6], want)
+	if !bytes.Equal(codes[6*4:7*4], want) {
+		t.Errorf("Expected the code of vector 7 at its position, got %v, want %v", codes[6*4:7*4], want)
+	}
+	if !bytes.Equal(codes[9*4:10*4], make([]byte, 4)) {
+		t.Errorf("Expected a zero code for a deleted vector, got %v", codes[9*4:10*4])
+	}
+}
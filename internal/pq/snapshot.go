@@ -0,0 +1,42 @@
+package pq
+
+import "fmt"
+
+// Reader returns the stored vector of id, nil if it was deleted
+type Reader func(id uint64) ([]float32, error)
+
+// TrainIDs trains a codebook on an evenly spaced sample of up to sampleSize
+// of the vectors of ids, then encodes every one of them
+// It holds no lock of its own: indexes snapshot their IDs, train with their
+// lock released and swap the result in. Codes are laid out in the order of
+// ids, subspaces bytes each; vectors deleted since the snapshot are skipped
+// and keep a zero code
+func TrainIDs(ids []uint64, read Reader, dim, subspaces, sampleSize int) (codebook *Codebook, codes []byte, trainedOn int, err error) {
+	step := max(len(ids)/sampleSize, 1)
+	sample := make([][]float32, 0, min(len(ids), sampleSize))
+	for i := 0; i < len(ids) && len(sample) < sampleSize; i += step {
+		vec, err := read(ids[i])
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to read vector %d for PQ training: %w", ids[i], err)
+		}
+		if vec != nil {
+			sample = append(sample, vec)
+		}
+	}
+	codebook, err = Train(sample, dim, subspaces)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	codes = make([]byte, len(ids)*subspaces)
+	for i, id := range ids {
+		vec, err := read(id)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to read vector %d for PQ encoding: %w", id, err)
+		}
+		if vec != nil {
+			codebook.Encode(vec, codes[i*subspaces:(i+1)*subspaces])
+		}
+	}
+	return codebook, codes, len(sample), nil
+}
@@ -0,0 +1,126 @@
+// Package profile records what a single search did: cheap counters that are
+// always maintained, and per-stage timings for searches picked for sampling
+package profile
+
+import (
+	"context"
+	"time"
+)
+
+// Counters are the work counters of one search
+type Counters struct {
+	Distances  uint64 // Distance computations
+	Candidates uint64 // Stored vectors scored as candidates (IVF centroids are not counted)
+	Reads      uint64 // Vector reads from storage, including cache hits
+	CacheHits  uint64 // Reads served by the storage cache
+	BytesRead  uint64 // Bytes read from the data file by reads the cache missed
+}
+
+// Add adds c2 to c
+func (c *Counters) Add(c2 Counters) {
+	c.Distances += c2.Distances
+	c.Candidates += c2.Candidates
+	c.Reads += c2.Reads
+	c.CacheHits += c2.CacheHits
+	c.BytesRead += c2.BytesRead
+}
+
+// Stage is the time spent in one stage of a search
+type Stage struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Profile collects the counters and, if timed, the stage timings of one search
+// A Profile belongs to a single search and is not safe for concurrent use;
+// all methods are no-ops on a nil *Profile so untracked callers need no checks
+type Profile struct {
+	Counters
+	timed  bool
+	stages []Stage
+}
+
+// New creates a profile; stage timings are only recorded if timed is set
+func New(timed bool) *Profile {
+	return &Profile{timed: timed}
+}
+
+// AddDistances counts n distance computations
+func (p *Profile) AddDistances(n int) {
+	if p != nil {
+		p.Distances += uint64(n)
+	}
+}
+
+// AddCandidates counts n stored vectors scored as candidates
+func (p *Profile) AddCandidates(n int) {
+	if p != nil {
+		p.Candidates += uint64(n)
+	}
+}
+
+// AddBytesRead counts n bytes read from disk
+func (p *Profile) AddBytesRead(n int64) {
+	if p != nil {
+		p.BytesRead += uint64(n)
+	}
+}
+
+// AddRead counts a vector read and whether the cache served it
+func (p *Profile) AddRead(cacheHit bool) {
+	if p == nil {
+		return
+	}
+	p.Reads++
+	if cacheHit {
+		p.CacheHits++
+	}
+}
+
+// Timed reports whether stage timings are recorded
+func (p *Profile) Timed() bool {
+	return p != nil && p.timed
+}
+
+// Start begins timing the named stage and returns the function ending it
+// Stages ending more than once under the same name accumulate their durations
+func (p *Profile) Start(name string) func() {
+	if !p.Timed() {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { p.record(name, time.Since(start)) }
+}
+
+// record adds d to the named stage
+func (p *Profile) record(name string, d time.Duration) {
+	for i := range p.stages {
+		if p.stages[i].Name == name {
+			p.stages[i].Duration += d
+			return
+		}
+	}
+	p.stages = append(p.stages, Stage{Name: name, Duration: d})
+}
+
+// Stages returns the recorded stages in the order they first ended
+func (p *Profile) Stages() []Stage {
+	if p == nil {
+		return nil
+	}
+	return append([]Stage(nil), p.stages...)
+}
+
+// contextKey is the context key of the profile
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p
+func NewContext(ctx context.Context, p *Profile) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the profile carried by ctx, or nil
+func FromContext(ctx context.Context) *Profile {
+	p, _ := ctx.Value(contextKey{}).(*Profile)
+	return p
+}
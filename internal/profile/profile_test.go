@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProfile_Counters(t *testing.T) {
+	p := New(false)
+	p.AddDistances(3)
+	p.AddRead(true)
+	p.AddRead(false)
+	p.AddCandidates(2)
+	p.AddBytesRead(48)
+
+	want := Counters{Distances: 3, Candidates: 2, Reads: 2, CacheHits: 1, BytesRead: 48}
+	if p.Counters != want {
+		t.Errorf("Expected %+v, got %+v", want, p.Counters)
+	}
+
+	var total Counters
+	total.Add(p.Counters)
+	total.Add(p.Counters)
+	if total.Distances != 6 || total.Reads != 4 || total.CacheHits != 2 || total.Candidates != 4 || total.BytesRead != 96 {
+		t.Errorf("Unexpected sum: %+v", total)
+	}
+}
+
+func TestProfile_Stages(t *testing.T) {
+	p := New(true)
+	end := p.Start("outer")
+	p.Start("inner")()
+	time.Sleep(time.Millisecond)
+	p.Start("inner")()
+	end()
+
+	stages := p.Stages()
+	if len(stages) != 2 || stages[0].Name != "inner" || stages[1].Name != "outer" {
+		t.Fatalf("Expected inner then outer, got %+v", stages)
+	}
+	if stages[1].Duration < time.Millisecond {
+		t.Errorf("Expected outer to last at least 1ms, got %v", stages[1].Duration)
+	}
+
+	untimed := New(false)
+	untimed.Start("stage")()
+	if len(untimed.Stages()) != 0 {
+		t.Error("Untimed profile should not record stages")
+	}
+}
+
+func TestProfile_Nil(t *testing.T) {
+	var p *Profile
+	p.AddDistances(1)
+	p.AddRead(true)
+	p.Start("stage")()
+	if p.Timed() || p.Stages() != nil {
+		t.Error("Nil profile should record nothing")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Error("Expected no profile in a plain context")
+	}
+	p := New(false)
+	if FromContext(NewContext(context.Background(), p)) != p {
+		t.Error("Expected the profile carried by the context")
+	}
+}
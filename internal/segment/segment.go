@@ -0,0 +1,296 @@
+// Package segment implements immutable cold segments: vectors scalar-quantized
+// to one byte per dimension in a single read-only file, searched by exact scan
+// over the quantized codes
+package segment
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/profile"
+)
+
+const (
+	segmentMagic   = uint32(0x564C5347) // "VLSG" in ASCII
+	segmentVersion = uint16(1)
+)
+
+// Segment is a loaded cold segment
+// Vectors are stored as codes: value = min[d] + code*scale[d], so each costs one
+// byte per dimension in memory instead of four
+// A Segment is immutable and safe for concurrent reads
+type Segment struct {
+	dim   int
+	min   []float32
+	scale []float32
+	ids   []uint64       // Ascending
+	codes []byte         // len(ids)*dim codes, in ids order
+	pos   map[uint64]int // ID -> position in ids
+}
+
+// Write quantizes vectors (all of dimension dim) into a new segment file at path
+// The file is written atomically (temp file + rename)
+func Write(path string, dim int, vectors map[uint64][]float32) (*Segment, error) {
+	if len(vectors) == 0 {
+		return nil, errors.New("segment must contain at least one vector")
+	}
+	s := &Segment{
+		dim:   dim,
+		min:   make([]float32, dim),
+		scale: make([]float32, dim),
+		ids:   make([]uint64, 0, len(vectors)),
+	}
+	for id, vec := range vectors {
+		if len(vec) != dim {
+			return nil, fmt.Errorf("vector %d: %w", id, types.ErrDimensionMismatch)
+		}
+		s.ids = append(s.ids, id)
+	}
+	sort.Slice(s.ids, func(i, j int) bool { return s.ids[i] < s.ids[j] })
+
+	// Per-dimension range
+	maxs := make([]float32, dim)
+	for d := 0; d < dim; d++ {
+		s.min[d], maxs[d] = float32(math.Inf(1)), float32(math.Inf(-1))
+	}
+	for _, vec := range vectors {
+		for d, x := range vec {
+			s.min[d] = min(s.min[d], x)
+			maxs[d] = max(maxs[d], x)
+		}
+	}
+	for d := 0; d < dim; d++ {
+		s.scale[d] = (maxs[d] - s.min[d]) / 255
+	}
+
+	s.codes = make([]byte, len(s.ids)*dim)
+	for i, id := range s.ids {
+		for d, x := range vectors[id] {
+			s.codes[i*dim+d] = s.quantize(d, x)
+		}
+	}
+	s.buildPositions()
+
+	if err := s.save(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// quantize returns the code of value x in dimension d
+func (s *Segment) quantize(d int, x float32) byte {
+	if s.scale[d] == 0 {
+		return 0
+	}
+	code := math.Round(float64((x - s.min[d]) / s.scale[d]))
+	return byte(min(max(code, 0), 255))
+}
+
+// buildPositions indexes the IDs
+func (s *Segment) buildPositions() {
+	s.pos = make(map[uint64]int, len(s.ids))
+	for i, id := range s.ids {
+		s.pos[id] = i
+	}
+}
+
+// save writes the segment file
+func (s *Segment) save(path string) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create segment file: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	header := []any{segmentMagic, segmentVersion, uint32(s.dim), uint64(len(s.ids)), s.min, s.scale}
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write segment header: %w", err)
+		}
+	}
+	for i, id := range s.ids {
+		if err := binary.Write(w, binary.LittleEndian, id); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write segment record: %w", err)
+		}
+		if _, err := w.Write(s.codes[i*s.dim : (i+1)*s.dim]); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write segment record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write segment file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync segment file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close segment file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename segment file: %w", err)
+	}
+	return nil
+}
+
+// Open loads the segment file at path, which must hold vectors of dimension dim
+func Open(path string, dim int) (*Segment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment file: %w", err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	var magic uint32
+	var version uint16
+	var fileDim uint32
+	var count uint64
+	for _, field := range []any{&magic, &version, &fileDim, &count} {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("failed to read segment header: %w", err)
+		}
+	}
+	if magic != segmentMagic {
+		return nil, errors.New("invalid segment file: magic number mismatch")
+	}
+	if version != segmentVersion {
+		return nil, fmt.Errorf("unsupported segment version %d", version)
+	}
+	if int(fileDim) != dim {
+		return nil, fmt.Errorf("segment dimension %d does not match configured dimension %d", fileDim, dim)
+	}
+	if info, err := file.Stat(); err == nil && count > uint64(info.Size())/uint64(8+dim) {
+		return nil, fmt.Errorf("invalid segment file: %d records do not fit in %d bytes", count, info.Size())
+	}
+
+	s := &Segment{
+		dim:   dim,
+		min:   make([]float32, dim),
+		scale: make([]float32, dim),
+		ids:   make([]uint64, count),
+		codes: make([]byte, int(count)*dim),
+	}
+	if err := binary.Read(r, binary.LittleEndian, s.min); err != nil {
+		return nil, fmt.Errorf("failed to read segment header: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, s.scale); err != nil {
+		return nil, fmt.Errorf("failed to read segment header: %w", err)
+	}
+	for i := range s.ids {
+		if err := binary.Read(r, binary.LittleEndian, &s.ids[i]); err != nil {
+			return nil, fmt.Errorf("failed to read segment record %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, s.codes[i*dim:(i+1)*dim]); err != nil {
+			return nil, fmt.Errorf("failed to read segment record %d: %w", i, err)
+		}
+	}
+	s.buildPositions()
+	return s, nil
+}
+
+// Len returns the number of vectors in the segment
+func (s *Segment) Len() int {
+	return len(s.ids)
+}
+
+// IDs returns the IDs in the segment in ascending order
+func (s *Segment) IDs() []uint64 {
+	return append([]uint64(nil), s.ids...)
+}
+
+// Contains reports whether id is in the segment
+func (s *Segment) Contains(id uint64) bool {
+	_, ok := s.pos[id]
+	return ok
+}
+
+// Vector returns the dequantized vector of id
+func (s *Segment) Vector(id uint64) ([]float32, error) {
+	i, ok := s.pos[id]
+	if !ok {
+		return nil, fmt.Errorf("vector with ID %d %w in segment", id, types.ErrNotFound)
+	}
+	vec := make([]float32, s.dim)
+	for d, code := range s.codes[i*s.dim : (i+1)*s.dim] {
+		vec[d] = s.min[d] + float32(code)*s.scale[d]
+	}
+	return vec, nil
+}
+
+// SizeBytes returns the size of the segment in memory
+func (s *Segment) SizeBytes() int64 {
+	return int64(len(s.codes) + len(s.ids)*8 + s.dim*8)
+}
+
+// Search returns the k vectors nearest to query among those accepted by keep
+// (nil = all), by L2 distance to their dequantized values
+// The scan stops and ctx.Err() is returned once ctx is done; distance
+// computations are counted in the profile of ctx
+func (s *Segment) Search(ctx context.Context, query []float32, k int, keep func(id uint64) bool) ([]types.SearchResult, error) {
+	if len(query) != s.dim {
+		return nil, types.ErrDimensionMismatch
+	}
+	if k <= 0 {
+		return nil, types.ErrInvalidK
+	}
+	p := profile.FromContext(ctx)
+
+	type candidate struct {
+		pos      int
+		distance float32
+	}
+	candidates := make([]candidate, 0, len(s.ids))
+	for i, id := range s.ids {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if keep != nil && !keep(id) {
+			continue
+		}
+		var sum float32
+		for d, code := range s.codes[i*s.dim : (i+1)*s.dim] {
+			diff := query[d] - (s.min[d] + float32(code)*s.scale[d])
+			sum += diff * diff
+		}
+		candidates = append(candidates, candidate{pos: i, distance: float32(math.Sqrt(float64(sum)))})
+	}
+	p.AddDistances(len(candidates))
+	p.AddCandidates(len(candidates))
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].pos < candidates[j].pos
+	})
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+	results := make([]types.SearchResult, k)
+	for i, c := range candidates[:k] {
+		id := s.ids[c.pos]
+		vec, _ := s.Vector(id) // Known ID
+		results[i] = types.SearchResult{ID: id, Distance: c.distance, Vector: vec}
+	}
+	return results, nil
+}
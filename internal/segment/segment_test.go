@@ -0,0 +1,139 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/index/types"
+	"github.com/monishSR/veclite/internal/profile"
+)
+
+// testVectors returns n vectors of dimension 4 with values in [0, n)
+func testVectors(n int) map[uint64][]float32 {
+	vectors := make(map[uint64][]float32, n)
+	for i := 1; i <= n; i++ {
+		vectors[uint64(i)] = []float32{float32(i), float32(n - i), 0.5, -float32(i) / 2}
+	}
+	return vectors
+}
+
+func TestWrite_Open(t *testing.T) {
+	path := t.TempDir() + "/test.seg"
+	vectors := testVectors(100)
+
+	written, err := Write(path, 4, vectors)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	loaded, err := Open(path, 4)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if loaded.Len() != 100 || !loaded.Contains(42) || loaded.Contains(101) {
+		t.Fatalf("Unexpected segment contents: %d vectors", loaded.Len())
+	}
+	if ids := loaded.IDs(); ids[0] != 1 || ids[99] != 100 {
+		t.Errorf("Expected ascending IDs, got %v...", ids[:3])
+	}
+
+	// Quantization error is at most half a step per dimension (range/255/2)
+	for _, seg := range []*Segment{written, loaded} {
+		for id, want := range vectors {
+			got, err := seg.Vector(id)
+			if err != nil {
+				t.Fatalf("Vector failed: %v", err)
+			}
+			for d := range want {
+				if math.Abs(float64(got[d]-want[d])) > 100.0/255/2+1e-4 {
+					t.Fatalf("Vector %d dim %d: got %v, want %v", id, d, got[d], want[d])
+				}
+			}
+		}
+	}
+	if _, err := loaded.Vector(999); !errors.Is(err, types.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestWrite_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Write(dir+"/empty.seg", 4, nil); err == nil {
+		t.Error("Expected an error for an empty segment")
+	}
+	if _, err := Write(dir+"/dim.seg", 4, map[uint64][]float32{1: {1, 2}}); !errors.Is(err, types.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Write(dir+"/test.seg", 4, testVectors(10)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := Open(dir+"/test.seg", 8); err == nil {
+		t.Error("Expected a dimension mismatch error")
+	}
+	if _, err := Open(dir+"/missing.seg", 4); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if err := os.WriteFile(dir+"/garbage.seg", []byte("garbage garbage garbage"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := Open(dir+"/garbage.seg", 4); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+
+	// Truncated records
+	data, err := os.ReadFile(dir + "/test.seg")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := os.WriteFile(dir+"/short.seg", data[:len(data)-6], 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := Open(dir+"/short.seg", 4); err == nil {
+		t.Error("Expected an error for a truncated file")
+	}
+}
+
+func TestSegment_Search(t *testing.T) {
+	seg, err := Write(t.TempDir()+"/test.seg", 4, testVectors(100))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	p := profile.New(false)
+	ctx := profile.NewContext(context.Background(), p)
+	query := []float32{50, 50, 0.5, -25}
+	results, err := seg.Search(ctx, query, 3, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != 50 {
+		t.Fatalf("Expected 3 results starting with 50, got %+v", results)
+	}
+	if p.Distances != 100 {
+		t.Errorf("Expected 100 distance computations, got %d", p.Distances)
+	}
+
+	odd := func(id uint64) bool { return id%2 == 1 }
+	results, err = seg.Search(context.Background(), query, 2, odd)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID%2 != 1 || results[1].ID%2 != 1 {
+		t.Errorf("Expected odd IDs, got %+v", results)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := seg.Search(ctx, query, 3, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := seg.Search(context.Background(), []float32{1}, 3, nil); !errors.Is(err, types.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+}
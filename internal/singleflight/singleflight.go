@@ -0,0 +1,55 @@
+package singleflight
+
+import "sync"
+
+// Group collapses concurrent calls with the same key into one execution
+// The zero value is ready to use
+type Group[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*call[V] // In-flight calls
+}
+
+// call is one in-flight execution shared by its callers
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which case
+// it waits for that call and returns its result
+// shared reports whether the result was handed to more than one caller; shared
+// values must then be treated as read-only or copied
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	if c, exists := g.calls[key]; exists {
+		g.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == c { // Unless forgotten and replaced by a newer call
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(c.done) // Also on panic, so waiters are not stuck forever
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
+
+// Forget makes later calls for key start a new execution instead of joining the
+// one in flight, e.g. because the value it is computing has just changed
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[uint64, int]
+	value, err, shared := g.Do(1, func() (int, error) { return 42, nil })
+	if value != 42 || err != nil || shared {
+		t.Errorf("Expected (42, nil, false), got (%d, %v, %v)", value, err, shared)
+	}
+
+	wantErr := errors.New("boom")
+	if _, err, _ := g.Do(1, func() (int, error) { return 0, wantErr }); !errors.Is(err, wantErr) {
+		t.Errorf("Expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestGroup_Do_Collapses(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	const callers = 10
+	var started, wg sync.WaitGroup
+	started.Add(callers)
+	wg.Add(callers)
+	results := make([]int, callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			results[i], _, _ = g.Do("key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 7, nil
+			})
+		}(i)
+	}
+	started.Wait()
+	time.Sleep(20 * time.Millisecond) // Let the callers pile up behind the first
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 execution, got %d", n)
+	}
+	for i, result := range results {
+		if result != 7 {
+			t.Errorf("Caller %d got %d, want 7", i, result)
+		}
+	}
+
+	// The key is released once the call finishes
+	if value, _, shared := g.Do("key", func() (int, error) { return 8, nil }); value != 8 || shared {
+		t.Errorf("Expected a fresh call after completion, got (%d, %v)", value, shared)
+	}
+}
+
+func TestGroup_Do_Panic(t *testing.T) {
+	var g Group[int, int]
+	func() {
+		defer func() { _ = recover() }()
+		g.Do(1, func() (int, error) { panic("boom") })
+	}()
+	if value, _, _ := g.Do(1, func() (int, error) { return 1, nil }); value != 1 {
+		t.Errorf("Expected key to be released after panic, got %d", value)
+	}
+}
+
+func TestGroup_Forget(t *testing.T) {
+	var g Group[int, int]
+	release := make(chan struct{})
+	done := make(chan int)
+	go func() {
+		value, _, _ := g.Do(1, func() (int, error) {
+			<-release
+			return 1, nil
+		})
+		done <- value
+	}()
+	for { // Wait until the first call is in flight
+		g.mu.Lock()
+		inFlight := len(g.calls) == 1
+		g.mu.Unlock()
+		if inFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	g.Forget(1)
+	if value, _, shared := g.Do(1, func() (int, error) { return 2, nil }); value != 2 || shared {
+		t.Errorf("Expected a new execution after Forget, got (%d, %v)", value, shared)
+	}
+	close(release)
+	if value := <-done; value != 1 {
+		t.Errorf("Forgotten call returned %d, want 1", value)
+	}
+}
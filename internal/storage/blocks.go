@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync/atomic"
+)
+
+// DefaultBlockSize is the number of records per block of EnableBlockBounds
+const DefaultBlockSize = 256
+
+// Block summarizes a run of consecutive vector records of the data file: the
+// live IDs among them and the per-dimension minimum and maximum of their
+// vectors, so a scan can bound the distance to any vector of the block
+// without reading it
+type Block struct {
+	IDs []uint64
+	Min []float32
+	Max []float32
+}
+
+// blockBounds tracks Blocks in record order
+// Deleted and superseded vectors leave the IDs of their block but not its
+// bounds, which stay valid (if looser) until compaction rebuilds them
+type blockBounds struct {
+	size     int                     // Records per block
+	blocks   []*Block                // In record order
+	fill     int                     // Records summarized by the last block, live or not
+	of       map[uint64]int          // Block of each live ID
+	snapshot atomic.Pointer[[]Block] // Copy returned by Blocks, dropped by add and remove (nil = not built)
+}
+
+// newBlockBounds creates empty bounds of size records per block
+func newBlockBounds(size int) *blockBounds {
+	return &blockBounds{size: size, of: make(map[uint64]int)}
+}
+
+// add summarizes the record of vector appended under id
+func (b *blockBounds) add(id uint64, vector []float32) {
+	b.remove(id)
+	b.snapshot.Store(nil)
+	if len(b.blocks) == 0 || b.fill == b.size {
+		b.blocks = append(b.blocks, &Block{Min: slices.Clone(vector), Max: slices.Clone(vector)})
+		b.fill = 0
+	}
+	last := b.blocks[len(b.blocks)-1]
+	last.IDs = append(last.IDs, id)
+	for i, value := range vector {
+		last.Min[i] = min(last.Min[i], value)
+		last.Max[i] = max(last.Max[i], value)
+	}
+	b.fill++
+	b.of[id] = len(b.blocks) - 1
+}
+
+// remove drops id from the IDs of its block
+func (b *blockBounds) remove(id uint64) {
+	i, ok := b.of[id]
+	if !ok {
+		return
+	}
+	block := b.blocks[i]
+	j := slices.Index(block.IDs, id)
+	block.IDs = slices.Delete(block.IDs, j, j+1)
+	delete(b.of, id)
+	b.snapshot.Store(nil)
+}
+
+// EnableBlockBounds maintains a Block summary per size records (<= 0 =
+// DefaultBlockSize) from now on, built from the live vectors by reading each
+// once; compaction rebuilds it with tight bounds
+func (s *Storage) EnableBlockBounds(size int) error {
+	if size <= 0 {
+		size = DefaultBlockSize
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("storage file not open")
+	}
+	bounds := newBlockBounds(size)
+	if err := s.buildBounds(bounds); err != nil {
+		return fmt.Errorf("failed to build block bounds: %w", err)
+	}
+	s.bounds = bounds
+	return nil
+}
+
+// buildBounds adds the live vectors to bounds in record order
+// Note: Assumes lock is already held
+func (s *Storage) buildBounds(bounds *blockBounds) error {
+	return s.forEachLive(func(id uint64, vector []float32) {
+		bounds.add(id, vector)
+	})
+}
+
+// forEachLive calls fn with every live vector in record order, reading each
+// once
+// Note: Assumes lock is already held
+func (s *Storage) forEachLive(fn func(id uint64, vector []float32)) error {
+	ids := make([]uint64, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return s.index[ids[i]] < s.index[ids[j]] })
+
+	s.advise(adviceSequential)
+	defer s.advise(adviceRandom)
+	for _, id := range ids {
+		if _, err := s.file.Seek(s.index[id], io.SeekStart); err != nil {
+			return err
+		}
+		h, err := readRecordHeader(s.file, s.dimension, s.legacy)
+		if err != nil {
+			return err
+		}
+		vector, err := readVectorPayload(s.file, h)
+		if err != nil {
+			return err
+		}
+		fn(id, vector)
+	}
+	return nil
+}
+
+// rebuildBounds replaces the block bounds, if enabled, with bounds built from
+// the live vectors, e.g. after RebuildIndex replaced the index
+// Note: Assumes lock is already held
+func (s *Storage) rebuildBounds() error {
+	if s.bounds == nil {
+		return nil
+	}
+	bounds := newBlockBounds(s.bounds.size)
+	if err := s.buildBounds(bounds); err != nil {
+		s.bounds = nil // Stale bounds could hide vectors from scans
+		return fmt.Errorf("failed to rebuild block bounds: %w", err)
+	}
+	s.bounds = bounds
+	return nil
+}
+
+// Blocks returns the block summaries in record order, covering every live
+// vector, or nil unless EnableBlockBounds was called
+// The summaries are an immutable snapshot, copied once after each write and
+// shared by all callers until the next one: they must not be modified
+func (s *Storage) Blocks() []Block {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.bounds == nil {
+		return nil
+	}
+	if snapshot := s.bounds.snapshot.Load(); snapshot != nil {
+		return *snapshot
+	}
+	blocks := make([]Block, 0, len(s.bounds.blocks))
+	for _, b := range s.bounds.blocks {
+		if len(b.IDs) == 0 {
+			continue
+		}
+		blocks = append(blocks, Block{IDs: slices.Clone(b.IDs), Min: slices.Clone(b.Min), Max: slices.Clone(b.Max)})
+	}
+	s.bounds.snapshot.Store(&blocks) // Concurrent readers may build equal copies; either one is kept
+	return blocks
+}
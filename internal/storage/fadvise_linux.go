@@ -0,0 +1,27 @@
+//go:build linux && (amd64 || arm64)
+
+package storage
+
+import "syscall"
+
+// Linux posix_fadvise advice values
+const (
+	adviceNormal     = 0 // POSIX_FADV_NORMAL
+	adviceRandom     = 1 // POSIX_FADV_RANDOM
+	adviceSequential = 2 // POSIX_FADV_SEQUENTIAL
+	adviceDontNeed   = 4 // POSIX_FADV_DONTNEED
+)
+
+// fadvise passes an access pattern hint for the whole file to the kernel
+// length 0 means "until the end of the file"; files without a descriptor are ignored
+func fadvise(f File, advice int) error {
+	fd, ok := f.(interface{ Fd() uintptr })
+	if !ok {
+		return nil
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, fd.Fd(), 0, 0, uintptr(advice), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
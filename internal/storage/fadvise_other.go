@@ -0,0 +1,16 @@
+//go:build !(linux && (amd64 || arm64))
+
+package storage
+
+// Advice values are accepted but ignored on platforms without posix_fadvise
+const (
+	adviceNormal     = 0
+	adviceRandom     = 1
+	adviceSequential = 2
+	adviceDontNeed   = 4
+)
+
+// fadvise is a no-op on platforms without posix_fadvise
+func fadvise(f File, advice int) error {
+	return nil
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+)
+
+const (
+	// pivotSample is the number of live vectors sampled to choose pivots from
+	pivotSample = 1024
+
+	// pivotSlack shrinks pivot bounds by this fraction of the distances they
+	// are computed from, so that rounding in the float32 distances they are
+	// compared with never prunes a vector that belongs in the results
+	pivotSlack = 1e-4
+)
+
+// pivotTable keeps the distances from every live vector to a few pivot
+// points, which bound the distance between a query and a vector by the
+// triangle inequality: d(q, v) >= |d(q, p) - d(v, p)| for every pivot p
+type pivotTable struct {
+	want     int                                  // Pivots to choose
+	sampled  int                                  // Live vectors the pivots were chosen among
+	pivots   [][]float32                          // Chosen among the stored vectors
+	dists    map[uint64][]float32                 // Distances from each live vector to the pivots
+	snapshot atomic.Pointer[map[uint64][]float32] // Copy of dists used by PivotQuery, dropped on every change (nil = not built)
+}
+
+// pivotDistance is the L2 distance from a to b in float64
+func pivotDistance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		diff := float64(a[i]) - float64(b[i])
+		sum += diff * diff
+	}
+	return math.Sqrt(sum)
+}
+
+// row returns the distances from vector to the pivots
+func (t *pivotTable) row(vector []float32) []float32 {
+	row := make([]float32, len(t.pivots))
+	for i, pivot := range t.pivots {
+		row[i] = float32(pivotDistance(vector, pivot))
+	}
+	return row
+}
+
+// EnablePivots keeps the distances from every vector to n pivots from now on,
+// for PivotQuery. The pivots are chosen far apart (farthest-first traversal)
+// among a sample of the stored vectors; if fewer than n vectors are stored,
+// they are chosen once more when the n-th is written. The distances cost 4*n
+// bytes per vector
+func (s *Storage) EnablePivots(n int) error {
+	if n <= 0 {
+		return errors.New("pivot count must be positive")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("storage file not open")
+	}
+	t := &pivotTable{want: n}
+	if err := s.buildPivots(t); err != nil {
+		return fmt.Errorf("failed to build pivot table: %w", err)
+	}
+	s.pivots = t
+	return nil
+}
+
+// buildPivots chooses the pivots of t among the live vectors and computes the
+// distances from every live vector to them
+// Note: Assumes lock is already held
+func (s *Storage) buildPivots(t *pivotTable) error {
+	// Reservoir sample of the live vectors in record order
+	var sample [][]float32
+	seen := 0
+	rng := rand.New(rand.NewSource(1))
+	err := s.forEachLive(func(id uint64, vector []float32) {
+		seen++
+		if len(sample) < pivotSample {
+			sample = append(sample, vector)
+		} else if j := rng.Intn(seen); j < pivotSample {
+			sample[j] = vector
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Farthest-first traversal from the first sampled vector, stopping early
+	// if only duplicates are left
+	t.pivots = nil
+	if len(sample) > 0 {
+		t.pivots = append(t.pivots, slices.Clone(sample[0]))
+		nearest := make([]float64, len(sample))
+		for i, vector := range sample {
+			nearest[i] = pivotDistance(vector, sample[0])
+		}
+		for len(t.pivots) < t.want {
+			farthest := 0
+			for i := range sample {
+				if nearest[i] > nearest[farthest] {
+					farthest = i
+				}
+			}
+			if nearest[farthest] == 0 {
+				break
+			}
+			pivot := slices.Clone(sample[farthest])
+			t.pivots = append(t.pivots, pivot)
+			for i, vector := range sample {
+				nearest[i] = min(nearest[i], pivotDistance(vector, pivot))
+			}
+		}
+	}
+	t.sampled = seen
+	t.snapshot.Store(nil)
+
+	t.dists = make(map[uint64][]float32, len(s.index))
+	return s.forEachLive(func(id uint64, vector []float32) {
+		t.dists[id] = t.row(vector)
+	})
+}
+
+// addPivotRow records the distances from vector, just written under id, to
+// the pivots
+// Note: Assumes lock is already held
+func (s *Storage) addPivotRow(id uint64, vector []float32) {
+	t := s.pivots
+	if t.sampled < t.want && len(s.index) >= t.want {
+		// The pivots were chosen among too few vectors; choose them once more
+		if err := s.buildPivots(t); err != nil {
+			s.pivots = nil // Bounds from missing rows would be wrong
+		}
+		return
+	}
+	t.dists[id] = t.row(vector)
+	t.snapshot.Store(nil)
+}
+
+// removePivotRow drops the distances from the vector deleted under id
+// Note: Assumes lock is already held
+func (s *Storage) removePivotRow(id uint64) {
+	delete(s.pivots.dists, id)
+	s.pivots.snapshot.Store(nil)
+}
+
+// rebuildPivots chooses the pivots again, if enabled, e.g. after RebuildIndex
+// replaced the index
+// Note: Assumes lock is already held
+func (s *Storage) rebuildPivots() error {
+	if s.pivots == nil {
+		return nil
+	}
+	if err := s.buildPivots(s.pivots); err != nil {
+		s.pivots = nil
+		return fmt.Errorf("failed to rebuild pivot table: %w", err)
+	}
+	return nil
+}
+
+// PivotQuery bounds the distances from a query to the stored vectors by the
+// pivots of EnablePivots
+// It reads an immutable snapshot of the pivot table taken when it was
+// created, so its bounds take no lock and ignore later writes
+type PivotQuery struct {
+	rows  map[uint64][]float32 // Snapshot of the distances from each vector to the pivots
+	dists []float64            // Distances from the query to the pivots
+}
+
+// PivotQuery returns the pivot bounds for query, or nil unless EnablePivots
+// was called and pivots could be chosen
+// The snapshot is copied once after each write and shared by all queries
+// until the next one
+func (s *Storage) PivotQuery(query []float32) *PivotQuery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := s.pivots
+	if t == nil || len(t.pivots) == 0 {
+		return nil
+	}
+	rows := t.snapshot.Load()
+	if rows == nil {
+		snapshot := maps.Clone(t.dists) // Rows are never modified, only replaced
+		rows = &snapshot
+		t.snapshot.Store(rows) // Concurrent readers may build equal copies; either one is kept
+	}
+	q := &PivotQuery{rows: *rows, dists: make([]float64, len(t.pivots))}
+	for i, pivot := range t.pivots {
+		q.dists[i] = pivotDistance(query, pivot)
+	}
+	return q
+}
+
+// LowerBound returns a lower bound of the L2 distance from the query to the
+// vector stored under id, false if it has none, e.g. because it was written
+// after the PivotQuery was created
+// A vector whose bound exceeds a distance computed with vector.L2Distance is
+// farther than that distance
+func (q *PivotQuery) LowerBound(id uint64) (float32, bool) {
+	row, ok := q.rows[id]
+	if !ok {
+		return 0, false
+	}
+	var bound float64
+	for i, dist := range row {
+		d := float64(dist)
+		bound = max(bound, math.Abs(q.dists[i]-d)-pivotSlack*(q.dists[i]+d))
+	}
+	return float32(bound), true
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// rebuildChunkBytes is the smallest range of the data section one rebuild
+// worker scans; smaller data sections are scanned by a single goroutine
+var rebuildChunkBytes int64 = 8 << 20
+
+// rebuildProgressBytes is how many scanned bytes a worker accumulates before
+// reporting progress
+const rebuildProgressBytes = 4 << 20
+
+// errNotFixedSize stops a parallel rebuild at a record that is not a vector
+// record of the storage dimension; range boundaries can't be computed then
+var errNotFixedSize = errors.New("data section has records of other sizes")
+
+// RebuildProgressFunc receives the bytes of the data section scanned so far
+// and the total while the index is rebuilt by scanning the data file
+type RebuildProgressFunc func(scanned, total int64)
+
+// SetRebuildWorkers sets how many goroutines rebuild the index when the
+// footer index is missing (0 = GOMAXPROCS, 1 = sequential)
+func (s *Storage) SetRebuildWorkers(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebuildWorkers = n
+}
+
+// SetRebuildProgress sets a callback for the progress of index rebuilds (nil = none)
+// Calls are serialized; the last one reports scanned == total
+func (s *Storage) SetRebuildProgress(fn RebuildProgressFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebuildProgress = fn
+}
+
+// rebuildProgress serializes progress reports of concurrent rebuild workers
+type rebuildProgress struct {
+	mu      sync.Mutex
+	fn      RebuildProgressFunc
+	scanned int64
+	total   int64
+}
+
+// add reports n more scanned bytes
+func (p *rebuildProgress) add(n int64) {
+	if p.fn == nil || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scanned = min(p.scanned+n, p.total)
+	p.fn(p.scanned, p.total)
+}
+
+// finish reports the whole data section as scanned
+func (p *rebuildProgress) finish() {
+	p.add(p.total - p.scanned)
+}
+
+// scanEntry is the last record of an ID within one scanned range
+type scanEntry struct {
+	offset  int64
+	deleted bool
+}
+
+// rangeScan is the result of scanning one range of the data section
+type rangeScan struct {
+	entries map[uint64]scanEntry
+	seq     uint64
+}
+
+// scanParallel builds the index from the whole records between start and
+// dataEnd using several goroutines, then scans the remaining tail like
+// scanDataSection. It returns false without changing the index if the file
+// can't be split into ranges (one worker, no io.ReaderAt, or records that
+// are not vector records of dimension), leaving the scan to scanDataSection
+// Note: Assumes lock is already held
+func (s *Storage) scanParallel(start, dataEnd int64, dimension int, legacy bool, progress *rebuildProgress) (bool, error) {
+	readerAt, ok := s.file.(io.ReaderAt)
+	if !ok || dataEnd <= start {
+		return false, nil
+	}
+	recordSize := int64(dimension) * 4
+	if legacy {
+		recordSize += 8
+	} else {
+		recordSize += s.recordHeaderLen()
+	}
+	pad := s.padding(recordSize)
+	recordSize += pad
+	records := (dataEnd - start) / recordSize
+
+	workers := s.rebuildWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = int(min(int64(workers), (dataEnd-start)/rebuildChunkBytes, records))
+	if workers < 2 {
+		return false, nil
+	}
+
+	perWorker := (records + int64(workers) - 1) / int64(workers)
+	results := make([]rangeScan, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from := start + int64(w)*perWorker*recordSize
+		to := start + min(int64(w+1)*perWorker, records)*recordSize
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w], errs[w] = scanRange(readerAt, from, to, recordSize, pad, dimension, legacy, progress)
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == errNotFixedSize {
+			return false, nil
+		}
+		if err != nil {
+			return true, err
+		}
+	}
+
+	// Later ranges supersede earlier ones, like later records in a sequential scan
+	for _, result := range results {
+		for id, entry := range result.entries {
+			switch {
+			case !entry.deleted:
+				s.index[id] = entry.offset
+			case !legacy:
+				delete(s.index, id)
+			}
+		}
+		s.seq = max(s.seq, result.seq)
+	}
+
+	// A partial record at the end is handled like the sequential scan does
+	tail := start + records*recordSize
+	if _, err := s.file.Seek(tail, io.SeekStart); err != nil {
+		return true, err
+	}
+	return true, s.scanDataSection(dataEnd, dimension, legacy)
+}
+
+// scanRange reads the whole records between from and to, each followed by pad
+// bytes of padding, keeping the last record of each ID
+func scanRange(readerAt io.ReaderAt, from, to, recordSize, pad int64, dimension int, legacy bool, progress *rebuildProgress) (rangeScan, error) {
+	result := rangeScan{entries: make(map[uint64]scanEntry, (to-from)/recordSize)}
+	reader := bufio.NewReaderSize(io.NewSectionReader(readerAt, from, to-from), 1<<20)
+	var unreported int64
+	for offset := from; offset < to; offset += recordSize {
+		h, err := readRecordHeader(reader, dimension, legacy)
+		if err == errBadRecord || (err == nil && (h.kind != recordVector || h.size(legacy)+pad != recordSize)) {
+			return result, errNotFixedSize
+		}
+		if err != nil {
+			return result, err
+		}
+		if _, err := reader.Discard(int(int64(h.length) + pad)); err != nil {
+			return result, err
+		}
+		result.entries[h.id] = scanEntry{offset: offset, deleted: h.deleted()}
+		result.seq = max(result.seq, h.seq)
+
+		if unreported += recordSize; unreported >= rebuildProgressBytes {
+			progress.add(unreported)
+			unreported = 0
+		}
+	}
+	progress.add(unreported)
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+// useSmallRebuildChunks lets tests with small files exercise parallel rebuilds
+func useSmallRebuildChunks(t *testing.T) {
+	t.Helper()
+	old := rebuildChunkBytes
+	rebuildChunkBytes = 64
+	t.Cleanup(func() { rebuildChunkBytes = old })
+}
+
+// writeRebuildFile opens storage with 300 writes of 120 IDs (so later records
+// supersede earlier ones) and every seventh ID deleted
+func writeRebuildFile(t *testing.T) (*Storage, string) {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		id := uint64(i%120 + 1)
+		if err := s.WriteVector(id, []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	for id := uint64(7); id <= 120; id += 7 {
+		if err := s.DeleteVector(id); err != nil {
+			t.Fatalf("DeleteVector failed: %v", err)
+		}
+	}
+	return s, tmpFile
+}
+
+// rebuildWith rebuilds the index of s with the given number of workers and
+// returns a copy of it
+func rebuildWith(t *testing.T, s *Storage, workers int) map[uint64]int64 {
+	t.Helper()
+	s.SetRebuildWorkers(workers)
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex with %d workers failed: %v", workers, err)
+	}
+	index := make(map[uint64]int64, len(s.index))
+	for id, offset := range s.index {
+		index[id] = offset
+	}
+	return index
+}
+
+// sameIndex reports whether two ID -> offset indexes are equal
+func sameIndex(a, b map[uint64]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, offset := range a {
+		if other, ok := b[id]; !ok || other != offset {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStorage_RebuildIndex_Parallel(t *testing.T) {
+	useSmallRebuildChunks(t)
+	s, tmpFile := writeRebuildFile(t)
+	defer os.Remove(tmpFile)
+	defer s.Close()
+
+	expected := make(map[uint64]int64, len(s.index))
+	for id, offset := range s.index {
+		expected[id] = offset
+	}
+	seq := s.Seq()
+
+	var calls, last, total int64
+	s.SetRebuildProgress(func(scanned, all int64) {
+		if scanned < last {
+			t.Errorf("Progress went backwards: %d after %d", scanned, last)
+		}
+		calls++
+		last, total = scanned, all
+	})
+	if index := rebuildWith(t, s, 4); !sameIndex(index, expected) {
+		t.Errorf("Parallel rebuild differs from the written index: %d vs %d entries", len(index), len(expected))
+	}
+	if s.Seq() != seq {
+		t.Errorf("Expected sequence number %d after rebuild, got %d", seq, s.Seq())
+	}
+	if calls == 0 || last != total || total != 300*42 {
+		t.Errorf("Expected progress to end at %d bytes, got %d/%d in %d calls", 300*42, last, total, calls)
+	}
+
+	if index := rebuildWith(t, s, 1); !sameIndex(index, expected) {
+		t.Errorf("Sequential rebuild differs from the written index")
+	}
+}
+
+func TestStorage_RebuildIndex_ParallelTornTail(t *testing.T) {
+	useSmallRebuildChunks(t)
+	s, tmpFile := writeRebuildFile(t)
+	defer os.Remove(tmpFile)
+	defer s.Close()
+
+	// Append a partial record, as left by a crash during a write
+	end, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if err := s.writeRecordHeader(s.file, recordHeader{id: 500, seq: 1000, kind: recordVector, length: 16}); err != nil {
+		t.Fatalf("writeRecordHeader failed: %v", err)
+	}
+	if err := s.file.Truncate(end + recordHeaderSize + 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	sequential := rebuildWith(t, s, 1)
+	if parallel := rebuildWith(t, s, 4); !sameIndex(parallel, sequential) {
+		t.Errorf("Parallel rebuild differs from sequential rebuild on a torn tail")
+	}
+}
+
+func TestStorage_RebuildIndex_ParallelFallback(t *testing.T) {
+	useSmallRebuildChunks(t)
+	s, tmpFile := writeRebuildFile(t)
+	defer os.Remove(tmpFile)
+	defer s.Close()
+
+	// A record of another size means ranges can't be split by arithmetic
+	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if err := s.writeRecordHeader(s.file, recordHeader{id: 1, kind: 200, length: 3}); err != nil {
+		t.Fatalf("writeRecordHeader failed: %v", err)
+	}
+	if _, err := s.file.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.WriteVector(121, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	expected := make(map[uint64]int64, len(s.index))
+	for id, offset := range s.index {
+		expected[id] = offset
+	}
+
+	if index := rebuildWith(t, s, 4); !sameIndex(index, expected) {
+		t.Errorf("Rebuild with a record of another type differs from the written index")
+	}
+}
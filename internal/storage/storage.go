@@ -1,29 +1,178 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"math"
 	"os"
+	"sort"
+	"strconv"
 	"sync"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/monishSR/veclite/internal/fileinfo"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/singleflight"
+	"github.com/monishSR/veclite/internal/throttle"
 )
 
 const (
 	indexMarker = uint32(0xDEADBEEF) // Magic number to mark start of index
-	deletedID   = ^uint64(0)         // Special ID to mark deleted vectors (tombstone) - all bits set (-1)
+
+	// Data files start with a header: magic, sequence number of the last write
+	// or delete (updated on Sync/Close) and the ID limit of NextID (updated when
+	// NextID reserves IDs). Records follow, each with a header: ID,
+	// flags, sequence number, type, payload length and, in version 5, a
+	// checksum. Deleting a record sets flagDeleted and its sequence number in
+	// place, so tombstones keep their ID and every ID is usable. Records of types a reader doesn't know are skipped
+	// by their length and kept by compaction
+	fileMagic        = uint64(0x334554494C434556) // "VECLITE3" in ASCII, little-endian
+	headerSize       = 24                         // Magic + sequence number + ID limit
+	recordHeaderSize = 22                         // ID + flags + sequence number + type + length
+	checksumSize     = 4                          // CRC32C following the record header if flagChecksum is set
+	flagDeleted      = byte(1)                    // Record is a tombstone
+	flagChecksum     = byte(2)                    // Record header is followed by a checksum (see recordChecksum)
+	recordVector     = byte(1)                    // Record type: payload is the float32 vector data
+	recordInfo       = byte(2)                    // Record type: payload is a file info block (see fileinfo), the first record
+
+	// Files without a header use the legacy layout: ID, vector data, with the
+	// ID overwritten by legacyDeletedID on delete. They keep that layout until
+	// the next compaction rewrites them
+	legacyDeletedID = ^uint64(0)
+
+	// Files with a version 2 header lack the ID limit and have untyped records:
+	// ID, flags, sequence number, vector data. Records gained their type and
+	// length in version 3; Open rewrites version 2 files in the current version
+	fileMagicV2       = uint64(0x324554494C434556) // "VECLITE2" in ASCII, little-endian
+	headerSizeV2      = 16                         // Magic + sequence number
+	untypedHeaderSize = 17                         // Record header of version 2 files: ID + flags + sequence number
+
+	// Files with a version 4 header have aligned records: the header records
+	// the alignment and is padded so that the payload of the first record
+	// starts on an alignment boundary, and every record is followed by zero
+	// padding up to a multiple of the alignment, so every payload is aligned
+	fileMagicV4  = uint64(0x344554494C434556) // "VECLITE4" in ASCII, little-endian
+	headerSizeV4 = 32                         // Magic + sequence number + ID limit + alignment
+
+	// Files with a version 5 header have checksummed records: the header has
+	// the layout of version 4, with alignment 1 for unaligned records, and
+	// every record sets flagChecksum. Files written from the start use it;
+	// files in older versions gain checksums when compaction rewrites them
+	fileMagicV5 = uint64(0x354554494C434556) // "VECLITE5" in ASCII, little-endian
+
+	// idReserve is how many IDs NextID reserves in the header at a time
+	idReserve = 1024
 )
 
+// MaxRecordAlignment is the largest record alignment, a typical page size
+const MaxRecordAlignment = 4096
+
+// errBadRecord marks a record whose header is invalid, e.g. garbage left where
+// the data section was expected to end; scans stop there like at a torn record
+var errBadRecord = errors.New("invalid record header")
+
+// ErrTornWrite is returned when a failed append left a partial record at the
+// end of the data file and it could not be removed; appends and footer writes
+// retry the removal and fail with it until it succeeds, so no record is ever
+// written after torn bytes
+var ErrTornWrite = errors.New("torn write")
+
+// QuarantineSuffix names the file next to the data file that receives torn
+// bytes found after the last whole record on open, e.g. left by a crash in
+// the middle of an append. Each range is appended as its offset (8 bytes),
+// its length (8 bytes) and the bytes
+const QuarantineSuffix = ".torn"
+
+// Tombstone is a deleted record still present in the data file
+type Tombstone struct {
+	ID  uint64 // ID of the deleted vector
+	Seq uint64 // Sequence number of the delete
+}
+
+// File is the subset of *os.File used by Storage for the data file
+// Alternative implementations (e.g. fault-injecting wrappers in tests) can be
+// supplied with SetOpenFile
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+	Truncate(size int64) error
+	Sync() error
+	Stat() (os.FileInfo, error)
+}
+
+// OpenFileFunc opens the data file, with the same contract as os.OpenFile
+type OpenFileFunc func(name string, flag int, perm os.FileMode) (File, error)
+
+// osOpenFile is the default OpenFileFunc backed by the real filesystem
+func osOpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err // Avoid returning a typed nil *os.File as a non-nil File
+	}
+	return f, nil
+}
+
 // Storage handles persistent storage of vectors and metadata
 type Storage struct {
 	mu          sync.RWMutex // Protects file I/O and index map
 	filePath    string
-	file        *os.File
+	file        File
+	openFile    OpenFileFunc                  // Opens the data file (default: os.OpenFile)
 	dimension   int                           // Vector dimension (stored in index metadata)
 	index       map[uint64]int64              // Index: ID -> file offset for fast lookups
+	sortedIDs   []uint64                      // Sorted view of the index keys for IDRange (nil = rebuild on next use)
 	vectorCache *lru.Cache[uint64, []float32] // LRU cache for vectors
+	footerSize  int64                         // Bytes of persisted index at the end of the file (0 if none)
+	ioHints     bool                          // Pass access-pattern hints (fadvise) to the kernel
+	layoutOrder []uint64                      // Preferred physical record order for the next compaction
+	throttle    *throttle.Throttle            // Paces compaction I/O (nil = unlimited)
+	seq         uint64                        // Sequence number of the last write or delete
+	legacy      bool                          // File uses the legacy headerless layout
+	headerLen   int64                         // Size of the header of the file (headerSize or padded headerSizeV4)
+	checksums   bool                          // Records written to the file are checksummed (version 5 header)
+	align       int64                         // Record alignment of the file (1 = unaligned)
+	alignment   int64                         // Record alignment of files written from the start (0 = unaligned)
+	fileInfo    bool                          // Files written from the start begin with a file info record (see SetFileInfo)
+	infoParams  []fileinfo.Param              // Extra parameters of the file info record
+	infoLen     int64                         // Size of the file info record after the header, with padding (0 if none)
+	nextID      uint64                        // Next ID NextID considers
+	idLimit     uint64                        // IDs below it may have been returned by NextID (recorded in the header)
+	deadRecords uint64                        // Records tombstoned or superseded since open (see DeadRecords)
+	torn        bool                          // A failed append left a partial record at tornAt that is not removed yet
+	tornAt      int64                         // Offset of the partial record if torn
+	recordsEnd  int64                         // End of the last whole record found by the last scan
+	quarantined int64                         // Torn bytes moved to the quarantine file since open
+	readOnly    bool                          // Opened by OpenReadOnly: the file is never changed
+	bounds      *blockBounds                  // Bounding boxes of blocks of records (nil = disabled, see EnableBlockBounds)
+	pivots      *pivotTable                   // Distances from every vector to pivot points (nil = disabled, see EnablePivots)
+
+	compactMu      sync.Mutex // Serializes Compact, which runs mostly without mu
+	generation     uint64     // Bumped whenever the file is rewritten or truncated in place
+	compactDeletes []uint64   // IDs deleted while Compact copies the file (nil = not copying)
+
+	rebuildWorkers  int                 // Goroutines scanning the file in rebuildIndex (0 = GOMAXPROCS)
+	rebuildProgress RebuildProgressFunc // Progress callback of rebuildIndex (nil = none)
+	compactProgress CompactProgressFunc // Progress callback of compact (nil = none)
+
+	reads singleflight.Group[uint64, []float32] // In-flight disk reads, see ReadVector
+}
+
+// Usage describes how the bytes of the data file are distributed
+type Usage struct {
+	FileSize    int64 // Total size of the data file
+	LiveBytes   int64 // Records reachable through the index
+	DeadBytes   int64 // Tombstoned or superseded records awaiting compaction
+	FooterBytes int64 // Persisted index (entries + metadata) at the end of the file
+	HeaderBytes int64 // Header and file info record at the start of the file
+	RecordBytes int64 // Size of one vector record
 }
 
 // NewStorage creates a new storage instance
@@ -53,29 +202,399 @@ func NewStorage(filePath string, dimension int, cacheCapacity int) (*Storage, er
 		dimension:   dimension,
 		index:       make(map[uint64]int64),
 		vectorCache: cache,
+		openFile:    osOpenFile,
 	}, nil
 }
 
+// SetRecordAlignment aligns the payload of every record to align bytes, which
+// must be a power of two up to MaxRecordAlignment (0 or 1 = unaligned)
+// Must be called before Open. It applies to new files and to files rewritten
+// by compaction; other files keep the alignment they were written with
+func (s *Storage) SetRecordAlignment(align int) error {
+	if align < 0 || align > MaxRecordAlignment || align&(align-1) != 0 {
+		return fmt.Errorf("record alignment %d is not a power of two up to %d", align, MaxRecordAlignment)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alignment = int64(align)
+	return nil
+}
+
+// SetFileInfo makes files written from the start, new or rewritten by
+// compaction, begin with a file info record: the creator, the creation time,
+// the dimension and record alignment and params
+// Must be called before Open. Readers that predate it skip the record
+func (s *Storage) SetFileInfo(params ...fileinfo.Param) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileInfo = true
+	s.infoParams = params
+}
+
+// SetOpenFile replaces the function used to open the data file
+// Must be called before Open; nil restores the default os.OpenFile
+func (s *Storage) SetOpenFile(fn OpenFileFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fn == nil {
+		fn = osOpenFile
+	}
+	s.openFile = fn
+}
+
 // Open opens the storage file and loads the index
 func (s *Storage) Open() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var err error
-	s.file, err = os.OpenFile(s.filePath, os.O_RDWR|os.O_CREATE, 0644)
+	openFile := s.openFile
+	if openFile == nil {
+		openFile = osOpenFile
+	}
+	file, err := openFile(s.filePath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
+	s.file = file
+
+	h, err := s.readHeader()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	s.seq, s.legacy, s.headerLen, s.align, s.checksums = h.seq, h.legacy, h.start, h.align, h.checksums
+	s.idLimit, s.nextID = h.idLimit, max(h.idLimit, 1)
+	s.infoLen = s.readInfoLen(h.start, h.legacy)
+
+	if h.start == headerSizeV2 {
+		err := s.upgradeV2()
+		s.advise(adviceRandom)
+		if err != nil {
+			return fmt.Errorf("failed to upgrade version 2 file: %w", err)
+		}
+		return nil
+	}
 
 	// Try to load index from end of file, fallback to rebuild if not found
-	if err := s.loadIndex(); err != nil {
+	err = s.loadIndex()
+	if err == nil {
+		err = s.dropTombstoned()
+	}
+	if err != nil {
 		// If index doesn't exist or is corrupted, rebuild it
-		return s.rebuildIndex()
+		s.advise(adviceSequential)
+		err := s.rebuildIndex()
+		s.advise(adviceRandom)
+		return err
+	}
+
+	s.advise(adviceRandom)
+	return nil
+}
+
+// SetIOHints enables or disables kernel access-pattern hints
+// When enabled, point reads are advised as random and full-file scans
+// (rebuild, ReadAllVectors, compaction) as sequential; pages written by
+// compaction are dropped afterwards so bulk work doesn't evict the page cache
+// entries serving live queries. Call before Open for the hints to cover startup.
+func (s *Storage) SetIOHints(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ioHints = enabled
+	if !enabled && s.file != nil {
+		_ = fadvise(s.file, adviceNormal)
+	}
+}
+
+// advise applies an access-pattern hint if hints are enabled
+// Hints are best effort - errors are ignored
+// Note: Assumes lock is already held
+func (s *Storage) advise(advice int) {
+	if !s.ioHints || s.file == nil {
+		return
+	}
+	_ = fadvise(s.file, advice)
+}
+
+// fileHeader is the decoded header of a data file
+type fileHeader struct {
+	start     int64  // Offset of the first record (the size of the header)
+	legacy    bool   // File uses the legacy headerless layout
+	seq       uint64 // Sequence number recorded in the header
+	idLimit   uint64 // ID limit recorded in the header (0 for version 2)
+	align     int64  // Record alignment (1 = unaligned)
+	checksums bool   // Records are checksummed (version 5)
+}
+
+// readHeader returns the header of the file
+// An empty file has no header yet; it is written with the first record, so
+// the header returned is the one of a new file
+// Note: Assumes lock is already held
+func (s *Storage) readHeader() (fileHeader, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fileHeader{}, err
+	}
+	var header [headerSizeV4]byte
+	n, err := io.ReadFull(s.file, header[:])
+	if n == 0 && (err == io.EOF || err == nil) {
+		return s.newHeader(), nil
+	}
+	switch magic := binary.LittleEndian.Uint64(header[:]); {
+	case n >= headerSize && magic == fileMagic:
+		return fileHeader{
+			start:   headerSize,
+			seq:     binary.LittleEndian.Uint64(header[8:]),
+			idLimit: binary.LittleEndian.Uint64(header[16:]),
+			align:   1,
+		}, nil
+	case n >= headerSizeV4 && (magic == fileMagicV4 || magic == fileMagicV5):
+		align, checksums := int64(binary.LittleEndian.Uint64(header[24:])), magic == fileMagicV5
+		if align < 1 || (align < 2 && !checksums) || align > MaxRecordAlignment || align&(align-1) != 0 {
+			return fileHeader{}, fmt.Errorf("invalid record alignment %d", align)
+		}
+		recordHeaderLen := int64(recordHeaderSize)
+		if checksums {
+			recordHeaderLen += checksumSize
+		}
+		return fileHeader{
+			start:     alignedStart(align, recordHeaderLen),
+			seq:       binary.LittleEndian.Uint64(header[8:]),
+			idLimit:   binary.LittleEndian.Uint64(header[16:]),
+			align:     align,
+			checksums: checksums,
+		}, nil
+	case n >= headerSizeV2 && magic == fileMagicV2:
+		return fileHeader{start: headerSizeV2, seq: binary.LittleEndian.Uint64(header[8:]), align: 1}, nil
+	}
+	return fileHeader{legacy: true, align: 1}, nil
+}
+
+// newHeader returns the header of a file written from the start: the current
+// version with the configured record alignment
+func (s *Storage) newHeader() fileHeader {
+	align := max(s.alignment, 1)
+	return fileHeader{start: alignedStart(align, recordHeaderSize+checksumSize), align: align, checksums: true}
+}
+
+// resetLayout switches to the layout of newHeader, for a file that is
+// written from the start
+// Note: Assumes lock is already held
+func (s *Storage) resetLayout() {
+	h := s.newHeader()
+	s.legacy, s.headerLen, s.align, s.checksums = false, h.start, h.align, h.checksums
+}
+
+// alignedStart returns the offset of the first record of a file with a
+// version 4 or 5 header, the header padded so the payload of a record with a
+// header of recordHeaderLen bytes is aligned
+func alignedStart(align, recordHeaderLen int64) int64 {
+	return (headerSizeV4+recordHeaderLen+align-1)/align*align - recordHeaderLen
+}
+
+// padding returns the zero bytes following a record of size bytes, which
+// align the next record
+// Note: Assumes lock is already held
+func (s *Storage) padding(size int64) int64 {
+	if s.align <= 1 {
+		return 0
+	}
+	return (s.align - size%s.align) % s.align
+}
+
+// writePadding writes the padding following a record of size bytes
+// Note: Assumes lock is already held
+func (s *Storage) writePadding(w io.Writer, size int64) error {
+	if pad := s.padding(size); pad > 0 {
+		if _, err := w.Write(make([]byte, pad)); err != nil {
+			return fmt.Errorf("failed to write record padding: %w", err)
+		}
 	}
+	return nil
+}
 
+// writeHeader writes the header with the current sequence number and ID limit
+// at the start of the file, in the version the file has (3, 4 or 5), leaving
+// the file offset after it
+// Note: Assumes lock is already held
+func (s *Storage) writeHeader() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, max(s.headerLen, headerSize))
+	switch {
+	case s.checksums, s.align > 1:
+		// The padding up to the first record is written with the header
+		binary.LittleEndian.PutUint64(header, fileMagicV4)
+		if s.checksums {
+			binary.LittleEndian.PutUint64(header, fileMagicV5)
+		}
+		binary.LittleEndian.PutUint64(header[16:], s.idLimit)
+		binary.LittleEndian.PutUint64(header[24:], uint64(s.align))
+	default:
+		header = header[:headerSize]
+		binary.LittleEndian.PutUint64(header, fileMagic)
+		binary.LittleEndian.PutUint64(header[16:], s.idLimit)
+	}
+	binary.LittleEndian.PutUint64(header[8:], s.seq)
+	if _, err := s.file.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
 	return nil
 }
 
+// writeFileStart writes the header of a file written from the start and, if
+// enabled, the file info record, leaving the file offset after them
+// It returns the offset of the first vector record
+// Note: Assumes lock is already held
+func (s *Storage) writeFileStart() (int64, error) {
+	s.resetLayout()
+	s.infoLen = 0
+	if err := s.writeHeader(); err != nil {
+		return 0, err
+	}
+	if !s.fileInfo {
+		return s.headerLen, nil
+	}
+	params := append([]fileinfo.Param{
+		{Name: "dimension", Value: strconv.Itoa(s.dimension)},
+		{Name: "alignment", Value: strconv.FormatInt(s.align, 10)},
+	}, s.infoParams...)
+	payload := fileinfo.New(params...).Marshal()
+	h := s.checksum(recordHeader{kind: recordInfo, length: uint32(len(payload))}, payload)
+	if err := s.writeRecordHeader(s.file, h); err != nil {
+		return 0, err
+	}
+	if _, err := s.file.Write(payload); err != nil {
+		return 0, fmt.Errorf("failed to write file info: %w", err)
+	}
+	if err := s.writePadding(s.file, h.size(false)); err != nil {
+		return 0, err
+	}
+	s.infoLen = h.size(false) + s.padding(h.size(false))
+	return s.headerLen + s.infoLen, nil
+}
+
+// readInfoLen returns the size, with padding, of the file info record if it
+// is the first record at start, or 0, leaving the file offset unchanged
+// Note: Assumes lock is already held
+func (s *Storage) readInfoLen(start int64, legacy bool) int64 {
+	if legacy {
+		return 0
+	}
+	pos, err := s.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	defer s.file.Seek(pos, io.SeekStart)
+	if _, err := s.file.Seek(start, io.SeekStart); err != nil {
+		return 0
+	}
+	h, err := readRecordHeader(s.file, s.dimension, false)
+	if err != nil || h.kind != recordInfo || h.length > fileinfo.MaxSize {
+		return 0
+	}
+	return h.size(false) + s.padding(h.size(false))
+}
+
+// DataHeaderFields documents the fixed fields of the data file header; version
+// 2 headers have the first two, version 3 the first three
+var DataHeaderFields = []fileinfo.Field{
+	{Name: "magic", Doc: "uint64 \"VECLITE2\" to \"VECLITE5\" (version 4: aligned records, version 5: checksummed records)"},
+	{Name: "seq", Doc: "uint64 sequence number of the last write or delete when the file was synced"},
+	{Name: "id_limit", Doc: "uint64 IDs below it may have been handed out by NextID"},
+	{Name: "alignment", Doc: "uint64 record payload alignment in bytes, zero padding up to the first record"},
+}
+
+// ReadHeader reads the header of the data file at path and its file info
+// record, if the first record is one
+// Files without a header (the legacy layout) are reported as version 0
+func ReadHeader(path string) (*fileinfo.Header, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err != nil {
+		return nil, err
+	} else if info.Size() == 0 {
+		return nil, errors.New("empty data file")
+	}
+
+	s := &Storage{filePath: path, file: file}
+	h, err := s.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	header := &fileinfo.Header{Format: "data"}
+	values := []string{"", strconv.FormatUint(h.seq, 10), strconv.FormatUint(h.idLimit, 10), strconv.FormatInt(h.align, 10)}
+	switch {
+	case h.legacy:
+		return header, nil
+	case h.start == headerSizeV2:
+		header.Version, values[0] = 2, "VECLITE2"
+	case h.checksums:
+		header.Version, values[0] = 5, "VECLITE5"
+	case h.align > 1:
+		header.Version, values[0] = 4, "VECLITE4"
+	default:
+		header.Version, values[0] = 3, "VECLITE3"
+	}
+	header.Fields = fileinfo.Fields(DataHeaderFields[:min(max(header.Version, 2), len(DataHeaderFields))], values...)
+
+	if s.readInfoLen(h.start, false) > 0 {
+		if _, err := file.Seek(h.start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		rec, err := s.readRecord(file, false, fileinfo.MaxSize+recordHeaderSize+checksumSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file info record: %w", err)
+		}
+		if header.Info, err = fileinfo.Unmarshal(rec.payload); err != nil {
+			return nil, fmt.Errorf("invalid file info record: %w", err)
+		}
+	}
+	return header, nil
+}
+
+// NextID returns an ID that no stored vector uses and NextID never returned
+// before, counting up from 1
+// IDs are reserved idReserve at a time: the ID limit is recorded in the header
+// and synced before an ID below it is returned, so reopening after a crash may
+// skip IDs but never returns one twice. A file with an older header is
+// compacted first to gain the ID limit
+func (s *Storage) NextID() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return 0, errors.New("storage file not open")
+	}
+	if s.legacy {
+		if err := s.compact(); err != nil {
+			return 0, fmt.Errorf("failed to upgrade header: %w", err)
+		}
+	}
+
+	for {
+		if s.nextID >= s.idLimit {
+			limit := s.idLimit
+			s.idLimit = s.nextID + idReserve
+			if err := s.writeHeader(); err != nil {
+				s.idLimit = limit
+				return 0, err
+			}
+			if err := s.file.Sync(); err != nil {
+				s.idLimit = limit
+				return 0, fmt.Errorf("failed to sync header: %w", err)
+			}
+		}
+		id := s.nextID
+		s.nextID++
+		if _, used := s.index[id]; !used {
+			return id, nil
+		}
+	}
+}
+
 // loadIndex reads the index from the end of the file
 // Note: Assumes lock is already held (called from Open)
 func (s *Storage) loadIndex() error {
@@ -143,7 +662,7 @@ func (s *Storage) loadIndex() error {
 	// Calculate index start position
 	// Each entry: 8 bytes (ID) + 8 bytes (offset) = 16 bytes
 	// Metadata: 4 bytes (dimension) + 4 bytes (count) + 4 bytes (marker) = 12 bytes
-	indexSize := int64(count * 16)
+	indexSize := int64(count) * 16
 	indexStart := fileSize - 12 - indexSize // 12 bytes for dimension + count + marker
 
 	if indexStart < 0 {
@@ -157,6 +676,7 @@ func (s *Storage) loadIndex() error {
 
 	// Read index entries
 	s.index = make(map[uint64]int64)
+	s.sortedIDs = nil
 	for i := uint32(0); i < count; i++ {
 		var id uint64
 		var offset int64
@@ -166,9 +686,34 @@ func (s *Storage) loadIndex() error {
 		if err := binary.Read(s.file, binary.LittleEndian, &offset); err != nil {
 			return err
 		}
+		if offset < s.headerLen || offset >= indexStart { // Records lie between header and footer
+			return fmt.Errorf("index entry %d: offset %d out of range", id, offset)
+		}
 		s.index[id] = offset
 	}
 
+	s.footerSize = fileSize - indexStart
+	return nil
+}
+
+// dropTombstoned removes footer entries whose record is flagged deleted
+// A delete drops the footer before flagging the record, but the truncation
+// may not reach the disk before a crash while the flag does, so the footer
+// can list tombstones
+// Note: Assumes lock is already held (called from Open)
+func (s *Storage) dropTombstoned() error {
+	for id, offset := range s.index {
+		if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		h, err := readRecordHeader(s.file, s.dimension, s.legacy)
+		if err != nil {
+			return fmt.Errorf("index entry %d: %w", id, err)
+		}
+		if h.deleted() {
+			delete(s.index, id)
+		}
+	}
 	return nil
 }
 
@@ -178,6 +723,9 @@ func (s *Storage) saveIndex() error {
 	if s.file == nil {
 		return errors.New("storage file not open")
 	}
+	if err := s.repairTorn(); err != nil {
+		return err
+	}
 
 	// Check if there's an existing index and truncate before it
 	fileInfo, err := s.file.Stat()
@@ -200,18 +748,33 @@ func (s *Storage) saveIndex() error {
 		}
 	}
 
+	// Record the current sequence number in the header
+	if h, err := s.readHeader(); err != nil {
+		return err
+	} else if !h.legacy {
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+
 	// Seek to end of data
 	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
 		return err
 	}
 
-	// Write index entries
+	// Write index entries in ID order, so a file with the same records always
+	// gets the same footer (snapshots compare and delta-transfer by content)
 	count := uint32(len(s.index))
-	for id, offset := range s.index {
+	ids := make([]uint64, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
 		if err := binary.Write(s.file, binary.LittleEndian, id); err != nil {
 			return err
 		}
-		if err := binary.Write(s.file, binary.LittleEndian, offset); err != nil {
+		if err := binary.Write(s.file, binary.LittleEndian, s.index[id]); err != nil {
 			return err
 		}
 	}
@@ -227,6 +790,30 @@ func (s *Storage) saveIndex() error {
 		return err
 	}
 
+	s.footerSize = int64(count)*16 + 12
+	return nil
+}
+
+// truncateFooter drops a persisted index from the end of the file so that new
+// records are appended to the data section instead of after the footer.
+// The footer is rewritten by the next Sync/Close.
+// Note: Assumes lock is already held
+func (s *Storage) truncateFooter() error {
+	if s.footerSize == 0 {
+		return nil
+	}
+	fileSize, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	dataEnd := fileSize - s.footerSize
+	if dataEnd < 0 {
+		dataEnd = 0
+	}
+	if err := s.file.Truncate(dataEnd); err != nil {
+		return fmt.Errorf("failed to truncate index footer: %w", err)
+	}
+	s.footerSize = 0
 	return nil
 }
 
@@ -273,51 +860,66 @@ func (s *Storage) findDataEnd(fileSize int64) (int64, int, error) {
 	}
 
 	// New format: dimension + count + marker = 12 bytes
-	dimension := int(dim)
-	indexSize := int64(count * 16)
-	dataEnd := fileSize - 12 - indexSize
-	if dataEnd < 0 {
-		dataEnd = 0
+	// A count or dimension the file can't hold means the marker is garbage
+	// rather than a footer, so the whole file is scanned; the footer of a file
+	// without vectors records the configured dimension, which no record holds
+	indexSize := int64(count) * 16
+	if indexSize > fileSize-12 || (count > 0 && int64(dim)*4 > fileSize) {
+		return fileSize, s.dimension, nil
 	}
-
-	return dataEnd, dimension, nil
+	return fileSize - 12 - indexSize, int(dim), nil
 }
 
 // scanDataSection scans the file from current position to dataEnd and builds the index
-func (s *Storage) scanDataSection(dataEnd int64, dimension int) error {
+// legacy selects the headerless record layout
+func (s *Storage) scanDataSection(dataEnd int64, dimension int, legacy bool) error {
+	if s.file == nil {
+		return errors.New("storage file not open")
+	}
+
 	for {
 		// Get current offset (where this vector starts)
 		offset, err := s.file.Seek(0, io.SeekCurrent)
 		if err != nil {
 			return err
 		}
+		s.recordsEnd = min(offset, dataEnd)
 
 		// Stop if we've reached the end of data section
 		if offset >= dataEnd {
 			break
 		}
 
-		// Read ID
-		var id uint64
-		if err := binary.Read(s.file, binary.LittleEndian, &id); err != nil {
-			if err == io.EOF {
+		// Read record header; a record running past the data section is torn
+		h, err := readRecordHeader(s.file, dimension, legacy)
+		if err != nil {
+			if err == io.EOF || (!legacy && endOfRecords(err)) {
 				break
 			}
 			return err
 		}
+		size := h.size(legacy) + s.padding(h.size(legacy))
+		if offset+size > dataEnd {
+			break
+		}
+		s.seq = max(s.seq, h.seq)
 
-		// Skip vector data (dimension is in metadata, not per-record)
-		vectorSize := int64(dimension * 4) // float32 is 4 bytes
-		if _, err := s.file.Seek(vectorSize, io.SeekCurrent); err != nil {
+		// Skip the payload and padding
+		if _, err := s.file.Seek(offset+size, io.SeekStart); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
 
-		// Only index non-deleted vectors (skip tombstones)
-		if id != deletedID {
-			s.index[id] = offset
+		// Only index non-deleted vectors; a tombstone also retires any earlier
+		// record of its ID (legacy tombstones have lost their ID)
+		switch {
+		case h.kind != recordVector:
+		case !h.deleted():
+			s.index[h.id] = offset
+		case !legacy:
+			delete(s.index, h.id)
 		}
 	}
 
@@ -333,6 +935,8 @@ func (s *Storage) rebuildIndex() error {
 	}
 
 	s.index = make(map[uint64]int64)
+	s.sortedIDs = nil
+	s.generation++
 
 	// Get file size to know where data ends (before any existing index)
 	fileInfo, err := s.file.Stat()
@@ -360,23 +964,178 @@ func (s *Storage) rebuildIndex() error {
 		s.dimension = dimension // Update Storage's dimension if valid
 	}
 
-	// Seek to beginning and scan only the data portion
-	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+	s.footerSize = fileSize - dataEnd
+	s.recordsEnd = dataEnd
+
+	// Seek to the first record and scan only the data portion
+	h, err := s.readHeader()
+	if err != nil {
+		return err
+	}
+	start, legacy := h.start, h.legacy
+	s.seq = max(s.seq, h.seq)
+	if _, err := s.file.Seek(start, io.SeekStart); err != nil {
 		return err
 	}
 
-	// Scan through file and build index (stop at dataEnd)
+	// Scan through file and build index (stop at dataEnd), in parallel ranges
+	// when the file is large enough
 	// Use Storage's dimension to ensure we read vectors correctly even if metadata is corrupted
-	return s.scanDataSection(dataEnd, useDimension)
+	// A leading file info record is skipped so that the vector records behind
+	// it can still be split into ranges
+	progress := &rebuildProgress{fn: s.rebuildProgress, total: max(dataEnd-start, 0)}
+	s.infoLen = s.readInfoLen(start, legacy)
+	scanned, err := s.scanParallel(start+s.infoLen, dataEnd, useDimension, legacy, progress)
+	if err != nil {
+		return err
+	}
+	if !scanned {
+		if _, err := s.file.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if err := s.scanDataSection(dataEnd, useDimension, legacy); err != nil {
+			return err
+		}
+	}
+	progress.finish()
+
+	// Bytes after the last whole record, e.g. of an append torn by a crash,
+	// are moved aside so that appends continue from a record boundary
+	if s.recordsEnd < dataEnd && !s.readOnly {
+		if err := s.quarantine(max(s.recordsEnd, start), fileSize); err != nil {
+			return fmt.Errorf("failed to quarantine torn bytes: %w", err)
+		}
+	}
+	return nil
+}
+
+// quarantine moves the bytes from offset to fileSize, the end of the file,
+// to the quarantine file (see QuarantineSuffix) and truncates the file at offset
+// Note: Assumes lock is already held
+func (s *Storage) quarantine(offset, fileSize int64) error {
+	openFile := s.openFile
+	if openFile == nil {
+		openFile = osOpenFile
+	}
+	file, err := openFile(s.filePath+QuarantineSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	length := fileSize - offset
+	var head [16]byte
+	binary.LittleEndian.PutUint64(head[0:], uint64(offset))
+	binary.LittleEndian.PutUint64(head[8:], uint64(length))
+	if _, err := file.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(file, s.file, length); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil { // The bytes must be safe before they are dropped
+		return err
+	}
+	if err := s.file.Truncate(offset); err != nil {
+		return err
+	}
+	s.footerSize = 0
+	s.quarantined += length
+	return nil
+}
+
+// Quarantined returns the number of torn bytes moved to the quarantine file
+// since open
+func (s *Storage) Quarantined() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.quarantined
+}
+
+// rollbackAppend removes what a failed append starting at offset may have
+// written, so that the append can be retried, and returns err
+// If the removal fails too the file is marked torn and ErrTornWrite is returned
+// Note: Assumes lock is already held
+func (s *Storage) rollbackAppend(offset int64, err error) error {
+	if truncErr := s.file.Truncate(offset); truncErr != nil {
+		s.torn, s.tornAt = true, offset
+		return fmt.Errorf("%w at offset %d: %w (and removing the partial record failed: %v)", ErrTornWrite, offset, err, truncErr)
+	}
+	return err
+}
+
+// repairTorn removes the partial record of an earlier failed append
+// Called before appending or writing the footer
+// Note: Assumes lock is already held
+func (s *Storage) repairTorn() error {
+	if !s.torn {
+		return nil
+	}
+	if err := s.file.Truncate(s.tornAt); err != nil {
+		return fmt.Errorf("%w at offset %d: %v", ErrTornWrite, s.tornAt, err)
+	}
+	s.torn = false
+	return nil
+}
+
+// RebuildIndex discards the loaded ID -> offset index and rebuilds it by scanning
+// the data section, e.g. when the persisted footer is suspected to be wrong
+// The footer is rewritten by the next Sync/Close
+func (s *Storage) RebuildIndex() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.advise(adviceSequential)
+	defer s.advise(adviceRandom)
+	if err := s.rebuildIndex(); err != nil {
+		return fmt.Errorf("failed to rebuild index: %w", err)
+	}
+	if err := s.rebuildBounds(); err != nil {
+		return err
+	}
+	return s.rebuildPivots()
+}
+
+// FooterDimension reads the vector dimension recorded in the footer of the data
+// file at path, without opening it as storage
+func FooterDimension(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(-12, io.SeekEnd); err != nil {
+		return 0, errors.New("file too small to contain index")
+	}
+	var footer struct {
+		Dimension, Count, Marker uint32
+	}
+	if err := binary.Read(file, binary.LittleEndian, &footer); err != nil {
+		return 0, err
+	}
+	if footer.Marker != indexMarker || footer.Dimension == 0 {
+		return 0, errors.New("index marker not found")
+	}
+	return int(footer.Dimension), nil
 }
 
 // compact removes all tombstones and rewrites the file with only active vectors
+// Legacy files are rewritten in the current layout
+// It rewrites the file in place, unthrottled; Compact copies it instead
 // Note: Assumes lock is already held (called from Close)
 func (s *Storage) compact() error {
 	if s.file == nil {
 		return errors.New("storage file not open")
 	}
 
+	// Compaction touches every record once - don't let it displace hot pages
+	s.advise(adviceSequential)
+	defer s.advise(adviceRandom)
+
 	// Read all active vectors directly (skip tombstones)
 	fileInfo, err := s.file.Stat()
 	if err != nil {
@@ -390,113 +1149,524 @@ func (s *Storage) compact() error {
 		return err
 	}
 
-	// Update dimension if we successfully read it from metadata
-	if dimension > 0 {
+	// Use dimension from metadata only if Storage is uninitialized; records
+	// state their length, so a corrupted metadata dimension would hide them all
+	if dimension > 0 && s.dimension == 0 {
 		s.dimension = dimension
 	}
 
-	// Seek to beginning and read all active vectors
-	if _, err := s.file.Seek(0, 0); err != nil {
+	// Seek to the first record and read all active vectors
+	h, err := s.readHeader()
+	if err != nil {
 		return err
 	}
+	if _, err := s.file.Seek(h.start, io.SeekStart); err != nil {
+		return err
+	}
+	live, err := s.readLive(s.file, h.start, dataEnd, h.legacy, nil)
+	if err != nil {
+		return err
+	}
+	return s.rewriteLive(live)
+}
 
-	vectors := make(map[uint64][]float32)
-	for {
-		currentPos, err := s.file.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return err
-		}
-		if currentPos >= dataEnd {
-			break
-		}
-
-		var id uint64
-		if err := binary.Read(s.file, binary.LittleEndian, &id); err != nil {
-			if err == io.EOF {
+// rewriteLive truncates the file and writes live to it from the start
+// Note: Assumes lock is already held
+func (s *Storage) rewriteLive(live liveRecords) error {
+	// Truncate file to start fresh; the header keeps the sequence number even
+	// when no vectors are left
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	s.generation++
+	s.footerSize = 0
+	s.sortedIDs = nil
+	s.index = make(map[uint64]int64)
+	if s.bounds != nil {
+		s.bounds = newBlockBounds(s.bounds.size) // Tight bounds for the new layout
+	}
+	if err := s.writeLive(live, nil); err != nil {
+		return err
+	}
+
+	// Clear cache if enabled, then fill it with the rewritten vectors
+	if s.vectorCache != nil {
+		s.vectorCache.Purge()
+		for id, vector := range live.vectors {
+			vecCopy := make([]float32, len(vector))
+			copy(vecCopy, vector)
+			s.vectorCache.Add(id, vecCopy)
+		}
+	}
+
+	// The layout order only applies to one compaction
+	s.layoutOrder = nil
+
+	// Rewritten pages are not hot yet - let the kernel reclaim them first
+	s.advise(adviceDontNeed)
+
+	return nil
+}
+
+// upgradeV2 rewrites a file with a version 2 header in the current version
+// Note: Assumes lock is already held
+func (s *Storage) upgradeV2() error {
+	fileInfo, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	dataEnd, dimension, err := s.findDataEnd(fileInfo.Size())
+	if err != nil {
+		return err
+	}
+	if dimension > 0 && s.dimension == 0 {
+		s.dimension = dimension
+	}
+	if s.dimension <= 0 {
+		return errors.New("dimension unknown")
+	}
+	if _, err := s.file.Seek(headerSizeV2, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Untyped records all have the same size; a torn one ends the data section
+	live := liveRecords{vectors: make(map[uint64][]float32), seqs: make(map[uint64]uint64)}
+	br := bufio.NewReader(io.LimitReader(s.file, dataEnd-headerSizeV2))
+	size := int64(untypedHeaderSize + 4*s.dimension)
+	for pos := int64(headerSizeV2); pos+size <= dataEnd; pos += size {
+		var buf [untypedHeaderSize]byte
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return err
+		}
+		vector := make([]float32, s.dimension)
+		if err := binary.Read(br, binary.LittleEndian, vector); err != nil {
+			return err
+		}
+		id, seq := binary.LittleEndian.Uint64(buf[0:]), binary.LittleEndian.Uint64(buf[9:])
+		s.seq = max(s.seq, seq)
+		if buf[8]&flagDeleted != 0 {
+			delete(live.vectors, id)
+			continue
+		}
+		live.vectors[id], live.seqs[id] = vector, seq
+	}
+	return s.rewriteLive(live)
+}
+
+// liveRecords are the records a compaction keeps
+type liveRecords struct {
+	vectors map[uint64][]float32
+	seqs    map[uint64]uint64 // Write sequence number of each vector
+	others  []record          // Live records of other types, kept as they are
+}
+
+// readLive reads the records of the data section from start to end, where r
+// is positioned, keeping the last record of every ID unless it is a tombstone
+// pace (optional) is called with the bytes of every record read
+func (s *Storage) readLive(r io.Reader, start, end int64, legacy bool, pace func(n int64)) (liveRecords, error) {
+	live := liveRecords{vectors: make(map[uint64][]float32), seqs: make(map[uint64]uint64)}
+	corrupt := make(map[uint64]error) // IDs whose last record fails its checksum
+	br := bufio.NewReader(io.LimitReader(r, end-start))
+	for pos := start; pos < end; {
+		rec, err := s.readRecord(br, legacy, end-pos)
+		if errors.Is(err, ErrChecksum) && rec.kind == recordVector {
+			// Rewriting the record would checksum the corrupted bytes: it
+			// blocks the compaction unless a later record of its ID supersedes it
+			corrupt[rec.id] = err
+		} else if err != nil {
+			// The end of the data, an unreadable record or a tail torn after
+			// the first vector ends the records; any other error aborts, so a
+			// compaction never drops the records after it
+			if err == io.EOF || err == errBadRecord || (err == io.ErrUnexpectedEOF && len(live.vectors) > 0) {
 				break
 			}
-			if len(vectors) == 0 {
-				return err
-			}
-			break
+			return live, err
 		}
 
-		// New format: read vector data directly (dimension from metadata)
-		vector := make([]float32, s.dimension)
-		if err := binary.Read(s.file, binary.LittleEndian, &vector); err != nil {
+		size := rec.size(legacy) + s.padding(rec.size(legacy))
+		if _, err := br.Discard(int(size - rec.size(legacy))); err != nil {
 			if err == io.EOF {
-				break
+				break // Padding torn off the last record
 			}
-			return err
+			return live, err
+		}
+		pos += size
+		if pace != nil {
+			pace(size)
 		}
 
-		// Skip deleted vectors (tombstones)
-		if id != deletedID {
-			vectors[id] = vector
+		switch {
+		case rec.kind != recordVector:
+			if !rec.deleted() {
+				live.others = append(live.others, rec)
+			}
+		case err != nil:
+			delete(live.vectors, rec.id)
+		case rec.deleted():
+			// Skip deleted vectors (tombstones)
+			delete(corrupt, rec.id)
+			delete(live.vectors, rec.id)
+		default:
+			delete(corrupt, rec.id)
+			live.vectors[rec.id] = rec.vector
+			live.seqs[rec.id] = rec.seq
 		}
 	}
+	for _, err := range corrupt {
+		return live, err
+	}
+	return live, nil
+}
 
-	// If no vectors, just truncate
-	if len(vectors) == 0 {
-		if err := s.file.Truncate(0); err != nil {
+// writeLive writes live to the empty file in the layout of a file written
+// from the start, filling the index and bounds
+// pace (optional) is called with the bytes of every record written
+// Note: Assumes lock is already held
+func (s *Storage) writeLive(live liveRecords, pace func(n int64)) error {
+	var err error
+	if len(live.others) > 0 && live.others[0].kind == recordInfo {
+		// The file keeps the info record of the binary that created it
+		s.resetLayout()
+		err = s.writeHeader()
+		s.infoLen = live.others[0].size(false) + s.padding(live.others[0].size(false))
+	} else {
+		_, err = s.writeFileStart()
+	}
+	if err != nil {
+		return err
+	}
+	for _, rec := range live.others {
+		rec.recordHeader = s.checksum(rec.recordHeader, rec.payload)
+		if err := s.writeRecordHeader(s.file, rec.recordHeader); err != nil {
 			return err
 		}
-		s.index = make(map[uint64]int64)
-		// Clear cache if enabled
-		if s.vectorCache != nil {
-			s.vectorCache.Purge()
+		if _, err := s.file.Write(rec.payload); err != nil {
+			return fmt.Errorf("failed to write record payload: %w", err)
+		}
+		if err := s.writePadding(s.file, rec.size(false)); err != nil {
+			return err
+		}
+		if pace != nil {
+			pace(rec.size(false) + s.padding(rec.size(false)))
 		}
+	}
+	s.reportCompaction(0, len(live.vectors))
+	if len(live.vectors) == 0 {
 		return nil
 	}
 
-	// Truncate file to start fresh
-	if err := s.file.Truncate(0); err != nil {
-		return fmt.Errorf("failed to truncate file: %w", err)
+	// Rewrite all active vectors directly - inline WriteVector logic
+	// Records are laid out in the preferred order so related vectors share pages
+	for written, vecID := range s.compactionOrder(live.vectors) {
+		if written > 0 && written%compactProgressRecords == 0 {
+			s.reportCompaction(written, len(live.vectors))
+		}
+		if _, err := s.appendVector(vecID, live.seqs[vecID], live.vectors[vecID]); err != nil {
+			return fmt.Errorf("failed to rewrite vector %d: %w", vecID, err)
+		}
+		if pace != nil {
+			pace(s.recordSize())
+		}
 	}
+	s.reportCompaction(len(live.vectors), len(live.vectors))
+	return nil
+}
 
-	// Seek to beginning
-	if _, err := s.file.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to seek to beginning: %w", err)
+// appendVector appends a vector record with seq to the end of the file and
+// adds it to the index and bounds, returning its offset
+// Note: Assumes lock is already held
+func (s *Storage) appendVector(id, seq uint64, vector []float32) (int64, error) {
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.writeVectorRecord(s.file, id, 0, seq, vector); err != nil {
+		return 0, err
+	}
+	if err := s.writePadding(s.file, s.recordHeaderLen()+int64(len(vector))*4); err != nil {
+		return 0, err
+	}
+	s.index[id] = offset
+	if s.bounds != nil {
+		s.bounds.add(id, vector)
 	}
+	return offset, nil
+}
 
-	// Rebuild index
-	s.index = make(map[uint64]int64)
+// compactSuffix is appended to the data file path for the file Compact writes
+const compactSuffix = ".compact"
 
-	// Clear cache if enabled
-	if s.vectorCache != nil {
-		s.vectorCache.Purge()
+// Compact removes tombstones and superseded records while the file stays open
+// The live records are copied to a new file without holding the lock, paced by
+// the throttle, so reads and writes go on meanwhile; under a short lock the
+// vectors written and deleted since are carried over and the new file
+// replaces the old one. The footer index is rewritten on Close
+func (s *Storage) Compact() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+
+	// Snapshot the data section; records before its end only change by
+	// deletes, which are recorded in s.compactDeletes until the swap
+	s.mu.Lock()
+	if s.file == nil {
+		s.mu.Unlock()
+		return errors.New("storage file not open")
 	}
+	h, end, err := s.dataSection()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	gen, readPath, hints := s.generation, s.filePath, s.ioHints
+	src := &Storage{dimension: s.dimension, align: h.align}
+	out := &Storage{
+		filePath:        s.filePath + compactSuffix,
+		openFile:        s.openFile,
+		dimension:       s.dimension,
+		index:           make(map[uint64]int64),
+		ioHints:         s.ioHints,
+		layoutOrder:     s.layoutOrder,
+		alignment:       s.alignment,
+		fileInfo:        s.fileInfo,
+		infoParams:      s.infoParams,
+		compactProgress: s.compactProgress,
+	}
+	if s.bounds != nil {
+		out.bounds = newBlockBounds(s.bounds.size)
+	}
+	s.compactDeletes = []uint64{}
+	s.mu.Unlock()
+
+	err = s.copyLive(src, out, readPath, h, end, hints)
+	if err == nil {
+		err = s.swapCompacted(out, gen, end)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.compactDeletes = nil
+		s.mu.Unlock()
+		if out.file != nil {
+			_ = out.file.Close()
+			_ = os.Remove(out.filePath)
+		}
+	}
+	return err
+}
 
-	// Rewrite all active vectors directly - inline WriteVector logic
-	for vecID, vector := range vectors {
-		// Get current offset (where this vector will start)
-		offset, err := s.file.Seek(0, io.SeekEnd)
+// dataSection returns the header of the file and the end of its records,
+// before any footer or torn tail
+// Note: Assumes lock is already held
+func (s *Storage) dataSection() (fileHeader, int64, error) {
+	fileInfo, err := s.file.Stat()
+	if err != nil {
+		return fileHeader{}, 0, err
+	}
+	end := fileInfo.Size() - s.footerSize
+	if s.torn {
+		end = min(end, s.tornAt)
+	}
+	h, err := s.readHeader()
+	return h, end, err
+}
+
+// copyLive reads the live records of the data section up to end through a
+// handle of its own and writes them to out, with no lock held
+func (s *Storage) copyLive(src, out *Storage, path string, h fileHeader, end int64, hints bool) error {
+	openFile := s.openFile
+	if openFile == nil {
+		openFile = osOpenFile
+	}
+	file, err := openFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if hints {
+		_ = fadvise(file, adviceSequential) // Don't displace hot pages
+	}
+	if _, err := file.Seek(h.start, io.SeekStart); err != nil {
+		return err
+	}
+	live, err := src.readLive(file, h.start, end, h.legacy, s.waitBytes)
+	if err != nil {
+		return err
+	}
+
+	if out.file, err = openFile(out.filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return err
+	}
+	if err := out.writeLive(live, s.waitBytes); err != nil {
+		return err
+	}
+	// Rewritten pages are not hot yet - let the kernel reclaim them first
+	out.advise(adviceDontNeed)
+	return nil
+}
+
+// waitBytes paces n bytes of Compact I/O with the throttle of the moment, so
+// a Close clearing it lets a running compaction finish at full speed
+// Note: Called without the lock
+func (s *Storage) waitBytes(n int64) {
+	s.mu.RLock()
+	t := s.throttle
+	s.mu.RUnlock()
+	t.WaitBytes(n)
+}
+
+// swapCompacted carries the writes since the snapshot ending at end over to
+// out and makes it the data file, unless the file was rewritten in place
+// meanwhile (generation gen)
+func (s *Storage) swapCompacted(out *Storage, gen uint64, end int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil || s.generation != gen {
+		return errors.New("compaction abandoned: the data file was rewritten meanwhile")
+	}
+
+	// Vectors written since the snapshot are appended; reading the records
+	// from the end of the snapshot only visits those
+	for id, offset := range s.index {
+		if offset < end {
+			continue
+		}
+		if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		rec, err := s.readRecord(s.file, s.legacy, math.MaxInt64)
 		if err != nil {
-			return fmt.Errorf("failed to rewrite vector %d: %w", vecID, err)
+			return fmt.Errorf("failed to carry over vector %d: %w", id, err)
 		}
-
-		// Write ID (8 bytes)
-		if err := binary.Write(s.file, binary.LittleEndian, vecID); err != nil {
-			return fmt.Errorf("failed to rewrite vector %d: %w", vecID, err)
+		if _, err := out.appendVector(id, rec.seq, rec.vector); err != nil {
+			return fmt.Errorf("failed to carry over vector %d: %w", id, err)
 		}
+	}
+	// Vectors deleted since get a tombstone, so a rebuild of the new file
+	// doesn't bring them back
+	for _, id := range s.compactDeletes {
+		offset, copied := out.index[id]
+		if _, live := s.index[id]; !copied || live {
+			continue // Not copied, or written again and carried over
+		}
+		if _, err := out.file.Seek(offset+8, io.SeekStart); err != nil {
+			return err
+		}
+		if err := out.writeRecordFlags(out.file, flagDeleted, s.seq); err != nil {
+			return err
+		}
+		delete(out.index, id)
+		if out.bounds != nil {
+			out.bounds.remove(id)
+		}
+	}
 
-		// Write vector data (dimension is stored in index metadata, not per-record)
-		if err := binary.Write(s.file, binary.LittleEndian, vector); err != nil {
-			return fmt.Errorf("failed to rewrite vector %d: %w", vecID, err)
+	out.seq, out.idLimit = s.seq, s.idLimit
+	if err := out.writeHeader(); err != nil {
+		return err
+	}
+	if err := out.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync compacted file: %w", err)
+	}
+	if err := os.Rename(out.filePath, s.filePath); err != nil {
+		return fmt.Errorf("failed to replace data file: %w", err)
+	}
+	_ = s.file.Close()
+
+	// Vectors are unchanged, so the cache stays valid
+	s.file, s.index, s.sortedIDs, s.bounds = out.file, out.index, nil, out.bounds
+	s.legacy, s.headerLen, s.align, s.infoLen, s.checksums = out.legacy, out.headerLen, out.align, out.infoLen, out.checksums
+	s.footerSize, s.torn, s.tornAt, s.compactDeletes = 0, false, 0, nil
+	s.generation++
+	s.layoutOrder = nil
+	return nil
+}
+
+// SetThrottle paces the reads and writes of Compact (nil = unlimited)
+// The in-place compaction of Close is never throttled
+func (s *Storage) SetThrottle(t *throttle.Throttle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle = t
+}
+
+// compactProgressRecords is how many rewritten vectors compaction reports at a time
+const compactProgressRecords = 1024
+
+// CompactProgressFunc receives the vectors rewritten so far and the number of
+// live vectors being rewritten while the data file is compacted
+type CompactProgressFunc func(written, total int)
+
+// SetCompactProgress sets a callback for the progress of compactions (nil = none)
+// It is called with the storage lock held and must not call into Storage
+func (s *Storage) SetCompactProgress(fn CompactProgressFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compactProgress = fn
+}
+
+// reportCompaction calls the compaction progress callback, if any
+// Note: Assumes lock is already held
+func (s *Storage) reportCompaction(written, total int) {
+	if s.compactProgress != nil {
+		s.compactProgress(written, total)
+	}
+}
+
+// CacheLen returns the number of vectors in the cache
+func (s *Storage) CacheLen() int {
+	if s.vectorCache == nil {
+		return 0
+	}
+	return s.vectorCache.Len()
+}
+
+// TrimCache evicts the least recently used vectors until at most keep are
+// cached and returns how many were evicted; the capacity is unchanged, so the
+// cache fills up again with later reads
+// Does not take the storage lock (the cache is safe for concurrent use), so it
+// never waits for a compaction
+func (s *Storage) TrimCache(keep int) int {
+	if s.vectorCache == nil {
+		return 0
+	}
+	evicted := 0
+	for s.vectorCache.Len() > max(keep, 0) {
+		if _, _, ok := s.vectorCache.RemoveOldest(); !ok {
+			break
 		}
+		evicted++
+	}
+	return evicted
+}
 
-		// Update index
-		s.index[vecID] = offset
+// SetLayoutOrder sets the preferred physical order of records for the next compaction
+// IDs that are not stored are ignored; stored IDs missing from order are written
+// afterwards in ascending ID order. Indexes use this to place neighbors
+// (HNSW neighborhoods, IVF clusters) in mostly-sequential disk regions.
+func (s *Storage) SetLayoutOrder(order []uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.layoutOrder = append([]uint64(nil), order...)
+}
 
-		// Update cache if enabled
-		if s.vectorCache != nil {
-			vecCopy := make([]float32, len(vector))
-			copy(vecCopy, vector)
-			s.vectorCache.Add(vecID, vecCopy)
+// compactionOrder returns the IDs of vectors in the order they should be rewritten
+func (s *Storage) compactionOrder(vectors map[uint64][]float32) []uint64 {
+	ordered := make([]uint64, 0, len(vectors))
+	placed := make(map[uint64]bool, len(vectors))
+	for _, id := range s.layoutOrder {
+		if _, exists := vectors[id]; exists && !placed[id] {
+			ordered = append(ordered, id)
+			placed[id] = true
 		}
 	}
 
-	return nil
+	rest := make([]uint64, 0, len(vectors)-len(ordered))
+	for id := range vectors {
+		if !placed[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+	return append(ordered, rest...)
 }
 
 // Close closes the storage file, compacts tombstones, and saves the index
@@ -504,9 +1674,19 @@ func (s *Storage) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.file != nil && s.readOnly {
+		err := s.file.Close()
+		s.file = nil
+		return err
+	}
 	if s.file != nil {
 		// Compact file to remove tombstones before closing
-		if err := s.compact(); err != nil {
+		// Shutdown is not background work and is never throttled; a Compact
+		// still copying the file finishes at full speed and is abandoned
+		s.throttle = nil
+		// A record failing its checksum is kept as it is for Verify to report:
+		// the file is closed uncompacted
+		if err := s.compact(); err != nil && !errors.Is(err, ErrChecksum) {
 			// Log error but still try to close
 			_ = s.file.Close()
 			return fmt.Errorf("failed to compact file: %w", err)
@@ -523,8 +1703,6 @@ func (s *Storage) Close() error {
 	return nil
 }
 
-// WriteVector writes a vector to storage
-// Always appends to the end of the file
 // writeVectorID writes the vector ID to the writer
 func (s *Storage) writeVectorID(w io.Writer, id uint64) error {
 	if err := binary.Write(w, binary.LittleEndian, id); err != nil {
@@ -533,6 +1711,37 @@ func (s *Storage) writeVectorID(w io.Writer, id uint64) error {
 	return nil
 }
 
+// writeRecordFlags writes the flags and sequence number following the record ID
+// In a file with checksummed records, flagChecksum is kept
+func (s *Storage) writeRecordFlags(w io.Writer, flags byte, seq uint64) error {
+	var buf [9]byte
+	buf[0] = flags
+	if s.checksums {
+		buf[0] |= flagChecksum
+	}
+	binary.LittleEndian.PutUint64(buf[1:], seq)
+	if _, err := w.Write(buf[:]); err != nil {
+		return fmt.Errorf("failed to write record flags: %w", err)
+	}
+	return nil
+}
+
+// writeRecordHeader writes a whole record header, with its checksum if
+// flagChecksum is set (see checksum)
+func (s *Storage) writeRecordHeader(w io.Writer, h recordHeader) error {
+	var buf [recordHeaderSize + checksumSize]byte
+	binary.LittleEndian.PutUint64(buf[0:], h.id)
+	buf[8] = h.flags
+	binary.LittleEndian.PutUint64(buf[9:], h.seq)
+	buf[17] = h.kind
+	binary.LittleEndian.PutUint32(buf[18:], h.length)
+	binary.LittleEndian.PutUint32(buf[22:], h.crc)
+	if _, err := w.Write(buf[:h.size(false)-int64(h.length)]); err != nil {
+		return fmt.Errorf("failed to write record header: %w", err)
+	}
+	return nil
+}
+
 // writeVectorData writes the vector data to the writer
 func (s *Storage) writeVectorData(w io.Writer, vector []float32) error {
 	if err := binary.Write(w, binary.LittleEndian, vector); err != nil {
@@ -541,6 +1750,198 @@ func (s *Storage) writeVectorData(w io.Writer, vector []float32) error {
 	return nil
 }
 
+// writeVectorRecord writes a whole vector record
+func (s *Storage) writeVectorRecord(w io.Writer, id uint64, flags byte, seq uint64, vector []float32) error {
+	payload := make([]byte, len(vector)*4)
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(payload[i*4:], math.Float32bits(value))
+	}
+	h := s.checksum(recordHeader{id: id, flags: flags, seq: seq, kind: recordVector, length: uint32(len(payload))}, payload)
+	if err := s.writeRecordHeader(w, h); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return fmt.Errorf("failed to write vector data: %w", err)
+	}
+	return nil
+}
+
+// crcTable is the CRC32C (Castagnoli) table of record checksums
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// recordChecksum returns the CRC32C of the ID, type, length and payload of a
+// record; the flags and sequence number are left out, as deletes rewrite them
+// in place
+func recordChecksum(h recordHeader, payload []byte) uint32 {
+	var buf [13]byte
+	binary.LittleEndian.PutUint64(buf[0:], h.id)
+	buf[8] = h.kind
+	binary.LittleEndian.PutUint32(buf[9:], h.length)
+	return crc32.Update(crc32.Checksum(buf[:], crcTable), crcTable, payload)
+}
+
+// ErrChecksum is returned by reads of a record whose payload doesn't match
+// its checksum
+var ErrChecksum = errors.New("record fails its checksum")
+
+// verifyChecksum returns an error wrapping ErrChecksum if h carries a
+// checksum that payload doesn't match
+func verifyChecksum(h recordHeader, payload []byte) error {
+	if h.flags&flagChecksum == 0 {
+		return nil
+	}
+	if sum := recordChecksum(h, payload); sum != h.crc {
+		return fmt.Errorf("%w: record of ID %d (stored %#08x, computed %#08x)", ErrChecksum, h.id, h.crc, sum)
+	}
+	return nil
+}
+
+// readVectorPayload reads the payload of the vector record h at the current
+// position and checks it against the checksum of h
+func readVectorPayload(r io.Reader, h recordHeader) ([]float32, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(h, payload); err != nil {
+		return nil, err
+	}
+	vector := make([]float32, h.length/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(payload[i*4:]))
+	}
+	return vector, nil
+}
+
+// checksum returns h, to be written with payload, with flagChecksum and its
+// checksum set if the file has checksummed records, or cleared otherwise
+func (s *Storage) checksum(h recordHeader, payload []byte) recordHeader {
+	h.flags &^= flagChecksum
+	h.crc = 0
+	if s.checksums {
+		h.flags |= flagChecksum
+		h.crc = recordChecksum(h, payload)
+	}
+	return h
+}
+
+// recordHeaderLen returns the size of the headers of records written to the file
+func (s *Storage) recordHeaderLen() int64 {
+	if s.checksums {
+		return recordHeaderSize + checksumSize
+	}
+	return recordHeaderSize
+}
+
+// recordHeader is the decoded header of a data record
+type recordHeader struct {
+	id     uint64
+	flags  byte
+	seq    uint64 // 0 for legacy records
+	kind   byte
+	length uint32 // Payload bytes following the header
+	crc    uint32 // Checksum of the record if flagChecksum is set
+}
+
+// deleted reports whether the record is a tombstone
+func (h recordHeader) deleted() bool {
+	return h.flags&flagDeleted != 0
+}
+
+// size returns the on-disk size of the record including its header
+func (h recordHeader) size(legacy bool) int64 {
+	if legacy {
+		return 8 + int64(h.length)
+	}
+	if h.flags&flagChecksum != 0 {
+		return recordHeaderSize + checksumSize + int64(h.length)
+	}
+	return recordHeaderSize + int64(h.length)
+}
+
+// readRecordHeader reads the record header at the current position; legacy
+// selects the headerless layout, in which every record is a vector of dimension
+// Unknown flags, a zero type and vector records whose length is not a whole
+// number of float32 values are rejected with errBadRecord
+func readRecordHeader(r io.Reader, dimension int, legacy bool) (recordHeader, error) {
+	var h recordHeader
+	if legacy {
+		if err := binary.Read(r, binary.LittleEndian, &h.id); err != nil {
+			return h, err
+		}
+		h.kind, h.length = recordVector, uint32(dimension*4)
+		if h.id == legacyDeletedID {
+			h.flags = flagDeleted
+		}
+		return h, nil
+	}
+
+	var buf [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return h, err
+	}
+	h.id = binary.LittleEndian.Uint64(buf[0:])
+	h.flags = buf[8]
+	h.seq = binary.LittleEndian.Uint64(buf[9:])
+	h.kind = buf[17]
+	h.length = binary.LittleEndian.Uint32(buf[18:])
+	if h.flags&^(flagDeleted|flagChecksum) != 0 || h.kind == 0 || (h.kind == recordVector && (h.length == 0 || h.length%4 != 0)) {
+		return h, errBadRecord
+	}
+	if h.flags&flagChecksum != 0 {
+		if _, err := io.ReadFull(r, buf[:checksumSize]); err != nil {
+			return h, err
+		}
+		h.crc = binary.LittleEndian.Uint32(buf[:])
+	}
+	return h, nil
+}
+
+// endOfRecords reports whether err from reading a record means the data
+// section ends there (torn or garbage tail) rather than an I/O failure
+func endOfRecords(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF || err == errBadRecord
+}
+
+// record is one decoded data record
+type record struct {
+	recordHeader
+	vector  []float32 // Vector records
+	payload []byte    // Records of other types
+}
+
+// readRecord reads the record at the current position, which has remaining
+// bytes of data section left; legacy selects the headerless layout
+// Vectors are returned with the dimension their record states. A record
+// failing its checksum is read whole and returned with an error wrapping
+// ErrChecksum
+func (s *Storage) readRecord(r io.Reader, legacy bool, remaining int64) (record, error) {
+	h, err := readRecordHeader(r, s.dimension, legacy)
+	rec := record{recordHeader: h}
+	if err != nil {
+		return rec, err
+	}
+	// A torn vector record of the usual size fails like any short read; other
+	// lengths are checked against the data section before allocating
+	if (h.kind != recordVector || h.length > uint32(s.dimension*4)) && h.size(legacy) > remaining {
+		return rec, io.ErrUnexpectedEOF
+	}
+	if h.kind == recordVector {
+		rec.vector, err = readVectorPayload(r, h)
+		return rec, err
+	}
+	rec.payload = make([]byte, h.length)
+	if _, err := io.ReadFull(r, rec.payload); err != nil {
+		return rec, err
+	}
+	return rec, verifyChecksum(h, rec.payload)
+}
+
+// WriteVector writes a vector to storage
+// Always appends to the end of the file
+// The whole record is written at once and its offset published only after the
+// write succeeds; a failed write is rolled back so it can be retried (see
+// ErrTornWrite)
 func (s *Storage) WriteVector(id uint64, vector []float32) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -548,6 +1949,14 @@ func (s *Storage) WriteVector(id uint64, vector []float32) error {
 	if s.file == nil {
 		return errors.New("storage file not open")
 	}
+	if err := s.repairTorn(); err != nil {
+		return err
+	}
+
+	// Drop any persisted index so the record lands in the data section
+	if err := s.truncateFooter(); err != nil {
+		return err
+	}
 
 	// Seek to end of file to append (get offset where this vector will start)
 	offset, err := s.file.Seek(0, io.SeekEnd)
@@ -560,22 +1969,149 @@ func (s *Storage) WriteVector(id uint64, vector []float32) error {
 		return fmt.Errorf("vector dimension mismatch: expected %d, got %d", s.dimension, len(vector))
 	}
 
-	// Write ID (8 bytes)
-	if err := s.writeVectorID(s.file, id); err != nil {
-		return err
+	// The first record of a file is preceded by the header
+	start := offset
+	if offset == 0 {
+		if offset, err = s.writeFileStart(); err != nil {
+			return s.rollbackAppend(start, err)
+		}
 	}
 
-	// Write vector data (dimension is stored in index metadata, not per-record)
-	if err := s.writeVectorData(s.file, vector); err != nil {
+	// Stage the record header (22 bytes, 26 with a checksum) and the vector
+	// data, or just the ID (8 bytes) and the data in the legacy layout, and
+	// the padding
+	var buf bytes.Buffer
+	seq := s.seq
+	if s.legacy {
+		err = s.writeVectorID(&buf, id)
+		if err == nil {
+			err = s.writeVectorData(&buf, vector)
+		}
+	} else {
+		seq++
+		err = s.writeVectorRecord(&buf, id, 0, seq, vector)
+	}
+	if err == nil {
+		err = s.writePadding(&buf, int64(buf.Len()))
+	}
+	if err != nil {
 		return err
 	}
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return s.rollbackAppend(start, fmt.Errorf("failed to write vector record: %w", err))
+	}
+	s.seq = seq
 
 	// Update index
+	if _, exists := s.index[id]; exists {
+		s.deadRecords++ // The previous record is superseded
+	} else {
+		s.sortedIDs = nil
+	}
 	s.index[id] = offset
+	s.reads.Forget(id) // Reads starting now must not join one that saw the old record
+	if s.bounds != nil {
+		s.bounds.add(id, vector)
+	}
+	if s.pivots != nil {
+		s.addPivotRow(id, vector)
+	}
+	if s.vectorCache != nil {
+		s.vectorCache.Remove(id) // Updated vectors must not be served from the cache
+	}
 
 	return nil
 }
 
+// WriteVectors writes vectors[i] under ids[i] like repeated WriteVector calls,
+// in one append: a single seek to the end and buffered writes
+// Dimensions are checked before anything is written; on a write error the
+// index is left untouched and the appended bytes are rolled back like in
+// WriteVector
+func (s *Storage) WriteVectors(ids []uint64, vectors [][]float32) error {
+	if len(ids) != len(vectors) {
+		return fmt.Errorf("got %d IDs for %d vectors", len(ids), len(vectors))
+	}
+	for i, vector := range vectors {
+		if len(vector) != s.dimension {
+			return fmt.Errorf("vector dimension mismatch for ID %d: expected %d, got %d", ids[i], s.dimension, len(vector))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("storage file not open")
+	}
+	if err := s.repairTorn(); err != nil {
+		return err
+	}
+	if err := s.truncateFooter(); err != nil {
+		return err
+	}
+	offset, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	start := offset
+	if offset == 0 {
+		if offset, err = s.writeFileStart(); err != nil {
+			return s.rollbackAppend(start, err)
+		}
+	}
+
+	w := bufio.NewWriterSize(s.file, 1<<20)
+	offsets := make([]int64, len(ids))
+	seq := s.seq
+	for i, id := range ids {
+		offsets[i] = offset
+		offset += s.recordSize()
+		if s.legacy {
+			err = s.writeVectorID(w, id)
+			if err == nil {
+				err = s.writeVectorData(w, vectors[i])
+			}
+		} else {
+			seq++
+			err = s.writeVectorRecord(w, id, 0, seq, vectors[i])
+		}
+		if err == nil {
+			err = s.writePadding(w, s.recordHeaderLen()+int64(len(vectors[i]))*4)
+		}
+		if err != nil {
+			return s.rollbackAppend(start, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return s.rollbackAppend(start, fmt.Errorf("failed to write vectors: %w", err))
+	}
+
+	s.seq = seq
+	for i, id := range ids {
+		if _, exists := s.index[id]; exists {
+			s.deadRecords++ // The previous record is superseded
+		} else {
+			s.sortedIDs = nil
+		}
+		s.index[id] = offsets[i]
+		if s.vectorCache != nil {
+			s.vectorCache.Remove(id) // Updated vectors must not be served from the cache
+		}
+		s.reads.Forget(id) // Reads starting now must not join one that saw the old record
+		if s.bounds != nil {
+			s.bounds.add(id, vectors[i])
+		}
+		if s.pivots != nil {
+			s.addPivotRow(id, vectors[i])
+		}
+	}
+	return nil
+}
+
 // getCachedVector retrieves a vector from cache if available
 // Returns the vector copy and true if found, nil and false otherwise
 // Thread-safe: can be called without holding the lock
@@ -595,14 +2131,40 @@ func (s *Storage) getCachedVector(id uint64) ([]float32, bool) {
 
 // ReadVector reads a vector from storage by ID using the index for fast lookup
 // Uses LRU cache to avoid redundant disk reads
-// Optimized: checks cache before acquiring lock to allow concurrent cache hits
+// Optimized: checks cache before acquiring lock to allow concurrent cache hits,
+// and concurrent misses for the same ID share a single disk read
 func (s *Storage) ReadVector(id uint64) ([]float32, error) {
+	return s.ReadVectorProfiled(id, nil)
+}
+
+// ReadVectorProfiled is ReadVector counting the read, whether the cache served
+// it and the bytes read from disk in p (may be nil)
+// A miss shared with a concurrent read of the same ID reads no bytes itself
+func (s *Storage) ReadVectorProfiled(id uint64, p *profile.Profile) ([]float32, error) {
 	// Check cache FIRST (before locking) - cache is thread-safe
 	// This allows concurrent cache hits without lock contention
 	if vec, cached := s.getCachedVector(id); cached {
+		p.AddRead(true)
 		return vec, nil
 	}
+	p.AddRead(false)
 
+	vector, err, shared := s.reads.Do(id, func() ([]float32, error) { return s.readVector(id) })
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		// Each caller gets its own copy, like cache hits
+		vecCopy := make([]float32, len(vector))
+		copy(vecCopy, vector)
+		return vecCopy, nil
+	}
+	p.AddBytesRead(s.recordSize())
+	return vector, nil
+}
+
+// readVector reads a vector from disk on cache miss
+func (s *Storage) readVector(id uint64) ([]float32, error) {
 	// Only acquire lock for cache miss (file I/O needed)
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -627,18 +2189,21 @@ func (s *Storage) ReadVector(id uint64) ([]float32, error) {
 		return nil, err
 	}
 
-	// Read ID (verify it matches)
-	var vecID uint64
-	if err := binary.Read(s.file, binary.LittleEndian, &vecID); err != nil {
+	// Read the record header (verify the ID matches)
+	h, err := readRecordHeader(s.file, s.dimension, s.legacy)
+	if err != nil {
 		return nil, err
 	}
-	if vecID != id {
-		return nil, fmt.Errorf("vector ID mismatch at offset %d: expected %d, got %d", offset, id, vecID)
+	if h.id != id {
+		return nil, fmt.Errorf("vector ID mismatch at offset %d: expected %d, got %d", offset, id, h.id)
+	}
+	if h.deleted() {
+		return nil, fmt.Errorf("vector with ID %d not found", id)
 	}
 
-	// New format: read vector data directly (dimension from metadata)
-	vector := make([]float32, s.dimension)
-	if err := binary.Read(s.file, binary.LittleEndian, &vector); err != nil {
+	// Read vector data, with the dimension the record states
+	vector, err := readVectorPayload(s.file, h)
+	if err != nil {
 		return nil, err
 	}
 
@@ -653,6 +2218,119 @@ func (s *Storage) ReadVector(id uint64) ([]float32, error) {
 	return vector, nil
 }
 
+// Has reports whether a live vector is stored under id
+func (s *Storage) Has(id uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.index[id]
+	return ok
+}
+
+// Seq returns the sequence number of the last write or delete
+// Sequence numbers increase by one per WriteVector and DeleteVector and survive
+// reopening, so they order mutations e.g. for replication
+func (s *Storage) Seq() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.seq
+}
+
+// DeadRecords returns the number of records tombstoned by deletes or
+// superseded by a later write of the same ID since the storage was opened
+// The count is cumulative: compaction reclaims the records but does not reset it
+func (s *Storage) DeadRecords() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deadRecords
+}
+
+// Tombstones returns the deletes with a sequence number above since, in
+// sequence order. Compaction drops tombstones, so consumers must read them
+// before the next compaction (Compact or Close)
+func (s *Storage) Tombstones(since uint64) ([]Tombstone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil, errors.New("storage file not open")
+	}
+	fileInfo, err := s.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	dataEnd := fileInfo.Size() - s.footerSize
+	if s.legacy {
+		return nil, nil // Legacy tombstones carry no ID or sequence number
+	}
+	h, err := s.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstones []Tombstone
+	for offset := h.start; offset+recordHeaderSize <= dataEnd; {
+		if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		h, err := readRecordHeader(s.file, s.dimension, false)
+		if err != nil {
+			if endOfRecords(err) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read record at offset %d: %w", offset, err)
+		}
+		if h.kind == recordVector && h.deleted() && h.seq > since {
+			tombstones = append(tombstones, Tombstone{ID: h.id, Seq: h.seq})
+		}
+		offset += h.size(false) + s.padding(h.size(false))
+	}
+	sort.Slice(tombstones, func(i, j int) bool { return tombstones[i].Seq < tombstones[j].Seq })
+	return tombstones, nil
+}
+
+// IDs returns the IDs of all stored vectors in ascending order
+func (s *Storage) IDs() []uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]uint64, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// IDRange returns the IDs of stored vectors from fromID to toID (inclusive) in
+// ascending order
+// Served from a sorted view of the index that is built on first use and kept
+// until an ID is added or removed, so repeated range scans cost a binary search
+// The view is replaced rather than modified, so it can be searched unlocked
+func (s *Storage) IDRange(fromID, toID uint64) []uint64 {
+	s.mu.RLock()
+	sorted := s.sortedIDs
+	s.mu.RUnlock()
+	if sorted == nil {
+		s.mu.Lock()
+		if s.sortedIDs == nil {
+			s.sortedIDs = make([]uint64, 0, len(s.index))
+			for id := range s.index {
+				s.sortedIDs = append(s.sortedIDs, id)
+			}
+			sort.Slice(s.sortedIDs, func(i, j int) bool { return s.sortedIDs[i] < s.sortedIDs[j] })
+		}
+		sorted = s.sortedIDs
+		s.mu.Unlock()
+	}
+	if fromID > toID {
+		return nil
+	}
+
+	start := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= fromID })
+	end := sort.Search(len(sorted), func(i int) bool { return sorted[i] > toID })
+	return append([]uint64(nil), sorted[start:end]...)
+}
+
 // ReadAllVectors reads all vectors from storage sequentially
 // Returns a map of ID -> vector
 // Stops at data boundary (before index section)
@@ -665,6 +2343,9 @@ func (s *Storage) ReadAllVectors() (map[uint64][]float32, error) {
 		return nil, errors.New("storage file not open")
 	}
 
+	s.advise(adviceSequential)
+	defer s.advise(adviceRandom)
+
 	// Get file size to find data boundary
 	fileInfo, err := s.file.Stat()
 	if err != nil {
@@ -678,13 +2359,19 @@ func (s *Storage) ReadAllVectors() (map[uint64][]float32, error) {
 		return nil, err
 	}
 
-	// Update dimension if we successfully read it from metadata
-	if dimension > 0 {
+	// Use dimension from metadata only if Storage is uninitialized; records
+	// state their length, so a corrupted metadata dimension would hide them all
+	if dimension > 0 && s.dimension == 0 {
 		s.dimension = dimension
 	}
 
-	// Seek to beginning
-	if _, err := s.file.Seek(0, 0); err != nil {
+	// Seek to the first record
+	h, err := s.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	legacy := h.legacy
+	if _, err := s.file.Seek(h.start, io.SeekStart); err != nil {
 		return nil, err
 	}
 
@@ -701,9 +2388,9 @@ func (s *Storage) ReadAllVectors() (map[uint64][]float32, error) {
 			break
 		}
 
-		var id uint64
-		if err := binary.Read(s.file, binary.LittleEndian, &id); err != nil {
-			if err == io.EOF {
+		rec, err := s.readRecord(s.file, legacy, dataEnd-currentPos)
+		if err != nil {
+			if err == io.EOF || err == errBadRecord {
 				break
 			}
 			// For other errors, check if we've read at least one vector
@@ -713,26 +2400,25 @@ func (s *Storage) ReadAllVectors() (map[uint64][]float32, error) {
 			// If we've read some vectors, EOF is likely
 			break
 		}
-
-		// New format: read vector data directly (dimension from metadata)
-		vector := make([]float32, s.dimension)
-		if err := binary.Read(s.file, binary.LittleEndian, &vector); err != nil {
-			if err == io.EOF {
-				break
-			}
+		if _, err := s.file.Seek(s.padding(rec.size(legacy)), io.SeekCurrent); err != nil {
 			return nil, err
 		}
 
-		// Skip deleted vectors (tombstones)
-		if id != deletedID {
-			vectors[id] = vector
+		// Skip records of other types and deleted vectors (tombstones)
+		switch {
+		case rec.kind != recordVector:
+		case rec.deleted():
+			delete(vectors, rec.id)
+		default:
+			vectors[rec.id] = rec.vector
 		}
 	}
 
 	return vectors, nil
 }
 
-// DeleteVector marks a vector as deleted using a tombstone (ID = ^uint64(0), all bits set)
+// DeleteVector marks a vector as deleted by setting the deleted flag and the
+// sequence number of the delete in its record (a tombstone)
 // This is much more efficient than rewriting the entire file
 func (s *Storage) DeleteVector(id uint64) error {
 	s.mu.Lock()
@@ -753,6 +2439,13 @@ func (s *Storage) DeleteVector(id uint64) error {
 		return nil // Vector not found, nothing to delete
 	}
 
+	// The first delete after a save drops the footer, which still lists the
+	// record; a crash before the next save then rebuilds the index by scanning
+	// the data section, which sees the tombstone
+	if err := s.truncateFooter(); err != nil {
+		return err
+	}
+
 	// Seek to the vector's offset
 	if _, err := s.file.Seek(offset, 0); err != nil {
 		return err
@@ -767,23 +2460,39 @@ func (s *Storage) DeleteVector(id uint64) error {
 		return fmt.Errorf("vector ID mismatch at offset %d: expected %d, got %d", offset, id, vecID)
 	}
 
-	// Dimension is not needed for deletion - we just mark the ID as deleted
-
-	// Seek back to the start of this vector
-	if _, err := s.file.Seek(offset, 0); err != nil {
-		return err
-	}
-
-	// Write tombstone: ID = ^uint64(0) (all bits set, marks as deleted)
-	if err := binary.Write(s.file, binary.LittleEndian, deletedID); err != nil {
-		return err
+	// Write tombstone: flags and sequence number right after the ID, keeping the
+	// type and length
+	if s.legacy {
+		if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := s.writeVectorID(s.file, legacyDeletedID); err != nil {
+			return err
+		}
+	} else {
+		if err := s.writeRecordFlags(s.file, flagDeleted, s.seq+1); err != nil {
+			return err
+		}
+		s.seq++
 	}
 
-	// Keep dimension and vector data (we just mark ID as deleted)
+	// Keep the ID and vector data (we just mark the record as deleted)
 	// This way we don't need to shift anything, just skip on read
 
 	// Remove from index
 	delete(s.index, id)
+	s.sortedIDs = nil
+	s.reads.Forget(id)
+	s.deadRecords++
+	if s.compactDeletes != nil {
+		s.compactDeletes = append(s.compactDeletes, id)
+	}
+	if s.bounds != nil {
+		s.bounds.remove(id)
+	}
+	if s.pivots != nil {
+		s.removePivotRow(id)
+	}
 
 	return nil
 }
@@ -807,6 +2516,8 @@ func (s *Storage) Clear() error {
 	if err := s.file.Truncate(0); err != nil {
 		return fmt.Errorf("failed to truncate file: %w", err)
 	}
+	s.footerSize = 0
+	s.generation++
 
 	// Seek to beginning
 	if _, err := s.file.Seek(0, 0); err != nil {
@@ -815,6 +2526,22 @@ func (s *Storage) Clear() error {
 
 	// Clear index
 	s.index = make(map[uint64]int64)
+	s.sortedIDs = nil
+	s.resetLayout()
+	if s.bounds != nil {
+		s.bounds = newBlockBounds(s.bounds.size)
+	}
+	if s.pivots != nil {
+		s.pivots = &pivotTable{want: s.pivots.want, dists: make(map[uint64][]float32)}
+	}
+
+	// Keep the ID limit, so IDs handed out before are not returned again
+	s.infoLen = 0
+	if s.idLimit > 0 {
+		if _, err := s.writeFileStart(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -829,6 +2556,61 @@ func (s *Storage) GetDimension() int {
 	return s.dimension
 }
 
+// recordSize returns the on-disk size of a single vector record, including its
+// padding
+func (s *Storage) recordSize() int64 {
+	if s.legacy {
+		return 8 + int64(s.dimension)*4 // ID + float32 data
+	}
+	size := s.recordHeaderLen() + int64(s.dimension)*4 // Record header + float32 data
+	return size + s.padding(size)
+}
+
+// Usage returns a breakdown of the data file into live, dead and footer bytes
+// Dead bytes are tombstones plus records superseded by a later write of the same ID;
+// records of other types than vectors are counted as dead too, although
+// compaction keeps them
+func (s *Storage) Usage() (Usage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.file == nil {
+		return Usage{}, errors.New("storage file not open")
+	}
+
+	fileInfo, err := s.file.Stat()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	u := Usage{
+		FileSize:    fileInfo.Size(),
+		LiveBytes:   int64(len(s.index)) * s.recordSize(),
+		FooterBytes: s.footerSize,
+		RecordBytes: s.recordSize(),
+	}
+	if !s.legacy && u.FileSize-u.FooterBytes >= s.headerLen+s.infoLen {
+		u.HeaderBytes = s.headerLen + s.infoLen
+	}
+	u.DeadBytes = u.FileSize - u.FooterBytes - u.HeaderBytes - u.LiveBytes
+	if u.DeadBytes < 0 {
+		u.DeadBytes = 0
+	}
+	return u, nil
+}
+
+// Flush fsyncs the records written so far without saving the footer index,
+// which Open rebuilds by scanning the data section if it is missing
+func (s *Storage) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("storage file not open")
+	}
+	return s.file.Sync()
+}
+
 // Sync flushes data to disk and saves the index
 func (s *Storage) Sync() error {
 	s.mu.Lock()
@@ -1,8 +1,17 @@
 package storage
 
 import (
+	"encoding/binary"
+	"errors"
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/throttle"
 )
 
 func TestReadAllVectors(t *testing.T) {
@@ -214,15 +223,15 @@ func TestCompaction(t *testing.T) {
 		t.Fatalf("DeleteVector failed: %v", err)
 	}
 
-	// Get file size after deletion (should be same - tombstone, no index saved yet)
+	// Get file size after deletion (records unchanged - tombstone, footer dropped until the next save)
 	fileInfo2, err := s.file.Stat()
 	if err != nil {
 		t.Fatalf("Stat failed: %v", err)
 	}
 	sizeAfterDelete := fileInfo2.Size()
 
-	if sizeAfterDelete != sizeBeforeDelete {
-		t.Errorf("File size should not change after delete (tombstone): before %d, after %d", sizeBeforeDelete, sizeAfterDelete)
+	if footer := int64(3*16 + 12); sizeAfterDelete != sizeBeforeDelete-footer {
+		t.Errorf("File size should only lose the footer after delete (tombstone): before %d, after %d", sizeBeforeDelete, sizeAfterDelete)
 	}
 
 	// Verify deleted vector is marked but still in file
@@ -668,10 +677,10 @@ func TestStorage_ReadAllVectors_PartialRead(t *testing.T) {
 	}
 
 	// Truncate file to corrupt second vector (partial data)
-	// First vector is: 8 bytes (ID) + 16 bytes (vector data) = 24 bytes
-	// Second vector starts at offset 24
-	// Truncate to 24 + 8 (ID) + 8 (partial vector data) = 40 bytes
-	if err := s.file.Truncate(40); err != nil {
+	// Header is 16 bytes, first vector is: 22 bytes (record header) + 16 bytes (vector data) = 38 bytes
+	// Second vector starts at offset 54
+	// Truncate to 54 + 22 (record header) + 8 (partial vector data) = 84 bytes
+	if err := s.file.Truncate(84); err != nil {
 		t.Fatalf("Truncate failed: %v", err)
 	}
 
@@ -690,4 +699,889 @@ func TestStorage_ReadAllVectors_PartialRead(t *testing.T) {
 		}
 	}
 	// Error is also acceptable for corrupted data
-}
\ No newline at end of file
+}
+func TestStorage_WriteAfterReopen_KeepsDataSectionContiguous(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVector(1, []float32{1.0, 2.0, 3.0, 4.0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	s.Close()
+
+	// Reopen (loads footer index) and append another vector
+	s2, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s2.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s2.WriteVector(2, []float32{5.0, 6.0, 7.0, 8.0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+
+	// Sequential scan must not see the old footer as records
+	vectors, err := s2.ReadAllVectors()
+	if err != nil {
+		t.Fatalf("ReadAllVectors failed: %v", err)
+	}
+	if len(vectors) != 2 {
+		t.Fatalf("Expected 2 vectors, got %d: %v", len(vectors), vectors)
+	}
+	if vectors[2][0] != 5.0 {
+		t.Errorf("Expected vector 2 to start with 5.0, got %v", vectors[2])
+	}
+	s2.Close()
+}
+
+// tornFile writes only half of each write once failWrites is set, and fails
+// truncation while failTruncate is set
+type tornFile struct {
+	*os.File
+	failWrites   bool
+	failTruncate bool
+}
+
+func (f *tornFile) Write(p []byte) (int, error) {
+	if f.failWrites {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errors.New("disk full")
+	}
+	return f.File.Write(p)
+}
+
+func (f *tornFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errors.New("truncate failed")
+	}
+	return f.File.Truncate(size)
+}
+
+func TestStorage_WriteVector_RollsBackTornWrite(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	file := &tornFile{}
+	s.SetOpenFile(func(name string, flag int, perm os.FileMode) (File, error) {
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		file.File = f
+		return file, nil
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	if err := s.WriteVector(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	size := func() int64 {
+		info, err := os.Stat(tmpFile)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		return info.Size()
+	}
+	whole := size()
+
+	// A failed write is rolled back and leaves the index untouched
+	file.failWrites = true
+	if err := s.WriteVector(2, []float32{5, 6, 7, 8}); err == nil || errors.Is(err, ErrTornWrite) {
+		t.Fatalf("Expected a rolled back write error, got %v", err)
+	}
+	if size() != whole || s.Has(2) {
+		t.Errorf("Expected the partial record to be removed, got size %d (was %d)", size(), whole)
+	}
+	if err := s.WriteVectors([]uint64{2, 3}, [][]float32{{5, 6, 7, 8}, {9, 10, 11, 12}}); err == nil {
+		t.Fatal("Expected WriteVectors to fail")
+	}
+	if size() != whole || s.Has(2) || s.Has(3) {
+		t.Errorf("Expected the partial batch to be removed, got size %d (was %d)", size(), whole)
+	}
+
+	// Until the partial record can be removed, appends fail with ErrTornWrite
+	file.failTruncate = true
+	if err := s.WriteVector(2, []float32{5, 6, 7, 8}); !errors.Is(err, ErrTornWrite) {
+		t.Fatalf("Expected ErrTornWrite, got %v", err)
+	}
+	file.failWrites = false
+	if err := s.WriteVector(2, []float32{5, 6, 7, 8}); !errors.Is(err, ErrTornWrite) {
+		t.Fatalf("Expected ErrTornWrite while the torn bytes remain, got %v", err)
+	}
+	file.failTruncate = false
+	if err := s.WriteVector(2, []float32{5, 6, 7, 8}); err != nil {
+		t.Fatalf("Retried WriteVector failed: %v", err)
+	}
+	vectors, err := s.ReadAllVectors()
+	if err != nil || len(vectors) != 2 || vectors[2][0] != 5 {
+		t.Errorf("Expected vectors 1 and 2 in contiguous records, got %v (%v)", vectors, err)
+	}
+}
+
+func TestStorage_Open_QuarantinesTornTail(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+	defer os.Remove(tmpFile + QuarantineSuffix)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for id := uint64(1); id <= 3; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	// Crash in the middle of the third record: no footer, half a record
+	recordsEnd := s.headerLen + 2*s.recordSize()
+	torn := s.recordSize() / 2
+	s.file.Close()
+	s.file = nil
+	if err := os.Truncate(tmpFile, recordsEnd+torn); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	s, err = NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if s.Has(3) || !s.Has(2) {
+		t.Errorf("Expected vectors 1 and 2 without the torn vector 3, got %v", s.IDs())
+	}
+	if s.Quarantined() != torn {
+		t.Errorf("Expected %d bytes quarantined, got %d", torn, s.Quarantined())
+	}
+	quarantine, err := os.ReadFile(tmpFile + QuarantineSuffix)
+	if err != nil {
+		t.Fatalf("Failed to read the quarantine file: %v", err)
+	}
+	if int64(len(quarantine)) != 16+torn || int64(binary.LittleEndian.Uint64(quarantine)) != recordsEnd {
+		t.Errorf("Expected the torn range at offset %d in the quarantine file, got %d bytes", recordsEnd, len(quarantine))
+	}
+
+	// Appends continue from the last whole record
+	if err := s.WriteVector(3, []float32{30, 0, 0, 0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := os.Truncate(tmpFile, recordsEnd+s.recordSize()); err != nil { // Drop the footer to force a scan
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	s, err = NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	if vec, err := s.ReadVector(3); err != nil || vec[0] != 30 {
+		t.Errorf("Expected the rewritten vector 3, got %v (%v)", vec, err)
+	}
+	if s.Quarantined() != 0 {
+		t.Errorf("Expected nothing quarantined on a clean scan, got %d", s.Quarantined())
+	}
+}
+
+func TestStorage_Usage(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+
+	if _, err := s.Usage(); err == nil {
+		t.Error("Expected error from Usage before Open")
+	}
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	for id := uint64(1); id <= 3; id++ {
+		if err := s.WriteVector(id, []float32{1.0, 2.0, 3.0, 4.0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(2); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	u, err := s.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	// Header: 32 bytes; each record: 26 bytes (record header with checksum) + 16 bytes (vector data) = 42 bytes
+	if u.LiveBytes != 84 || u.DeadBytes != 42 || u.FooterBytes != 0 || u.HeaderBytes != 32 || u.FileSize != 158 || u.RecordBytes != 42 {
+		t.Errorf("Unexpected usage before sync: %+v", u)
+	}
+
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	u, err = s.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	// Footer: 2 entries * 16 bytes + 12 bytes metadata
+	if u.FooterBytes != 44 || u.FileSize != 202 || u.DeadBytes != 42 {
+		t.Errorf("Unexpected usage after sync: %+v", u)
+	}
+}
+
+func TestStorage_DeadRecords(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	vec := []float32{1.0, 2.0, 3.0, 4.0}
+	for id := uint64(1); id <= 3; id++ {
+		if err := s.WriteVector(id, vec); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if n := s.DeadRecords(); n != 0 {
+		t.Errorf("Expected 0 dead records after inserts, got %d", n)
+	}
+
+	// An overwrite, a batch with one overwrite and a delete each kill one record
+	if err := s.WriteVector(1, vec); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.WriteVectors([]uint64{2, 4}, [][]float32{vec, vec}); err != nil {
+		t.Fatalf("WriteVectors failed: %v", err)
+	}
+	if err := s.DeleteVector(3); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.DeleteVector(99); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if n := s.DeadRecords(); n != 3 {
+		t.Errorf("Expected 3 dead records, got %d", n)
+	}
+
+	// Compaction reclaims the records but keeps the count
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if n := s.DeadRecords(); n != 3 {
+		t.Errorf("Expected 3 dead records after compaction, got %d", n)
+	}
+}
+
+func TestStorage_Compact_LayoutOrder(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for id := uint64(1); id <= 5; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+
+	// 99 is not stored and 4 is repeated - both must be ignored
+	s.SetLayoutOrder([]uint64{4, 2, 99, 4})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s2, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s2.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s2.Close()
+
+	// Ordered IDs first, then the rest ascending; each record is 42 bytes after the header
+	expected := map[uint64]int64{4: 32, 2: 74, 1: 116, 3: 158, 5: 200}
+	for id, offset := range expected {
+		if s2.index[id] != offset {
+			t.Errorf("Expected ID %d at offset %d, got %d", id, offset, s2.index[id])
+		}
+	}
+	if s2.layoutOrder != nil {
+		t.Error("Expected layout order to be consumed by compaction")
+	}
+}
+
+// failingReader returns err once the bytes of r are exhausted
+type failingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
+
+func TestStorage_ReadLive_ReadErrorAborts(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	for id := uint64(1); id <= 3; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+
+	// An I/O error after the first record must not read as the end of the
+	// data, or a compaction would drop the records after it
+	if _, err := s.file.Seek(headerSizeV4, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	ioErr := errors.New("read failed")
+	r := &failingReader{r: io.LimitReader(s.file, 42+10), err: ioErr}
+	if _, err := s.readLive(r, headerSizeV4, headerSizeV4+3*42, false, nil); !errors.Is(err, ioErr) {
+		t.Errorf("Expected the read error from readLive, got %v", err)
+	}
+}
+
+func TestStorage_Compact_KeepsFileOpen(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for id := uint64(1); id <= 3; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(2); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	u, err := s.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if u.DeadBytes != 0 || u.LiveBytes != 84 || u.FooterBytes != 0 {
+		t.Errorf("Unexpected usage after compaction: %+v", u)
+	}
+
+	// Storage stays usable after compaction and survives a reopen
+	if err := s.WriteVector(4, []float32{4, 0, 0, 0}); err != nil {
+		t.Fatalf("WriteVector after Compact failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	for _, id := range []uint64{1, 3, 4} {
+		vec, err := s.ReadVector(id)
+		if err != nil || vec[0] != float32(id) {
+			t.Errorf("Expected vector %d after reopen, got %v (%v)", id, vec, err)
+		}
+	}
+	if _, err := s.ReadVector(2); err == nil {
+		t.Error("Deleted vector 2 resurrected after compaction")
+	}
+}
+
+func TestStorage_SetThrottle(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	th := throttle.New(1<<20, 0)
+	s.SetThrottle(th)
+
+	for id := uint64(1); id <= 4; id++ {
+		if err := s.WriteVector(id, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if th.State().Bytes != 0 {
+		t.Error("Foreground writes must not be throttled")
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	// 4 records read, 3 rewritten, 42 bytes each
+	if got := th.State().Bytes; got != 7*42 {
+		t.Errorf("Expected %d throttled bytes, got %d", 7*42, got)
+	}
+
+	// Compaction on Close is not throttled
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := th.State().Bytes; got != 7*42 {
+		t.Errorf("Close compaction was throttled: %d bytes", got)
+	}
+}
+
+func TestStorage_Compact_Online(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	for id := uint64(1); id <= 10; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	// Writes and deletes run while the records are copied, without the lock
+	var ran bool
+	var during error
+	s.SetCompactProgress(func(written, total int) {
+		if written != 0 || ran {
+			return
+		}
+		ran = true
+		done := make(chan error, 1)
+		go func() {
+			done <- errors.Join(
+				s.WriteVector(11, []float32{11, 0, 0, 0}),
+				s.WriteVector(3, []float32{33, 0, 0, 0}),
+				s.DeleteVector(5),
+			)
+		}()
+		select {
+		case during = <-done:
+		case <-time.After(5 * time.Second):
+			during = errors.New("writes blocked by the compaction")
+		}
+	})
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if !ran || during != nil {
+		t.Fatalf("Expected writes during compaction to succeed, got %v", during)
+	}
+	if _, err := os.Stat(tmpFile + compactSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected no leftover compaction file, got %v", err)
+	}
+
+	// The compacted file holds the writes made meanwhile, also when its index
+	// is rebuilt from the records alone
+	other, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := other.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer other.Close()
+	for _, st := range []*Storage{s, other} {
+		want := map[uint64]float32{2: 2, 3: 33, 4: 4, 10: 10, 11: 11}
+		for id, x := range want {
+			if vec, err := st.ReadVector(id); err != nil || vec[0] != x {
+				t.Errorf("Expected vector %d = %v, got %v (%v)", id, x, vec, err)
+			}
+		}
+		for _, id := range []uint64{1, 5} {
+			if _, err := st.ReadVector(id); err == nil {
+				t.Errorf("Deleted vector %d resurrected after compaction", id)
+			}
+		}
+		if n := len(st.IDs()); n != 9 {
+			t.Errorf("Expected 9 vectors, got %d", n)
+		}
+	}
+}
+
+func TestStorage_IDs(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 2, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	for _, id := range []uint64{9, 3, 5} {
+		if err := s.WriteVector(id, []float32{1, 2}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(5); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	ids := s.IDs()
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 9 {
+		t.Errorf("Expected [3 9], got %v", ids)
+	}
+}
+
+func TestStorage_IDRange(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 2, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	for _, id := range []uint64{9, 3, 5, 12} {
+		if err := s.WriteVector(id, []float32{1, 2}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if ids := s.IDRange(4, 9); len(ids) != 2 || ids[0] != 5 || ids[1] != 9 {
+		t.Errorf("Expected [5 9], got %v", ids)
+	}
+	if ids := s.IDRange(9, 4); len(ids) != 0 {
+		t.Errorf("Expected an empty range, got %v", ids)
+	}
+
+	// The sorted view follows added and removed IDs
+	if err := s.DeleteVector(5); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.WriteVectors([]uint64{7, 9}, [][]float32{{1, 2}, {3, 4}}); err != nil {
+		t.Fatalf("WriteVectors failed: %v", err)
+	}
+	if ids := s.IDRange(0, ^uint64(0)); len(ids) != 4 || ids[0] != 3 || ids[1] != 7 || ids[2] != 9 || ids[3] != 12 {
+		t.Errorf("Expected [3 7 9 12], got %v", ids)
+	}
+}
+
+// slowSeekFile delays and counts seeks once enabled, to make concurrent reads overlap
+type slowSeekFile struct {
+	*os.File
+	enabled atomic.Bool
+	seeks   atomic.Int32
+}
+
+func (f *slowSeekFile) Seek(offset int64, whence int) (int64, error) {
+	if f.enabled.Load() {
+		f.seeks.Add(1)
+		time.Sleep(20 * time.Millisecond)
+	}
+	return f.File.Seek(offset, whence)
+}
+
+func TestStorage_ReadVector_SingleFlight(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0) // No cache: every miss would hit the disk
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	file := &slowSeekFile{}
+	s.SetOpenFile(func(name string, flag int, perm os.FileMode) (File, error) {
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		file.File = f
+		return file, nil
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	if err := s.WriteVector(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+
+	file.enabled.Store(true)
+	const readers = 8
+	var wg sync.WaitGroup
+	vectors := make([][]float32, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vec, err := s.ReadVector(1)
+			if err != nil {
+				t.Errorf("ReadVector failed: %v", err)
+			}
+			vectors[i] = vec
+		}(i)
+	}
+	wg.Wait()
+	file.enabled.Store(false)
+
+	if n := file.seeks.Load(); n >= readers {
+		t.Errorf("Expected concurrent reads to share disk I/O, got %d seeks for %d readers", n, readers)
+	}
+	// Callers own their result
+	vectors[0][0] = 100
+	for i := 1; i < readers; i++ {
+		if vectors[i][0] != 1 {
+			t.Errorf("Reader %d saw another reader's modification: %v", i, vectors[i])
+		}
+	}
+}
+
+func TestStorage_ReadVectorProfiled(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0) // No cache: every read is a miss
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	if err := s.WriteVector(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+
+	p := profile.New(false)
+	for i := 0; i < 2; i++ {
+		if _, err := s.ReadVectorProfiled(1, p); err != nil {
+			t.Fatalf("ReadVectorProfiled failed: %v", err)
+		}
+	}
+	if p.Reads != 2 || p.CacheHits != 0 || p.BytesRead != 2*uint64(s.recordSize()) {
+		t.Errorf("Expected 2 uncached reads of one record each, got %+v", p.Counters)
+	}
+
+	cached, err := NewStorage(tmpFile+".cached", 4, 10)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	defer os.Remove(tmpFile + ".cached")
+	if err := cached.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer cached.Close()
+	if err := cached.WriteVector(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	p = profile.New(false)
+	for i := 0; i < 2; i++ {
+		if _, err := cached.ReadVectorProfiled(1, p); err != nil {
+			t.Fatalf("ReadVectorProfiled failed: %v", err)
+		}
+	}
+	if p.Reads != 2 || p.CacheHits < 1 {
+		t.Errorf("Expected the second read to hit the cache, got %+v", p.Counters)
+	}
+}
+
+func TestStorage_RecordAlignment(t *testing.T) {
+	useSmallRebuildChunks(t) // Rebuilds scan the padded records in parallel
+	for _, align := range []int64{8, 64} {
+		tmpFile := createTempFile(t)
+		defer os.Remove(tmpFile)
+
+		open := func(alignment int) *Storage {
+			s, err := NewStorage(tmpFile, 5, 0)
+			if err != nil {
+				t.Fatalf("NewStorage failed: %v", err)
+			}
+			if err := s.SetRecordAlignment(alignment); err != nil {
+				t.Fatalf("SetRecordAlignment failed: %v", err)
+			}
+			s.SetRebuildWorkers(4)
+			if err := s.Open(); err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			return s
+		}
+		checkAligned := func(s *Storage, want int64) {
+			t.Helper()
+			for id, offset := range s.index {
+				if (offset+s.recordHeaderLen())%want != 0 {
+					t.Errorf("Alignment %d: vector %d starts at unaligned offset %d", want, id, offset+s.recordHeaderLen())
+				}
+			}
+		}
+
+		s := open(int(align))
+		if err := s.WriteVector(1, []float32{1, 1, 1, 1, 1}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+		if err := s.WriteVectors([]uint64{2, 3, 4}, [][]float32{{2, 2, 2, 2, 2}, {3, 3, 3, 3, 3}, {4, 4, 4, 4, 4}}); err != nil {
+			t.Fatalf("WriteVectors failed: %v", err)
+		}
+		if err := s.DeleteVector(3); err != nil {
+			t.Fatalf("DeleteVector failed: %v", err)
+		}
+		if s.recordSize()%align != 0 {
+			t.Errorf("Alignment %d: expected padded records, got %d bytes", align, s.recordSize())
+		}
+		checkAligned(s, align)
+		if err := s.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		s.file.Close()
+		s.file = nil
+
+		// The footer is dropped so the index is rebuilt by scanning the padded records
+		info, err := os.Stat(tmpFile)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if err := os.Truncate(tmpFile, info.Size()-(3*16+12)); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		s = open(0)
+		if len(s.index) != 3 || s.Has(3) || s.Quarantined() != 0 {
+			t.Errorf("Alignment %d: expected vectors 1, 2 and 4 after the scan, got %v", align, s.IDs())
+		}
+		if tombstones, err := s.Tombstones(0); err != nil || len(tombstones) != 1 || tombstones[0].ID != 3 {
+			t.Errorf("Alignment %d: expected the tombstone of vector 3, got %v (%v)", align, tombstones, err)
+		}
+
+		// An existing file keeps its alignment until it is compacted
+		if err := s.WriteVector(5, []float32{5, 5, 5, 5, 5}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+		checkAligned(s, align)
+		vectors, err := s.ReadAllVectors()
+		if err != nil || len(vectors) != 4 || vectors[5][0] != 5 {
+			t.Errorf("Alignment %d: unexpected vectors %v (%v)", align, vectors, err)
+		}
+		if err := s.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if err := s.Discard(); err != nil {
+			t.Fatalf("Discard failed: %v", err)
+		}
+		result, err := Verify(tmpFile, 5, true)
+		if err != nil || len(result.Problems) != 0 || result.Records != 5 {
+			t.Errorf("Alignment %d: expected a clean verify, got %+v (%v)", align, result, err)
+		}
+
+		// Compaction (here by Close) rewrites the file with the configured alignment
+		s = open(0)
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		s = open(0)
+		if s.align != 1 || s.headerLen != headerSizeV4 {
+			t.Errorf("Alignment %d: expected compaction to drop the alignment, got %d", align, s.align)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		s = open(int(align))
+		if err := s.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		checkAligned(s, align)
+		for id := uint64(1); id <= 5; id++ {
+			vector, err := s.ReadVector(id)
+			if id == 3 {
+				continue
+			}
+			if err != nil || vector[0] != float32(id) {
+				t.Errorf("Alignment %d: expected vector %d, got %v (%v)", align, id, vector, err)
+			}
+		}
+		s.Close()
+	}
+
+	s, err := NewStorage(createTempFile(t), 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	for _, align := range []int{-8, 3, 48, MaxRecordAlignment * 2} {
+		if err := s.SetRecordAlignment(align); err == nil {
+			t.Errorf("Expected alignment %d to be rejected", align)
+		}
+	}
+}
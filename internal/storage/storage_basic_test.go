@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
+	"math"
 	"os"
 	"testing"
 
+	"github.com/monishSR/veclite/internal/fileinfo"
 	"github.com/monishSR/veclite/internal/index/utils"
 )
 
@@ -145,6 +149,69 @@ func TestWriteVector_Multiple(t *testing.T) {
 	}
 }
 
+func TestWriteVectors(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 10)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := s.WriteVector(1, []float32{1, 1, 1, 1}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if _, err := s.ReadVector(1); err != nil { // Cached
+		t.Fatalf("ReadVector failed: %v", err)
+	}
+	ids := []uint64{2, 3, 1}
+	vectors := [][]float32{{2, 2, 2, 2}, {3, 3, 3, 3}, {4, 4, 4, 4}}
+	if err := s.WriteVectors(ids, vectors); err != nil {
+		t.Fatalf("WriteVectors failed: %v", err)
+	}
+	if seq := s.Seq(); seq != 4 {
+		t.Errorf("Expected sequence number 4 after 4 records, got %d", seq)
+	}
+	if vec, err := s.ReadVector(1); err != nil || vec[0] != 4 {
+		t.Errorf("Expected the updated vector 1, got %v (%v)", vec, err)
+	}
+
+	// Nothing is written if any vector has the wrong dimension
+	if err := s.WriteVectors([]uint64{5, 6}, [][]float32{{5, 5, 5, 5}, {6, 6}}); err == nil {
+		t.Error("Expected an error for a vector of the wrong dimension")
+	}
+	if err := s.WriteVectors([]uint64{5}, nil); err == nil {
+		t.Error("Expected an error for mismatched IDs and vectors")
+	}
+	if _, exists := s.index[5]; exists {
+		t.Error("Expected no record from a failed WriteVectors")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	want := map[uint64]float32{1: 4, 2: 2, 3: 3}
+	if len(s.index) != len(want) {
+		t.Errorf("Expected %d vectors after reopen, got %d", len(want), len(s.index))
+	}
+	for id, value := range want {
+		vec, err := s.ReadVector(id)
+		if err != nil {
+			t.Fatalf("ReadVector failed for ID %d: %v", id, err)
+		}
+		if vec[0] != value {
+			t.Errorf("Expected vector %d to start with %f, got %v", id, value, vec)
+		}
+	}
+}
+
 func TestReadVector_NotFound(t *testing.T) {
 	tmpFile := createTempFile(t)
 	defer os.Remove(tmpFile)
@@ -684,6 +751,42 @@ func TestStorage_DeleteVector_WithCache(t *testing.T) {
 	}
 }
 
+func TestStorage_WriteVector_UpdatesCache(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 10)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.WriteVector(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if _, err := s.ReadVector(1); err != nil { // Populates the cache
+		t.Fatalf("ReadVector failed: %v", err)
+	}
+	if !s.Has(1) || s.Has(2) {
+		t.Errorf("Expected Has to report only ID 1")
+	}
+
+	// An overwrite must not be served from the cache
+	if err := s.WriteVector(1, []float32{5, 6, 7, 8}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	vec, err := s.ReadVector(1)
+	if err != nil {
+		t.Fatalf("ReadVector failed: %v", err)
+	}
+	if vec[0] != 5 {
+		t.Errorf("Expected the overwritten vector, got %v", vec)
+	}
+}
+
 func TestStorage_DeleteVector_SeekError(t *testing.T) {
 	tmpFile := createTempFile(t)
 	defer os.Remove(tmpFile)
@@ -1113,10 +1216,545 @@ func TestStorage_WriteVector_WriteVectorDataError(t *testing.T) {
 
 // Helper function to create a temporary file
 func createTempFile(t *testing.T) string {
-	tmpFile, err := os.CreateTemp("", "veclite_test_*.db")
+	// Under the test's directory, so sidecars such as the quarantined torn
+	// tail are removed with it
+	tmpFile, err := os.CreateTemp(t.TempDir(), "veclite_test_*.db")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
 	tmpFile.Close()
 	return tmpFile.Name()
-}
\ No newline at end of file
+}
+
+func TestStorage_IOHints(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	s.SetIOHints(true)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := fadvise(s.file, adviceRandom); err != nil {
+		t.Errorf("fadvise failed: %v", err)
+	}
+
+	// Hints must not change observable behavior
+	for id := uint64(1); id <= 5; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 2.0, 3.0, 4.0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(3); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	vectors, err := s.ReadAllVectors()
+	if err != nil {
+		t.Fatalf("ReadAllVectors failed: %v", err)
+	}
+	if len(vectors) != 4 {
+		t.Errorf("Expected 4 vectors, got %d", len(vectors))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s2, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	s2.SetIOHints(true)
+	if err := s2.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s2.Close()
+	vec, err := s2.ReadVector(5)
+	if err != nil {
+		t.Fatalf("ReadVector failed: %v", err)
+	}
+	if vec[0] != 5.0 {
+		t.Errorf("Expected 5.0, got %f", vec[0])
+	}
+	s2.SetIOHints(false)
+}
+
+func TestStorage_SetOpenFile(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+
+	openErr := errors.New("open refused")
+	s.SetOpenFile(func(name string, flag int, perm os.FileMode) (File, error) {
+		return nil, openErr
+	})
+	if err := s.Open(); !errors.Is(err, openErr) {
+		t.Fatalf("Expected custom opener error, got %v", err)
+	}
+	if s.file != nil {
+		t.Error("Expected file to stay nil after failed open")
+	}
+
+	// nil restores the default opener
+	opened := 0
+	s.SetOpenFile(func(name string, flag int, perm os.FileMode) (File, error) {
+		opened++
+		return os.OpenFile(name, flag, perm)
+	})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if opened != 1 {
+		t.Errorf("Expected custom opener to be used once, got %d", opened)
+	}
+	s.Close()
+
+	s.SetOpenFile(nil)
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open with default opener failed: %v", err)
+	}
+	s.Close()
+}
+
+func TestStorage_Tombstones(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// The whole ID space is usable, including the legacy tombstone ID
+	for _, id := range []uint64{1, 2, ^uint64(0)} {
+		if err := s.WriteVector(id, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("WriteVector(%d) failed: %v", id, err)
+		}
+	}
+	if err := s.DeleteVector(2); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if s.Seq() != 5 {
+		t.Errorf("Expected sequence number 5, got %d", s.Seq())
+	}
+
+	tombstones, err := s.Tombstones(0)
+	if err != nil {
+		t.Fatalf("Tombstones failed: %v", err)
+	}
+	want := []Tombstone{{ID: 2, Seq: 4}, {ID: 1, Seq: 5}}
+	if len(tombstones) != len(want) || tombstones[0] != want[0] || tombstones[1] != want[1] {
+		t.Errorf("Expected tombstones %v, got %v", want, tombstones)
+	}
+	if tombstones, _ := s.Tombstones(4); len(tombstones) != 1 || tombstones[0].ID != 1 {
+		t.Errorf("Expected only the delete after seq 4, got %v", tombstones)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The sequence number survives compaction and reopening; tombstones don't
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if s.Seq() != 5 {
+		t.Errorf("Expected sequence number 5 after reopen, got %d", s.Seq())
+	}
+	if tombstones, _ := s.Tombstones(0); len(tombstones) != 0 {
+		t.Errorf("Expected compaction to drop tombstones, got %v", tombstones)
+	}
+	if vector, err := s.ReadVector(^uint64(0)); err != nil || vector[3] != 4 {
+		t.Errorf("Expected to read back vector %d, got %v, %v", ^uint64(0), vector, err)
+	}
+}
+
+func TestStorage_LegacyFile(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	// Headerless layout: ID + data, deleted records carry the reserved ID
+	var data []byte
+	for _, id := range []uint64{1, legacyDeletedID, 3} {
+		data = binary.LittleEndian.AppendUint64(data, id)
+		for i := 0; i < 4; i++ {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(id)))
+		}
+	}
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if ids := s.IDs(); len(ids) != 2 {
+		t.Errorf("Expected 2 live vectors, got %v", ids)
+	}
+	if tombstones, err := s.Tombstones(0); err != nil || tombstones != nil {
+		t.Errorf("Expected no tombstones for a legacy file, got %v, %v", tombstones, err)
+	}
+	// Writes and deletes keep the legacy layout until compaction
+	if err := s.WriteVector(4, []float32{4, 4, 4, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if s.legacy {
+		t.Error("Expected Close to rewrite the file in the current layout")
+	}
+	for _, id := range []uint64{3, 4} {
+		if vector, err := s.ReadVector(id); err != nil || vector[0] != float32(id) {
+			t.Errorf("Expected to read back vector %d, got %v, %v", id, vector, err)
+		}
+	}
+	if _, err := s.ReadVector(1); err == nil {
+		t.Error("Expected vector 1 to stay deleted")
+	}
+}
+
+func TestStorage_UnknownRecordType(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVector(1, []float32{1, 1, 1, 1}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	// A record of a type this version doesn't know, e.g. written by a newer one
+	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	payload := []byte("hello")
+	if err := s.writeRecordHeader(s.file, recordHeader{id: 7, seq: 9, kind: 200, length: uint32(len(payload))}); err != nil {
+		t.Fatalf("writeRecordHeader failed: %v", err)
+	}
+	if _, err := s.file.Write(payload); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.WriteVector(2, []float32{2, 2, 2, 2}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if ids := s.IDs(); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected the unknown record to be skipped, got IDs %v", ids)
+	}
+	if vectors, err := s.ReadAllVectors(); err != nil || len(vectors) != 2 {
+		t.Errorf("Expected 2 vectors, got %v, %v", vectors, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Compaction keeps the record as it was
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	h, err := readRecordHeader(bytes.NewReader(data[headerSizeV4:]), 4, false)
+	if err != nil || h.kind != 200 || h.id != 7 || h.seq != 9 {
+		t.Errorf("Expected the unknown record first after compaction, got %+v, %v", h, err)
+	}
+	start := headerSizeV4 + recordHeaderSize + checksumSize // Rewritten with a checksum
+	if got := string(data[start : start+len(payload)]); got != "hello" {
+		t.Errorf("Expected payload %q, got %q", "hello", got)
+	}
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if result.Records != 3 || len(result.Problems) != 0 {
+		t.Errorf("Expected 3 records and no problems, got %+v", result)
+	}
+
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if vector, err := s.ReadVector(2); err != nil || vector[0] != 2 {
+		t.Errorf("Expected to read back vector 2, got %v, %v", vector, err)
+	}
+}
+
+func TestStorage_FileInfo(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.SetRecordAlignment(64); err != nil {
+		t.Fatalf("SetRecordAlignment failed: %v", err)
+	}
+	s.SetFileInfo(fileinfo.Param{Name: "index", Value: "flat"})
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVectors([]uint64{1, 2, 3}, [][]float32{{1, 1, 1, 1}, {2, 2, 2, 2}, {3, 3, 3, 3}}); err != nil {
+		t.Fatalf("WriteVectors failed: %v", err)
+	}
+	if err := s.DeleteVector(2); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if s.infoLen == 0 || s.infoLen%64 != 0 {
+		t.Fatalf("Expected a padded file info record, got %d bytes", s.infoLen)
+	}
+	if u, err := s.Usage(); err != nil || u.HeaderBytes != s.headerLen+s.infoLen {
+		t.Errorf("Expected the file info record in the header bytes, got %+v (%v)", u, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	header, err := ReadHeader(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if header.Version != 5 || len(header.Fields) != 4 || header.Fields[3].Value != "64" || header.Info == nil {
+		t.Fatalf("Expected a version 5 header with file info, got %+v", header)
+	}
+	created := header.Info.Created
+	for name, want := range map[string]string{"dimension": "4", "alignment": "64", "index": "flat"} {
+		if value, _ := header.Info.Param(name); value != want {
+			t.Errorf("Expected %s = %s, got %q", name, want, value)
+		}
+	}
+	if result, err := Verify(tmpFile, 4, false); err != nil || len(result.Problems) != 0 {
+		t.Errorf("Expected a clean quick verify, got %+v (%v)", result, err)
+	}
+	if result, err := Verify(tmpFile, 4, true); err != nil || len(result.Problems) != 0 || result.Records != 2 {
+		t.Errorf("Expected a clean deep verify of 2 records, got %+v (%v)", result, err)
+	}
+
+	// The record is skipped on rebuild and kept by compaction
+	s2, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	s2.SetFileInfo()
+	if err := s2.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s2.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if ids := s2.IDs(); len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("Expected IDs 1 and 3, got %v", ids)
+	}
+	if err := s2.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if vec, err := s2.ReadVector(3); err != nil || vec[0] != 3 {
+		t.Errorf("Expected vector 3 after compaction, got %v (%v)", vec, err)
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if header, err := ReadHeader(tmpFile); err != nil || header.Info == nil || !header.Info.Created.Equal(created) {
+		t.Errorf("Expected compaction to keep the file info record, got %+v (%v)", header, err)
+	}
+}
+
+func TestStorage_NextID(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVector(2, []float32{2, 2, 2, 4}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+
+	// IDs count up from 1, skipping the stored ID 2
+	for _, want := range []uint64{1, 3, 4} {
+		if id, err := s.NextID(); err != nil || id != want {
+			t.Errorf("Expected ID %d, got %d, %v", want, id, err)
+		}
+	}
+
+	// A crash loses the rest of the reserved block, but no ID is returned twice
+	if err := s.file.Close(); err != nil {
+		t.Fatalf("Closing the file failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if id, err := s.NextID(); err != nil || id != idReserve+1 {
+		t.Errorf("Expected ID %d after the crash, got %d, %v", idReserve+1, id, err)
+	}
+	if vector, err := s.ReadVector(2); err != nil || vector[0] != 2 {
+		t.Errorf("Expected to read back vector 2, got %v, %v", vector, err)
+	}
+
+	// The limit survives Clear and a clean close
+	if err := s.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if id, err := s.NextID(); err != nil || id <= idReserve+1 {
+		t.Errorf("Expected an ID above %d after Clear, got %d, %v", idReserve+1, id, err)
+	}
+}
+
+func TestStorage_ChecksumsAfterCompaction(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	// A version 3 file with one record without checksum
+	data := binary.LittleEndian.AppendUint64(nil, fileMagic)
+	data = binary.LittleEndian.AppendUint64(data, 1)
+	data = binary.LittleEndian.AppendUint64(data, 0)
+	data = binary.LittleEndian.AppendUint64(data, 1)
+	data = append(data, 0)
+	data = binary.LittleEndian.AppendUint64(data, 1)
+	data = append(data, recordVector)
+	data = binary.LittleEndian.AppendUint32(data, 16)
+	for i := 0; i < 4; i++ {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(1))
+	}
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Appends keep the layout of the file
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVector(2, []float32{2, 2, 2, 2}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if s.checksums || s.recordSize() != recordHeaderSize+16 {
+		t.Errorf("Expected unchecksummed records of a version 3 file, got %d bytes", s.recordSize())
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := s.Discard(); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if result, err := Verify(tmpFile, 4, true); err != nil || result.Records != 2 || result.Checksums != 0 || len(result.Problems) != 0 {
+		t.Errorf("Expected 2 records without checksum, got %+v (%v)", result, err)
+	}
+
+	// Compaction (here by Close) rewrites it in version 5
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if header, err := ReadHeader(tmpFile); err != nil || header.Version != 5 {
+		t.Errorf("Expected a version 5 header after compaction, got %+v (%v)", header, err)
+	}
+	if result, err := Verify(tmpFile, 4, true); err != nil || result.Records != 2 || result.Checksums != 2 || len(result.Problems) != 0 {
+		t.Errorf("Expected 2 checksummed records, got %+v (%v)", result, err)
+	}
+}
+
+func TestStorage_OpenUpgradesVersion2(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+
+	// Untyped records of version 2 files: ID, flags, sequence number, vector
+	untyped := binary.LittleEndian.AppendUint64(nil, fileMagicV2)
+	untyped = binary.LittleEndian.AppendUint64(untyped, 2)
+	for _, r := range []struct {
+		id, seq uint64
+		flags   byte
+		value   float32
+	}{{1, 1, 0, 1}, {2, 2, 0, 2}, {2, 3, flagDeleted, 2}, {3, 4, 0, 3}} {
+		untyped = binary.LittleEndian.AppendUint64(untyped, r.id)
+		untyped = append(untyped, r.flags)
+		untyped = binary.LittleEndian.AppendUint64(untyped, r.seq)
+		for i := 0; i < 4; i++ {
+			untyped = binary.LittleEndian.AppendUint32(untyped, math.Float32bits(r.value))
+		}
+	}
+
+	if err := os.WriteFile(tmpFile, untyped, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if s.headerLen != headerSizeV4 {
+		t.Errorf("Expected Open to upgrade the header, got size %d", s.headerLen)
+	}
+	if vector, err := s.ReadVector(1); err != nil || vector[0] != 1 {
+		t.Errorf("Expected to read back vector 1, got %v, %v", vector, err)
+	}
+	if id, err := s.NextID(); err != nil || id != 2 {
+		t.Errorf("Expected ID 2, got %d, %v", id, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if s.Seq() != 4 {
+		t.Errorf("Expected the sequence number of the last untyped record, got %d", s.Seq())
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.ReadVector(2); err == nil {
+		t.Error("Expected untyped tombstone to delete vector 2")
+	}
+	if vector, err := s.ReadVector(3); err != nil || vector[3] != 3 {
+		t.Errorf("Expected to read back vector 3, got %v, %v", vector, err)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/binary"
 	"io"
 	"os"
+	"reflect"
 	"testing"
+
+	"github.com/monishSR/veclite/internal/vector"
 )
 
 func TestStorage_LoadIndex_ErrorCases(t *testing.T) {
@@ -985,6 +988,81 @@ func TestStorage_RebuildIndex_DataEndNegative(t *testing.T) {
 	// but the error path (dataEnd < 0 -> dataEnd = 0) was tested
 }
 
+func TestStorage_FindDataEnd_CountAndDimensionBounds(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+	if err := s.WriteVector(1, []float32{1.0, 2.0, 3.0, 4.0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	fileInfo, _ := s.file.Stat()
+	fileSize := fileInfo.Size()
+	if dataEnd, dim, err := s.findDataEnd(fileSize); err != nil || dataEnd != fileSize-12-16 || dim != 4 {
+		t.Fatalf("Expected the footer of one entry, got data end %d of %d, dimension %d (%v)", dataEnd, fileSize, dim, err)
+	}
+
+	// count*16 wraps to 0 in 32 bits, and a dimension no record of the file
+	// can hold: both are rejected instead of trusted
+	for _, tc := range []struct {
+		name       string
+		dim, count uint32
+	}{
+		{"wrapping count", 4, 1 << 28},
+		{"huge dimension", 1 << 30, 1},
+	} {
+		if _, err := s.file.Seek(-12, io.SeekEnd); err != nil {
+			t.Fatalf("Seek failed: %v", err)
+		}
+		if err := binary.Write(s.file, binary.LittleEndian, []uint32{tc.dim, tc.count}); err != nil {
+			t.Fatalf("Failed to corrupt the footer: %v", err)
+		}
+		if dataEnd, dim, err := s.findDataEnd(fileSize); err != nil || dataEnd != fileSize || dim != 4 {
+			t.Errorf("%s: expected a scan of the whole file, got data end %d of %d, dimension %d (%v)", tc.name, dataEnd, fileSize, dim, err)
+		}
+	}
+}
+
+func TestStorage_FindDataEnd_EmptyFileWithLargeDimension(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	// The footer of a file without vectors records a dimension larger than the file
+	s, err := NewStorage(tmpFile, 128, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	fileInfo, _ := s.file.Stat()
+	if dataEnd, dim, err := s.findDataEnd(fileInfo.Size()); err != nil || dataEnd != fileInfo.Size()-12 || dim != 128 {
+		t.Errorf("Expected an empty footer of dimension 128, got data end %d of %d, dimension %d (%v)", dataEnd, fileInfo.Size(), dim, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close after reopen failed: %v", err)
+	}
+}
+
 func TestStorage_RebuildIndex_SeekMetadataErrors(t *testing.T) {
 	tmpFile := createTempFile(t)
 	defer os.Remove(tmpFile)
@@ -2485,7 +2563,7 @@ func TestStorage_ScanDataSection_SeekCurrentError(t *testing.T) {
 	s.file = nil
 
 	// scanDataSection should error when file is nil
-	err = s.scanDataSection(100, 4)
+	err = s.scanDataSection(100, 4, false)
 	if err == nil {
 		t.Error("Expected error when scanning with closed file")
 	}
@@ -2523,15 +2601,15 @@ func TestStorage_ScanDataSection_ReadIDError(t *testing.T) {
 	}
 	defer s2.Close()
 
-	// Seek to start
-	if _, err := s2.file.Seek(0, io.SeekStart); err != nil {
+	// Seek to the first record
+	if _, err := s2.file.Seek(headerSizeV4, io.SeekStart); err != nil {
 		t.Fatalf("Seek failed: %v", err)
 	}
 
 	// scanDataSection should handle EOF on ID read
 	fileInfo, _ := s2.file.Stat()
 	fileSize := fileInfo.Size()
-	err = s2.scanDataSection(fileSize, 4)
+	err = s2.scanDataSection(fileSize, 4, false)
 	if err != nil {
 		t.Fatalf("scanDataSection should handle EOF gracefully: %v", err)
 	}
@@ -2577,15 +2655,15 @@ func TestStorage_ScanDataSection_SeekVectorSizeError(t *testing.T) {
 	}
 	defer s2.Close()
 
-	// Seek to start
-	if _, err := s2.file.Seek(0, io.SeekStart); err != nil {
+	// Seek to the first record
+	if _, err := s2.file.Seek(headerSizeV4, io.SeekStart); err != nil {
 		t.Fatalf("Seek failed: %v", err)
 	}
 
 	// scanDataSection should handle EOF on vector size seek
 	fileInfo, _ := s2.file.Stat()
 	fileSize := fileInfo.Size()
-	err = s2.scanDataSection(fileSize, 4)
+	err = s2.scanDataSection(fileSize, 4, false)
 	if err != nil {
 		t.Fatalf("scanDataSection should handle EOF gracefully: %v", err)
 	}
@@ -2748,4 +2826,350 @@ func TestStorage_SaveIndex_WriteMetadataError(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error when Write fails for metadata in saveIndex")
 	}
-}
\ No newline at end of file
+}
+func TestStorage_RebuildIndex_Exported(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := s.WriteVector(i, []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.DeleteVector(2); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+
+	s.index = map[uint64]int64{42: 0} // Simulate a wrong footer
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if len(s.index) != 2 {
+		t.Fatalf("Expected 2 indexed vectors, got %v", s.index)
+	}
+	if vec, err := s.ReadVector(3); err != nil || vec[0] != 3 {
+		t.Errorf("Expected vector 3 after rebuild, got %v (err %v)", vec, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dim, err := FooterDimension(tmpFile)
+	if err != nil || dim != 4 {
+		t.Errorf("Expected footer dimension 4, got %d (err %v)", dim, err)
+	}
+	if _, err := FooterDimension(tmpFile + ".missing"); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestStorage_BlockBounds(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 2, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if s.Blocks() != nil {
+		t.Fatal("Expected no blocks before EnableBlockBounds")
+	}
+	for i := 1; i <= 3; i++ {
+		if err := s.WriteVector(uint64(i), []float32{float32(i), -float32(i)}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.EnableBlockBounds(2); err != nil {
+		t.Fatalf("EnableBlockBounds failed: %v", err)
+	}
+	if err := s.WriteVectors([]uint64{4, 5}, [][]float32{{4, -4}, {5, -5}}); err != nil {
+		t.Fatalf("WriteVectors failed: %v", err)
+	}
+	want := []Block{
+		{IDs: []uint64{1, 2}, Min: []float32{1, -2}, Max: []float32{2, -1}},
+		{IDs: []uint64{3, 4}, Min: []float32{3, -4}, Max: []float32{4, -3}},
+		{IDs: []uint64{5}, Min: []float32{5, -5}, Max: []float32{5, -5}},
+	}
+	got := s.Blocks()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Blocks = %v, want %v", got, want)
+	}
+	if again := s.Blocks(); &again[0] != &got[0] {
+		t.Error("Expected Blocks to share its snapshot until the next write")
+	}
+
+	// Updates move the ID to the last block; deletes drop it, leaving the bounds
+	if err := s.WriteVector(1, []float32{10, 0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.DeleteVector(3); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	want = []Block{
+		{IDs: []uint64{2}, Min: []float32{1, -2}, Max: []float32{2, -1}},
+		{IDs: []uint64{4}, Min: []float32{3, -4}, Max: []float32{4, -3}},
+		{IDs: []uint64{5, 1}, Min: []float32{5, -5}, Max: []float32{10, 0}},
+	}
+	if got := s.Blocks(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Blocks after update and delete = %v, want %v", got, want)
+	}
+
+	// Compaction tightens the bounds over the new layout, as does RebuildIndex
+	s.SetLayoutOrder([]uint64{1, 2, 4, 5})
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	want = []Block{
+		{IDs: []uint64{1, 2}, Min: []float32{2, -2}, Max: []float32{10, 0}},
+		{IDs: []uint64{4, 5}, Min: []float32{4, -5}, Max: []float32{5, -4}},
+	}
+	if got := s.Blocks(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Blocks after Compact = %v, want %v", got, want)
+	}
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if got := s.Blocks(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Blocks after RebuildIndex = %v, want %v", got, want)
+	}
+
+	if err := s.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if got := s.Blocks(); got == nil || len(got) != 0 {
+		t.Errorf("Expected no blocks after Clear, got %v", got)
+	}
+}
+
+func TestStorage_Pivots(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 3, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.EnablePivots(0); err == nil {
+		t.Error("Expected a pivot count of 0 to be rejected")
+	}
+	if err := s.EnablePivots(3); err != nil {
+		t.Fatalf("EnablePivots failed: %v", err)
+	}
+	if s.PivotQuery([]float32{0, 0, 0}) != nil {
+		t.Error("Expected no pivot query without vectors")
+	}
+
+	// The first vectors become pivots, the rest get distances to them
+	vectors := make(map[uint64][]float32)
+	for i := uint64(1); i <= 50; i++ {
+		vec := []float32{float32(i % 7), float32(i % 5), float32(i) / 10}
+		vectors[i] = vec
+		if err := s.WriteVector(i, vec); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if got := len(s.pivots.pivots); got != 3 {
+		t.Fatalf("Expected 3 pivots, got %d", got)
+	}
+	if s.pivots.sampled != 3 {
+		t.Errorf("Expected the pivots to be chosen once 3 vectors were stored, got %d", s.pivots.sampled)
+	}
+	for _, query := range [][]float32{{0, 0, 0}, {3, 2, 2.5}, {-4, 9, 1}} {
+		q := s.PivotQuery(query)
+		for id, vec := range vectors {
+			bound, ok := q.LowerBound(id)
+			if !ok {
+				t.Fatalf("No pivot bound for vector %d", id)
+			}
+			if dist := vector.L2Distance(query, vec); bound > dist {
+				t.Errorf("Bound %v exceeds the distance %v from %v to vector %d", bound, dist, query, id)
+			}
+		}
+	}
+
+	if err := s.DeleteVector(7); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if _, ok := s.PivotQuery(vectors[1]).LowerBound(7); ok {
+		t.Error("Expected no bound for a deleted vector")
+	}
+
+	// Queries share a snapshot until the next write, and keep theirs after it
+	q := s.PivotQuery(vectors[1])
+	shared := s.pivots.snapshot.Load()
+	s.PivotQuery(vectors[2])
+	if shared == nil || s.pivots.snapshot.Load() != shared {
+		t.Fatal("Expected a shared pivot snapshot")
+	}
+	if err := s.WriteVector(51, vectors[1]); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if s.pivots.snapshot.Load() != nil {
+		t.Error("Expected a write to drop the pivot snapshot")
+	}
+	if _, ok := q.LowerBound(51); ok {
+		t.Error("Expected no bound for a vector written after the query")
+	}
+	if _, ok := s.PivotQuery(vectors[1]).LowerBound(51); !ok {
+		t.Error("Expected a bound for vector 51 in a new query")
+	}
+	if err := s.RebuildIndex(); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if _, ok := q.LowerBound(1); !ok {
+		t.Error("Expected a query from before RebuildIndex to keep its bounds")
+	}
+	if _, ok := s.PivotQuery(vectors[1]).LowerBound(1); !ok {
+		t.Error("Expected bounds after RebuildIndex")
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if s.PivotQuery(vectors[1]) != nil {
+		t.Error("Expected no pivot query after Clear")
+	}
+}
+
+func TestStorage_LoadIndex_OffsetOutOfRange(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.WriteVector(1, []float32{1.0, 2.0, 3.0, 4.0}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Point the only index entry past the end of the file (8 bytes before the metadata)
+	file, err := os.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	if _, err := file.Seek(-12-8, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	if err := binary.Write(file, binary.LittleEndian, int64(1)<<40); err != nil {
+		t.Fatalf("Failed to write offset: %v", err)
+	}
+	file.Close()
+
+	// loadIndex rejects the entry and rebuildIndex recovers the vector
+	s2, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s2.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s2.Close()
+	vec, err := s2.ReadVector(1)
+	if err != nil {
+		t.Fatalf("ReadVector failed: %v", err)
+	}
+	if !reflect.DeepEqual(vec, []float32{1.0, 2.0, 3.0, 4.0}) {
+		t.Errorf("Expected the written vector, got %v", vec)
+	}
+}
+
+func TestStorage_DeleteAfterSync_Crash(t *testing.T) {
+	tmpFile := createTempFile(t)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for id := uint64(1); id <= 2; id++ {
+		if err := s.WriteVector(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	synced, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	offset := s.index[1]
+
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A stale index entry never serves the tombstoned record
+	s.index[1] = offset
+	if _, err := s.ReadVector(1); err == nil {
+		t.Error("Expected ReadVector of a tombstoned record to fail")
+	}
+
+	// Crash: the footer saved by Sync is gone, so the scan sees the tombstone
+	s.file.Close()
+	s.file = nil
+	reopen := func() *Storage {
+		t.Helper()
+		s, err := NewStorage(tmpFile, 4, 0)
+		if err != nil {
+			t.Fatalf("NewStorage failed: %v", err)
+		}
+		if err := s.Open(); err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		return s
+	}
+	s = reopen()
+	if s.Has(1) || !s.Has(2) {
+		t.Errorf("Expected only vector 2 after the crash, got %v", s.IDs())
+	}
+	if vec, err := s.ReadVector(1); err == nil {
+		t.Errorf("Expected the deleted vector 1 to stay deleted, got %v", vec)
+	}
+	s.file.Close()
+	s.file = nil
+
+	// Crash with the flag on disk but the footer truncation lost: the footer
+	// still lists vector 1
+	synced[offset+8] |= flagDeleted
+	if err := os.WriteFile(tmpFile, synced, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	s = reopen()
+	defer s.Close()
+	if s.Has(1) || !s.Has(2) {
+		t.Errorf("Expected only vector 2 with the stale footer, got %v", s.IDs())
+	}
+	if vec, err := s.ReadVector(1); err == nil {
+		t.Errorf("Expected the deleted vector 1 to stay deleted, got %v", vec)
+	}
+}
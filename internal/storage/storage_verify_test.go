@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeVerifyFile creates a closed data file with vectors 1..n of dimension 4
+func writeVerifyFile(t *testing.T, n int) string {
+	t.Helper()
+	tmpFile := createTempFile(t)
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if err := s.WriteVector(uint64(i), []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("WriteVector failed: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return tmpFile
+}
+
+// problemKinds returns the kinds of problems in result, in order
+func problemKinds(result *VerifyResult) string {
+	kinds := make([]string, 0, len(result.Problems))
+	for _, p := range result.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return strings.Join(kinds, ",")
+}
+
+func TestVerify_Clean(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 5)
+	defer os.Remove(tmpFile)
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.Footer || result.Indexed != 5 || result.Records != 5 || result.Checksums != 5 || len(result.Problems) != 0 {
+		t.Errorf("Expected a clean file with 5 records, got %+v", result)
+	}
+}
+
+func TestVerify_Problems(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 3)
+	defer os.Remove(tmpFile)
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	// Put a NaN into the second record and drop the footer, leaving a partial
+	// record; records are 42 bytes: a 26 byte header with checksum and 16 bytes of data
+	binary.LittleEndian.PutUint32(data[headerSizeV4+42+recordHeaderSize+checksumSize:], math.Float32bits(float32(math.NaN())))
+	data = data[:headerSizeV4+3*42-10]
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	quick, err := Verify(tmpFile, 4, false)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(quick); got != "footer,framing" {
+		t.Errorf("Expected footer and framing problems, got %q", got)
+	}
+	if quick.TornBytes != 32 || quick.Records != 0 {
+		t.Errorf("Expected 32 torn bytes and no record scan, got %+v", quick)
+	}
+
+	deep, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(deep); got != "footer,framing,checksum,record" {
+		t.Errorf("Expected footer, framing, checksum and record problems, got %q", got)
+	}
+	if deep.Records != 2 || deep.Problems[2].ID != 2 || deep.Problems[3].ID != 2 {
+		t.Errorf("Expected 2 records and a NaN in vector 2, got %+v", deep)
+	}
+
+	// Verify never modifies the file
+	after, _ := os.ReadFile(tmpFile)
+	if len(after) != len(data) {
+		t.Errorf("Verify changed the file size from %d to %d", len(data), len(after))
+	}
+}
+
+func TestStorage_ChecksumFailureOnRead(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 5)
+	defer os.Remove(tmpFile)
+
+	// Flip a payload byte of vector 5; records are 42 bytes
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[headerSizeV4+4*42+recordHeaderSize+checksumSize] ^= 0x01
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := s.ReadVector(5); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum reading vector 5, got %v", err)
+	}
+	if _, err := s.ReadVector(4); err != nil {
+		t.Errorf("ReadVector(4) failed: %v", err)
+	}
+	// Compaction must not checksum the corrupted bytes anew
+	if err := s.Compact(); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected Compact to refuse with ErrChecksum, got %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(result); got != "checksum" || result.Problems[0].ID != 5 {
+		t.Errorf("Expected the checksum problem of vector 5 to survive Close, got %+v", result.Problems)
+	}
+}
+
+func TestStorage_ChecksumFailureSuperseded(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 2)
+	defer os.Remove(tmpFile)
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[headerSizeV4+42+recordHeaderSize+checksumSize] ^= 0x01 // Vector 2
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	// Rewriting the vector supersedes the corrupted record, which compacts away
+	if err := s.WriteVector(2, []float32{2, 2, 2, 2}); err != nil {
+		t.Fatalf("WriteVector failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(result.Problems) != 0 || result.Records != 2 {
+		t.Errorf("Expected a clean compacted file with 2 records, got %+v", result)
+	}
+}
+
+func TestVerify_FooterMismatch(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 2)
+	defer os.Remove(tmpFile)
+
+	// Swap the offsets of the two footer entries
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	footer := data[headerSizeV4+2*42:]
+	first, second := binary.LittleEndian.Uint64(footer[8:]), binary.LittleEndian.Uint64(footer[24:])
+	binary.LittleEndian.PutUint64(footer[8:], second)
+	binary.LittleEndian.PutUint64(footer[24:], first)
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(result); got != "footer,footer" {
+		t.Errorf("Expected two footer problems, got %q (%+v)", got, result.Problems)
+	}
+}
+
+func TestVerify_UnknownFlags(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 2)
+	defer os.Remove(tmpFile)
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[headerSizeV4+42+8] = 0x80 // Flags of the second record
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(result); got != "record" || result.Problems[0].ID != 2 {
+		t.Errorf("Expected a record problem for vector 2, got %+v", result.Problems)
+	}
+}
+
+func TestVerify_Checksum(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 3)
+	defer os.Remove(tmpFile)
+
+	// Deletes rewrite the flags and sequence number, which the checksum leaves out
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := s.Discard(); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	result, err := Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if result.Checksums != 3 || result.Tombstones != 1 || len(result.Problems) != 0 {
+		t.Errorf("Expected 3 valid checksums and a tombstone, got %+v", result)
+	}
+
+	// A flipped bit that leaves the value finite is only caught by the checksum
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[headerSizeV4+2*42+recordHeaderSize+checksumSize+4] ^= 0x01 // Second value of the third record
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if quick, err := Verify(tmpFile, 4, false); err != nil || len(quick.Problems) != 0 {
+		t.Errorf("Expected the quick check to miss the flipped bit, got %+v (%v)", quick, err)
+	}
+	result, err = Verify(tmpFile, 4, true)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := problemKinds(result); got != "checksum" || result.Problems[0].ID != 3 || result.Problems[0].Offset != headerSizeV4+2*42 {
+		t.Errorf("Expected a checksum problem for vector 3, got %+v", result.Problems)
+	}
+}
+
+func TestStorage_Discard(t *testing.T) {
+	tmpFile := writeVerifyFile(t, 2)
+	defer os.Remove(tmpFile)
+
+	s, err := NewStorage(tmpFile, 4, 0)
+	if err != nil {
+		t.Fatalf("NewStorage failed: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := s.DeleteVector(1); err != nil {
+		t.Fatalf("DeleteVector failed: %v", err)
+	}
+	before, _ := os.ReadFile(tmpFile) // The delete dropped the footer
+	if err := s.Discard(); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close after Discard failed: %v", err)
+	}
+	after, _ := os.ReadFile(tmpFile)
+	if len(after) != len(before) {
+		t.Errorf("Discard compacted or rewrote the footer: %d -> %d bytes", len(before), len(after))
+	}
+}
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// VerifyResult describes the state of a data file as found by Verify
+type VerifyResult struct {
+	Footer     bool  // A footer index was found and parsed
+	Indexed    int   // Entries in the footer index
+	Records    int   // Whole records in the data section besides a file info record (deep only)
+	Tombstones int   // Tombstoned records (deep only)
+	Checksums  int   // Records whose checksum was validated, including a file info record (deep only)
+	TornBytes  int64 // Trailing bytes of the data section that don't form a whole record
+	Problems   []Problem
+}
+
+// Problem is one inconsistency found by Verify
+type Problem struct {
+	Kind    string // "footer", "framing", "record" or "checksum"
+	ID      uint64 // Affected vector ID (0 if not specific to one)
+	Offset  int64  // Affected file offset (-1 if not specific to one)
+	Message string
+}
+
+// Verify inspects the data file at path without modifying it
+// It checks that the footer index is present and that its entries point at
+// whole records inside the data section; deep additionally reads every record,
+// validating record headers and framing, the checksum of every record that
+// has one (files written in version 5), that each footer entry points at a
+// vector record with its ID, and that vector values are finite
+func Verify(path string, dimension int, deep bool) (*VerifyResult, error) {
+	if dimension <= 0 {
+		return nil, errors.New("dimension must be greater than 0")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := info.Size()
+
+	s := &Storage{filePath: path, file: file, dimension: dimension}
+	result := &VerifyResult{}
+	problem := func(kind string, id uint64, offset int64, format string, args ...any) {
+		result.Problems = append(result.Problems, Problem{Kind: kind, ID: id, Offset: offset, Message: fmt.Sprintf(format, args...)})
+	}
+
+	dataEnd := fileSize
+	if fileSize > 0 {
+		if err := s.loadIndex(); err != nil {
+			problem("footer", 0, -1, "footer index unusable (%v); it is rebuilt by scanning the data section on open", err)
+			s.index = nil
+		} else {
+			result.Footer = true
+			result.Indexed = len(s.index)
+			dataEnd = fileSize - s.footerSize
+		}
+	}
+
+	h, err := s.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	start, legacy := min(h.start, dataEnd), h.legacy
+	s.legacy, s.headerLen, s.align, s.checksums = legacy, h.start, h.align, h.checksums
+	if h.start == headerSizeV2 {
+		problem("framing", 0, start, "version 2 file with untyped records; it is rewritten in the current version on open")
+		return result, nil
+	}
+
+	// The quick check frames the data section assuming every record after a
+	// leading file info record is a vector record; the deep check walks the
+	// record headers
+	first := min(start+s.readInfoLen(start, legacy), dataEnd)
+	var headers map[int64]recordHeader // Offset -> header of each whole record (deep only)
+	var recordProblems []Problem
+	framedEnd := dataEnd // Records at or after framedEnd can't be located
+	if deep {
+		headers = make(map[int64]recordHeader)
+		offset, badHeader, err := s.verifyRecords(file, start, dataEnd, legacy, headers, &recordProblems)
+		if err != nil {
+			return nil, err
+		}
+		if badHeader {
+			framedEnd = offset
+		} else {
+			result.TornBytes = dataEnd - offset
+		}
+		result.Records = len(headers)
+		if rec, ok := headers[start]; ok && rec.kind == recordInfo {
+			result.Records-- // Not part of the data
+		}
+	} else {
+		result.TornBytes = (dataEnd - first) % s.recordSize()
+	}
+	if result.TornBytes != 0 {
+		problem("framing", 0, dataEnd-result.TornBytes, "data section ends with %d bytes of a partial record", result.TornBytes)
+	}
+	wholeEnd := dataEnd - result.TornBytes
+
+	// Footer entries must point at record boundaries inside the data section
+	recordSize := s.recordSize()
+	for id, offset := range s.index {
+		h, framed := headers[offset]
+		switch {
+		case legacy && id == legacyDeletedID:
+			problem("footer", id, offset, "footer indexes the tombstone ID")
+		case offset < start || offset+recordSize > wholeEnd:
+			problem("footer", id, offset, "footer offset %d of vector %d is outside the data section", offset, id)
+		case !deep && (offset < first || (offset-first)%recordSize != 0), deep && !framed && offset < framedEnd:
+			problem("footer", id, offset, "footer offset %d of vector %d is not on a record boundary", offset, id)
+		case !framed:
+		case h.kind != recordVector:
+			problem("footer", id, offset, "footer points vector %d at a record of type %d", id, h.kind)
+		case h.deleted():
+			problem("footer", id, offset, "footer points vector %d at a tombstoned record", id)
+		case h.id != id:
+			problem("footer", id, offset, "footer points vector %d at a record of vector %d", id, h.id)
+		}
+	}
+	for _, h := range headers {
+		if h.deleted() {
+			result.Tombstones++
+		}
+		if h.flags&flagChecksum != 0 {
+			result.Checksums++
+		}
+	}
+	result.Problems = append(result.Problems, recordProblems...)
+	return result, nil
+}
+
+// verifyRecords reads the records from start to dataEnd into headers, adding
+// a problem for every record whose checksum doesn't match and every vector
+// with a non-finite value. It returns where the
+// whole records end, and whether a record with an invalid header (also
+// reported as a problem) stopped the walk there
+func (s *Storage) verifyRecords(file File, start, dataEnd int64, legacy bool, headers map[int64]recordHeader, problems *[]Problem) (int64, bool, error) {
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, false, err
+	}
+	reader := bufio.NewReader(io.LimitReader(file, dataEnd-start))
+	var payload []byte
+	offset := start
+	for offset < dataEnd {
+		h, err := readRecordHeader(reader, s.dimension, legacy)
+		pad := s.padding(h.size(legacy))
+		if err == nil && h.size(legacy)+pad > dataEnd-offset {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == errBadRecord {
+			*problems = append(*problems, Problem{Kind: "record", ID: h.id, Offset: offset,
+				Message: fmt.Sprintf("record at offset %d has an invalid header (flags %#x, type %d, length %d); later records can't be located", offset, h.flags, h.kind, h.length)})
+			return offset, true, nil
+		}
+		if err != nil {
+			if endOfRecords(err) {
+				return offset, false, nil
+			}
+			return 0, false, fmt.Errorf("failed to read record at offset %d: %w", offset, err)
+		}
+
+		if int(h.length) > cap(payload) {
+			payload = make([]byte, h.length)
+		}
+		payload = payload[:h.length]
+		_, err = io.ReadFull(reader, payload)
+		if err == nil {
+			_, err = reader.Discard(int(pad))
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to read record at offset %d: %w", offset, err)
+		}
+		headers[offset] = h
+		if h.flags&flagChecksum != 0 {
+			if sum := recordChecksum(h, payload); sum != h.crc {
+				*problems = append(*problems, Problem{Kind: "checksum", ID: h.id, Offset: offset,
+					Message: fmt.Sprintf("record at offset %d fails its checksum (stored %#08x, computed %#08x)", offset, h.crc, sum)})
+			}
+		}
+		if h.kind == recordVector && !h.deleted() {
+			if len(payload) != s.dimension*4 {
+				*problems = append(*problems, Problem{Kind: "record", ID: h.id, Offset: offset,
+					Message: fmt.Sprintf("vector %d has %d dimensions, expected %d", h.id, len(payload)/4, s.dimension)})
+			}
+			for i := 0; i < len(payload); i += 4 {
+				value := math.Float32frombits(binary.LittleEndian.Uint32(payload[i:]))
+				if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+					*problems = append(*problems, Problem{Kind: "record", ID: h.id, Offset: offset,
+						Message: fmt.Sprintf("vector %d has a non-finite value at dimension %d", h.id, i/4)})
+					break
+				}
+			}
+		}
+		offset += h.size(legacy) + pad
+	}
+	return offset, false, nil
+}
+
+// ErrNeedsUpgrade is returned by OpenReadOnly for version 2 files, which Open
+// rewrites in the current version
+var ErrNeedsUpgrade = errors.New("version 2 file is rewritten in the current version on open")
+
+// OpenReadOnly opens the storage file without ever changing it, e.g. to
+// inspect it: the index is loaded from the footer or rebuilt by a scan in
+// memory, and torn bytes are left in place instead of quarantined
+// Writes fail; Close only closes the file
+func (s *Storage) OpenReadOnly() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := s.openFile(s.filePath, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	s.file, s.readOnly = file, true
+
+	h, err := s.readHeader()
+	if err != nil {
+		s.file.Close()
+		s.file = nil
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if h.start == headerSizeV2 {
+		s.file.Close()
+		s.file = nil
+		return ErrNeedsUpgrade
+	}
+	s.seq, s.legacy, s.headerLen, s.align, s.checksums = h.seq, h.legacy, h.start, h.align, h.checksums
+	s.idLimit, s.nextID = h.idLimit, max(h.idLimit, 1)
+	s.infoLen = s.readInfoLen(h.start, h.legacy)
+
+	err = s.loadIndex()
+	if err == nil {
+		err = s.dropTombstoned()
+	}
+	if err != nil {
+		return s.rebuildIndex()
+	}
+	return nil
+}
+
+// Discard closes the file without compacting or writing the footer index,
+// leaving it as it was opened (for read-only inspection)
+func (s *Storage) Discard() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,112 @@
+package throttle
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// burst is how far ahead of the configured rate a caller may run before waiting
+const burst = 100 * time.Millisecond
+
+// Throttle paces background work to a byte rate and a CPU budget
+// A nil *Throttle never waits, so callers don't need to check for it
+type Throttle struct {
+	bytesPerSecond int64   // Max bytes per second (0 = unlimited)
+	cpuBudget      float64 // Fraction of one core background work may use (0 or >= 1 = unlimited)
+
+	mu     sync.Mutex
+	next   time.Time     // When the bytes accounted so far are paid off at bytesPerSecond
+	bytes  int64         // Total bytes accounted
+	waited time.Duration // Total time spent waiting
+
+	waiting atomic.Int32 // Callers currently waiting
+
+	now   func() time.Time    // Clock (replaced in tests)
+	sleep func(time.Duration) // Sleep (replaced in tests)
+}
+
+// State is a snapshot of a throttle
+type State struct {
+	BytesPerSecond int64         // Configured byte rate (0 = unlimited)
+	CPUBudget      float64       // Configured CPU budget (0 = unlimited)
+	Bytes          int64         // Bytes of background I/O accounted so far
+	Waited         time.Duration // Total time background work has been held back
+	Throttling     bool          // Background work is being held back right now
+}
+
+// New creates a throttle; it returns nil if neither limit is set
+func New(bytesPerSecond int64, cpuBudget float64) *Throttle {
+	if cpuBudget >= 1 {
+		cpuBudget = 0
+	}
+	if bytesPerSecond <= 0 && cpuBudget <= 0 {
+		return nil
+	}
+	return &Throttle{
+		bytesPerSecond: max(bytesPerSecond, 0),
+		cpuBudget:      max(cpuBudget, 0),
+		now:            time.Now,
+		sleep:          time.Sleep,
+	}
+}
+
+// WaitBytes accounts n bytes of I/O and waits while the byte rate is exceeded
+func (t *Throttle) WaitBytes(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.bytes += n
+	if t.bytesPerSecond <= 0 {
+		t.mu.Unlock()
+		return
+	}
+	now := t.now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(float64(n) / float64(t.bytesPerSecond) * float64(time.Second)))
+	wait := t.next.Sub(now) - burst
+	t.mu.Unlock()
+
+	t.wait(wait)
+}
+
+// Pace waits after busy of CPU-bound work so it stays within the CPU budget
+func (t *Throttle) Pace(busy time.Duration) {
+	if t == nil || t.cpuBudget <= 0 || busy <= 0 {
+		return
+	}
+	t.wait(time.Duration(float64(busy) * (1 - t.cpuBudget) / t.cpuBudget))
+}
+
+// wait sleeps for d, recording it in the state
+func (t *Throttle) wait(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t.waiting.Add(1)
+	t.sleep(d)
+	t.waiting.Add(-1)
+
+	t.mu.Lock()
+	t.waited += d
+	t.mu.Unlock()
+}
+
+// State returns a snapshot of the throttle (zero value for nil)
+func (t *Throttle) State() State {
+	if t == nil {
+		return State{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return State{
+		BytesPerSecond: t.bytesPerSecond,
+		CPUBudget:      t.cpuBudget,
+		Bytes:          t.bytes,
+		Waited:         t.waited,
+		Throttling:     t.waiting.Load() > 0,
+	}
+}
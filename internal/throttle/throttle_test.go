@@ -0,0 +1,106 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances time only when the throttle sleeps
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestThrottle(bytesPerSecond int64, cpuBudget float64) (*Throttle, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	th := New(bytesPerSecond, cpuBudget)
+	th.now = clock.Now
+	th.sleep = clock.Sleep
+	return th, clock
+}
+
+func TestNew_Unlimited(t *testing.T) {
+	if th := New(0, 0); th != nil {
+		t.Error("Expected nil throttle without limits")
+	}
+	if th := New(0, 1); th != nil {
+		t.Error("Expected nil throttle for a full CPU budget")
+	}
+
+	// A nil throttle is a no-op
+	var th *Throttle
+	th.WaitBytes(1 << 30)
+	th.Pace(time.Hour)
+	if state := th.State(); state != (State{}) {
+		t.Errorf("Expected zero state for nil throttle, got %+v", state)
+	}
+}
+
+func TestThrottle_WaitBytes(t *testing.T) {
+	th, clock := newTestThrottle(1000, 0)
+	start := clock.Now()
+
+	// 10 KB at 1 KB/s takes ~10s, minus the allowed burst
+	for i := 0; i < 100; i++ {
+		th.WaitBytes(100)
+	}
+	elapsed := clock.Now().Sub(start)
+	if elapsed < 9*time.Second || elapsed > 10*time.Second {
+		t.Errorf("Expected ~10s of pacing, got %v", elapsed)
+	}
+
+	state := th.State()
+	if state.Bytes != 10000 || state.BytesPerSecond != 1000 {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+	if state.Waited != elapsed {
+		t.Errorf("Expected waited %v, got %v", elapsed, state.Waited)
+	}
+	if state.Throttling {
+		t.Error("Expected no caller to be waiting")
+	}
+}
+
+func TestThrottle_Pace(t *testing.T) {
+	th, clock := newTestThrottle(0, 0.25)
+	start := clock.Now()
+
+	// At a 25% budget, 1s of work is followed by 3s of rest
+	th.Pace(time.Second)
+	if elapsed := clock.Now().Sub(start); elapsed != 3*time.Second {
+		t.Errorf("Expected 3s pause, got %v", elapsed)
+	}
+	if state := th.State(); state.CPUBudget != 0.25 || state.Waited != 3*time.Second {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+}
+
+func TestThrottle_StateWhileWaiting(t *testing.T) {
+	th := New(1, 0)
+	release := make(chan struct{})
+	sleeping := make(chan struct{})
+	th.sleep = func(time.Duration) {
+		close(sleeping)
+		<-release
+	}
+
+	go th.WaitBytes(10)
+	<-sleeping
+	if !th.State().Throttling {
+		t.Error("Expected Throttling while a caller waits")
+	}
+	close(release)
+}
@@ -0,0 +1,334 @@
+package docstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Tokenizers of an Analyzer
+const (
+	TokenizerUnicode    = "unicode"    // Runs of letters and digits (default)
+	TokenizerWhitespace = "whitespace" // Runs of non-space characters, punctuation included
+	TokenizerCJK        = "cjk"        // Like unicode, with Han, kana and Hangul split into overlapping bigrams
+)
+
+// Analyzer configures how chunk texts and queries are split into terms for the
+// lexical index; texts and queries always go through the same analyzer
+// The zero value splits on letters and digits and lowercases, without
+// stopwords or stemming
+type Analyzer struct {
+	Tokenizer     string   `json:"tokenizer,omitempty"`      // TokenizerUnicode (default), TokenizerWhitespace or TokenizerCJK
+	CaseSensitive bool     `json:"case_sensitive,omitempty"` // Keep case instead of lowercasing terms
+	Language      string   `json:"language,omitempty"`       // Stemming language: english, german, french or spanish ("" = no stemming)
+	Stopwords     []string `json:"stopwords,omitempty"`      // Terms left out of the index and queries, e.g. Stopwords("german")
+}
+
+// stemmers are the light stemmers of the supported languages
+var stemmers = map[string]func(term string) string{
+	"english": stemEnglish,
+	"german":  stemGerman,
+	"french":  stemFrench,
+	"spanish": stemSpanish,
+}
+
+// stopwords are the built-in stopword lists of the supported languages
+var stopwords = map[string][]string{
+	"english": {"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into", "is", "it",
+		"no", "not", "of", "on", "or", "such", "that", "the", "their", "then", "there", "these", "they",
+		"this", "to", "was", "will", "with"},
+	"german": {"aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "das", "dass", "dem",
+		"den", "der", "des", "die", "ein", "eine", "einem", "einen", "einer", "es", "für", "im", "in", "ist",
+		"mit", "nicht", "oder", "sich", "sie", "und", "von", "zu", "zum", "zur"},
+	"french": {"à", "au", "aux", "avec", "ce", "ces", "dans", "de", "des", "du", "elle", "en", "est", "et",
+		"il", "je", "la", "le", "les", "leur", "lui", "mais", "ne", "nous", "ou", "par", "pas", "pour",
+		"qu", "que", "qui", "sa", "se", "son", "sur", "un", "une", "vous"},
+	"spanish": {"a", "al", "como", "con", "de", "del", "el", "en", "es", "esta", "la", "las", "lo", "los",
+		"más", "no", "o", "para", "pero", "por", "que", "se", "sin", "su", "sus", "un", "una", "y"},
+}
+
+// Stopwords returns a copy of the built-in stopword list of language, or nil
+// if there is none
+func Stopwords(language string) []string {
+	return append([]string(nil), stopwords[language]...)
+}
+
+// analyzer is a validated Analyzer ready to split texts
+type analyzer struct {
+	config    Analyzer
+	tokenize  func(text string) []token
+	stem      func(term string) string // nil = no stemming
+	stopwords map[string]bool
+}
+
+// newAnalyzer validates config and prepares it for use
+func newAnalyzer(config Analyzer) (*analyzer, error) {
+	a := &analyzer{config: config, stopwords: make(map[string]bool, len(config.Stopwords))}
+	switch config.Tokenizer {
+	case "", TokenizerUnicode:
+		a.tokenize = tokenize
+	case TokenizerWhitespace:
+		a.tokenize = tokenizeWhitespace
+	case TokenizerCJK:
+		a.tokenize = tokenizeCJK
+	default:
+		return nil, fmt.Errorf("docstore: unknown tokenizer %q", config.Tokenizer)
+	}
+	if config.Language != "" {
+		a.stem = stemmers[config.Language]
+		if a.stem == nil {
+			return nil, fmt.Errorf("docstore: unsupported stemming language %q", config.Language)
+		}
+	}
+	for _, word := range config.Stopwords {
+		a.stopwords[a.normalize(word)] = true
+	}
+	return a, nil
+}
+
+// normalize applies the case setting to a term
+func (a *analyzer) normalize(term string) string {
+	if a.config.CaseSensitive {
+		return term
+	}
+	return strings.ToLower(term)
+}
+
+// analyze splits text into indexed terms with the position of each, leaving
+// out stopwords
+func (a *analyzer) analyze(text string) []token {
+	tokens := a.tokenize(text)
+	terms := tokens[:0]
+	for _, t := range tokens {
+		t.term = a.normalize(t.term)
+		if a.stopwords[t.term] {
+			continue
+		}
+		if a.stem != nil {
+			t.term = a.stem(t.term)
+		}
+		terms = append(terms, t)
+	}
+	return terms
+}
+
+// loadAnalyzer returns the analyzer of a store: config if set, saved to path
+// so the collection keeps it, or else the one saved at path, or the default
+func loadAnalyzer(config *Analyzer, path string) (*analyzer, error) {
+	if config != nil {
+		a, err := newAnalyzer(*config)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			if err := saveAnalyzer(path, *config); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+
+	var saved Analyzer
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("docstore: failed to read analyzer: %w", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &saved); err != nil {
+				return nil, fmt.Errorf("docstore: invalid analyzer in %s: %w", path, err)
+			}
+		}
+	}
+	return newAnalyzer(saved)
+}
+
+// saveAnalyzer writes config to path atomically
+func saveAnalyzer(path string, config Analyzer) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("docstore: failed to encode analyzer: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("docstore: failed to write analyzer: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("docstore: failed to save analyzer: %w", err)
+	}
+	return nil
+}
+
+// tokenizeWhitespace splits text on white space
+func tokenizeWhitespace(text string) []token {
+	return splitRuns(text, func(r rune) bool { return !unicode.IsSpace(r) })
+}
+
+// tokenize splits text into runs of letters and digits
+func tokenize(text string) []token {
+	return splitRuns(text, isWordRune)
+}
+
+// isWordRune reports whether r is part of a term for the unicode tokenizer
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// splitRuns returns the maximal runs of runes accepted by inRun
+func splitRuns(text string, inRun func(r rune) bool) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		switch in := inRun(r); {
+		case in && start < 0:
+			start = i
+		case !in && start >= 0:
+			tokens = append(tokens, token{term: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: text[start:], start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// isCJK reports whether r belongs to a script written without spaces between words
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー' // Prolonged sound mark
+}
+
+// tokenizeCJK splits text like tokenize, then splits runs of CJK characters
+// into overlapping bigrams (a single character stays a term of its own), so
+// words are found without a dictionary
+func tokenizeCJK(text string) []token {
+	var tokens []token
+	for _, run := range tokenize(text) {
+		type char struct{ start, end int }
+		var cjk []char // Current run of CJK characters
+		flush := func() {
+			if len(cjk) == 1 {
+				tokens = append(tokens, token{term: text[cjk[0].start:cjk[0].end], start: cjk[0].start, end: cjk[0].end})
+			}
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, token{term: text[cjk[i].start:cjk[i+1].end], start: cjk[i].start, end: cjk[i+1].end})
+			}
+			cjk = cjk[:0]
+		}
+		other := -1 // Start of the current run of other characters
+		for i, r := range run.term {
+			pos := run.start + i
+			if isCJK(r) {
+				if other >= 0 {
+					tokens = append(tokens, token{term: text[other:pos], start: other, end: pos})
+					other = -1
+				}
+				cjk = append(cjk, char{start: pos, end: pos + len(string(r))})
+				continue
+			}
+			flush()
+			if other < 0 {
+				other = pos
+			}
+		}
+		flush()
+		if other >= 0 {
+			tokens = append(tokens, token{term: text[other:run.end], start: other, end: run.end})
+		}
+	}
+	return tokens
+}
+
+// stemEnglish removes plural endings ("queries" -> "query", "vectors" -> "vector")
+func stemEnglish(term string) string {
+	n := len(term)
+	if n < 3 || term[n-1] != 's' {
+		return term
+	}
+	switch term[n-2] {
+	case 'u', 's':
+		return term
+	case 'e':
+		if n > 3 && term[n-3] == 'i' && term[n-4] != 'a' && term[n-4] != 'e' {
+			return term[:n-3] + "y"
+		}
+		if strings.IndexByte("iaoe", term[n-3]) >= 0 {
+			return term
+		}
+	}
+	return term[:n-1]
+}
+
+// stemGerman folds umlauts and removes plural and inflection endings
+// ("Datenbanken" -> "datenbank", "Häuser" -> "haus")
+func stemGerman(term string) string {
+	term = strings.NewReplacer("ä", "a", "ö", "o", "ü", "u").Replace(term)
+	r := []rune(term)
+	n := len(r)
+	if n < 5 {
+		return term
+	}
+	if n > 6 && string(r[n-3:]) == "nen" {
+		return string(r[:n-3])
+	}
+	if n > 5 {
+		switch string(r[n-2:]) {
+		case "en", "se", "es", "er":
+			return string(r[:n-2])
+		}
+	}
+	switch r[n-1] {
+	case 'n', 'e', 's', 'r':
+		return string(r[:n-1])
+	}
+	return term
+}
+
+// stemFrench removes plural and feminine endings ("journaux" -> "journal",
+// "maisons" -> "maison")
+func stemFrench(term string) string {
+	r := []rune(term)
+	n := len(r)
+	if n < 6 {
+		return term
+	}
+	if r[n-1] == 'x' {
+		if r[n-3] == 'a' && r[n-2] == 'u' {
+			r[n-2] = 'l'
+		}
+		return string(r[:n-1])
+	}
+	for _, ending := range []rune{'s', 'r', 'e', 'é'} {
+		if r[n-1] == ending {
+			n--
+		}
+	}
+	if r[n-1] == r[n-2] && unicode.IsLetter(r[n-1]) {
+		n--
+	}
+	return string(r[:n])
+}
+
+// stemSpanish folds accents and removes plural endings ("canciones" -> "cancion")
+func stemSpanish(term string) string {
+	term = strings.NewReplacer("á", "a", "à", "a", "é", "e", "è", "e", "í", "i", "ì", "i",
+		"ó", "o", "ò", "o", "ú", "u", "ù", "u", "ü", "u").Replace(term)
+	n := len(term)
+	if n < 4 || term[n-1] != 's' {
+		return term
+	}
+	switch {
+	case strings.HasSuffix(term, "eses"):
+		return term[:n-2]
+	case strings.HasSuffix(term, "ces"):
+		return term[:n-3] + "z"
+	case strings.HasSuffix(term, "ones"):
+		return term[:n-2]
+	case strings.IndexByte("oae", term[n-2]) >= 0:
+		return term[:n-1]
+	}
+	return term
+}
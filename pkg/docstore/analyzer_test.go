@@ -0,0 +1,110 @@
+package docstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// terms returns the indexed terms of text under config
+func terms(t *testing.T, config Analyzer, text string) []string {
+	t.Helper()
+	a, err := newAnalyzer(config)
+	if err != nil {
+		t.Fatalf("newAnalyzer failed: %v", err)
+	}
+	var out []string
+	for _, tok := range a.analyze(text) {
+		if got := text[tok.start:tok.end]; got == "" {
+			t.Errorf("Empty span for term %q", tok.term)
+		}
+		out = append(out, tok.term)
+	}
+	return out
+}
+
+func equalTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAnalyzer_Pipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Analyzer
+		text   string
+		want   []string
+	}{
+		{"default", Analyzer{}, "The Vectors, indexed.", []string{"the", "vectors", "indexed"}},
+		{"case sensitive", Analyzer{CaseSensitive: true}, "HNSW graph", []string{"HNSW", "graph"}},
+		{"whitespace", Analyzer{Tokenizer: TokenizerWhitespace}, "e-mail c++ code", []string{"e-mail", "c++", "code"}},
+		{"english", Analyzer{Language: "english", Stopwords: Stopwords("english")}, "The queries of the vectors", []string{"query", "vector"}},
+		{"german", Analyzer{Language: "german", Stopwords: Stopwords("german")}, "Die Datenbanken und Häuser", []string{"datenbank", "haus"}},
+		{"french", Analyzer{Language: "french", Stopwords: Stopwords("french")}, "Les journaux des maisons", []string{"journal", "maison"}},
+		{"spanish", Analyzer{Language: "spanish", Stopwords: Stopwords("spanish")}, "Las canciones y luces", []string{"cancion", "luz"}},
+		{"cjk", Analyzer{Tokenizer: TokenizerCJK}, "東京都 tokyo 駅", []string{"東京", "京都", "tokyo", "駅"}},
+		{"cjk mixed run", Analyzer{Tokenizer: TokenizerCJK}, "iPhone用ケース", []string{"iphone", "用ケ", "ケー", "ース"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := terms(t, tt.config, tt.text); !equalTerms(got, tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := newAnalyzer(Analyzer{Language: "klingon"}); err == nil {
+		t.Errorf("Expected an unsupported language to be rejected")
+	}
+	if _, err := newAnalyzer(Analyzer{Tokenizer: "regex"}); err == nil {
+		t.Errorf("Expected an unknown tokenizer to be rejected")
+	}
+}
+
+func TestAnalyzer_PersistedPerCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.analyzer")
+	german := &Analyzer{Language: "german", Stopwords: Stopwords("german")}
+	store, db, _ := createTestStore(t, Options{Model: "test", Lexical: true, Analyzer: german, AnalyzerPath: path})
+	ctx := context.Background()
+	chunks := []Chunk{
+		{ID: 1, Text: "Die Datenbanken speichern Vektoren."},
+		{ID: 2, Text: "Ein Haus mit Garten."},
+	}
+	if _, err := store.Ingest(ctx, chunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	hits, err := store.Search(ctx, "Datenbank", 10, SearchOptions{Mode: SearchLexical})
+	if err != nil || len(hits) != 1 || hits[0].ID != 1 {
+		t.Fatalf("Expected the stemmed plural to match, got %+v, %v", hits, err)
+	}
+	if h := hits[0].Highlights; len(h) != 1 || hits[0].Text[h[0].Start:h[0].End] != "Datenbanken" {
+		t.Errorf("Expected the original word highlighted, got %+v", h)
+	}
+	if hits, _ := store.Search(ctx, "die", 10, SearchOptions{Mode: SearchLexical}); len(hits) != 0 {
+		t.Errorf("Expected stopwords not to match, got %+v", hits)
+	}
+
+	// Reopened without an analyzer, the collection keeps the saved one
+	reopened, err := New(db, &countingEmbedder{}, Options{Model: "test", Lexical: true, AnalyzerPath: path})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	hits, err = reopened.Search(ctx, "Häuser", 10, SearchOptions{Mode: SearchLexical})
+	if err != nil || len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Expected the saved German analyzer, got %+v, %v", hits, err)
+	}
+
+	if _, err := New(db, &countingEmbedder{}, Options{Analyzer: german}); err == nil {
+		t.Errorf("Expected an analyzer without Options.Lexical to be rejected")
+	}
+	if _, err := New(db, &countingEmbedder{}, Options{Lexical: true, Analyzer: &Analyzer{Language: "klingon"}}); err == nil {
+		t.Errorf("Expected an invalid analyzer to be rejected")
+	}
+}
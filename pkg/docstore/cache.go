@@ -0,0 +1,119 @@
+package docstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// embeddingCache keeps recent embeddings by content hash
+// All methods are no-ops on a nil cache
+type embeddingCache struct {
+	path    string // Persisted to this JSON-lines file ("" = memory only)
+	entries *lru.Cache[string, []float32]
+	dirty   bool // Changed since loaded or saved
+}
+
+// cacheRecord is one line of the cache file
+type cacheRecord struct {
+	Hash   string    `json:"hash"`
+	Vector []float32 `json:"vector"`
+}
+
+// openEmbeddingCache creates a cache of size entries, loading path if it exists
+func openEmbeddingCache(path string, size int) (*embeddingCache, error) {
+	entries, err := lru.New[string, []float32](size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache: %w", err)
+	}
+	c := &embeddingCache{path: path, entries: entries}
+	if path == "" {
+		return c, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedding cache: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record cacheRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid embedding cache entry on line %d: %w", line, err)
+		}
+		entries.Add(record.Hash, record.Vector)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	return c, nil
+}
+
+// get returns a copy of the cached embedding of hash
+func (c *embeddingCache) get(hash string) ([]float32, bool) {
+	if c == nil {
+		return nil, false
+	}
+	vector, ok := c.entries.Get(hash)
+	if !ok {
+		return nil, false
+	}
+	return append([]float32(nil), vector...), true
+}
+
+// add caches a copy of the embedding of hash
+func (c *embeddingCache) add(hash string, vector []float32) {
+	if c == nil {
+		return
+	}
+	c.entries.Add(hash, append([]float32(nil), vector...))
+	c.dirty = true
+}
+
+// save writes the cache file atomically (temp file + rename), least recently
+// used entries first so reloading preserves the eviction order
+func (c *embeddingCache) save() error {
+	if c == nil || c.path == "" || !c.dirty {
+		return nil
+	}
+	tmpPath := c.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding cache: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, hash := range c.entries.Keys() { // Oldest first
+		vector, ok := c.entries.Peek(hash)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(cacheRecord{Hash: hash, Vector: vector}); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to encode embedding cache: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write embedding cache: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close embedding cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to replace embedding cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
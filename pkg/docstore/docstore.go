@@ -0,0 +1,428 @@
+// Package docstore ingests text into a VecLite database: chunks are embedded
+// through an Embedder and stored with their text hash, so unchanged chunks are
+// never embedded or written twice, and whole documents can be re-synced by
+// writing only the chunks that changed
+package docstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// Metadata keys reserved by the store
+const (
+	HashKey   = "docstore.hash"   // Content hash of a stored chunk
+	DocKey    = "docstore.doc"    // Document of a chunk stored by Sync
+	TextKey   = "docstore.text"   // Text of a stored chunk, with Options.Lexical
+	FieldsKey = "docstore.fields" // Text fields of a stored chunk, with Options.Lexical
+)
+
+// TextField names Chunk.Text among the text fields of a chunk, e.g. in
+// SearchOptions.Boosts
+const TextField = "text"
+
+// Embedder turns texts into vectors, e.g. a client of an embedding API
+// It must return one vector per text, in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedderFunc adapts a function to the Embedder interface
+type EmbedderFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// Embed calls f
+func (f EmbedderFunc) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return f(ctx, texts)
+}
+
+// Chunk is a piece of text stored as one vector
+type Chunk struct {
+	ID       uint64
+	Text     string
+	Metadata veclite.Metadata // Optional; HashKey, DocKey, TextKey and FieldsKey are reserved
+
+	// Fields are more texts of the chunk (field name -> text, e.g. "title") for
+	// lexical search next to Text, each boostable on its own; they are not
+	// embedded and need Options.Lexical
+	Fields map[string]string
+}
+
+// Options configures a Store
+type Options struct {
+	// Model identifies the embedding model; it is part of the content hash so
+	// switching models re-embeds everything instead of reusing stale vectors
+	Model string
+
+	// CacheSize is the number of embeddings kept in the cache (default: 10000, -1 = disabled)
+	CacheSize int
+
+	// CachePath persists the cache across restarts: loaded by New, saved by Close
+	// ("" = in memory only)
+	CachePath string
+
+	// Lexical stores the text of chunks under TextKey and keeps a BM25 index of
+	// it in memory (rebuilt by New) for lexical and hybrid Search with term
+	// highlights; chunks stored without text are rewritten by their next ingest
+	Lexical bool
+
+	// Analyzer splits texts and queries into terms for the lexical index
+	// (nil = the one saved at AnalyzerPath, or the zero Analyzer)
+	Analyzer *Analyzer
+
+	// AnalyzerPath persists the analyzer of the collection: New saves
+	// Options.Analyzer there, or loads it when Options.Analyzer is nil, so the
+	// collection is always searched with the analyzer it was configured with
+	// ("" = not persisted)
+	AnalyzerPath string
+}
+
+// IngestReport counts what Ingest did with each chunk
+type IngestReport struct {
+	Embedded  int // Chunks sent to the Embedder
+	CacheHits int // Chunks whose embedding came from the cache or a stored chunk, skipping the Embedder
+	Written   int // Chunks written to the database
+	Unchanged int // Chunks already stored with the same text and metadata, skipping the write
+}
+
+// Store ingests chunks into a database
+// Safe for concurrent use; Ingest and Sync calls are serialized
+type Store struct {
+	db       *veclite.VecLite
+	embedder Embedder
+	model    string
+	cache    *embeddingCache // nil = disabled
+	lexical  *lexicalIndex   // nil = disabled
+	mu       sync.Mutex      // Serializes Ingest and Sync
+}
+
+// New creates a store writing to db through embedder
+func New(db *veclite.VecLite, embedder Embedder, opts Options) (*Store, error) {
+	if db == nil || embedder == nil {
+		return nil, errors.New("docstore: database and embedder are required")
+	}
+	s := &Store{db: db, embedder: embedder, model: opts.Model}
+	if opts.CacheSize >= 0 {
+		size := opts.CacheSize
+		if size == 0 {
+			size = 10000
+		}
+		cache, err := openEmbeddingCache(opts.CachePath, size)
+		if err != nil {
+			return nil, err
+		}
+		s.cache = cache
+	}
+	if !opts.Lexical && (opts.Analyzer != nil || opts.AnalyzerPath != "") {
+		return nil, errors.New("docstore: Analyzer requires Options.Lexical")
+	}
+	if opts.Lexical {
+		analyzer, err := loadAnalyzer(opts.Analyzer, opts.AnalyzerPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.buildLexical(analyzer); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// buildLexical indexes the text of every chunk stored in the database
+func (s *Store) buildLexical(analyzer *analyzer) error {
+	s.lexical = newLexicalIndex(analyzer)
+	scroll, err := s.db.Scroll(1000)
+	if err != nil {
+		return fmt.Errorf("docstore: failed to scan chunks: %w", err)
+	}
+	defer scroll.Close()
+	for {
+		items, err := scroll.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("docstore: failed to scan chunks: %w", err)
+		}
+		for _, item := range items {
+			if text, ok := item.Metadata[TextKey].(string); ok {
+				s.lexical.add(item.ID, storedFields(text, item.Metadata))
+			}
+		}
+	}
+}
+
+// Close saves the embedding cache if Options.CachePath is set
+// The database stays open
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cache.save()
+}
+
+// contentHash returns the hash identifying the embedding of text
+func (s *Store) contentHash(text string) string {
+	sum := sha256.Sum256([]byte(s.model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Ingest stores chunks, embedding only texts that are neither stored unchanged
+// nor cached; all missing embeddings are requested in one Embedder call and the
+// writes are applied as one batch (see veclite.Apply)
+// An I/O error can leave some chunks written; calling Ingest again writes the rest
+func (s *Store) Ingest(ctx context.Context, chunks []Chunk) (IngestReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, report, err := s.prepare(ctx, chunks, "", nil)
+	if err != nil {
+		return IngestReport{}, err
+	}
+	if err := s.db.ApplyContext(ctx, batch); err != nil {
+		return IngestReport{}, fmt.Errorf("docstore: failed to write chunks: %w", err)
+	}
+	s.updateLexical(chunks, nil)
+	return report, nil
+}
+
+// SyncReport counts what Sync did
+type SyncReport struct {
+	IngestReport
+	Deleted int // Stored chunks of the document that are no longer part of it
+}
+
+// Sync makes chunks the complete content of document docID: chunks that are
+// stored unchanged are left alone, changed and new chunks are written and
+// stored chunks missing from chunks are deleted, all in one batch so readers
+// never see a document being updated
+// The batch is not atomic (see veclite.Apply): an I/O error can leave the
+// document partly updated, which calling Sync again with the same chunks repairs
+// Text moved to another chunk reuses its stored embedding (matched by content
+// hash) instead of calling the Embedder. Chunks are tagged with DocKey
+func (s *Store) Sync(ctx context.Context, docID string, chunks []Chunk) (SyncReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.db.FilterIDs(veclite.Filter{DocKey: docID})
+	if err != nil {
+		return SyncReport{}, fmt.Errorf("docstore: failed to list chunks of %q: %w", docID, err)
+	}
+	byHash := make(map[string]uint64, len(stored)) // Content hash -> stored chunk ID
+	for _, id := range stored {
+		if metadata, err := s.db.GetMetadata(id); err == nil {
+			if hash, ok := metadata[HashKey].(string); ok {
+				byHash[hash] = id
+			}
+		}
+	}
+
+	batch, ingest, err := s.prepare(ctx, chunks, docID, byHash)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	report := SyncReport{IngestReport: ingest}
+	keep := make(map[uint64]bool, len(chunks))
+	for _, chunk := range chunks {
+		keep[chunk.ID] = true
+	}
+	var deleted []uint64
+	for _, id := range stored {
+		if !keep[id] {
+			batch.Delete(id)
+			deleted = append(deleted, id)
+		}
+	}
+	report.Deleted = len(deleted)
+	if err := s.db.ApplyContext(ctx, batch); err != nil {
+		s.updateLexical(nil, s.gone(deleted))
+		return SyncReport{}, fmt.Errorf("docstore: failed to sync %q: %w", docID, err)
+	}
+	s.updateLexical(chunks, deleted)
+	return report, nil
+}
+
+// gone returns the IDs of ids no longer stored, e.g. the deletes of a batch
+// that landed before it failed
+func (s *Store) gone(ids []uint64) []uint64 {
+	var gone []uint64
+	for _, id := range ids {
+		if _, err := s.db.Get(id); errors.Is(err, veclite.ErrNotFound) {
+			gone = append(gone, id)
+		}
+	}
+	return gone
+}
+
+// updateLexical indexes the text of chunks and drops deleted from the lexical
+// index once they are written
+func (s *Store) updateLexical(chunks []Chunk, deleted []uint64) {
+	if s.lexical == nil {
+		return
+	}
+	for _, chunk := range chunks {
+		fields := make(map[string]string, len(chunk.Fields)+1)
+		for name, text := range chunk.Fields {
+			fields[name] = text
+		}
+		fields[TextField] = chunk.Text
+		s.lexical.add(chunk.ID, fields)
+	}
+	for _, id := range deleted {
+		s.lexical.remove(id)
+	}
+}
+
+// prepare builds the batch writing chunks, tagged with docID unless it is ""
+// Embeddings come from, in order: the stored chunk with the same content hash
+// in byHash, the cache, and one Embedder call for the rest
+func (s *Store) prepare(ctx context.Context, chunks []Chunk, docID string, byHash map[string]uint64) (*veclite.Batch, IngestReport, error) {
+	var report IngestReport
+	type pending struct {
+		id       uint64
+		metadata veclite.Metadata
+		vector   []float32
+	}
+	var writes []*pending
+	var missing []*pending // Waiting for the Embedder
+	var texts []string
+	seen := make(map[uint64]bool, len(chunks))
+	for _, chunk := range chunks {
+		if seen[chunk.ID] {
+			return nil, report, fmt.Errorf("docstore: duplicate chunk ID %d", chunk.ID)
+		}
+		seen[chunk.ID] = true
+		for _, key := range []string{HashKey, DocKey, TextKey, FieldsKey} {
+			if _, reserved := chunk.Metadata[key]; reserved {
+				return nil, report, fmt.Errorf("docstore: chunk %d uses reserved metadata key %q", chunk.ID, key)
+			}
+		}
+		if len(chunk.Fields) > 0 && s.lexical == nil {
+			return nil, report, fmt.Errorf("docstore: chunk %d has text fields, which require Options.Lexical", chunk.ID)
+		}
+		if _, reserved := chunk.Fields[TextField]; reserved {
+			return nil, report, fmt.Errorf("docstore: chunk %d uses reserved field name %q", chunk.ID, TextField)
+		}
+
+		hash := s.contentHash(chunk.Text)
+		metadata := make(veclite.Metadata, len(chunk.Metadata)+4)
+		for key, value := range chunk.Metadata {
+			metadata[key] = value
+		}
+		metadata[HashKey] = hash
+		if docID != "" {
+			metadata[DocKey] = docID
+		}
+		if s.lexical != nil {
+			metadata[TextKey] = chunk.Text
+		}
+		if len(chunk.Fields) > 0 {
+			fields := make(map[string]any, len(chunk.Fields))
+			for name, text := range chunk.Fields {
+				fields[name] = text
+			}
+			metadata[FieldsKey] = fields
+		}
+		if s.unchanged(chunk.ID, metadata) {
+			report.Unchanged++
+			continue
+		}
+
+		p := &pending{id: chunk.ID, metadata: metadata}
+		writes = append(writes, p)
+		if id, ok := byHash[hash]; ok {
+			if vector, err := s.db.Get(id); err == nil {
+				p.vector = vector
+				report.CacheHits++
+				continue
+			}
+		}
+		if vector, ok := s.cache.get(hash); ok {
+			p.vector = vector
+			report.CacheHits++
+			continue
+		}
+		missing = append(missing, p)
+		texts = append(texts, chunk.Text)
+	}
+
+	if len(missing) > 0 {
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, report, fmt.Errorf("docstore: failed to embed %d chunks: %w", len(texts), err)
+		}
+		if len(vectors) != len(texts) {
+			return nil, report, fmt.Errorf("docstore: embedder returned %d vectors for %d texts", len(vectors), len(texts))
+		}
+		report.Embedded = len(texts)
+		for i, p := range missing {
+			p.vector = vectors[i]
+			s.cache.add(p.metadata[HashKey].(string), vectors[i])
+		}
+	}
+
+	batch := &veclite.Batch{}
+	for _, p := range writes {
+		batch.UpsertWithMetadata(p.id, p.vector, p.metadata) // Chunks of a re-put document keep their IDs
+	}
+	report.Written = len(writes)
+	return batch, report, nil
+}
+
+// storedFields returns the text fields of a stored chunk with text
+func storedFields(text string, metadata veclite.Metadata) map[string]string {
+	stored, _ := metadata[FieldsKey].(map[string]any)
+	fields := make(map[string]string, len(stored)+1)
+	for name, value := range stored {
+		if s, ok := value.(string); ok {
+			fields[name] = s
+		}
+	}
+	fields[TextField] = text
+	return fields
+}
+
+// unchanged reports whether id is already stored with exactly metadata, which
+// includes the content hash
+func (s *Store) unchanged(id uint64, metadata veclite.Metadata) bool {
+	stored, err := s.db.GetMetadata(id)
+	if err != nil || len(stored) != len(metadata) {
+		return false
+	}
+	for key, value := range metadata {
+		if !sameValue(stored[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue compares metadata values, treating numbers as float64 as they are
+// read back from the metadata sidecar
+func sameValue(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts numeric values to float64
+func toFloat(value any) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,279 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// countingEmbedder embeds text as (len, first byte, 1, 0) and counts calls and texts
+type countingEmbedder struct {
+	calls int
+	texts int
+	err   error
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.calls++
+	e.texts += len(texts)
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), float32(text[0]), 1, 0}
+	}
+	return vectors, nil
+}
+
+// createTestStore creates a store over a new database of dimension 4
+func createTestStore(t *testing.T, opts Options) (*Store, *veclite.VecLite, *countingEmbedder) {
+	t.Helper()
+	config := veclite.DefaultConfig()
+	config.DataPath = t.TempDir() + "/docs.db"
+	config.Dimension = 4
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	embedder := &countingEmbedder{}
+	store, err := New(db, embedder, opts)
+	if err != nil {
+		t.Fatalf("docstore.New failed: %v", err)
+	}
+	return store, db, embedder
+}
+
+var testChunks = []Chunk{
+	{ID: 1, Text: "alpha", Metadata: veclite.Metadata{"doc": "a", "page": 1}},
+	{ID: 2, Text: "beta", Metadata: veclite.Metadata{"doc": "a", "page": 2}},
+	{ID: 3, Text: "gamma"},
+}
+
+func TestIngest_SkipsUnchanged(t *testing.T) {
+	store, db, embedder := createTestStore(t, Options{Model: "test"})
+	ctx := context.Background()
+
+	report, err := store.Ingest(ctx, testChunks)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report != (IngestReport{Embedded: 3, Written: 3}) || embedder.calls != 1 {
+		t.Fatalf("Unexpected first ingest: %+v (%d calls)", report, embedder.calls)
+	}
+	if vec, err := db.Get(2); err != nil || vec[0] != 4 {
+		t.Errorf("Expected the embedding of chunk 2, got %v, %v", vec, err)
+	}
+	if metadata, _ := db.GetMetadata(1); metadata[HashKey] == nil || metadata["doc"] != "a" {
+		t.Errorf("Expected metadata with content hash, got %v", metadata)
+	}
+
+	// Re-ingesting the same chunks does nothing
+	report, err = store.Ingest(ctx, testChunks)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report != (IngestReport{Unchanged: 3}) || embedder.calls != 1 {
+		t.Errorf("Expected all chunks unchanged, got %+v (%d calls)", report, embedder.calls)
+	}
+
+	// Changed metadata is rewritten from the cache; changed text is embedded
+	changed := []Chunk{
+		{ID: 1, Text: "alpha", Metadata: veclite.Metadata{"doc": "a", "page": 3}},
+		{ID: 3, Text: "gamma ray"},
+	}
+	report, err = store.Ingest(ctx, changed)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report != (IngestReport{Embedded: 1, CacheHits: 1, Written: 2}) || embedder.texts != 4 {
+		t.Errorf("Unexpected ingest of changed chunks: %+v (%d texts)", report, embedder.texts)
+	}
+
+	// The same text under a new ID comes from the cache
+	report, err = store.Ingest(ctx, []Chunk{{ID: 9, Text: "beta"}})
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report != (IngestReport{CacheHits: 1, Written: 1}) {
+		t.Errorf("Expected a cache hit for known text, got %+v", report)
+	}
+}
+
+func TestIngest_Errors(t *testing.T) {
+	store, _, embedder := createTestStore(t, Options{})
+	ctx := context.Background()
+
+	if _, err := store.Ingest(ctx, []Chunk{{ID: 1, Text: "x", Metadata: veclite.Metadata{HashKey: "h"}}}); err == nil {
+		t.Error("Expected an error for the reserved metadata key")
+	}
+
+	embedder.err = errors.New("rate limited")
+	if _, err := store.Ingest(ctx, []Chunk{{ID: 1, Text: "x"}}); !errors.Is(err, embedder.err) {
+		t.Errorf("Expected the embedder error, got %v", err)
+	}
+
+	wrongDim := EmbedderFunc(func(ctx context.Context, texts []string) ([][]float32, error) {
+		return [][]float32{{1, 2}}, nil
+	})
+	store.embedder = wrongDim
+	if report, err := store.Ingest(ctx, []Chunk{{ID: 1, Text: "x"}}); err == nil || report.Written != 0 {
+		t.Errorf("Expected a write error for the wrong dimension, got %+v, %v", report, err)
+	}
+	if _, err := New(nil, wrongDim, Options{}); err == nil {
+		t.Error("Expected an error without a database")
+	}
+}
+
+func TestIngest_ModelChangeReembeds(t *testing.T) {
+	store, _, embedder := createTestStore(t, Options{Model: "v1"})
+	ctx := context.Background()
+	if _, err := store.Ingest(ctx, testChunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	store.model = "v2"
+	report, err := store.Ingest(ctx, testChunks)
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report.Embedded != 3 || embedder.texts != 6 {
+		t.Errorf("Expected all chunks re-embedded for a new model, got %+v", report)
+	}
+}
+
+func TestEmbeddingCache_Persistence(t *testing.T) {
+	path := t.TempDir() + "/embeddings.cache"
+	store, _, _ := createTestStore(t, Options{CachePath: path, CacheSize: 2})
+	if _, err := store.Ingest(context.Background(), testChunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	cache, err := openEmbeddingCache(path, 2)
+	if err != nil {
+		t.Fatalf("openEmbeddingCache failed: %v", err)
+	}
+	if cache.entries.Len() != 2 {
+		t.Fatalf("Expected the 2 most recent embeddings, got %d", cache.entries.Len())
+	}
+	if _, ok := cache.get(store.contentHash("gamma")); !ok {
+		t.Error("Expected the most recent embedding to survive")
+	}
+	if _, ok := cache.get(store.contentHash("alpha")); ok {
+		t.Error("Expected the oldest embedding to be evicted")
+	}
+
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := openEmbeddingCache(path, 2); err == nil {
+		t.Error("Expected an error for an invalid cache file")
+	}
+}
+
+func TestStore_CacheDisabled(t *testing.T) {
+	store, _, embedder := createTestStore(t, Options{CacheSize: -1})
+	ctx := context.Background()
+	if _, err := store.Ingest(ctx, []Chunk{{ID: 1, Text: "same"}, {ID: 2, Text: "other"}}); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	report, err := store.Ingest(ctx, []Chunk{{ID: 3, Text: "same"}})
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if report.CacheHits != 0 || embedder.texts != 3 {
+		t.Errorf("Expected no cache hits with the cache disabled, got %+v", report)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestSync_DiffsChunks(t *testing.T) {
+	store, db, embedder := createTestStore(t, Options{Model: "test", CacheSize: -1})
+	ctx := context.Background()
+
+	report, err := store.Sync(ctx, "doc", testChunks)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if report != (SyncReport{IngestReport: IngestReport{Embedded: 3, Written: 3}}) {
+		t.Fatalf("Unexpected first sync: %+v", report)
+	}
+	if metadata, _ := db.GetMetadata(3); metadata[DocKey] != "doc" {
+		t.Errorf("Expected chunk tagged with document, got %v", metadata)
+	}
+
+	// Chunk 1 is kept, "gamma" moves from 3 to 4, 2 is dropped and 5 is new
+	chunks := []Chunk{
+		testChunks[0],
+		{ID: 4, Text: "gamma"},
+		{ID: 5, Text: "delta"},
+	}
+	report, err = store.Sync(ctx, "doc", chunks)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	want := SyncReport{IngestReport: IngestReport{Embedded: 1, CacheHits: 1, Written: 2, Unchanged: 1}, Deleted: 2}
+	if report != want {
+		t.Errorf("Expected %+v, got %+v", want, report)
+	}
+	if embedder.texts != 4 {
+		t.Errorf("Expected only the new text to be embedded, got %d texts", embedder.texts)
+	}
+	ids, err := db.FilterIDs(veclite.Filter{DocKey: "doc"})
+	if err != nil {
+		t.Fatalf("FilterIDs failed: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 4 || ids[2] != 5 {
+		t.Errorf("Expected chunks 1, 4, 5, got %v", ids)
+	}
+	if vec, err := db.Get(4); err != nil || vec[0] != 5 {
+		t.Errorf("Expected the reused embedding of gamma, got %v, %v", vec, err)
+	}
+
+	// Chunks of other documents are left alone
+	if _, err := store.Sync(ctx, "other", []Chunk{{ID: 9, Text: "zeta"}}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if report, err := store.Sync(ctx, "doc", nil); err != nil || report.Deleted != 3 {
+		t.Errorf("Expected the document to be emptied, got %+v, %v", report, err)
+	}
+	if db.Size() != 1 {
+		t.Errorf("Expected only the other document, got size %d", db.Size())
+	}
+}
+
+func TestSync_Errors(t *testing.T) {
+	store, db, embedder := createTestStore(t, Options{})
+	ctx := context.Background()
+	if _, err := store.Sync(ctx, "doc", testChunks); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	duplicate := []Chunk{{ID: 7, Text: "x"}, {ID: 7, Text: "y"}}
+	if _, err := store.Sync(ctx, "doc", duplicate); err == nil {
+		t.Error("Expected error for duplicate chunk IDs")
+	}
+	reserved := []Chunk{{ID: 7, Text: "x", Metadata: veclite.Metadata{DocKey: "a"}}}
+	if _, err := store.Sync(ctx, "doc", reserved); err == nil {
+		t.Error("Expected error for reserved metadata key")
+	}
+
+	// A failed embedding leaves the stored document untouched
+	embedder.err = errors.New("rate limited")
+	if _, err := store.Sync(ctx, "doc", []Chunk{{ID: 8, Text: "new"}}); !errors.Is(err, embedder.err) {
+		t.Errorf("Expected embedder error, got %v", err)
+	}
+	if db.Size() != 3 {
+		t.Errorf("Expected the document to be untouched, got size %d", db.Size())
+	}
+}
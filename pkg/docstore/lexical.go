@@ -0,0 +1,250 @@
+package docstore
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// BM25 parameters of the lexical index
+const (
+	bm25K1 = 1.2  // Term frequency saturation
+	bm25B  = 0.75 // Document length normalization
+)
+
+// Highlight is one occurrence of a query term in a text field of a hit, as
+// byte offsets into the field (Hit.Text or Hit.Fields), for highlighting why a
+// chunk matched
+type Highlight struct {
+	Term  string // Indexed form of the term (see Analyzer)
+	Start int    // Offset of the first byte
+	End   int    // Offset after the last byte
+}
+
+// token is one term of a text with its position
+type token struct {
+	term       string
+	start, end int
+}
+
+// span is the position of one occurrence of a term
+type span struct {
+	start, end int32
+}
+
+// fieldIndex holds the postings of one text field
+type fieldIndex struct {
+	postings map[string]map[uint64][]span // Term -> chunk ID -> occurrences
+	terms    map[uint64][]string          // Chunk ID -> distinct terms, for removal
+	lengths  map[uint64]int               // Chunk ID -> number of terms
+	total    int                          // Sum of lengths
+}
+
+// lexicalIndex is an in-memory BM25 index of chunk texts whose postings keep
+// the position of every occurrence, so hits can be highlighted
+// Each text field has its own postings and length statistics, so fields can be
+// boosted separately
+type lexicalIndex struct {
+	analyzer *analyzer
+
+	mu     sync.RWMutex
+	fields map[string]*fieldIndex // Field name (TextField for chunk text) -> postings
+	chunks map[uint64][]string    // Chunk ID -> fields it has terms in
+}
+
+// newLexicalIndex creates an empty lexical index splitting texts with analyzer
+func newLexicalIndex(analyzer *analyzer) *lexicalIndex {
+	return &lexicalIndex{
+		analyzer: analyzer,
+		fields:   make(map[string]*fieldIndex),
+		chunks:   make(map[uint64][]string),
+	}
+}
+
+// add indexes fields (field name -> text) as the content of id, replacing its
+// previous content
+func (x *lexicalIndex) add(id uint64, fields map[string]string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(id)
+
+	for name, text := range fields {
+		tokens := x.analyzer.analyze(text)
+		if len(tokens) == 0 {
+			continue
+		}
+		f := x.fields[name]
+		if f == nil {
+			f = &fieldIndex{
+				postings: make(map[string]map[uint64][]span),
+				terms:    make(map[uint64][]string),
+				lengths:  make(map[uint64]int),
+			}
+			x.fields[name] = f
+		}
+		for _, t := range tokens {
+			chunks := f.postings[t.term]
+			if chunks == nil {
+				chunks = make(map[uint64][]span)
+				f.postings[t.term] = chunks
+			}
+			if chunks[id] == nil {
+				f.terms[id] = append(f.terms[id], t.term)
+			}
+			chunks[id] = append(chunks[id], span{start: int32(t.start), end: int32(t.end)})
+		}
+		f.lengths[id] = len(tokens)
+		f.total += len(tokens)
+		x.chunks[id] = append(x.chunks[id], name)
+	}
+}
+
+// remove drops id from the index
+func (x *lexicalIndex) remove(id uint64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(id)
+}
+
+// removeLocked implements remove
+// Note: Assumes lock is already held
+func (x *lexicalIndex) removeLocked(id uint64) {
+	for _, name := range x.chunks[id] {
+		f := x.fields[name]
+		for _, term := range f.terms[id] {
+			delete(f.postings[term], id)
+			if len(f.postings[term]) == 0 {
+				delete(f.postings, term)
+			}
+		}
+		f.total -= f.lengths[id]
+		delete(f.terms, id)
+		delete(f.lengths, id)
+		if len(f.lengths) == 0 {
+			delete(x.fields, name)
+		}
+	}
+	delete(x.chunks, id)
+}
+
+// lexicalHit is one result of a lexical search
+type lexicalHit struct {
+	id    uint64
+	score float64
+}
+
+// queryTerms returns the distinct terms of a query
+func (x *lexicalIndex) queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range x.analyzer.analyze(query) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// search returns the n chunks with the highest BM25 score for terms, skipping
+// chunks rejected by keep (nil = all), highest score first
+// The score of a chunk is the sum of its per-field BM25 scores multiplied by
+// the boost of the field in boosts (missing = 1, 0 = field ignored)
+func (x *lexicalIndex) search(terms []string, n int, keep func(id uint64) bool, boosts map[string]float64) []lexicalHit {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if len(x.chunks) == 0 {
+		return nil
+	}
+
+	chunks := float64(len(x.chunks))
+	scores := make(map[uint64]float64)
+	for name, f := range x.fields {
+		boost, ok := boosts[name]
+		if !ok {
+			boost = 1
+		}
+		if boost == 0 {
+			continue
+		}
+		average := float64(f.total) / float64(len(f.lengths))
+		for _, term := range terms {
+			postings := f.postings[term]
+			if len(postings) == 0 {
+				continue
+			}
+			df := float64(len(postings))
+			idf := math.Log(1 + (chunks-df+0.5)/(df+0.5))
+			for id, occurrences := range postings {
+				if keep != nil && !keep(id) {
+					continue
+				}
+				tf := float64(len(occurrences))
+				norm := 1 - bm25B + bm25B*float64(f.lengths[id])/max(average, 1)
+				scores[id] += boost * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			}
+		}
+	}
+
+	hits := make([]lexicalHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, lexicalHit{id: id, score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+	if len(hits) > n {
+		hits = hits[:n]
+	}
+	return hits
+}
+
+// highlights returns the occurrences of terms in id by field, in text order
+func (x *lexicalIndex) highlights(id uint64, terms []string) map[string][]Highlight {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var fields map[string][]Highlight
+	for _, name := range x.chunks[id] {
+		var highlights []Highlight
+		for _, term := range terms {
+			for _, s := range x.fields[name].postings[term][id] {
+				highlights = append(highlights, Highlight{Term: term, Start: int(s.start), End: int(s.end)})
+			}
+		}
+		if len(highlights) == 0 {
+			continue
+		}
+		sort.Slice(highlights, func(i, j int) bool { return highlights[i].Start < highlights[j].Start })
+		if fields == nil {
+			fields = make(map[string][]Highlight)
+		}
+		fields[name] = highlights
+	}
+	return fields
+}
+
+// Mark returns text with every highlight wrapped in open and close, e.g.
+// "<mark>" and "</mark>"; highlights must be in text order, as returned in Hit
+// Highlights outside text or splitting a UTF-8 sequence are skipped
+func Mark(text string, highlights []Highlight, open, close string) string {
+	var b strings.Builder
+	last := 0
+	for _, h := range highlights {
+		if h.Start < last || h.End > len(text) || h.Start >= h.End ||
+			!utf8.RuneStart(text[h.Start]) || (h.End < len(text) && !utf8.RuneStart(text[h.End])) {
+			continue
+		}
+		b.WriteString(text[last:h.Start])
+		b.WriteString(open)
+		b.WriteString(text[h.Start:h.End])
+		b.WriteString(close)
+		last = h.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
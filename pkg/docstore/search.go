@@ -0,0 +1,196 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// rrfK is the rank constant of reciprocal rank fusion
+const rrfK = 60
+
+// SearchMode selects the rankings Search combines
+type SearchMode int
+
+const (
+	SearchHybrid  SearchMode = iota // Fuse the lexical and vector rankings (requires Options.Lexical)
+	SearchLexical                   // BM25 ranking only (requires Options.Lexical)
+	SearchVector                    // Vector ranking only
+)
+
+// SearchOptions configures a Store search
+type SearchOptions struct {
+	Mode       SearchMode
+	Filter     veclite.Filter // Only chunks whose metadata matches (nil = all)
+	Candidates int            // Chunks taken from each ranking before fusion (default: 4×k)
+
+	// LexicalWeight and VectorWeight scale the contribution of each ranking to
+	// the fused score (0 = 1), e.g. 2 and 1 to favor exact term matches
+	LexicalWeight float64
+	VectorWeight  float64
+
+	// Boosts scale the lexical score of text fields by field name (TextField
+	// for Chunk.Text, names of Chunk.Fields otherwise); fields without a boost
+	// count once and a boost of 0 leaves the field out, e.g.
+	// {"title": 3} to rank title matches above body matches
+	Boosts map[string]float64
+}
+
+// Hit is one result of a Store search
+type Hit struct {
+	ID       uint64
+	Score    float64           // Weighted reciprocal rank fusion score over the rankings of the mode
+	Distance float32           // Vector distance (0 if not in the vector ranking)
+	Lexical  float64           // BM25 score (0 if not in the lexical ranking)
+	Text     string            // Chunk text (requires Options.Lexical)
+	Fields   map[string]string // Chunk.Fields as stored
+	Metadata veclite.Metadata
+
+	// Highlights are the occurrences of query terms in Text, in text order,
+	// taken from the lexical index postings (requires Options.Lexical)
+	Highlights []Highlight
+
+	// FieldHighlights are the occurrences of query terms in Fields, by field name
+	FieldHighlights map[string][]Highlight
+}
+
+// Search returns the k chunks ranked best for query, each with the positions of
+// the query terms it contains so UIs can highlight why it matched
+func (s *Store) Search(ctx context.Context, query string, k int, opts SearchOptions) ([]Hit, error) {
+	if k <= 0 {
+		return nil, errors.New("docstore: k must be greater than 0")
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("docstore: query is empty")
+	}
+	useLexical := opts.Mode == SearchHybrid || opts.Mode == SearchLexical
+	useVector := opts.Mode == SearchHybrid || opts.Mode == SearchVector
+	if !useLexical && !useVector {
+		return nil, fmt.Errorf("docstore: unknown search mode %d", opts.Mode)
+	}
+	if useLexical && s.lexical == nil {
+		return nil, errors.New("docstore: lexical search requires Options.Lexical")
+	}
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = 4 * k
+	}
+	candidates = max(candidates, k)
+	lexicalWeight, vectorWeight := opts.LexicalWeight, opts.VectorWeight
+	if lexicalWeight < 0 || vectorWeight < 0 {
+		return nil, errors.New("docstore: ranking weights must not be negative")
+	}
+	if lexicalWeight == 0 {
+		lexicalWeight = 1
+	}
+	if vectorWeight == 0 {
+		vectorWeight = 1
+	}
+	for field, boost := range opts.Boosts {
+		if boost < 0 {
+			return nil, fmt.Errorf("docstore: boost of field %q must not be negative", field)
+		}
+	}
+
+	hits := make(map[uint64]*Hit)
+	hit := func(id uint64, rank int, weight float64) *Hit {
+		h := hits[id]
+		if h == nil {
+			h = &Hit{ID: id}
+			hits[id] = h
+		}
+		h.Score += weight / float64(rrfK+rank+1)
+		return h
+	}
+
+	if useVector {
+		vectors, err := s.embedder.Embed(ctx, []string{query})
+		if err != nil {
+			return nil, fmt.Errorf("docstore: failed to embed query: %w", err)
+		}
+		if len(vectors) != 1 {
+			return nil, fmt.Errorf("docstore: embedder returned %d vectors for 1 text", len(vectors))
+		}
+		results, err := s.db.SearchWithOptionsContext(ctx, vectors[0], candidates, veclite.SearchOptions{
+			IncludeMetadata: true,
+			Filter:          opts.Filter,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("docstore: failed to search vectors: %w", err)
+		}
+		for rank, result := range results {
+			h := hit(result.ID, rank, vectorWeight)
+			h.Distance = result.Distance
+			h.Metadata = result.Metadata
+			if h.Metadata == nil {
+				h.Metadata = veclite.Metadata{} // Found without metadata, not deleted
+			}
+		}
+	}
+
+	var terms []string
+	if s.lexical != nil {
+		terms = s.lexical.queryTerms(query)
+	}
+	if useLexical {
+		var keep func(id uint64) bool
+		if len(opts.Filter) > 0 {
+			ids, err := s.db.FilterIDs(opts.Filter)
+			if err != nil {
+				return nil, fmt.Errorf("docstore: failed to filter chunks: %w", err)
+			}
+			allowed := make(map[uint64]bool, len(ids))
+			for _, id := range ids {
+				allowed[id] = true
+			}
+			keep = func(id uint64) bool { return allowed[id] }
+		}
+		for rank, result := range s.lexical.search(terms, candidates, keep, opts.Boosts) {
+			hit(result.id, rank, lexicalWeight).Lexical = result.score
+		}
+	}
+
+	ranked := make([]*Hit, 0, len(hits))
+	for _, h := range hits {
+		ranked = append(ranked, h)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	out := make([]Hit, 0, len(ranked))
+	for _, h := range ranked {
+		if h.Metadata == nil {
+			metadata, err := s.db.GetMetadata(h.ID)
+			if err != nil {
+				continue // Deleted since it was ranked
+			}
+			h.Metadata = metadata
+		}
+		h.Text, _ = h.Metadata[TextKey].(string)
+		if fields := storedFields(h.Text, h.Metadata); len(fields) > 1 {
+			delete(fields, TextField)
+			h.Fields = fields
+		}
+		if s.lexical != nil {
+			highlights := s.lexical.highlights(h.ID, terms)
+			h.Highlights = highlights[TextField]
+			delete(highlights, TextField)
+			if len(highlights) > 0 {
+				h.FieldHighlights = highlights
+			}
+		}
+		out = append(out, *h)
+	}
+	return out, nil
+}
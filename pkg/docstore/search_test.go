@@ -0,0 +1,177 @@
+package docstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+var articleChunks = []Chunk{
+	{ID: 1, Text: "Vector databases store embeddings.", Metadata: veclite.Metadata{"lang": "en"}},
+	{ID: 2, Text: "A vector is a list of numbers; vector search ranks by distance.", Metadata: veclite.Metadata{"lang": "en"}},
+	{ID: 3, Text: "Lexical search matches words, not embeddings.", Metadata: veclite.Metadata{"lang": "en"}},
+	{ID: 4, Text: "Vektor-Datenbanken speichern Einbettungen.", Metadata: veclite.Metadata{"lang": "de"}},
+}
+
+func TestTokenize_Offsets(t *testing.T) {
+	text := "Ünïcode-Wörter, ID 42!"
+	a, _ := newAnalyzer(Analyzer{})
+	tokens := a.analyze(text)
+	want := []string{"ünïcode", "wörter", "id", "42"}
+	if len(tokens) != len(want) {
+		t.Fatalf("Expected %d tokens, got %+v", len(want), tokens)
+	}
+	for i, tok := range tokens {
+		if tok.term != want[i] {
+			t.Errorf("Token %d: expected %q, got %q", i, want[i], tok.term)
+		}
+		if got := text[tok.start:tok.end]; got != []string{"Ünïcode", "Wörter", "ID", "42"}[i] {
+			t.Errorf("Token %d: offsets select %q", i, got)
+		}
+	}
+}
+
+func TestSearch_LexicalHighlights(t *testing.T) {
+	store, _, _ := createTestStore(t, Options{Model: "test", Lexical: true})
+	ctx := context.Background()
+	if _, err := store.Ingest(ctx, articleChunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+
+	hits, err := store.Search(ctx, "Vector search", 10, SearchOptions{Mode: SearchLexical})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 3 || hits[0].ID != 2 {
+		t.Fatalf("Expected chunk 2 first of 3 lexical hits, got %+v", hits)
+	}
+	top := hits[0]
+	if top.Text != articleChunks[1].Text || len(top.Highlights) != 3 || top.Lexical <= hits[1].Lexical {
+		t.Errorf("Unexpected top hit: %+v", top)
+	}
+	for _, h := range top.Highlights {
+		if got := top.Text[h.Start:h.End]; got != h.Term {
+			t.Errorf("Highlight %+v selects %q", h, got)
+		}
+	}
+	marked := Mark(top.Text, top.Highlights, "[", "]")
+	if want := "A [vector] is a list of numbers; [vector] [search] ranks by distance."; marked != want {
+		t.Errorf("Expected %q, got %q", want, marked)
+	}
+
+	// Filters apply to the lexical ranking
+	hits, err = store.Search(ctx, "embeddings", 10, SearchOptions{Mode: SearchLexical, Filter: veclite.Filter{"lang": "de"}})
+	if err != nil || len(hits) != 0 {
+		t.Errorf("Expected no German chunk to contain the term, got %+v, %v", hits, err)
+	}
+}
+
+func TestSearch_HybridAndSync(t *testing.T) {
+	store, db, _ := createTestStore(t, Options{Model: "test", Lexical: true})
+	ctx := context.Background()
+	if _, err := store.Sync(ctx, "article", articleChunks); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	hits, err := store.Search(ctx, "lexical", 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != 3 || len(hits[0].Highlights) != 1 || hits[0].Lexical == 0 {
+		t.Fatalf("Expected the lexical match fused first, got %+v", hits)
+	}
+	if hits[1].Lexical != 0 || len(hits[1].Highlights) != 0 || hits[1].Text == "" {
+		t.Errorf("Expected a vector-only second hit with text and no highlights, got %+v", hits[1])
+	}
+
+	// Removed and changed chunks leave the lexical index with the sync
+	changed := []Chunk{{ID: 1, Text: "Lexical indexes keep postings."}}
+	if _, err := store.Sync(ctx, "article", changed); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	hits, err = store.Search(ctx, "lexical", 10, SearchOptions{Mode: SearchLexical})
+	if err != nil || len(hits) != 1 || hits[0].ID != 1 {
+		t.Errorf("Expected only the changed chunk to match, got %+v, %v", hits, err)
+	}
+
+	// The index is rebuilt from stored texts
+	reopened, err := New(db, &countingEmbedder{}, Options{Model: "test", Lexical: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	hits, err = reopened.Search(ctx, "postings", 10, SearchOptions{Mode: SearchLexical})
+	if err != nil || len(hits) != 1 || hits[0].Highlights[0].Start != 21 {
+		t.Errorf("Expected the rebuilt index to highlight the stored text, got %+v, %v", hits, err)
+	}
+}
+
+func TestSearch_RequiresLexical(t *testing.T) {
+	store, _, _ := createTestStore(t, Options{Model: "test"})
+	ctx := context.Background()
+	if _, err := store.Ingest(ctx, articleChunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if _, err := store.Search(ctx, "vector", 3, SearchOptions{}); err == nil {
+		t.Errorf("Expected hybrid search without Options.Lexical to fail")
+	}
+	hits, err := store.Search(ctx, "vector", 3, SearchOptions{Mode: SearchVector})
+	if err != nil || len(hits) != 3 || hits[0].Text != "" || hits[0].Highlights != nil {
+		t.Errorf("Expected plain vector hits, got %+v, %v", hits, err)
+	}
+}
+
+func TestSearch_BoostsAndWeights(t *testing.T) {
+	store, _, _ := createTestStore(t, Options{Model: "test", Lexical: true})
+	ctx := context.Background()
+	chunks := []Chunk{
+		{ID: 1, Text: "An overview of databases.", Fields: map[string]string{"title": "Vector search guide"}},
+		{ID: 2, Text: "Vector search is covered in the vector search guide."},
+	}
+	if _, err := store.Ingest(ctx, chunks); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	first := func(opts SearchOptions) Hit {
+		t.Helper()
+		hits, err := store.Search(ctx, "vector search", 2, opts)
+		if err != nil || len(hits) == 0 {
+			t.Fatalf("Search failed: %v, %v", hits, err)
+		}
+		return hits[0]
+	}
+
+	if hit := first(SearchOptions{Mode: SearchLexical}); hit.ID != 2 {
+		t.Errorf("Expected the body with repeated terms first without boosts, got %d", hit.ID)
+	}
+	titled := SearchOptions{Mode: SearchLexical, Boosts: map[string]float64{"title": 5}}
+	hit := first(titled)
+	if hit.ID != 1 || hit.Fields["title"] != "Vector search guide" {
+		t.Fatalf("Expected the boosted title match first with its fields, got %+v", hit)
+	}
+	if h := hit.FieldHighlights["title"]; len(h) != 2 || hit.Fields["title"][h[0].Start:h[0].End] != "Vector" || len(hit.Highlights) != 0 {
+		t.Errorf("Expected highlights in the title only, got %+v / %+v", hit.FieldHighlights, hit.Highlights)
+	}
+	hits, err := store.Search(ctx, "vector search", 2, SearchOptions{Mode: SearchLexical, Boosts: map[string]float64{"title": 0}})
+	if err != nil || len(hits) != 1 || hits[0].ID != 2 {
+		t.Errorf("Expected a zero boost to leave the title out, got %+v, %v", hits, err)
+	}
+
+	// The vector ranking prefers chunk 2: the weights decide the fused order
+	titled.Mode = SearchHybrid
+	titled.LexicalWeight = 2
+	if hit := first(titled); hit.ID != 1 {
+		t.Errorf("Expected the lexical ranking to win with weight 2, got %d", hit.ID)
+	}
+	titled.LexicalWeight, titled.VectorWeight = 1, 2
+	if hit := first(titled); hit.ID != 2 {
+		t.Errorf("Expected the vector ranking to win with weight 2, got %d", hit.ID)
+	}
+	titled.VectorWeight = -1
+	if _, err := store.Search(ctx, "vector", 2, titled); err == nil {
+		t.Errorf("Expected a negative weight to be rejected")
+	}
+
+	if _, err := store.Ingest(ctx, []Chunk{{ID: 3, Text: "x", Fields: map[string]string{TextField: "y"}}}); err == nil {
+		t.Errorf("Expected the reserved field name to be rejected")
+	}
+}
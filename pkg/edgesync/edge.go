@@ -0,0 +1,454 @@
+package edgesync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// currentName is the descriptor of the serving snapshot in an edge directory
+const currentName = "current.json"
+
+// EdgeConfig configures an Edge
+type EdgeConfig struct {
+	Interval time.Duration // Time between polls in Run (default: 1m)
+
+	// DeltaThreshold is the fraction of changed bytes above which the whole
+	// archive is downloaded in one request instead of its changed chunks (default: 0.5)
+	DeltaThreshold float64
+
+	// Configure adjusts the configuration each snapshot is opened with, e.g.
+	// cache sizes (optional)
+	Configure func(config *veclite.Config)
+
+	Aliases *veclite.Aliases // Registry holding the serving database (default: a new one)
+	Alias   string           // Alias of the serving database (default: "edge")
+
+	// OnSwap is called after the serving database was swapped to snapshot (optional)
+	OnSwap func(snapshot *Snapshot)
+
+	// OnError is called with every error Run retries (default: print a warning)
+	OnError func(err error)
+}
+
+// EdgeStats reports the progress of an Edge
+type EdgeStats struct {
+	Version    int64     // Version of the serving snapshot (0 = none yet)
+	Seq        uint64    // Sequence number of the last builder write in the serving snapshot
+	Swaps      uint64    // Snapshots swapped in
+	Downloaded int64     // Archive bytes downloaded
+	Reused     int64     // Archive bytes copied from the previous snapshot instead
+	Errors     uint64    // Failed syncs
+	LastSwap   time.Time // When the last snapshot was swapped in
+}
+
+// edgeSnapshot is a snapshot opened by an Edge
+type edgeSnapshot struct {
+	snapshot *Snapshot
+	db       *veclite.VecLite
+}
+
+// Edge pulls snapshots from a Source into a local directory and serves the
+// newest one through an alias
+// The directory holds the archive and unpacked database of the serving
+// snapshot and of the one before it, which stays open for searches still
+// running on it and for Aliases.Rollback
+type Edge struct {
+	dir    string
+	source Source
+	config EdgeConfig
+
+	mu       sync.Mutex    // Serializes Sync and Close
+	current  *edgeSnapshot // Serving snapshot (nil before the first)
+	previous *edgeSnapshot // Snapshot replaced by the last swap (nil if none)
+
+	statsMu sync.Mutex
+	stats   EdgeStats
+	swapped chan struct{} // Closed by the next swap (nil = nobody waiting), guarded by statsMu
+}
+
+// NewEdge creates an edge syncing from source into dir; the snapshot an
+// earlier edge left in dir is opened and served right away, so the edge works
+// offline until the source is reachable
+func NewEdge(dir string, source Source, config EdgeConfig) (*Edge, error) {
+	if source == nil {
+		return nil, errors.New("source is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.DeltaThreshold <= 0 {
+		config.DeltaThreshold = 0.5
+	}
+	if config.Aliases == nil {
+		config.Aliases = veclite.NewAliases()
+	}
+	if config.Alias == "" {
+		config.Alias = "edge"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create edge directory: %w", err)
+	}
+
+	e := &Edge{dir: dir, source: source, config: config}
+	snapshot, err := readSnapshot(filepath.Join(dir, currentName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if snapshot != nil {
+		db, err := e.open(snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open snapshot %d: %w", snapshot.Version, err)
+		}
+		e.current = &edgeSnapshot{snapshot: snapshot, db: db}
+		if _, err := config.Aliases.SwapAlias(config.Alias, db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		e.stats.Version = snapshot.Version
+		e.stats.Seq = snapshot.Seq
+	}
+	e.removeStale()
+	return e, nil
+}
+
+// DB returns the serving database (nil before the first snapshot)
+func (e *Edge) DB() *veclite.VecLite {
+	db, err := e.config.Aliases.Resolve(e.config.Alias)
+	if err != nil {
+		return nil
+	}
+	return db
+}
+
+// Stats returns the progress so far
+func (e *Edge) Stats() EdgeStats {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+	return e.stats
+}
+
+// WaitForSeq waits until the serving snapshot contains the builder write with
+// sequence number seq (see veclite.VecLite.Seq), so a client that wrote to the
+// builder can read its write from the edge
+func (e *Edge) WaitForSeq(ctx context.Context, seq uint64) error {
+	for {
+		e.statsMu.Lock()
+		current, serving := e.stats.Seq, e.stats.Version > 0
+		if e.swapped == nil {
+			e.swapped = make(chan struct{})
+		}
+		swapped := e.swapped
+		e.statsMu.Unlock()
+		if serving && current >= seq {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for seq %d (serving: %d): %w", seq, current, ctx.Err())
+		case <-swapped:
+		}
+	}
+}
+
+// update changes the stats under the lock
+func (e *Edge) update(fn func(s *EdgeStats)) {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+	fn(&e.stats)
+}
+
+// Sync downloads the latest snapshot if it is newer than the serving one,
+// verifies it, opens it and swaps the alias to it, reporting whether it did
+// On any error the serving database is left unchanged
+func (e *Edge) Sync(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	swapped, err := e.sync(ctx)
+	if err != nil {
+		e.update(func(s *EdgeStats) { s.Errors++ })
+	}
+	return swapped, err
+}
+
+// sync is Sync with the lock held
+func (e *Edge) sync(ctx context.Context) (bool, error) {
+	latest, err := e.source.Latest(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch latest snapshot: %w", err)
+	}
+	if err := latest.validate(); err != nil {
+		return false, err
+	}
+	if e.current != nil && latest.Version <= e.current.snapshot.Version {
+		return false, nil
+	}
+
+	archivePath := filepath.Join(e.dir, latest.Archive)
+	downloaded, reused, err := e.download(ctx, latest, archivePath)
+	if err != nil {
+		return false, err
+	}
+	e.update(func(s *EdgeStats) {
+		s.Downloaded += downloaded
+		s.Reused += reused
+	})
+
+	db, err := e.open(latest)
+	if err != nil {
+		e.remove(latest)
+		return false, fmt.Errorf("failed to open snapshot %d: %w", latest.Version, err)
+	}
+	if err := writeSnapshot(filepath.Join(e.dir, currentName), latest); err != nil {
+		db.Close()
+		e.remove(latest)
+		return false, err
+	}
+	if _, err := e.config.Aliases.SwapAlias(e.config.Alias, db); err != nil {
+		db.Close()
+		return false, err
+	}
+
+	// The snapshot before the replaced one has had a full sync interval to drain
+	if e.previous != nil {
+		e.previous.db.Close()
+		e.remove(e.previous.snapshot)
+	}
+	e.previous, e.current = e.current, &edgeSnapshot{snapshot: latest, db: db}
+	e.update(func(s *EdgeStats) {
+		s.Version = latest.Version
+		s.Seq = latest.Seq
+		s.Swaps++
+		s.LastSwap = time.Now()
+		if e.swapped != nil {
+			close(e.swapped)
+			e.swapped = nil
+		}
+	})
+	if e.config.OnSwap != nil {
+		e.config.OnSwap(latest)
+	}
+	return true, nil
+}
+
+// download writes the archive of snapshot to path, copying the chunks the
+// serving snapshot shares with it from its local archive and fetching the rest
+// Every chunk and the whole archive are verified against their checksums
+func (e *Edge) download(ctx context.Context, snapshot *Snapshot, path string) (downloaded, reused int64, err error) {
+	local := make(map[string]Chunk)
+	var localFile *os.File
+	if e.current != nil {
+		if file, err := os.Open(filepath.Join(e.dir, e.current.snapshot.Archive)); err == nil {
+			defer file.Close()
+			localFile = file
+			for _, chunk := range e.current.snapshot.Chunks {
+				local[chunk.SHA256] = chunk
+			}
+		}
+	}
+	var missing int64
+	for _, chunk := range snapshot.Chunks {
+		if _, ok := local[chunk.SHA256]; !ok {
+			missing += chunk.Size
+		}
+	}
+	if snapshot.Size > 0 && float64(missing)/float64(snapshot.Size) > e.config.DeltaThreshold {
+		local = nil
+	}
+
+	tmpPath := path + ".part"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create snapshot archive: %w", err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	whole := sha256.New()
+	w := io.MultiWriter(out, whole)
+	chunks := snapshot.Chunks
+	for len(chunks) > 0 {
+		if have, ok := local[chunks[0].SHA256]; ok {
+			if err := copyChunk(w, io.NewSectionReader(localFile, have.Offset, have.Size), chunks[0]); err != nil {
+				return 0, 0, fmt.Errorf("failed to copy local chunk at %d: %w", have.Offset, err)
+			}
+			reused += chunks[0].Size
+			chunks = chunks[1:]
+			continue
+		}
+
+		// Fetch the run of missing chunks in one request
+		n, length := 0, int64(0)
+		for n < len(chunks) {
+			if _, ok := local[chunks[n].SHA256]; ok {
+				break
+			}
+			length += chunks[n].Size
+			n++
+		}
+		if err := e.fetch(ctx, w, snapshot.Archive, chunks[:n], length); err != nil {
+			return 0, 0, err
+		}
+		downloaded += length
+		chunks = chunks[n:]
+	}
+
+	if sum := hex.EncodeToString(whole.Sum(nil)); sum != snapshot.SHA256 {
+		return 0, 0, fmt.Errorf("snapshot archive %s checksum mismatch", snapshot.Archive)
+	}
+	if err := out.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("failed to sync snapshot archive: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write snapshot archive: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, 0, fmt.Errorf("failed to rename snapshot archive: %w", err)
+	}
+	return downloaded, reused, nil
+}
+
+// fetch copies consecutive chunks of length bytes in total from the source to w
+func (e *Edge) fetch(ctx context.Context, w io.Writer, archive string, chunks []Chunk, length int64) error {
+	r, err := e.source.Open(ctx, archive, chunks[0].Offset, length)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", archive, err)
+	}
+	defer r.Close()
+	for _, chunk := range chunks {
+		if err := copyChunk(w, r, chunk); err != nil {
+			return fmt.Errorf("failed to fetch chunk at %d of %s: %w", chunk.Offset, archive, err)
+		}
+	}
+	return nil
+}
+
+// copyChunk copies one chunk from r to w, checking its checksum before writing it
+func copyChunk(w io.Writer, r io.Reader, chunk Chunk) error {
+	data := make([]byte, chunk.Size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != chunk.SHA256 {
+		return errors.New("checksum mismatch")
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// snapshotDir returns the directory of the unpacked database of snapshot
+func (e *Edge) snapshotDir(snapshot *Snapshot) string {
+	return filepath.Join(e.dir, fmt.Sprintf("snapshot-%d", snapshot.Version))
+}
+
+// open unpacks the archive of snapshot unless already unpacked and opens it
+func (e *Edge) open(snapshot *Snapshot) (*veclite.VecLite, error) {
+	dir := e.snapshotDir(snapshot)
+	dataPath := filepath.Join(dir, "data")
+	archivePath := filepath.Join(e.dir, snapshot.Archive)
+
+	var config *veclite.Config
+	if _, err := os.Stat(dataPath); err == nil {
+		manifest, err := veclite.ReadPackManifest(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		config = manifest.Config(dataPath)
+	} else {
+		// Unpack into a fresh directory: a partial unpack left by a crash is discarded
+		os.RemoveAll(dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+		}
+		if config, err = veclite.Unpack(archivePath, dataPath); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	if e.config.Configure != nil {
+		e.config.Configure(config)
+	}
+	return veclite.New(config)
+}
+
+// remove deletes the archive and unpacked database of snapshot
+func (e *Edge) remove(snapshot *Snapshot) {
+	os.Remove(filepath.Join(e.dir, snapshot.Archive))
+	os.RemoveAll(e.snapshotDir(snapshot))
+}
+
+// removeStale deletes the snapshots and partial downloads in the directory
+// other than the serving snapshot, left by a restart or an interrupted sync
+func (e *Edge) removeStale() {
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return
+	}
+	keep := make(map[string]bool)
+	if e.current != nil {
+		keep[e.current.snapshot.Archive] = true
+		keep[filepath.Base(e.snapshotDir(e.current.snapshot))] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if (strings.HasPrefix(name, "snapshot-") || strings.HasSuffix(name, ".part")) && !keep[name] {
+			os.RemoveAll(filepath.Join(e.dir, name))
+		}
+	}
+}
+
+// Run syncs right away and then every Config.Interval until ctx is done,
+// returning ctx.Err(); failed syncs are retried at the next interval
+func (e *Edge) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.Sync(ctx); err != nil && ctx.Err() == nil {
+			if e.config.OnError != nil {
+				e.config.OnError(err)
+			} else {
+				fmt.Printf("Warning: failed to sync snapshot: %v\n", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the serving and previous databases; the alias keeps pointing
+// at the closed serving database
+func (e *Edge) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firstErr error
+	for _, s := range []*edgeSnapshot{e.previous, e.current} {
+		if s == nil {
+			continue
+		}
+		if err := s.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	e.previous, e.current = nil, nil
+	return firstErr
+}
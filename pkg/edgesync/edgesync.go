@@ -0,0 +1,189 @@
+// Package edgesync distributes database snapshots from a central builder to
+// edge instances. A Publisher periodically packs the database (see
+// veclite.Pack) into a directory served over HTTP or a shared filesystem; an
+// Edge polls it, downloads the new archive, verifies it and atomically swaps
+// its serving database to it
+//
+// Archives are split into content-defined chunks, so an edge that already
+// holds the previous snapshot only downloads the chunks that changed
+//
+// Publish directory layout:
+//
+//	latest.json          Snapshot descriptor of the newest archive
+//	snapshot-<N>.pack    Pack archive of version N (the last PublisherConfig.Keep are kept)
+package edgesync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// LatestName is the name of the descriptor of the newest snapshot in a publish directory
+const LatestName = "latest.json"
+
+// defaultChunkSize is the default average chunk size
+const defaultChunkSize = 256 << 10
+
+// Snapshot describes one published Pack archive
+type Snapshot struct {
+	Version int64              `json:"version"`
+	Created time.Time          `json:"created"`
+	Archive string             `json:"archive"` // File name of the archive in the publish directory
+	Size    int64              `json:"size"`
+	SHA256  string             `json:"sha256"`
+	Vectors int                `json:"vectors"`
+	Seq     uint64             `json:"seq"`   // Sequence number of the last write in the snapshot (see veclite.VecLite.Seq)
+	Files   []veclite.PackFile `json:"files"` // Files of the archive (see veclite.PackManifest)
+	Chunks  []Chunk            `json:"chunks"`
+}
+
+// Chunk is one content-defined range of an archive
+type Chunk struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// archiveName returns the archive file name of version
+func archiveName(version int64) string {
+	return fmt.Sprintf("snapshot-%d.pack", version)
+}
+
+// validate checks that a descriptor read from a source is usable
+func (s *Snapshot) validate() error {
+	if s.Version <= 0 {
+		return fmt.Errorf("invalid snapshot version %d", s.Version)
+	}
+	if s.Archive == "" || filepath.Base(s.Archive) != s.Archive || s.Archive == ".." {
+		return fmt.Errorf("invalid snapshot archive name %q", s.Archive)
+	}
+	var offset int64
+	for _, chunk := range s.Chunks {
+		if chunk.Offset != offset || chunk.Size <= 0 {
+			return errors.New("snapshot chunks do not cover the archive")
+		}
+		offset += chunk.Size
+	}
+	if offset != s.Size {
+		return errors.New("snapshot chunks do not cover the archive")
+	}
+	return nil
+}
+
+// gear maps bytes to the random values of the rolling chunk boundary hash
+// The values are fixed: changing them moves every boundary, so the next
+// snapshot would share no chunks with the previous one
+var gear = func() (table [256]uint64) {
+	seed := uint64(0)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunkFile splits the file at path into chunks of about average bytes, with
+// boundaries determined by content so that inserting or removing bytes only
+// changes the chunks around the edit, and returns its size and SHA-256
+func chunkFile(path string, average int) (size int64, sum string, chunks []Chunk, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer file.Close()
+
+	mask := uint64(1)<<bits.Len(uint(average-1)) - 1
+	minSize, maxSize := int64(average/4), int64(average*4)
+	whole, part := sha256.New(), sha256.New()
+	var hash uint64
+	var n int64 // Bytes in the current chunk
+	cut := func() {
+		chunks = append(chunks, Chunk{Offset: size - n, Size: n, SHA256: hex.EncodeToString(part.Sum(nil))})
+		part.Reset()
+		hash, n = 0, 0
+	}
+
+	buf := make([]byte, 64<<10)
+	for {
+		k, readErr := io.ReadFull(file, buf)
+		data := buf[:k]
+		whole.Write(data)
+		from := 0
+		for i, b := range data {
+			hash = hash<<1 + gear[b]
+			n++
+			size++
+			if (n >= minSize && hash&mask == 0) || n >= maxSize {
+				part.Write(data[from : i+1])
+				from = i + 1
+				cut()
+			}
+		}
+		part.Write(data[from:])
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return 0, "", nil, readErr
+		}
+	}
+	if n > 0 {
+		cut()
+	}
+	return size, hex.EncodeToString(whole.Sum(nil)), chunks, nil
+}
+
+// readSnapshot reads a Snapshot descriptor file
+func readSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot descriptor %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// writeSnapshot atomically replaces the descriptor file at path
+func writeSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot descriptor: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot descriptor: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write snapshot descriptor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package edgesync
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+const testDimension = 16
+
+// newBuilderDB creates the central database with n random vectors
+func newBuilderDB(t *testing.T, n int) *veclite.VecLite {
+	t.Helper()
+	return newBuilderIndexDB(t, "flat", n)
+}
+
+// newBuilderIndexDB creates the central database of indexType with n random vectors
+func newBuilderIndexDB(t *testing.T, indexType string, n int) *veclite.VecLite {
+	t.Helper()
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "builder.db")
+	config.Dimension = testDimension
+	config.IndexType = indexType
+	config.M = 8
+	config.EfConstruction = 50
+	config.EfSearch = 50
+	config.NClusters = 4
+	config.NProbe = 2
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	insertRandom(t, db, 1, n)
+	return db
+}
+
+// insertRandom inserts n random vectors with IDs counting up from first
+func insertRandom(t *testing.T, db *veclite.VecLite, first uint64, n int) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(int64(first)))
+	for i := 0; i < n; i++ {
+		vector := make([]float32, testDimension)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		if err := db.Insert(first+uint64(i), vector); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+}
+
+// publish publishes a snapshot and fails the test if none was published
+func publish(t *testing.T, p *Publisher) *Snapshot {
+	t.Helper()
+	snapshot, published, err := p.Publish()
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if !published {
+		t.Fatalf("Expected a new snapshot to be published")
+	}
+	return snapshot
+}
+
+func TestChunkFile_LocalEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 256<<10)
+	rng.Read(data)
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original")
+	edited := filepath.Join(dir, "edited")
+	if err := os.WriteFile(original, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	// Insert bytes near the start: later boundaries shift with the content
+	changed := append(append(append([]byte{}, data[:1000]...), []byte("inserted")...), data[1000:]...)
+	if err := os.WriteFile(edited, changed, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	size, _, chunks, err := chunkFile(original, 4096)
+	if err != nil {
+		t.Fatalf("chunkFile failed: %v", err)
+	}
+	if size != int64(len(data)) || len(chunks) < 16 {
+		t.Fatalf("Expected %d bytes in many chunks, got %d bytes in %d chunks", len(data), size, len(chunks))
+	}
+	_, _, editedChunks, err := chunkFile(edited, 4096)
+	if err != nil {
+		t.Fatalf("chunkFile failed: %v", err)
+	}
+	known := make(map[string]bool)
+	for _, chunk := range chunks {
+		known[chunk.SHA256] = true
+	}
+	var shared int64
+	for _, chunk := range editedChunks {
+		if known[chunk.SHA256] {
+			shared += chunk.Size
+		}
+	}
+	if shared < size*3/4 {
+		t.Errorf("Expected most chunks to survive an insertion, %d of %d bytes shared", shared, size)
+	}
+}
+
+func TestPublisher_SkipsUnchanged(t *testing.T) {
+	for _, indexType := range []string{"flat", "hnsw", "ivf"} {
+		t.Run(indexType, func(t *testing.T) {
+			builder := newBuilderIndexDB(t, indexType, 300)
+			publisher, err := NewPublisher(builder, t.TempDir(), PublisherConfig{})
+			if err != nil {
+				t.Fatalf("NewPublisher failed: %v", err)
+			}
+			first := publish(t, publisher)
+			latest, published, err := publisher.Publish()
+			if err != nil || published || latest != first {
+				t.Errorf("Expected an unchanged %s database not to be published again, got %v, %v", indexType, published, err)
+			}
+		})
+	}
+}
+
+func TestEdge_SyncAndDelta(t *testing.T) {
+	builder := newBuilderDB(t, 500)
+	publishDir := t.TempDir()
+	publisher, err := NewPublisher(builder, publishDir, PublisherConfig{ChunkSize: 1024})
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	first := publish(t, publisher)
+
+	var swaps []int64
+	edge, err := NewEdge(t.TempDir(), DirSource(publishDir), EdgeConfig{
+		OnSwap: func(s *Snapshot) { swaps = append(swaps, s.Version) },
+	})
+	if err != nil {
+		t.Fatalf("NewEdge failed: %v", err)
+	}
+	defer edge.Close()
+	if edge.DB() != nil {
+		t.Errorf("Expected no database before the first sync")
+	}
+	if swapped, err := edge.Sync(context.Background()); err != nil || !swapped {
+		t.Fatalf("Expected the first sync to swap, got %v, %v", swapped, err)
+	}
+	if size := edge.DB().Size(); size != 500 {
+		t.Errorf("Expected 500 vectors on the edge, got %d", size)
+	}
+	if stats := edge.Stats(); stats.Downloaded != first.Size || stats.Reused != 0 {
+		t.Errorf("Expected the whole archive (%d bytes) downloaded, got %+v", first.Size, stats)
+	}
+
+	// Nothing changed: no new snapshot and nothing to pull
+	if _, published, err := publisher.Publish(); err != nil || published {
+		t.Errorf("Expected an unchanged database not to be published, got %v, %v", published, err)
+	}
+	if swapped, err := edge.Sync(context.Background()); err != nil || swapped {
+		t.Errorf("Expected no swap without a new snapshot, got %v, %v", swapped, err)
+	}
+
+	// A small change is pulled as a delta
+	insertRandom(t, builder, 501, 5)
+	second := publish(t, publisher)
+	old := edge.DB()
+	if swapped, err := edge.Sync(context.Background()); err != nil || !swapped {
+		t.Fatalf("Expected the second sync to swap, got %v, %v", swapped, err)
+	}
+	if size := edge.DB().Size(); size != 505 {
+		t.Errorf("Expected 505 vectors after the delta, got %d", size)
+	}
+	if old.Size() != 500 {
+		t.Errorf("Expected the replaced database to stay open for running searches")
+	}
+	stats := edge.Stats()
+	if delta := stats.Downloaded - first.Size; delta <= 0 || delta > second.Size/2 || stats.Reused == 0 {
+		t.Errorf("Expected a small delta download of the %d byte archive, got %d bytes (reused %d)", second.Size, delta, stats.Reused)
+	}
+	if stats.Version != second.Version || stats.Swaps != 2 || len(swaps) != 2 {
+		t.Errorf("Expected version %d after 2 swaps, got %+v (OnSwap %v)", second.Version, stats, swaps)
+	}
+	results, err := edge.DB().Search(make([]float32, testDimension), 3)
+	if err != nil || len(results) != 3 {
+		t.Errorf("Expected 3 results from the new snapshot, got %d, %v", len(results), err)
+	}
+}
+
+func TestEdge_RejectsCorruptArchive(t *testing.T) {
+	builder := newBuilderDB(t, 100)
+	publishDir := t.TempDir()
+	publisher, err := NewPublisher(builder, publishDir, PublisherConfig{ChunkSize: 1024})
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	snapshot := publish(t, publisher)
+
+	path := filepath.Join(publishDir, snapshot.Archive)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	edgeDir := t.TempDir()
+	edge, err := NewEdge(edgeDir, DirSource(publishDir), EdgeConfig{})
+	if err != nil {
+		t.Fatalf("NewEdge failed: %v", err)
+	}
+	defer edge.Close()
+	if _, err := edge.Sync(context.Background()); err == nil {
+		t.Fatalf("Expected a corrupt archive to be rejected")
+	}
+	if edge.DB() != nil || edge.Stats().Errors != 1 {
+		t.Errorf("Expected no database and one error, got %+v", edge.Stats())
+	}
+	if entries, _ := os.ReadDir(edgeDir); len(entries) != 0 {
+		t.Errorf("Expected the partial download to be removed, found %d files", len(entries))
+	}
+}
+
+func TestEdge_HTTPSourceAndRestart(t *testing.T) {
+	builder := newBuilderDB(t, 200)
+	publishDir := t.TempDir()
+	publisher, err := NewPublisher(builder, publishDir, PublisherConfig{ChunkSize: 1024, Keep: 1})
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	publish(t, publisher)
+	server := httptest.NewServer(http.FileServer(http.Dir(publishDir)))
+	defer server.Close()
+
+	edgeDir := t.TempDir()
+	source := &HTTPSource{URL: server.URL}
+	edge, err := NewEdge(edgeDir, source, EdgeConfig{})
+	if err != nil {
+		t.Fatalf("NewEdge failed: %v", err)
+	}
+	if _, err := edge.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	insertRandom(t, builder, 201, 3)
+	second := publish(t, publisher)
+	if _, err := os.Stat(filepath.Join(publishDir, archiveName(1))); !os.IsNotExist(err) {
+		t.Errorf("Expected the first archive to be pruned with Keep 1")
+	}
+	if _, err := edge.Sync(context.Background()); err != nil {
+		t.Fatalf("Delta sync over HTTP failed: %v", err)
+	}
+	if stats := edge.Stats(); stats.Reused == 0 {
+		t.Errorf("Expected ranged HTTP downloads to reuse local chunks, got %+v", stats)
+	}
+	if err := edge.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// A restarted edge serves its snapshot offline
+	server.Close()
+	restarted, err := NewEdge(edgeDir, source, EdgeConfig{})
+	if err != nil {
+		t.Fatalf("NewEdge after restart failed: %v", err)
+	}
+	defer restarted.Close()
+	if restarted.DB() == nil || restarted.DB().Size() != 203 || restarted.Stats().Version != second.Version {
+		t.Errorf("Expected the restarted edge to serve version %d, got %+v", second.Version, restarted.Stats())
+	}
+	if _, err := restarted.Sync(context.Background()); err == nil {
+		t.Errorf("Expected a sync without the source to fail")
+	}
+	if restarted.DB().Size() != 203 {
+		t.Errorf("Expected the failed sync to keep the serving database")
+	}
+	entries, _ := os.ReadDir(edgeDir)
+	if len(entries) != 3 {
+		t.Errorf("Expected only the serving archive, database and descriptor after restart, found %d entries", len(entries))
+	}
+}
+
+func TestEdge_WaitForSeq(t *testing.T) {
+	builder := newBuilderDB(t, 50)
+	publishDir := t.TempDir()
+	publisher, err := NewPublisher(builder, publishDir, PublisherConfig{})
+	if err != nil {
+		t.Fatalf("NewPublisher failed: %v", err)
+	}
+	publish(t, publisher)
+	edge, err := NewEdge(t.TempDir(), DirSource(publishDir), EdgeConfig{})
+	if err != nil {
+		t.Fatalf("NewEdge failed: %v", err)
+	}
+	defer edge.Close()
+	if _, err := edge.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// A write on the builder becomes readable on the edge with its next snapshot
+	insertRandom(t, builder, 100, 1)
+	seq := builder.Seq()
+	if stats := edge.Stats(); stats.Seq >= seq {
+		t.Fatalf("Expected the edge to serve an older snapshot, got seq %d", stats.Seq)
+	}
+	done := make(chan error, 1)
+	go func() { done <- edge.WaitForSeq(context.Background(), seq) }()
+	publish(t, publisher)
+	if _, err := edge.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("WaitForSeq failed: %v", err)
+	}
+	if _, err := edge.DB().Get(100); err != nil {
+		t.Errorf("Expected the awaited write on the edge: %v", err)
+	}
+	if seq := edge.DB().Seq(); seq != edge.Stats().Seq {
+		t.Errorf("Expected the serving database at the snapshot seq %d, got %d", edge.Stats().Seq, seq)
+	}
+}
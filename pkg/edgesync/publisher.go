@@ -0,0 +1,165 @@
+package edgesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// PublisherConfig configures a Publisher
+type PublisherConfig struct {
+	Interval  time.Duration // Time between snapshots in Run (default: 5m)
+	ChunkSize int           // Average chunk size for delta downloads in bytes (default: 256 KiB)
+	Keep      int           // Archives kept in the directory, including the latest (default: 2)
+
+	// OnError is called with every error Run skips (default: print a warning)
+	OnError func(err error)
+}
+
+// Publisher packs a database into a publish directory for edges to pull
+type Publisher struct {
+	db     *veclite.VecLite
+	dir    string
+	config PublisherConfig
+
+	mu     sync.Mutex // Serializes Publish
+	latest *Snapshot  // Newest published snapshot (nil before the first)
+}
+
+// NewPublisher creates a publisher of db into dir, continuing the version
+// numbers of snapshots already published there
+func NewPublisher(db *veclite.VecLite, dir string, config PublisherConfig) (*Publisher, error) {
+	if db == nil {
+		return nil, errors.New("database is required")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = defaultChunkSize
+	}
+	if config.ChunkSize < 64 {
+		return nil, fmt.Errorf("chunk size %d is too small (minimum 64 bytes)", config.ChunkSize)
+	}
+	if config.Keep <= 0 {
+		config.Keep = 2
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create publish directory: %w", err)
+	}
+
+	p := &Publisher{db: db, dir: dir, config: config}
+	latest, err := readSnapshot(filepath.Join(dir, LatestName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	p.latest = latest
+	return p, nil
+}
+
+// Publish packs the database and makes it the latest snapshot, returning it
+// and whether it was published: if no file changed since the latest snapshot,
+// that one is returned instead
+// Packing blocks writes to the database (see veclite.Pack)
+func (p *Publisher) Publish() (*Snapshot, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	version := int64(1)
+	if p.latest != nil {
+		version = p.latest.Version + 1
+	}
+	name := archiveName(version)
+	path := filepath.Join(p.dir, name)
+
+	// Archives are not compressed: compression would spread every change over
+	// the rest of the archive and defeat delta downloads
+	if err := p.db.Pack(path, veclite.PackOptions{}); err != nil {
+		return nil, false, fmt.Errorf("failed to pack database: %w", err)
+	}
+	manifest, err := veclite.ReadPackManifest(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, false, err
+	}
+	if p.latest != nil && sameFiles(p.latest.Files, manifest.Files) {
+		os.Remove(path)
+		return p.latest, false, nil
+	}
+
+	size, sum, chunks, err := chunkFile(path, p.config.ChunkSize)
+	if err != nil {
+		os.Remove(path)
+		return nil, false, fmt.Errorf("failed to chunk snapshot archive: %w", err)
+	}
+	snapshot := &Snapshot{
+		Version: version,
+		Created: manifest.Created,
+		Archive: name,
+		Size:    size,
+		SHA256:  sum,
+		Vectors: manifest.Vectors,
+		Seq:     manifest.Seq,
+		Files:   manifest.Files,
+		Chunks:  chunks,
+	}
+	if err := writeSnapshot(filepath.Join(p.dir, LatestName), snapshot); err != nil {
+		os.Remove(path)
+		return nil, false, err
+	}
+	p.latest = snapshot
+
+	// Older archives stay available while edges may still be downloading them
+	for old := version - int64(p.config.Keep); old > 0; old-- {
+		if err := os.Remove(filepath.Join(p.dir, archiveName(old))); os.IsNotExist(err) {
+			break
+		}
+	}
+	return snapshot, true, nil
+}
+
+// sameFiles reports whether two archives hold the same files
+func sameFiles(a, b []veclite.PackFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Size != b[i].Size || a[i].SHA256 != b[i].SHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// Latest returns the newest published snapshot (nil before the first)
+func (p *Publisher) Latest() *Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latest
+}
+
+// Run publishes a snapshot every Config.Interval until ctx is done, returning ctx.Err()
+func (p *Publisher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, _, err := p.Publish(); err != nil {
+			if p.config.OnError != nil {
+				p.config.OnError(err)
+			} else {
+				fmt.Printf("Warning: failed to publish snapshot: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package edgesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultHTTPTimeout bounds fetching a descriptor with an HTTPSource without its own Client
+const defaultHTTPTimeout = 30 * time.Second
+
+// Source serves the snapshots of a publish directory to an Edge
+type Source interface {
+	// Latest returns the descriptor of the newest snapshot
+	Latest(ctx context.Context) (*Snapshot, error)
+
+	// Open returns length bytes of archive starting at offset
+	Open(ctx context.Context, archive string, offset, length int64) (io.ReadCloser, error)
+}
+
+// DirSource serves a publish directory on a local or shared filesystem
+type DirSource string
+
+// Latest reads the latest snapshot descriptor of the directory
+func (d DirSource) Latest(ctx context.Context) (*Snapshot, error) {
+	return readSnapshot(filepath.Join(string(d), LatestName))
+}
+
+// Open opens a range of an archive in the directory
+func (d DirSource) Open(ctx context.Context, archive string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(string(d), archive))
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+// HTTPSource serves a publish directory from a web server, e.g. one serving
+// the directory with http.FileServer; ranges are fetched with Range requests
+type HTTPSource struct {
+	URL    string       // Base URL of the publish directory, e.g. "https://cdn.example.com/vectors"
+	Header http.Header  // Extra request headers, e.g. Authorization
+	Client *http.Client // HTTP client (default: http.DefaultClient, with a 30s timeout for descriptors)
+}
+
+// get requests name with optional extra headers
+func (s *HTTPSource) get(ctx context.Context, name string, header http.Header) (*http.Response, error) {
+	if s.URL == "" {
+		return nil, errors.New("source URL is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.URL, "/")+"/"+name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	return resp, nil
+}
+
+// Latest fetches the latest snapshot descriptor
+func (s *HTTPSource) Latest(ctx context.Context) (*Snapshot, error) {
+	if s.Client == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultHTTPTimeout)
+		defer cancel()
+	}
+	resp, err := s.get(ctx, LatestName, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LatestName, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %s: %s", LatestName, resp.Status, bytes.TrimSpace(data))
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot descriptor: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// Open fetches a range of an archive; servers ignoring the Range header are
+// handled by skipping to the range in the full response
+func (s *HTTPSource) Open(ctx context.Context, archive string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)}}
+	resp, err := s.get(ctx, archive, header)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", archive, err)
+		}
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s returned %s", archive, resp.Status)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, length), resp.Body}, nil
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// Record is a write decoded from a message
+type Record struct {
+	ID       uint64           `json:"id"`
+	Vector   []float32        `json:"vector,omitempty"`
+	Metadata veclite.Metadata `json:"metadata,omitempty"` // Replaces the stored metadata if set
+	Delete   bool             `json:"delete,omitempty"`   // Delete ID instead of inserting Vector
+}
+
+// validate checks that the record is a delete or an insert of a vector of dimension
+func (r Record) validate(dimension int) error {
+	switch {
+	case r.Delete && (r.Vector != nil || r.Metadata != nil):
+		return errors.New("delete record must not carry a vector or metadata")
+	case !r.Delete && len(r.Vector) != dimension:
+		return fmt.Errorf("vector dimension %d does not match configured dimension %d", len(r.Vector), dimension)
+	}
+	return nil
+}
+
+// addTo adds the write of the record to batch
+func (r Record) addTo(batch *veclite.Batch) {
+	switch {
+	case r.Delete:
+		batch.Delete(r.ID)
+	case r.Metadata != nil: // Upserts, so replayed messages rewrite the same vector
+		batch.UpsertWithMetadata(r.ID, r.Vector, r.Metadata)
+	default:
+		batch.Upsert(r.ID, r.Vector)
+	}
+}
+
+// Codec decodes messages into records
+type Codec interface {
+	Decode(msg Message) (Record, error)
+}
+
+// CodecFunc adapts a function to the Codec interface
+type CodecFunc func(msg Message) (Record, error)
+
+// Decode calls f
+func (f CodecFunc) Decode(msg Message) (Record, error) {
+	return f(msg)
+}
+
+// JSONCodec decodes message values holding a Record as JSON:
+// {"id": 1, "vector": [0.1, 0.2], "metadata": {"lang": "en"}} or {"id": 1, "delete": true}
+var JSONCodec Codec = CodecFunc(func(msg Message) (Record, error) {
+	var record Record
+	if err := json.Unmarshal(msg.Value, &record); err != nil {
+		return Record{}, fmt.Errorf("failed to decode JSON record: %w", err)
+	}
+	return record, nil
+})
+
+// BinaryCodec decodes messages keyed by the decimal vector ID whose value is
+// the vector as little-endian float32 values; an empty value (a tombstone, as
+// used by Kafka log compaction) deletes the ID
+var BinaryCodec Codec = CodecFunc(func(msg Message) (Record, error) {
+	id, err := strconv.ParseUint(string(msg.Key), 10, 64)
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid vector ID key %q: %w", msg.Key, err)
+	}
+	if len(msg.Value) == 0 {
+		return Record{ID: id, Delete: true}, nil
+	}
+	if len(msg.Value)%4 != 0 {
+		return Record{}, fmt.Errorf("value of %d bytes is not a float32 vector", len(msg.Value))
+	}
+	vector := make([]float32, len(msg.Value)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(msg.Value[i*4:]))
+	}
+	return Record{ID: id, Vector: vector}, nil
+})
@@ -0,0 +1,330 @@
+// Package ingest consumes vector records from a message stream such as a
+// Kafka topic or a NATS JetStream consumer and writes them to a VecLite
+// database. Offsets are committed only once the writes are durable, so a
+// crash replays uncommitted messages instead of losing them
+//
+// The package has no broker client dependency: a Source adapts the client of
+// choice (e.g. a Kafka consumer group committing partition offsets, or a
+// JetStream pull subscription acking messages). The server binary
+// (cmd/veclite-server, flag -ingest) runs a Consumer with Kafka and NATS
+// sources and publishes its Stats, including the lag, as expvar metrics
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// Message is one message read from a Source
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// Source reads messages from a stream and commits consumed positions
+type Source interface {
+	// Fetch returns the next messages, at most max, waiting until at least
+	// one is available or ctx is done
+	Fetch(ctx context.Context, max int) ([]Message, error)
+
+	// Commit marks msgs, all fetched earlier, as processed; after a restart
+	// the source resumes after the last committed message of each partition
+	Commit(ctx context.Context, msgs []Message) error
+}
+
+// LagSource is implemented by sources that can report how many messages are
+// waiting behind the last fetched one
+type LagSource interface {
+	Lag(ctx context.Context) (int64, error)
+}
+
+// Config configures a Consumer
+type Config struct {
+	Codec     Codec         // Decodes messages (default: JSONCodec)
+	BatchSize int           // Messages fetched and written per batch (default: 500)
+	Retry     time.Duration // Wait before retrying after a fetch, write or commit error (default: 1s)
+
+	// SkipInvalid counts and commits messages that don't decode to a valid record,
+	// or whose record the database rejects, instead of stopping Run with the error
+	SkipInvalid bool
+
+	// OnError is called with every error Run retries or skips (optional)
+	OnError func(err error)
+}
+
+// Stats reports the progress of a Consumer
+type Stats struct {
+	Fetched    uint64    // Messages fetched
+	Applied    uint64    // Records written (inserts and deletes)
+	Invalid    uint64    // Messages skipped because they couldn't be decoded or were rejected
+	Batches    uint64    // Batches written, flushed and committed
+	Errors     uint64    // Fetch, write and commit errors retried
+	Lag        int64     // Messages waiting in the source after the last fetch (-1 = unknown)
+	LastCommit time.Time // When the last batch was committed
+}
+
+// Consumer writes the records of a Source to a database
+type Consumer struct {
+	db     *veclite.VecLite
+	source Source
+	config Config
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewConsumer creates a consumer of source writing to db
+func NewConsumer(db *veclite.VecLite, source Source, config Config) (*Consumer, error) {
+	if db == nil || source == nil {
+		return nil, errors.New("database and source are required")
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.Retry <= 0 {
+		config.Retry = time.Second
+	}
+	return &Consumer{db: db, source: source, config: config, stats: Stats{Lag: -1}}, nil
+}
+
+// Run consumes messages until ctx is done, returning ctx.Err()
+// Each batch is applied with one write lock together with its checkpoint (see
+// Checkpoint), made durable with Flush and only then committed. Transient
+// errors, such as a write stall or an I/O error, are retried after
+// Config.Retry; a message that doesn't decode, or whose record the database
+// rejects (a Before hook, a non-finite value or a reserved ID), stops Run
+// unless Config.SkipInvalid is set
+// Flush leaves the index structure to the database's AutoSave settings, Sync
+// and Close, as for any other write. A batch with deletes is made durable with
+// Sync instead, as Flush doesn't persist the deletion of cold-tier vectors
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msgs, err := c.source.Fetch(ctx, c.config.BatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.retry(ctx, fmt.Errorf("failed to fetch messages: %w", err))
+			continue
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		c.update(func(s *Stats) { s.Fetched += uint64(len(msgs)) })
+
+		// Uncommitted messages are only fetched again after a restart or a
+		// rebalance, so the batch is retried until it is committed; writes and
+		// deletes are idempotent, so a partly applied batch can be applied again
+		for {
+			err := c.process(ctx, msgs)
+			if err == nil {
+				break
+			}
+			var invalid *InvalidMessageError
+			if errors.As(err, &invalid) || rejected(err) {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.retry(ctx, err)
+		}
+	}
+}
+
+// rejected reports whether err, returned by the database for the write of a
+// record, rejects the record itself, so writing it again fails the same way
+func rejected(err error) bool {
+	return errors.Is(err, veclite.ErrRejected) || errors.Is(err, veclite.ErrNonFinite) ||
+		errors.Is(err, veclite.ErrReservedID) || errors.Is(err, veclite.ErrDimensionMismatch)
+}
+
+// process writes, syncs and commits one batch of messages
+func (c *Consumer) process(ctx context.Context, msgs []Message) error {
+	records := make([]Record, 0, len(msgs))
+	sources := make([]int, 0, len(msgs)) // Index in msgs of each record
+	invalid := 0
+	for i, msg := range msgs {
+		record, err := c.config.Codec.Decode(msg)
+		if err == nil {
+			err = record.validate(c.db.InputDimension())
+		}
+		if err != nil {
+			if err := c.skip(&InvalidMessageError{Message: msg, Err: err}); err != nil {
+				return err
+			}
+			invalid++
+			continue
+		}
+		records = append(records, record)
+		sources = append(sources, i)
+	}
+
+	// A record the database rejects is skipped like an undecodable message
+	// and the batch is written again without it
+	for {
+		var batch veclite.Batch
+		deletes := 0
+		for _, record := range records {
+			record.addTo(&batch)
+			if record.Delete {
+				deletes++
+			}
+		}
+		for key, offset := range checkpoints(msgs) {
+			batch.PutKV(key, []byte(strconv.FormatInt(offset, 10)))
+		}
+
+		err := c.db.ApplyContext(ctx, &batch)
+		var opErr *veclite.BatchOpError
+		if err != nil && errors.As(err, &opErr) && opErr.Index < len(records) && rejected(opErr.Err) {
+			if err := c.skip(&InvalidMessageError{Message: msgs[sources[opErr.Index]], Err: opErr.Err}); err != nil {
+				return err
+			}
+			invalid++
+			records = append(records[:opErr.Index], records[opErr.Index+1:]...)
+			sources = append(sources[:opErr.Index], sources[opErr.Index+1:]...)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write batch: %w", err)
+		}
+		return c.finish(ctx, msgs, len(records), invalid, deletes)
+	}
+}
+
+// skip returns err, an invalid message, unless Config.SkipInvalid is set, in
+// which case it is reported to Config.OnError
+func (c *Consumer) skip(err *InvalidMessageError) error {
+	if !c.config.SkipInvalid {
+		return err
+	}
+	if c.config.OnError != nil {
+		c.config.OnError(err)
+	}
+	return nil
+}
+
+// finish makes a written batch of msgs durable and commits it
+func (c *Consumer) finish(ctx context.Context, msgs []Message, records, invalid, deletes int) error {
+	durable := c.db.Flush
+	if deletes > 0 {
+		durable = c.db.Sync
+	}
+	if err := durable(); err != nil {
+		return fmt.Errorf("failed to sync batch: %w", err)
+	}
+	if err := c.source.Commit(ctx, msgs); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	lag := int64(-1)
+	if lagSource, ok := c.source.(LagSource); ok {
+		if n, err := lagSource.Lag(ctx); err == nil {
+			lag = n
+		}
+	}
+	c.update(func(s *Stats) {
+		s.Applied += uint64(records)
+		s.Invalid += uint64(invalid)
+		s.Batches++
+		s.Lag = lag
+		s.LastCommit = time.Now()
+	})
+	return nil
+}
+
+// checkpointPrefix starts the KV keys of the checkpoints written by a Consumer
+const checkpointPrefix = "ingest.offset/"
+
+// checkpointKey returns the KV key of the checkpoint of a partition
+func checkpointKey(topic string, partition int32) string {
+	return checkpointPrefix + topic + "/" + strconv.Itoa(int(partition))
+}
+
+// checkpoints returns the offset after the last of msgs in each partition,
+// keyed by checkpointKey
+func checkpoints(msgs []Message) map[string]int64 {
+	next := make(map[string]int64)
+	for _, msg := range msgs {
+		key := checkpointKey(msg.Topic, msg.Partition)
+		next[key] = max(next[key], msg.Offset+1)
+	}
+	return next
+}
+
+// Checkpoint returns the offset after the last message of a partition that a
+// Consumer wrote to db and made durable, read from its KV namespace
+// A Source can resume from it when the broker lost or never received the
+// commit of a batch that is stored; ok is false if no batch was written
+func Checkpoint(db *veclite.VecLite, topic string, partition int32) (offset int64, ok bool, err error) {
+	value, err := db.KV().Get(checkpointKey(topic, partition))
+	if errors.Is(err, veclite.ErrKVNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	offset, err = strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid checkpoint of %s/%d: %w", topic, partition, err)
+	}
+	return offset, true, nil
+}
+
+// retry reports err and waits Config.Retry or until ctx is done
+func (c *Consumer) retry(ctx context.Context, err error) {
+	c.update(func(s *Stats) { s.Errors++ })
+	if c.config.OnError != nil {
+		c.config.OnError(err)
+	}
+	timer := time.NewTimer(c.config.Retry)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// update changes the stats under the lock
+func (c *Consumer) update(fn func(s *Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// Stats returns the progress so far
+func (c *Consumer) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// InvalidMessageError is returned by Run for a message that doesn't decode to a valid record
+type InvalidMessageError struct {
+	Message Message
+	Err     error
+}
+
+func (e *InvalidMessageError) Error() string {
+	return fmt.Sprintf("invalid message at %s/%d offset %d: %v", e.Message.Topic, e.Message.Partition, e.Message.Offset, e.Err)
+}
+
+func (e *InvalidMessageError) Unwrap() error {
+	return e.Err
+}
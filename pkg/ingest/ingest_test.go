@@ -0,0 +1,259 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// memorySource serves a fixed list of messages and records commits
+type memorySource struct {
+	mu         sync.Mutex
+	messages   []Message
+	next       int   // Index of the next message to fetch
+	committed  int64 // Offset after the last committed message
+	commitErrs int   // Commits to fail before succeeding
+	done       chan struct{}
+}
+
+func newMemorySource(values ...string) *memorySource {
+	s := &memorySource{done: make(chan struct{})}
+	for i, value := range values {
+		s.messages = append(s.messages, Message{Topic: "vectors", Offset: int64(i), Value: []byte(value)})
+	}
+	return s
+}
+
+func (s *memorySource) Fetch(ctx context.Context, max int) ([]Message, error) {
+	s.mu.Lock()
+	if s.next < len(s.messages) {
+		end := min(s.next+max, len(s.messages))
+		msgs := s.messages[s.next:end]
+		s.next = end
+		s.mu.Unlock()
+		return msgs, nil
+	}
+	s.mu.Unlock()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *memorySource) Commit(ctx context.Context, msgs []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.commitErrs > 0 {
+		s.commitErrs--
+		return errors.New("broker unavailable")
+	}
+	s.committed = msgs[len(msgs)-1].Offset + 1
+	if s.committed == int64(len(s.messages)) {
+		close(s.done)
+	}
+	return nil
+}
+
+func (s *memorySource) Lag(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.messages) - s.next), nil
+}
+
+// newTestDB creates a flat database of dimension 2
+func newTestDB(t *testing.T) *veclite.VecLite {
+	t.Helper()
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "ingest.db")
+	config.Dimension = 2
+	config.IndexType = "flat"
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// runUntilCommitted runs the consumer until every message of source is committed
+func runUntilCommitted(t *testing.T, consumer *Consumer, source *memorySource) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- consumer.Run(ctx) }()
+	select {
+	case <-source.done:
+	case err := <-errc:
+		t.Fatalf("Run stopped early: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the messages to be committed")
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Run to return context.Canceled, got %v", err)
+	}
+}
+
+func TestConsumer_Run(t *testing.T) {
+	db := newTestDB(t)
+	var values []string
+	for i := 1; i <= 5; i++ {
+		values = append(values, fmt.Sprintf(`{"id": %d, "vector": [%d, 0], "metadata": {"n": %d}}`, i, i, i))
+	}
+	values = append(values, `{"id": 2, "delete": true}`)
+	source := newMemorySource(values...)
+	source.commitErrs = 1
+
+	var errs []error
+	consumer, err := NewConsumer(db, source, Config{BatchSize: 4, Retry: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatalf("NewConsumer failed: %v", err)
+	}
+	runUntilCommitted(t, consumer, source)
+
+	if db.Size() != 4 {
+		t.Errorf("Expected 4 vectors after 5 inserts and a delete, got %d", db.Size())
+	}
+	if metadata, err := db.GetMetadata(5); err != nil || metadata["n"] != float64(5) {
+		t.Errorf("Expected the metadata of vector 5, got %v (%v)", metadata, err)
+	}
+	stats := consumer.Stats()
+	if stats.Fetched != 6 || stats.Applied != 6 || stats.Batches != 2 || stats.Errors != 1 || stats.Lag != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected the failed commit to be reported once, got %v", errs)
+	}
+	if offset, ok, err := Checkpoint(db, "vectors", 0); err != nil || !ok || offset != 6 {
+		t.Errorf("Expected checkpoint 6, got %d, %v (%v)", offset, ok, err)
+	}
+	if _, ok, err := Checkpoint(db, "vectors", 1); err != nil || ok {
+		t.Errorf("Expected no checkpoint for another partition, got %v (%v)", ok, err)
+	}
+}
+
+func TestConsumer_DurableWithoutSync(t *testing.T) {
+	dir := t.TempDir()
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(dir, "ingest.db")
+	config.Dimension = 2
+	config.IndexType = "flat"
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	source := newMemorySource(`{"id": 1, "vector": [1, 0], "metadata": {"n": 1}}`, `{"id": 2, "vector": [2, 0]}`)
+	consumer, err := NewConsumer(db, source, Config{})
+	if err != nil {
+		t.Fatalf("NewConsumer failed: %v", err)
+	}
+	runUntilCommitted(t, consumer, source)
+
+	// Copy the files as a crash would leave them: flushed, never synced or closed
+	crashed := filepath.Join(t.TempDir(), "ingest.db")
+	for _, suffix := range []string{"", ".meta", ".kv"} {
+		data, err := os.ReadFile(config.DataPath + suffix)
+		if err != nil {
+			t.Fatalf("Expected %s to be written: %v", suffix, err)
+		}
+		if err := os.WriteFile(crashed+suffix, data, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	config.DataPath = crashed
+	reopened, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != 2 {
+		t.Errorf("Expected 2 vectors, got %d", reopened.Size())
+	}
+	if metadata, err := reopened.GetMetadata(1); err != nil || metadata["n"] != float64(1) {
+		t.Errorf("Expected the metadata of vector 1, got %v (%v)", metadata, err)
+	}
+	if offset, ok, err := Checkpoint(reopened, "vectors", 0); err != nil || !ok || offset != 2 {
+		t.Errorf("Expected checkpoint 2, got %d, %v (%v)", offset, ok, err)
+	}
+}
+
+func TestConsumer_InvalidMessages(t *testing.T) {
+	db := newTestDB(t)
+	source := newMemorySource(`{"id": 1, "vector": [1, 0]}`, `not json`, `{"id": 3, "vector": [1, 2, 3]}`)
+	consumer, _ := NewConsumer(db, source, Config{})
+
+	var invalid *InvalidMessageError
+	if err := consumer.Run(context.Background()); !errors.As(err, &invalid) || invalid.Message.Offset != 1 {
+		t.Fatalf("Expected an InvalidMessageError for offset 1, got %v", err)
+	}
+	if db.Size() != 0 || source.committed != 0 {
+		t.Errorf("Expected nothing written or committed, got %d vectors, committed %d", db.Size(), source.committed)
+	}
+
+	source.next = 0
+	consumer, _ = NewConsumer(db, source, Config{SkipInvalid: true})
+	runUntilCommitted(t, consumer, source)
+	if stats := consumer.Stats(); db.Size() != 1 || stats.Invalid != 2 || stats.Applied != 1 {
+		t.Errorf("Expected 1 write and 2 skipped messages, got size %d and %+v", db.Size(), stats)
+	}
+}
+
+func TestBinaryCodec(t *testing.T) {
+	record, err := BinaryCodec.Decode(Message{Key: []byte("42"), Value: []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0x40}})
+	if err != nil || record.ID != 42 || len(record.Vector) != 2 || record.Vector[0] != 1 || record.Vector[1] != 2 {
+		t.Errorf("Expected vector 42 = [1 2], got %+v (%v)", record, err)
+	}
+	if record, err := BinaryCodec.Decode(Message{Key: []byte("42")}); err != nil || !record.Delete {
+		t.Errorf("Expected a tombstone to delete, got %+v (%v)", record, err)
+	}
+	if _, err := BinaryCodec.Decode(Message{Key: []byte("x"), Value: []byte{1, 2, 3, 4}}); err == nil {
+		t.Error("Expected an invalid key to be rejected")
+	}
+}
+
+func TestConsumer_RejectedRecords(t *testing.T) {
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "ingest.db")
+	config.Dimension = 2
+	config.IndexType = "flat"
+	config.BeforeInsert = func(id uint64, vector []float32) error {
+		if id == 2 {
+			return errors.New("ID 2 is blocked")
+		}
+		return nil
+	}
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	source := newMemorySource(`{"id": 1, "vector": [1, 0]}`, `{"id": 2, "vector": [2, 0]}`, `{"id": 3, "vector": [3, 0]}`)
+
+	// A rejected record is not retried as a transient error
+	var errs []error
+	consumer, _ := NewConsumer(db, source, Config{Retry: time.Millisecond, OnError: func(err error) { errs = append(errs, err) }})
+	var invalid *InvalidMessageError
+	if err := consumer.Run(context.Background()); !errors.As(err, &invalid) || invalid.Message.Offset != 1 ||
+		!errors.Is(err, veclite.ErrRejected) {
+		t.Fatalf("Expected an InvalidMessageError rejecting offset 1, got %v", err)
+	}
+	if db.Size() != 0 || source.committed != 0 || len(errs) != 0 {
+		t.Errorf("Expected nothing written, committed or retried, got %d vectors, committed %d, errors %v", db.Size(), source.committed, errs)
+	}
+
+	source.next = 0
+	consumer, _ = NewConsumer(db, source, Config{SkipInvalid: true, OnError: func(err error) { errs = append(errs, err) }})
+	runUntilCommitted(t, consumer, source)
+	if stats := consumer.Stats(); db.Size() != 2 || stats.Invalid != 1 || stats.Applied != 2 || stats.Errors != 0 {
+		t.Errorf("Expected 2 writes and 1 skipped message, got size %d and %+v", db.Size(), stats)
+	}
+	if len(errs) != 1 || !errors.As(errs[0], &invalid) || invalid.Message.Offset != 1 {
+		t.Errorf("Expected the rejected message to be reported once, got %v", errs)
+	}
+}
@@ -0,0 +1,141 @@
+// Package server exposes a VecLite database over gRPC, so it can be shared
+// between processes or used from clients in other languages
+// The service is defined in veclitepb/veclite.proto; cmd/veclite-server runs it
+package server
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/monishSR/veclite/pkg/server/veclitepb"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// Server implements the VecLite gRPC service on top of an open database
+// The database stays owned by the caller, which must close it after the
+// gRPC server has stopped
+type Server struct {
+	veclitepb.UnimplementedVecLiteServer
+	db *veclite.VecLite
+}
+
+// New creates a server for db
+func New(db *veclite.VecLite) *Server {
+	return &Server{db: db}
+}
+
+// Register registers the VecLite service on s
+func (s *Server) Register(gs *grpc.Server) {
+	veclitepb.RegisterVecLiteServer(gs, s)
+}
+
+// Insert implements veclitepb.VecLiteServer
+func (s *Server) Insert(ctx context.Context, req *veclitepb.InsertRequest) (*veclitepb.InsertResponse, error) {
+	var err error
+	switch {
+	case req.Metadata != nil && req.Upsert:
+		err = s.db.UpsertWithMetadataContext(ctx, req.Id, req.Vector, req.Metadata.AsMap())
+	case req.Metadata != nil:
+		err = s.db.InsertWithMetadataContext(ctx, req.Id, req.Vector, req.Metadata.AsMap())
+	case req.Upsert:
+		err = s.db.UpsertContext(ctx, req.Id, req.Vector)
+	default:
+		err = s.db.InsertContext(ctx, req.Id, req.Vector)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &veclitepb.InsertResponse{}, nil
+}
+
+// Search implements veclitepb.VecLiteServer
+func (s *Server) Search(ctx context.Context, req *veclitepb.SearchRequest) (*veclitepb.SearchResponse, error) {
+	if req.K == 0 {
+		return nil, status.Error(codes.InvalidArgument, "k must be positive")
+	}
+	opts := veclite.SearchOptions{IncludeScore: true, IncludeMetadata: true}
+	if req.Filter != nil {
+		opts.Filter = req.Filter.AsMap()
+	}
+	results, err := s.db.SearchWithOptionsContext(ctx, req.Vector, int(req.K), opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &veclitepb.SearchResponse{Results: make([]*veclitepb.SearchResult, len(results))}
+	for i, r := range results {
+		metadata, err := toStruct(r.Metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "metadata of vector %d: %v", r.ID, err)
+		}
+		resp.Results[i] = &veclitepb.SearchResult{Id: r.ID, Key: r.Key, Distance: r.Distance, Metadata: metadata}
+	}
+	return resp, nil
+}
+
+// Get implements veclitepb.VecLiteServer
+func (s *Server) Get(ctx context.Context, req *veclitepb.GetRequest) (*veclitepb.GetResponse, error) {
+	vector, err := s.db.GetContext(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	metadata, err := s.db.GetMetadata(req.Id)
+	if err != nil && !errors.Is(err, veclite.ErrNoMetadata) {
+		return nil, toStatus(err)
+	}
+	pbMetadata, err := toStruct(metadata)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "metadata of vector %d: %v", req.Id, err)
+	}
+	return &veclitepb.GetResponse{Vector: vector, Metadata: pbMetadata}, nil
+}
+
+// Delete implements veclitepb.VecLiteServer
+func (s *Server) Delete(ctx context.Context, req *veclitepb.DeleteRequest) (*veclitepb.DeleteResponse, error) {
+	if err := s.db.DeleteContext(ctx, req.Id); err != nil {
+		return nil, toStatus(err)
+	}
+	return &veclitepb.DeleteResponse{}, nil
+}
+
+// toStruct converts metadata for a response (nil for no metadata)
+func toStruct(metadata map[string]any) (*structpb.Struct, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return structpb.NewStruct(metadata)
+}
+
+// toStatus maps database errors to gRPC status codes
+func toStatus(err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, veclite.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, veclite.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(err, veclite.ErrDimensionMismatch), errors.Is(err, veclite.ErrNonFinite), errors.Is(err, veclite.ErrReservedID),
+		errors.Is(err, veclite.ErrKTooLarge), errors.Is(err, veclite.ErrInvalidFilter):
+		code = codes.InvalidArgument
+	case errors.Is(err, veclite.ErrEmptyDatabase):
+		code = codes.FailedPrecondition
+	case errors.Is(err, veclite.ErrRejected):
+		code = codes.FailedPrecondition
+	case errors.Is(err, veclite.ErrWriteStall), errors.Is(err, veclite.ErrQueryTooExpensive):
+		code = codes.ResourceExhausted
+	case errors.Is(err, veclite.ErrUnhealthy):
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/monishSR/veclite/pkg/server/veclitepb"
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// startTestServer serves a new 4-dimensional flat database over an in-memory
+// listener and returns a client for it
+func startTestServer(t *testing.T) veclitepb.VecLiteClient {
+	t.Helper()
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "server.db")
+	config.Dimension = 4
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	New(db).Register(gs)
+	go gs.Serve(lis)
+	t.Cleanup(func() {
+		gs.GracefulStop()
+		db.Close()
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return veclitepb.NewVecLiteClient(conn)
+}
+
+// expectCode fails the test unless err has the gRPC status code
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("Expected %v, got %v", code, err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	client := startTestServer(t)
+	ctx := context.Background()
+
+	metadata, _ := structpb.NewStruct(map[string]any{"lang": "en"})
+	if _, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 1, Vector: []float32{1, 0, 0, 0}, Metadata: metadata}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 2, Vector: []float32{0, 1, 0, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	resp, err := client.Search(ctx, &veclitepb.SearchRequest{Vector: []float32{0.9, 0.1, 0, 0}, K: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Id != 1 || resp.Results[0].Metadata.AsMap()["lang"] != "en" {
+		t.Errorf("Expected ID 1 with its metadata first, got %v", resp.Results)
+	}
+	filter, _ := structpb.NewStruct(map[string]any{"lang": "en"})
+	resp, err = client.Search(ctx, &veclitepb.SearchRequest{Vector: []float32{0, 1, 0, 0}, K: 2, Filter: filter})
+	if err != nil || len(resp.Results) != 1 || resp.Results[0].Id != 1 {
+		t.Errorf("Expected only ID 1 to match the filter, got %v (%v)", resp, err)
+	}
+
+	got, err := client.Get(ctx, &veclitepb.GetRequest{Id: 1})
+	if err != nil || len(got.Vector) != 4 || got.Vector[0] != 1 || got.Metadata.AsMap()["lang"] != "en" {
+		t.Errorf("Expected vector 1 with its metadata, got %v (%v)", got, err)
+	}
+
+	// Upserts replace the vector and keep the metadata
+	if _, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 1, Vector: []float32{0, 0, 1, 0}, Upsert: true}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	got, err = client.Get(ctx, &veclitepb.GetRequest{Id: 1})
+	if err != nil || got.Vector[2] != 1 || got.Metadata.AsMap()["lang"] != "en" {
+		t.Errorf("Expected the upserted vector with its metadata, got %v (%v)", got, err)
+	}
+
+	if _, err := client.Delete(ctx, &veclitepb.DeleteRequest{Id: 2}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	_, err = client.Get(ctx, &veclitepb.GetRequest{Id: 2})
+	expectCode(t, err, codes.NotFound)
+}
+
+func TestServer_Errors(t *testing.T) {
+	client := startTestServer(t)
+	ctx := context.Background()
+
+	if _, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 1, Vector: []float32{1, 0, 0, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	_, err := client.Insert(ctx, &veclitepb.InsertRequest{Id: 1, Vector: []float32{1, 0, 0, 0}})
+	expectCode(t, err, codes.AlreadyExists)
+	_, err = client.Insert(ctx, &veclitepb.InsertRequest{Id: 2, Vector: []float32{1}})
+	expectCode(t, err, codes.InvalidArgument)
+	_, err = client.Search(ctx, &veclitepb.SearchRequest{Vector: []float32{1, 0, 0, 0}})
+	expectCode(t, err, codes.InvalidArgument)
+	_, err = client.Get(ctx, &veclitepb.GetRequest{Id: 42})
+	expectCode(t, err, codes.NotFound)
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{veclite.ErrNotFound, codes.NotFound},
+		{veclite.ErrAlreadyExists, codes.AlreadyExists},
+		{veclite.ErrDimensionMismatch, codes.InvalidArgument},
+		{veclite.ErrNonFinite, codes.InvalidArgument},
+		{veclite.ErrReservedID, codes.InvalidArgument},
+		{veclite.ErrKTooLarge, codes.InvalidArgument},
+		{veclite.ErrInvalidFilter, codes.InvalidArgument},
+		{veclite.ErrEmptyDatabase, codes.FailedPrecondition},
+		{veclite.ErrRejected, codes.FailedPrecondition},
+		{veclite.ErrWriteStall, codes.ResourceExhausted},
+		{errors.New("disk failure"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(toStatus(fmt.Errorf("wrapped: %w", tt.err))); got != tt.want {
+			t.Errorf("toStatus(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
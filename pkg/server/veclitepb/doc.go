@@ -0,0 +1,5 @@
+// Package veclitepb holds the protobuf messages and gRPC stubs of the VecLite
+// service served by pkg/server, generated from veclite.proto
+package veclitepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative veclite.proto
@@ -0,0 +1,187 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// ErrQueryTooExpensive is wrapped by errors returned when a search is estimated
+// to exceed Config.QueryBudget; the error is a *QueryCostError with the estimate
+var ErrQueryTooExpensive = errors.New("query too expensive")
+
+// QueryBudgetConfig bounds the estimated work of a single search, so one huge
+// query (e.g. k=10000 with a Reranker over 50x candidates) cannot monopolize
+// the database
+// Searches over budget are rejected with ErrQueryTooExpensive, or queued to run
+// one at a time if Queue is set
+type QueryBudgetConfig struct {
+	MaxDistances  int // Estimated distance computations a search may cost (0 = unlimited)
+	MaxCandidates int // Candidates a search may fetch from the index, see QueryCost.Candidates (0 = unlimited)
+
+	// Queue is the number of over-budget searches that may wait to run one at a
+	// time (bounded by their context); further ones are rejected (0 = reject all)
+	Queue int
+}
+
+// QueryCost is the estimated work of a search, see Config.QueryBudget and
+// Explanation.Estimate
+type QueryCost struct {
+	Candidates int // Results requested from the index: k, RerankCandidates with a Reranker, more for postfiltered searches
+	Distances  int // Estimated distance computations, hot and cold tiers
+}
+
+// QueryCostError is returned for searches estimated to exceed the budget
+type QueryCostError struct {
+	Estimate QueryCost
+	Budget   QueryBudgetConfig
+}
+
+func (e *QueryCostError) Error() string {
+	return fmt.Sprintf("%v: estimated %d distance computations and %d candidates (budget: %d distances, %d candidates)",
+		ErrQueryTooExpensive, e.Estimate.Distances, e.Estimate.Candidates, e.Budget.MaxDistances, e.Budget.MaxCandidates)
+}
+
+// Unwrap lets errors.Is(err, ErrQueryTooExpensive) match
+func (e *QueryCostError) Unwrap() error {
+	return ErrQueryTooExpensive
+}
+
+// QueryBudgetStats reports admission control in Stats
+type QueryBudgetStats struct {
+	Rejected uint64 // Searches rejected with ErrQueryTooExpensive since open
+	Queued   uint64 // Over-budget searches that waited in the queue since open
+	Waiting  int    // Over-budget searches running or waiting to run
+}
+
+// admission rejects or queues searches over the budget
+type admission struct {
+	budget   QueryBudgetConfig
+	slot     chan struct{} // Held by the over-budget search running
+	waiting  atomic.Int64  // Over-budget searches holding or waiting for slot
+	rejected atomic.Uint64
+	queued   atomic.Uint64
+}
+
+// newAdmission creates admission control for budget (nil if budget is nil)
+func newAdmission(budget *QueryBudgetConfig) *admission {
+	if budget == nil {
+		return nil
+	}
+	return &admission{budget: *budget, slot: make(chan struct{}, 1)}
+}
+
+// over reports whether cost exceeds the budget
+func (a *admission) over(cost QueryCost) bool {
+	return (a.budget.MaxDistances > 0 && cost.Distances > a.budget.MaxDistances) ||
+		(a.budget.MaxCandidates > 0 && cost.Candidates > a.budget.MaxCandidates)
+}
+
+// admit lets a search with the estimated cost run, waiting for its turn if it
+// is over budget and the queue has room, and returns the function ending it
+func (a *admission) admit(ctx context.Context, cost QueryCost) (func(), error) {
+	if !a.over(cost) {
+		return func() {}, nil
+	}
+	if a.waiting.Add(1) > int64(a.budget.Queue) {
+		a.waiting.Add(-1)
+		a.rejected.Add(1)
+		return nil, &QueryCostError{Estimate: cost, Budget: a.budget}
+	}
+	a.queued.Add(1)
+	select {
+	case a.slot <- struct{}{}:
+		return func() {
+			<-a.slot
+			a.waiting.Add(-1)
+		}, nil
+	case <-ctx.Done():
+		a.waiting.Add(-1)
+		return nil, ctx.Err()
+	}
+}
+
+// state returns the admission section of Stats (nil = no budget)
+func (a *admission) state() *QueryBudgetStats {
+	if a == nil {
+		return nil
+	}
+	return &QueryBudgetStats{
+		Rejected: a.rejected.Load(),
+		Queued:   a.queued.Load(),
+		Waiting:  int(a.waiting.Load()),
+	}
+}
+
+// admitSearch estimates the cost of a search under a short read lock and
+// admits it (requires Config.QueryBudget)
+func (v *VecLite) admitSearch(ctx context.Context, fetch int, filter Filter) (func(), error) {
+	if err := v.rLockContext(ctx); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	cost := v.estimateCost(fetch, filter)
+	v.mu.RUnlock()
+	return v.admission.admit(ctx, cost)
+}
+
+// estimateCost estimates the work of a search fetching fetch candidates, from
+// the index parameters and, for filtered searches, the query plan
+// Note: Assumes read lock is already held
+func (v *VecLite) estimateCost(fetch int, filter Filter) QueryCost {
+	cost := QueryCost{Candidates: fetch}
+	if len(filter) > 0 {
+		plan := v.planFilter(filter, fetch)
+		if plan.Strategy == PlanPrefilter {
+			cost.Distances = plan.EstimatedMatches
+		} else {
+			cost.Candidates = plan.Candidates
+			cost.Distances = v.estimateIndexDistances(plan.Candidates)
+		}
+	} else {
+		cost.Distances = v.estimateIndexDistances(fetch)
+	}
+	cost.Distances += v.tiers.size() // Cold segments are scanned in full
+	return cost
+}
+
+// estimateIndexDistances estimates the distance computations of an index
+// search for n candidates
+// Note: Assumes read lock is already held
+func (v *VecLite) estimateIndexDistances(n int) int {
+	size := v.index.Size()
+	params := map[string]int{}
+	if p, ok := v.index.(index.Parameterized); ok {
+		params = p.Params()
+	}
+	switch v.servingIndex() {
+	case string(index.IndexTypeHNSW):
+		// Upper layers are descended greedily; layer 0 explores up to ef
+		// candidates with 2*M neighbors each
+		m := max(params["M"], 2)
+		ef := max(params["EfSearch"], n)
+		layers := int(math.Ceil(math.Log(float64(size+1)) / math.Log(float64(m))))
+		return min(size, ef*2*m+layers*m)
+	case string(index.IndexTypeIVF), string(index.IndexTypeIVFPQ):
+		clusters := params["NClusters"]
+		if clusters <= 0 {
+			return size
+		}
+		probe := min(max(params["NProbe"], 1), clusters)
+		scanned := clusters + int(math.Ceil(float64(size)*float64(probe)/float64(clusters)))
+		if _, ok := params["PQSubspaces"]; ok {
+			// IVF-PQ scores the probed vectors by code, then re-ranks the nearest exactly
+			rerank := 2 * n
+			if params["RerankK"] > 0 {
+				rerank = max(params["RerankK"], n)
+			}
+			scanned += rerank
+		}
+		return scanned
+	default: // Flat scans everything
+		return size
+	}
+}
@@ -0,0 +1,85 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVecLite_QueryBudget(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/budget.db"
+	config.Dimension = 128
+	config.IndexType = "flat"
+	config.QueryBudget = &QueryBudgetConfig{MaxDistances: 1000, MaxCandidates: 50}
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	insertTestVectors(t, db, 100)
+
+	query := make([]float32, 128)
+	if _, err := db.Search(query, 10); err != nil {
+		t.Fatalf("Expected a search within budget to run, got %v", err)
+	}
+
+	// 4x candidates for the Reranker exceed MaxCandidates
+	opts := DefaultSearchOptions()
+	opts.Reranker = func(query []float32, candidates []SearchResult) []SearchResult { return candidates }
+	_, err = db.SearchWithOptions(query, 20, opts)
+	var costErr *QueryCostError
+	if !errors.Is(err, ErrQueryTooExpensive) || !errors.As(err, &costErr) {
+		t.Fatalf("Expected ErrQueryTooExpensive, got %v", err)
+	}
+	if costErr.Estimate.Candidates != 80 || costErr.Estimate.Distances != 100 {
+		t.Errorf("Unexpected estimate: %+v", costErr.Estimate)
+	}
+
+	explain, err := db.Explain(query, 10, DefaultSearchOptions())
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.Estimate.Distances != 100 || uint64(explain.Estimate.Distances) != explain.Counters.Distances {
+		t.Errorf("Expected the flat estimate to match the work, got %+v and %+v", explain.Estimate, explain.Counters)
+	}
+	if stats := db.Stats().QueryBudget; stats == nil || stats.Rejected != 1 || stats.Waiting != 0 {
+		t.Errorf("Unexpected budget stats: %+v", stats)
+	}
+}
+
+func TestAdmission_Queue(t *testing.T) {
+	a := newAdmission(&QueryBudgetConfig{MaxDistances: 10, Queue: 1})
+	expensive := QueryCost{Distances: 11}
+
+	release, err := a.admit(context.Background(), expensive)
+	if err != nil {
+		t.Fatalf("Expected the first over-budget search to be queued, got %v", err)
+	}
+	if _, err := a.admit(context.Background(), expensive); !errors.Is(err, ErrQueryTooExpensive) {
+		t.Errorf("Expected a full queue to reject, got %v", err)
+	}
+	if cheap, err := a.admit(context.Background(), QueryCost{Distances: 10}); err != nil {
+		t.Errorf("Expected searches within budget to bypass the queue, got %v", err)
+	} else {
+		cheap()
+	}
+	release()
+
+	// The slot is free again; a waiting search gives up with its context
+	release, err = a.admit(context.Background(), expensive)
+	if err != nil {
+		t.Fatalf("Expected a queued search after release, got %v", err)
+	}
+	defer release()
+	a.budget.Queue = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.admit(ctx, expensive); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	if stats := a.state(); stats.Rejected != 1 || stats.Queued != 3 || stats.Waiting != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
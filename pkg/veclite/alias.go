@@ -0,0 +1,325 @@
+package veclite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrAliasNotFound is returned when an alias has not been set
+var ErrAliasNotFound = errors.New("alias not found")
+
+// Aliases maps names (e.g. "prod") to databases so a freshly rebuilt database can
+// replace the serving one atomically, with the replaced one kept for rollback
+// Aliases never close databases: once a replaced database has drained, close it
+// (or keep it open to be able to roll back to it)
+// Aliases live in memory; aliases of the collections of a Collections registry
+// are kept by the registry itself (Collections.SwapAlias), which persists them
+type Aliases struct {
+	mu      sync.RWMutex      // Guards the map and the targets
+	targets map[string]*alias // Alias name -> target
+}
+
+// alias is one named pointer
+type alias struct {
+	current  *VecLite
+	previous *VecLite // Target before the last swap
+}
+
+// NewAliases creates an empty alias registry
+func NewAliases() *Aliases {
+	return &Aliases{targets: make(map[string]*alias)}
+}
+
+// SwapAlias atomically points name at db and returns the database it pointed at
+// before (nil if the alias is new). Searches resolving the alias afterwards use db,
+// searches already running on the previous database complete normally.
+func (a *Aliases) SwapAlias(name string, db *VecLite) (*VecLite, error) {
+	if db == nil {
+		return nil, errors.New("alias target must not be nil")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	target, ok := a.targets[name]
+	if !ok {
+		target = &alias{}
+		a.targets[name] = target
+	}
+	previous := target.current
+	target.current, target.previous = db, previous
+	return previous, nil
+}
+
+// Rollback points name back at the database it pointed at before the last swap
+// and returns the database it replaced
+func (a *Aliases) Rollback(name string) (*VecLite, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	target, ok := a.targets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrAliasNotFound, name)
+	}
+	if target.previous == nil {
+		return nil, fmt.Errorf("alias %q has no previous target to roll back to", name)
+	}
+	replaced := target.current
+	target.current, target.previous = target.previous, replaced
+	return replaced, nil
+}
+
+// Resolve returns the database name currently points at
+func (a *Aliases) Resolve(name string) (*VecLite, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	target, ok := a.targets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrAliasNotFound, name)
+	}
+	return target.current, nil
+}
+
+// RemoveAlias deletes name; the databases it referenced are left open
+func (a *Aliases) RemoveAlias(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.targets, name)
+}
+
+// Names returns the alias names currently set in sorted order
+func (a *Aliases) Names() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.targets))
+	for name := range a.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Search resolves name and searches the database it points at
+func (a *Aliases) Search(name string, query []float32, k int) ([]SearchResult, error) {
+	db, err := a.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return db.Search(query, k)
+}
+
+// aliasesManifestName is the file in the collection directory, next to
+// collections.json, recording the aliases of the collections
+const aliasesManifestName = "aliases.json"
+
+// collectionAlias is what the alias manifest records of an alias
+type collectionAlias struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous,omitempty"` // Target before the last swap ("" = none)
+}
+
+// SwapAlias points the alias name at the collection and returns the collection
+// it pointed at before ("" if the alias is new)
+// Aliases are recorded in <dir>/aliases.json, so they, and Rollback, survive a
+// restart. Collections are opened by ResolveAlias, not by the swap
+func (c *Collections) SwapAlias(name, collection string) (string, error) {
+	if name == "" {
+		return "", errors.New("alias name must not be empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadManifest(); err != nil {
+		return "", err
+	}
+	if _, ok := c.specs[collection]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrCollectionNotFound, collection)
+	}
+	if err := c.loadAliases(); err != nil {
+		return "", err
+	}
+	old, existed := c.aliases[name]
+	c.aliases[name] = collectionAlias{Current: collection, Previous: old.Current}
+	if err := c.saveAliases(); err != nil {
+		c.restoreAlias(name, old, existed)
+		return "", err
+	}
+	return old.Current, nil
+}
+
+// RollbackAlias points the alias name back at the collection it pointed at
+// before the last swap and returns the collection it replaced
+func (c *Collections) RollbackAlias(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadAliases(); err != nil {
+		return "", err
+	}
+	old, ok := c.aliases[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrAliasNotFound, name)
+	}
+	if old.Previous == "" {
+		return "", fmt.Errorf("alias %q has no previous target to roll back to", name)
+	}
+	c.aliases[name] = collectionAlias{Current: old.Previous, Previous: old.Current}
+	if err := c.saveAliases(); err != nil {
+		c.aliases[name] = old
+		return "", err
+	}
+	return old.Current, nil
+}
+
+// ResolveAlias returns the handle of the collection the alias name points at,
+// opening it on first use like Reopen
+func (c *Collections) ResolveAlias(name string) (*VecLite, error) {
+	collection, err := c.AliasTarget(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Reopen(collection)
+}
+
+// AliasTarget returns the collection the alias name points at
+func (c *Collections) AliasTarget(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadAliases(); err != nil {
+		return "", err
+	}
+	target, ok := c.aliases[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrAliasNotFound, name)
+	}
+	return target.Current, nil
+}
+
+// RemoveAlias deletes the alias name; the collections it referenced are kept
+func (c *Collections) RemoveAlias(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadAliases(); err != nil {
+		return err
+	}
+	old, ok := c.aliases[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrAliasNotFound, name)
+	}
+	delete(c.aliases, name)
+	if err := c.saveAliases(); err != nil {
+		c.aliases[name] = old
+		return err
+	}
+	return nil
+}
+
+// AliasNames returns the aliases of the collections in sorted order
+func (c *Collections) AliasNames() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadAliases(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(c.aliases))
+	for name := range c.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// restoreAlias undoes a change of the alias name that failed to be saved
+// Note: Assumes mu is already held
+func (c *Collections) restoreAlias(name string, old collectionAlias, existed bool) {
+	if existed {
+		c.aliases[name] = old
+	} else {
+		delete(c.aliases, name)
+	}
+}
+
+// unlinkAliases clears the previous target of the aliases that could roll back
+// to collection, failing if one points at it
+// Note: Assumes mu is already held
+func (c *Collections) unlinkAliases(collection string) error {
+	if err := c.loadAliases(); err != nil {
+		return err
+	}
+	old := c.aliases
+	unlinked := maps.Clone(old)
+	for name, target := range old {
+		if target.Current == collection {
+			return fmt.Errorf("collection %q is the target of alias %q", collection, name)
+		}
+		if target.Previous == collection {
+			target.Previous = ""
+			unlinked[name] = target
+		}
+	}
+	if maps.Equal(old, unlinked) {
+		return nil
+	}
+	c.aliases = unlinked
+	if err := c.saveAliases(); err != nil {
+		c.aliases = old
+		return err
+	}
+	return nil
+}
+
+// aliasManifest is the content of the alias manifest
+type aliasManifest struct {
+	Aliases map[string]collectionAlias `json:"aliases"`
+}
+
+// loadAliases reads the alias manifest, once
+// Note: Assumes mu is already held
+func (c *Collections) loadAliases() error {
+	if c.aliases != nil {
+		return nil
+	}
+	var manifest aliasManifest
+	data, err := os.ReadFile(filepath.Join(c.dir, aliasesManifestName))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read alias manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("failed to decode alias manifest: %w", err)
+		}
+	}
+	c.aliases = manifest.Aliases
+	if c.aliases == nil {
+		c.aliases = make(map[string]collectionAlias)
+	}
+	return nil
+}
+
+// saveAliases writes the alias manifest atomically
+// Note: Assumes mu is already held
+func (c *Collections) saveAliases() error {
+	data, err := json.MarshalIndent(aliasManifest{Aliases: c.aliases}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode alias manifest: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create collection directory: %w", err)
+	}
+	path := filepath.Join(c.dir, aliasesManifestName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write alias manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace alias manifest: %w", err)
+	}
+	return nil
+}
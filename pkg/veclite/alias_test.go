@@ -0,0 +1,186 @@
+package veclite
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAliases_SwapAndRollback(t *testing.T) {
+	oldDB, cleanupOld := createTestDB(t, "flat")
+	defer cleanupOld()
+	newDB, cleanupNew := createTestDB(t, "flat")
+	defer cleanupNew()
+
+	if err := oldDB.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := newDB.Insert(2, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	aliases := NewAliases()
+	if _, err := aliases.Resolve("prod"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound, got %v", err)
+	}
+
+	previous, err := aliases.SwapAlias("prod", oldDB)
+	if err != nil || previous != nil {
+		t.Fatalf("Expected new alias with no previous target, got %v (%v)", previous, err)
+	}
+	results, err := aliases.Search("prod", make([]float32, 128), 1)
+	if err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("Expected old database result, got %v (%v)", results, err)
+	}
+
+	previous, err = aliases.SwapAlias("prod", newDB)
+	if err != nil || previous != oldDB {
+		t.Fatalf("Expected swap to return the old database, got %v (%v)", previous, err)
+	}
+	results, err = aliases.Search("prod", make([]float32, 128), 1)
+	if err != nil || len(results) != 1 || results[0].ID != 2 {
+		t.Errorf("Expected new database result, got %v (%v)", results, err)
+	}
+
+	replaced, err := aliases.Rollback("prod")
+	if err != nil || replaced != newDB {
+		t.Fatalf("Expected rollback to replace the new database, got %v (%v)", replaced, err)
+	}
+	if db, _ := aliases.Resolve("prod"); db != oldDB {
+		t.Error("Expected alias to point at the old database after rollback")
+	}
+
+	if names := aliases.Names(); len(names) != 1 || names[0] != "prod" {
+		t.Errorf("Expected [prod], got %v", names)
+	}
+	aliases.RemoveAlias("prod")
+	if _, err := aliases.Rollback("prod"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound after removal, got %v", err)
+	}
+}
+
+func TestAliases_Errors(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	aliases := NewAliases()
+	if _, err := aliases.SwapAlias("prod", nil); err == nil {
+		t.Error("Expected error for nil target")
+	}
+	if _, err := aliases.SwapAlias("prod", db); err != nil {
+		t.Fatalf("SwapAlias failed: %v", err)
+	}
+	if _, err := aliases.Rollback("prod"); err == nil {
+		t.Error("Expected error rolling back an alias with no previous target")
+	}
+	if _, err := aliases.Search("missing", make([]float32, 128), 1); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound, got %v", err)
+	}
+}
+
+func TestAliases_ConcurrentSwapAndSearch(t *testing.T) {
+	first, cleanupFirst := createTestDB(t, "flat")
+	defer cleanupFirst()
+	second, cleanupSecond := createTestDB(t, "flat")
+	defer cleanupSecond()
+	for _, db := range []*VecLite{first, second} {
+		if err := db.Insert(1, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	aliases := NewAliases()
+	if _, err := aliases.SwapAlias("prod", first); err != nil {
+		t.Fatalf("SwapAlias failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, err := aliases.Search("prod", make([]float32, 128), 1); err != nil {
+					t.Errorf("Search during swaps failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		target := first
+		if i%2 == 0 {
+			target = second
+		}
+		if _, err := aliases.SwapAlias("prod", target); err != nil {
+			t.Errorf("SwapAlias failed: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCollections_Aliases(t *testing.T) {
+	dir := t.TempDir()
+	collections := NewCollections(dir)
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	for i, name := range []string{"v1", "v2"} {
+		db, err := collections.Open(name, config)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if err := db.Insert(uint64(i+1), []float32{1, 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := collections.SwapAlias("prod", "missing"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	if previous, err := collections.SwapAlias("prod", "v1"); err != nil || previous != "" {
+		t.Fatalf("Expected new alias with no previous target, got %q (%v)", previous, err)
+	}
+	if previous, err := collections.SwapAlias("prod", "v2"); err != nil || previous != "v1" {
+		t.Fatalf("Expected swap to return v1, got %q (%v)", previous, err)
+	}
+	if err := collections.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The aliases survive a restart and resolve through the registry
+	collections = NewCollections(dir)
+	defer collections.Close()
+	if names, err := collections.AliasNames(); err != nil || len(names) != 1 || names[0] != "prod" {
+		t.Errorf("Expected [prod], got %v (%v)", names, err)
+	}
+	db, err := collections.ResolveAlias("prod")
+	if err != nil {
+		t.Fatalf("ResolveAlias failed: %v", err)
+	}
+	if results, err := db.Search([]float32{1, 0, 0, 0}, 1); err != nil || len(results) != 1 || results[0].ID != 2 {
+		t.Errorf("Expected the result of v2, got %v (%v)", results, err)
+	}
+	if err := collections.Drop("v2"); err == nil {
+		t.Error("Expected error dropping the target of an alias")
+	}
+	if replaced, err := collections.RollbackAlias("prod"); err != nil || replaced != "v2" {
+		t.Fatalf("Expected rollback to replace v2, got %q (%v)", replaced, err)
+	}
+	if target, err := NewCollections(dir).AliasTarget("prod"); err != nil || target != "v1" {
+		t.Errorf("Expected the rollback persisted, got %q (%v)", target, err)
+	}
+
+	// Dropping the previous target leaves nothing to roll back to
+	if err := collections.Drop("v2"); err != nil {
+		t.Fatalf("Drop failed: %v", err)
+	}
+	if _, err := collections.RollbackAlias("prod"); err == nil {
+		t.Error("Expected error rolling back to a dropped collection")
+	}
+	if err := collections.RemoveAlias("prod"); err != nil {
+		t.Fatalf("RemoveAlias failed: %v", err)
+	}
+	if _, err := collections.ResolveAlias("prod"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound after removal, got %v", err)
+	}
+}
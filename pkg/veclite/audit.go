@@ -0,0 +1,89 @@
+package veclite
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// AuditConfig enables the append-only audit log of mutations
+// The audit log is independent of the data file: it is never compacted and
+// records every successful Insert and Delete as one JSON line
+type AuditConfig struct {
+	Path       string // Log file path (default: DataPath + ".audit")
+	Actor      string // Identity recorded as "who" for every entry (e.g. service name)
+	MaxSize    int64  // Rotate once the log exceeds this many bytes (0 = never rotate)
+	MaxBackups int    // Rotated files kept as Path.1 ... Path.N (0 = keep none)
+	Sync       bool   // fsync after every entry (slower, but survives power loss)
+}
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor,omitempty"`
+	Op    string    `json:"op"`
+	ID    uint64    `json:"id"`
+}
+
+// Audit operation names
+const (
+	AuditOpInsert = "insert"
+	AuditOpDelete = "delete"
+)
+
+// auditLog appends AuditEntry records to a rotating JSON-lines file
+type auditLog struct {
+	actor string // Default actor of entries
+	log   *rotatingLog
+}
+
+// openAuditLog opens (or creates) the audit log for appending
+func openAuditLog(config AuditConfig) (*auditLog, error) {
+	log, err := openRotatingLog("audit log", config.Path, config.MaxSize, config.MaxBackups, config.Sync)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{actor: config.Actor, log: log}, nil
+}
+
+// Record appends one entry, rotating the file first if it is full
+func (a *auditLog) Record(actor, op string, id uint64) error {
+	if actor == "" {
+		actor = a.actor
+	}
+	line, err := json.Marshal(AuditEntry{Time: time.Now().UTC(), Actor: actor, Op: op, ID: id})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	return a.log.append(append(line, '\n'))
+}
+
+// Close syncs and closes the active log file
+func (a *auditLog) Close() error {
+	return a.log.Close()
+}
+
+// ReadAuditLog reads all entries from one audit log file, oldest first
+func ReadAuditLog(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
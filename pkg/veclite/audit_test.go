@@ -0,0 +1,117 @@
+package veclite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVecLite_AuditLog(t *testing.T) {
+	db, _ := createTestDB(t, "flat", smallVectors, func(config *Config) {
+		config.Audit = &AuditConfig{Actor: "ingest-service"}
+	})
+	config := db.config
+
+	if err := db.Insert(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Insert(2, []float32{1, 2, 3}); err == nil {
+		t.Fatal("Expected dimension mismatch")
+	}
+	if err := db.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := ReadAuditLog(config.DataPath + ".audit")
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audited mutations, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Op != AuditOpInsert || entries[0].ID != 1 || entries[0].Actor != "ingest-service" {
+		t.Errorf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Op != AuditOpDelete || entries[1].ID != 1 {
+		t.Errorf("Unexpected second entry: %+v", entries[1])
+	}
+	if entries[0].Time.IsZero() || entries[1].Time.Before(entries[0].Time) {
+		t.Errorf("Expected ordered timestamps, got %v then %v", entries[0].Time, entries[1].Time)
+	}
+
+	// Reopening appends to the existing log
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if err := db.Insert(3, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	db.Close()
+	entries, err = ReadAuditLog(config.DataPath + ".audit")
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	if len(entries) != 3 || entries[2].ID != 3 {
+		t.Errorf("Expected appended third entry, got %+v", entries)
+	}
+}
+
+func TestVecLite_AuditLog_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mutations.log")
+	db, _ := createTestDB(t, "flat", smallVectors, func(config *Config) {
+		config.Audit = &AuditConfig{Path: path, MaxSize: 200, MaxBackups: 2, Sync: true}
+	})
+
+	for id := uint64(1); id <= 20; id++ {
+		if err := db.Insert(id, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	db.Close()
+
+	total := 0
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s exceeds MaxSize: %d bytes", name, info.Size())
+		}
+		entries, err := ReadAuditLog(name)
+		if err != nil {
+			t.Fatalf("ReadAuditLog(%s) failed: %v", name, err)
+		}
+		total += len(entries)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Expected backups beyond MaxBackups to be removed")
+	}
+
+	// The active file holds the newest entries
+	entries, _ := ReadAuditLog(path)
+	if last := entries[len(entries)-1]; last.ID != 20 {
+		t.Errorf("Expected newest entry in active file, got %+v", last)
+	}
+	if total >= 20 {
+		t.Errorf("Expected oldest entries to be rotated out, got %d kept", total)
+	}
+}
+
+func TestReadAuditLog_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.audit")
+	if err := os.WriteFile(path, []byte(fmt.Sprintln("not json")), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := ReadAuditLog(path); err == nil {
+		t.Error("Expected error for invalid audit line")
+	}
+	if _, err := ReadAuditLog(path + ".missing"); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,76 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrIDsExhausted is returned by NextID once the IDs below KeyedIDBase are used up
+var ErrIDsExhausted = errors.New("auto IDs exhausted")
+
+// NextID returns a new ID for Insert, for vectors without a natural uint64 key
+// IDs count up from 1 and skip IDs already in use. None is returned twice,
+// even across a crash: the counter is recorded in the header of the data file
+// (a crash may skip some IDs). Data files written by older versions are
+// compacted by the first call to gain the counter
+// Requires exclusive write lock; bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) NextID() (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.NextIDContext(ctx)
+}
+
+// NextIDContext is NextID with a context bounding the wait for the write lock
+func (v *VecLite) NextIDContext(ctx context.Context) (id uint64, err error) {
+	defer v.label(ctx, "next_id")()
+	if err := v.lockContext(ctx); err != nil {
+		return 0, fmt.Errorf("next ID: %w", err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic("next_id", &err)
+
+	for {
+		id, err := v.storage.NextID()
+		if err != nil {
+			return 0, fmt.Errorf("failed to allocate ID: %w", err)
+		}
+		if id >= KeyedIDBase {
+			return 0, ErrIDsExhausted
+		}
+		if _, cold := v.tiers.get(id); !cold {
+			return id, nil
+		}
+	}
+}
+
+// InsertAuto adds a vector under a new ID from NextID and returns the ID
+// The ID of a failed insert is not reused
+func (v *VecLite) InsertAuto(vector []float32) (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.insertAuto(ctx, vector, nil, false)
+}
+
+// InsertAutoWithMetadata is InsertAuto also setting the metadata of the vector
+func (v *VecLite) InsertAutoWithMetadata(vector []float32, metadata Metadata) (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.insertAuto(ctx, vector, metadata, true)
+}
+
+// insertAuto implements InsertAuto and InsertAutoWithMetadata
+// The vector is checked before an ID is allocated for it
+func (v *VecLite) insertAuto(ctx context.Context, vector []float32, metadata Metadata, setMetadata bool) (uint64, error) {
+	if err := v.validate("vector", vector); err != nil {
+		return 0, err
+	}
+	id, err := v.NextIDContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := v.insert(ctx, id, vector, metadata, setMetadata, false); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
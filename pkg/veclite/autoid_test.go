@@ -0,0 +1,46 @@
+package veclite
+
+import "testing"
+
+func TestVecLite_NextID(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		// Auto IDs skip IDs inserted explicitly
+		if err := db.Insert(1, testVector(1)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		id, err := db.InsertAutoWithMetadata(testVector(2), Metadata{"tag": "a"})
+		if err != nil || id != 2 {
+			t.Fatalf("Expected auto ID 2, got %d, %v", id, err)
+		}
+		if metadata, err := db.GetMetadata(id); err != nil || metadata["tag"] != "a" {
+			t.Errorf("Expected the metadata of the auto ID, got %v, %v", metadata, err)
+		}
+		if next, err := db.NextID(); err != nil || next != 3 {
+			t.Errorf("Expected ID 3, got %d, %v", next, err)
+		}
+		if _, err := db.InsertAuto(make([]float32, 3)); err == nil {
+			t.Error("Expected a dimension mismatch")
+		}
+
+		// IDs are not handed out again after reopening
+		config := *db.config
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		reopened, err := New(&config)
+		if err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		defer reopened.Close()
+		id, err = reopened.InsertAuto(testVector(4))
+		if err != nil || id <= 3 {
+			t.Errorf("Expected a new ID above 3, got %d, %v", id, err)
+		}
+		if vec, err := reopened.Get(2); err != nil || vec[0] != testVector(2)[0] {
+			t.Errorf("Expected to read back vector 2, got %v", err)
+		}
+	})
+}
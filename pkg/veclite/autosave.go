@@ -0,0 +1,109 @@
+package veclite
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// autoSaver runs Sync in the background every interval and after every
+// afterWrites writes, see Config.AutoSaveInterval and Config.AutoSaveAfterWrites
+type autoSaver struct {
+	interval    time.Duration // 0 = no periodic saves
+	afterWrites int64         // 0 = no saves triggered by writes
+	writes      atomic.Int64  // Vectors written or deleted since the last save
+	kick        chan struct{} // Signaled once writes reaches afterWrites
+	stop        chan struct{}
+	done        chan struct{}
+
+	mu       sync.Mutex // Guards the fields below
+	lastSave time.Time
+	lastErr  error
+}
+
+// newAutoSaver creates a saver for config (nil if auto-save is disabled)
+func newAutoSaver(config *Config) *autoSaver {
+	if config.AutoSaveInterval <= 0 && config.AutoSaveAfterWrites <= 0 {
+		return nil
+	}
+	return &autoSaver{
+		interval:    max(config.AutoSaveInterval, 0),
+		afterWrites: int64(max(config.AutoSaveAfterWrites, 0)),
+		kick:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// wrote counts n vectors written or deleted, waking the saver once enough
+// accumulated
+// Called with the write lock held
+func (a *autoSaver) wrote(n int) {
+	if a == nil {
+		return
+	}
+	if writes := a.writes.Add(int64(n)); a.afterWrites > 0 && writes >= a.afterWrites {
+		select {
+		case a.kick <- struct{}{}:
+		default: // A save is already pending
+		}
+	}
+}
+
+// startAutoSave starts the saver goroutine
+func (v *VecLite) startAutoSave(saver *autoSaver) {
+	v.autoSaver = saver
+	go func() {
+		defer v.label(context.Background(), "autosave")()
+		defer close(saver.done)
+		var tick <-chan time.Time
+		if saver.interval > 0 {
+			ticker := time.NewTicker(saver.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-saver.stop:
+				return
+			case <-tick:
+				v.autoSave()
+			case <-saver.kick:
+				if saver.writes.Load() >= saver.afterWrites { // Not saved since the kick
+					v.autoSave()
+				}
+			}
+		}
+	}()
+}
+
+// stopAutoSave stops the saver and waits for a running save to finish
+// Must be called without holding the lock
+func (v *VecLite) stopAutoSave() {
+	saver := v.autoSaver
+	if saver == nil {
+		return
+	}
+	select {
+	case <-saver.stop:
+	default:
+		close(saver.stop)
+	}
+	<-saver.done
+}
+
+// autoSave runs Sync and records the result for Stats
+// Requires exclusive write lock - blocks all reads and writes while saving
+func (v *VecLite) autoSave() {
+	saver := v.autoSaver
+	v.mu.Lock()
+	saver.writes.Store(0)
+	err := v.syncLocked()
+	v.mu.Unlock()
+
+	saver.mu.Lock()
+	saver.lastSave = time.Now()
+	saver.lastErr = err
+	saver.mu.Unlock()
+}
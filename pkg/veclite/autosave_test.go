@@ -0,0 +1,87 @@
+package veclite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForAutoSave waits until a background save of db finished after since
+func waitForAutoSave(t *testing.T, db *VecLite, since time.Time) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !db.Stats().LastAutoSave.After(since) {
+		if time.Now().After(deadline) {
+			t.Fatal("Background save did not happen")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestVecLite_AutoSaveAfterWrites(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "autosave.db")
+	config.Dimension = 2
+	config.IndexType = "hnsw"
+	config.M = 8
+	config.EfConstruction = 50
+	config.EfSearch = 50
+	config.AutoSaveAfterWrites = 10
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	for i := uint64(1); i < 10; i++ {
+		if err := db.Insert(i, []float32{float32(i), 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := os.Stat(config.DataPath + ".graph"); !os.IsNotExist(err) {
+		t.Fatalf("Expected no graph file before 10 writes, got %v", err)
+	}
+	if stats := db.Stats(); !stats.LastAutoSave.IsZero() {
+		t.Fatalf("Expected no background save yet, got one at %v", stats.LastAutoSave)
+	}
+
+	// The tenth write triggers a save
+	if err := db.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	waitForAutoSave(t, db, start)
+	if stats := db.Stats(); stats.AutoSaveError != "" {
+		t.Fatalf("Background save failed: %s", stats.AutoSaveError)
+	}
+	if _, err := os.Stat(config.DataPath + ".graph"); err != nil {
+		t.Fatalf("Expected the graph file to be saved: %v", err)
+	}
+}
+
+func TestVecLite_AutoSaveInterval(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "autosave.db")
+	config.Dimension = 2
+	config.IndexType = "ivf"
+	config.NClusters = 2
+	config.NProbe = 1
+	config.AutoSaveInterval = 5 * time.Millisecond
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := uint64(1); i <= 20; i++ {
+		if err := db.Insert(i, []float32{float32(i), 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	waitForAutoSave(t, db, time.Now())
+	if _, err := os.Stat(config.DataPath + ".ivf"); err != nil {
+		t.Fatalf("Expected the IVF file to be saved: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriteStall is returned when too many writes are already queued for the
+// write lock (see Config.MaxPendingWrites); callers should back off and retry
+var ErrWriteStall = errors.New("write stall: too many pending writes")
+
+// writeGate bounds the number of writes waiting for or holding the write lock,
+// so a slow writer (e.g. a long compaction) makes new writes fail fast instead
+// of piling up goroutines and their vectors in memory
+type writeGate struct {
+	slots   chan struct{} // One token per admitted write
+	timeout time.Duration // How long a write may wait for a slot
+	stalls  atomic.Uint64 // Writes rejected with ErrWriteStall
+}
+
+// newWriteGate creates a gate admitting max writes (nil if max <= 0)
+func newWriteGate(max int, timeout time.Duration) *writeGate {
+	if max <= 0 {
+		return nil
+	}
+	return &writeGate{slots: make(chan struct{}, max), timeout: timeout}
+}
+
+// acquire admits a write, waiting up to the gate timeout (bounded by ctx) for a slot
+func (g *writeGate) acquire(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	select {
+	case g.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if g.timeout > 0 {
+		timer := time.NewTimer(g.timeout)
+		defer timer.Stop()
+		select {
+		case g.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	g.stalls.Add(1)
+	return fmt.Errorf("%w (%d pending)", ErrWriteStall, cap(g.slots))
+}
+
+// release frees the slot taken by acquire
+func (g *writeGate) release() {
+	if g == nil {
+		return
+	}
+	<-g.slots
+}
+
+// state returns the number of admitted writes and the total number of stalls
+func (g *writeGate) state() (pending int, stalls uint64) {
+	if g == nil {
+		return 0, 0
+	}
+	return len(g.slots), g.stalls.Load()
+}
@@ -0,0 +1,81 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fillWriteGate holds the write lock and starts one insert that occupies the
+// only slot of db's write gate; the returned function releases both
+func fillWriteGate(t *testing.T, db *VecLite) func() {
+	t.Helper()
+	db.mu.Lock()
+	done := make(chan error, 1)
+	go func() { done <- db.Insert(1, make([]float32, 128)) }()
+	for pending, _ := db.writes.state(); pending != 1; pending, _ = db.writes.state() {
+		time.Sleep(time.Millisecond)
+	}
+	return func() {
+		db.mu.Unlock()
+		if err := <-done; err != nil {
+			t.Errorf("Admitted insert failed: %v", err)
+		}
+	}
+}
+
+func TestVecLite_WriteStall(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	db.writes = newWriteGate(1, 0)
+
+	release := fillWriteGate(t, db)
+	if err := db.Insert(2, make([]float32, 128)); !errors.Is(err, ErrWriteStall) {
+		t.Errorf("Expected ErrWriteStall from Insert, got %v", err)
+	}
+	if err := db.Delete(1); !errors.Is(err, ErrWriteStall) {
+		t.Errorf("Expected ErrWriteStall from Delete, got %v", err)
+	}
+	release()
+
+	stats := db.Stats()
+	if stats.WriteStalls != 2 || stats.PendingWrites != 0 {
+		t.Errorf("Expected 2 stalls and no pending writes, got %d/%d", stats.WriteStalls, stats.PendingWrites)
+	}
+	if err := db.Insert(2, make([]float32, 128)); err != nil {
+		t.Errorf("Insert after the stall cleared failed: %v", err)
+	}
+}
+
+func TestVecLite_WriteStall_Timeout(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	db.writes = newWriteGate(1, 20*time.Millisecond)
+
+	release := fillWriteGate(t, db)
+	start := time.Now()
+	if err := db.Insert(2, make([]float32, 128)); !errors.Is(err, ErrWriteStall) {
+		t.Errorf("Expected ErrWriteStall after timeout, got %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("Expected the write to wait for a slot, returned after %v", waited)
+	}
+
+	// The caller's context bounds the wait too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.InsertContext(ctx, 2, make([]float32, 128)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// A slot freed within the timeout admits the waiting write
+	errc := make(chan error, 1)
+	db.writes.timeout = 2 * time.Second
+	go func() { errc <- db.Insert(3, make([]float32, 128)) }()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	if err := <-errc; err != nil {
+		t.Errorf("Expected waiting insert to be admitted, got %v", err)
+	}
+}
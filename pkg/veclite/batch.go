@@ -0,0 +1,499 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// ErrBatchAborted is passed to the After hooks of batch operations that were
+// not attempted because an earlier operation failed
+var ErrBatchAborted = errors.New("batch aborted by an earlier failure")
+
+// BatchOpError is returned by Apply for the operation of a batch that failed
+type BatchOpError struct {
+	Index  int    // Position of the operation in the batch
+	Target string // What the operation writes, e.g. "ID 42"
+	Err    error
+}
+
+func (e *BatchOpError) Error() string {
+	return fmt.Sprintf("batch operation %d (%s): %v", e.Index, e.Target, e.Err)
+}
+
+func (e *BatchOpError) Unwrap() error {
+	return e.Err
+}
+
+// Batch is a list of inserts, deletes and KV writes applied together by Apply
+type Batch struct {
+	ops []batchOp
+}
+
+// batchOp is one operation of a batch
+// KV operations (kv) use key and value instead of id and vector
+type batchOp struct {
+	delete      bool
+	id          uint64
+	vector      []float32
+	metadata    Metadata
+	setMetadata bool
+	replace     bool // Upsert: a stored ID is replaced instead of rejected
+	kv          bool
+	key         string
+	value       []byte
+}
+
+// target names what op writes in errors
+func (op batchOp) target() string {
+	if op.kv {
+		return fmt.Sprintf("key %q", op.key)
+	}
+	return fmt.Sprintf("ID %d", op.id)
+}
+
+// Insert adds an insert of vector under id, which fails with ErrAlreadyExists
+// if id is stored when the batch reaches it
+func (b *Batch) Insert(id uint64, vector []float32) {
+	b.ops = append(b.ops, batchOp{id: id, vector: vector})
+}
+
+// InsertWithMetadata adds an insert of vector under id also setting its metadata
+func (b *Batch) InsertWithMetadata(id uint64, vector []float32, metadata Metadata) {
+	b.ops = append(b.ops, batchOp{id: id, vector: vector, metadata: metadata, setMetadata: true})
+}
+
+// Upsert adds an upsert of vector under id; existing metadata is kept
+func (b *Batch) Upsert(id uint64, vector []float32) {
+	b.ops = append(b.ops, batchOp{id: id, vector: vector, replace: true})
+}
+
+// UpsertWithMetadata adds an upsert of vector under id replacing its metadata
+func (b *Batch) UpsertWithMetadata(id uint64, vector []float32, metadata Metadata) {
+	b.ops = append(b.ops, batchOp{id: id, vector: vector, metadata: metadata, setMetadata: true, replace: true})
+}
+
+// Delete adds a delete of id
+func (b *Batch) Delete(id uint64) {
+	b.ops = append(b.ops, batchOp{delete: true, id: id})
+}
+
+// PutKV adds a KV.Put of value under key, e.g. the offset of the last
+// ingested message next to the inserts it covers
+func (b *Batch) PutKV(key string, value []byte) {
+	b.ops = append(b.ops, batchOp{kv: true, key: key, value: value})
+}
+
+// DeleteKV adds a KV.Delete of key
+func (b *Batch) DeleteKV(key string) {
+	b.ops = append(b.ops, batchOp{kv: true, delete: true, key: key})
+}
+
+// Len returns the number of operations in the batch
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Apply runs the operations of batch in order under a single write lock, so
+// readers never see a batch in progress
+// All operations are validated, pass their Before hooks and are checked
+// against the stored IDs before any is applied. Past that point the batch is
+// not atomic: an I/O error stops it, leaving the operations before the failure
+// applied (their After hooks get nil) and visible once the lock is released
+// The failing operation is reported as a *BatchOpError
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) Apply(batch *Batch) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.ApplyContext(ctx, batch)
+}
+
+// ApplyContext is Apply with a context bounding the wait for the write lock
+func (v *VecLite) ApplyContext(ctx context.Context, batch *Batch) (err error) {
+	defer v.label(ctx, "batch")()
+	if batch.Len() == 0 {
+		return nil
+	}
+	errs := make([]error, len(batch.ops)) // Per-operation error for the After hooks
+	applied := 0                          // Operations that landed before a failure
+	defer func() {
+		for i, op := range batch.ops {
+			opErr := errs[i]
+			if opErr == nil && err != nil && i >= applied {
+				opErr = ErrBatchAborted
+			}
+			if op.kv {
+				continue // KV operations have no hooks
+			}
+			if op.delete && v.config.AfterDelete != nil {
+				v.config.AfterDelete(op.id, opErr)
+			} else if !op.delete && v.config.AfterInsert != nil {
+				v.config.AfterInsert(op.id, op.vector, opErr)
+			}
+		}
+	}()
+
+	stored := make([][]float32, len(batch.ops)) // Inserted vectors after Config.Pipeline
+	for i, op := range batch.ops {
+		switch {
+		case op.kv && !op.delete:
+			errs[i] = validateKV(op.key, op.value)
+		case op.kv:
+		case op.delete && v.config.BeforeDelete != nil:
+			if hookErr := v.config.BeforeDelete(op.id); hookErr != nil {
+				errs[i] = fmt.Errorf("%w: %w", ErrRejected, hookErr)
+			}
+		case !op.delete:
+			errs[i] = v.validateInsert(op.id, op.vector)
+			if hook := v.config.BeforeInsert; errs[i] == nil && hook != nil {
+				if hookErr := hook(op.id, op.vector); hookErr != nil {
+					errs[i] = fmt.Errorf("%w: %w", ErrRejected, hookErr)
+				}
+			}
+		}
+		if errs[i] != nil {
+			return &BatchOpError{Index: i, Target: op.target(), Err: errs[i]}
+		}
+		if !op.kv && !op.delete {
+			stored[i] = v.preprocess(op.vector)
+		}
+	}
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return fmt.Errorf("apply: %w", err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return fmt.Errorf("apply: %w", err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic("apply", &err)
+
+	if i, err := v.checkExisting(batch.ops); err != nil {
+		errs[i] = err
+		return &BatchOpError{Index: i, Target: batch.ops[i].target(), Err: err}
+	}
+	for i, op := range batch.ops {
+		switch {
+		case op.kv && op.delete:
+			errs[i] = v.kv.remove(op.key, v.storage.Seq())
+		case op.kv:
+			errs[i] = v.kv.put(op.key, op.value, v.storage.Seq())
+		case op.delete:
+			errs[i] = v.deleteLocked(op.id)
+		default:
+			errs[i] = v.insertLocked(op.id, stored[i], op.metadata, op.setMetadata, op.replace)
+		}
+		if errs[i] != nil {
+			return &BatchOpError{Index: i, Target: op.target(), Err: errs[i]}
+		}
+		applied = i + 1
+	}
+	return nil
+}
+
+// checkExisting finds the first insert of ops whose ID is stored when the batch
+// reaches it, counting the IDs written and deleted by the operations before it
+// Returns its position and ErrAlreadyExists, or -1 and nil
+// Note: Assumes write lock is already held
+func (v *VecLite) checkExisting(ops []batchOp) (int, error) {
+	stored := make(map[uint64]bool) // IDs written (true) or deleted by earlier operations
+	for i, op := range ops {
+		if op.kv {
+			continue
+		}
+		exists, seen := stored[op.id]
+		if !seen {
+			exists = v.exists(op.id)
+		}
+		if !op.delete && !op.replace && exists {
+			return i, fmt.Errorf("vector %d %w", op.id, ErrAlreadyExists)
+		}
+		stored[op.id] = !op.delete
+	}
+	return -1, nil
+}
+
+// BatchOptions configures InsertBatchWithOptions and DeleteBatch
+type BatchOptions struct {
+	// ContinueOnError skips items that fail validation, their Before hook or
+	// the existence check, recording them in BatchResult.Failures, instead of
+	// aborting the batch on the first one
+	// I/O errors still abort an insert batch; deletes fail one ID at a time
+	ContinueOnError bool
+}
+
+// BatchFailure is an item of a batch that was not applied
+type BatchFailure struct {
+	Index int    // Position of the item in the batch
+	ID    uint64 // ID of the item
+	Err   error
+}
+
+// BatchResult reports the outcome of a batch item by item
+type BatchResult struct {
+	Applied  int            // Items applied
+	Failures []BatchFailure // Items skipped, in batch order
+}
+
+// Err returns the failures joined into one error (nil if there are none)
+func (r BatchResult) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = fmt.Errorf("batch item %d (ID %d): %w", f.Index, f.ID, f.Err)
+	}
+	return errors.Join(errs...)
+}
+
+// fail records the failure of item i of ids; without ContinueOnError it
+// returns the error aborting the batch
+func (r *BatchResult) fail(opts BatchOptions, ids []uint64, i int, err error) error {
+	r.Failures = append(r.Failures, BatchFailure{Index: i, ID: ids[i], Err: err})
+	if opts.ContinueOnError {
+		return nil
+	}
+	return fmt.Errorf("batch vector %d (ID %d): %w", i, ids[i], err)
+}
+
+// InsertBatch inserts vectors[i] under ids[i] for bulk loading: the vectors are
+// written to storage in one append and indexed under a single write lock,
+// instead of a lock, seek and write per vector; with Config.Progress set it
+// appends and reports OpBulkLoad progress every 4096 vectors
+// Like Insert it returns ErrAlreadyExists, writing nothing, if an ID is stored
+// or repeated in ids
+// All vectors are validated and pass their BeforeInsert hooks before any is
+// written. Like Apply, the batch is not crash-atomic
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) InsertBatch(ids []uint64, vectors [][]float32) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.InsertBatchContext(ctx, ids, vectors)
+}
+
+// InsertBatchContext is InsertBatch with a context bounding the wait for the write lock
+func (v *VecLite) InsertBatchContext(ctx context.Context, ids []uint64, vectors [][]float32) error {
+	_, err := v.InsertBatchWithOptionsContext(ctx, ids, vectors, BatchOptions{})
+	return err
+}
+
+// InsertBatchWithOptions is InsertBatch reporting the outcome of each vector
+// With opts.ContinueOnError, vectors with the wrong dimension, rejected by
+// BeforeInsert, already stored or repeated in ids (all but the first) are
+// skipped and listed in the result while the others are inserted
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) InsertBatchWithOptions(ids []uint64, vectors [][]float32, opts BatchOptions) (BatchResult, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.InsertBatchWithOptionsContext(ctx, ids, vectors, opts)
+}
+
+// InsertBatchWithOptionsContext is InsertBatchWithOptions with a context
+// bounding the wait for the write lock
+func (v *VecLite) InsertBatchWithOptionsContext(ctx context.Context, ids []uint64, vectors [][]float32, opts BatchOptions) (result BatchResult, err error) {
+	defer v.label(ctx, "insert")()
+	if len(ids) != len(vectors) {
+		return result, fmt.Errorf("insert batch: got %d IDs for %d vectors", len(ids), len(vectors))
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	errs := make([]error, len(ids)) // Per-vector error for the After hooks
+	indexed := false                // The kept vectors landed; a later audit failure does not undo them
+	if hook := v.config.AfterInsert; hook != nil {
+		defer func() {
+			for i, id := range ids {
+				opErr := errs[i]
+				if opErr == nil && err != nil && !indexed {
+					opErr = ErrBatchAborted
+				}
+				hook(id, vectors[i], opErr)
+			}
+		}()
+	}
+
+	stored := make([][]float32, len(ids)) // Vectors after Config.Pipeline
+	for i, id := range ids {
+		errs[i] = v.validateInsert(id, vectors[i])
+		if hook := v.config.BeforeInsert; errs[i] == nil && hook != nil {
+			if hookErr := hook(id, vectors[i]); hookErr != nil {
+				errs[i] = fmt.Errorf("%w: %w", ErrRejected, hookErr)
+			}
+		}
+		if errs[i] != nil {
+			if err := result.fail(opts, ids, i, errs[i]); err != nil {
+				return result, err
+			}
+			continue
+		}
+		stored[i] = v.preprocess(vectors[i])
+	}
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return result, fmt.Errorf("insert batch: %w", err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return result, fmt.Errorf("insert batch: %w", err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic("insert batch", &err)
+
+	seen := make(map[uint64]struct{}, len(ids))
+	keptIDs := make([]uint64, 0, len(ids))
+	keptVectors := make([][]float32, 0, len(ids))
+	for i, id := range ids {
+		if errs[i] != nil {
+			continue // Skipped during validation
+		}
+		if _, dup := seen[id]; dup || v.exists(id) {
+			errs[i] = fmt.Errorf("vector %d %w", id, ErrAlreadyExists)
+			if err := result.fail(opts, ids, i, errs[i]); err != nil {
+				return result, err
+			}
+			continue
+		}
+		seen[id] = struct{}{}
+		keptIDs = append(keptIDs, id)
+		keptVectors = append(keptVectors, stored[i])
+	}
+	sort.SliceStable(result.Failures, func(i, j int) bool { return result.Failures[i].Index < result.Failures[j].Index })
+	if len(keptIDs) == 0 {
+		return result, nil
+	}
+
+	for _, id := range keptIDs {
+		v.preserveForScrolls(id)
+	}
+	tracker := v.trackProgress(OpBulkLoad)
+	defer tracker.finish()
+	if err := v.indexBatch(keptIDs, keptVectors, tracker); err != nil {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = err // Which vectors made it is unknown
+			}
+		}
+		return result, err
+	}
+	indexed = true
+	for _, id := range keptIDs {
+		v.tiers.remove(id) // The new hot vectors supersede cold copies
+		v.markDirty(id)
+	}
+	v.visible.notify()
+	v.autoSaver.wrote(len(keptIDs))
+	result.Applied = len(keptIDs)
+	for i, id := range ids {
+		if errs[i] != nil {
+			continue
+		}
+		if errs[i] = v.recordAudit(AuditOpInsert, id); errs[i] != nil {
+			return result, errs[i]
+		}
+	}
+	return result, nil
+}
+
+// DeleteBatch deletes ids under a single write lock, reporting the outcome of
+// each ID
+// With opts.ContinueOnError, IDs rejected by BeforeDelete or failing to
+// delete, e.g. not stored, are skipped and listed in the result while the
+// others are deleted
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) DeleteBatch(ids []uint64, opts BatchOptions) (BatchResult, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.DeleteBatchContext(ctx, ids, opts)
+}
+
+// DeleteBatchContext is DeleteBatch with a context bounding the wait for the write lock
+func (v *VecLite) DeleteBatchContext(ctx context.Context, ids []uint64, opts BatchOptions) (result BatchResult, err error) {
+	defer v.label(ctx, "delete")()
+	if len(ids) == 0 {
+		return result, nil
+	}
+	errs := make([]error, len(ids)) // Per-ID error for the After hooks
+	attempted := make([]bool, len(ids))
+	if hook := v.config.AfterDelete; hook != nil {
+		defer func() {
+			for i, id := range ids {
+				opErr := errs[i]
+				if opErr == nil && !attempted[i] {
+					opErr = ErrBatchAborted
+				}
+				hook(id, opErr)
+			}
+		}()
+	}
+
+	if hook := v.config.BeforeDelete; hook != nil {
+		for i, id := range ids {
+			if hookErr := hook(id); hookErr != nil {
+				errs[i] = fmt.Errorf("%w: %w", ErrRejected, hookErr)
+				if err := result.fail(opts, ids, i, errs[i]); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return result, fmt.Errorf("delete batch: %w", err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return result, fmt.Errorf("delete batch: %w", err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic("delete batch", &err)
+
+	for i, id := range ids {
+		if errs[i] != nil {
+			continue // Rejected by BeforeDelete
+		}
+		attempted[i] = true
+		if errs[i] = v.deleteLocked(id); errs[i] != nil {
+			if err := result.fail(opts, ids, i, errs[i]); err != nil {
+				return result, err
+			}
+			continue
+		}
+		result.Applied++
+	}
+	sort.SliceStable(result.Failures, func(i, j int) bool { return result.Failures[i].Index < result.Failures[j].Index })
+	return result, nil
+}
+
+// bulkLoadChunk is how many vectors InsertBatch indexes between progress
+// reports when Config.Progress is set
+const bulkLoadChunk = 4096
+
+// indexBatch inserts the vectors into the index, in one storage append if
+// the index supports it, or one per bulkLoadChunk vectors with Config.Progress
+// Note: Assumes write lock is already held
+func (v *VecLite) indexBatch(ids []uint64, vectors [][]float32, tracker *progressTracker) error {
+	chunk := len(ids)
+	if v.config.Progress != nil {
+		chunk = bulkLoadChunk
+	}
+	total := int64(len(ids))
+	tracker.report("index", 0, total, "vectors")
+	for start := 0; start < len(ids); start += chunk {
+		end := min(start+chunk, len(ids))
+		if inserter, ok := v.index.(index.BatchInserter); ok {
+			if err := inserter.InsertBatch(ids[start:end], vectors[start:end]); err != nil {
+				return err
+			}
+		} else {
+			for i := start; i < end; i++ {
+				if err := v.index.Insert(ids[i], vectors[i]); err != nil {
+					return err
+				}
+			}
+		}
+		tracker.report("index", int64(end), total, "vectors")
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package veclite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVecLite_Apply(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		for i := uint64(1); i <= 3; i++ {
+			if err := db.Insert(i, make([]float32, 128)); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+
+		vector := make([]float32, 128)
+		vector[0] = 1
+		batch := &Batch{}
+		batch.InsertWithMetadata(4, vector, Metadata{"tag": "new"})
+		batch.Insert(5, vector)
+		batch.Delete(2)
+		if batch.Len() != 3 {
+			t.Fatalf("Expected 3 operations, got %d", batch.Len())
+		}
+		if err := db.Apply(batch); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+
+		if db.Size() != 4 {
+			t.Errorf("Expected size 4, got %d", db.Size())
+		}
+		if _, err := db.Get(2); err == nil {
+			t.Error("Expected ID 2 to be deleted")
+		}
+		if got, err := db.Get(5); err != nil || got[0] != 1 {
+			t.Errorf("Expected ID 5 to be inserted, got %v, %v", got, err)
+		}
+		if metadata, _ := db.GetMetadata(4); metadata["tag"] != "new" {
+			t.Errorf("Expected metadata of ID 4, got %v", metadata)
+		}
+	})
+}
+
+func TestVecLite_ApplyValidatesFirst(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	errBlocked := errors.New("blocked")
+	var afterInserts, afterDeletes []error
+	db.config.BeforeDelete = func(id uint64) error {
+		if id == 9 {
+			return errBlocked
+		}
+		return nil
+	}
+	db.config.AfterInsert = func(id uint64, vector []float32, err error) {
+		afterInserts = append(afterInserts, err)
+	}
+	db.config.AfterDelete = func(id uint64, err error) {
+		afterDeletes = append(afterDeletes, err)
+	}
+
+	// A rejected operation stops the batch before anything is applied
+	batch := &Batch{}
+	batch.Insert(1, make([]float32, 128))
+	batch.Delete(9)
+	err := db.Apply(batch)
+	if !errors.Is(err, ErrRejected) || !errors.Is(err, errBlocked) {
+		t.Fatalf("Expected rejection, got %v", err)
+	}
+	if db.Size() != 0 {
+		t.Errorf("Expected nothing applied, got size %d", db.Size())
+	}
+	if len(afterInserts) != 1 || !errors.Is(afterInserts[0], ErrBatchAborted) {
+		t.Errorf("Expected aborted insert, got %v", afterInserts)
+	}
+	if len(afterDeletes) != 1 || !errors.Is(afterDeletes[0], ErrRejected) {
+		t.Errorf("Expected rejected delete, got %v", afterDeletes)
+	}
+
+	// So does a dimension mismatch
+	batch = &Batch{}
+	batch.Insert(1, make([]float32, 128))
+	batch.Insert(2, []float32{1})
+	if err := db.Apply(batch); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+	if db.Size() != 0 {
+		t.Errorf("Expected nothing applied, got size %d", db.Size())
+	}
+
+	// So does an insert of an ID stored by the time the batch reaches it
+	if err := db.Insert(7, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	afterInserts, afterDeletes = nil, nil
+	batch = &Batch{}
+	batch.Insert(1, make([]float32, 128))
+	batch.Delete(7)
+	batch.Insert(7, make([]float32, 128))
+	batch.Insert(1, make([]float32, 128))
+	if err := db.Apply(batch); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if _, err := db.Get(1); err == nil || db.Size() != 1 {
+		t.Errorf("Expected nothing applied, got size %d", db.Size())
+	}
+	if len(afterInserts) != 3 || !errors.Is(afterInserts[0], ErrBatchAborted) || !errors.Is(afterInserts[2], ErrAlreadyExists) {
+		t.Errorf("Expected two aborted inserts and a rejected one, got %v", afterInserts)
+	}
+
+	if err := db.Apply(&Batch{}); err != nil {
+		t.Errorf("Expected empty batch to succeed, got %v", err)
+	}
+}
+
+func TestVecLite_InsertBatch(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		if err := db.InsertWithMetadata(1, make([]float32, 128), Metadata{"tag": "kept"}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		ids := make([]uint64, 40)
+		vectors := make([][]float32, 40)
+		for i := range ids {
+			ids[i] = uint64(i + 2)
+			vectors[i] = testVector(i + 2)
+		}
+		if err := db.InsertBatch(ids, vectors); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		if db.Size() != 41 {
+			t.Errorf("Expected size 41, got %d", db.Size())
+		}
+		results, err := db.Search(vectors[25], 1)
+		if err != nil || len(results) != 1 || results[0].ID != 27 {
+			t.Errorf("Expected ID 27 to be nearest, got %v (%v)", results, err)
+		}
+
+		// Stored or repeated IDs are rejected before anything is written
+		if err := db.InsertBatch([]uint64{100, 1}, [][]float32{testVector(100), testVector(1)}); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists for a stored ID, got %v", err)
+		}
+		if err := db.InsertBatch([]uint64{100, 100}, [][]float32{testVector(100), testVector(100)}); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists for a repeated ID, got %v", err)
+		}
+		if db.Size() != 41 {
+			t.Errorf("Expected rejected batches to write nothing, got size %d", db.Size())
+		}
+
+		// Upserts keep the metadata
+		var batch Batch
+		batch.Upsert(1, testVector(1))
+		if err := db.Apply(&batch); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		if got, err := db.Get(1); err != nil || got[0] != 1 {
+			t.Errorf("Expected ID 1 to be updated, got %v, %v", got, err)
+		}
+		if metadata, _ := db.GetMetadata(1); metadata["tag"] != "kept" {
+			t.Errorf("Expected metadata of ID 1 to be kept, got %v", metadata)
+		}
+	})
+}
+
+func TestVecLite_InsertBatchValidatesFirst(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	var afterInserts []error
+	db.config.AfterInsert = func(id uint64, vector []float32, err error) {
+		afterInserts = append(afterInserts, err)
+	}
+
+	err := db.InsertBatch([]uint64{1, 2, 3}, [][]float32{make([]float32, 128), {1}, make([]float32, 128)})
+	if err == nil {
+		t.Fatal("Expected dimension mismatch error")
+	}
+	if db.Size() != 0 {
+		t.Errorf("Expected nothing inserted, got size %d", db.Size())
+	}
+	if len(afterInserts) != 3 || !errors.Is(afterInserts[0], ErrBatchAborted) || afterInserts[1] == nil || errors.Is(afterInserts[1], ErrBatchAborted) {
+		t.Errorf("Expected the mismatch and two aborted inserts, got %v", afterInserts)
+	}
+
+	if err := db.InsertBatch([]uint64{1}, nil); err == nil {
+		t.Error("Expected an error for mismatched IDs and vectors")
+	}
+	if err := db.InsertBatch(nil, nil); err != nil {
+		t.Errorf("Expected an empty batch to succeed, got %v", err)
+	}
+}
+
+func TestVecLite_InsertBatchContinueOnError(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		if err := db.Insert(1, testVector(1)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		var afterInserts []error
+		db.config.AfterInsert = func(id uint64, vector []float32, err error) {
+			afterInserts = append(afterInserts, err)
+		}
+
+		ids := []uint64{2, 1, 3, 4, 3}
+		vectors := [][]float32{testVector(2), testVector(1), {1}, testVector(4), testVector(3)}
+		result, err := db.InsertBatchWithOptions(ids, vectors, BatchOptions{ContinueOnError: true})
+		if err != nil {
+			t.Fatalf("InsertBatchWithOptions failed: %v", err)
+		}
+		if result.Applied != 3 || len(result.Failures) != 2 {
+			t.Fatalf("Expected 3 applied and 2 failures, got %+v", result)
+		}
+		if f := result.Failures[0]; f.Index != 1 || f.ID != 1 || !errors.Is(f.Err, ErrAlreadyExists) {
+			t.Errorf("Expected the stored ID 1 to fail, got %+v", f)
+		}
+		if f := result.Failures[1]; f.Index != 2 || f.ID != 3 || f.Err == nil {
+			t.Errorf("Expected the dimension mismatch of item 2 to fail, got %+v", f)
+		}
+		if result.Err() == nil {
+			t.Error("Expected Err to report the failures")
+		}
+		if db.Size() != 4 {
+			t.Errorf("Expected size 4, got %d", db.Size())
+		}
+		// The valid copy of ID 3 later in the batch is inserted
+		if vec, err := db.Get(3); err != nil || vec[0] != testVector(3)[0] {
+			t.Errorf("Expected ID 3 to be inserted, got %v (%v)", vec, err)
+		}
+		if len(afterInserts) != 5 || afterInserts[0] != nil || afterInserts[1] == nil || afterInserts[2] == nil || afterInserts[4] != nil {
+			t.Errorf("Expected AfterInsert to see the per-item outcome, got %v", afterInserts)
+		}
+
+		// Without ContinueOnError the first failure aborts the batch
+		result, err = db.InsertBatchWithOptions([]uint64{10, 1}, [][]float32{testVector(10), testVector(1)}, BatchOptions{})
+		if !errors.Is(err, ErrAlreadyExists) || result.Applied != 0 || len(result.Failures) != 1 {
+			t.Errorf("Expected the batch to abort on ID 1, got %+v (%v)", result, err)
+		}
+		if db.Size() != 4 {
+			t.Errorf("Expected an aborted batch to write nothing, got size %d", db.Size())
+		}
+	})
+}
+
+func TestVecLite_DeleteBatch(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+	insertTestVectors(t, db, 10)
+
+	db.config.BeforeDelete = func(id uint64) error {
+		if id == 3 {
+			return errors.New("protected")
+		}
+		return nil
+	}
+	var afterDeletes []error
+	db.config.AfterDelete = func(id uint64, err error) {
+		afterDeletes = append(afterDeletes, err)
+	}
+
+	result, err := db.DeleteBatch([]uint64{1, 3, 5}, BatchOptions{ContinueOnError: true})
+	if err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if result.Applied != 2 || len(result.Failures) != 1 || result.Failures[0].ID != 3 || !errors.Is(result.Failures[0].Err, ErrRejected) {
+		t.Errorf("Expected ID 3 to be rejected and the others deleted, got %+v", result)
+	}
+	if db.Size() != 8 {
+		t.Errorf("Expected size 8, got %d", db.Size())
+	}
+	if len(afterDeletes) != 3 || afterDeletes[0] != nil || !errors.Is(afterDeletes[1], ErrRejected) || afterDeletes[2] != nil {
+		t.Errorf("Expected AfterDelete to see the per-item outcome, got %v", afterDeletes)
+	}
+
+	afterDeletes = nil
+	result, err = db.DeleteBatch([]uint64{6, 3, 7}, BatchOptions{})
+	if !errors.Is(err, ErrRejected) || result.Applied != 0 {
+		t.Errorf("Expected the batch to abort on ID 3, got %+v (%v)", result, err)
+	}
+	if db.Size() != 8 {
+		t.Errorf("Expected an aborted batch to delete nothing, got size %d", db.Size())
+	}
+	if len(afterDeletes) != 3 || !errors.Is(afterDeletes[0], ErrBatchAborted) || !errors.Is(afterDeletes[2], ErrBatchAborted) {
+		t.Errorf("Expected the other deletes to be aborted, got %v", afterDeletes)
+	}
+
+	// A failing delete is skipped like a rejected one; a closed audit log
+	// fails every delete after it is applied
+	db.config.BeforeDelete = nil
+	afterDeletes = nil
+	db.audit = &auditLog{log: &rotatingLog{name: "audit log"}}
+	result, err = db.DeleteBatch([]uint64{2, 4}, BatchOptions{ContinueOnError: true})
+	if err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if result.Applied != 0 || len(result.Failures) != 2 || result.Failures[1].ID != 4 {
+		t.Errorf("Expected both deletes to be listed as failures, got %+v", result)
+	}
+	if len(afterDeletes) != 2 || afterDeletes[0] == nil || afterDeletes[1] == nil {
+		t.Errorf("Expected AfterDelete to see both failures, got %v", afterDeletes)
+	}
+	result, err = db.DeleteBatch([]uint64{6, 7}, BatchOptions{})
+	if err == nil || result.Applied != 0 || len(result.Failures) != 1 {
+		t.Errorf("Expected the batch to abort on ID 6, got %+v (%v)", result, err)
+	}
+	db.audit = nil
+}
+
+func TestVecLite_FilterIDs(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	insertTagged(t, db, 30)
+
+	ids, err := db.FilterIDs(Filter{"rare": true})
+	if err != nil {
+		t.Fatalf("FilterIDs failed: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != 10 || ids[1] != 20 || ids[2] != 30 {
+		t.Errorf("Expected IDs 10, 20, 30, got %v", ids)
+	}
+	if _, err := db.FilterIDs(nil); err == nil {
+		t.Error("Expected error for empty filter")
+	}
+}
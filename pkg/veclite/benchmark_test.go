@@ -113,16 +113,6 @@ func generateClusteredVector(dimension, clusterID, numClusters int, seed int64)
 	return vector
 }
 
-// loadVectorsFromFile loads vectors from a binary file (optional helper)
-// Format: [numVectors uint32][dimension uint32][vector1...][vector2...]
-// Each vector is dimension * float32 values
-// This is useful for loading pre-computed embeddings
-func loadVectorsFromFile(filename string) ([][]float32, error) {
-	// This is a placeholder - implement based on your file format
-	// Example formats: binary, CSV, JSON, etc.
-	return nil, nil
-}
-
 // BenchmarkInsert_Flat benchmarks insert performance for flat index
 func BenchmarkInsert_Flat(b *testing.B) {
 	db, cleanup := createBenchmarkDB(b, "flat")
@@ -0,0 +1,182 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnhealthy is wrapped by errors returned from Health
+var ErrUnhealthy = errors.New("database is unhealthy")
+
+// Canary is a query with a known nearest neighbor
+// Canaries catch silent recall regressions after compaction, deletes or
+// parameter changes
+type Canary struct {
+	Name       string
+	Query      []float32
+	ExpectedID uint64 // Expected top-1 result
+}
+
+// CanaryReport is the result of one canary run
+type CanaryReport struct {
+	Ran      time.Time
+	Passed   int
+	Failures []CanaryFailure
+}
+
+// CanaryFailure describes a canary whose top-1 result was not the expected ID
+type CanaryFailure struct {
+	Name       string
+	ExpectedID uint64
+	GotID      uint64 // Actual top-1 result (0 if Err is set or there were no results)
+	Err        string // Search error, if any
+}
+
+// canarySuite holds the registered canaries and the last report
+type canarySuite struct {
+	mu       sync.Mutex // Guards the fields below
+	canaries map[string]Canary
+	last     *CanaryReport
+
+	stop chan struct{} // Background runner (nil = on demand only)
+	done chan struct{}
+}
+
+// AddCanary registers a canary, replacing any canary with the same name
+func (v *VecLite) AddCanary(canary Canary) error {
+	if canary.Name == "" {
+		return errors.New("canary name must not be empty")
+	}
+	if len(canary.Query) != v.inputDim {
+		return fmt.Errorf("canary query dimension %d does not match configured dimension %d", len(canary.Query), v.inputDim)
+	}
+	canary.Query = append([]float32(nil), v.preprocess(canary.Query)...) // Canaries search the index directly
+
+	v.canaries.mu.Lock()
+	defer v.canaries.mu.Unlock()
+	if v.canaries.canaries == nil {
+		v.canaries.canaries = make(map[string]Canary)
+	}
+	v.canaries.canaries[canary.Name] = canary
+	return nil
+}
+
+// RemoveCanary unregisters the canary with the given name
+func (v *VecLite) RemoveCanary(name string) {
+	v.canaries.mu.Lock()
+	defer v.canaries.mu.Unlock()
+	delete(v.canaries.canaries, name)
+}
+
+// RunCanaries runs every registered canary now and records the report for
+// Stats and Health
+// Uses read lock - allows concurrent reads
+func (v *VecLite) RunCanaries() *CanaryReport {
+	v.canaries.mu.Lock()
+	canaries := make([]Canary, 0, len(v.canaries.canaries))
+	for _, canary := range v.canaries.canaries {
+		canaries = append(canaries, canary)
+	}
+	v.canaries.mu.Unlock()
+	sort.Slice(canaries, func(i, j int) bool { return canaries[i].Name < canaries[j].Name })
+
+	report := &CanaryReport{}
+	v.mu.RLock()
+	for _, canary := range canaries {
+		// Query the index directly so canaries don't trigger hooks or count as query drift
+		results, err := v.index.SearchContext(context.Background(), canary.Query, 1)
+		switch {
+		case err != nil:
+			report.Failures = append(report.Failures, CanaryFailure{Name: canary.Name, ExpectedID: canary.ExpectedID, Err: err.Error()})
+		case len(results) == 0:
+			report.Failures = append(report.Failures, CanaryFailure{Name: canary.Name, ExpectedID: canary.ExpectedID})
+		case results[0].ID != canary.ExpectedID:
+			report.Failures = append(report.Failures, CanaryFailure{Name: canary.Name, ExpectedID: canary.ExpectedID, GotID: results[0].ID})
+		default:
+			report.Passed++
+		}
+	}
+	v.mu.RUnlock()
+	report.Ran = time.Now()
+
+	v.canaries.mu.Lock()
+	v.canaries.last = report
+	v.canaries.mu.Unlock()
+	return report
+}
+
+// lastCanaryReport returns the report of the last canary run (nil = never ran)
+func (v *VecLite) lastCanaryReport() *CanaryReport {
+	v.canaries.mu.Lock()
+	defer v.canaries.mu.Unlock()
+	return v.canaries.last
+}
+
+// startCanaries runs the canaries every interval in the background
+func (v *VecLite) startCanaries(interval time.Duration) {
+	v.canaries.stop = make(chan struct{})
+	v.canaries.done = make(chan struct{})
+	go func() {
+		defer v.label(context.Background(), "canary")()
+		defer close(v.canaries.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-v.canaries.stop:
+				return
+			case <-ticker.C:
+				v.RunCanaries()
+			}
+		}
+	}()
+}
+
+// stopCanaries stops the background runner and waits for a running check to finish
+// Must be called without holding the lock
+func (v *VecLite) stopCanaries() {
+	if v.canaries.stop == nil {
+		return
+	}
+	select {
+	case <-v.canaries.stop:
+	default:
+		close(v.canaries.stop)
+	}
+	<-v.canaries.done
+}
+
+// Health returns nil if the database is serving normally, or an error wrapping
+// ErrUnhealthy that lists what is wrong: degraded mode, a failed rebuild or
+// maintenance run, or failing canaries in the last canary run
+func (v *VecLite) Health() error {
+	stats := v.Stats()
+
+	var problems []error
+	if stats.Degraded {
+		problems = append(problems, fmt.Errorf("degraded: %s", stats.DegradedReason))
+	}
+	if stats.RebuildError != "" {
+		problems = append(problems, fmt.Errorf("rebuild failed: %s", stats.RebuildError))
+	}
+	if stats.MaintenanceError != "" {
+		problems = append(problems, fmt.Errorf("maintenance failed: %s", stats.MaintenanceError))
+	}
+	if report := stats.Canaries; report != nil {
+		for _, failure := range report.Failures {
+			if failure.Err != "" {
+				problems = append(problems, fmt.Errorf("canary %q failed: %s", failure.Name, failure.Err))
+			} else {
+				problems = append(problems, fmt.Errorf("canary %q: expected top-1 ID %d, got %d", failure.Name, failure.ExpectedID, failure.GotID))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrUnhealthy, errors.Join(problems...))
+}
@@ -0,0 +1,96 @@
+package veclite
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVecLite_Canaries(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 3; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.AddCanary(Canary{Name: "bad", Query: make([]float32, 3)}); err == nil {
+		t.Error("Expected error for canary with wrong dimension")
+	}
+
+	query := make([]float32, 128)
+	query[0] = 2
+	if err := db.AddCanary(Canary{Name: "two", Query: query, ExpectedID: 2}); err != nil {
+		t.Fatalf("AddCanary failed: %v", err)
+	}
+	if db.Stats().Canaries != nil {
+		t.Error("Expected no canary report before the first run")
+	}
+
+	report := db.RunCanaries()
+	if report.Passed != 1 || len(report.Failures) != 0 {
+		t.Errorf("Expected canary to pass, got %+v", report)
+	}
+	if err := db.Health(); err != nil {
+		t.Errorf("Expected healthy database, got %v", err)
+	}
+
+	// Deleting the expected neighbor is a recall regression
+	if err := db.Delete(2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	report = db.RunCanaries()
+	if report.Passed != 0 || len(report.Failures) != 1 || report.Failures[0].ExpectedID != 2 {
+		t.Fatalf("Expected canary failure, got %+v", report)
+	}
+	if got := report.Failures[0].GotID; got != 1 && got != 3 {
+		t.Errorf("Expected top-1 of 1 or 3, got %d", got)
+	}
+	if stats := db.Stats(); stats.Canaries != report {
+		t.Error("Expected Stats to expose the last canary report")
+	}
+	if err := db.Health(); !errors.Is(err, ErrUnhealthy) {
+		t.Errorf("Expected ErrUnhealthy, got %v", err)
+	}
+
+	db.RemoveCanary("two")
+	if report := db.RunCanaries(); report.Passed != 0 || len(report.Failures) != 0 {
+		t.Errorf("Expected empty report after RemoveCanary, got %+v", report)
+	}
+}
+
+func TestVecLite_Canaries_Background(t *testing.T) {
+	tmpFile := t.TempDir() + "/canary.db"
+	config := DefaultConfig()
+	config.DataPath = tmpFile
+	config.Dimension = 4
+	config.CanaryInterval = 5 * time.Millisecond
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	if err := db.Insert(1, []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.AddCanary(Canary{Name: "one", Query: []float32{1, 0, 0, 0}, ExpectedID: 1}); err != nil {
+		t.Fatalf("AddCanary failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for db.Stats().Canaries == nil || db.Stats().Canaries.Passed != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Background canary run did not happen")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/profile"
+)
+
+// Candidate is one vector of the candidate stream of a search, with the
+// distance the index ranked it by (approximate for HNSW and IVF candidates
+// outside the final top k, exact for Flat)
+type Candidate struct {
+	ID       uint64
+	Key      string // String ID the vector was inserted under ("" for numeric IDs)
+	Distance float32
+}
+
+// CandidateIterator yields the candidates of a search nearest first, before
+// they are truncated to k, so callers can implement multi-stage ranking (e.g.
+// a cross-encoder rerank) and read only the vectors they need
+// The candidates are collected when the iterator is created; Vector and
+// Metadata read the current values, so a candidate deleted since returns ErrNotFound
+type CandidateIterator struct {
+	db         *VecLite
+	candidates []Candidate
+	pos        int // Position of the current candidate plus one
+}
+
+// Candidates returns an iterator over up to n candidates of a search for
+// query: HNSW yields the nearest nodes found with max(EfSearch, n) candidates,
+// IVF every vector of the NProbe nearest clusters, Flat every vector (n <= 0 =
+// every candidate the index visited)
+// Only opts.Filter, opts.SeedID and opts.TraceID apply; candidates not matching the filter
+// are dropped, so fewer than n may be returned
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) Candidates(query []float32, n int, opts SearchOptions) (*CandidateIterator, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.CandidatesContext(ctx, query, n, opts)
+}
+
+// CandidatesContext is Candidates with a context, see SearchContext
+func (v *VecLite) CandidatesContext(ctx context.Context, query []float32, n int, opts SearchOptions) (*CandidateIterator, error) {
+	defer v.label(ctx, "candidates", LabelTrace, opts.TraceID)()
+	if err := v.validate("query", query); err != nil {
+		return nil, err
+	}
+	if err := opts.Filter.validate(); err != nil {
+		return nil, err
+	}
+
+	results, err := v.candidates(opts.indexContext(ctx), v.preprocess(query), n, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	it := &CandidateIterator{db: v, candidates: make([]Candidate, len(results))}
+	for i, result := range results {
+		it.candidates[i] = Candidate{ID: result.ID, Key: result.Key, Distance: result.Distance}
+	}
+	return it, nil
+}
+
+// candidates collects the candidates of the serving index and the cold tier
+// Uses read lock - allows concurrent reads
+func (v *VecLite) candidates(ctx context.Context, query []float32, n int, filter Filter) (results []SearchResult, err error) {
+	p := v.profiler.begin(v.config.ProfileSampleRate, false)
+	defer v.profiler.end(p)
+	if err := v.rLockContext(ctx); err != nil {
+		return nil, fmt.Errorf("candidates: %w", err)
+	}
+	defer v.mu.RUnlock()
+	defer v.recoverPanic("candidates", &err)
+
+	ctx = profile.NewContext(ctx, p)
+	if searcher, ok := v.index.(index.CandidateSearcher); ok {
+		results, err = searcher.Candidates(ctx, query, n)
+	} else if k := v.index.Size(); k > 0 {
+		if n > 0 {
+			k = n
+		}
+		results, err = v.index.SearchContext(ctx, query, k)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("candidates: %w", err)
+		}
+		return nil, err
+	}
+
+	var keep func(id uint64) bool
+	if len(filter) > 0 {
+		keep = func(id uint64) bool { return v.metadata.matches(id, filter) }
+		matching := results[:0]
+		for _, result := range results {
+			if keep(result.ID) {
+				matching = append(matching, result)
+			}
+		}
+		results = matching
+	}
+	if cold := v.tiers.size(); cold > 0 {
+		limit := n
+		if limit <= 0 {
+			limit = len(results) + cold
+		}
+		found, err := v.tiers.search(ctx, query, limit, keep)
+		if err != nil {
+			return nil, err
+		}
+		results = mergeResults(results, found, limit)
+	}
+	v.keys.attach(results)
+	return results, nil
+}
+
+// Next advances to the next candidate, reporting false once all were yielded
+func (it *CandidateIterator) Next() bool {
+	if it.pos >= len(it.candidates) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// Candidate returns the current candidate
+func (it *CandidateIterator) Candidate() Candidate {
+	if it.pos == 0 {
+		return Candidate{}
+	}
+	return it.candidates[it.pos-1]
+}
+
+// Len returns the number of candidates not yet yielded by Next
+func (it *CandidateIterator) Len() int {
+	return len(it.candidates) - it.pos
+}
+
+// Vector reads the vector of the current candidate
+func (it *CandidateIterator) Vector() ([]float32, error) {
+	if it.pos == 0 {
+		return nil, errors.New("no current candidate: call Next first")
+	}
+	return it.db.get(it.candidates[it.pos-1].ID)
+}
+
+// Metadata returns a copy of the metadata of the current candidate (nil if it has none)
+func (it *CandidateIterator) Metadata() Metadata {
+	if it.pos == 0 {
+		return nil
+	}
+	metadata, _ := it.db.metadata.Get(it.candidates[it.pos-1].ID, nil)
+	return metadata
+}
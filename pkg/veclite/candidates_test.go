@@ -0,0 +1,84 @@
+package veclite
+
+import (
+	"testing"
+)
+
+func TestVecLite_Candidates(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		for i := 1; i <= 60; i++ {
+			vec := make([]float32, 128)
+			vec[0] = float32(i)
+			metadata := Metadata{"even": i%2 == 0}
+			if err := db.InsertWithMetadata(uint64(i), vec, metadata); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		query := make([]float32, 128)
+		query[0] = 30.3 // Avoids distance ties
+
+		results, err := db.Search(query, 5)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		it, err := db.Candidates(query, 20, SearchOptions{})
+		if err != nil {
+			t.Fatalf("Candidates failed: %v", err)
+		}
+		if it.Len() < len(results) {
+			t.Fatalf("Expected at least %d candidates, got %d", len(results), it.Len())
+		}
+		var last float32
+		for i := 0; it.Next(); i++ {
+			c := it.Candidate()
+			if c.Distance < last {
+				t.Fatalf("Candidates not sorted at %d", i)
+			}
+			last = c.Distance
+			if i < len(results) && c.ID != results[i].ID {
+				t.Errorf("Expected candidate %d to be search result %d, got %d", i, results[i].ID, c.ID)
+			}
+			if i == 0 {
+				vec, err := it.Vector()
+				if err != nil || vec[0] != float32(c.ID) {
+					t.Errorf("Expected the vector of candidate %d, got %v (%v)", c.ID, vec, err)
+				}
+				if it.Metadata()["even"] != (c.ID%2 == 0) {
+					t.Errorf("Expected the metadata of candidate %d", c.ID)
+				}
+			}
+		}
+		if it.Len() != 0 || it.Next() {
+			t.Error("Expected the iterator to be exhausted")
+		}
+
+		filtered, err := db.Candidates(query, 0, SearchOptions{Filter: Filter{"even": true}})
+		if err != nil {
+			t.Fatalf("Candidates failed: %v", err)
+		}
+		for filtered.Next() {
+			if filtered.Candidate().ID%2 != 0 {
+				t.Errorf("Candidate %d does not match the filter", filtered.Candidate().ID)
+			}
+		}
+	})
+}
+
+func TestVecLite_Candidates_InvalidQuery(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if _, err := db.Candidates([]float32{1, 2}, 10, SearchOptions{}); err == nil {
+		t.Error("Expected dimension mismatch")
+	}
+	it, err := db.Candidates(make([]float32, 128), 10, SearchOptions{})
+	if err != nil || it.Len() != 0 {
+		t.Errorf("Expected no candidates in an empty database, got %v", err)
+	}
+	if _, err := it.Vector(); err == nil {
+		t.Error("Expected an error reading a vector before Next")
+	}
+}
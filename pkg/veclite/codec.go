@@ -0,0 +1,93 @@
+package veclite
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Codec compresses a byte stream, e.g. a Pack archive
+// Register codecs with RegisterCodec to select them by name in PackOptions.Codec;
+// archives written with a codec can only be unpacked where it is registered
+type Codec interface {
+	// NewWriter returns a writer compressing to w; Close flushes the stream
+	// but must not close w
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// CodecGzip is the name of the built-in gzip codec
+const CodecGzip = "gzip"
+
+// maxCodecName is the longest codec name, so it fits the length byte of the
+// Pack codec header
+const maxCodecName = 255
+
+// codecs is the codec registry, by name
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+}{byName: map[string]Codec{CodecGzip: gzipCodec{}}}
+
+// RegisterCodec makes codec available under name, e.g. RegisterCodec("lz4", codec)
+// with a codec wrapping an LZ4 library, so Pack can use it and Unpack can read
+// archives written with it
+// Panics if name is empty or too long, codec is nil, or name is already registered,
+// like database/sql.Register; call it from an init function
+func RegisterCodec(name string, codec Codec) {
+	if name == "" || len(name) > maxCodecName {
+		panic(fmt.Sprintf("veclite: invalid codec name %q", name))
+	}
+	if codec == nil {
+		panic("veclite: RegisterCodec codec is nil")
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	if _, exists := codecs.byName[name]; exists {
+		panic(fmt.Sprintf("veclite: codec %q registered twice", name))
+	}
+	codecs.byName[name] = codec
+}
+
+// Codecs returns the names of the registered codecs, sorted
+func Codecs() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecNames()
+}
+
+// codecNames returns the names of the registered codecs, sorted
+// Note: Assumes the registry lock is already held
+func codecNames() []string {
+	names := make([]string, 0, len(codecs.byName))
+	for name := range codecs.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupCodec returns the codec registered under name
+func lookupCodec(name string) (Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	codec, ok := codecs.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q (registered: %v)", name, codecNames())
+	}
+	return codec, nil
+}
+
+// gzipCodec is the built-in gzip codec (compress/gzip, default level)
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
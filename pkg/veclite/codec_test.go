@@ -0,0 +1,86 @@
+package veclite
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// flateCodec is a codec registered by the tests, standing in for a third-party one
+type flateCodec struct{}
+
+func (flateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestSpeed)
+}
+
+func (flateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func init() {
+	RegisterCodec("test-flate", flateCodec{})
+}
+
+func TestRegisterCodec(t *testing.T) {
+	if names := Codecs(); !slices.Contains(names, CodecGzip) || !slices.Contains(names, "test-flate") {
+		t.Errorf("Expected gzip and test-flate to be registered, got %v", names)
+	}
+	for name, codec := range map[string]Codec{"": flateCodec{}, "gzip": flateCodec{}, "nil": nil, strings.Repeat("x", 256): flateCodec{}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterCodec(%.10q) to panic", name)
+				}
+			}()
+			RegisterCodec(name, codec)
+		}()
+	}
+}
+
+func TestVecLite_PackCodec(t *testing.T) {
+	db := createPackTestDB(t)
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "snapshot.vlpack")
+	if err := db.Pack(archivePath, PackOptions{Codec: "lz4"}); err == nil || !strings.Contains(err.Error(), `unknown codec "lz4"`) {
+		t.Errorf("Expected an unknown codec to be rejected, got %v", err)
+	}
+	if err := db.Pack(archivePath, PackOptions{Codec: "test-flate"}); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte(packCodecMagic+"\x0atest-flate")) {
+		t.Fatalf("Expected the archive to name its codec, got %q", data[:16])
+	}
+
+	config, err := Unpack(archivePath, filepath.Join(dir, "restored.db"))
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	restored, err := New(config)
+	if err != nil {
+		t.Fatalf("Opening unpacked database failed: %v", err)
+	}
+	defer restored.Close()
+	if restored.Size() != 31 {
+		t.Errorf("Expected 31 vectors, got %d", restored.Size())
+	}
+
+	// An archive written with a codec that is not registered here
+	unknown := filepath.Join(dir, "unknown.vlpack")
+	if err := os.WriteFile(unknown, append([]byte(packCodecMagic+"\x03zst"), data[15:]...), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := ReadPackManifest(unknown); err == nil || !strings.Contains(err.Error(), `unknown codec "zst"`) {
+		t.Errorf("Expected the unregistered codec to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,552 @@
+package veclite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// ErrCollectionNotFound is returned for collections that are not open
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// ErrCollectionConfig is returned by Collections.Open for a collection recorded
+// with another configuration
+var ErrCollectionConfig = errors.New("collection configuration differs from the recorded one")
+
+// Collections is a set of named databases stored in one directory
+// Every collection is its own VecLite with its own lock, so a bulk import into
+// one collection never blocks searches in another; the registry lock is only
+// held to look up, open or close handles, never during operations
+// The dimension, index type, index, file layout and search parameters of every
+// collection opened are recorded in <dir>/collections.json, so Reopen opens it after a restart;
+// aliases pointing at collections are recorded next to it (see SwapAlias)
+type Collections struct {
+	dir    string
+	prefix string // Prepended to collection names in pprof labels and expvar
+
+	mu      sync.RWMutex // Guards the map and the manifest only
+	handles map[string]*VecLite
+	specs   map[string]collectionSpec  // Collections in the manifest (nil = not loaded yet)
+	aliases map[string]collectionAlias // Aliases in the alias manifest (nil = not loaded yet)
+}
+
+// NewCollections creates a registry for collections stored in dir
+func NewCollections(dir string) *Collections {
+	return &Collections{dir: dir, handles: make(map[string]*VecLite)}
+}
+
+// Open opens (or creates) the collection name with config and returns its handle
+// config.DataPath is ignored: the data file is <dir>/<name>.db
+// Opening a collection that is already open returns the existing handle. A
+// recorded collection must be opened with the configuration it was recorded
+// with: another dimension fails with ErrDimensionMismatch, another index type,
+// index, file layout or search parameter with ErrCollectionConfig
+func (c *Collections) Open(name string, config *Config) (*VecLite, error) {
+	if err := checkCollectionName(name); err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadManifest(); err != nil {
+		return nil, err
+	}
+	if spec, ok := c.specs[name]; ok {
+		if spec.Dimension != config.Dimension {
+			return nil, fmt.Errorf("collection %q has dimension %d: %w", name, spec.Dimension, ErrDimensionMismatch)
+		}
+		if field, recorded, got := spec.diff(newCollectionSpec(config)); field != "" {
+			return nil, fmt.Errorf("%w: collection %q has %s %v, got %v", ErrCollectionConfig, name, field, recorded, got)
+		}
+	}
+	if db, ok := c.handles[name]; ok {
+		return db, nil
+	}
+
+	db, err := c.open(name, config)
+	if err != nil {
+		return nil, err
+	}
+	old, existed := c.specs[name]
+	c.specs[name] = newCollectionSpec(config)
+	if err := c.saveManifest(); err != nil {
+		if existed {
+			c.specs[name] = old
+		} else {
+			delete(c.specs, name)
+		}
+		delete(c.handles, name)
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Reopen returns the handle of the collection name, opening it on first use
+// with the default configuration and the dimension, index type, index, file
+// layout and search parameters recorded by Open
+// It fails with ErrCollectionNotFound if the collection was never opened, and
+// for collections opened with a Pipeline, which only Open can supply
+func (c *Collections) Reopen(name string) (*VecLite, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadManifest(); err != nil {
+		return nil, err
+	}
+	if db, ok := c.handles[name]; ok {
+		return db, nil
+	}
+	spec, ok := c.specs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	if spec.Pipeline {
+		return nil, fmt.Errorf("collection %q has a Pipeline the manifest can't record: open it with Open", name)
+	}
+	return c.open(name, spec.config())
+}
+
+// open opens the collection name with config
+// Note: Assumes mu is already held
+func (c *Collections) open(name string, config *Config) (*VecLite, error) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create collection directory: %w", err)
+	}
+	collectionConfig := *config // Don't alias the caller's config across collections
+	collectionConfig.DataPath = filepath.Join(c.dir, name+".db")
+	collectionConfig.Name = c.prefix + name
+	db, err := New(&collectionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open collection %q: %w", name, err)
+	}
+	c.handles[name] = db
+	return db, nil
+}
+
+// Get returns the handle of an open collection
+func (c *Collections) Get(name string) (*VecLite, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	db, ok := c.handles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	return db, nil
+}
+
+// Names returns the open collections in sorted order
+func (c *Collections) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.handles))
+	for name := range c.handles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Recorded returns the collections recorded by Open, open or not, in sorted order
+func (c *Collections) Recorded() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadManifest(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(c.specs))
+	for name := range c.specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CloseCollection closes one collection and removes its handle
+func (c *Collections) CloseCollection(name string) error {
+	c.mu.Lock()
+	db, ok := c.handles[name]
+	delete(c.handles, name)
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	return db.Close()
+}
+
+// Drop closes the collection name, removes it from the manifest and deletes
+// its data file and sidecars
+// It fails while an alias points at the collection; aliases that could roll
+// back to it lose their previous target
+func (c *Collections) Drop(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadManifest(); err != nil {
+		return err
+	}
+	if _, ok := c.specs[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrCollectionNotFound, name)
+	}
+	if err := c.unlinkAliases(name); err != nil {
+		return err
+	}
+	if db, ok := c.handles[name]; ok {
+		delete(c.handles, name)
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("failed to close collection %q: %w", name, err)
+		}
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to list files of collection %q: %w", name, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if c.fileOf(entry.Name()) == name {
+			files = append(files, entry.Name())
+		}
+	}
+	delete(c.specs, name)
+	if err := c.saveManifest(); err != nil {
+		return err
+	}
+
+	// The manifest no longer lists the collection, so a failure here leaves
+	// at worst orphaned files
+	for _, file := range files {
+		if err := os.RemoveAll(filepath.Join(c.dir, file)); err != nil {
+			return fmt.Errorf("failed to remove collection %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// fileOf returns the collection that the file or directory named file in dir
+// belongs to: <name>.db or a sidecar <name>.db.<suffix> ("" = none)
+// Of recorded collections whose files it could be, as in "a" and "a.db", the
+// longest name wins
+// Note: Assumes mu is already held
+func (c *Collections) fileOf(file string) string {
+	owner := ""
+	for name := range c.specs {
+		if len(name) > len(owner) && (file == name+".db" || strings.HasPrefix(file, name+".db.")) {
+			owner = name
+		}
+	}
+	return owner
+}
+
+// Close closes every open collection
+func (c *Collections) Close() error {
+	c.mu.Lock()
+	handles := c.handles
+	c.handles = make(map[string]*VecLite)
+	c.mu.Unlock()
+
+	var errs []error
+	for name, db := range handles {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close collection %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectionsManifestName is the file in the collection directory recording
+// how to open every collection
+const collectionsManifestName = "collections.json"
+
+// collectionSpec is what the manifest records of a collection: every Config
+// field that shapes its files or its search results
+type collectionSpec struct {
+	Dimension            int     `json:"dimension"`
+	IndexType            string  `json:"index_type"`
+	MaxElements          int     `json:"max_elements,omitempty"`
+	M                    int     `json:"m,omitempty"`
+	EfConstruction       int     `json:"ef_construction,omitempty"`
+	EfSearch             int     `json:"ef_search,omitempty"`
+	EdgeWeights          bool    `json:"edge_weights,omitempty"`
+	EntryPoints          int     `json:"entry_points,omitempty"`
+	PQSubspaces          int     `json:"pq_subspaces,omitempty"`
+	GraphJournal         bool    `json:"graph_journal,omitempty"`
+	NClusters            int     `json:"nclusters,omitempty"`
+	NProbe               int     `json:"nprobe,omitempty"`
+	RerankK              int     `json:"rerank_k,omitempty"`
+	StrictFloat64        bool    `json:"strict_float64,omitempty"`
+	Strict               bool    `json:"strict,omitempty"`
+	LocalityLayout       bool    `json:"locality_layout,omitempty"`
+	RecordAlignment      int     `json:"record_alignment,omitempty"`
+	FlatBlockSize        int     `json:"flat_block_size,omitempty"`
+	Pivots               int     `json:"pivots,omitempty"`
+	PrefilterSelectivity float64 `json:"prefilter_selectivity,omitempty"`
+
+	// Pipeline is set if the collection was opened with a Pipeline, which the
+	// manifest can't record: Reopen refuses it rather than skip the transforms
+	Pipeline bool `json:"pipeline,omitempty"`
+}
+
+// newCollectionSpec returns the spec recording config
+func newCollectionSpec(config *Config) collectionSpec {
+	return collectionSpec{
+		Dimension:            config.Dimension,
+		IndexType:            config.IndexType,
+		MaxElements:          config.MaxElements,
+		M:                    config.M,
+		EfConstruction:       config.EfConstruction,
+		EfSearch:             config.EfSearch,
+		EdgeWeights:          config.EdgeWeights,
+		EntryPoints:          config.EntryPoints,
+		PQSubspaces:          config.PQSubspaces,
+		GraphJournal:         config.GraphJournal,
+		NClusters:            config.NClusters,
+		NProbe:               config.NProbe,
+		RerankK:              config.RerankK,
+		StrictFloat64:        config.StrictFloat64,
+		Strict:               config.Strict,
+		LocalityLayout:       config.LocalityLayout,
+		RecordAlignment:      config.RecordAlignment,
+		FlatBlockSize:        config.FlatBlockSize,
+		Pivots:               config.Pivots,
+		PrefilterSelectivity: config.PrefilterSelectivity,
+		Pipeline:             config.Pipeline != nil,
+	}
+}
+
+// diff returns the JSON name and both values of the first field in which
+// other differs from spec, or "" if they are equal
+func (spec collectionSpec) diff(other collectionSpec) (field string, value, otherValue any) {
+	a, b := reflect.ValueOf(spec), reflect.ValueOf(other)
+	for i := 0; i < a.NumField(); i++ {
+		if a.Field(i).Interface() != b.Field(i).Interface() {
+			name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("json"), ",")
+			return name, a.Field(i).Interface(), b.Field(i).Interface()
+		}
+	}
+	return "", nil, nil
+}
+
+// config returns the default configuration with the recorded parameters
+func (spec collectionSpec) config() *Config {
+	config := DefaultConfig()
+	config.Dimension = spec.Dimension
+	config.IndexType = spec.IndexType
+	if spec.MaxElements > 0 {
+		config.MaxElements = spec.MaxElements
+	}
+	config.M = spec.M
+	config.EfConstruction = spec.EfConstruction
+	config.EfSearch = spec.EfSearch
+	config.EdgeWeights = spec.EdgeWeights
+	config.EntryPoints = spec.EntryPoints
+	config.PQSubspaces = spec.PQSubspaces
+	config.GraphJournal = spec.GraphJournal
+	config.NClusters = spec.NClusters
+	config.NProbe = spec.NProbe
+	config.RerankK = spec.RerankK
+	config.StrictFloat64 = spec.StrictFloat64
+	config.Strict = spec.Strict
+	config.LocalityLayout = spec.LocalityLayout
+	config.RecordAlignment = spec.RecordAlignment
+	config.FlatBlockSize = spec.FlatBlockSize
+	config.Pivots = spec.Pivots
+	config.PrefilterSelectivity = spec.PrefilterSelectivity
+	return config
+}
+
+// collectionManifest is the content of the manifest
+type collectionManifest struct {
+	Collections map[string]collectionSpec `json:"collections"`
+}
+
+// loadManifest reads the manifest, once
+// Note: Assumes mu is already held
+func (c *Collections) loadManifest() error {
+	if c.specs != nil {
+		return nil
+	}
+	var manifest collectionManifest
+	data, err := os.ReadFile(filepath.Join(c.dir, collectionsManifestName))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read collection manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("failed to decode collection manifest: %w", err)
+		}
+	}
+	c.specs = manifest.Collections
+	if c.specs == nil {
+		c.specs = make(map[string]collectionSpec)
+	}
+	return nil
+}
+
+// saveManifest writes the manifest atomically
+// Note: Assumes mu is already held
+func (c *Collections) saveManifest() error {
+	data, err := json.MarshalIndent(collectionManifest{Collections: c.specs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode collection manifest: %w", err)
+	}
+	path := filepath.Join(c.dir, collectionsManifestName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write collection manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace collection manifest: %w", err)
+	}
+	return nil
+}
+
+// checkCollectionName rejects names that can't be used as a file name
+func checkCollectionName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	return nil
+}
+
+// Collections of a database are stored in the directory <DataPath>.collections
+const collectionsSuffix = ".collections"
+
+// newCollections returns the registry of the collections of the database
+func (v *VecLite) newCollections() *Collections {
+	c := NewCollections(v.config.DataPath + collectionsSuffix)
+	c.prefix = v.name + "/"
+	return c
+}
+
+// CreateCollection creates the collection name with its own dimension, index
+// type and files, or opens it with config if it exists, and returns its handle
+// Collections are kept by a Collections registry in <DataPath>.collections/,
+// which records their dimension, index type and index parameters, so
+// OpenCollection reopens them after a restart. config.DataPath is ignored.
+// Opening a collection that is already open returns the existing handle; as
+// with Collections.Open, config must match the recorded one.
+// Collections are closed by Close
+func (v *VecLite) CreateCollection(name string, config *Config) (*VecLite, error) {
+	return v.collections.Open(name, config)
+}
+
+// OpenCollection returns the handle of the collection name, opening it on
+// first use with the default configuration and the parameters recorded by
+// CreateCollection (see Collections.Reopen)
+// It fails with ErrCollectionNotFound if the collection was never created
+func (v *VecLite) OpenCollection(name string) (*VecLite, error) {
+	return v.collections.Reopen(name)
+}
+
+// Collection returns the collection name for chaining, as in
+// db.Collection("images").Insert(...); it is opened on first use like
+// OpenCollection, and its methods return the error if it can't be
+func (v *VecLite) Collection(name string) CollectionRef {
+	return CollectionRef{db: v, name: name}
+}
+
+// CollectionRef is a collection of a database named by Collection
+type CollectionRef struct {
+	db   *VecLite
+	name string
+}
+
+// Handle returns the handle of the collection, opening it on first use
+func (c CollectionRef) Handle() (*VecLite, error) {
+	return c.db.OpenCollection(c.name)
+}
+
+// Insert inserts vector under id into the collection (see VecLite.Insert)
+func (c CollectionRef) Insert(id uint64, vector []float32) error {
+	db, err := c.Handle()
+	if err != nil {
+		return err
+	}
+	return db.Insert(id, vector)
+}
+
+// InsertWithMetadata inserts vector with metadata under id into the
+// collection (see VecLite.InsertWithMetadata)
+func (c CollectionRef) InsertWithMetadata(id uint64, vector []float32, metadata Metadata) error {
+	db, err := c.Handle()
+	if err != nil {
+		return err
+	}
+	return db.InsertWithMetadata(id, vector, metadata)
+}
+
+// Upsert inserts or replaces vector under id in the collection (see VecLite.Upsert)
+func (c CollectionRef) Upsert(id uint64, vector []float32) error {
+	db, err := c.Handle()
+	if err != nil {
+		return err
+	}
+	return db.Upsert(id, vector)
+}
+
+// Get returns the vector stored under id in the collection (see VecLite.Get)
+func (c CollectionRef) Get(id uint64) ([]float32, error) {
+	db, err := c.Handle()
+	if err != nil {
+		return nil, err
+	}
+	return db.Get(id)
+}
+
+// Delete deletes id from the collection (see VecLite.Delete)
+func (c CollectionRef) Delete(id uint64) error {
+	db, err := c.Handle()
+	if err != nil {
+		return err
+	}
+	return db.Delete(id)
+}
+
+// Search returns the k nearest neighbors of query in the collection (see VecLite.Search)
+func (c CollectionRef) Search(query []float32, k int) ([]index.SearchResult, error) {
+	db, err := c.Handle()
+	if err != nil {
+		return nil, err
+	}
+	return db.Search(query, k)
+}
+
+// CollectionNames returns the names of the collections created with
+// CreateCollection, open or not, in sorted order
+func (v *VecLite) CollectionNames() ([]string, error) {
+	return v.collections.Recorded()
+}
+
+// DropCollection closes the collection name and deletes its files
+func (v *VecLite) DropCollection(name string) error {
+	return v.collections.Drop(name)
+}
+
+// SwapCollectionAlias points the alias name at the collection and returns the
+// collection it pointed at before ("" if the alias is new); aliases are
+// recorded next to the collections, so they survive a restart
+func (v *VecLite) SwapCollectionAlias(name, collection string) (string, error) {
+	return v.collections.SwapAlias(name, collection)
+}
+
+// RollbackCollectionAlias points the alias name back at the collection it
+// pointed at before the last swap and returns the collection it replaced
+func (v *VecLite) RollbackCollectionAlias(name string) (string, error) {
+	return v.collections.RollbackAlias(name)
+}
+
+// ResolveCollectionAlias returns the handle of the collection the alias name
+// points at, opening it on first use like OpenCollection
+func (v *VecLite) ResolveCollectionAlias(name string) (*VecLite, error) {
+	return v.collections.ResolveAlias(name)
+}
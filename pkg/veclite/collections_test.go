@@ -0,0 +1,212 @@
+package veclite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollections(t *testing.T) {
+	dir := t.TempDir()
+	collections := NewCollections(dir)
+	defer collections.Close()
+
+	config := DefaultConfig()
+	config.Dimension = 4
+	products, err := collections.Open("products", config)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	users, err := collections.Open("users", config)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if again, _ := collections.Open("products", config); again != products {
+		t.Error("Expected Open of an open collection to return its handle")
+	}
+	if _, err := os.Stat(dir + "/products.db"); err != nil {
+		t.Errorf("Expected data file in collection directory: %v", err)
+	}
+	if _, err := collections.Open("../escape", config); err == nil {
+		t.Error("Expected error for collection name with path separator")
+	}
+	if names := collections.Names(); len(names) != 2 || names[0] != "products" || names[1] != "users" {
+		t.Errorf("Unexpected names %v", names)
+	}
+
+	if err := users.Insert(1, []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// A writer holding one collection's lock does not block searches in another
+	products.mu.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := users.Search([]float32{1, 0, 0, 0}, 1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Search failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Search in one collection blocked by a write lock on another")
+	}
+	products.mu.Unlock()
+
+	if err := collections.CloseCollection("users"); err != nil {
+		t.Fatalf("CloseCollection failed: %v", err)
+	}
+	if _, err := collections.Get("users"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+	if err := collections.CloseCollection("users"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+
+	// Reopening a closed collection loads its data
+	reopened, err := collections.Open("users", config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if reopened.Size() != 1 {
+		t.Errorf("Expected 1 vector after reopen, got %d", reopened.Size())
+	}
+
+	// A Pipeline can't be recorded, so only Open can reopen its collection
+	normalized := *config
+	normalized.Pipeline = Pipeline{Normalize()}
+	if _, err := collections.Open("normalized", &normalized); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := collections.CloseCollection("normalized"); err != nil {
+		t.Fatalf("CloseCollection failed: %v", err)
+	}
+	if _, err := collections.Reopen("normalized"); err == nil {
+		t.Error("Expected Reopen to refuse a collection opened with a Pipeline")
+	}
+}
+
+func TestVecLite_Collection(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "main.db")
+	config.Dimension = 4
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	if _, err := db.OpenCollection("images"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+
+	imagesConfig := DefaultConfig()
+	imagesConfig.Dimension = 2
+	imagesConfig.IndexType = "hnsw"
+	imagesConfig.M = 8
+	imagesConfig.EdgeWeights = true
+	imagesConfig.EntryPoints = 2
+	imagesConfig.GraphJournal = true
+	imagesConfig.RecordAlignment = 8
+	imagesConfig.Pivots = 2
+	images, err := db.CreateCollection("images", imagesConfig)
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	texts, err := db.CreateCollection("texts", nil)
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if _, err := db.CreateCollection("../escape", imagesConfig); err == nil {
+		t.Error("Expected error for collection name with path separator")
+	}
+	if _, err := db.CreateCollection("images", DefaultConfig()); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for another dimension, got %v", err)
+	}
+	flatConfig := *imagesConfig
+	flatConfig.IndexType = "flat"
+	if _, err := db.CreateCollection("images", &flatConfig); !errors.Is(err, ErrCollectionConfig) {
+		t.Errorf("Expected ErrCollectionConfig for another index type, got %v", err)
+	}
+	paramConfig := *imagesConfig
+	paramConfig.M = 16
+	if _, err := db.CreateCollection("images", &paramConfig); !errors.Is(err, ErrCollectionConfig) {
+		t.Errorf("Expected ErrCollectionConfig for another index parameter, got %v", err)
+	}
+	if again, err := db.CreateCollection("images", imagesConfig); err != nil || again != images {
+		t.Errorf("Expected CreateCollection with the recorded config to return the open handle, got %v", err)
+	}
+	if again, err := db.OpenCollection("images"); err != nil || again != images {
+		t.Errorf("Expected OpenCollection to return the open handle, got %v", err)
+	}
+	if err := images.Insert(1, []float32{1, 2}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Insert(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if images.Size() != 1 || db.Size() != 1 || texts.Size() != 0 {
+		t.Errorf("Expected collections to be separate, got sizes %d, %d and %d", images.Size(), db.Size(), texts.Size())
+	}
+	// The files of images.db start like those of images: dropping one keeps the other's
+	if _, err := db.CreateCollection("images.db", imagesConfig); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Collections are reopened from the manifest
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if names, err := db.CollectionNames(); err != nil || len(names) != 3 || names[0] != "images" || names[2] != "texts" {
+		t.Errorf("Expected collections images, images.db and texts, got %v (%v)", names, err)
+	}
+	images, err = db.OpenCollection("images")
+	if err != nil {
+		t.Fatalf("OpenCollection failed: %v", err)
+	}
+	if images.config.Dimension != 2 || images.config.IndexType != "hnsw" || images.config.M != 8 {
+		t.Errorf("Expected the recorded configuration, got dimension %d, index %s, M %d", images.config.Dimension, images.config.IndexType, images.config.M)
+	}
+	if c := images.config; !c.EdgeWeights || c.EntryPoints != 2 || !c.GraphJournal || c.RecordAlignment != 8 || c.Pivots != 2 {
+		t.Errorf("Expected the recorded file and search parameters, got %+v", c)
+	}
+	if vector, err := images.Get(1); err != nil || vector[1] != 2 {
+		t.Errorf("Expected vector 1 in images, got %v (%v)", vector, err)
+	}
+
+	// Collection opens a recorded collection on first use for chaining
+	if err := db.Collection("images.db").Insert(7, []float32{0, 1}); err != nil {
+		t.Fatalf("Insert through Collection failed: %v", err)
+	}
+	if vector, err := db.Collection("images.db").Get(7); err != nil || vector[1] != 1 {
+		t.Errorf("Expected vector 7 in images.db, got %v (%v)", vector, err)
+	}
+	if err := db.Collection("missing").Insert(1, []float32{1, 0, 0, 0}); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound for a collection never created, got %v", err)
+	}
+
+	if err := db.DropCollection("images"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	dir := config.DataPath + collectionsSuffix
+	if _, err := os.Stat(filepath.Join(dir, "images.db")); !os.IsNotExist(err) {
+		t.Errorf("Expected the files of the dropped collection to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "images.db.db")); err != nil {
+		t.Errorf("Expected the files of images.db to be kept: %v", err)
+	}
+	if names, _ := db.CollectionNames(); len(names) != 2 || names[0] != "images.db" || names[1] != "texts" {
+		t.Errorf("Expected images.db and texts after the drop, got %v", names)
+	}
+	if err := db.DropCollection("images"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected ErrCollectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// VectorColumns is a batch of vectors in columnar form, laid out like an
+// Apache Arrow record batch with a uint64 ID column and a
+// FixedSizeList<float32>[Dimension] vector column, so Arrow buffers can be
+// passed without per-row conversion or a dependency on the Arrow module:
+//
+//	list := record.Column(1).(*array.FixedSizeList)
+//	cols := veclite.VectorColumns{
+//		IDs:      record.Column(0).(*array.Uint64).Uint64Values(),
+//		Values:   list.ListValues().(*array.Float32).Float32Values(),
+//		Validity: list.NullBitmapBytes(),
+//		Offset:   list.Data().Offset(),
+//	}
+type VectorColumns struct {
+	IDs      []uint64  // One ID per row
+	Values   []float32 // Vectors of all rows back to back (the child values of the list column)
+	Validity []byte    // Validity bitmap of the vector column, least significant bit first (nil = no nulls); null rows are skipped
+	Offset   int       // Row offset of the vector column into Values and Validity (Arrow array offset)
+}
+
+// rows returns the vector of every non-null row, as sub-slices of Values
+func (c VectorColumns) rows(dimension int) (ids []uint64, vectors [][]float32, err error) {
+	if c.Offset < 0 {
+		return nil, nil, errors.New("column offset must not be negative")
+	}
+	if need := (c.Offset + len(c.IDs)) * dimension; len(c.Values) < need {
+		return nil, nil, fmt.Errorf("vector column has %d values, expected at least %d for %d rows of dimension %d", len(c.Values), need, len(c.IDs), dimension)
+	}
+	if c.Validity != nil && len(c.Validity)*8 < c.Offset+len(c.IDs) {
+		return nil, nil, fmt.Errorf("validity bitmap of %d bytes is too short for %d rows", len(c.Validity), len(c.IDs))
+	}
+	ids = make([]uint64, 0, len(c.IDs))
+	vectors = make([][]float32, 0, len(c.IDs))
+	for i, id := range c.IDs {
+		row := c.Offset + i
+		if c.Validity != nil && c.Validity[row/8]&(1<<(row%8)) == 0 {
+			continue
+		}
+		ids = append(ids, id)
+		vectors = append(vectors, c.Values[row*dimension:(row+1)*dimension:(row+1)*dimension])
+	}
+	return ids, vectors, nil
+}
+
+// InsertColumns adds an insert for every non-null row of cols, referencing
+// its vector in cols.Values without copying it
+func (b *Batch) InsertColumns(cols VectorColumns, dimension int) error {
+	if dimension <= 0 {
+		return errors.New("dimension must be greater than 0")
+	}
+	ids, vectors, err := cols.rows(dimension)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		b.Insert(id, vectors[i])
+	}
+	return nil
+}
+
+// InsertColumns inserts every non-null row of cols in one batch (see Apply)
+// and returns the number of vectors inserted
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) InsertColumns(cols VectorColumns) (int, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.InsertColumnsContext(ctx, cols)
+}
+
+// InsertColumnsContext is InsertColumns with a context bounding the wait for the write lock
+func (v *VecLite) InsertColumnsContext(ctx context.Context, cols VectorColumns) (int, error) {
+	var batch Batch
+	if err := batch.InsertColumns(cols, v.inputDim); err != nil {
+		return 0, fmt.Errorf("insert columns: %w", err)
+	}
+	if err := v.ApplyContext(ctx, &batch); err != nil {
+		return 0, err
+	}
+	return batch.Len(), nil
+}
+
+// ResultColumns holds search results in columnar form, laid out for Arrow
+// arrays: IDs and Distances are uint64 and float32 columns, Values is the
+// child of a FixedSizeList<float32>[Dimension] column and Keys a string column
+type ResultColumns struct {
+	IDs       []uint64
+	Distances []float32
+	Keys      []string  // nil if no result has a string key
+	Values    []float32 // Vectors back to back (nil if the results carry no vectors)
+	Dimension int       // Length of each vector in Values (0 if Values is nil)
+}
+
+// Columns converts results to columns; either every result carries a vector
+// (all of the same dimension) or none does
+func Columns(results []SearchResult) (ResultColumns, error) {
+	cols := ResultColumns{
+		IDs:       make([]uint64, len(results)),
+		Distances: make([]float32, len(results)),
+	}
+	if len(results) > 0 && results[0].Vector != nil {
+		cols.Dimension = len(results[0].Vector)
+		cols.Values = make([]float32, 0, len(results)*cols.Dimension)
+	}
+	for i, result := range results {
+		cols.IDs[i] = result.ID
+		cols.Distances[i] = result.Distance
+		if result.Key != "" && cols.Keys == nil {
+			cols.Keys = make([]string, len(results))
+		}
+		if cols.Keys != nil {
+			cols.Keys[i] = result.Key
+		}
+		if (result.Vector != nil) != (cols.Values != nil) || (cols.Values != nil && len(result.Vector) != cols.Dimension) {
+			return ResultColumns{}, fmt.Errorf("result %d has a vector of dimension %d, expected %d", result.ID, len(result.Vector), cols.Dimension)
+		}
+		cols.Values = append(cols.Values, result.Vector...)
+	}
+	return cols, nil
+}
@@ -0,0 +1,65 @@
+package veclite
+
+import (
+	"testing"
+)
+
+func TestVecLite_InsertColumns(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	// Four rows starting at offset 1 of the vector column; the third (ID 30) is null
+	values := make([]float32, 5*128)
+	for row := 0; row < 5; row++ {
+		values[row*128] = float32(row)
+	}
+	cols := VectorColumns{
+		IDs:      []uint64{10, 20, 30, 40},
+		Values:   values,
+		Validity: []byte{0b10111}, // Bit 3 (offset 1 + row 2) is cleared
+		Offset:   1,
+	}
+	n, err := db.InsertColumns(cols)
+	if err != nil {
+		t.Fatalf("InsertColumns failed: %v", err)
+	}
+	if n != 3 || db.Size() != 3 {
+		t.Fatalf("Expected 3 inserted vectors, got %d (size %d)", n, db.Size())
+	}
+	if vec, err := db.Get(40); err != nil || vec[0] != 4 {
+		t.Errorf("Expected vector 40 from row 4 of the column, got %v (%v)", vec, err)
+	}
+	if _, err := db.Get(30); err == nil {
+		t.Error("Expected the null row to be skipped")
+	}
+
+	cols.Values = values[:3*128]
+	if _, err := db.InsertColumns(cols); err == nil {
+		t.Error("Expected a too short value column to be rejected")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	results := []SearchResult{
+		{ID: 1, Distance: 0.5, Vector: []float32{1, 2}},
+		{ID: 2, Key: "doc", Distance: 1, Vector: []float32{3, 4}},
+	}
+	cols, err := Columns(results)
+	if err != nil {
+		t.Fatalf("Columns failed: %v", err)
+	}
+	if cols.Dimension != 2 || len(cols.Values) != 4 || cols.Values[2] != 3 || cols.IDs[1] != 2 || cols.Distances[0] != 0.5 {
+		t.Errorf("Unexpected columns: %+v", cols)
+	}
+	if len(cols.Keys) != 2 || cols.Keys[0] != "" || cols.Keys[1] != "doc" {
+		t.Errorf("Expected a key column, got %v", cols.Keys)
+	}
+
+	results[1].Vector = nil
+	if _, err := Columns(results); err == nil {
+		t.Error("Expected mixed vector presence to be rejected")
+	}
+	if cols, err := Columns([]SearchResult{{ID: 1}}); err != nil || cols.Values != nil || cols.Keys != nil {
+		t.Errorf("Expected no vector or key columns, got %+v (%v)", cols, err)
+	}
+}
@@ -0,0 +1,76 @@
+package veclite
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// CompactEstimate is a dry run of Compact: what it would reclaim, how much it
+// would rewrite and how long that would take
+type CompactEstimate struct {
+	ReclaimedBytes int64   // Tombstoned and superseded records removed from the data file
+	RecordsToMove  int     // Live records rewritten into the compacted file
+	BytesToProcess int64   // Bytes read (the records section) plus bytes written (header and live records)
+	Throughput     float64 // Bytes per second of the compactions run since open (0 = none measured)
+
+	// Duration is BytesToProcess at Throughput, or at the BytesPerSecond limit of
+	// Config.BackgroundThrottle before any compaction was measured (0 = unknown)
+	Duration time.Duration
+}
+
+// compactionHistory accumulates the work and time of past compactions
+// Guarded by the VecLite lock (written under the write lock in Compact)
+type compactionHistory struct {
+	bytes   int64
+	elapsed time.Duration
+}
+
+// throughput returns the bytes per second of past compactions (0 = none)
+func (c compactionHistory) throughput() float64 {
+	if c.elapsed <= 0 {
+		return 0
+	}
+	return float64(c.bytes) / c.elapsed.Seconds()
+}
+
+// compactionBytes returns the bytes compaction reads and writes for the data
+// file described by u
+func compactionBytes(u storage.Usage) int64 {
+	return u.FileSize - u.FooterBytes + u.HeaderBytes + u.LiveBytes
+}
+
+// CompactEstimate estimates the effect and cost of running Compact now,
+// without touching the data file
+// Uses read lock - allows concurrent reads
+func (v *VecLite) CompactEstimate() (*CompactEstimate, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	u, err := v.storage.Usage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage usage: %w", err)
+	}
+	estimate := &CompactEstimate{
+		ReclaimedBytes: u.DeadBytes,
+		BytesToProcess: compactionBytes(u),
+		Throughput:     v.compactions.throughput(),
+	}
+	if u.RecordBytes > 0 {
+		estimate.RecordsToMove = int(u.LiveBytes / u.RecordBytes)
+	}
+
+	rate := estimate.Throughput
+	if rate == 0 && v.config.BackgroundThrottle != nil {
+		rate = float64(v.config.BackgroundThrottle.BytesPerSecond)
+	}
+	if rate > 0 {
+		estimate.Duration = time.Duration(math.MaxInt64)
+		if seconds := float64(estimate.BytesToProcess) / rate; seconds < math.MaxInt64/float64(time.Second) {
+			estimate.Duration = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return estimate, nil
+}
@@ -0,0 +1,67 @@
+package veclite
+
+import "testing"
+
+func TestVecLite_CompactEstimate(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/estimate.db"
+	config.Dimension = 4
+	config.BackgroundThrottle = &ThrottleConfig{BytesPerSecond: 1 << 30}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	for id := uint64(1); id <= 10; id++ {
+		if err := db.Insert(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for id := uint64(1); id <= 4; id++ {
+		if err := db.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	estimate, err := db.CompactEstimate()
+	if err != nil {
+		t.Fatalf("CompactEstimate failed: %v", err)
+	}
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if estimate.ReclaimedBytes != usage.DeadData || estimate.RecordsToMove != 6 {
+		t.Errorf("Expected %d reclaimed bytes and 6 records to move, got %+v", usage.DeadData, estimate)
+	}
+	// Header and all 10 records read, header and 6 live records written
+	if want := 2*usage.Header + usage.LiveData + usage.LiveData + usage.DeadData; estimate.BytesToProcess != want {
+		t.Errorf("Expected %d bytes to process, got %d", want, estimate.BytesToProcess)
+	}
+	// No compaction measured yet: the throttle limit bounds the duration
+	if estimate.Throughput != 0 || estimate.Duration <= 0 {
+		t.Errorf("Expected a duration from the throttle limit only, got %+v", estimate)
+	}
+
+	// A dry run changes nothing
+	after, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if after.DeadData != usage.DeadData || after.Total != usage.Total {
+		t.Errorf("Expected the dry run not to touch the data file, got %+v", after)
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	estimate, err = db.CompactEstimate()
+	if err != nil {
+		t.Fatalf("CompactEstimate failed: %v", err)
+	}
+	if estimate.ReclaimedBytes != 0 || estimate.RecordsToMove != 6 || estimate.Throughput <= 0 {
+		t.Errorf("Expected nothing to reclaim and a measured throughput after Compact, got %+v", estimate)
+	}
+}
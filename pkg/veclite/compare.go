@@ -0,0 +1,74 @@
+package veclite
+
+import "math"
+
+// ResultComparison compares two rankings of search results, see CompareResults
+type ResultComparison struct {
+	Overlap        float64 // Fraction of the IDs in a that b returned too (1 if both are empty)
+	Common         int     // IDs returned by both
+	RankMatches    int     // Ranks at which a and b hold the same ID
+	Identical      bool    // Same IDs in the same order
+	KendallTau     float64 // Rank correlation of the common IDs, -1..1 (1 if fewer than two)
+	MeanScoreDelta float64 // Mean b - a distance of the common IDs
+	MaxScoreDelta  float64 // Largest absolute b - a distance of the common IDs
+}
+
+// CompareResults compares the results b of a search with the results a of the
+// same search, e.g. from a retuned index against the current one or against
+// exact search; a is the reference, so Overlap is the recall of b against a
+// Score deltas are only meaningful if both were searched with IncludeScore
+func CompareResults(a, b []SearchResult) ResultComparison {
+	c := ResultComparison{KendallTau: 1}
+	inB := make(map[uint64]int, len(b)) // ID -> rank in b
+	for i, result := range b {
+		if _, seen := inB[result.ID]; !seen {
+			inB[result.ID] = i
+		}
+		if i < len(a) && a[i].ID == result.ID {
+			c.RankMatches++
+		}
+	}
+	c.Identical = len(a) == len(b) && c.RankMatches == len(a)
+	if len(a) == 0 {
+		if len(b) == 0 {
+			c.Overlap = 1
+		}
+		return c
+	}
+
+	// Ranks in b of the common IDs, in the order of a
+	ranks := make([]int, 0, len(a))
+	var deltaSum float64
+	for _, result := range a {
+		rank, ok := inB[result.ID]
+		if !ok {
+			continue
+		}
+		delete(inB, result.ID) // Count duplicate IDs in a once
+		ranks = append(ranks, rank)
+		delta := float64(b[rank].Distance - result.Distance)
+		deltaSum += delta
+		c.MaxScoreDelta = math.Max(c.MaxScoreDelta, math.Abs(delta))
+	}
+	c.Common = len(ranks)
+	c.Overlap = float64(c.Common) / float64(len(a))
+	if c.Common == 0 {
+		return c
+	}
+	c.MeanScoreDelta = deltaSum / float64(c.Common)
+
+	if c.Common >= 2 {
+		concordant, discordant := 0, 0
+		for i := range ranks {
+			for j := i + 1; j < len(ranks); j++ {
+				if ranks[i] < ranks[j] {
+					concordant++
+				} else {
+					discordant++
+				}
+			}
+		}
+		c.KendallTau = float64(concordant-discordant) / float64(concordant+discordant)
+	}
+	return c
+}
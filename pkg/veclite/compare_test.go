@@ -0,0 +1,51 @@
+package veclite
+
+import (
+	"math"
+	"testing"
+)
+
+// rankedResults returns results for ids with distances 1, 2, 3, ...
+func rankedResults(ids ...uint64) []SearchResult {
+	results := make([]SearchResult, len(ids))
+	for i, id := range ids {
+		results[i] = SearchResult{ID: id, Distance: float32(i + 1)}
+	}
+	return results
+}
+
+func TestCompareResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []SearchResult
+		overlap   float64
+		rank      int
+		identical bool
+		tau       float64
+	}{
+		{"identical", rankedResults(1, 2, 3), rankedResults(1, 2, 3), 1, 3, true, 1},
+		{"reversed", rankedResults(1, 2, 3), rankedResults(3, 2, 1), 1, 1, false, -1},
+		{"one swap", rankedResults(1, 2, 3, 4), rankedResults(1, 3, 2, 4), 1, 2, false, 4.0 / 6},
+		{"partial", rankedResults(1, 2, 3, 4), rankedResults(1, 2, 7, 8), 0.5, 2, false, 1},
+		{"disjoint", rankedResults(1, 2), rankedResults(3, 4), 0, 0, false, 1},
+		{"both empty", nil, nil, 1, 0, true, 1},
+		{"a empty", nil, rankedResults(1), 0, 0, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := CompareResults(tt.a, tt.b)
+			if c.Overlap != tt.overlap || c.RankMatches != tt.rank || c.Identical != tt.identical || math.Abs(c.KendallTau-tt.tau) > 1e-9 {
+				t.Errorf("Expected overlap %v, %d rank matches, identical %v, tau %v; got %+v", tt.overlap, tt.rank, tt.identical, tt.tau, c)
+			}
+		})
+	}
+}
+
+func TestCompareResults_ScoreDeltas(t *testing.T) {
+	a := []SearchResult{{ID: 1, Distance: 1}, {ID: 2, Distance: 2}, {ID: 3, Distance: 3}}
+	b := []SearchResult{{ID: 1, Distance: 1.5}, {ID: 2, Distance: 1}, {ID: 9, Distance: 2}}
+	c := CompareResults(a, b)
+	if c.Common != 2 || c.MeanScoreDelta != -0.25 || c.MaxScoreDelta != 1 {
+		t.Errorf("Expected 2 common IDs, mean delta -0.25 and max delta 1, got %+v", c)
+	}
+}
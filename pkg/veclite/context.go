@@ -0,0 +1,58 @@
+package veclite
+
+import (
+	"context"
+	"time"
+)
+
+// withDefaultTimeout derives a context with timeout d, unless d is zero
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// lockContext acquires the write lock, giving up when ctx is done
+// sync.RWMutex can't be abandoned mid-wait, so a helper goroutine takes the
+// lock and hands it back if nobody is waiting for it anymore
+func (v *VecLite) lockContext(ctx context.Context) error {
+	return acquireContext(ctx, v.mu.TryLock, v.mu.Lock, v.mu.Unlock)
+}
+
+// rLockContext acquires the read lock, giving up when ctx is done
+func (v *VecLite) rLockContext(ctx context.Context) error {
+	return acquireContext(ctx, v.mu.TryRLock, v.mu.RLock, v.mu.RUnlock)
+}
+
+// acquireContext implements lockContext/rLockContext for one lock mode
+func acquireContext(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tryLock() {
+		return nil
+	}
+	if ctx.Done() == nil { // Never cancelled - plain blocking acquire
+		lock()
+		return nil
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		lock()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		return nil
+	case <-ctx.Done():
+		// Release the lock as soon as the helper gets it
+		go func() {
+			<-acquired
+			unlock()
+		}()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,122 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVecLite_SearchContext_Cancelled(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		for i := uint64(1); i <= 20; i++ {
+			vec := make([]float32, 128)
+			vec[0] = float32(i)
+			if err := db.Insert(i, vec); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := db.SearchContext(ctx, make([]float32, 128), 5); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+
+		results, err := db.SearchContext(context.Background(), make([]float32, 128), 5)
+		if err != nil || len(results) == 0 {
+			t.Errorf("Expected results with live context, got %v (%v)", results, err)
+		}
+	})
+}
+
+func TestVecLite_DefaultSearchTimeout(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	db.config.DefaultSearchTimeout = 20 * time.Millisecond
+
+	// Simulate a long-running writer holding the lock
+	db.mu.Lock()
+	start := time.Now()
+	_, err := db.Search(make([]float32, 128), 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Search waited %v despite 20ms default timeout", elapsed)
+	}
+	db.mu.Unlock()
+
+	// The abandoned lock attempt must not leak the read lock
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert after timed-out search failed: %v", err)
+	}
+	if _, err := db.Search(make([]float32, 128), 1); err != nil {
+		t.Errorf("Search failed: %v", err)
+	}
+}
+
+func TestVecLite_DefaultWriteTimeout(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	db.config.DefaultWriteTimeout = 20 * time.Millisecond
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// Simulate a long-running reader holding the lock
+	db.mu.RLock()
+	if err := db.Insert(2, make([]float32, 128)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected insert to time out, got %v", err)
+	}
+	if err := db.Delete(1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected delete to time out, got %v", err)
+	}
+	db.mu.RUnlock()
+
+	// The abandoned lock attempts must not leak the write lock
+	if err := db.InsertContext(context.Background(), 2, make([]float32, 128)); err != nil {
+		t.Fatalf("InsertContext failed: %v", err)
+	}
+	if err := db.DeleteContext(context.Background(), 1); err != nil {
+		t.Fatalf("DeleteContext failed: %v", err)
+	}
+	if db.Size() != 1 {
+		t.Errorf("Expected size 1, got %d", db.Size())
+	}
+}
+
+func TestVecLite_GetAndMetadataContext(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	// Simulate a long-running writer holding the lock
+	db.mu.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	if _, err := db.GetContext(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected get to time out, got %v", err)
+	}
+	if err := db.InsertWithMetadataContext(ctx, 1, make([]float32, 128), Metadata{"a": 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected insert to time out, got %v", err)
+	}
+	cancel()
+	db.mu.Unlock()
+
+	ctx = context.Background()
+	if err := db.InsertWithMetadataContext(ctx, 1, make([]float32, 128), Metadata{"a": 1}); err != nil {
+		t.Fatalf("InsertWithMetadataContext failed: %v", err)
+	}
+	if err := db.UpsertWithMetadataContext(ctx, 1, make([]float32, 128), Metadata{"a": 2}); err != nil {
+		t.Fatalf("UpsertWithMetadataContext failed: %v", err)
+	}
+	if vector, err := db.GetContext(ctx, 1); err != nil || len(vector) != 128 {
+		t.Errorf("Expected vector 1, got %v (%v)", vector, err)
+	}
+	if metadata, _ := db.GetMetadata(1); metadata["a"] != 2 {
+		t.Errorf("Expected the upserted metadata, got %v", metadata)
+	}
+}
@@ -0,0 +1,264 @@
+package veclite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// IndexHistogram describes how vectors are spread over the index structure
+type IndexHistogram struct {
+	Label  string // "level" (HNSW: nodes by top level) or "cluster" (IVF: vectors per cluster)
+	Counts []int
+}
+
+// Histogram returns how vectors are spread over the serving index (nil for
+// flat search, which has no structure)
+// Uses read lock - allows concurrent reads
+func (v *VecLite) Histogram() *IndexHistogram {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	h, ok := v.index.(index.Histogrammer)
+	if !ok {
+		return nil
+	}
+	label, counts := h.Histogram()
+	return &IndexHistogram{Label: label, Counts: counts}
+}
+
+// DebugHandler returns an HTTP handler serving a small inspection UI for db:
+// stats, cache metrics, the HNSW level or IVF cluster histogram, recent slow
+// queries and a console for ID lookups and ad-hoc searches
+// JSON endpoints are served next to the page: stats, slow, histogram,
+// vector?id=N and search?q=[...]&k=N. Mount it with a trailing slash, e.g.
+//
+//	mux.Handle("/debug/veclite/", http.StripPrefix("/debug/veclite", veclite.DebugHandler(db)))
+//
+// The console runs real searches and reads; don't expose it publicly
+func DebugHandler(db *VecLite) http.Handler {
+	return &debugHandler{db: db}
+}
+
+// debugHandler implements DebugHandler
+type debugHandler struct {
+	db *VecLite
+}
+
+// ServeHTTP routes requests to the page and the JSON endpoints
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "":
+		h.page(w, r)
+	case "stats":
+		writeDebugJSON(w, h.db.Stats())
+	case "slow":
+		writeDebugJSON(w, h.db.SlowQueries())
+	case "histogram":
+		writeDebugJSON(w, h.db.Histogram())
+	case "vector":
+		lookup, err := h.lookup(r.FormValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), debugStatus(err))
+			return
+		}
+		writeDebugJSON(w, lookup)
+	case "search":
+		results, err := h.search(r.FormValue("q"), r.FormValue("k"))
+		if err != nil {
+			http.Error(w, err.Error(), debugStatus(err))
+			return
+		}
+		writeDebugJSON(w, results)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// debugLookup is the result of a console ID lookup
+type debugLookup struct {
+	ID       uint64
+	Vector   []float32
+	Metadata Metadata
+}
+
+// lookup reads the vector and metadata with the given ID
+// Unlike Get it never calls Config.Loader
+func (h *debugHandler) lookup(idParam string) (*debugLookup, error) {
+	id, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q", idParam)
+	}
+	vector, err := h.db.get(id)
+	if err != nil {
+		return nil, err
+	}
+	metadata, _ := h.db.GetMetadata(id)
+	return &debugLookup{ID: id, Vector: vector, Metadata: metadata}, nil
+}
+
+// search runs a console search for the JSON array query
+func (h *debugHandler) search(query, kParam string) ([]SearchResult, error) {
+	var vector []float32
+	if err := json.Unmarshal([]byte(query), &vector); err != nil {
+		return nil, fmt.Errorf("query must be a JSON array of numbers: %w", err)
+	}
+	k := 10
+	if kParam != "" {
+		var err error
+		if k, err = strconv.Atoi(kParam); err != nil {
+			return nil, fmt.Errorf("invalid k %q", kParam)
+		}
+	}
+	return h.db.Search(vector, k)
+}
+
+// debugStatus maps a console error to an HTTP status
+func debugStatus(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// writeDebugJSON writes value as indented JSON
+func writeDebugJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// debugPage is the data of the debug page template
+type debugPage struct {
+	Stats     Stats
+	HitRatio  float64 // Fraction of vector reads served by the cache
+	Cache     int     // Configured cache capacity
+	Histogram *IndexHistogram
+	MaxCount  int // Largest histogram count, for scaling the bars
+	Slow      []SlowQuery
+	Threshold string
+
+	ID, Query, K string // Console input
+	Lookup       *debugLookup
+	Results      []SearchResult
+	Error        string
+}
+
+// page renders the debug page, running the console request if there is one
+func (h *debugHandler) page(w http.ResponseWriter, r *http.Request) {
+	page := debugPage{
+		Stats:     h.db.Stats(),
+		Cache:     h.db.config.CacheCapacity,
+		Histogram: h.db.Histogram(),
+		Slow:      h.db.SlowQueries(),
+		Threshold: "disabled",
+		ID:        r.FormValue("id"),
+		Query:     r.FormValue("q"),
+		K:         r.FormValue("k"),
+	}
+	if counters := page.Stats.Profile.Counters; counters.Reads > 0 {
+		page.HitRatio = float64(counters.CacheHits) / float64(counters.Reads)
+	}
+	if page.Histogram != nil {
+		for _, n := range page.Histogram.Counts {
+			page.MaxCount = max(page.MaxCount, n)
+		}
+	}
+	if threshold := h.db.slowQueryThreshold(); threshold > 0 {
+		page.Threshold = threshold.String()
+	}
+
+	var err error
+	switch {
+	case page.ID != "":
+		page.Lookup, err = h.lookup(page.ID)
+	case page.Query != "":
+		page.Results, err = h.search(page.Query, page.K)
+	}
+	if err != nil {
+		page.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugTemplate.Execute(w, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// debugTemplate is the debug page
+var debugTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"bar": func(n, maxCount int) int {
+		if maxCount == 0 {
+			return 0
+		}
+		return n * 300 / maxCount
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><title>VecLite debug</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; vertical-align: top; }
+.bar { background: #4a90d9; height: 10px; display: inline-block; }
+.error { color: #b00; }
+</style></head><body>
+<h1>VecLite</h1>
+<p>JSON: <a href="stats">stats</a> · <a href="slow">slow</a> · <a href="histogram">histogram</a></p>
+
+<h2>Stats</h2>
+<table>
+<tr><th>Vectors</th><td>{{.Stats.Size}}</td></tr>
+<tr><th>Index</th><td>{{.Stats.IndexType}} (serving: {{.Stats.ServingIndex}})</td></tr>
+{{if .Stats.Loading}}<tr><th>Loading</th><td>index is loading in the background</td></tr>{{end}}
+{{if .Stats.Degraded}}<tr><th>Degraded</th><td class="error">{{.Stats.DegradedReason}}</td></tr>{{end}}
+{{if .Stats.RebuildError}}<tr><th>Rebuild error</th><td class="error">{{.Stats.RebuildError}}</td></tr>{{end}}
+{{if .Stats.ParamsMismatch}}<tr><th>Params mismatch</th><td>{{.Stats.ParamsMismatch}}</td></tr>{{end}}
+<tr><th>Pending writes</th><td>{{.Stats.PendingWrites}} ({{.Stats.WriteStalls}} stalls)</td></tr>
+<tr><th>Searches</th><td>{{.Stats.Profile.Searches}}</td></tr>
+{{if .Stats.MaintenanceError}}<tr><th>Maintenance error</th><td class="error">{{.Stats.MaintenanceError}}</td></tr>{{end}}
+</table>
+
+<h2>Cache</h2>
+<table>
+<tr><th>Capacity</th><td>{{.Cache}}</td></tr>
+<tr><th>Vector reads</th><td>{{.Stats.Profile.Counters.Reads}}</td></tr>
+<tr><th>Cache hits</th><td>{{.Stats.Profile.Counters.CacheHits}} ({{percent .HitRatio}})</td></tr>
+<tr><th>Bytes read</th><td>{{.Stats.Profile.Counters.BytesRead}}</td></tr>
+<tr><th>Distance computations</th><td>{{.Stats.Profile.Counters.Distances}}</td></tr>
+</table>
+
+{{with .Histogram}}<h2>Vectors by {{.Label}}</h2>
+<table>{{range $i, $n := .Counts}}
+<tr><td>{{$i}}</td><td>{{$n}}</td><td><span class="bar" style="width: {{bar $n $.MaxCount}}px"></span></td></tr>{{end}}
+</table>{{end}}
+
+<h2>Slow queries (threshold: {{.Threshold}})</h2>
+{{if .Slow}}<table>
+<tr><th>Time</th><th>Trace</th><th>Latency</th><th>k</th><th>Results</th><th>Filter</th><th>Error</th></tr>{{range .Slow}}
+<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.TraceID}}</td><td>{{.Latency}}</td><td>{{.K}}</td><td>{{.Results}}</td><td>{{if .Filter}}{{.Filter}}{{end}}</td><td class="error">{{.Error}}</td></tr>{{end}}
+</table>{{else}}<p>None.</p>{{end}}
+
+<h2>Console</h2>
+<form method="get" action="."><input name="id" placeholder="Vector ID" value="{{.ID}}"> <button>Look up</button></form>
+<form method="get" action="."><input name="q" size="60" placeholder="[0.1, 0.2, ...]" value="{{.Query}}"> k <input name="k" size="3" value="{{.K}}"> <button>Search</button></form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{with .Lookup}}<table>
+<tr><th>ID</th><td>{{.ID}}</td></tr>
+<tr><th>Vector</th><td>{{.Vector}}</td></tr>
+{{if .Metadata}}<tr><th>Metadata</th><td>{{.Metadata}}</td></tr>{{end}}
+</table>{{end}}
+{{if .Results}}<table>
+<tr><th>ID</th><th>Distance</th><th>Metadata</th></tr>{{range .Results}}
+<tr><td><a href="?id={{.ID}}">{{.ID}}</a></td><td>{{.Distance}}</td><td>{{if .Metadata}}{{.Metadata}}{{end}}</td></tr>{{end}}
+</table>{{end}}
+</body></html>
+`))
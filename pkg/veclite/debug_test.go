@@ -0,0 +1,121 @@
+package veclite
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDebugTestDB creates an HNSW database with vectors 1..40 of dimension 4
+func newDebugTestDB(t *testing.T) *VecLite {
+	t.Helper()
+	db, _ := createTestDB(t, "hnsw", smallVectors, smallHNSW, func(config *Config) {
+		config.SlowQueryThreshold = time.Nanosecond
+	})
+	insertSmallVectors(t, db, 40, func(int) Metadata { return Metadata{"name": "v"} })
+	return db
+}
+
+// debugGet requests target from handler and returns the status and body
+func debugGet(t *testing.T, handler http.Handler, target string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestDebugHandler_Page(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := DebugHandler(db)
+
+	if _, err := db.Search([]float32{3, 1, 0, 0}, 2); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	code, body := debugGet(t, handler, "/")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	for _, want := range []string{"<td>40</td>", "Vectors by level", "threshold: 1ns", "Cache hits"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected page to contain %q", want)
+		}
+	}
+
+	// Console lookup and search
+	if _, body := debugGet(t, handler, "/?id=7"); !strings.Contains(body, "[7 1 0 0]") || !strings.Contains(body, "map[name:v]") {
+		t.Errorf("Expected lookup of vector 7 with metadata")
+	}
+	if _, body := debugGet(t, handler, "/?q=%5B5,1,0,0%5D&k=2"); !strings.Contains(body, `<a href="?id=5">5</a>`) {
+		t.Errorf("Expected search results linking to vector 5")
+	}
+	if _, body := debugGet(t, handler, "/?q=nope"); !strings.Contains(body, `class="error">query must be a JSON array`) {
+		t.Errorf("Expected console error for an invalid query")
+	}
+}
+
+func TestDebugHandler_JSON(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := DebugHandler(db)
+
+	code, body := debugGet(t, handler, "/search?q=%5B5,1,0,0%5D&k=3")
+	var results []SearchResult
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &results) != nil || len(results) != 3 || results[0].ID != 5 {
+		t.Fatalf("Unexpected search response %d: %s", code, body)
+	}
+
+	code, body = debugGet(t, handler, "/slow")
+	var slow []SlowQuery
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &slow) != nil || len(slow) != 1 || slow[0].K != 3 {
+		t.Errorf("Expected the search in the slow query log, got %d: %s", code, body)
+	}
+
+	code, body = debugGet(t, handler, "/histogram")
+	var histogram IndexHistogram
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &histogram) != nil || histogram.Label != "level" {
+		t.Errorf("Unexpected histogram response %d: %s", code, body)
+	}
+
+	if code, _ := debugGet(t, handler, "/stats"); code != http.StatusOK {
+		t.Errorf("Expected 200 for stats, got %d", code)
+	}
+	if code, _ := debugGet(t, handler, "/vector?id=999"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing vector, got %d", code)
+	}
+	if code, _ := debugGet(t, handler, "/vector?id=x"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid id, got %d", code)
+	}
+	if code, _ := debugGet(t, handler, "/bogus"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown path, got %d", code)
+	}
+}
+
+func TestVecLite_SlowQueries(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+
+	for i := 0; i < slowQueryCapacity+5; i++ {
+		if _, err := db.Search([]float32{float32(i), 1, 0, 0}, 1+i%3); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	slow := db.SlowQueries()
+	if len(slow) != slowQueryCapacity {
+		t.Fatalf("Expected %d slow queries, got %d", slowQueryCapacity, len(slow))
+	}
+	if slow[0].Query[0] != float32(slowQueryCapacity+4) || slow[len(slow)-1].Query[0] != 5 {
+		t.Errorf("Expected newest first, got %v ... %v", slow[0].Query, slow[len(slow)-1].Query)
+	}
+
+	db.config.SlowQueryThreshold = -1
+	if _, err := db.Search([]float32{1, 1, 0, 0}, 1); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := db.SlowQueries(); got[0].Query[0] != float32(slowQueryCapacity+4) {
+		t.Errorf("Expected no slow queries to be recorded when disabled")
+	}
+}
@@ -0,0 +1,323 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/index/flat"
+	"github.com/monishSR/veclite/internal/throttle"
+)
+
+// Degraded mode
+//
+// With Config.DegradedMode set, a HNSW graph or IVF file that fails to load no
+// longer fails New. Instead the database serves exact flat search over the
+// vectors in storage (Stats().Degraded reports it) while the configured index
+// is rebuilt from storage in the background. Once the rebuild finishes it is
+// swapped in under the write lock and its sidecar is saved.
+//
+// Config.LazyLoad uses the same machinery to open quickly: New serves flat
+// search over the stored IDs (Stats().Loading) while the HNSW graph or IVF file
+// is loaded in the background, and Ready is closed once it is swapped in. A
+// sidecar that fails to load is then rebuilt as in degraded mode.
+
+// degradedState tracks the background rebuild of a degraded database
+// dirty is guarded by VecLite.mu (write lock)
+type degradedState struct {
+	loading bool                // Loading the sidecar for Config.LazyLoad; it hasn't failed (yet)
+	reason  error               // Why the sidecar failed to load
+	dirty   map[uint64]struct{} // IDs mutated since the rebuild snapshot (nil before the snapshot)
+	stop    chan struct{}       // Closed by Close to abandon the rebuild
+	done    chan struct{}       // Closed when the rebuild goroutine exits
+	err     error               // Rebuild failure, if any (valid after done)
+}
+
+// sidecarPath returns the persisted index structure file for the index type, if any
+func sidecarPath(config *Config) string {
+	switch index.IndexType(config.IndexType) {
+	case index.IndexTypeHNSW:
+		return config.DataPath + ".graph"
+	case index.IndexTypeIVF, index.IndexTypeIVFPQ:
+		return config.DataPath + ".ivf" // IVF-PQ codes are retrained if their sidecar is lost
+	}
+	return ""
+}
+
+// canLoadLazily reports whether the index can be loaded in the background
+func canLoadLazily(config *Config) bool {
+	path := sidecarPath(config)
+	if !config.LazyLoad || path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// canDegrade reports whether a failed index load may fall back to degraded mode
+func canDegrade(config *Config) bool {
+	path := sidecarPath(config)
+	if !config.DegradedMode || path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// isIVF reports whether the index type keeps IVF clusters (IVF or IVF-PQ)
+func isIVF(config *Config) bool {
+	switch index.IndexType(config.IndexType) {
+	case index.IndexTypeIVF, index.IndexTypeIVFPQ:
+		return true
+	}
+	return false
+}
+
+// alreadyReady is the Ready channel of databases that load their index in New
+var alreadyReady = func() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Ready returns a channel that is closed once the configured index serves
+// searches: immediately, unless Config.LazyLoad or DegradedMode load or rebuild
+// it in the background. It is closed as well if that fails, with Stats().RebuildError
+// reporting why the database keeps serving flat search
+func (v *VecLite) Ready() <-chan struct{} {
+	return v.ready
+}
+
+// errBuildStopped is returned by buildIndex when stop is closed
+var errBuildStopped = errors.New("index build stopped")
+
+// buildIndex builds the configured index type over vectors already in storage
+// IDs rejected by keep are skipped; closing stop abandons the build
+// pace (optional) paces the batches; callers holding the lock pass nil, so no
+// one waits on the throttle behind it
+// progress (optional) is called after every batch and at the end
+func (v *VecLite) buildIndex(vectors map[uint64][]float32, keep func(id uint64) bool, stop <-chan struct{}, pace *throttle.Throttle, progress func(done, total int)) (index.Index, error) {
+	built, err := index.NewEmptyIndex(index.IndexType(v.config.IndexType), v.config.Dimension, indexConfig(v.config), v.storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index for rebuild: %w", err)
+	}
+	indexer, ok := built.(index.ExistingIndexer)
+	if !ok {
+		return nil, fmt.Errorf("index type %s cannot be rebuilt from storage", v.config.IndexType)
+	}
+
+	// Pace the build in batches so background rebuilds stay within the throttle
+	const batchSize = 256
+	recordBytes := int64(8 + 4*v.config.Dimension)
+	batchStart, batched, done := time.Now(), 0, 0
+	for id, vec := range vectors {
+		select {
+		case <-stop:
+			return nil, errBuildStopped
+		default:
+		}
+		if keep != nil && !keep(id) {
+			continue
+		}
+		if err := indexer.IndexExisting(id, vec); err != nil {
+			return nil, fmt.Errorf("failed to rebuild vector %d: %w", id, err)
+		}
+		done++
+		if batched++; batched == batchSize {
+			pace.Pace(time.Since(batchStart))
+			pace.WaitBytes(batchSize * recordBytes)
+			batchStart, batched = time.Now(), 0
+			if progress != nil {
+				progress(done, len(vectors))
+			}
+		}
+	}
+	if progress != nil {
+		progress(len(vectors), len(vectors))
+	}
+	return built, nil
+}
+
+// openDegraded opens the flat fallback index used while the real index is rebuilt
+func (v *VecLite) openDegraded(reason error) error {
+	fallback, err := flat.OpenFlatIndexFunc(v.config.Dimension, v.storage, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open flat fallback index: %w", err)
+	}
+	fmt.Printf("Warning: failed to load %s index, serving degraded flat search while rebuilding: %v\n", v.config.IndexType, reason)
+
+	v.index = fallback
+	v.degraded = &degradedState{
+		reason: reason,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	v.ready = v.degraded.done
+	go v.rebuildDegraded(v.degraded)
+	return nil
+}
+
+// openLazy opens the flat fallback over the stored IDs and loads the configured
+// index in the background (Config.LazyLoad)
+func (v *VecLite) openLazy() error {
+	fallback, err := flat.OpenFlatIndexIDs(v.config.Dimension, v.storage, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open flat fallback index: %w", err)
+	}
+
+	// The sidecar is as of the last Close, so every write from now on is replayed
+	v.index = fallback
+	v.degraded = &degradedState{
+		loading: true,
+		dirty:   make(map[uint64]struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	v.ready = v.degraded.done
+	go v.loadLazily(v.degraded)
+	return nil
+}
+
+// recoverBackground turns a panic of a background load or rebuild into
+// state.err, so the database stays degraded rather than crash the process
+func (v *VecLite) recoverBackground(state *degradedState) {
+	if r := recover(); r != nil {
+		state.err = &PanicError{Op: "rebuild", IndexType: v.config.IndexType, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// loadLazily loads the configured index from its sidecar without holding the
+// lock, then replays IDs mutated meanwhile and swaps it in
+// A load failure turns into a degraded-mode rebuild
+func (v *VecLite) loadLazily(state *degradedState) {
+	defer v.label(context.Background(), "load")()
+	defer close(state.done)
+	defer v.recoverBackground(state)
+
+	loaded, err := index.NewIndex(index.IndexType(v.config.IndexType), v.config.Dimension, indexConfig(v.config), v.storage)
+	if err != nil {
+		v.mu.Lock()
+		state.loading, state.reason = false, err
+		v.mu.Unlock()
+		fmt.Printf("Warning: failed to load %s index, serving degraded flat search while rebuilding: %v\n", v.config.IndexType, err)
+		v.rebuild(state)
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.swapIn(state, loaded) {
+		return
+	}
+	if err := v.applyConfigParams(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// rebuildDegraded builds the configured index from a storage snapshot without
+// holding the lock, then replays IDs mutated meanwhile and swaps it in
+func (v *VecLite) rebuildDegraded(state *degradedState) {
+	defer v.label(context.Background(), "rebuild")()
+	defer close(state.done)
+	defer v.recoverBackground(state)
+	v.rebuild(state)
+}
+
+// rebuild implements rebuildDegraded
+func (v *VecLite) rebuild(state *degradedState) {
+	v.mu.Lock()
+	vectors, err := v.storage.ReadAllVectors()
+	state.dirty = make(map[uint64]struct{})
+	v.mu.Unlock()
+	if err != nil {
+		state.err = fmt.Errorf("failed to read vectors for rebuild: %w", err)
+		return
+	}
+
+	tracker := v.trackProgress(OpRebuild)
+	defer tracker.finish()
+	rebuilt, err := v.buildIndex(vectors, nil, state.stop, v.throttle, func(done, total int) {
+		tracker.report("index", int64(done), int64(total), "vectors")
+	})
+	if err != nil {
+		if err != errBuildStopped {
+			state.err = err
+		}
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.swapIn(state, rebuilt) {
+		return
+	}
+	if err := v.saveSidecar(); err != nil {
+		fmt.Printf("Warning: failed to save rebuilt %s index: %v\n", v.config.IndexType, err)
+	}
+}
+
+// swapIn replays the IDs mutated since state.dirty was created into idx and
+// makes it the serving index, unless Close abandoned the background work
+// Reports whether idx was swapped in
+// Note: Assumes write lock is already held
+func (v *VecLite) swapIn(state *degradedState, idx index.Index) bool {
+	select {
+	case <-state.stop:
+		return false
+	default:
+	}
+
+	// Replay writes that happened during the load or rebuild
+	indexer, ok := idx.(index.ExistingIndexer)
+	if !ok && len(state.dirty) > 0 {
+		state.err = fmt.Errorf("index type %s cannot replay writes", v.config.IndexType)
+		return false
+	}
+	for id := range state.dirty {
+		vec, err := v.storage.ReadVector(id)
+		if err != nil {
+			if err := idx.Delete(id); err != nil { // Deleted meanwhile
+				state.err = fmt.Errorf("failed to replay delete of vector %d: %w", id, err)
+				return false
+			}
+			continue
+		}
+		if err := indexer.IndexExisting(id, vec); err != nil {
+			state.err = fmt.Errorf("failed to replay vector %d: %w", id, err)
+			return false
+		}
+	}
+
+	v.index = idx
+	v.degraded = nil
+	return true
+}
+
+// markDirty records a mutation for replay by an in-progress rebuild
+// Note: Assumes write lock is already held
+func (v *VecLite) markDirty(id uint64) {
+	if v.degraded != nil && v.degraded.dirty != nil {
+		v.degraded.dirty[id] = struct{}{}
+	}
+}
+
+// stopRebuild abandons an in-progress rebuild and waits for it to exit
+// Must be called without holding the lock
+func (v *VecLite) stopRebuild() {
+	v.mu.RLock()
+	state := v.degraded
+	v.mu.RUnlock()
+	if state == nil {
+		return
+	}
+	select {
+	case <-state.stop:
+	default:
+		close(state.stop)
+	}
+	<-state.done
+}
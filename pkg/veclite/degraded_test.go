@@ -0,0 +1,244 @@
+package veclite
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// createCorruptSidecarDB writes 50 vectors with the given index type, closes the
+// database and overwrites its sidecar with garbage
+func createCorruptSidecarDB(t *testing.T, indexType string) *Config {
+	t.Helper()
+	config := createSidecarDB(t, indexType)
+	if err := os.WriteFile(sidecarPath(config), []byte("not an index"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt sidecar: %v", err)
+	}
+	return config
+}
+
+// createSidecarDB writes 50 vectors with the given index type and closes the
+// database, leaving its sidecar on disk
+func createSidecarDB(t *testing.T, indexType string) *Config {
+	t.Helper()
+	db, _ := createTestDB(t, indexType, func(config *Config) {
+		config.Dimension = 8
+		config.NClusters = 4
+		config.NProbe = 4
+	})
+	for i := uint64(1); i <= 50; i++ {
+		vec := make([]float32, 8)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return db.config
+}
+
+// waitForReady waits for the index to be loaded or rebuilt in the background
+func waitForReady(t *testing.T, db *VecLite) {
+	select {
+	case <-db.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Index was not ready")
+	}
+}
+
+// waitForRebuild waits for the degraded-mode rebuild to finish
+func waitForRebuild(t *testing.T, db *VecLite) {
+	db.mu.RLock()
+	state := db.degraded
+	db.mu.RUnlock()
+	if state == nil {
+		return
+	}
+	select {
+	case <-state.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Rebuild did not finish")
+	}
+}
+
+func TestVecLite_CorruptSidecar_FailsWithoutDegradedMode(t *testing.T) {
+	config := createCorruptSidecarDB(t, "hnsw")
+
+	if _, err := New(config); err == nil {
+		t.Fatal("Expected New to fail on corrupt graph without DegradedMode")
+	}
+}
+
+func TestVecLite_DegradedMode(t *testing.T) {
+	for _, indexType := range []string{"hnsw", "ivf"} {
+		t.Run(indexType, func(t *testing.T) {
+			config := createCorruptSidecarDB(t, indexType)
+			config.DegradedMode = true
+
+			db, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed in degraded mode: %v", err)
+			}
+			defer db.Close()
+
+			// Serving continues while (or after) the index is rebuilt
+			query := make([]float32, 8)
+			query[0] = 10
+			results, err := db.Search(query, 3)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != 3 || results[0].ID != 10 {
+				t.Errorf("Expected nearest ID 10, got %v", results)
+			}
+			if err := db.Delete(10); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			vec := make([]float32, 8)
+			vec[0] = 51
+			if err := db.Insert(1000, vec); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+
+			waitForRebuild(t, db)
+			stats := db.Stats()
+			if stats.Degraded || stats.ServingIndex != indexType {
+				t.Fatalf("Expected rebuilt %s index, got %+v", indexType, stats)
+			}
+			if stats.Size != 50 {
+				t.Errorf("Expected 50 vectors after rebuild, got %d", stats.Size)
+			}
+
+			results, err = db.Search(query, 1)
+			if err != nil {
+				t.Fatalf("Search after rebuild failed: %v", err)
+			}
+			if len(results) != 1 || results[0].ID == 10 {
+				t.Errorf("Deleted vector returned after rebuild: %v", results)
+			}
+			results, err = db.Search(vec, 1)
+			if err != nil || len(results) != 1 || results[0].ID != 1000 {
+				t.Errorf("Expected vector inserted while degraded, got %v (%v)", results, err)
+			}
+		})
+	}
+}
+
+func TestVecLite_DegradedMode_Stats(t *testing.T) {
+	config := createCorruptSidecarDB(t, "hnsw")
+	config.DegradedMode = true
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed in degraded mode: %v", err)
+	}
+
+	// Hold the rebuild before its swap by holding the write lock
+	db.mu.Lock()
+	state := db.degraded
+	db.mu.Unlock()
+	if state == nil {
+		t.Fatal("Expected database to start degraded")
+	}
+	if state.reason == nil {
+		t.Error("Expected degraded reason to be recorded")
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-state.done:
+	default:
+		t.Error("Close returned before the rebuild goroutine exited")
+	}
+}
+
+func TestVecLite_Stats(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	stats := db.Stats()
+	if stats.Size != 1 || stats.IndexType != "flat" || stats.ServingIndex != "flat" || stats.Degraded {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestVecLite_LazyLoad(t *testing.T) {
+	for _, indexType := range []string{"hnsw", "ivf"} {
+		t.Run(indexType, func(t *testing.T) {
+			config := createSidecarDB(t, indexType)
+			config.LazyLoad = true
+
+			db, err := New(config)
+			if err != nil {
+				t.Fatalf("New failed with LazyLoad: %v", err)
+			}
+			defer db.Close()
+
+			// Writes before or during the load are replayed into the loaded index
+			if err := db.Delete(10); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			vec := make([]float32, 8)
+			vec[0] = 51
+			if err := db.Insert(1000, vec); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			query := make([]float32, 8)
+			query[0] = 20
+			if results, err := db.Search(query, 1); err != nil || len(results) != 1 || results[0].ID != 20 {
+				t.Errorf("Expected nearest ID 20 while loading, got %v (%v)", results, err)
+			}
+
+			waitForReady(t, db)
+			stats := db.Stats()
+			if stats.Loading || stats.Degraded || stats.ServingIndex != indexType {
+				t.Fatalf("Expected loaded %s index, got %+v", indexType, stats)
+			}
+			if stats.Size != 50 {
+				t.Errorf("Expected 50 vectors after load, got %d", stats.Size)
+			}
+			query[0] = 10
+			if results, err := db.Search(query, 1); err != nil || len(results) != 1 || results[0].ID == 10 {
+				t.Errorf("Deleted vector returned after load: %v (%v)", results, err)
+			}
+			if results, err := db.Search(vec, 1); err != nil || len(results) != 1 || results[0].ID != 1000 {
+				t.Errorf("Expected vector inserted while loading, got %v (%v)", results, err)
+			}
+		})
+	}
+}
+
+func TestVecLite_LazyLoad_CorruptSidecar(t *testing.T) {
+	config := createCorruptSidecarDB(t, "hnsw")
+	config.LazyLoad = true
+
+	// Without DegradedMode a corrupt sidecar is still rebuilt, as New already returned
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed with LazyLoad: %v", err)
+	}
+	defer db.Close()
+
+	waitForReady(t, db)
+	if stats := db.Stats(); stats.Degraded || stats.ServingIndex != "hnsw" || stats.Size != 50 {
+		t.Errorf("Expected rebuilt hnsw index, got %+v", stats)
+	}
+}
+
+func TestVecLite_Ready(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	select {
+	case <-db.Ready():
+	default:
+		t.Error("Expected Ready to be closed without background loading")
+	}
+}
@@ -0,0 +1,69 @@
+package veclite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// DiskUsage is a breakdown of the bytes VecLite keeps on disk for one database
+type DiskUsage struct {
+	LiveData    int64 // Vector records reachable through the ID index
+	DeadData    int64 // Tombstoned or superseded records reclaimed on compaction
+	FooterIndex int64 // Persisted ID -> offset index at the end of the data file
+	Header      int64 // Format header at the start of the data file
+	Graph       int64 // HNSW graph sidecar and its journal (.graph, .graph.journal)
+	PQ          int64 // HNSW and IVF-PQ product quantization codebooks and codes (.pq, .ivfpq)
+	IVF         int64 // IVF structure sidecar (.ivf)
+	Metadata    int64 // Metadata sidecar (.meta), as of the last Close
+	Keys        int64 // String key sidecar (.keys)
+	KV          int64 // Key-value namespace sidecar (.kv)
+	Cold        int64 // Cold segments created by Demote and their manifest (.cold)
+	Quarantine  int64 // Torn records moved out of the data file on open (.torn)
+	Total       int64 // Sum of all of the above
+}
+
+// DiskUsage returns how the database files are split between live data,
+// dead (tombstoned) data, the footer index, and index sidecars
+// Uses read lock - allows concurrent reads
+func (v *VecLite) DiskUsage() (*DiskUsage, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	u, err := v.storage.Usage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage usage: %w", err)
+	}
+
+	usage := &DiskUsage{
+		LiveData:    u.LiveBytes,
+		DeadData:    u.DeadBytes,
+		FooterIndex: u.FooterBytes,
+		Header:      u.HeaderBytes,
+		Graph:       fileSize(v.config.DataPath+".graph") + fileSize(v.config.DataPath+".graph.journal"),
+		PQ:          fileSize(v.config.DataPath+".pq") + fileSize(v.config.DataPath+".ivfpq"),
+		IVF:         fileSize(v.config.DataPath + ".ivf"),
+		Metadata:    fileSize(v.config.DataPath + ".meta"),
+		Keys:        fileSize(v.keys.path),
+		KV:          fileSize(v.kv.path),
+		Cold:        fileSize(v.tiers.manifestPath),
+		Quarantine:  fileSize(v.config.DataPath + storage.QuarantineSuffix),
+	}
+	dir := filepath.Dir(v.config.DataPath)
+	for _, cold := range v.tiers.segments {
+		usage.Cold += fileSize(filepath.Join(dir, cold.name))
+	}
+	usage.Total = u.FileSize + usage.Graph + usage.PQ + usage.IVF + usage.Metadata + usage.Keys + usage.KV + usage.Cold + usage.Quarantine
+	return usage, nil
+}
+
+// fileSize returns the size of the file at path, or 0 if it does not exist
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
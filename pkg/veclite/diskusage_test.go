@@ -0,0 +1,93 @@
+package veclite
+
+import (
+	"os"
+	"testing"
+)
+
+func TestVecLite_DiskUsage(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := db.Delete(i); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+
+	recordSize := int64(26 + 128*4) // Record header with checksum, vector data
+	if usage.LiveData != 7*recordSize {
+		t.Errorf("Expected live data %d, got %d", 7*recordSize, usage.LiveData)
+	}
+	if usage.DeadData != 3*recordSize {
+		t.Errorf("Expected dead data %d, got %d", 3*recordSize, usage.DeadData)
+	}
+	if usage.Graph != 0 || usage.IVF != 0 {
+		t.Errorf("Expected no sidecars for flat index, got %+v", usage)
+	}
+	if usage.Total != usage.Header+usage.LiveData+usage.DeadData+usage.FooterIndex {
+		t.Errorf("Total %d does not add up: %+v", usage.Total, usage)
+	}
+}
+
+func TestVecLite_DiskUsage_Sidecars(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "veclite_usage_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+	defer os.Remove(tmpFile.Name() + ".graph")
+
+	config := DefaultConfig()
+	config.DataPath = tmpFile.Name()
+	config.Dimension = 8
+	config.IndexType = "hnsw"
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := uint64(1); i <= 20; i++ {
+		vec := make([]float32, 8)
+		vec[i%8] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if usage.Graph == 0 {
+		t.Error("Expected non-zero graph sidecar size after Close")
+	}
+	if usage.FooterIndex != 20*16+12 {
+		t.Errorf("Expected footer index %d, got %d", 20*16+12, usage.FooterIndex)
+	}
+	if usage.DeadData != 0 {
+		t.Errorf("Expected no dead data after compaction, got %d", usage.DeadData)
+	}
+}
@@ -0,0 +1,198 @@
+package veclite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// driftCorpusSample is the number of stored vectors the query window is compared against
+const driftCorpusSample = 1000
+
+// DriftReport compares recent queries with the stored vectors
+// A rising score means queries no longer look like the corpus: time to re-embed
+// the corpus or retrain IVF centroids
+type DriftReport struct {
+	Queries          int     // Queries in the rolling window
+	Sampled          int     // Stored vectors compared against
+	CentroidDistance float64 // L2 distance between the mean query and the mean stored vector
+	NormKS           float64 // Two-sample Kolmogorov-Smirnov statistic of query vs stored norms (0..1)
+	Score            float64 // max(NormKS, CentroidDistance relative to the mean stored norm), capped at 1
+}
+
+// driftTracker keeps a rolling window of query vectors, a cached corpus
+// reference and the last report computed from them
+type driftTracker struct {
+	mu       sync.Mutex
+	window   [][]float32 // Ring buffer of recent queries
+	next     int         // Ring position of the next query
+	queries  int         // Queries in the window (<= len(window))
+	corpus   *corpusReference
+	report   *DriftReport // Last report, served by Stats until it is due
+	recorded int          // Queries recorded since report was computed
+}
+
+// corpusReference summarizes a sample of stored vectors
+type corpusReference struct {
+	size  int       // Number of stored vectors when sampled
+	mean  []float64 // Mean sampled vector
+	norms []float64 // Sorted norms of the sampled vectors
+}
+
+// newDriftTracker creates a tracker over the last window queries (nil if window <= 0)
+func newDriftTracker(window int) *driftTracker {
+	if window <= 0 {
+		return nil
+	}
+	return &driftTracker{window: make([][]float32, window)}
+}
+
+// record adds query to the rolling window
+func (d *driftTracker) record(query []float32) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window[d.next] = append(d.window[d.next][:0], query...) // Reuse the evicted slot
+	d.next = (d.next + 1) % len(d.window)
+	d.queries = min(d.queries+1, len(d.window))
+	d.recorded++
+}
+
+// due reports whether the cached report must be recomputed: once a tenth of
+// the window was replaced by new queries since it was computed
+// Note: Assumes tracker lock is already held
+func (d *driftTracker) due() bool {
+	return d.report == nil || d.recorded >= max(len(d.window)/10, 1)
+}
+
+// Drift compares the rolling query window with a fresh sample of stored vectors
+// Returns nil if drift detection is disabled (Config.DriftWindow)
+// Uses read lock - allows concurrent reads
+func (v *VecLite) Drift() (*DriftReport, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.driftReport(true)
+}
+
+// driftReport computes the drift report, resampling the corpus if refresh is set,
+// or if it grew or shrank by more than 10% since it was last sampled
+// Without refresh, the last report is returned until the tracker says it is due
+// or the corpus is stale, so frequent Stats calls don't recompute it
+// Note: Assumes lock is already held
+func (v *VecLite) driftReport(refresh bool) (*DriftReport, error) {
+	d := v.drift
+	if d == nil {
+		return nil, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stale := d.corpus == nil || math.Abs(float64(v.index.Size()-d.corpus.size)) > float64(d.corpus.size)/10
+	if !refresh && !stale && !d.due() {
+		cached := *d.report
+		return &cached, nil
+	}
+	report, err := v.computeDrift(refresh || stale)
+	if err != nil {
+		return nil, err
+	}
+	cached := *report
+	d.report, d.recorded = &cached, 0
+	return report, nil
+}
+
+// computeDrift implements driftReport, resampling the corpus first if resample is set
+// Note: Assumes lock and tracker lock are already held
+func (v *VecLite) computeDrift(resample bool) (*DriftReport, error) {
+	d := v.drift
+	if resample {
+		corpus, err := v.sampleCorpus()
+		if err != nil {
+			return nil, err
+		}
+		d.corpus = corpus
+	}
+
+	report := &DriftReport{Queries: d.queries, Sampled: len(d.corpus.norms)}
+	if d.queries == 0 || len(d.corpus.norms) == 0 {
+		return report, nil
+	}
+
+	mean := make([]float64, v.config.Dimension)
+	norms := make([]float64, 0, d.queries)
+	for _, query := range d.window[:d.queries] {
+		var sumSquares float64
+		for i, x := range query {
+			mean[i] += float64(x) / float64(d.queries)
+			sumSquares += float64(x) * float64(x)
+		}
+		norms = append(norms, math.Sqrt(sumSquares))
+	}
+	sort.Float64s(norms)
+
+	var sumSquares float64
+	for i := range mean {
+		diff := mean[i] - d.corpus.mean[i]
+		sumSquares += diff * diff
+	}
+	report.CentroidDistance = math.Sqrt(sumSquares)
+	report.NormKS = kolmogorovSmirnov(norms, d.corpus.norms)
+
+	var corpusNorm float64
+	for _, norm := range d.corpus.norms {
+		corpusNorm += norm / float64(len(d.corpus.norms))
+	}
+	shift := 0.0
+	if corpusNorm > 0 {
+		shift = math.Min(report.CentroidDistance/corpusNorm, 1)
+	} else if report.CentroidDistance > 0 {
+		shift = 1
+	}
+	report.Score = math.Max(report.NormKS, shift)
+	return report, nil
+}
+
+// sampleCorpus reads a sample of stored vectors into a corpus reference
+// Note: Assumes lock is already held
+func (v *VecLite) sampleCorpus() (*corpusReference, error) {
+	ids, total := v.sampleIDs(driftCorpusSample)
+	corpus := &corpusReference{
+		size:  total,
+		mean:  make([]float64, v.config.Dimension),
+		norms: make([]float64, 0, len(ids)),
+	}
+	for _, id := range ids {
+		vector, err := v.index.ReadVector(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vector %d: %w", id, err)
+		}
+		var sumSquares float64
+		for i, x := range vector {
+			corpus.mean[i] += float64(x) / float64(len(ids))
+			sumSquares += float64(x) * float64(x)
+		}
+		corpus.norms = append(corpus.norms, math.Sqrt(sumSquares))
+	}
+	sort.Float64s(corpus.norms)
+	return corpus, nil
+}
+
+// kolmogorovSmirnov returns the largest distance between the empirical CDFs of two sorted samples
+func kolmogorovSmirnov(a, b []float64) float64 {
+	var i, j int
+	var maxDiff float64
+	for i < len(a) && j < len(b) {
+		x := math.Min(a[i], b[j])
+		for i < len(a) && a[i] <= x {
+			i++
+		}
+		for j < len(b) && b[j] <= x {
+			j++
+		}
+		maxDiff = math.Max(maxDiff, math.Abs(float64(i)/float64(len(a))-float64(j)/float64(len(b))))
+	}
+	return maxDiff
+}
@@ -0,0 +1,100 @@
+package veclite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVecLite_Drift(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if report, err := db.Drift(); err != nil || report != nil {
+		t.Fatalf("Expected nil report with drift detection disabled, got %v (err %v)", report, err)
+	}
+
+	db.drift = newDriftTracker(4)
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = 1
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Queries that look like the corpus
+	similar := make([]float32, 128)
+	similar[0] = 1
+	for i := 0; i < 4; i++ {
+		if _, err := db.Search(similar, 1); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	report, err := db.Drift()
+	if err != nil {
+		t.Fatalf("Drift failed: %v", err)
+	}
+	if report.Queries != 4 || report.Sampled != 10 || report.Score > 1e-9 {
+		t.Errorf("Expected no drift, got %+v", report)
+	}
+
+	// Queries that are far larger than anything stored push out the old window
+	shifted := make([]float32, 128)
+	shifted[1] = 5
+	for i := 0; i < 4; i++ {
+		if _, err := db.Search(shifted, 1); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	stats := db.Stats()
+	if stats.Drift == nil {
+		t.Fatal("Expected drift report in Stats")
+	}
+	if stats.Drift.Queries != 4 || stats.Drift.NormKS != 1 || stats.Drift.Score != 1 {
+		t.Errorf("Expected full drift, got %+v", stats.Drift)
+	}
+	if want := math.Sqrt(26); math.Abs(stats.Drift.CentroidDistance-want) > 1e-6 {
+		t.Errorf("Expected centroid distance %v, got %v", want, stats.Drift.CentroidDistance)
+	}
+
+	// Stats serves the last report until a tenth of the window was replaced
+	db.drift = newDriftTracker(20)
+	search := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := db.Search(similar, 1); err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+		}
+	}
+	search(2)
+	if queries := db.Stats().Drift.Queries; queries != 2 {
+		t.Errorf("Expected a report over 2 queries, got %d", queries)
+	}
+	search(1)
+	if queries := db.Stats().Drift.Queries; queries != 2 {
+		t.Errorf("Expected the cached report over 2 queries, got %d", queries)
+	}
+	search(1)
+	if queries := db.Stats().Drift.Queries; queries != 4 {
+		t.Errorf("Expected a new report over 4 queries, got %d", queries)
+	}
+	if report, _ := db.Drift(); report.Queries != 4 {
+		t.Errorf("Expected Drift to recompute the report, got %+v", report)
+	}
+}
+
+func TestKolmogorovSmirnov(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, 0},
+		{[]float64{1, 2}, []float64{3, 4}, 1},
+		{[]float64{1, 2, 3, 4}, []float64{3, 4}, 0.5},
+	}
+	for _, tt := range tests {
+		if got := kolmogorovSmirnov(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("kolmogorovSmirnov(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package veclite
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarName is the expvar map holding one entry per database published with
+// Config.Expvar, keyed by Config.Name
+const expvarName = "veclite"
+
+var (
+	expvarOnce sync.Once
+	expvarRoot *expvar.Map
+
+	expvarMu     sync.Mutex
+	expvarOwners = make(map[string]*VecLite) // Database currently published under each name
+)
+
+// ExpvarCounters is the value published for a database under the "veclite" expvar map
+type ExpvarCounters struct {
+	Size          int    // Number of vectors
+	Searches      uint64 // Searches since open
+	Distances     uint64 // Distance computations of all searches
+	Candidates    uint64 // Stored vectors scored as candidates by all searches
+	Reads         uint64 // Vector reads of all searches
+	CacheHits     uint64 // Vector reads served by the cache
+	BytesRead     uint64 // Bytes read from disk by all searches
+	SlowQueries   int    // Recent searches slower than Config.SlowQueryThreshold (at most 100)
+	PendingWrites int    // Insert/Delete calls waiting for or holding the write lock
+	WriteStalls   uint64 // Writes rejected with ErrWriteStall
+	Loading       bool   // Serving flat search while the index loads
+	Degraded      bool   // Serving flat search because the index failed to load
+}
+
+// expvarCounters returns the counters published for the database
+// Uses read lock - a scrape waits for a running write
+func (v *VecLite) expvarCounters() ExpvarCounters {
+	counters := ExpvarCounters{
+		Size:       v.Size(),
+		Searches:   v.profiler.searches.Load(),
+		Distances:  v.profiler.distances.Load(),
+		Candidates: v.profiler.candidates.Load(),
+		Reads:      v.profiler.reads.Load(),
+		CacheHits:  v.profiler.cacheHits.Load(),
+		BytesRead:  v.profiler.bytesRead.Load(),
+	}
+	counters.PendingWrites, counters.WriteStalls = v.writes.state()
+	v.slowQueries.mu.Lock()
+	counters.SlowQueries = len(v.slowQueries.entries)
+	v.slowQueries.mu.Unlock()
+
+	v.mu.RLock()
+	if v.degraded != nil {
+		counters.Loading = v.degraded.loading
+		counters.Degraded = !v.degraded.loading
+	}
+	v.mu.RUnlock()
+	return counters
+}
+
+// publishExpvar publishes the counters of the database under its name,
+// replacing a closed (or still open) database published under the same name
+func (v *VecLite) publishExpvar() {
+	expvarOnce.Do(func() { expvarRoot = expvar.NewMap(expvarName) })
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	expvarOwners[v.name] = v
+	expvarRoot.Set(v.name, expvar.Func(func() any { return v.expvarCounters() }))
+}
+
+// unpublishExpvar removes the counters of the database unless another database
+// has been published under its name since
+func (v *VecLite) unpublishExpvar() {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if expvarOwners[v.name] != v {
+		return
+	}
+	delete(expvarOwners, v.name)
+	expvarRoot.Delete(v.name)
+}
@@ -0,0 +1,67 @@
+package veclite
+
+import (
+	"encoding/json"
+	"expvar"
+	"path/filepath"
+	"testing"
+)
+
+// publishedCounters returns the counters published under name (nil if none)
+func publishedCounters(t *testing.T, name string) *ExpvarCounters {
+	t.Helper()
+	root, ok := expvar.Get(expvarName).(*expvar.Map)
+	if !ok {
+		return nil
+	}
+	value := root.Get(name)
+	if value == nil {
+		return nil
+	}
+	var counters ExpvarCounters
+	if err := json.Unmarshal([]byte(value.String()), &counters); err != nil {
+		t.Fatalf("Invalid published counters %s: %v", value.String(), err)
+	}
+	return &counters
+}
+
+func TestVecLite_Expvar(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "vars.db")
+	config.Dimension = 4
+	config.Name = "expvar-test"
+	config.Expvar = true
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := db.Insert(uint64(i), []float32{float32(i), 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := db.Search([]float32{1, 0, 0, 0}, 2); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	counters := publishedCounters(t, "expvar-test")
+	if counters == nil || counters.Size != 3 || counters.Searches != 1 || counters.Distances == 0 {
+		t.Errorf("Unexpected published counters: %+v", counters)
+	}
+
+	// Reopening under the same name replaces the entry; closing the old handle keeps it
+	reopened, err := New(config)
+	if err == nil {
+		db.Close()
+		if publishedCounters(t, "expvar-test") == nil {
+			t.Error("Expected the reopened database to stay published")
+		}
+		reopened.Close()
+	} else {
+		db.Close()
+	}
+	if publishedCounters(t, "expvar-test") != nil {
+		t.Error("Expected the counters to be removed on Close")
+	}
+}
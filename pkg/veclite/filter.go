@@ -0,0 +1,383 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// Filter restricts a search to vectors whose metadata has all of the given
+// key/value pairs; values must be strings, numbers or bools, or a GeoRadius or
+// GeoBox the location under the key must lie in (see GeoPoint)
+type Filter map[string]any
+
+// ErrInvalidFilter is returned for a filter with a value that can't be matched
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Query plan strategies reported by Explain
+const (
+	PlanIndex      = "index"      // No filter: the configured index answers the search
+	PlanPrefilter  = "prefilter"  // Exact search over the vectors matching the filter
+	PlanPostfilter = "postfilter" // Index search for extra candidates, then filtered
+)
+
+// defaultPrefilterSelectivity is the default Config.PrefilterSelectivity
+const defaultPrefilterSelectivity = 0.1
+
+// QueryPlan describes how a search was executed, see Explanation
+type QueryPlan struct {
+	Strategy         string  // PlanIndex, PlanPrefilter or PlanPostfilter
+	EstimatedMatches int     // Estimated vectors matching the filter, see EstimateMatches
+	Selectivity      float64 // EstimatedMatches as a fraction of the index size
+	Candidates       int     // Postfilter: results requested from the index
+	Fallback         bool    // Postfilter found too few matches and fell back to prefilter
+}
+
+// filterValue normalizes a metadata value for indexing and matching
+// Numbers become float64, as they are read back from the sidecar; other types
+// than strings, numbers and bools are not indexable
+func filterValue(value any) (any, bool) {
+	switch value.(type) {
+	case string, bool, float64:
+		return value, true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32:
+		return rv.Float(), true
+	}
+	return nil, false
+}
+
+// validate checks that every value of f can be matched
+func (f Filter) validate() error {
+	for key, value := range f {
+		if _, isGeo, err := geoAreaValue(value); isGeo {
+			if err != nil {
+				return fmt.Errorf("%w: value for key %q: %v", ErrInvalidFilter, key, err)
+			}
+			continue
+		}
+		if _, ok := filterValue(value); !ok {
+			return fmt.Errorf("%w: value for key %q must be a string, number, bool, GeoRadius or GeoBox, got %T", ErrInvalidFilter, key, value)
+		}
+	}
+	return nil
+}
+
+// exactMaxIDs is the size up to which the smallest posting of a filter is
+// intersected with the others to count its matches exactly; larger
+// intersections are estimated from the posting sizes
+const exactMaxIDs = 1024
+
+// posting holds the IDs having one metadata value
+// Its size is the exact count of the value, kept up to date by every write
+type posting struct {
+	ids map[uint64]struct{}
+}
+
+// add adds id to the posting
+func (p *posting) add(id uint64) {
+	p.ids[id] = struct{}{}
+}
+
+// remove drops id from the posting
+func (p *posting) remove(id uint64) {
+	delete(p.ids, id)
+}
+
+// postings maps metadata key -> normalized value -> IDs having that value
+type postings map[string]map[any]*posting
+
+// add indexes the indexable values of metadata under id
+func (p postings) add(id uint64, metadata Metadata) {
+	for key, value := range metadata {
+		normalized, ok := filterValue(value)
+		if !ok {
+			continue
+		}
+		values := p[key]
+		if values == nil {
+			values = make(map[any]*posting)
+			p[key] = values
+		}
+		entry := values[normalized]
+		if entry == nil {
+			entry = &posting{ids: make(map[uint64]struct{})}
+			values[normalized] = entry
+		}
+		entry.add(id)
+	}
+}
+
+// remove drops id from the postings of metadata
+func (p postings) remove(id uint64, metadata Metadata) {
+	for key, value := range metadata {
+		normalized, ok := filterValue(value)
+		if !ok {
+			continue
+		}
+		entry := p[key][normalized]
+		if entry == nil {
+			continue
+		}
+		entry.remove(id)
+		if len(entry.ids) == 0 {
+			delete(p[key], normalized)
+			if len(p[key]) == 0 {
+				delete(p, key)
+			}
+		}
+	}
+}
+
+// lookup returns the postings of each value of filter, smallest first
+// Values no ID has get an empty posting
+func (p postings) lookup(filter Filter) []*posting {
+	entries := make([]*posting, 0, len(filter))
+	for key, value := range filter {
+		normalized, _ := filterValue(value)
+		entry := p[key][normalized]
+		if entry == nil {
+			entry = &posting{}
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return len(entries[i].ids) < len(entries[j].ids) })
+	return entries
+}
+
+// estimate returns the estimated number of IDs matching filter
+// Single values and intersections with a value of at most exactMaxIDs IDs are
+// counted exactly; larger intersections are estimated from the posting sizes,
+// assuming the values are independent
+// Geo conditions are counted exactly from the locations in their area
+func (m *metadataStore) estimate(filter Filter) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filter, areas := filter.splitGeo()
+	if areas != nil {
+		return len(m.matchGeo(filter, areas))
+	}
+	entries := m.postings.lookup(filter)
+	smallest := entries[0]
+	if len(entries) == 1 {
+		return len(smallest.ids)
+	}
+	if len(smallest.ids) <= exactMaxIDs {
+		count := 0
+		for id := range smallest.ids {
+			if inAll(id, entries[1:]) {
+				count++
+			}
+		}
+		return count
+	}
+
+	estimate := float64(len(smallest.ids))
+	total := float64(len(m.entries))
+	for _, entry := range entries[1:] {
+		estimate *= float64(len(entry.ids)) / total
+	}
+	return int(estimate + 0.5)
+}
+
+// match returns the IDs matching filter in ascending order
+func (m *metadataStore) match(filter Filter) []uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	filter, areas := filter.splitGeo()
+	if areas != nil {
+		return m.matchGeo(filter, areas)
+	}
+	entries := m.postings.lookup(filter)
+	var ids []uint64
+	for id := range entries[0].ids {
+		if inAll(id, entries[1:]) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// matchGeo returns the IDs in every area matching the key/value pairs of
+// filter, in ascending order: the locations in one area are checked against
+// the other conditions
+// Note: Assumes lock is already held
+func (m *metadataStore) matchGeo(filter Filter, areas map[string]geoArea) []uint64 {
+	var firstKey string
+	for key := range areas {
+		if firstKey == "" || key < firstKey {
+			firstKey = key
+		}
+	}
+	var entries []*posting
+	if len(filter) > 0 {
+		entries = m.postings.lookup(filter)
+	}
+	ids := m.geo.match(firstKey, areas[firstKey])
+	matching := ids[:0]
+	for _, id := range ids {
+		if inAll(id, entries) && m.inAreas(id, areas) {
+			matching = append(matching, id)
+		}
+	}
+	return matching
+}
+
+// inAreas reports whether the locations of id lie in all areas
+// Note: Assumes lock is already held
+func (m *metadataStore) inAreas(id uint64, areas map[string]geoArea) bool {
+	for key, area := range areas {
+		if !m.geo.contains(key, id, area) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the metadata of id matches filter
+func (m *metadataStore) matches(id uint64, filter Filter) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	filter, areas := filter.splitGeo()
+	if areas != nil && !m.inAreas(id, areas) {
+		return false
+	}
+	return len(filter) == 0 || inAll(id, m.postings.lookup(filter))
+}
+
+// inAll reports whether id is in every posting
+func inAll(id uint64, entries []*posting) bool {
+	for _, entry := range entries {
+		if _, ok := entry.ids[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// EstimateMatches returns the estimated number of vectors matching filter, as
+// used by the query planner, without searching
+// A single key/value pair is counted exactly; combined pairs are too unless
+// each value has more than 1024 vectors, in which case the values are assumed
+// independent. Filters with geo conditions are counted exactly
+func (v *VecLite) EstimateMatches(filter Filter) (int, error) {
+	if len(filter) == 0 {
+		return v.Size(), nil
+	}
+	if err := filter.validate(); err != nil {
+		return 0, err
+	}
+	return v.metadata.estimate(filter), nil
+}
+
+// planFilter chooses how to run a search with filter: selective filters (and
+// any filter on a flat index) are searched exactly over the matching IDs, others
+// through the index with enough extra candidates to expect k matches
+// Note: Assumes read lock is already held
+func (v *VecLite) planFilter(filter Filter, k int) QueryPlan {
+	size := v.index.Size()
+	plan := QueryPlan{Strategy: PlanPrefilter, EstimatedMatches: v.metadata.estimate(filter)}
+	if size == 0 || plan.EstimatedMatches == 0 {
+		return plan
+	}
+	plan.Selectivity = float64(plan.EstimatedMatches) / float64(size)
+
+	threshold := v.config.PrefilterSelectivity
+	if threshold == 0 {
+		threshold = defaultPrefilterSelectivity
+	}
+	if v.servingIndex() == string(index.IndexTypeFlat) || plan.Selectivity <= threshold {
+		return plan
+	}
+	plan.Strategy = PlanPostfilter
+	plan.Candidates = min(size, int(float64(k)/plan.Selectivity)*2)
+	return plan
+}
+
+// searchFiltered runs a search restricted to the vectors matching filter
+// Note: Assumes read lock is already held
+func (v *VecLite) searchFiltered(ctx context.Context, query []float32, k int, filter Filter, p *profile.Profile) ([]index.SearchResult, QueryPlan, error) {
+	endStage := p.Start("plan")
+	plan := v.planFilter(filter, k)
+	endStage()
+
+	if plan.Strategy == PlanPostfilter {
+		candidates, err := v.index.SearchContext(profile.NewContext(ctx, p), query, plan.Candidates)
+		if err != nil {
+			return nil, plan, err
+		}
+		results := make([]index.SearchResult, 0, k)
+		for _, candidate := range candidates {
+			if len(results) < k && v.metadata.matches(candidate.ID, filter) {
+				results = append(results, candidate)
+			}
+		}
+		if len(results) == k {
+			return results, plan, nil
+		}
+		plan.Fallback = true // The index returned too few matches; search them exactly
+	}
+
+	defer p.Start("prefilter")()
+	results, err := v.searchIDs(ctx, query, k, v.metadata.match(filter), p)
+	return results, plan, err
+}
+
+// searchIDs returns the k vectors among ids nearest to query by exact search
+// Note: Assumes read lock is already held
+func (v *VecLite) searchIDs(ctx context.Context, query []float32, k int, ids []uint64, p *profile.Profile) ([]index.SearchResult, error) {
+	results := make([]index.SearchResult, 0, len(ids))
+	for i, id := range ids {
+		if i%256 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		vec, err := v.storage.ReadVectorProfiled(id, p)
+		if err != nil {
+			continue // Metadata of a vector missing from storage
+		}
+		p.AddDistances(1)
+		p.AddCandidates(1)
+		vecCopy := make([]float32, len(vec))
+		copy(vecCopy, vec)
+		results = append(results, index.SearchResult{ID: id, Distance: vector.L2Distance(query, vec), Vector: vecCopy})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].ID < results[j].ID
+	})
+	if k < len(results) {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// FilterIDs returns the IDs of the vectors whose metadata matches filter, in
+// ascending order, including cold vectors
+func (v *VecLite) FilterIDs(filter Filter) ([]uint64, error) {
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("%w: filter must not be empty", ErrInvalidFilter)
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return v.metadata.match(filter), nil
+}
@@ -0,0 +1,235 @@
+package veclite
+
+import (
+	"os"
+	"testing"
+)
+
+// insertTagged inserts n vectors with IDs 1..n; even IDs get {"parity": "even"}
+// and every tenth ID also gets {"rare": true}
+func insertTagged(t *testing.T, db *VecLite, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		vector := make([]float32, 128)
+		for j := range vector {
+			vector[j] = float32(i) + float32(j)*0.001
+		}
+		metadata := Metadata{"n": i}
+		if i%2 == 0 {
+			metadata["parity"] = "even"
+		}
+		if i%10 == 0 {
+			metadata["rare"] = true
+		}
+		if err := db.InsertWithMetadata(uint64(i), vector, metadata); err != nil {
+			t.Fatalf("InsertWithMetadata failed: %v", err)
+		}
+	}
+}
+
+// checkMatches fails if a result does not match filter
+func checkMatches(t *testing.T, db *VecLite, results []SearchResult, filter Filter) {
+	t.Helper()
+	for _, result := range results {
+		if !db.metadata.matches(result.ID, filter) {
+			t.Errorf("Result %d does not match %v", result.ID, filter)
+		}
+	}
+}
+
+func TestFilterValue(t *testing.T) {
+	for _, value := range []any{3, int64(3), uint8(3), float32(3), 3.0} {
+		if normalized, ok := filterValue(value); !ok || normalized != 3.0 {
+			t.Errorf("filterValue(%T) = %v, %v", value, normalized, ok)
+		}
+	}
+	if _, ok := filterValue([]any{1}); ok {
+		t.Error("Slices should not be indexable")
+	}
+	if err := (Filter{"tags": []string{"a"}}).validate(); err == nil {
+		t.Error("Expected an error for a slice filter value")
+	}
+}
+
+func TestPostings(t *testing.T) {
+	p := make(postings)
+	p.add(1, Metadata{"tag": "a", "n": 1, "list": []any{1}})
+	p.add(2, Metadata{"tag": "a"})
+	if len(p["tag"]["a"].ids) != 2 || len(p["n"][1.0].ids) != 1 || p["list"] != nil {
+		t.Fatalf("Unexpected postings: %v", p)
+	}
+	p.remove(1, Metadata{"tag": "a", "n": 1})
+	p.remove(2, Metadata{"tag": "a"})
+	if len(p) != 0 {
+		t.Errorf("Expected empty postings, got %v", p)
+	}
+}
+
+func TestMetadataStore_Estimate(t *testing.T) {
+	m, err := openMetadataStore(t.TempDir()+"/test.meta", 0)
+	if err != nil {
+		t.Fatalf("openMetadataStore failed: %v", err)
+	}
+	// 8000 vectors: half "a", a quarter "x" (of which half are "a")
+	for id := uint64(0); id < 8000; id++ {
+		metadata := Metadata{"group": "b"}
+		if id%2 == 0 {
+			metadata["group"] = "a"
+		}
+		if id%8 < 2 {
+			metadata["tag"] = "x"
+		}
+		if err := m.Set(id, metadata, id+1); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	if n := m.estimate(Filter{"group": "a"}); n != 4000 {
+		t.Errorf("Expected exact count 4000 for a single value, got %d", n)
+	}
+	// The values are independent: the intersection is estimated at 1000
+	if n := m.estimate(Filter{"group": "a", "tag": "x"}); n != 1000 {
+		t.Errorf("Expected 1000 for the intersection, got %d", n)
+	}
+
+	// Deletes update the counts right away
+	for id := uint64(0); id < 7000; id++ {
+		if err := m.Delete(id, 8001+id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if n := m.estimate(Filter{"group": "a"}); n != 500 {
+		t.Errorf("Expected exact count 500 after deletes, got %d", n)
+	}
+	if n := m.estimate(Filter{"group": "a", "tag": "x"}); n != 125 {
+		t.Errorf("Expected exact count 125 for a small value, got %d", n)
+	}
+}
+
+func TestEstimateMatches(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+	insertTagged(t, db, 100)
+
+	if n, err := db.EstimateMatches(Filter{"rare": true}); err != nil || n != 10 {
+		t.Errorf("Expected 10 rare vectors, got %d, %v", n, err)
+	}
+	if n, err := db.EstimateMatches(Filter{"rare": true, "parity": "even"}); err != nil || n != 10 {
+		t.Errorf("Expected 10 rare even vectors, got %d, %v", n, err)
+	}
+	if n, err := db.EstimateMatches(nil); err != nil || n != 100 {
+		t.Errorf("Expected all 100 vectors without a filter, got %d, %v", n, err)
+	}
+	if _, err := db.EstimateMatches(Filter{"rare": []int{1}}); err == nil {
+		t.Error("Expected an error for an invalid filter value")
+	}
+}
+
+func TestSearch_Filter(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		defer os.Remove(db.config.DataPath + ".meta")
+		insertTagged(t, db, 100)
+
+		query := make([]float32, 128)
+		for j := range query {
+			query[j] = 50
+		}
+		opts := DefaultSearchOptions()
+		opts.Filter = Filter{"parity": "even", "rare": true}
+		results, err := db.SearchWithOptions(query, 3, opts)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 3 || results[0].ID != 50 {
+			t.Fatalf("Expected 3 results starting with 50, got %+v", results)
+		}
+		checkMatches(t, db, results, opts.Filter)
+
+		opts.Filter = Filter{"parity": "odd"}
+		if results, err := db.SearchWithOptions(query, 3, opts); err != nil || len(results) != 0 {
+			t.Errorf("Expected no results for an unmatched filter, got %v, %v", results, err)
+		}
+	})
+}
+
+func TestExplain_Plan(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+	insertTagged(t, db, 200)
+	query := make([]float32, 128)
+
+	opts := DefaultSearchOptions()
+	explain, err := db.Explain(query, 5, opts)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.Plan.Strategy != PlanIndex {
+		t.Errorf("Expected index plan without a filter, got %+v", explain.Plan)
+	}
+
+	// 10% of the vectors match: searched exactly
+	opts.Filter = Filter{"rare": true}
+	explain, err = db.Explain(query, 5, opts)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.Plan.Strategy != PlanPrefilter || explain.Plan.EstimatedMatches != 20 || explain.Counters.Distances != 20 {
+		t.Errorf("Expected prefilter over 20 vectors, got %+v (%+v)", explain.Plan, explain.Counters)
+	}
+	checkMatches(t, db, explain.Results, opts.Filter)
+
+	// 50% match: index search with twice the expected candidates
+	opts.Filter = Filter{"parity": "even"}
+	explain, err = db.Explain(query, 5, opts)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.Plan.Strategy != PlanPostfilter || explain.Plan.Candidates != 20 || explain.Plan.Fallback {
+		t.Errorf("Expected postfilter with 20 candidates, got %+v", explain.Plan)
+	}
+	if len(explain.Results) != 5 {
+		t.Errorf("Expected 5 results, got %d", len(explain.Results))
+	}
+	checkMatches(t, db, explain.Results, opts.Filter)
+
+	// More results than EfSearch can return: falls back to exact search
+	explain, err = db.Explain(query, 40, opts)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if !explain.Plan.Fallback || len(explain.Results) != 40 || explain.Results[0].ID != 2 {
+		t.Errorf("Expected fallback to 40 exact results, got %+v with %d results", explain.Plan, len(explain.Results))
+	}
+	checkMatches(t, db, explain.Results, opts.Filter)
+}
+
+func TestFilter_Persistence(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+	insertTagged(t, db, 20)
+	if err := db.Delete(10); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := New(db.config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer reopened.Close()
+
+	// Numbers are float64 after reload but still match int filters
+	if ids := reopened.metadata.match(Filter{"n": 7}); len(ids) != 1 || ids[0] != 7 {
+		t.Errorf("Expected ID 7, got %v", ids)
+	}
+	if ids := reopened.metadata.match(Filter{"rare": true}); len(ids) != 1 || ids[0] != 20 {
+		t.Errorf("Expected only ID 20 after deleting 10, got %v", ids)
+	}
+}
@@ -0,0 +1,96 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrPrecisionLoss is returned for float64 values that float32 can't
+// represent exactly when Config.StrictFloat64 is set
+var ErrPrecisionLoss = errors.New("float64 value loses precision as float32")
+
+// Float32 converts a float64 vector to the float32 values VecLite stores,
+// rounding each to the nearest float32
+// Values beyond the float32 range are rejected; with strict, so is any value
+// that is not exactly representable (ErrPrecisionLoss). NaNs are kept
+func Float32(vector []float64, strict bool) ([]float32, error) {
+	converted := make([]float32, len(vector))
+	for i, value := range vector {
+		f := float32(value)
+		switch {
+		case math.IsInf(float64(f), 0) && !math.IsInf(value, 0):
+			return nil, fmt.Errorf("value %g at dimension %d is out of float32 range", value, i)
+		case strict && float64(f) != value && !math.IsNaN(value):
+			return nil, fmt.Errorf("%w: %g at dimension %d", ErrPrecisionLoss, value, i)
+		}
+		converted[i] = f
+	}
+	return converted, nil
+}
+
+// Float64 converts a float32 vector, e.g. from Get or a SearchResult, to float64
+func Float64(vector []float32) []float64 {
+	converted := make([]float64, len(vector))
+	for i, value := range vector {
+		converted[i] = float64(value)
+	}
+	return converted
+}
+
+// InsertFloat64 is Insert with a float64 vector, converted as by Float32
+// under Config.StrictFloat64
+func (v *VecLite) InsertFloat64(id uint64, vector []float64) error {
+	converted, err := Float32(vector, v.config.StrictFloat64)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	return v.Insert(id, converted)
+}
+
+// UpsertFloat64 is Upsert with a float64 vector, see InsertFloat64
+func (v *VecLite) UpsertFloat64(id uint64, vector []float64) error {
+	converted, err := Float32(vector, v.config.StrictFloat64)
+	if err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+	return v.Upsert(id, converted)
+}
+
+// InsertFloat64WithMetadata is InsertWithMetadata with a float64 vector, see InsertFloat64
+func (v *VecLite) InsertFloat64WithMetadata(id uint64, vector []float64, metadata Metadata) error {
+	converted, err := Float32(vector, v.config.StrictFloat64)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	return v.InsertWithMetadata(id, converted, metadata)
+}
+
+// SearchFloat64 is Search with a float64 query, converted as by Float32
+// under Config.StrictFloat64
+func (v *VecLite) SearchFloat64(query []float64, k int) ([]SearchResult, error) {
+	converted, err := Float32(query, v.config.StrictFloat64)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return v.Search(converted, k)
+}
+
+// SearchFloat64WithOptionsContext is SearchWithOptionsContext with a float64 query, see SearchFloat64
+func (v *VecLite) SearchFloat64WithOptionsContext(ctx context.Context, query []float64, k int, opts SearchOptions) ([]SearchResult, error) {
+	converted, err := Float32(query, v.config.StrictFloat64)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return v.SearchWithOptionsContext(ctx, converted, k, opts)
+}
+
+// GetFloat64 is Get returning the vector as float64
+func (v *VecLite) GetFloat64(id uint64) ([]float64, error) {
+	vector, err := v.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return Float64(vector), nil
+}
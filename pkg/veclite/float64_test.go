@@ -0,0 +1,54 @@
+package veclite
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFloat32(t *testing.T) {
+	exact := []float64{1, -0.5, 0.25, math.NaN()}
+	if converted, err := Float32(exact, true); err != nil || converted[1] != -0.5 || !math.IsNaN(float64(converted[3])) {
+		t.Errorf("Expected exact values to convert in strict mode, got %v (%v)", converted, err)
+	}
+
+	if _, err := Float32([]float64{0.1}, true); !errors.Is(err, ErrPrecisionLoss) {
+		t.Errorf("Expected ErrPrecisionLoss for 0.1 in strict mode, got %v", err)
+	}
+	if converted, err := Float32([]float64{0.1}, false); err != nil || converted[0] != float32(0.1) {
+		t.Errorf("Expected 0.1 to round without strict mode, got %v (%v)", converted, err)
+	}
+	if _, err := Float32([]float64{1e300}, false); err == nil {
+		t.Error("Expected an out of range value to be rejected")
+	}
+}
+
+func TestVecLite_Float64(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		vec := make([]float64, 128)
+		vec[0] = float64(i) + 0.1
+		if err := db.InsertFloat64(uint64(i), vec); err != nil {
+			t.Fatalf("InsertFloat64 failed: %v", err)
+		}
+	}
+	query := make([]float64, 128)
+	query[0] = 2.1
+	results, err := db.SearchFloat64(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 2 {
+		t.Fatalf("Expected vector 2, got %+v (%v)", results, err)
+	}
+	if vec, err := db.GetFloat64(2); err != nil || vec[0] != float64(float32(2.1)) {
+		t.Errorf("Expected the stored float32 value as float64, got %v (%v)", vec, err)
+	}
+
+	db.config.StrictFloat64 = true
+	if _, err := db.SearchFloat64(query, 1); !errors.Is(err, ErrPrecisionLoss) {
+		t.Errorf("Expected strict mode to reject 2.1, got %v", err)
+	}
+	if err := db.InsertFloat64(4, query); !errors.Is(err, ErrPrecisionLoss) {
+		t.Errorf("Expected strict mode to reject the insert, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GeoPoint is a location in metadata, stored as {"lat": ..., "lon": ...} in
+// degrees; every metadata value of that form is indexed for GeoRadius and
+// GeoBox filters (read back from disk it is a map, like other structs)
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoRadius is a Filter value matching locations within Meters of a center
+// (great-circle distance)
+type GeoRadius struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Meters float64 `json:"radius_m"`
+}
+
+// GeoBox is a Filter value matching locations inside a bounding box, edges
+// included; a box with MinLon > MaxLon crosses the antimeridian
+type GeoBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// Geo buckets are geohash cells of geoLonBits+geoLatBits bits (5 geohash
+// characters, ~4.9 x 4.9 km at the equator); areas covering more than
+// geoMaxCells cells are matched by checking every indexed location instead
+const (
+	geoLonBits   = 13
+	geoLatBits   = 12
+	geoMaxCells  = 1024
+	earthRadiusM = 6371008.8 // Mean Earth radius in meters
+)
+
+// geoArea is a parsed GeoRadius or GeoBox filter value
+type geoArea struct {
+	box    GeoBox
+	radius *GeoRadius // Set for radius filters; box is its bounding box
+}
+
+// geoPointValue returns the location held by a metadata value
+func geoPointValue(value any) (GeoPoint, bool) {
+	var point GeoPoint
+	switch v := value.(type) {
+	case GeoPoint:
+		point = v
+	case *GeoPoint:
+		if v == nil {
+			return GeoPoint{}, false
+		}
+		point = *v
+	case map[string]any:
+		if len(v) != 2 || !numberFields(v, &point.Lat, "lat", &point.Lon, "lon") {
+			return GeoPoint{}, false
+		}
+	default:
+		return GeoPoint{}, false
+	}
+	if !validLatLon(point.Lat, point.Lon) {
+		return GeoPoint{}, false
+	}
+	return point, true
+}
+
+// numberFields reads numeric fields of m into targets, given as pointer/name pairs
+func numberFields(m map[string]any, fields ...any) bool {
+	for i := 0; i < len(fields); i += 2 {
+		number, ok := filterValue(m[fields[i+1].(string)])
+		value, isFloat := number.(float64)
+		if !ok || !isFloat {
+			return false
+		}
+		*fields[i].(*float64) = value
+	}
+	return true
+}
+
+// validLatLon reports whether lat and lon are valid coordinates in degrees
+func validLatLon(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// geoAreaValue parses a GeoRadius or GeoBox filter value, also in the map form
+// it takes in JSON (e.g. in the query log); ok is false for other values
+func geoAreaValue(value any) (area geoArea, ok bool, err error) {
+	switch v := value.(type) {
+	case GeoRadius:
+		return radiusArea(v)
+	case *GeoRadius:
+		if v != nil {
+			return radiusArea(*v)
+		}
+	case GeoBox:
+		return boxArea(v)
+	case *GeoBox:
+		if v != nil {
+			return boxArea(*v)
+		}
+	case map[string]any:
+		var radius GeoRadius
+		if len(v) == 3 && numberFields(v, &radius.Lat, "lat", &radius.Lon, "lon", &radius.Meters, "radius_m") {
+			return radiusArea(radius)
+		}
+		var box GeoBox
+		if len(v) == 4 && numberFields(v, &box.MinLat, "min_lat", &box.MinLon, "min_lon", &box.MaxLat, "max_lat", &box.MaxLon, "max_lon") {
+			return boxArea(box)
+		}
+	}
+	return geoArea{}, false, nil
+}
+
+// radiusArea returns the area of a GeoRadius with its bounding box
+func radiusArea(r GeoRadius) (geoArea, bool, error) {
+	if !validLatLon(r.Lat, r.Lon) {
+		return geoArea{}, true, fmt.Errorf("invalid geo radius center (%g, %g)", r.Lat, r.Lon)
+	}
+	if r.Meters <= 0 || math.IsNaN(r.Meters) {
+		return geoArea{}, true, errors.New("geo radius must be greater than 0")
+	}
+
+	angle := r.Meters / earthRadiusM
+	dLat := angle * 180 / math.Pi
+	box := GeoBox{MinLat: r.Lat - dLat, MaxLat: r.Lat + dLat, MinLon: -180, MaxLon: 180}
+	if box.MinLat > -90 && box.MaxLat < 90 && angle < math.Pi/2 {
+		// Longitude half-width of the circle, which is widest at the latitude
+		// where its meridians touch it rather than at the center
+		dLon := math.Asin(math.Sin(angle)/math.Cos(r.Lat*math.Pi/180)) * 180 / math.Pi
+		box.MinLon, box.MaxLon = r.Lon-dLon, r.Lon+dLon
+		if box.MinLon < -180 {
+			box.MinLon += 360
+		}
+		if box.MaxLon > 180 {
+			box.MaxLon -= 360
+		}
+	}
+	box.MinLat, box.MaxLat = max(box.MinLat, -90), min(box.MaxLat, 90)
+	return geoArea{box: box, radius: &r}, true, nil
+}
+
+// boxArea returns the area of a GeoBox
+func boxArea(b GeoBox) (geoArea, bool, error) {
+	if !validLatLon(b.MinLat, b.MinLon) || !validLatLon(b.MaxLat, b.MaxLon) || b.MinLat > b.MaxLat {
+		return geoArea{}, true, fmt.Errorf("invalid geo box (%g, %g)-(%g, %g)", b.MinLat, b.MinLon, b.MaxLat, b.MaxLon)
+	}
+	return geoArea{box: b}, true, nil
+}
+
+// contains reports whether point lies in the area
+func (a geoArea) contains(point GeoPoint) bool {
+	if a.radius != nil {
+		return haversine(a.radius.Lat, a.radius.Lon, point.Lat, point.Lon) <= a.radius.Meters
+	}
+	if point.Lat < a.box.MinLat || point.Lat > a.box.MaxLat {
+		return false
+	}
+	if a.box.MinLon <= a.box.MaxLon {
+		return point.Lon >= a.box.MinLon && point.Lon <= a.box.MaxLon
+	}
+	return point.Lon >= a.box.MinLon || point.Lon <= a.box.MaxLon
+}
+
+// haversine returns the great-circle distance in meters between two locations
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// geoCellIndex returns the row (latitude) or column (longitude) of the cell
+// holding a coordinate in [-limit, limit]
+func geoCellIndex(coordinate, limit float64, bits int) uint32 {
+	cells := uint32(1) << bits
+	index := uint32((coordinate + limit) / (2 * limit) * float64(cells))
+	return min(index, cells-1)
+}
+
+// geoCell returns the geohash of the cell at row latIndex and column lonIndex:
+// longitude and latitude bits interleaved, starting with longitude
+func geoCell(latIndex, lonIndex uint32) uint64 {
+	var hash uint64
+	for i := geoLonBits - 1; i >= 0; i-- {
+		hash = hash<<1 | uint64(lonIndex>>i&1)
+		if i > 0 {
+			hash = hash<<1 | uint64(latIndex>>(i-1)&1)
+		}
+	}
+	return hash
+}
+
+// geohash returns the geohash cell of point
+func geohash(point GeoPoint) uint64 {
+	return geoCell(geoCellIndex(point.Lat, 90, geoLatBits), geoCellIndex(point.Lon, 180, geoLonBits))
+}
+
+// cells returns the geohash cells covering the bounding box of the area, or
+// false if there are more than geoMaxCells
+func (a geoArea) cells() ([]uint64, bool) {
+	minRow, maxRow := geoCellIndex(a.box.MinLat, 90, geoLatBits), geoCellIndex(a.box.MaxLat, 90, geoLatBits)
+	minCol, maxCol := geoCellIndex(a.box.MinLon, 180, geoLonBits), geoCellIndex(a.box.MaxLon, 180, geoLonBits)
+	columns := [][2]uint32{{minCol, maxCol}}
+	if a.box.MinLon > a.box.MaxLon { // Crosses the antimeridian
+		columns = [][2]uint32{{minCol, 1<<geoLonBits - 1}, {0, maxCol}}
+	}
+
+	count := 0
+	for _, span := range columns {
+		count += int(span[1]-span[0]+1) * int(maxRow-minRow+1)
+	}
+	if count > geoMaxCells {
+		return nil, false
+	}
+	cells := make([]uint64, 0, count)
+	for _, span := range columns {
+		for col := span[0]; col <= span[1]; col++ {
+			for row := minRow; row <= maxRow; row++ {
+				cells = append(cells, geoCell(row, col))
+			}
+		}
+	}
+	return cells, true
+}
+
+// geoIndex holds the locations in metadata, bucketed by geohash cell
+type geoIndex struct {
+	points  map[string]map[uint64]GeoPoint            // Metadata key -> ID -> location
+	buckets map[string]map[uint64]map[uint64]struct{} // Metadata key -> cell -> IDs
+}
+
+// newGeoIndex creates an empty geo index
+func newGeoIndex() *geoIndex {
+	return &geoIndex{points: make(map[string]map[uint64]GeoPoint), buckets: make(map[string]map[uint64]map[uint64]struct{})}
+}
+
+// add indexes the locations of metadata under id
+func (g *geoIndex) add(id uint64, metadata Metadata) {
+	for key, value := range metadata {
+		point, ok := geoPointValue(value)
+		if !ok {
+			continue
+		}
+		if g.points[key] == nil {
+			g.points[key] = make(map[uint64]GeoPoint)
+			g.buckets[key] = make(map[uint64]map[uint64]struct{})
+		}
+		g.points[key][id] = point
+		cell := geohash(point)
+		if g.buckets[key][cell] == nil {
+			g.buckets[key][cell] = make(map[uint64]struct{})
+		}
+		g.buckets[key][cell][id] = struct{}{}
+	}
+}
+
+// remove drops the locations of metadata under id
+func (g *geoIndex) remove(id uint64, metadata Metadata) {
+	for key, value := range metadata {
+		point, ok := geoPointValue(value)
+		if !ok || g.points[key] == nil {
+			continue
+		}
+		delete(g.points[key], id)
+		cell := geohash(point)
+		delete(g.buckets[key][cell], id)
+		if len(g.buckets[key][cell]) == 0 {
+			delete(g.buckets[key], cell)
+		}
+		if len(g.points[key]) == 0 {
+			delete(g.points, key)
+			delete(g.buckets, key)
+		}
+	}
+}
+
+// contains reports whether the location of id under key lies in area
+func (g *geoIndex) contains(key string, id uint64, area geoArea) bool {
+	point, ok := g.points[key][id]
+	return ok && area.contains(point)
+}
+
+// match returns the IDs whose location under key lies in area, in ascending order
+func (g *geoIndex) match(key string, area geoArea) []uint64 {
+	var ids []uint64
+	if cells, ok := area.cells(); ok {
+		for _, cell := range cells {
+			for id := range g.buckets[key][cell] {
+				if area.contains(g.points[key][id]) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	} else {
+		for id, point := range g.points[key] {
+			if area.contains(point) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// splitGeo separates the geo conditions of filter from its key/value pairs
+// Returns filter itself if it has no geo conditions
+// Note: the filter must be valid (see Filter.validate)
+func (f Filter) splitGeo() (Filter, map[string]geoArea) {
+	var areas map[string]geoArea
+	for key, value := range f {
+		if area, ok, _ := geoAreaValue(value); ok {
+			if areas == nil {
+				areas = make(map[string]geoArea)
+			}
+			areas[key] = area
+		}
+	}
+	if areas == nil {
+		return f, nil
+	}
+	equal := make(Filter, len(f)-len(areas))
+	for key, value := range f {
+		if _, isGeo := areas[key]; !isGeo {
+			equal[key] = value
+		}
+	}
+	return equal, areas
+}
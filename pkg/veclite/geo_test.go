@@ -0,0 +1,191 @@
+package veclite
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// geohashString formats a geohash cell in the geohash base32 alphabet
+func geohashString(cell uint64) string {
+	const alphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+	chars := make([]byte, (geoLonBits+geoLatBits)/5)
+	for i := len(chars) - 1; i >= 0; i-- {
+		chars[i] = alphabet[cell&31]
+		cell >>= 5
+	}
+	return string(chars)
+}
+
+func TestGeohash(t *testing.T) {
+	if hash := geohashString(geohash(GeoPoint{Lat: 57.64911, Lon: 10.40744})); hash != "u4pru" {
+		t.Errorf("Expected geohash u4pru, got %s", hash)
+	}
+	if hash := geohashString(geohash(GeoPoint{Lat: -90, Lon: -180})); hash != "00000" {
+		t.Errorf("Expected geohash 00000 for the south-west corner, got %s", hash)
+	}
+	if hash := geohashString(geohash(GeoPoint{Lat: 90, Lon: 180})); hash != "zzzzz" {
+		t.Errorf("Expected geohash zzzzz for the north-east corner, got %s", hash)
+	}
+}
+
+func TestGeoArea_CellsCoverArea(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	areas := []any{
+		GeoRadius{Lat: 52.52, Lon: 13.405, Meters: 20000},
+		GeoRadius{Lat: 64.1, Lon: 179.9, Meters: 30000}, // Crosses the antimeridian
+		GeoBox{MinLat: -17, MinLon: 179, MaxLat: -16.5, MaxLon: -179.5},
+	}
+	for _, value := range areas {
+		area, ok, err := geoAreaValue(value)
+		if !ok || err != nil {
+			t.Fatalf("geoAreaValue(%v) = %v, %v", value, ok, err)
+		}
+		cells, ok := area.cells()
+		if !ok {
+			t.Fatalf("Expected %v to be covered by few cells", value)
+		}
+		covered := make(map[uint64]bool, len(cells))
+		for _, cell := range cells {
+			covered[cell] = true
+		}
+		inside := 0
+		for i := 0; i < 20000; i++ {
+			point := GeoPoint{Lat: area.box.MinLat - 1 + rng.Float64()*(area.box.MaxLat-area.box.MinLat+2), Lon: rng.Float64()*360 - 180}
+			if !area.contains(point) {
+				continue
+			}
+			inside++
+			if !covered[geohash(point)] {
+				t.Errorf("%v contains %v outside its cells", value, point)
+				break
+			}
+		}
+		if inside == 0 {
+			t.Errorf("Expected random points inside %v", value)
+		}
+	}
+
+	wide, _, _ := geoAreaValue(GeoRadius{Lat: 0, Lon: 0, Meters: 2000000})
+	if _, ok := wide.cells(); ok {
+		t.Errorf("Expected a 2000 km radius to be matched without cells")
+	}
+}
+
+func TestFilter_ValidateGeo(t *testing.T) {
+	valid := []Filter{
+		{"loc": GeoRadius{Lat: 1, Lon: 2, Meters: 10}},
+		{"loc": &GeoBox{MinLat: 1, MinLon: 2, MaxLat: 3, MaxLon: 4}},
+		{"loc": map[string]any{"lat": 1.0, "lon": 2.0, "radius_m": 10.0}}, // As decoded from JSON
+	}
+	for _, filter := range valid {
+		if err := filter.validate(); err != nil {
+			t.Errorf("Expected %v to be valid, got %v", filter, err)
+		}
+	}
+	invalid := []Filter{
+		{"loc": GeoRadius{Lat: 91, Lon: 0, Meters: 10}},
+		{"loc": GeoRadius{Lat: 0, Lon: 0}},
+		{"loc": GeoBox{MinLat: 3, MaxLat: 1}},
+		{"loc": map[string]any{"lat": 1.0}},
+	}
+	for _, filter := range invalid {
+		if err := filter.validate(); err == nil {
+			t.Errorf("Expected %v to be invalid", filter)
+		}
+	}
+}
+
+func TestVecLite_GeoFilter(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		// 400 places on a 20 x 20 grid around Berlin, 0.01 degrees apart;
+		// every third one is a cafe
+		center := GeoPoint{Lat: 52.52, Lon: 13.405}
+		locations := make(map[uint64]GeoPoint)
+		for i := 0; i < 400; i++ {
+			id := uint64(i + 1)
+			location := GeoPoint{Lat: center.Lat + float64(i/20-10)*0.01, Lon: center.Lon + float64(i%20-10)*0.01}
+			locations[id] = location
+			metadata := Metadata{"location": location, "kind": "shop"}
+			if i%3 == 0 {
+				metadata["kind"] = "cafe"
+			}
+			vector := make([]float32, 128)
+			for j := range vector {
+				vector[j] = float32(i) + float32(j)*0.001
+			}
+			if err := db.InsertWithMetadata(id, vector, metadata); err != nil {
+				t.Fatalf("InsertWithMetadata failed: %v", err)
+			}
+		}
+
+		radius := GeoRadius{Lat: center.Lat, Lon: center.Lon, Meters: 4000}
+		near := 0
+		for _, location := range locations {
+			if haversine(center.Lat, center.Lon, location.Lat, location.Lon) <= radius.Meters {
+				near++
+			}
+		}
+		filter := Filter{"location": radius}
+		if count, err := db.EstimateMatches(filter); err != nil || count != near {
+			t.Errorf("Expected %d places within 4 km, estimated %d (%v)", near, count, err)
+		}
+
+		opts := DefaultSearchOptions()
+		opts.Filter = Filter{"location": radius, "kind": "cafe"}
+		results, err := db.SearchWithOptions(make([]float32, 128), 10, opts)
+		if err != nil {
+			t.Fatalf("Geo search failed: %v", err)
+		}
+		if len(results) != 10 {
+			t.Fatalf("Expected 10 nearby cafes, got %d", len(results))
+		}
+		checkMatches(t, db, results, opts.Filter)
+		for _, result := range results {
+			location := locations[result.ID]
+			if haversine(center.Lat, center.Lon, location.Lat, location.Lon) > radius.Meters || (result.ID-1)%3 != 0 {
+				t.Errorf("Result %d at %v is not a cafe within the radius", result.ID, location)
+			}
+		}
+
+		// The box covers the north-east quarter of the grid
+		box := Filter{"location": GeoBox{MinLat: center.Lat - 0.0001, MinLon: center.Lon - 0.0001, MaxLat: 60, MaxLon: 20}}
+		ids, err := db.FilterIDs(box)
+		if err != nil || len(ids) != 100 {
+			t.Errorf("Expected 100 places in the box, got %d (%v)", len(ids), err)
+		}
+	})
+}
+
+func TestVecLite_GeoFilterPersists(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	if err := db.InsertWithMetadata(1, make([]float32, 128), Metadata{"at": GeoPoint{Lat: -33.87, Lon: 151.21}}); err != nil {
+		t.Fatalf("InsertWithMetadata failed: %v", err)
+	}
+	if err := db.InsertWithMetadata(2, make([]float32, 128), Metadata{"at": map[string]any{"lat": 51.5, "lon": -0.12}}); err != nil {
+		t.Fatalf("InsertWithMetadata failed: %v", err)
+	}
+	config := *db.config
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reopened, err := New(&config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	ids, err := reopened.FilterIDs(Filter{"at": GeoRadius{Lat: -33.86, Lon: 151.2, Meters: 5000}})
+	if err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("Expected vector 1 near Sydney after reopening, got %v (%v)", ids, err)
+	}
+	if err := reopened.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ids, _ := reopened.FilterIDs(Filter{"at": GeoBox{MinLat: -90, MinLon: -180, MaxLat: 90, MaxLon: 180}}); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("Expected only vector 2 indexed after the delete, got %v", ids)
+	}
+}
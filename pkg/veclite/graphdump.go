@@ -0,0 +1,41 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/monishSR/veclite/internal/index/hnsw"
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// GraphTopology is the structure of an HNSW graph: entry point, node levels and
+// per-level neighbor lists
+type GraphTopology = hnsw.Topology
+
+// GraphNode is one node of a GraphTopology
+type GraphNode = hnsw.TopologyNode
+
+// DumpGraph loads the HNSW graph of the database at config.DataPath and returns
+// its topology for offline analysis
+// The database must not be open; its files are left untouched
+func DumpGraph(config *Config) (*GraphTopology, error) {
+	if config.Dimension <= 0 {
+		return nil, errors.New("dimension must be greater than 0")
+	}
+
+	store, err := storage.NewStorage(config.DataPath, config.Dimension, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	if err := store.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	defer store.Discard()
+
+	graph, err := hnsw.OpenHNSWIndex(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load graph: %w", err)
+	}
+	topology := graph.Topology()
+	return &topology, nil
+}
@@ -0,0 +1,81 @@
+package veclite
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDumpGraph(t *testing.T) {
+	config := createVerifyDB(t)
+	before, err := os.ReadFile(config.DataPath)
+	if err != nil {
+		t.Fatalf("Failed to read data file: %v", err)
+	}
+
+	topology, err := DumpGraph(config)
+	if err != nil {
+		t.Fatalf("DumpGraph failed: %v", err)
+	}
+	if len(topology.Nodes) != 20 || topology.M != 8 {
+		t.Fatalf("Expected 20 nodes with M=8, got %d nodes with M=%d", len(topology.Nodes), topology.M)
+	}
+	found := false
+	for _, node := range topology.Nodes {
+		found = found || node.ID == topology.EntryPoint
+		if node.Level > topology.MaxLevel {
+			t.Errorf("Node %d level %d exceeds max level %d", node.ID, node.Level, topology.MaxLevel)
+		}
+	}
+	if !found {
+		t.Errorf("Entry point %d is not a node", topology.EntryPoint)
+	}
+
+	after, err := os.ReadFile(config.DataPath)
+	if err != nil {
+		t.Fatalf("Failed to read data file: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("DumpGraph should not modify the data file")
+	}
+}
+
+func TestDumpGraph_NoGraph(t *testing.T) {
+	config := createVerifyDB(t)
+	if err := os.Remove(config.DataPath + ".graph"); err != nil {
+		t.Fatalf("Failed to remove graph: %v", err)
+	}
+	if _, err := DumpGraph(config); err == nil {
+		t.Error("Expected an error without a graph file")
+	}
+}
+
+func TestDumpGraph_EdgeWeights(t *testing.T) {
+	config := createVerifyDB(t)
+	if topology, err := DumpGraph(config); err != nil || topology.Nodes[0].Distances != nil {
+		t.Fatalf("Expected no distances by default, got %v", err)
+	}
+
+	// Reopening with EdgeWeights saves them with the graph on Close
+	config.EdgeWeights = true
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	topology, err := DumpGraph(config)
+	if err != nil {
+		t.Fatalf("DumpGraph failed: %v", err)
+	}
+	for _, node := range topology.Nodes {
+		for level, neighbors := range node.Neighbors {
+			for i, neighborID := range neighbors {
+				want := float32(node.ID) - float32(neighborID)
+				if d := node.Distances[level][i]; d != max(want, -want) {
+					t.Fatalf("Expected distance %f for %d -> %d, got %f", max(want, -want), node.ID, neighborID, d)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package veclite
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/monishSR/veclite/internal/fileinfo"
+	"github.com/monishSR/veclite/internal/index/hnsw"
+	"github.com/monishSR/veclite/internal/index/ivf"
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// FileHeader is the decoded header of a data, .graph or .ivf file: the format
+// and version, the fixed fields and the file info block
+type FileHeader = fileinfo.Header
+
+// FileInfo is the self-describing block of a file header: the creator, the
+// creation time and the parameters the file was written with
+// Files written before it was added have none
+type FileInfo = fileinfo.Info
+
+// HeaderField is one fixed field of a file header, with its documentation
+type HeaderField = fileinfo.Field
+
+// HeaderFormat documents the fixed header fields of one file format
+type HeaderFormat struct {
+	Format string        // "data", "graph" or "ivf"
+	Fields []HeaderField // Fields in file order, without values
+}
+
+// ReadFileHeader reads the header of the data, .graph or .ivf file at path,
+// telling the format from its magic number, without reading the rest of the
+// file. Files with no known magic number are read as data files without a
+// header (the legacy layout, version 0)
+func ReadFileHeader(path string) (*FileHeader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var magic [4]byte
+	_, err = io.ReadFull(file, magic[:])
+	file.Close()
+	if err == nil {
+		switch binary.LittleEndian.Uint32(magic[:]) {
+		case 0x48534E57: // "HNSW"
+			return hnsw.ReadGraphHeader(path)
+		case 0x49564620: // "IVF "
+			return ivf.ReadIVFHeader(path)
+		}
+	}
+	return storage.ReadHeader(path)
+}
+
+// HeaderFormats documents the fixed header fields of every format
+// ReadFileHeader decodes; the file info block follows them
+func HeaderFormats() []HeaderFormat {
+	return []HeaderFormat{
+		{Format: "data", Fields: storage.DataHeaderFields},
+		{Format: "graph", Fields: hnsw.GraphHeaderFields},
+		{Format: "ivf", Fields: ivf.IVFHeaderFields},
+	}
+}
@@ -0,0 +1,62 @@
+package veclite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileHeader(t *testing.T) {
+	for _, tc := range []struct {
+		indexType, sidecar, format string
+		version                    int
+	}{
+		{"hnsw", ".graph", "graph", 3},
+		{"ivf", ".ivf", "ivf", 3},
+	} {
+		config := DefaultConfig()
+		config.DataPath = filepath.Join(t.TempDir(), "header.db")
+		config.Dimension = 4
+		config.IndexType = tc.indexType
+		db, err := New(config)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		for i := uint64(1); i <= 5; i++ {
+			if err := db.Insert(i, []float32{float32(i), 0, 0, 1}); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		header, err := ReadFileHeader(config.DataPath + tc.sidecar)
+		if err != nil {
+			t.Fatalf("ReadFileHeader failed: %v", err)
+		}
+		if header.Format != tc.format || header.Version != tc.version || header.Info == nil {
+			t.Fatalf("Expected a version %d %s header with file info, got %+v", tc.version, tc.format, header)
+		}
+		if index, _ := header.Info.Param("index"); index != tc.indexType {
+			t.Errorf("Expected index %s in the file info, got %q", tc.indexType, index)
+		}
+
+		header, err = ReadFileHeader(config.DataPath)
+		if err != nil || header.Format != "data" || header.Info == nil {
+			t.Fatalf("Expected a data header with file info, got %+v (%v)", header, err)
+		}
+		if dimension, _ := header.Info.Param("dimension"); dimension != "4" {
+			t.Errorf("Expected dimension 4 in the file info, got %q", dimension)
+		}
+	}
+
+	// Files written before the file info block have none
+	legacy := filepath.Join(t.TempDir(), "legacy.db")
+	if err := os.WriteFile(legacy, []byte("VECLITE3\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if header, err := ReadFileHeader(legacy); err != nil || header.Version != 3 || header.Info != nil || header.Fields[1].Value != "1" {
+		t.Errorf("Expected a version 3 header without file info, got %+v (%v)", header, err)
+	}
+}
@@ -0,0 +1,138 @@
+package veclite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVecLite_InsertHooks(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	errNegative := errors.New("negative first component")
+	var afterCalls []error
+	db.config.BeforeInsert = func(id uint64, vector []float32) error {
+		if vector[0] < 0 {
+			return errNegative
+		}
+		return nil
+	}
+	db.config.AfterInsert = func(id uint64, vector []float32, err error) {
+		afterCalls = append(afterCalls, err)
+	}
+
+	good := make([]float32, 128)
+	bad := make([]float32, 128)
+	bad[0] = -1
+
+	if err := db.Insert(1, good); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	err := db.Insert(2, bad)
+	if !errors.Is(err, ErrRejected) || !errors.Is(err, errNegative) {
+		t.Errorf("Expected rejection wrapping hook error, got %v", err)
+	}
+	if err := db.Insert(3, []float32{1}); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+
+	if db.Size() != 1 {
+		t.Errorf("Expected only the accepted vector to be stored, got size %d", db.Size())
+	}
+	if len(afterCalls) != 3 {
+		t.Fatalf("Expected AfterInsert for every call, got %d", len(afterCalls))
+	}
+	if afterCalls[0] != nil || afterCalls[1] == nil || afterCalls[2] == nil {
+		t.Errorf("Unexpected AfterInsert errors: %v", afterCalls)
+	}
+}
+
+func TestVecLite_DeleteHooks(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Insert(2, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	var deleted []uint64
+	db.config.BeforeDelete = func(id uint64) error {
+		if id == 1 {
+			return errors.New("protected")
+		}
+		return nil
+	}
+	db.config.AfterDelete = func(id uint64, err error) {
+		if err == nil {
+			deleted = append(deleted, id)
+		}
+	}
+
+	if err := db.Delete(1); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected rejected delete, got %v", err)
+	}
+	if err := db.Delete(2); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != 2 {
+		t.Errorf("Expected AfterDelete success for ID 2 only, got %v", deleted)
+	}
+	if _, err := db.Get(1); err != nil {
+		t.Errorf("Protected vector should still exist: %v", err)
+	}
+}
+
+func TestVecLite_SearchHooks(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 5; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	db.config.BeforeSearch = func(query []float32, k int) error {
+		if k > 3 {
+			return errors.New("k too large")
+		}
+		return nil
+	}
+	var lastResults []SearchResult
+	db.config.AfterSearch = func(query []float32, k int, results []SearchResult, err error) {
+		lastResults = results
+	}
+
+	if _, err := db.Search(make([]float32, 128), 4); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected rejected search, got %v", err)
+	}
+	results, err := db.Search(make([]float32, 128), 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(lastResults) != len(results) || lastResults[0].ID != results[0].ID {
+		t.Errorf("AfterSearch saw %v, caller got %v", lastResults, results)
+	}
+}
+
+func TestVecLite_HooksMayCallBack(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	// Hooks run outside the lock, so they can use the database themselves
+	sizes := []int{}
+	db.config.AfterInsert = func(id uint64, vector []float32, err error) {
+		sizes = append(sizes, db.Size())
+	}
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if len(sizes) != 1 || sizes[0] != 1 {
+		t.Errorf("Expected size 1 observed from hook, got %v", sizes)
+	}
+}
@@ -0,0 +1,264 @@
+package veclite
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAPISpec describes the endpoints of NewHTTPHandler
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// NewHTTPHandler returns an HTTP handler serving db as a JSON API, for
+// embedding into an existing Go HTTP server:
+//
+//	POST /vectors        Insert (or upsert) a vector with optional metadata
+//	POST /vectors/batch  InsertBatchWithOptions
+//	POST /search         Search with k and a metadata filter
+//	GET  /stats          Stats
+//	GET  /openapi.json   OpenAPI 3 description of the endpoints
+//
+// Errors are {"error": "..."} with a status derived from the error, e.g. 404
+// for ErrNotFound and 409 for ErrAlreadyExists. Mount it with a trailing
+// slash, e.g.
+//
+//	mux.Handle("/veclite/", http.StripPrefix("/veclite", veclite.NewHTTPHandler(db)))
+//
+// Request bodies are not size-limited; wrap the handler in
+// http.MaxBytesHandler when exposing it to untrusted clients
+func NewHTTPHandler(db *VecLite) http.Handler {
+	return &httpHandler{db: db}
+}
+
+// httpHandler implements NewHTTPHandler
+type httpHandler struct {
+	db *VecLite
+}
+
+// httpInsertRequest is the body of POST /vectors
+type httpInsertRequest struct {
+	ID       uint64    `json:"id"`
+	Vector   []float32 `json:"vector"`
+	Metadata Metadata  `json:"metadata,omitempty"` // Replaces the stored metadata if set
+	Upsert   bool      `json:"upsert,omitempty"`   // Replace the vector if ID is stored
+}
+
+// httpBatchRequest is the body of POST /vectors/batch
+type httpBatchRequest struct {
+	IDs             []uint64    `json:"ids"`
+	Vectors         [][]float32 `json:"vectors"`
+	ContinueOnError bool        `json:"continue_on_error,omitempty"`
+}
+
+// httpBatchResponse is the body of POST /vectors/batch responses
+type httpBatchResponse struct {
+	Error    string             `json:"error,omitempty"` // Set if the batch aborted
+	Applied  int                `json:"applied"`
+	Failures []httpBatchFailure `json:"failures"`
+}
+
+// httpBatchFailure is a BatchFailure with its error as text
+type httpBatchFailure struct {
+	Index int    `json:"index"`
+	ID    uint64 `json:"id"`
+	Error string `json:"error"`
+}
+
+// httpSearchRequest is the body of POST /search
+type httpSearchRequest struct {
+	Vector          []float32 `json:"vector"`
+	K               int       `json:"k"`
+	Filter          Filter    `json:"filter,omitempty"`
+	IncludeVector   bool      `json:"include_vector,omitempty"`
+	IncludeMetadata bool      `json:"include_metadata,omitempty"`
+}
+
+// httpSearchResult is a SearchResult of POST /search responses
+type httpSearchResult struct {
+	ID       uint64    `json:"id"`
+	Key      string    `json:"key,omitempty"`
+	Distance float32   `json:"distance"`
+	Vector   []float32 `json:"vector,omitempty"`
+	Metadata Metadata  `json:"metadata,omitempty"`
+}
+
+// ServeHTTP routes requests to the endpoints
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	method := http.MethodPost
+	if path == "stats" || path == "openapi.json" {
+		method = http.MethodGet
+	}
+	switch path {
+	case "vectors", "vectors/batch", "search", "stats", "openapi.json":
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeHTTPError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+	default:
+		writeHTTPError(w, http.StatusNotFound, fmt.Errorf("no endpoint %s", r.URL.Path))
+		return
+	}
+
+	switch path {
+	case "vectors":
+		h.insert(w, r)
+	case "vectors/batch":
+		h.insertBatch(w, r)
+	case "search":
+		h.search(w, r)
+	case "stats":
+		writeHTTPJSON(w, http.StatusOK, h.db.Stats())
+	case "openapi.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}
+}
+
+// insert implements POST /vectors
+func (h *httpHandler) insert(w http.ResponseWriter, r *http.Request) {
+	var req httpInsertRequest
+	if !decodeHTTPRequest(w, r, &req) {
+		return
+	}
+	var err error
+	switch {
+	case req.Metadata != nil && req.Upsert:
+		err = h.db.UpsertWithMetadataContext(r.Context(), req.ID, req.Vector, req.Metadata)
+	case req.Metadata != nil:
+		err = h.db.InsertWithMetadataContext(r.Context(), req.ID, req.Vector, req.Metadata)
+	case req.Upsert:
+		err = h.db.UpsertContext(r.Context(), req.ID, req.Vector)
+	default:
+		err = h.db.InsertContext(r.Context(), req.ID, req.Vector)
+	}
+	if err != nil {
+		writeHTTPError(w, httpStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// insertBatch implements POST /vectors/batch
+func (h *httpHandler) insertBatch(w http.ResponseWriter, r *http.Request) {
+	var req httpBatchRequest
+	if !decodeHTTPRequest(w, r, &req) {
+		return
+	}
+	if len(req.IDs) != len(req.Vectors) {
+		writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("got %d IDs for %d vectors", len(req.IDs), len(req.Vectors)))
+		return
+	}
+	result, err := h.db.InsertBatchWithOptionsContext(r.Context(), req.IDs, req.Vectors, BatchOptions{ContinueOnError: req.ContinueOnError})
+	resp := httpBatchResponse{Applied: result.Applied, Failures: make([]httpBatchFailure, len(result.Failures))}
+	for i, f := range result.Failures {
+		resp.Failures[i] = httpBatchFailure{Index: f.Index, ID: f.ID, Error: f.Err.Error()}
+	}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		status = httpStatus(err)
+	}
+	writeHTTPJSON(w, status, resp)
+}
+
+// search implements POST /search
+func (h *httpHandler) search(w http.ResponseWriter, r *http.Request) {
+	var req httpSearchRequest
+	if !decodeHTTPRequest(w, r, &req) {
+		return
+	}
+	if req.K <= 0 {
+		writeHTTPError(w, http.StatusBadRequest, errors.New("k must be positive"))
+		return
+	}
+	opts := SearchOptions{
+		IncludeScore:    true,
+		IncludeVector:   req.IncludeVector,
+		IncludeMetadata: req.IncludeMetadata,
+		Filter:          req.Filter,
+	}
+	results, err := h.db.SearchWithOptionsContext(r.Context(), req.Vector, req.K, opts)
+	if err != nil {
+		writeHTTPError(w, httpStatus(err), err)
+		return
+	}
+	resp := struct {
+		Results []httpSearchResult `json:"results"`
+	}{Results: make([]httpSearchResult, len(results))}
+	for i, result := range results {
+		resp.Results[i] = httpSearchResult{ID: result.ID, Key: result.Key, Distance: result.Distance, Vector: result.Vector, Metadata: result.Metadata}
+	}
+	writeHTTPJSON(w, http.StatusOK, resp)
+}
+
+// decodeHTTPRequest decodes the JSON body of r into req, answering 400 and
+// returning false if it is malformed
+func decodeHTTPRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeHTTPError(w, status, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+// httpStatus maps a database error to an HTTP status
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrQueryTooExpensive):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrWriteStall), errors.Is(err, ErrUnhealthy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrDimensionMismatch), errors.Is(err, ErrNonFinite), errors.Is(err, ErrReservedID),
+		errors.Is(err, ErrKTooLarge), errors.Is(err, ErrInvalidFilter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrEmptyDatabase):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeHTTPJSON writes value as JSON with status
+func writeHTTPJSON(w http.ResponseWriter, status int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// writeHTTPError writes err as {"error": "..."} with status
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	body, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,181 @@
+package veclite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// httpPost sends body to target on handler and returns the status and
+// response body
+func httpPost(t *testing.T, handler http.Handler, target, body string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestHTTPHandler_Insert(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := NewHTTPHandler(db)
+
+	code, body := httpPost(t, handler, "/vectors", `{"id": 100, "vector": [100, 1, 0, 0], "metadata": {"lang": "en"}}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", code, body)
+	}
+	if metadata, err := db.GetMetadata(100); err != nil || metadata["lang"] != "en" {
+		t.Errorf("Expected metadata of ID 100, got %v (%v)", metadata, err)
+	}
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"id": 100, "vector": [1, 1, 0, 0]}`, http.StatusConflict},
+		{`{"id": 101, "vector": [1]}`, http.StatusBadRequest},
+		{`{"id": 101, "vectr": [1, 1, 0, 0]}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	} {
+		code, body := httpPost(t, handler, "/vectors", tc.body)
+		if code != tc.code || !strings.Contains(body, `"error"`) {
+			t.Errorf("Expected %d with an error for %s, got %d: %s", tc.code, tc.body, code, body)
+		}
+	}
+
+	code, body = httpPost(t, handler, "/vectors", `{"id": 100, "vector": [7, 1, 0, 0], "upsert": true}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for an upsert, got %d: %s", code, body)
+	}
+	if vec, _ := db.Get(100); vec[0] != 7 {
+		t.Errorf("Expected the upserted vector, got %v", vec)
+	}
+}
+
+func TestHTTPHandler_InsertBatch(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := NewHTTPHandler(db)
+
+	code, body := httpPost(t, handler, "/vectors/batch", `{"ids": [101, 1, 102], "vectors": [[1, 0, 0, 0], [1, 0, 0, 0], [1]], "continue_on_error": true}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", code, body)
+	}
+	var resp httpBatchResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Applied != 1 || len(resp.Failures) != 2 || resp.Failures[0].ID != 1 || resp.Failures[1].Index != 2 {
+		t.Errorf("Expected 1 applied and IDs 1 and 102 failed, got %+v", resp)
+	}
+
+	code, body = httpPost(t, handler, "/vectors/batch", `{"ids": [103, 1], "vectors": [[1, 0, 0, 0], [1, 0, 0, 0]]}`)
+	if code != http.StatusConflict || !strings.Contains(body, `"applied":0`) {
+		t.Errorf("Expected 409 with nothing applied, got %d: %s", code, body)
+	}
+	if _, err := db.Get(103); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an aborted batch to insert nothing, got %v", err)
+	}
+	if code, _ := httpPost(t, handler, "/vectors/batch", `{"ids": [104], "vectors": []}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for mismatched IDs and vectors, got %d", code)
+	}
+}
+
+func TestHTTPHandler_Search(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := NewHTTPHandler(db)
+	if err := db.InsertWithMetadata(100, []float32{3, 1, 0, 0}, Metadata{"name": "other"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	code, body := httpPost(t, handler, "/search", `{"vector": [3, 1, 0, 0], "k": 2, "filter": {"name": "v"}, "include_metadata": true}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", code, body)
+	}
+	var resp struct {
+		Results []httpSearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].ID != 3 || resp.Results[0].Metadata["name"] != "v" || resp.Results[0].Vector != nil {
+		t.Errorf("Expected ID 3 with its metadata first and ID 100 filtered out, got %+v", resp.Results)
+	}
+
+	if code, _ := httpPost(t, handler, "/search", `{"vector": [3, 1, 0, 0]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without k, got %d", code)
+	}
+	if code, _ := httpPost(t, handler, "/search", `{"vector": [3], "k": 1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a dimension mismatch, got %d", code)
+	}
+}
+
+func TestHTTPHandler_Routes(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+	handler := NewHTTPHandler(db)
+
+	code, body := debugGet(t, handler, "/stats")
+	if code != http.StatusOK || !strings.Contains(body, `"Size":40`) {
+		t.Errorf("Expected stats with 40 vectors, got %d: %s", code, body)
+	}
+	code, body = debugGet(t, handler, "/openapi.json")
+	var spec struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if code != http.StatusOK || json.Unmarshal([]byte(body), &spec) != nil || spec.OpenAPI == "" {
+		t.Fatalf("Expected the OpenAPI document, got %d", code)
+	}
+	for _, path := range []string{"/vectors", "/vectors/batch", "/search", "/stats"} {
+		if spec.Paths[path] == nil {
+			t.Errorf("Expected %s in the OpenAPI document", path)
+		}
+	}
+
+	if code, _ := debugGet(t, handler, "/search"); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET /search, got %d", code)
+	}
+	if code, _ := debugGet(t, handler, "/bogus"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown path, got %d", code)
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{ErrNotFound, http.StatusNotFound},
+		{ErrAlreadyExists, http.StatusConflict},
+		{ErrDimensionMismatch, http.StatusBadRequest},
+		{ErrNonFinite, http.StatusBadRequest},
+		{ErrReservedID, http.StatusBadRequest},
+		{ErrKTooLarge, http.StatusBadRequest},
+		{ErrInvalidFilter, http.StatusBadRequest},
+		{ErrEmptyDatabase, http.StatusConflict},
+		{ErrRejected, http.StatusUnprocessableEntity},
+		{ErrWriteStall, http.StatusServiceUnavailable},
+		{errors.New("disk failure"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := httpStatus(fmt.Errorf("wrapped: %w", tt.err)); got != tt.want {
+			t.Errorf("httpStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPHandler_InvalidFilter(t *testing.T) {
+	db := newDebugTestDB(t)
+	defer db.Close()
+
+	code, body := httpPost(t, NewHTTPHandler(db), "/search", `{"vector": [1, 0, 0, 0], "k": 3, "filter": {"tag": [1, 2]}}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid filter, got %d: %s", code, body)
+	}
+}
@@ -0,0 +1,240 @@
+// Package vecio imports vectors into and exports them from VecLite databases
+// in standard formats: NumPy .npy and .npz arrays, JSON Lines and CSV
+//
+// JSONL lines are {"id": 1, "vector": [0.1, 0.2], "metadata": {"lang": "en"}};
+// CSV rows are an ID followed by the vector components, after an optional
+// header row. NumPy files hold a 2-D float32 or float64 array of vectors; their
+// IDs are numbered from a first ID, except in .npz archives with an "ids"
+// array next to the "vectors" one. Only JSONL carries metadata
+package vecio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// Formats of OpenFile and CreateFile
+const (
+	FormatNPY   = "npy"
+	FormatNPZ   = "npz"
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// Record is one vector with its ID and optional metadata
+type Record struct {
+	ID       uint64           `json:"id"`
+	Vector   []float32        `json:"vector"`
+	Metadata veclite.Metadata `json:"metadata,omitempty"`
+}
+
+// Reader reads records one at a time, returning io.EOF after the last one
+type Reader interface {
+	Read() (Record, error)
+}
+
+// Writer writes records; Close completes the output (NumPy writers write
+// everything then) without closing the underlying writer
+type Writer interface {
+	Write(rec Record) error
+	Close() error
+}
+
+// DetectFormat returns the format of path from its extension
+func DetectFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".npy":
+		return FormatNPY, nil
+	case ".npz":
+		return FormatNPZ, nil
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	case ".csv":
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unknown file format %q, expected .npy, .npz, .jsonl or .csv", ext)
+	}
+}
+
+// FileReader reads the records of a file, see OpenFile
+type FileReader struct {
+	Reader
+	file *os.File
+}
+
+// Close closes the file
+func (f *FileReader) Close() error {
+	return f.file.Close()
+}
+
+// OpenFile opens path for reading records in format ("" = detected from the
+// extension); NumPy vectors without IDs are numbered from firstID
+func OpenFile(path, format string, firstID uint64) (*FileReader, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Reader
+	switch format {
+	case FormatNPY:
+		r, err = NewNPYReader(file, firstID)
+	case FormatNPZ:
+		var info os.FileInfo
+		if info, err = file.Stat(); err == nil {
+			r, err = NewNPZReader(file, info.Size(), firstID)
+		}
+	case FormatJSONL:
+		r = NewJSONLReader(file)
+	case FormatCSV:
+		r = NewCSVReader(file)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &FileReader{Reader: r, file: file}, nil
+}
+
+// FileWriter writes records to a file, see CreateFile
+type FileWriter struct {
+	Writer
+	file *os.File
+}
+
+// Close completes the output and closes the file
+func (f *FileWriter) Close() error {
+	err := f.Writer.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// CreateFile creates path for writing records in format ("" = detected from
+// the extension)
+func CreateFile(path, format string) (*FileWriter, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	var newWriter func(io.Writer) Writer
+	switch format {
+	case FormatNPY:
+		newWriter = NewNPYWriter
+	case FormatNPZ:
+		newWriter = NewNPZWriter
+	case FormatJSONL:
+		newWriter = NewJSONLWriter
+	case FormatCSV:
+		newWriter = NewCSVWriter
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileWriter{Writer: newWriter(file), file: file}, nil
+}
+
+// ImportOptions controls Import
+type ImportOptions struct {
+	BatchSize int  // Records applied per batch (0 = 1000)
+	Upsert    bool // Replace stored IDs instead of failing with veclite.ErrAlreadyExists
+}
+
+// Import inserts the records of r into db in batches and returns how many
+// were applied
+// Each batch is applied with veclite.Apply, so a failing record stops the
+// import with the batches before it applied
+func Import(db *veclite.VecLite, r Reader, opts ImportOptions) (int, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	imported := 0
+	batch := &veclite.Batch{}
+	apply := func() error {
+		if err := db.Apply(batch); err != nil {
+			return fmt.Errorf("failed to import records %d-%d: %w", imported+1, imported+batch.Len(), err)
+		}
+		imported += batch.Len()
+		batch = &veclite.Batch{}
+		return nil
+	}
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+		switch {
+		case rec.Metadata != nil && opts.Upsert:
+			batch.UpsertWithMetadata(rec.ID, rec.Vector, rec.Metadata)
+		case rec.Metadata != nil:
+			batch.InsertWithMetadata(rec.ID, rec.Vector, rec.Metadata)
+		case opts.Upsert:
+			batch.Upsert(rec.ID, rec.Vector)
+		default:
+			batch.Insert(rec.ID, rec.Vector)
+		}
+		if batch.Len() == batchSize {
+			if err := apply(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if batch.Len() > 0 {
+		if err := apply(); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// Export writes every vector of db with its metadata to w in ascending ID
+// order, as of the moment it starts, then closes w; it returns how many
+// vectors were written
+func Export(db *veclite.VecLite, w Writer) (int, error) {
+	scroll, err := db.Scroll(1000)
+	if err != nil {
+		return 0, err
+	}
+	defer scroll.Close()
+
+	exported := 0
+	for {
+		items, err := scroll.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return exported, err
+		}
+		for _, item := range items {
+			if err := w.Write(Record{ID: item.ID, Vector: item.Vector, Metadata: item.Metadata}); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+	}
+	return exported, w.Close()
+}
@@ -0,0 +1,194 @@
+package vecio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// testRecords returns n records of dimension 3, the even ones with metadata
+func testRecords(n int) []Record {
+	records := make([]Record, n)
+	for i := range records {
+		records[i] = Record{ID: uint64(i + 10), Vector: []float32{float32(i), 0.5, -1.25}}
+		if i%2 == 0 {
+			records[i].Metadata = veclite.Metadata{"lang": "en"}
+		}
+	}
+	return records
+}
+
+// readAll reads every record of r
+func readAll(t *testing.T, r Reader) []Record {
+	t.Helper()
+	var records []Record
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Read failed after %d records: %v", len(records), err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// writeAll writes records to w and closes it
+func writeAll(t *testing.T, w Writer, records []Record) {
+	t.Helper()
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	records := testRecords(5)
+	withoutMetadata := make([]Record, len(records))
+	for i, rec := range records {
+		withoutMetadata[i] = Record{ID: rec.ID, Vector: rec.Vector}
+	}
+	renumbered := make([]Record, len(records))
+	for i, rec := range records {
+		renumbered[i] = Record{ID: uint64(i + 1), Vector: rec.Vector}
+	}
+
+	tests := []struct {
+		format string
+		want   []Record
+	}{
+		{FormatJSONL, records},
+		{FormatCSV, withoutMetadata},
+		{FormatNPZ, withoutMetadata},
+		{FormatNPY, renumbered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vectors."+tt.format)
+			w, err := CreateFile(path, "")
+			if err != nil {
+				t.Fatalf("CreateFile failed: %v", err)
+			}
+			writeAll(t, w, records)
+
+			r, err := OpenFile(path, "", 1)
+			if err != nil {
+				t.Fatalf("OpenFile failed: %v", err)
+			}
+			defer r.Close()
+			if got := readAll(t, r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNPY_Header(t *testing.T) {
+	var buf bytes.Buffer
+	writeAll(t, NewNPYWriter(&buf), testRecords(2))
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("\x93NUMPY\x01\x00")) {
+		t.Fatalf("Unexpected magic %q", data[:8])
+	}
+	headerEnd := bytes.IndexByte(data, '\n') + 1
+	if headerEnd%64 != 0 {
+		t.Errorf("Data starts at %d, want a multiple of 64", headerEnd)
+	}
+	if header := string(data[10:headerEnd]); !strings.Contains(header, "'descr': '<f4'") || !strings.Contains(header, "'shape': (2, 3)") {
+		t.Errorf("Unexpected header %q", header)
+	}
+	if len(data) != headerEnd+2*3*4 {
+		t.Errorf("File is %d bytes, want %d", len(data), headerEnd+2*3*4)
+	}
+
+	// Float64 arrays as written by numpy.save
+	header := "{'descr': '<f8', 'fortran_order': False, 'shape': (1, 2), }"
+	header += strings.Repeat(" ", 128-10-len(header)-1) + "\n"
+	f64 := append([]byte("\x93NUMPY\x01\x00\x76\x00"+header), 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0xc0)
+	r, err := NewNPYReader(bytes.NewReader(f64), 7)
+	if err != nil {
+		t.Fatalf("NewNPYReader failed: %v", err)
+	}
+	if got := readAll(t, r); !reflect.DeepEqual(got, []Record{{ID: 7, Vector: []float32{1, -2}}}) {
+		t.Errorf("Read %v", got)
+	}
+
+	// Truncated data
+	r, err = NewNPYReader(bytes.NewReader(data[:len(data)-1]), 1)
+	if err != nil {
+		t.Fatalf("NewNPYReader failed: %v", err)
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("First Read failed: %v", err)
+	}
+	if _, err := r.Read(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected an error for a truncated array, got %v", err)
+	}
+}
+
+func TestCSV_Header(t *testing.T) {
+	r := NewCSVReader(strings.NewReader("id,x,y\n1, 0.5,1\n2,2,3e-1\n"))
+	want := []Record{{ID: 1, Vector: []float32{0.5, 1}}, {ID: 2, Vector: []float32{2, 0.3}}}
+	if got := readAll(t, r); !reflect.DeepEqual(got, want) {
+		t.Errorf("Read %v, want %v", got, want)
+	}
+
+	r = NewCSVReader(strings.NewReader("1,0.5\nx,1\n"))
+	r.Read()
+	if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("Expected an invalid ID error for row 2, got %v", err)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "import.db")
+	config.Dimension = 3
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	records := testRecords(25)
+	var buf bytes.Buffer
+	writeAll(t, NewJSONLWriter(&buf), records)
+	n, err := Import(db, NewJSONLReader(bytes.NewReader(buf.Bytes())), ImportOptions{BatchSize: 10})
+	if err != nil || n != 25 {
+		t.Fatalf("Import = %d, %v; want 25", n, err)
+	}
+	if db.Size() != 25 {
+		t.Errorf("Size = %d, want 25", db.Size())
+	}
+	if metadata, err := db.GetMetadata(10); err != nil || metadata["lang"] != "en" {
+		t.Errorf("GetMetadata(10) = %v, %v", metadata, err)
+	}
+
+	// Importing again fails on the first batch unless upserting
+	if n, err := Import(db, NewJSONLReader(bytes.NewReader(buf.Bytes())), ImportOptions{}); n != 0 || !errors.Is(err, veclite.ErrAlreadyExists) {
+		t.Errorf("Second Import = %d, %v; want ErrAlreadyExists", n, err)
+	}
+	if n, err := Import(db, NewJSONLReader(bytes.NewReader(buf.Bytes())), ImportOptions{Upsert: true}); n != 25 || err != nil {
+		t.Errorf("Upsert Import = %d, %v; want 25", n, err)
+	}
+
+	var out bytes.Buffer
+	n, err = Export(db, NewJSONLWriter(&out))
+	if err != nil || n != 25 {
+		t.Fatalf("Export = %d, %v; want 25", n, err)
+	}
+	if got := readAll(t, NewJSONLReader(&out)); !reflect.DeepEqual(got, records) {
+		t.Errorf("Exported %v, want %v", got, records)
+	}
+}
@@ -0,0 +1,386 @@
+package vecio
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NumPy .npy layout: magic, version, header length (2 bytes in version 1, 4
+// in versions 2 and 3), a Python dict literal with the dtype, order and
+// shape padded with spaces to a multiple of 64 bytes and ended by a newline,
+// then the array data
+const npyMagic = "\x93NUMPY"
+
+// npyAlign is the alignment of the data after the header
+const npyAlign = 64
+
+// Names of the arrays in .npz archives written by NewNPZWriter and preferred
+// by NewNPZReader
+const (
+	npzVectors = "vectors.npy"
+	npzIDs     = "ids.npy"
+)
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// npyHeader is the decoded header of a .npy array
+type npyHeader struct {
+	descr string
+	shape []int
+}
+
+// readNPYHeader reads the header of a .npy array, leaving r at its data
+func readNPYHeader(r io.Reader) (npyHeader, error) {
+	var h npyHeader
+	var prefix [8]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return h, fmt.Errorf("failed to read .npy header: %w", err)
+	}
+	if string(prefix[:6]) != npyMagic {
+		return h, errors.New("not a .npy array")
+	}
+	var headerLen int
+	switch major := prefix[6]; major {
+	case 1:
+		var n [2]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return h, fmt.Errorf("failed to read .npy header: %w", err)
+		}
+		headerLen = int(binary.LittleEndian.Uint16(n[:]))
+	case 2, 3:
+		var n [4]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return h, fmt.Errorf("failed to read .npy header: %w", err)
+		}
+		headerLen = int(binary.LittleEndian.Uint32(n[:]))
+	default:
+		return h, fmt.Errorf("unsupported .npy version %d", major)
+	}
+	if headerLen > 1<<20 {
+		return h, fmt.Errorf(".npy header of %d bytes is too large", headerLen)
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return h, fmt.Errorf("failed to read .npy header: %w", err)
+	}
+
+	descr, fortran, shape := npyDescr.FindSubmatch(header), npyFortran.FindSubmatch(header), npyShape.FindSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return h, fmt.Errorf("invalid .npy header %q", strings.TrimSpace(string(header)))
+	}
+	if string(fortran[1]) == "True" {
+		return h, errors.New("Fortran-order .npy arrays are not supported")
+	}
+	h.descr = string(descr[1])
+	for _, dim := range strings.Split(string(shape[1]), ",") {
+		if dim = strings.TrimSpace(dim); dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil || n < 0 {
+			return h, fmt.Errorf("invalid .npy shape (%s)", shape[1])
+		}
+		h.shape = append(h.shape, n)
+	}
+	return h, nil
+}
+
+// writeNPYHeader writes a version 1 header for an array of descr and shape
+func writeNPYHeader(w io.Writer, descr string, shape ...int) error {
+	dims := make([]string, len(shape))
+	for i, n := range shape {
+		dims[i] = strconv.Itoa(n)
+	}
+	shapeText := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeText += ","
+	}
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", descr, shapeText)
+	headerLen := len(dict) + 1
+	headerLen += (npyAlign - (len(npyMagic)+4+headerLen)%npyAlign) % npyAlign
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(headerLen))
+	buf.WriteString(dict)
+	buf.WriteString(strings.Repeat(" ", headerLen-len(dict)-1))
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// npyReader implements NewNPYReader and NewNPZReader
+type npyReader struct {
+	r        *bufio.Reader
+	closer   io.Closer // Closed after the last vector (nil = none)
+	rows     int
+	dim      int
+	float64s bool     // The array is float64 rather than float32
+	row      int      // Vectors read
+	firstID  uint64   // ID of the first vector if ids is nil
+	ids      []uint64 // IDs of the vectors (nil = numbered from firstID)
+	buf      []byte
+}
+
+// NewNPYReader reads records from a .npy file holding a 2-D little-endian
+// float32 or float64 array, one vector per row, numbered from firstID
+func NewNPYReader(r io.Reader, firstID uint64) (Reader, error) {
+	return newNPYReader(r, firstID)
+}
+
+// newNPYReader reads the header of a .npy array of vectors
+func newNPYReader(r io.Reader, firstID uint64) (*npyReader, error) {
+	h, err := readNPYHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.shape) != 2 {
+		return nil, fmt.Errorf("expected a 2-D .npy array of vectors, got shape %v", h.shape)
+	}
+	reader := &npyReader{r: bufio.NewReader(r), rows: h.shape[0], dim: h.shape[1], firstID: firstID}
+	switch h.descr {
+	case "<f4":
+	case "<f8":
+		reader.float64s = true
+	default:
+		return nil, fmt.Errorf("unsupported .npy dtype %q, expected <f4 or <f8", h.descr)
+	}
+	if reader.rows > 0 && reader.dim == 0 {
+		return nil, errors.New(".npy vectors have no components")
+	}
+	itemSize := 4
+	if reader.float64s {
+		itemSize = 8
+	}
+	reader.buf = make([]byte, reader.dim*itemSize)
+	return reader, nil
+}
+
+func (r *npyReader) Read() (Record, error) {
+	if r.row == r.rows {
+		if r.closer != nil {
+			err := r.closer.Close()
+			r.closer = nil
+			if err != nil {
+				return Record{}, err
+			}
+		}
+		return Record{}, io.EOF
+	}
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return Record{}, fmt.Errorf("array ends after %d of %d vectors: %w", r.row, r.rows, err)
+	}
+	vector := make([]float32, r.dim)
+	for i := range vector {
+		if r.float64s {
+			vector[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(r.buf[i*8:])))
+		} else {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(r.buf[i*4:]))
+		}
+	}
+	id := r.firstID + uint64(r.row)
+	if r.ids != nil {
+		id = r.ids[r.row]
+	}
+	r.row++
+	return Record{ID: id, Vector: vector}, nil
+}
+
+// NewNPZReader reads records from a .npz archive (as written by numpy.savez
+// or numpy.savez_compressed) of size bytes: the vectors are the "vectors"
+// array, or the only array besides "ids", in the layout of NewNPYReader
+// A 1-D integer "ids" array holds their IDs; without it they are numbered
+// from firstID
+func NewNPZReader(r io.ReaderAt, size int64, firstID uint64) (Reader, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a .npz archive: %w", err)
+	}
+	var vectorsFile, idsFile *zip.File
+	var arrays []*zip.File
+	for _, f := range archive.File {
+		switch {
+		case f.Name == npzVectors:
+			vectorsFile = f
+		case f.Name == npzIDs:
+			idsFile = f
+		case strings.HasSuffix(f.Name, ".npy"):
+			arrays = append(arrays, f)
+		}
+	}
+	if vectorsFile == nil {
+		if len(arrays) != 1 {
+			return nil, fmt.Errorf(".npz archive has %d arrays besides ids; name the vectors %q", len(arrays), strings.TrimSuffix(npzVectors, ".npy"))
+		}
+		vectorsFile = arrays[0]
+	}
+
+	rc, err := vectorsFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newNPYReader(rc, firstID)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("%s: %w", vectorsFile.Name, err)
+	}
+	reader.closer = rc
+	if idsFile != nil {
+		if reader.ids, err = readNPYIDs(idsFile); err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("%s: %w", idsFile.Name, err)
+		}
+		if len(reader.ids) != reader.rows {
+			rc.Close()
+			return nil, fmt.Errorf(".npz archive has %d IDs for %d vectors", len(reader.ids), reader.rows)
+		}
+	}
+	return reader, nil
+}
+
+// readNPYIDs reads a 1-D array of non-negative integers
+func readNPYIDs(f *zip.File) ([]uint64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	h, err := readNPYHeader(rc)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.shape) != 1 {
+		return nil, fmt.Errorf("expected a 1-D array of IDs, got shape %v", h.shape)
+	}
+	itemSize := map[string]int{"<i4": 4, "<u4": 4, "<i8": 8, "<u8": 8}[h.descr]
+	if itemSize == 0 {
+		return nil, fmt.Errorf("unsupported ID dtype %q, expected <i4, <u4, <i8 or <u8", h.descr)
+	}
+	data := make([]byte, h.shape[0]*itemSize)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, fmt.Errorf("failed to read IDs: %w", err)
+	}
+	ids := make([]uint64, h.shape[0])
+	for i := range ids {
+		switch h.descr {
+		case "<i4":
+			if v := int32(binary.LittleEndian.Uint32(data[i*4:])); v >= 0 {
+				ids[i] = uint64(v)
+			} else {
+				return nil, fmt.Errorf("negative ID %d", v)
+			}
+		case "<u4":
+			ids[i] = uint64(binary.LittleEndian.Uint32(data[i*4:]))
+		case "<i8":
+			if v := int64(binary.LittleEndian.Uint64(data[i*8:])); v >= 0 {
+				ids[i] = uint64(v)
+			} else {
+				return nil, fmt.Errorf("negative ID %d", v)
+			}
+		case "<u8":
+			ids[i] = binary.LittleEndian.Uint64(data[i*8:])
+		}
+	}
+	return ids, nil
+}
+
+// npyWriter implements NewNPYWriter and NewNPZWriter
+// The row count precedes the data, so vectors are buffered until Close
+type npyWriter struct {
+	w    io.Writer
+	npz  bool
+	dim  int
+	ids  []uint64
+	data []float32
+}
+
+// NewNPYWriter writes the vectors of the records as a 2-D float32 .npy array
+// on Close; IDs and metadata are not written
+func NewNPYWriter(w io.Writer) Writer {
+	return &npyWriter{w: w}
+}
+
+// NewNPZWriter writes the records as a compressed .npz archive on Close: a
+// "vectors" float32 array and an "ids" uint64 array; metadata is not written
+func NewNPZWriter(w io.Writer) Writer {
+	return &npyWriter{w: w, npz: true}
+}
+
+func (w *npyWriter) Write(rec Record) error {
+	if len(w.ids) == 0 {
+		w.dim = len(rec.Vector)
+	}
+	if len(rec.Vector) != w.dim {
+		return fmt.Errorf("vector %d has dimension %d, expected %d", rec.ID, len(rec.Vector), w.dim)
+	}
+	w.ids = append(w.ids, rec.ID)
+	w.data = append(w.data, rec.Vector...)
+	return nil
+}
+
+func (w *npyWriter) Close() error {
+	if !w.npz {
+		return w.writeVectors(w.w)
+	}
+	archive := zip.NewWriter(w.w)
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: npzIDs, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	if err := w.writeIDs(entry); err != nil {
+		return err
+	}
+	if entry, err = archive.CreateHeader(&zip.FileHeader{Name: npzVectors, Method: zip.Deflate}); err != nil {
+		return err
+	}
+	if err := w.writeVectors(entry); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// writeVectors writes the vectors as a .npy array
+func (w *npyWriter) writeVectors(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	if err := writeNPYHeader(bw, "<f4", len(w.ids), w.dim); err != nil {
+		return err
+	}
+	var buf [4]byte
+	for _, value := range w.data {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(value))
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeIDs writes the IDs as a .npy array
+func (w *npyWriter) writeIDs(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	if err := writeNPYHeader(bw, "<u8", len(w.ids)); err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, id := range w.ids {
+		binary.LittleEndian.PutUint64(buf[:], id)
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,130 @@
+package vecio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// jsonlReader implements NewJSONLReader
+type jsonlReader struct {
+	decoder *json.Decoder
+	n       int // Records read
+}
+
+// NewJSONLReader reads records from JSON Lines: one {"id", "vector",
+// "metadata"} object per line
+func NewJSONLReader(r io.Reader) Reader {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return &jsonlReader{decoder: decoder}
+}
+
+func (r *jsonlReader) Read() (Record, error) {
+	var rec Record
+	if err := r.decoder.Decode(&rec); err != nil {
+		if err == io.EOF {
+			return rec, io.EOF
+		}
+		return rec, fmt.Errorf("record %d: %w", r.n+1, err)
+	}
+	r.n++
+	if len(rec.Vector) == 0 {
+		return rec, fmt.Errorf("record %d (ID %d) has no vector", r.n, rec.ID)
+	}
+	return rec, nil
+}
+
+// jsonlWriter implements NewJSONLWriter
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLWriter writes records as JSON Lines
+func NewJSONLWriter(w io.Writer) Writer {
+	return &jsonlWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *jsonlWriter) Write(rec Record) error {
+	return w.encoder.Encode(rec)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvReader implements NewCSVReader
+type csvReader struct {
+	reader *csv.Reader
+	row    int // Rows read, including a header
+}
+
+// NewCSVReader reads records from CSV rows of an ID followed by the vector
+// components; a first row whose ID column is not a number is skipped as a
+// header
+func NewCSVReader(r io.Reader) Reader {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+	return &csvReader{reader: reader}
+}
+
+func (r *csvReader) Read() (Record, error) {
+	for {
+		fields, err := r.reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return Record{}, io.EOF
+			}
+			return Record{}, err
+		}
+		r.row++
+		id, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			if r.row == 1 {
+				continue // Header
+			}
+			return Record{}, fmt.Errorf("row %d: invalid ID %q", r.row, fields[0])
+		}
+		if len(fields) < 2 {
+			return Record{}, fmt.Errorf("row %d (ID %d) has no vector", r.row, id)
+		}
+		vector := make([]float32, len(fields)-1)
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return Record{}, fmt.Errorf("row %d (ID %d): invalid component %d %q", r.row, id, i, field)
+			}
+			vector[i] = float32(value)
+		}
+		return Record{ID: id, Vector: vector}, nil
+	}
+}
+
+// csvWriter implements NewCSVWriter
+type csvWriter struct {
+	writer *csv.Writer
+	fields []string
+}
+
+// NewCSVWriter writes records as CSV rows of the ID and the vector components,
+// without a header; metadata is not written
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(rec Record) error {
+	w.fields = append(w.fields[:0], strconv.FormatUint(rec.ID, 10))
+	for _, value := range rec.Vector {
+		w.fields = append(w.fields, strconv.FormatFloat(float64(value), 'g', -1, 32))
+	}
+	return w.writer.Write(w.fields)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,394 @@
+package veclite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// KeyedIDBase is the first ID assigned to string keys; keyed IDs count up from
+// it, skipping IDs already stored, so they never collide with numeric IDs
+// Numeric inserts may use any ID, including those at or above KeyedIDBase
+const KeyedIDBase uint64 = 1 << 63
+
+// ErrKeyNotFound is returned for string keys that were never inserted
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrKeyInUse is returned by SetKey for a key that belongs to another vector
+var ErrKeyInUse = errors.New("key already in use")
+
+// keyStore is the persistent dictionary between string keys and the uint64 IDs
+// the indexes work with, so string ID schemes never reach the index structures
+// Persisted to a JSON-lines sidecar (DataPath + ".keys"): every change is
+// appended to it as part of the write that makes it, and the file is rewritten
+// without superseded lines on Sync/Close
+// A key keeps its ID until its vector is deleted
+type keyStore struct {
+	mu      sync.RWMutex
+	path    string
+	ids     map[string]uint64 // Key -> ID
+	keys    map[uint64]string // ID -> key
+	next    uint64            // Next ID to assign
+	dirty   bool              // Changed since the last save
+	journal *os.File          // Sidecar opened for appending changes (nil until the first change)
+}
+
+// keyRecord is one line of the sidecar
+// Lines are applied in order; a deleted line drops the key of ID
+type keyRecord struct {
+	Key     string `json:"key,omitempty"`
+	ID      uint64 `json:"id"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// openKeyStore loads the sidecar at path if it exists
+// A torn last line, left by a crash while appending, is dropped
+func openKeyStore(path string) (*keyStore, error) {
+	k := &keyStore{path: path, ids: make(map[string]uint64), keys: make(map[uint64]string), next: KeyedIDBase}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	for line, rest := 1, data; len(rest) > 0; line++ {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 { // Every line written ends with a newline
+			if err := os.Truncate(path, int64(len(data)-len(rest))); err != nil {
+				return nil, fmt.Errorf("failed to drop torn key: %w", err)
+			}
+			break
+		}
+		var record keyRecord
+		if err := json.Unmarshal(rest[:end], &record); err != nil {
+			return nil, fmt.Errorf("invalid key on line %d: %w", line, err)
+		}
+		k.apply(record)
+		rest = rest[end+1:]
+	}
+	return k, nil
+}
+
+// apply applies one sidecar line
+// Note: Assumes lock is already held (or the store is not shared yet)
+func (k *keyStore) apply(record keyRecord) {
+	if key, ok := k.keys[record.ID]; ok {
+		delete(k.ids, key)
+		delete(k.keys, record.ID)
+	}
+	if record.Deleted {
+		return
+	}
+	if id, ok := k.ids[record.Key]; ok {
+		delete(k.keys, id)
+	}
+	k.ids[record.Key] = record.ID
+	k.keys[record.ID] = record.Key
+	if record.ID >= KeyedIDBase {
+		k.next = max(k.next, record.ID+1)
+	}
+}
+
+// append records a change in the sidecar, then applies it
+// Note: Assumes lock is already held
+func (k *keyStore) append(record keyRecord) error {
+	if k.journal == nil {
+		file, err := os.OpenFile(k.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open key file: %w", err)
+		}
+		k.journal = file
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode key %q: %w", record.Key, err)
+	}
+	if _, err := k.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	k.apply(record)
+	k.dirty = true
+	return nil
+}
+
+// assign returns the ID of key, assigning the next free one if needed
+// IDs for which stored reports true, e.g. inserted under a numeric ID, are skipped
+func (k *keyStore) assign(key string, stored func(id uint64) bool) (uint64, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id, ok := k.ids[key]; ok {
+		return id, nil
+	}
+	id := k.next
+	for stored(id) {
+		id++
+	}
+	if err := k.append(keyRecord{Key: key, ID: id}); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// set binds key to id, replacing the previous key of id
+func (k *keyStore) set(id uint64, key string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if other, ok := k.ids[key]; ok {
+		if other == id {
+			return nil
+		}
+		return fmt.Errorf("%w: %q belongs to vector %d", ErrKeyInUse, key, other)
+	}
+	return k.append(keyRecord{Key: key, ID: id})
+}
+
+// id returns the ID assigned to key
+func (k *keyStore) id(key string) (uint64, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	id, ok := k.ids[key]
+	return id, ok
+}
+
+// key returns the key assigned to id
+func (k *keyStore) key(id uint64) (string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// remove drops the key of id, if any
+// The key is dropped even if recording that fails; the next save persists it
+func (k *keyStore) remove(id uint64) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return nil
+	}
+	record := keyRecord{ID: id, Deleted: true}
+	if err := k.append(record); err != nil {
+		k.apply(record)
+		k.dirty = true
+		return err
+	}
+	return nil
+}
+
+// records returns the table in ID order
+func (k *keyStore) records() []keyRecord {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.sorted()
+}
+
+// sorted implements records
+// Note: Assumes lock is already held
+func (k *keyStore) sorted() []keyRecord {
+	records := make([]keyRecord, 0, len(k.keys))
+	for id, key := range k.keys {
+		records = append(records, keyRecord{Key: key, ID: id})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records
+}
+
+// attach fills in the key of every keyed result
+func (k *keyStore) attach(results []index.SearchResult) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if len(k.keys) == 0 {
+		return
+	}
+	for i := range results {
+		results[i].Key = k.keys[results[i].ID]
+	}
+}
+
+// flush fsyncs the lines appended since the last save
+func (k *keyStore) flush() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.journal == nil {
+		return nil
+	}
+	if err := k.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync key file: %w", err)
+	}
+	return nil
+}
+
+// save rewrites the sidecar atomically (temp file + rename) if anything
+// changed, dropping superseded lines
+func (k *keyStore) save() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.journal != nil {
+		if err := k.journal.Close(); err != nil {
+			return fmt.Errorf("failed to close key file: %w", err)
+		}
+		k.journal = nil
+	}
+	if !k.dirty {
+		return nil
+	}
+	if len(k.ids) == 0 {
+		if err := os.Remove(k.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove key file: %w", err)
+		}
+		k.dirty = false
+		return nil
+	}
+
+	tmpPath := k.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range k.sorted() {
+		if err := encoder.Encode(record); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to encode key %q: %w", record.Key, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync key file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close key file: %w", err)
+	}
+	if err := os.Rename(tmpPath, k.path); err != nil {
+		return fmt.Errorf("failed to replace key file: %w", err)
+	}
+	k.dirty = false
+	return nil
+}
+
+// InsertKey adds a vector under a string key and returns the ID assigned to it
+// Inserting an existing key replaces its vector under the same ID
+func (v *VecLite) InsertKey(key string, vector []float32) (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.insertKey(ctx, key, vector, nil, false)
+}
+
+// InsertKeyWithMetadata is InsertKey replacing the metadata of the vector
+func (v *VecLite) InsertKeyWithMetadata(key string, vector []float32, metadata Metadata) (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.insertKey(ctx, key, vector, metadata, true)
+}
+
+// insertKey implements InsertKey and InsertKeyWithMetadata
+// A key assigned by a failed insert keeps its ID for the next attempt
+func (v *VecLite) insertKey(ctx context.Context, key string, vector []float32, metadata Metadata, setMetadata bool) (uint64, error) {
+	if key == "" {
+		return 0, errors.New("key must not be empty")
+	}
+	v.mu.RLock()
+	id, err := v.keys.assign(key, v.exists)
+	v.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	if err := v.insert(ctx, id, vector, metadata, setMetadata, true); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetKey reads the vector stored under key
+func (v *VecLite) GetKey(key string) ([]float32, error) {
+	id, ok := v.keys.id(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+	return v.Get(id)
+}
+
+// DeleteKey removes the vector stored under key, releasing the key
+func (v *VecLite) DeleteKey(key string) error {
+	id, ok := v.keys.id(key)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, key)
+	}
+	return v.Delete(id)
+}
+
+// KeyID returns the ID assigned to key, e.g. to use it with Batch or filters
+func (v *VecLite) KeyID(key string) (uint64, bool) {
+	return v.keys.id(key)
+}
+
+// IDKey returns the key a vector was inserted under with InsertKey
+func (v *VecLite) IDKey(id uint64) (string, bool) {
+	return v.keys.key(id)
+}
+
+// SetKey binds an external string key to the vector stored under id, replacing
+// its previous key, so it can be looked up both ways (KeyID, IDKey, GetKey)
+// The key goes into the same table as those of InsertKey and follows the
+// vector: it is recorded as part of this call and dropped when the vector is
+// deleted. Fails with ErrKeyInUse if key belongs to another vector
+// Requires exclusive write lock - blocks all reads and other writes
+func (v *VecLite) SetKey(id uint64, key string) (err error) {
+	if key == "" {
+		return errors.New("key must not be empty")
+	}
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	defer v.label(ctx, "set_key")()
+
+	if err := v.lockContext(ctx); err != nil {
+		return fmt.Errorf("set key: %w", err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic("set_key", &err)
+
+	if _, err := v.index.ReadVector(id); err != nil {
+		if _, cold := v.tiers.get(id); !cold {
+			return err
+		}
+	}
+	return v.keys.set(id, key)
+}
+
+// ExportKeys writes the table between IDs and string keys to w as JSON lines
+// ({"key":...,"id":...}) in ID order and returns the number of keys written
+func (v *VecLite) ExportKeys(w io.Writer) (int, error) {
+	records := v.keys.records()
+	writer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for n, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return n, fmt.Errorf("failed to export key %q: %w", record.Key, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to export keys: %w", err)
+	}
+	return len(records), nil
+}
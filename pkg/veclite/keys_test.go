@@ -0,0 +1,266 @@
+package veclite
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVecLite_Keys(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		defer os.Remove(db.config.DataPath + ".keys")
+
+		// Numeric and keyed IDs live side by side
+		if err := db.Insert(1, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		near := make([]float32, 128)
+		near[0] = 0.1
+		id, err := db.InsertKeyWithMetadata("doc-1", near, Metadata{"tag": "a"})
+		if err != nil {
+			t.Fatalf("InsertKeyWithMetadata failed: %v", err)
+		}
+		if id != KeyedIDBase {
+			t.Errorf("Expected the first keyed ID, got %d", id)
+		}
+		if again, err := db.InsertKey("doc-1", near); err != nil || again != id {
+			t.Errorf("Expected reinsert to keep ID %d, got %d, %v", id, again, err)
+		}
+		if other, _ := db.InsertKey("doc-2", make([]float32, 128)); other != id+1 {
+			t.Errorf("Expected the next keyed ID, got %d", other)
+		}
+
+		if vec, err := db.GetKey("doc-1"); err != nil || vec[0] != 0.1 {
+			t.Errorf("Expected the vector of doc-1, got %v, %v", vec, err)
+		}
+		if key, ok := db.IDKey(id); !ok || key != "doc-1" {
+			t.Errorf("Expected key doc-1, got %q, %v", key, ok)
+		}
+		if metadata, _ := db.GetMetadata(id); metadata["tag"] != "a" {
+			t.Errorf("Expected metadata of doc-1, got %v", metadata)
+		}
+
+		results, err := db.Search(near, 3)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		want := map[uint64]string{1: "", id: "doc-1", id + 1: "doc-2"}
+		if len(results) == 0 || results[0].ID != id {
+			t.Errorf("Expected doc-1 as the nearest result, got %v", results)
+		}
+		for _, r := range results {
+			if r.Key != want[r.ID] {
+				t.Errorf("Result %d: expected key %q, got %q", r.ID, want[r.ID], r.Key)
+			}
+		}
+
+		if err := db.DeleteKey("doc-1"); err != nil {
+			t.Fatalf("DeleteKey failed: %v", err)
+		}
+		if _, err := db.GetKey("doc-1"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound, got %v", err)
+		}
+		if err := db.DeleteKey("doc-1"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected ErrKeyNotFound, got %v", err)
+		}
+		if _, err := db.InsertKey("", near); err == nil {
+			t.Error("Expected error for empty key")
+		}
+	})
+}
+
+func TestVecLite_Keys_Persistence(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/keys.db"
+	config.Dimension = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := db.InsertKey(key, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("InsertKey failed: %v", err)
+		}
+	}
+	// Deleting by ID releases the key too
+	if err := db.Delete(KeyedIDBase + 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if id, ok := db.KeyID("c"); !ok || id != KeyedIDBase+2 {
+		t.Errorf("Expected key c to keep its ID, got %d, %v", id, ok)
+	}
+	if _, ok := db.KeyID("b"); ok {
+		t.Error("Expected key b to be gone")
+	}
+	if _, err := db.GetKey("a"); err != nil {
+		t.Errorf("GetKey failed: %v", err)
+	}
+	// IDs are not reused after a reload
+	if id, _ := db.InsertKey("d", []float32{1, 2, 3, 4}); id != KeyedIDBase+3 {
+		t.Errorf("Expected a fresh ID, got %d", id)
+	}
+}
+
+func TestVecLite_Keys_NumericIDs(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/hashed.db"
+	config.Dimension = 4
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	// Hashed 64-bit IDs use the whole ID space; keys skip the IDs they took
+	for _, id := range []uint64{KeyedIDBase, KeyedIDBase + 1, ^uint64(0) - 1} {
+		if err := db.Insert(id, []float32{1, 0, 0, 0}); err != nil {
+			t.Fatalf("Insert of %d failed: %v", id, err)
+		}
+	}
+	id, err := db.InsertKey("doc", []float32{0, 1, 0, 0})
+	if err != nil {
+		t.Fatalf("InsertKey failed: %v", err)
+	}
+	if id != KeyedIDBase+2 {
+		t.Errorf("Expected the first free keyed ID %d, got %d", KeyedIDBase+2, id)
+	}
+	if vec, err := db.Get(KeyedIDBase); err != nil || vec[0] != 1 {
+		t.Errorf("Expected the numeric vector to stay, got %v, %v", vec, err)
+	}
+	if _, ok := db.IDKey(KeyedIDBase); ok {
+		t.Error("Expected no key for the numeric ID")
+	}
+}
+
+func TestOpenKeyStore_Invalid(t *testing.T) {
+	path := t.TempDir() + "/bad.keys"
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := openKeyStore(path); err == nil {
+		t.Error("Expected error for invalid key file")
+	}
+}
+
+func TestVecLite_SetKey(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/setkey.db"
+	config.Dimension = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	for id := uint64(1); id <= 2; id++ {
+		if err := db.Insert(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	if err := db.SetKey(1, "ext-1"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if id, ok := db.KeyID("ext-1"); !ok || id != 1 {
+		t.Errorf("Expected ext-1 to map to 1, got %d, %v", id, ok)
+	}
+	if vec, err := db.GetKey("ext-1"); err != nil || vec[0] != 1 {
+		t.Errorf("Expected the vector of ext-1, got %v, %v", vec, err)
+	}
+	if err := db.SetKey(2, "ext-1"); !errors.Is(err, ErrKeyInUse) {
+		t.Errorf("Expected ErrKeyInUse, got %v", err)
+	}
+	if err := db.SetKey(3, "ext-3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing vector, got %v", err)
+	}
+
+	// Rebinding replaces the previous key of the vector
+	if err := db.SetKey(1, "ext-one"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+	if _, ok := db.KeyID("ext-1"); ok {
+		t.Error("Expected ext-1 to be released")
+	}
+	if key, ok := db.IDKey(1); !ok || key != "ext-one" {
+		t.Errorf("Expected key ext-one, got %q, %v", key, ok)
+	}
+	if err := db.SetKey(2, "ext-2"); err != nil {
+		t.Fatalf("SetKey failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := db.ExportKeys(&buf)
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 exported keys, got %d, %v", n, err)
+	}
+	want := `{"key":"ext-one","id":1}` + "\n" + `{"key":"ext-2","id":2}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected export %q, got %q", want, buf.String())
+	}
+}
+
+func TestVecLite_Keys_Journal(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/journal.db"
+	config.Dimension = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := db.InsertKey(key, []float32{1, 2, 3, 4}); err != nil {
+			t.Fatalf("InsertKey failed: %v", err)
+		}
+	}
+	if err := db.DeleteKey("a"); err != nil {
+		t.Fatalf("DeleteKey failed: %v", err)
+	}
+
+	// Changes reach the sidecar without a Close; a torn last line is dropped
+	keys, err := openKeyStore(config.DataPath + ".keys")
+	if err != nil {
+		t.Fatalf("openKeyStore failed: %v", err)
+	}
+	if _, ok := keys.id("a"); ok {
+		t.Error("Expected key a to be deleted")
+	}
+	if id, ok := keys.id("b"); !ok || id != KeyedIDBase+1 {
+		t.Errorf("Expected key b at %d, got %d, %v", KeyedIDBase+1, id, ok)
+	}
+	file, err := os.OpenFile(config.DataPath+".keys", os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	file.WriteString(`{"key":"c","i`)
+	file.Close()
+	if keys, err = openKeyStore(config.DataPath + ".keys"); err != nil || len(keys.records()) != 1 {
+		t.Errorf("Expected the torn line to be dropped, got %v, %v", keys.records(), err)
+	}
+
+	// Close compacts the sidecar to the live keys
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data, err := os.ReadFile(config.DataPath + ".keys")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected 1 line after Close, got %q", data)
+	}
+}
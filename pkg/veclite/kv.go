@@ -0,0 +1,319 @@
+package veclite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MaxKVValueSize is the largest value KV.Put accepts; the namespace is meant
+// for small blobs such as checkpoints, not for documents
+const MaxKVValueSize = 64 * 1024
+
+// ErrKVNotFound is returned by KV.Get for keys that were never put or were deleted
+var ErrKVNotFound = errors.New("kv key not found")
+
+// KV is a small key-value namespace stored with the vectors, e.g. for the last
+// ingested offset of a stream; see VecLite.KV
+type KV struct {
+	v *VecLite
+}
+
+// kvStore holds the KV namespace in memory and persists it to a JSON-lines
+// sidecar (DataPath + ".kv") the same way as the key table: every change is
+// appended as part of the write that makes it, and the file is rewritten
+// without superseded lines on Sync/Close
+// Every line carries the sequence number of the data file when it was written;
+// on open, lines ahead of the data file (whose records a crash lost) are
+// dropped, so a checkpoint never covers vectors that are not stored
+type kvStore struct {
+	mu      sync.RWMutex
+	path    string
+	values  map[string][]byte
+	seqs    map[string]uint64 // Data file sequence number of the line of every key
+	dirty   bool              // Changed since the last save
+	journal *os.File          // Sidecar opened for appending changes (nil until the first change)
+}
+
+// kvRecord is one line of the sidecar; values are base64 in JSON
+// Lines are applied in order; a deleted line drops the key
+type kvRecord struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Seq     uint64 `json:"seq,omitempty"` // Data file sequence number when written
+}
+
+// openKVStore loads the sidecar at path if it exists, up to seq, the sequence
+// number of the data file
+// A torn last line, left by a crash while appending, is dropped; so are lines
+// written after data file records the crash lost, and the sidecar is rewritten
+// without them so later writes don't bring them back
+func openKVStore(path string, seq uint64) (*kvStore, error) {
+	s := &kvStore{path: path, values: make(map[string][]byte), seqs: make(map[string]uint64)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kv file: %w", err)
+	}
+
+	for line, rest := 1, data; len(rest) > 0; line++ {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 { // Every line written ends with a newline
+			if err := os.Truncate(path, int64(len(data)-len(rest))); err != nil {
+				return nil, fmt.Errorf("failed to drop torn kv entry: %w", err)
+			}
+			break
+		}
+		var record kvRecord
+		if err := json.Unmarshal(rest[:end], &record); err != nil {
+			return nil, fmt.Errorf("invalid kv entry on line %d: %w", line, err)
+		}
+		if record.Seq <= seq {
+			s.apply(record)
+		} else {
+			s.dirty = true
+		}
+		rest = rest[end+1:]
+	}
+	if s.dirty {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// apply applies one sidecar line
+// Note: Assumes lock is already held (or the store is not shared yet)
+func (s *kvStore) apply(record kvRecord) {
+	if record.Deleted {
+		delete(s.values, record.Key)
+		delete(s.seqs, record.Key)
+		return
+	}
+	s.values[record.Key] = record.Value
+	s.seqs[record.Key] = record.Seq
+}
+
+// append records a change in the sidecar, then applies it
+// Note: Assumes lock is already held
+func (s *kvStore) append(record kvRecord) error {
+	if s.journal == nil {
+		file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open kv file: %w", err)
+		}
+		s.journal = file
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode kv entry %q: %w", record.Key, err)
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write kv file: %w", err)
+	}
+	s.apply(record)
+	s.dirty = true
+	return nil
+}
+
+// get returns a copy of the value of key
+func (s *kvStore) get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte{}, value...), true
+}
+
+// put stores a copy of value under key; seq is the sequence number of the
+// data file, covering the vectors written before
+func (s *kvStore) put(key string, value []byte, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(kvRecord{Key: key, Value: append([]byte{}, value...), Seq: seq})
+}
+
+// remove drops key; removing a missing key is a no-op
+func (s *kvStore) remove(key string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; !ok {
+		return nil
+	}
+	return s.append(kvRecord{Key: key, Deleted: true, Seq: seq})
+}
+
+// keys returns the keys in sorted order
+func (s *kvStore) keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted()
+}
+
+// sorted implements keys
+// Note: Assumes lock is already held
+func (s *kvStore) sorted() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flush fsyncs the lines appended since the last save
+func (s *kvStore) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync kv file: %w", err)
+	}
+	return nil
+}
+
+// save rewrites the sidecar atomically (temp file + rename) if anything
+// changed, dropping superseded lines
+func (s *kvStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal != nil {
+		if err := s.journal.Close(); err != nil {
+			return fmt.Errorf("failed to close kv file: %w", err)
+		}
+		s.journal = nil
+	}
+	if !s.dirty {
+		return nil
+	}
+	if len(s.values) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove kv file: %w", err)
+		}
+		s.dirty = false
+		return nil
+	}
+
+	tmpPath := s.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create kv file: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, key := range s.sorted() {
+		if err := encoder.Encode(kvRecord{Key: key, Value: s.values[key], Seq: s.seqs[key]}); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to encode kv entry %q: %w", key, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write kv file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync kv file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close kv file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace kv file: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// validateKV checks a key and value before they are written
+func validateKV(key string, value []byte) error {
+	if key == "" {
+		return errors.New("kv key must not be empty")
+	}
+	if len(value) > MaxKVValueSize {
+		return fmt.Errorf("kv value of %d bytes exceeds the limit of %d", len(value), MaxKVValueSize)
+	}
+	return nil
+}
+
+// KV returns the key-value namespace of the database, for small application
+// blobs that must stay consistent with the vectors, e.g. the last ingested
+// offset of a stream
+// Writes take the same write lock as vector writes and are appended to the .kv
+// sidecar with the sequence number of the data file, made durable by
+// Sync/Close. After a crash a value is only kept if the data file kept every
+// record written before it, so a checkpoint never claims lost vectors; put it
+// in the same Batch as the vectors it covers (Batch.PutKV) to apply both
+// under one lock
+func (v *VecLite) KV() *KV {
+	return &KV{v: v}
+}
+
+// Get returns a copy of the value stored under key
+func (kv *KV) Get(key string) ([]byte, error) {
+	value, ok := kv.v.kv.get(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrKVNotFound, key)
+	}
+	return value, nil
+}
+
+// Put stores value under key, replacing the previous value
+// Requires exclusive write lock - blocks all reads and other writes
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (kv *KV) Put(key string, value []byte) error {
+	if err := validateKV(key, value); err != nil {
+		return err
+	}
+	return kv.write("kv_put", func() error { return kv.v.kv.put(key, value, kv.v.storage.Seq()) })
+}
+
+// Delete removes key; deleting a missing key is not an error
+// Requires exclusive write lock - blocks all reads and other writes
+func (kv *KV) Delete(key string) error {
+	return kv.write("kv_delete", func() error { return kv.v.kv.remove(key, kv.v.storage.Seq()) })
+}
+
+// Keys returns the stored keys in sorted order
+func (kv *KV) Keys() []string {
+	return kv.v.kv.keys()
+}
+
+// write runs fn under the write lock, like a vector write
+func (kv *KV) write(op string, fn func() error) (err error) {
+	v := kv.v
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	defer v.label(ctx, op)()
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer v.mu.Unlock()
+	defer v.recoverPanic(op, &err)
+
+	return fn()
+}
@@ -0,0 +1,143 @@
+package veclite
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVecLite_KV(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/kv.db"
+	config.Dimension = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	kv := db.KV()
+	if _, err := kv.Get("offset"); !errors.Is(err, ErrKVNotFound) {
+		t.Errorf("Expected ErrKVNotFound, got %v", err)
+	}
+	if err := kv.Put("", []byte("x")); err == nil {
+		t.Error("Expected an empty key to be rejected")
+	}
+	if err := kv.Put("big", make([]byte, MaxKVValueSize+1)); err == nil {
+		t.Error("Expected an oversized value to be rejected")
+	}
+
+	value := []byte("41")
+	if err := kv.Put("offset", value); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	value[0] = '9' // The stored value is a copy
+	if got, err := kv.Get("offset"); err != nil || string(got) != "41" {
+		t.Errorf("Expected 41, got %q, %v", got, err)
+	}
+
+	// A checkpoint applied with the vectors it covers
+	var batch Batch
+	batch.Insert(1, []float32{1, 2, 3, 4})
+	batch.PutKV("offset", []byte("42"))
+	batch.PutKV("tmp", []byte("x"))
+	batch.DeleteKV("tmp")
+	if err := db.Apply(&batch); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got, _ := kv.Get("offset"); string(got) != "42" || db.Size() != 1 {
+		t.Errorf("Expected offset 42 with 1 vector, got %q with %d", got, db.Size())
+	}
+	if keys := kv.Keys(); len(keys) != 1 || keys[0] != "offset" {
+		t.Errorf("Expected keys [offset], got %v", keys)
+	}
+
+	// An invalid KV write rejects the whole batch before anything is applied
+	var bad Batch
+	bad.Insert(2, []float32{1, 2, 3, 4})
+	bad.PutKV("", nil)
+	if err := db.Apply(&bad); err == nil || !strings.Contains(err.Error(), `key ""`) {
+		t.Errorf("Expected the empty key to be rejected, got %v", err)
+	}
+	if db.Size() != 1 {
+		t.Errorf("Expected the rejected batch not to be applied, got %d vectors", db.Size())
+	}
+
+	// Entries reach the sidecar without a Close, and survive reopening
+	reopened, err := openKVStore(config.DataPath+".kv", db.storage.Seq())
+	if err != nil {
+		t.Fatalf("openKVStore failed: %v", err)
+	}
+	if got, ok := reopened.get("offset"); !ok || string(got) != "42" {
+		t.Errorf("Expected journaled offset 42, got %q, %v", got, ok)
+	}
+	if err := kv.Delete("missing"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	data, err := os.ReadFile(config.DataPath + ".kv")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected 1 line after Close, got %q", data)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if got, err := db.KV().Get("offset"); err != nil || string(got) != "42" {
+		t.Errorf("Expected offset 42 after reopening, got %q, %v", got, err)
+	}
+}
+
+func TestOpenKVStore_TornLine(t *testing.T) {
+	path := t.TempDir() + "/torn.kv"
+	if err := os.WriteFile(path, []byte(`{"key":"a","value":"MQ=="}`+"\n"+`{"key":"b","va`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	s, err := openKVStore(path, 0)
+	if err != nil {
+		t.Fatalf("openKVStore failed: %v", err)
+	}
+	if keys := s.keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("Expected the torn line to be dropped, got %v", keys)
+	}
+
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := openKVStore(path, 0); err == nil {
+		t.Error("Expected an invalid line to fail")
+	}
+}
+
+func TestOpenKVStore_AheadOfData(t *testing.T) {
+	path := t.TempDir() + "/ahead.kv"
+	lines := `{"key":"a","value":"MQ==","seq":1}` + "\n" + `{"key":"b","value":"Mg==","seq":5}` + "\n" + `{"key":"a","deleted":true,"seq":6}` + "\n"
+	if err := os.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// The data file only kept the records up to sequence number 3
+	s, err := openKVStore(path, 3)
+	if err != nil {
+		t.Fatalf("openKVStore failed: %v", err)
+	}
+	if keys := s.keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("Expected only the entry covered by the data file, got %v", keys)
+	}
+
+	// The dropped lines are gone for good, even once the data file moves past them
+	s, err = openKVStore(path, 10)
+	if err != nil {
+		t.Fatalf("openKVStore failed: %v", err)
+	}
+	if got, ok := s.get("a"); len(s.keys()) != 1 || !ok || string(got) != "1" {
+		t.Errorf("Expected only a = 1 after reopening, got %v", s.keys())
+	}
+}
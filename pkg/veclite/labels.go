@@ -0,0 +1,62 @@
+package veclite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"unsafe"
+)
+
+// pprof label keys set on goroutines running VecLite operations, so CPU and
+// goroutine profiles attribute time to the operation and database
+const (
+	LabelOperation  = "veclite.operation"  // "search", "candidates", "insert", "delete", "batch", "get", "load", "rebuild", "maintenance" or "canary"
+	LabelCollection = "veclite.collection" // Config.Name of the database
+	LabelTrace      = "veclite.trace"      // SearchOptions.TraceID of a search (only set if given)
+)
+
+// databaseName returns the name identifying the database in pprof labels and
+// expvar: Config.Name, or the data file name without its extension
+func databaseName(config *Config) string {
+	if config.Name != "" {
+		return config.Name
+	}
+	base := filepath.Base(config.DataPath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// The labels of the calling goroutine are read and restored as they are, so
+// labels set without a context reaching VecLite survive the call; the runtime
+// keeps these two functions for packages outside the standard library
+//
+//go:linkname runtimeGetProfLabel runtime/pprof.runtime_getProfLabel
+func runtimeGetProfLabel() unsafe.Pointer
+
+//go:linkname runtimeSetProfLabel runtime/pprof.runtime_setProfLabel
+func runtimeSetProfLabel(labels unsafe.Pointer)
+
+// label sets pprof labels naming op and the database on the calling
+// goroutine, on top of the labels of ctx, plus the extra key/value pairs
+// whose value is not empty; calling the returned function restores the
+// labels the goroutine had before, whether or not ctx carries them
+func (v *VecLite) label(ctx context.Context, op string, extra ...string) func() {
+	labels := []string{LabelOperation, op, LabelCollection, v.name}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if extra[i+1] != "" {
+			labels = append(labels, extra[i], extra[i+1])
+		}
+	}
+	previous := runtimeGetProfLabel()
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(labels...)))
+	return func() { runtimeSetProfLabel(previous) }
+}
+
+// traceSuffix formats a trace ID for appending to log messages ("" if none)
+func traceSuffix(traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (trace %s)", traceID)
+}
@@ -0,0 +1,75 @@
+package veclite
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+// goroutineLabels returns the goroutine profile, which lists the pprof labels
+// of every goroutine
+func goroutineLabels(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatalf("Failed to write goroutine profile: %v", err)
+	}
+	return buf.String()
+}
+
+func TestVecLite_PprofLabels(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "labels.db")
+	config.Dimension = 4
+	var during string
+	config.BeforeSearch = func(query []float32, k int) error {
+		during = goroutineLabels(t)
+		return nil
+	}
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Insert(1, []float32{1, 0, 0, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request", "r1"))
+	pprof.SetGoroutineLabels(ctx)
+	defer pprof.SetGoroutineLabels(context.Background())
+	if _, err := db.SearchContext(ctx, []float32{1, 0, 0, 0}, 1); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	for _, want := range []string{`"veclite.operation":"search"`, `"veclite.collection":"labels"`, `"request":"r1"`} {
+		if !strings.Contains(during, want) {
+			t.Errorf("Expected label %s during search", want)
+		}
+	}
+	// The caller's labels are restored afterwards
+	if after := goroutineLabels(t); strings.Contains(after, `"veclite.operation":"search"`) {
+		t.Error("Expected the search label to be removed after the search")
+	}
+
+	// Also when the call gets no context carrying them
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("request", "r2")))
+	if _, err := db.Get(1); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if after := goroutineLabels(t); !strings.Contains(after, `"request":"r2"`) || strings.Contains(after, `"veclite.operation":"get"`) {
+		t.Error("Expected the caller's labels to be restored after Get")
+	}
+}
+
+func TestDatabaseName(t *testing.T) {
+	if name := databaseName(&Config{DataPath: "/data/products.db"}); name != "products" {
+		t.Errorf("Expected name from the data file, got %q", name)
+	}
+	if name := databaseName(&Config{DataPath: "/data/products.db", Name: "catalog"}); name != "catalog" {
+		t.Errorf("Expected Config.Name, got %q", name)
+	}
+}
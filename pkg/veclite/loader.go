@@ -0,0 +1,44 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// ErrNotFound is wrapped by Get errors for IDs that are not stored
+var ErrNotFound = index.ErrNotFound
+
+// ErrTornWrite is wrapped by write errors when a failed write left part of a
+// record in the data file that could not be removed; writes keep failing with
+// it until the record is removed or the database is reopened
+var ErrTornWrite = storage.ErrTornWrite
+
+// load fetches a missing vector through Config.Loader and inserts it
+// Concurrent loads of the same ID share one Loader call
+func (v *VecLite) load(id uint64) ([]float32, error) {
+	vector, err, shared := v.loads.Do(id, func() ([]float32, error) {
+		vector, err := v.config.Loader(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load vector %d: %w", id, err)
+		}
+		if len(vector) != v.inputDim {
+			return nil, fmt.Errorf("loaded vector %d has dimension %d, expected %d", id, len(vector), v.inputDim)
+		}
+		if err := v.Insert(id, vector); errors.Is(err, ErrAlreadyExists) {
+			return v.get(id) // Inserted since the miss; the stored vector wins
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to insert loaded vector %d: %w", id, err)
+		}
+		return v.preprocess(vector), nil // As stored
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return append([]float32(nil), vector...), nil // Each caller owns its copy
+	}
+	return vector, nil
+}
@@ -0,0 +1,91 @@
+package veclite
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVecLite_Get_NotFound(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if _, err := db.Get(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestVecLite_Loader(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	db.config.Loader = func(id uint64) ([]float32, error) {
+		calls.Add(1)
+		<-release
+		if id == 99 {
+			return nil, errors.New("embedding service unavailable")
+		}
+		vec := make([]float32, 128)
+		vec[0] = float32(id)
+		return vec, nil
+	}
+
+	// Concurrent misses for the same ID share one Loader call
+	const callers = 8
+	var wg sync.WaitGroup
+	vectors := make([][]float32, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vectors[i], errs[i] = db.Get(7)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 Loader call, got %d", n)
+	}
+	for i := range vectors {
+		if errs[i] != nil || vectors[i][0] != 7 {
+			t.Errorf("Caller %d: unexpected result %v (err %v)", i, vectors[i], errs[i])
+		}
+	}
+
+	// The loaded vector was inserted: later Gets and searches don't call the Loader
+	if _, err := db.Get(7); err != nil {
+		t.Fatalf("Get after load failed: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected stored vector to be served without Loader, got %d calls", n)
+	}
+	if db.Size() != 1 {
+		t.Errorf("Expected 1 stored vector, got %d", db.Size())
+	}
+
+	if _, err := db.Get(99); err == nil {
+		t.Error("Expected Loader error to be returned")
+	}
+	if db.Size() != 1 {
+		t.Errorf("Failed load must not insert, got size %d", db.Size())
+	}
+}
+
+func TestVecLite_Loader_DimensionMismatch(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	db.config.Loader = func(id uint64) ([]float32, error) {
+		return make([]float32, 3), nil
+	}
+	if _, err := db.Get(1); err == nil {
+		t.Error("Expected error for loaded vector with wrong dimension")
+	}
+}
@@ -0,0 +1,123 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingLog appends lines to a file, shifting it to Path.1 ... Path.N once
+// it exceeds a size limit; it backs the audit log and the query log
+type rotatingLog struct {
+	mu         sync.Mutex
+	name       string // Used in error messages, e.g. "audit log"
+	path       string
+	maxSize    int64 // Rotate once the file exceeds this many bytes (0 = never rotate)
+	maxBackups int   // Rotated files kept (0 = keep none)
+	sync       bool  // fsync after every line
+	file       *os.File
+	size       int64 // Current size of the active file
+}
+
+// openRotatingLog opens (or creates) the log at path for appending
+func openRotatingLog(name, path string, maxSize int64, maxBackups int, sync bool) (*rotatingLog, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s path is required", name)
+	}
+	l := &rotatingLog{name: name, path: path, maxSize: maxSize, maxBackups: maxBackups, sync: sync}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the active log file in append mode
+// Note: Assumes lock is already held (or the log is not shared yet)
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", l.name, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", l.name, err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// append writes one line, rotating the file first if it is full
+func (l *rotatingLog) append(line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New(l.name + " closed")
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write %s entry: %w", l.name, err)
+	}
+	if l.sync {
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// rotate shifts Path.N-1 -> Path.N ... Path -> Path.1 and starts a new file
+// The oldest backup beyond maxBackups is removed
+// Note: Assumes lock is already held
+func (l *rotatingLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s for rotation: %w", l.name, err)
+	}
+	l.file = nil
+
+	if l.maxBackups <= 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove full %s: %w", l.name, err)
+		}
+		return l.open()
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups))
+	for n := l.maxBackups - 1; n >= 1; n-- {
+		from := fmt.Sprintf("%s.%d", l.path, n)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", l.path, n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate %s: %w", l.name, err)
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", l.name, err)
+	}
+	return l.open()
+}
+
+// Close syncs and closes the active log file
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	syncErr := l.file.Sync()
+	closeErr := l.file.Close()
+	l.file = nil
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
@@ -0,0 +1,258 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/index/hnsw"
+	"github.com/monishSR/veclite/internal/index/ivf"
+)
+
+// MaintenanceConfig enables the background maintenance scheduler
+// Each run executes the enabled tasks in order (graph repair, retrain, compaction);
+// every task holds the write lock while it runs, which is why runs are confined
+// to the configured windows
+type MaintenanceConfig struct {
+	Compaction  bool                // Rewrite the data file without tombstones and superseded records
+	Retrain     bool                // Recompute IVF clusters from the current data (IVF only)
+	GraphRepair bool                // Drop dangling links and relink unreachable nodes (HNSW only)
+	Schedule    []MaintenanceWindow // Windows in which runs may start (empty = any time)
+
+	Interval      time.Duration // Minimum time between runs (default: 24h)
+	CheckInterval time.Duration // How often the scheduler checks the clock (default: 1m)
+}
+
+// MaintenanceWindow is a daily window in local time
+type MaintenanceWindow struct {
+	Start time.Duration // Offset from midnight, e.g. 2*time.Hour for 02:00
+	End   time.Duration // Offset from midnight; End before Start wraps past midnight
+}
+
+// Contains reports whether t falls within the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// maintenanceRunner runs MaintenanceConfig tasks in the background
+type maintenanceRunner struct {
+	config MaintenanceConfig
+	now    func() time.Time // Clock (replaced in tests)
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu      sync.Mutex // Guards the fields below
+	lastRun time.Time
+	lastErr error
+}
+
+// newMaintenanceRunner applies defaults to config
+func newMaintenanceRunner(config MaintenanceConfig) *maintenanceRunner {
+	if config.Interval <= 0 {
+		config.Interval = 24 * time.Hour
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = time.Minute
+	}
+	return &maintenanceRunner{
+		config: config,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// due reports whether a run should start at t
+func (m *maintenanceRunner) due(t time.Time) bool {
+	m.mu.Lock()
+	lastRun := m.lastRun
+	m.mu.Unlock()
+	if !lastRun.IsZero() && t.Sub(lastRun) < m.config.Interval {
+		return false
+	}
+	if len(m.config.Schedule) == 0 {
+		return true
+	}
+	for _, window := range m.config.Schedule {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// startMaintenance starts the scheduler goroutine
+func (v *VecLite) startMaintenance(runner *maintenanceRunner) {
+	v.maintenance = runner
+	go func() {
+		defer v.label(context.Background(), "maintenance")()
+		defer close(runner.done)
+		ticker := time.NewTicker(runner.config.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runner.stop:
+				return
+			case <-ticker.C:
+				if runner.due(runner.now()) {
+					_ = v.RunMaintenance()
+				}
+			}
+		}
+	}()
+}
+
+// stopMaintenance stops the scheduler and waits for a running task to finish
+// Must be called without holding the lock
+func (v *VecLite) stopMaintenance() {
+	runner := v.maintenance
+	if runner == nil {
+		return
+	}
+	select {
+	case <-runner.stop:
+	default:
+		close(runner.stop)
+	}
+	<-runner.done
+}
+
+// RunMaintenance runs the tasks enabled in Config.Maintenance now, regardless of
+// the schedule, and records the result for Stats
+func (v *VecLite) RunMaintenance() error {
+	runner := v.maintenance
+	if runner == nil {
+		return errors.New("maintenance is not configured")
+	}
+
+	var errs []error
+	if runner.config.GraphRepair {
+		if _, err := v.RepairGraph(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if runner.config.Retrain {
+		if err := v.Retrain(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if runner.config.Compaction {
+		if err := v.Compact(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+
+	runner.mu.Lock()
+	runner.lastRun = runner.now()
+	runner.lastErr = err
+	runner.mu.Unlock()
+	return err
+}
+
+// Compact rewrites the data file without tombstones and superseded records
+// With Config.LocalityLayout records are laid out in index locality order
+// The file is copied without the write lock, paced by Config.BackgroundThrottle;
+// reads and writes go on meanwhile and only the final swap blocks them briefly
+func (v *VecLite) Compact() error {
+	v.compactMu.Lock()
+	defer v.compactMu.Unlock()
+
+	v.mu.Lock()
+	if v.config.LocalityLayout {
+		if orderer, ok := v.index.(index.LocalityOrderer); ok {
+			v.storage.SetLayoutOrder(orderer.LocalityOrder())
+		}
+	}
+	u, usageErr := v.storage.Usage()
+	size := v.index.Size() + v.tiers.size()
+	v.mu.Unlock()
+
+	defer v.rewrite.beginLive("compact", size)()
+	start := time.Now()
+	v.compacting = v.trackProgress(OpCompact)
+	defer v.compacting.finish()
+	if err := v.storage.Compact(); err != nil {
+		return fmt.Errorf("failed to compact storage: %w", err)
+	}
+	if usageErr == nil { // Measure throughput for CompactEstimate
+		v.mu.Lock()
+		v.compactions.bytes += compactionBytes(u)
+		v.compactions.elapsed += time.Since(start)
+		v.mu.Unlock()
+	}
+	return nil
+}
+
+// compactProgress receives the progress of storage compactions
+// Called by storage with compactMu held
+func (v *VecLite) compactProgress(written, total int) {
+	v.rewrite.progress(written, total)
+	v.compacting.report("", int64(written), int64(total), "records")
+}
+
+// Retrain recomputes the IVF clusters from the vectors currently stored, so
+// centroids follow data that drifted since they were chosen, by rebuilding the
+// index with Reindex (applying a changed NClusters); see RetrainIndex
+// No-op for other index types
+// Requires exclusive write lock - blocks all reads and writes while retraining
+func (v *VecLite) Retrain() error {
+	if !isIVF(v.config) {
+		return nil
+	}
+	if err := v.Reindex(); err != nil {
+		return fmt.Errorf("failed to retrain IVF clusters: %w", err)
+	}
+	return nil
+}
+
+// retrainSamplePerCluster is the number of vectors RetrainIndex samples per
+// IVF cluster to run k-means over
+const retrainSamplePerCluster = 256
+
+// RetrainIndex re-runs k-means over a sample of the stored vectors (256 per
+// cluster), reassigns every vector to its nearest new centroid and swaps in
+// the new clusters, restoring recall as the data drifts
+// Unlike Retrain, no vector is rewritten and NClusters stays as built
+// No-op for other index types
+// Requires exclusive write lock - blocks all reads and writes while retraining
+func (v *VecLite) RetrainIndex() error {
+	if !isIVF(v.config) {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	idx, ok := v.index.(*ivf.IVFIndex)
+	if !ok {
+		return errors.New("retrain: IVF index is not loaded")
+	}
+	defer v.beginRewrite("retrain")()
+	nClusters := idx.Params()["NClusters"]
+	if err := idx.Retrain(retrainSamplePerCluster * nClusters); err != nil {
+		return fmt.Errorf("failed to retrain IVF clusters: %w", err)
+	}
+	return nil
+}
+
+// RepairGraph repairs the HNSW graph and returns the number of fixes made
+// No-op for other index types
+// Requires exclusive write lock - blocks all reads and writes while repairing
+func (v *VecLite) RepairGraph() (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	graph, ok := v.index.(*hnsw.HNSWIndex)
+	if !ok {
+		return 0, nil
+	}
+	return graph.Repair(), nil
+}
@@ -0,0 +1,263 @@
+package veclite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	night := MaintenanceWindow{Start: 2 * time.Hour, End: 4 * time.Hour}
+	if !night.Contains(at(2, 0)) || !night.Contains(at(3, 59)) {
+		t.Error("Expected 02:00 and 03:59 inside 02:00-04:00")
+	}
+	if night.Contains(at(4, 0)) || night.Contains(at(1, 59)) {
+		t.Error("Expected 04:00 and 01:59 outside 02:00-04:00")
+	}
+
+	wrapping := MaintenanceWindow{Start: 23 * time.Hour, End: time.Hour}
+	if !wrapping.Contains(at(23, 30)) || !wrapping.Contains(at(0, 30)) {
+		t.Error("Expected 23:30 and 00:30 inside 23:00-01:00")
+	}
+	if wrapping.Contains(at(12, 0)) {
+		t.Error("Expected 12:00 outside 23:00-01:00")
+	}
+}
+
+func TestMaintenanceRunner_Due(t *testing.T) {
+	runner := newMaintenanceRunner(MaintenanceConfig{
+		Schedule: []MaintenanceWindow{{Start: 2 * time.Hour, End: 4 * time.Hour}},
+		Interval: time.Hour,
+	})
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	if runner.due(day.Add(12 * time.Hour)) {
+		t.Error("Run must not be due outside the schedule")
+	}
+	if !runner.due(day.Add(2 * time.Hour)) {
+		t.Error("First run should be due inside the window")
+	}
+
+	runner.lastRun = day.Add(2 * time.Hour)
+	if runner.due(day.Add(2*time.Hour + 30*time.Minute)) {
+		t.Error("Run must not be due before Interval has passed")
+	}
+	if !runner.due(day.Add(3*time.Hour + 30*time.Minute)) {
+		t.Error("Run should be due again once Interval has passed")
+	}
+
+	defaults := newMaintenanceRunner(MaintenanceConfig{})
+	if defaults.config.Interval != 24*time.Hour || defaults.config.CheckInterval != time.Minute {
+		t.Errorf("Unexpected defaults: %+v", defaults.config)
+	}
+}
+
+func TestVecLite_MaintenanceScheduler(t *testing.T) {
+	tmpFile := t.TempDir() + "/maintenance.db"
+	config := DefaultConfig()
+	config.DataPath = tmpFile
+	config.Dimension = 8
+	config.Maintenance = &MaintenanceConfig{
+		Compaction:    true,
+		CheckInterval: 5 * time.Millisecond,
+	}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := uint64(1); i <= 10; i++ {
+		if err := db.Insert(i, make([]float32, 8)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if err := db.Delete(i); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	// Wait for a run that saw the deletes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		usage, err := db.DiskUsage()
+		if err != nil {
+			t.Fatalf("DiskUsage failed: %v", err)
+		}
+		if usage.DeadData == 0 && !db.Stats().LastMaintenance.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Scheduled compaction did not run: %+v", usage)
+		}
+		// The first run may have happened before the deletes
+		db.maintenance.mu.Lock()
+		db.maintenance.lastRun = time.Time{}
+		db.maintenance.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats := db.Stats(); stats.MaintenanceError != "" || stats.Size != 5 {
+		t.Errorf("Unexpected stats after maintenance: %+v", stats)
+	}
+}
+
+func TestVecLite_RunMaintenance(t *testing.T) {
+	db, cleanup := createTestDB(t, "ivf")
+	defer cleanup()
+
+	if err := db.RunMaintenance(); err == nil {
+		t.Error("Expected error when maintenance is not configured")
+	}
+
+	for i := uint64(1); i <= 30; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Delete(3); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Configure the tasks without starting the scheduler
+	db.maintenance = newMaintenanceRunner(MaintenanceConfig{Compaction: true, Retrain: true, GraphRepair: true})
+	defer func() { db.maintenance = nil }()
+	if err := db.RunMaintenance(); err != nil {
+		t.Fatalf("RunMaintenance failed: %v", err)
+	}
+	if db.Size() != 29 {
+		t.Errorf("Expected 29 vectors after maintenance, got %d", db.Size())
+	}
+	query := make([]float32, 128)
+	query[0] = 20
+	results, err := db.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 20 {
+		t.Errorf("Expected nearest ID 20 after retrain, got %v (%v)", results, err)
+	}
+	if stats := db.Stats(); stats.LastMaintenance.IsZero() {
+		t.Error("Expected LastMaintenance to be recorded")
+	}
+}
+
+func TestVecLite_RepairGraph(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	for i := uint64(1); i <= 20; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	fixes, err := db.RepairGraph()
+	if err != nil || fixes != 0 {
+		t.Errorf("Expected no fixes on a healthy graph, got %d (%v)", fixes, err)
+	}
+
+	flatDB, cleanupFlat := createTestDB(t, "flat")
+	defer cleanupFlat()
+	if fixes, err := flatDB.RepairGraph(); err != nil || fixes != 0 {
+		t.Errorf("Expected RepairGraph to be a no-op for flat, got %d (%v)", fixes, err)
+	}
+	if err := flatDB.Retrain(); err != nil {
+		t.Errorf("Expected Retrain to be a no-op for flat, got %v", err)
+	}
+	if err := flatDB.RetrainIndex(); err != nil {
+		t.Errorf("Expected RetrainIndex to be a no-op for flat, got %v", err)
+	}
+}
+
+func TestVecLite_RetrainIndex(t *testing.T) {
+	db, cleanup := createTestDB(t, "ivf")
+	defer cleanup()
+
+	// The first vectors, which become the centroids, are all near the origin;
+	// later ones spread along the first dimension
+	id := uint64(1)
+	for i := 0; i < 10; i++ {
+		vec := make([]float32, 128)
+		vec[1] = float32(i) * 0.01
+		if err := db.Insert(id, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		id++
+	}
+	for i := 0; i < 200; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(id, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		id++
+	}
+
+	if err := db.RetrainIndex(); err != nil {
+		t.Fatalf("RetrainIndex failed: %v", err)
+	}
+	if size := db.Size(); size != 210 {
+		t.Fatalf("Expected 210 vectors after retraining, got %d", size)
+	}
+	for _, x := range []float32{5, 100, 195} {
+		query := make([]float32, 128)
+		query[0] = x
+		results, err := db.Search(query, 1)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Distance != 0 {
+			t.Fatalf("Expected an exact match at %v, got %+v", x, results)
+		}
+	}
+}
+
+func TestVecLite_BackgroundThrottle(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/throttle.db"
+	config.Dimension = 8
+	config.BackgroundThrottle = &ThrottleConfig{BytesPerSecond: 1 << 20, CPUBudget: 0.5}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := uint64(1); i <= 100; i++ {
+		if err := db.Insert(i, make([]float32, 8)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if state := db.Stats().Throttle; state.Bytes != 0 {
+		t.Errorf("Foreground writes must not be throttled, got %+v", state)
+	}
+	infoBytes := fileStart(t, db) - 32
+	for i := uint64(1); i <= 50; i++ {
+		if err := db.Delete(i); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+
+	if err := db.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	state := db.Stats().Throttle
+	if state.BytesPerSecond != 1<<20 || state.CPUBudget != 0.5 {
+		t.Errorf("Unexpected throttle limits: %+v", state)
+	}
+	// 100 records read and 50 rewritten, 58 bytes each, and the file info record
+	if want := 150*58 + 2*infoBytes; state.Bytes != want {
+		t.Errorf("Expected %d throttled bytes, got %d", want, state.Bytes)
+	}
+
+	if stats := db.Stats(); stats.Size != 50 {
+		t.Errorf("Expected 50 vectors after compaction, got %d", stats.Size)
+	}
+}
@@ -0,0 +1,230 @@
+package veclite
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// MemoryPressureConfig makes the database give memory back when the process
+// approaches its memory limit, instead of holding on to caches until the host
+// is OOM-killed
+// Memory in use is checked after every garbage collection, which the Go
+// runtime runs more often as the heap nears the limit
+type MemoryPressureConfig struct {
+	// Limit is the memory budget of the process in bytes (0 = the Go runtime
+	// memory limit set with debug.SetMemoryLimit or GOMEMLIMIT; without one,
+	// nothing is ever released)
+	Limit int64
+
+	HighWater float64       // Fraction of Limit in use that triggers a release (default: 0.85)
+	Release   float64       // Fraction of the cached vectors evicted per release (default: 0.5)
+	Cooldown  time.Duration // Minimum time between releases (default: 1s)
+
+	// OnRelease is called after every release triggered by memory pressure (optional)
+	OnRelease func(MemoryRelease)
+}
+
+// MemoryRelease describes memory given back by ReleaseMemory or under memory pressure
+type MemoryRelease struct {
+	Time         time.Time
+	InUse        int64 // Process memory in use that triggered the release (0 = ReleaseMemory call)
+	Limit        int64 // Memory limit it was compared against (0 = ReleaseMemory call)
+	CacheEvicted int   // Vectors evicted from the cache
+	CacheLeft    int   // Vectors still cached
+	Scratch      bool  // Whether the index scratch buffers were released
+}
+
+// MemoryStats reports the vector cache and memory releases in Stats
+type MemoryStats struct {
+	CachedVectors int           // Vectors in the storage cache
+	Releases      uint64        // Releases since open, manual and under pressure
+	Evicted       uint64        // Vectors evicted by releases since open
+	LastRelease   MemoryRelease // Most recent release (zero = none)
+}
+
+// memoryTracker counts releases for Stats
+type memoryTracker struct {
+	mu       sync.Mutex
+	releases uint64
+	evicted  uint64
+	last     MemoryRelease
+}
+
+// record adds a release
+func (m *memoryTracker) record(release MemoryRelease) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releases++
+	m.evicted += uint64(release.CacheEvicted)
+	m.last = release
+}
+
+// ReleaseMemory evicts fraction (0 to 1) of the cached vectors, least recently
+// used first, and drops the scratch buffers searches reuse, so their memory can
+// be reclaimed; a host with its own memory pressure signal (e.g. cgroup
+// notifications) calls it directly, see Config.MemoryPressure otherwise
+// The cache fills up again with later reads. Never waits for writes: scratch
+// buffers are left alone if the write lock is held
+func (v *VecLite) ReleaseMemory(fraction float64) MemoryRelease {
+	return v.releaseMemory(fraction, 0, 0)
+}
+
+// releaseMemory implements ReleaseMemory and records the release
+func (v *VecLite) releaseMemory(fraction float64, inUse, limit int64) MemoryRelease {
+	fraction = min(max(fraction, 0), 1)
+	cached := v.storage.CacheLen()
+	keep := cached - int(math.Ceil(float64(cached)*fraction))
+	release := MemoryRelease{
+		Time:         time.Now(),
+		InUse:        inUse,
+		Limit:        limit,
+		CacheEvicted: v.storage.TrimCache(keep),
+		CacheLeft:    v.storage.CacheLen(),
+	}
+	if v.mu.TryRLock() {
+		if releaser, ok := v.index.(index.ScratchReleaser); ok {
+			releaser.ReleaseScratch()
+			release.Scratch = true
+		}
+		v.mu.RUnlock()
+	}
+	v.memory.record(release)
+	return release
+}
+
+// memoryStats returns the memory section of Stats
+func (v *VecLite) memoryStats() MemoryStats {
+	v.memory.mu.Lock()
+	defer v.memory.mu.Unlock()
+	return MemoryStats{
+		CachedVectors: v.storage.CacheLen(),
+		Releases:      v.memory.releases,
+		Evicted:       v.memory.evicted,
+		LastRelease:   v.memory.last,
+	}
+}
+
+// memoryWatcher releases memory when a garbage collection finds the process
+// near its memory limit
+type memoryWatcher struct {
+	config  MemoryPressureConfig
+	gc      chan struct{} // Signaled after every garbage collection
+	stop    chan struct{}
+	done    chan struct{}
+	stopped atomic.Bool
+	inUse   func() int64 // Reads process memory in use (replaced in tests)
+}
+
+// newMemoryWatcher applies defaults to config
+func newMemoryWatcher(config MemoryPressureConfig) *memoryWatcher {
+	if config.HighWater <= 0 || config.HighWater > 1 {
+		config.HighWater = 0.85
+	}
+	if config.Release <= 0 || config.Release > 1 {
+		config.Release = 0.5
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = time.Second
+	}
+	return &memoryWatcher{
+		config: config,
+		gc:     make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		inUse:  memoryInUse,
+	}
+}
+
+// memoryMetrics are the runtime metrics the memory limit is enforced against
+var memoryMetrics = []string{"/memory/classes/total:bytes", "/memory/classes/heap/released:bytes"}
+
+// memoryInUse returns the memory the Go runtime counts against its memory limit
+func memoryInUse() int64 {
+	samples := make([]metrics.Sample, len(memoryMetrics))
+	for i, name := range memoryMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// limit returns the memory limit to compare against (math.MaxInt64 = none)
+func (w *memoryWatcher) limit() int64 {
+	if w.config.Limit > 0 {
+		return w.config.Limit
+	}
+	return debug.SetMemoryLimit(-1) // Reads the limit without changing it
+}
+
+// gcSentinel is an object that becomes garbage right away: its finalizer runs
+// after the next garbage collection and arms a new one
+type gcSentinel struct {
+	w *memoryWatcher
+}
+
+// armGC signals w.gc after the next garbage collection, and after every one
+// following it until the watcher is stopped
+// Finalizers run on a single goroutine shared by the process, so the finalizer
+// only signals the watcher goroutine
+func (w *memoryWatcher) armGC() {
+	runtime.SetFinalizer(&gcSentinel{w: w}, func(s *gcSentinel) {
+		if s.w.stopped.Load() {
+			return
+		}
+		select {
+		case s.w.gc <- struct{}{}:
+		default:
+		}
+		s.w.armGC()
+	})
+}
+
+// startMemoryWatcher starts releasing memory under pressure
+func (v *VecLite) startMemoryWatcher(w *memoryWatcher) {
+	v.memoryWatcher = w
+	w.armGC()
+	go func() {
+		defer v.label(context.Background(), "memory")()
+		defer close(w.done)
+		var last time.Time
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-w.gc:
+			}
+			limit := w.limit()
+			if limit == math.MaxInt64 || time.Since(last) < w.config.Cooldown {
+				continue
+			}
+			inUse := w.inUse()
+			if float64(inUse) < w.config.HighWater*float64(limit) {
+				continue
+			}
+			last = time.Now()
+			release := v.releaseMemory(w.config.Release, inUse, limit)
+			if w.config.OnRelease != nil {
+				w.config.OnRelease(release)
+			}
+		}
+	}()
+}
+
+// stopMemoryWatcher stops the watcher and waits for a running release to finish
+// Must be called without holding the lock
+func (v *VecLite) stopMemoryWatcher() {
+	w := v.memoryWatcher
+	if w == nil || w.stopped.Swap(true) {
+		return
+	}
+	close(w.stop)
+	<-w.done
+}
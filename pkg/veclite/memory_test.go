@@ -0,0 +1,110 @@
+package veclite
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestVecLite_ReleaseMemory(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	for i := uint64(1); i <= 200; i++ {
+		if err := db.Insert(i, testVector(int(i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := db.Search(testVector(7), 5); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	cached := db.Stats().Memory.CachedVectors
+	if cached == 0 {
+		t.Fatalf("Expected cached vectors after inserts")
+	}
+
+	release := db.ReleaseMemory(0.5)
+	if release.CacheEvicted != (cached+1)/2 || release.CacheLeft != cached-release.CacheEvicted || !release.Scratch {
+		t.Errorf("Expected half of %d cached vectors evicted and scratch released, got %+v", cached, release)
+	}
+	if release = db.ReleaseMemory(1); release.CacheLeft != 0 {
+		t.Errorf("Expected an empty cache, got %+v", release)
+	}
+	stats := db.Stats().Memory
+	if stats.Releases != 2 || stats.Evicted != uint64(cached) || stats.CachedVectors != 0 || stats.LastRelease.InUse != 0 {
+		t.Errorf("Unexpected memory stats: %+v", stats)
+	}
+
+	// Searches keep working with released buffers and refill the cache
+	results, err := db.Search(testVector(7), 5)
+	if err != nil || len(results) != 5 || results[0].ID != 7 {
+		t.Errorf("Expected searches to work after the release, got %v, %v", results, err)
+	}
+	if db.Stats().Memory.CachedVectors == 0 {
+		t.Errorf("Expected reads to refill the cache")
+	}
+}
+
+func TestVecLite_MemoryPressure(t *testing.T) {
+	releases := make(chan MemoryRelease, 16)
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/pressure.db"
+	config.Dimension = 8
+	config.MemoryPressure = &MemoryPressureConfig{
+		Limit:     1, // Always under pressure
+		Cooldown:  time.Millisecond,
+		OnRelease: func(r MemoryRelease) { releases <- r },
+	}
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	for i := uint64(1); i <= 10; i++ {
+		if err := db.Insert(i, make([]float32, 8)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	var release MemoryRelease
+	for deadline := time.Now().Add(5 * time.Second); release.Limit == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a release after garbage collections under pressure")
+		}
+		runtime.GC()
+		select {
+		case release = <-releases:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if release.Limit != 1 || release.InUse <= 1 || release.Time.IsZero() {
+		t.Errorf("Unexpected release: %+v", release)
+	}
+	if stats := db.Stats().Memory; stats.Releases == 0 {
+		t.Errorf("Expected the release in Stats, got %+v", stats)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for len(releases) > 0 {
+		<-releases
+	}
+	runtime.GC()
+	runtime.GC()
+	select {
+	case r := <-releases:
+		t.Errorf("Expected no release after Close, got %+v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryWatcher_NoLimit(t *testing.T) {
+	w := newMemoryWatcher(MemoryPressureConfig{})
+	if w.config.HighWater != 0.85 || w.config.Release != 0.5 || w.config.Cooldown != time.Second {
+		t.Errorf("Unexpected defaults: %+v", w.config)
+	}
+	if memoryInUse() <= 0 {
+		t.Errorf("Expected memory in use to be reported")
+	}
+}
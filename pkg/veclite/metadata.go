@@ -0,0 +1,293 @@
+package veclite
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Metadata is a set of attributes stored alongside a vector
+// Values must be JSON-encodable; numbers read back from disk are float64
+type Metadata map[string]any
+
+// ErrNoMetadata is returned by GetMetadata for vectors without metadata
+var ErrNoMetadata = errors.New("no metadata for vector")
+
+// metadataStore keeps vector metadata in memory and persists it to a JSON-lines
+// sidecar (DataPath + ".meta") the same way as the KV namespace: every change is
+// appended as part of the write that makes it, and the file is rewritten
+// without superseded lines on Sync/Close
+// Every line carries the sequence number of the data file after the write it
+// belongs to; on open, lines ahead of the data file are dropped
+type metadataStore struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[uint64]Metadata
+	seqs    map[uint64]uint64 // Data file sequence number of the line of every ID
+	dirty   bool              // Changed since the last save
+	journal *os.File          // Sidecar opened for appending changes (nil until the first change)
+
+	postings postings  // IDs per indexable key/value, for filtered search
+	geo      *geoIndex // Locations per key, for geo filters
+}
+
+// metadataRecord is one line of the sidecar
+// Lines are applied in order; a deleted line drops the metadata of the ID
+type metadataRecord struct {
+	ID       uint64   `json:"id"`
+	Metadata Metadata `json:"metadata"`
+	Deleted  bool     `json:"deleted,omitempty"`
+	Seq      uint64   `json:"seq,omitempty"` // Data file sequence number when written
+}
+
+// openMetadataStore loads the sidecar at path if it exists, up to seq, the
+// sequence number of the data file
+// A torn last line and lines ahead of the data file are dropped, and the
+// sidecar is rewritten without them so appended lines follow whole ones
+func openMetadataStore(path string, seq uint64) (*metadataStore, error) {
+	m, rewrite, err := loadMetadataStore(path, seq)
+	if err != nil {
+		return nil, err
+	}
+	if rewrite {
+		if err := m.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// loadMetadataStore implements openMetadataStore without changing the sidecar,
+// reporting whether it needs to be rewritten
+func loadMetadataStore(path string, seq uint64) (*metadataStore, bool, error) {
+	m := &metadataStore{path: path, entries: make(map[uint64]Metadata), seqs: make(map[uint64]uint64), postings: make(postings), geo: newGeoIndex()}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open metadata file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 { // Every line written ends with a newline
+				m.dirty = true
+			}
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read metadata file: %w", err)
+		}
+		var record metadataRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, false, fmt.Errorf("invalid metadata on line %d: %w", line, err)
+		}
+		if record.Seq <= seq {
+			m.apply(record)
+		} else {
+			m.dirty = true
+		}
+	}
+	return m, m.dirty, nil
+}
+
+// apply applies one sidecar line
+// Note: Assumes lock is already held (or the store is not shared yet)
+func (m *metadataStore) apply(record metadataRecord) {
+	m.postings.remove(record.ID, m.entries[record.ID])
+	m.geo.remove(record.ID, m.entries[record.ID])
+	if record.Deleted || len(record.Metadata) == 0 {
+		delete(m.entries, record.ID)
+		delete(m.seqs, record.ID)
+		return
+	}
+	m.entries[record.ID] = record.Metadata
+	m.seqs[record.ID] = record.Seq
+	m.postings.add(record.ID, record.Metadata)
+	m.geo.add(record.ID, record.Metadata)
+}
+
+// append records a change in the sidecar, then applies it
+// Note: Assumes lock is already held
+func (m *metadataStore) append(record metadataRecord) error {
+	if m.journal == nil {
+		file, err := os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open metadata file: %w", err)
+		}
+		m.journal = file
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata for vector %d: %w", record.ID, err)
+	}
+	if _, err := m.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	m.apply(record)
+	m.dirty = true
+	return nil
+}
+
+// Set replaces the metadata of id (empty metadata removes it); seq is the
+// sequence number of the data file after the write of the vector
+func (m *metadataStore) Set(id uint64, metadata Metadata, seq uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.append(metadataRecord{ID: id, Metadata: copyMetadata(metadata, nil), Deleted: len(metadata) == 0, Seq: seq})
+}
+
+// Delete removes the metadata of id; removing missing metadata is a no-op
+func (m *metadataStore) Delete(id uint64, seq uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.entries[id]; !exists {
+		return nil
+	}
+	return m.append(metadataRecord{ID: id, Deleted: true, Seq: seq})
+}
+
+// Get returns a copy of the metadata of id restricted to keys (nil = all keys)
+func (m *metadataStore) Get(id uint64, keys []string) (Metadata, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	metadata, exists := m.entries[id]
+	if !exists {
+		return nil, false
+	}
+	return copyMetadata(metadata, keys), true
+}
+
+// flush fsyncs the lines appended since the last save
+func (m *metadataStore) flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.journal == nil {
+		return nil
+	}
+	if err := m.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync metadata file: %w", err)
+	}
+	return nil
+}
+
+// Save rewrites the sidecar atomically (temp file + rename) if anything
+// changed, dropping superseded lines
+func (m *metadataStore) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.journal != nil {
+		if err := m.journal.Close(); err != nil {
+			return fmt.Errorf("failed to close metadata file: %w", err)
+		}
+		m.journal = nil
+	}
+	if !m.dirty {
+		return nil
+	}
+	if len(m.entries) == 0 {
+		if err := os.Remove(m.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove metadata file: %w", err)
+		}
+		m.dirty = false
+		return nil
+	}
+
+	tmpPath := m.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create metadata file: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for id, metadata := range m.entries {
+		if err := encoder.Encode(metadataRecord{ID: id, Metadata: metadata, Seq: m.seqs[id]}); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to encode metadata for vector %d: %w", id, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync metadata file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close metadata file: %w", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("failed to replace metadata file: %w", err)
+	}
+	m.dirty = false
+	return nil
+}
+
+// copyMetadata returns a shallow copy of metadata restricted to keys (nil = all keys)
+func copyMetadata(metadata Metadata, keys []string) Metadata {
+	if keys == nil {
+		copied := make(Metadata, len(metadata))
+		for key, value := range metadata {
+			copied[key] = value
+		}
+		return copied
+	}
+	copied := make(Metadata, len(keys))
+	for _, key := range keys {
+		if value, exists := metadata[key]; exists {
+			copied[key] = value
+		}
+	}
+	return copied
+}
+
+// InsertWithMetadata adds a vector with an ID and replaces its metadata
+// Plain Insert leaves existing metadata untouched
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) InsertWithMetadata(id uint64, vector []float32, metadata Metadata) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.InsertWithMetadataContext(ctx, id, vector, metadata)
+}
+
+// InsertWithMetadataContext is InsertWithMetadata with a context bounding the wait for the write lock
+func (v *VecLite) InsertWithMetadataContext(ctx context.Context, id uint64, vector []float32, metadata Metadata) error {
+	return v.insert(ctx, id, vector, metadata, true, false)
+}
+
+// UpsertWithMetadata is Upsert replacing the metadata of the vector
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) UpsertWithMetadata(id uint64, vector []float32, metadata Metadata) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.UpsertWithMetadataContext(ctx, id, vector, metadata)
+}
+
+// UpsertWithMetadataContext is UpsertWithMetadata with a context bounding the wait for the write lock
+func (v *VecLite) UpsertWithMetadataContext(ctx context.Context, id uint64, vector []float32, metadata Metadata) error {
+	return v.insert(ctx, id, vector, metadata, true, true)
+}
+
+// GetMetadata returns a copy of the metadata stored for id
+func (v *VecLite) GetMetadata(id uint64) (Metadata, error) {
+	metadata, exists := v.metadata.Get(id, nil)
+	if !exists {
+		return nil, fmt.Errorf("%w %d", ErrNoMetadata, id)
+	}
+	return metadata, nil
+}
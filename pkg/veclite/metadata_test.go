@@ -0,0 +1,189 @@
+package veclite
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestVecLite_Metadata(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+
+	if err := db.InsertWithMetadata(1, make([]float32, 128), Metadata{"tag": "a", "year": 2024}); err != nil {
+		t.Fatalf("InsertWithMetadata failed: %v", err)
+	}
+	metadata, err := db.GetMetadata(1)
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata["tag"] != "a" || metadata["year"] != 2024 {
+		t.Errorf("Unexpected metadata: %v", metadata)
+	}
+
+	// Returned metadata is a copy
+	metadata["tag"] = "changed"
+	if again, _ := db.GetMetadata(1); again["tag"] != "a" {
+		t.Error("Mutating returned metadata changed the stored copy")
+	}
+
+	// Plain Upsert keeps metadata, UpsertWithMetadata replaces it
+	if err := db.Upsert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if again, _ := db.GetMetadata(1); again["tag"] != "a" {
+		t.Error("Plain Upsert dropped metadata")
+	}
+	if err := db.UpsertWithMetadata(1, make([]float32, 128), Metadata{"tag": "b"}); err != nil {
+		t.Fatalf("UpsertWithMetadata failed: %v", err)
+	}
+	if again, _ := db.GetMetadata(1); again["tag"] != "b" || len(again) != 1 {
+		t.Errorf("Expected replaced metadata, got %v", again)
+	}
+
+	if err := db.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := db.GetMetadata(1); !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("Expected ErrNoMetadata after delete, got %v", err)
+	}
+}
+
+func TestVecLite_Metadata_Persistence(t *testing.T) {
+	tmpFile := t.TempDir() + "/meta.db"
+	config := DefaultConfig()
+	config.DataPath = tmpFile
+	config.Dimension = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if err := db.InsertWithMetadata(i, []float32{float32(i), 0, 0, 0}, Metadata{"n": float64(i), "name": "v"}); err != nil {
+			t.Fatalf("InsertWithMetadata failed: %v", err)
+		}
+	}
+	if err := db.Delete(2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	metadata, err := db.GetMetadata(3)
+	if err != nil {
+		t.Fatalf("GetMetadata after reopen failed: %v", err)
+	}
+	if metadata["n"] != float64(3) || metadata["name"] != "v" {
+		t.Errorf("Unexpected metadata after reopen: %v", metadata)
+	}
+	if _, err := db.GetMetadata(2); !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("Deleted vector's metadata survived reopen: %v", err)
+	}
+
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if usage.Metadata == 0 {
+		t.Error("Expected metadata sidecar to be counted in DiskUsage")
+	}
+}
+
+func TestOpenMetadataStore_Invalid(t *testing.T) {
+	path := t.TempDir() + "/bad.meta"
+	if err := os.WriteFile(path, []byte("{not json}\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := openMetadataStore(path, 0); err == nil {
+		t.Error("Expected error for invalid metadata file")
+	}
+}
+
+func TestMetadataStore_SaveRemovesEmptyFile(t *testing.T) {
+	path := t.TempDir() + "/empty.meta"
+	store, err := openMetadataStore(path, 0)
+	if err != nil {
+		t.Fatalf("openMetadataStore failed: %v", err)
+	}
+	if err := store.Set(1, Metadata{"a": "b"}, 1); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Delete(1, 2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected metadata file to be removed, got %v", err)
+	}
+}
+
+func TestOpenMetadataStore_Journal(t *testing.T) {
+	path := t.TempDir() + "/journal.meta"
+	store, err := openMetadataStore(path, 0)
+	if err != nil {
+		t.Fatalf("openMetadataStore failed: %v", err)
+	}
+	for _, err := range []error{
+		store.Set(1, Metadata{"tag": "a"}, 1),
+		store.Set(2, Metadata{"tag": "a"}, 2),
+		store.Set(1, Metadata{"tag": "b"}, 3),
+		store.Delete(2, 4),
+		store.Set(3, Metadata{"tag": "c"}, 5),
+		store.flush(),
+	} {
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	// A crash left a torn line after the last whole one
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	file.WriteString(`{"id":4,"meta`)
+	file.Close()
+
+	// Changes are read back without a save; the data file only kept the records up to sequence number 4
+	store, err = openMetadataStore(path, 4)
+	if err != nil {
+		t.Fatalf("openMetadataStore failed: %v", err)
+	}
+	if metadata, ok := store.Get(1, nil); !ok || metadata["tag"] != "b" {
+		t.Errorf("Expected the last metadata of ID 1, got %v", metadata)
+	}
+	if _, ok := store.Get(2, nil); ok {
+		t.Error("Expected the metadata of ID 2 to be deleted")
+	}
+	if _, ok := store.Get(3, nil); ok {
+		t.Error("Expected the line ahead of the data file to be dropped")
+	}
+	if ids := store.match(Filter{"tag": "a"}); len(ids) != 0 {
+		t.Errorf("Expected no IDs left with tag a, got %v", ids)
+	}
+
+	// Lines appended after the reopen follow whole ones
+	if err := store.Set(5, Metadata{"tag": "e"}, 5); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	store, err = openMetadataStore(path, 10)
+	if err != nil {
+		t.Fatalf("openMetadataStore failed: %v", err)
+	}
+	if _, ok := store.Get(3, nil); ok || len(store.entries) != 2 {
+		t.Errorf("Expected IDs 1 and 5 after reopening, got %v", store.entries)
+	}
+}
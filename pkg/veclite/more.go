@@ -0,0 +1,120 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// SearchCursor is the state of a search that More continues with further
+// results, e.g. for "load more" in a UI; pass a new one in
+// SearchOptions.Cursor to have a search fill it
+// HNSW searches without a Filter, Reranker or cold tier retain their candidate
+// frontier, and More continues the traversal from it; other searches are rerun
+// with a larger k by More, skipping the results already returned
+// A SearchCursor is safe for concurrent use
+type SearchCursor struct {
+	mu       sync.Mutex
+	db       *VecLite
+	query    []float32
+	opts     SearchOptions
+	index    index.Index         // Index the frontier belongs to
+	frontier index.Frontier      // nil = rerun the search
+	returned map[uint64]struct{} // IDs returned so far
+}
+
+// start resets the cursor to the search of query that returned results
+func (c *SearchCursor) start(db *VecLite, query []float32, opts SearchOptions, frontier index.Frontier, results []index.SearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	opts.Cost, opts.Cursor = nil, nil
+	c.db = db
+	c.query = append([]float32(nil), query...)
+	c.opts = opts
+	c.index = db.index
+	c.frontier = frontier
+	c.returned = make(map[uint64]struct{}, len(results))
+	c.record(results)
+}
+
+// record adds results to the IDs returned so far
+// Note: Assumes cursor lock is already held
+func (c *SearchCursor) record(results []index.SearchResult) {
+	for _, result := range results {
+		c.returned[result.ID] = struct{}{}
+	}
+}
+
+// Returned returns the number of results returned through the cursor so far
+func (c *SearchCursor) Returned() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.returned)
+}
+
+// More returns up to additionalK further results of the search that filled
+// cursor, none returned before, nearest first; fewer once no more vectors can
+// be reached
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) More(cursor *SearchCursor, additionalK int) ([]index.SearchResult, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.MoreContext(ctx, cursor, additionalK)
+}
+
+// MoreContext is More with a context, see SearchContext
+func (v *VecLite) MoreContext(ctx context.Context, cursor *SearchCursor, additionalK int) (results []index.SearchResult, err error) {
+	if additionalK <= 0 {
+		return nil, errors.New("additional k must be greater than 0")
+	}
+	cursor.mu.Lock()
+	defer cursor.mu.Unlock()
+	if cursor.db != v {
+		return nil, errors.New("cursor was not filled by a search of this database")
+	}
+	defer v.label(ctx, "more", LabelTrace, cursor.opts.TraceID)()
+
+	if err := v.rLockContext(ctx); err != nil {
+		return nil, fmt.Errorf("more: %w", err)
+	}
+	if cursor.frontier == nil || cursor.index != v.index { // Rebuilt since
+		v.mu.RUnlock()
+		return v.moreBySearch(ctx, cursor, additionalK)
+	}
+	defer v.mu.RUnlock()
+	defer v.recoverPanic("more", &err)
+
+	results, err = cursor.frontier.Next(ctx, additionalK)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("more: %w", err)
+		}
+		return nil, err
+	}
+	cursor.record(results)
+	v.project(results, cursor.opts)
+	return results, nil
+}
+
+// moreBySearch reruns the search of cursor for the results returned so far
+// plus additionalK and returns the ones not returned yet
+// Note: Assumes cursor lock is already held
+func (v *VecLite) moreBySearch(ctx context.Context, cursor *SearchCursor, additionalK int) ([]index.SearchResult, error) {
+	results, err := v.search(ctx, cursor.query, len(cursor.returned)+additionalK, cursor.opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	more := make([]index.SearchResult, 0, additionalK)
+	for _, result := range results {
+		if _, returned := cursor.returned[result.ID]; !returned && len(more) < additionalK {
+			more = append(more, result)
+		}
+	}
+	cursor.frontier = nil
+	cursor.record(more)
+	return more, nil
+}
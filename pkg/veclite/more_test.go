@@ -0,0 +1,106 @@
+package veclite
+
+import (
+	"testing"
+)
+
+// pageThrough collects a search of k results continued by More in pages of
+// size until it is exhausted or holds max results
+func pageThrough(t *testing.T, db *VecLite, query []float32, k, size, max int, opts SearchOptions) []SearchResult {
+	t.Helper()
+	opts.Cursor = &SearchCursor{}
+	results, err := db.SearchWithOptions(query, k, opts)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for len(results) < max {
+		page, err := db.More(opts.Cursor, size)
+		if err != nil {
+			t.Fatalf("More failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		results = append(results, page...)
+	}
+	if n := opts.Cursor.Returned(); n != len(results) {
+		t.Errorf("Expected the cursor to count %d results, got %d", len(results), n)
+	}
+	return results
+}
+
+func TestVecLite_More(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		for i := 1; i <= 60; i++ {
+			if err := db.Insert(uint64(i), testVector(i)); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+
+		query := testVector(30)
+		results := pageThrough(t, db, query, 5, 4, 17, SearchOptions{IncludeScore: true})
+		if len(results) != 17 {
+			t.Fatalf("Expected 17 results, got %d", len(results))
+		}
+		if indexType == "ivf" {
+			return // Probes only NProbe clusters, so later pages may differ from a larger k
+		}
+		want, err := db.Search(query, 17)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for i := range want {
+			if results[i].ID != want[i].ID || results[i].Distance != want[i].Distance {
+				t.Fatalf("Expected result %d to be %d (%f), got %d (%f)", i, want[i].ID, want[i].Distance, results[i].ID, results[i].Distance)
+			}
+			if results[i].Vector != nil {
+				t.Errorf("Expected More to leave out vectors like the search")
+			}
+		}
+	})
+}
+
+func TestVecLite_More_Filtered(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	for i := 1; i <= 40; i++ {
+		if err := db.InsertWithMetadata(uint64(i), testVector(i), Metadata{"even": i%2 == 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	results := pageThrough(t, db, testVector(10), 3, 5, 100, SearchOptions{Filter: Filter{"even": true}})
+	seen := make(map[uint64]bool)
+	for _, result := range results {
+		if result.ID%2 != 0 || seen[result.ID] {
+			t.Errorf("Expected distinct even IDs, got %d", result.ID)
+		}
+		seen[result.ID] = true
+	}
+	if len(results) != 20 {
+		t.Errorf("Expected all 20 matches, got %d", len(results))
+	}
+}
+
+func TestVecLite_More_Errors(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	if _, err := db.More(&SearchCursor{}, 5); err == nil {
+		t.Error("Expected an error for a cursor no search filled")
+	}
+	cursor := &SearchCursor{}
+	if _, err := db.SearchWithOptions(testVector(1), 1, SearchOptions{Cursor: cursor}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, err := db.More(cursor, 0); err == nil {
+		t.Error("Expected an error for additionalK 0")
+	}
+	if results, err := db.More(cursor, 5); err != nil || len(results) != 0 {
+		t.Errorf("Expected no more results from an empty database, got %v (%v)", results, err)
+	}
+}
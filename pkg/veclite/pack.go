@@ -0,0 +1,592 @@
+package veclite
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Pack archive layout: a tar file whose first entry is the JSON manifest,
+// followed by the data file ("data"), its sidecars ("data" + suffix) and cold
+// segments ("segments/" + file name)
+// From version 2 the last entry (packChecksumName) holds the SHA-256 of the
+// manifest and every packed file in archive order, so any corruption of the
+// archive, including of the manifest, is detected
+// A gzip-compressed archive is a plain gzip stream; with any other codec the
+// compressed stream follows a header naming it: packCodecMagic, the length of
+// the name (one byte) and the name
+const (
+	packFormat       = "veclite-pack"
+	packVersion      = 2
+	packManifestName = "manifest.json"
+	packDataName     = "data"
+	packSegmentDir   = "segments/"
+	packChecksumName = "checksum.sha256"
+	packCodecMagic   = "VLPZ"
+
+	// packChunkSize is the size of the chunks of a packed file that are
+	// checksummed separately, so Unpack can tell where a file is corrupt
+	packChunkSize = 1 << 20
+)
+
+// ErrPackCorrupt is returned by Unpack and VerifyPack when an archive does not
+// match its checksums, e.g. after a corrupted transfer
+var ErrPackCorrupt = errors.New("pack archive is corrupt")
+
+// packSidecars are the suffixes of the sidecar files packed with the data file
+// Audit and query logs are not part of a snapshot
+var packSidecars = []string{".graph", ".graph.journal", ".pq", ".ivf", ".ivfpq", ".meta", ".keys", ".kv", ".cold"}
+
+// PackOptions controls Pack
+type PackOptions struct {
+	Compress bool   // gzip the archive (same as Codec: CodecGzip)
+	Codec    string // Compress the archive with this codec, see RegisterCodec ("" = none unless Compress)
+}
+
+// codec returns the name of the codec to compress with ("" = none)
+func (o PackOptions) codec() string {
+	if o.Codec == "" && o.Compress {
+		return CodecGzip
+	}
+	return o.Codec
+}
+
+// PackManifest describes a Pack archive: how to open the database and the
+// files it is made of
+type PackManifest struct {
+	Format         string     `json:"format"`
+	Version        int        `json:"version"`
+	Created        time.Time  `json:"created"`
+	Vectors        int        `json:"vectors"`
+	Seq            uint64     `json:"seq,omitempty"` // Sequence number of the last write in the snapshot (see VecLite.Seq)
+	Dimension      int        `json:"dimension"`
+	IndexType      string     `json:"index_type"`
+	MaxElements    int        `json:"max_elements,omitempty"`
+	M              int        `json:"m,omitempty"`
+	EfConstruction int        `json:"ef_construction,omitempty"`
+	EfSearch       int        `json:"ef_search,omitempty"`
+	NClusters      int        `json:"nclusters,omitempty"`
+	NProbe         int        `json:"nprobe,omitempty"`
+	Files          []PackFile `json:"files"`
+}
+
+// PackFile is one file of a Pack archive
+type PackFile struct {
+	Name      string   `json:"name"`
+	Size      int64    `json:"size"`
+	SHA256    string   `json:"sha256"`
+	ChunkSize int64    `json:"chunk_size,omitempty"` // Size of the chunks hashed in Chunks
+	Chunks    []string `json:"chunks,omitempty"`     // SHA-256 of every ChunkSize bytes of the file (version 2)
+}
+
+// Config returns a configuration opening the unpacked database at dataPath
+// with the packed index type and parameters
+func (m *PackManifest) Config(dataPath string) *Config {
+	config := DefaultConfig()
+	config.DataPath = dataPath
+	config.Dimension = m.Dimension
+	config.IndexType = m.IndexType
+	if m.MaxElements > 0 {
+		config.MaxElements = m.MaxElements
+	}
+	config.M = m.M
+	config.EfConstruction = m.EfConstruction
+	config.EfSearch = m.EfSearch
+	config.NClusters = m.NClusters
+	config.NProbe = m.NProbe
+	return config
+}
+
+// packSource is a file to pack and its name in the archive
+type packSource struct {
+	name string
+	path string
+}
+
+// Pack writes a snapshot of the database to one archive at path: the data
+// file with its footer index, the index, metadata and key sidecars, cold
+// segments, and a manifest with the configuration and file checksums
+// Use Unpack to restore it
+// Requires exclusive write lock - blocks all reads and writes while packing
+func (v *VecLite) Pack(path string, opts PackOptions) error {
+	var codec Codec
+	if name := opts.codec(); name != "" {
+		var err error
+		if codec, err = lookupCodec(name); err != nil {
+			return fmt.Errorf("pack: %w", err)
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Bring every file up to date, as Close would
+	if err := v.syncLocked(); err != nil {
+		return err
+	}
+
+	sources := []packSource{{packDataName, v.config.DataPath}}
+	for _, suffix := range packSidecars {
+		if _, err := os.Stat(v.config.DataPath + suffix); err == nil {
+			sources = append(sources, packSource{packDataName + suffix, v.config.DataPath + suffix})
+		}
+	}
+	dir := filepath.Dir(v.config.DataPath)
+	for _, cold := range v.tiers.segments {
+		sources = append(sources, packSource{packSegmentDir + cold.name, filepath.Join(dir, cold.name)})
+	}
+
+	manifest := &PackManifest{
+		Format:         packFormat,
+		Version:        packVersion,
+		Created:        time.Now().UTC(),
+		Vectors:        v.index.Size(),
+		Seq:            v.storage.Seq(),
+		Dimension:      v.config.Dimension,
+		IndexType:      v.config.IndexType,
+		MaxElements:    v.config.MaxElements,
+		M:              v.config.M,
+		EfConstruction: v.config.EfConstruction,
+		EfSearch:       v.config.EfSearch,
+		NClusters:      v.config.NClusters,
+		NProbe:         v.config.NProbe,
+	}
+	tracker := v.trackProgress(OpPack)
+	defer tracker.finish()
+	var total int64
+	for _, source := range sources {
+		if info, err := os.Stat(source.path); err == nil {
+			total += info.Size()
+		}
+	}
+	checksummed := &progressWriter{tracker: tracker, stage: "checksum", total: total}
+	tracker.report(checksummed.stage, 0, total, "bytes")
+	for _, source := range sources {
+		file, err := checksumFile(source.path, checksummed)
+		if err != nil {
+			return fmt.Errorf("failed to read %s for packing: %w", source.path, err)
+		}
+		file.Name = source.name
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	tmpPath := path + ".tmp"
+	archived := &progressWriter{tracker: tracker, stage: "archive", total: total}
+	tracker.report(archived.stage, 0, total, "bytes")
+	if err := writePack(tmpPath, manifest, sources, opts.codec(), codec, archived); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename pack archive: %w", err)
+	}
+	return nil
+}
+
+// checksumFile returns the size, SHA-256 and chunk hashes of the file at path
+// The bytes read are also written to progress (nil = none)
+func checksumFile(path string, progress io.Writer) (PackFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return PackFile{}, err
+	}
+	defer file.Close()
+	packed := PackFile{ChunkSize: packChunkSize}
+	whole := sha256.New()
+	var sink io.Writer = whole
+	if progress != nil {
+		sink = io.MultiWriter(whole, progress)
+	}
+	for {
+		chunk := sha256.New()
+		n, err := io.CopyN(io.MultiWriter(sink, chunk), file, packChunkSize)
+		if n > 0 {
+			packed.Size += n
+			packed.Chunks = append(packed.Chunks, hex.EncodeToString(chunk.Sum(nil)))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return PackFile{}, err
+		}
+	}
+	packed.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return packed, nil
+}
+
+// writePack writes the archive of manifest and sources to path, compressed
+// with codec registered as codecName (nil = uncompressed)
+func writePack(path string, manifest *PackManifest, sources []packSource, codecName string, codec Codec, progress io.Writer) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create pack archive: %w", err)
+	}
+	defer out.Close()
+
+	buffered := bufio.NewWriter(out)
+	var w io.Writer = buffered
+	var compressor io.WriteCloser
+	if codec != nil {
+		if codecName != CodecGzip { // gzip archives stay plain gzip streams
+			header := append([]byte(packCodecMagic), byte(len(codecName)))
+			if _, err := buffered.Write(append(header, codecName...)); err != nil {
+				return fmt.Errorf("failed to write pack codec header: %w", err)
+			}
+		}
+		if compressor, err = codec.NewWriter(buffered); err != nil {
+			return fmt.Errorf("failed to start %s compression: %w", codecName, err)
+		}
+		w = compressor
+	}
+	archive := tar.NewWriter(w)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pack manifest: %w", err)
+	}
+	header := &tar.Header{Name: packManifestName, Mode: 0644, Size: int64(len(manifestJSON)), ModTime: manifest.Created}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write pack manifest: %w", err)
+	}
+	if _, err := archive.Write(manifestJSON); err != nil {
+		return fmt.Errorf("failed to write pack manifest: %w", err)
+	}
+
+	digest := sha256.New()
+	digest.Write(manifestJSON)
+	for i, source := range sources {
+		if err := packFile(archive, io.MultiWriter(digest, progress), source, manifest.Files[i].Size, manifest.Created); err != nil {
+			return fmt.Errorf("failed to pack %s: %w", source.path, err)
+		}
+	}
+
+	checksum := hex.EncodeToString(digest.Sum(nil))
+	header = &tar.Header{Name: packChecksumName, Mode: 0644, Size: int64(len(checksum)), ModTime: manifest.Created}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write pack checksum: %w", err)
+	}
+	if _, err := io.WriteString(archive, checksum); err != nil {
+		return fmt.Errorf("failed to write pack checksum: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish pack archive: %w", err)
+	}
+	if compressor != nil {
+		if err := compressor.Close(); err != nil {
+			return fmt.Errorf("failed to finish pack archive: %w", err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write pack archive: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("failed to sync pack archive: %w", err)
+	}
+	return out.Close()
+}
+
+// packFile copies one source file of size bytes into archive and digest
+func packFile(archive *tar.Writer, digest io.Writer, source packSource, size int64, modTime time.Time) error {
+	file, err := os.Open(source.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := archive.WriteHeader(&tar.Header{Name: source.name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.CopyN(io.MultiWriter(archive, digest), file, size)
+	return err
+}
+
+// packCloser closes a pack archive and its decompressor
+type packCloser struct {
+	file         *os.File
+	decompressor io.Closer // nil = uncompressed
+}
+
+func (c packCloser) Close() error {
+	if c.decompressor != nil {
+		c.decompressor.Close()
+	}
+	return c.file.Close()
+}
+
+// packDecompressor returns the codec the archive read by r is compressed
+// with, consuming its codec header (nil = uncompressed)
+func packDecompressor(r *bufio.Reader) (Codec, error) {
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return lookupCodec(CodecGzip)
+	}
+	if magic, err := r.Peek(len(packCodecMagic) + 1); err != nil || string(magic[:len(packCodecMagic)]) != packCodecMagic {
+		return nil, nil
+	}
+	header := make([]byte, len(packCodecMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	name := make([]byte, header[len(packCodecMagic)])
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, fmt.Errorf("failed to read codec name: %w", err)
+	}
+	return lookupCodec(string(name))
+}
+
+// packReader reads a Pack archive positioned after its manifest
+type packReader struct {
+	manifest *PackManifest
+	archive  *tar.Reader
+	digest   hash.Hash // Archive checksum of the entries read so far
+	closer   io.Closer
+}
+
+// openPack opens the archive at path and reads its manifest
+func openPack(path string) (*packReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(file)
+	closer := packCloser{file: file}
+	var r io.Reader = buffered
+	codec, err := packDecompressor(buffered)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid pack archive: %w", err)
+	}
+	if codec != nil {
+		decompressor, err := codec.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("invalid pack archive: %w", err)
+		}
+		r, closer.decompressor = decompressor, decompressor
+	}
+	archive := tar.NewReader(r)
+
+	header, err := archive.Next()
+	if err != nil || header.Name != packManifestName {
+		closer.Close()
+		return nil, errors.New("invalid pack archive: manifest not found")
+	}
+	manifestJSON, err := io.ReadAll(archive)
+	if err != nil {
+		closer.Close()
+		return nil, fmt.Errorf("failed to read pack manifest: %w", err)
+	}
+	var manifest PackManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		closer.Close()
+		return nil, fmt.Errorf("invalid pack manifest: %w", err)
+	}
+	if manifest.Format != packFormat {
+		closer.Close()
+		return nil, fmt.Errorf("invalid pack manifest: format %q", manifest.Format)
+	}
+	if manifest.Version > packVersion {
+		closer.Close()
+		return nil, fmt.Errorf("pack archive version %d is newer than supported version %d", manifest.Version, packVersion)
+	}
+	digest := sha256.New()
+	digest.Write(manifestJSON)
+	return &packReader{manifest: &manifest, archive: archive, digest: digest, closer: closer}, nil
+}
+
+// ReadPackManifest reads the manifest of the Pack archive at path
+// The manifest is not verified; use VerifyPack to check the whole archive
+func ReadPackManifest(path string) (*PackManifest, error) {
+	pr, err := openPack(path)
+	if err != nil {
+		return nil, err
+	}
+	pr.closer.Close()
+	return pr.manifest, nil
+}
+
+// VerifyPack checks every file of the Pack archive at path and the archive
+// checksum without restoring anything, e.g. after transferring the archive
+// Corruption is reported as an error wrapping ErrPackCorrupt
+func VerifyPack(path string) (*PackManifest, error) {
+	pr, err := openPack(path)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.closer.Close()
+	err = pr.readFiles(func(name string, file PackFile) (io.Writer, error) {
+		return io.Discard, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr.manifest, nil
+}
+
+// readFiles reads the packed files, copying each to the writer open returns
+// for it, and verifies them and the archive checksum
+// Returns at the first error; the files written so far are then incomplete
+// or unverified
+func (pr *packReader) readFiles(open func(name string, file PackFile) (io.Writer, error)) error {
+	expected := make(map[string]PackFile, len(pr.manifest.Files))
+	for _, file := range pr.manifest.Files {
+		expected[file.Name] = file
+	}
+	verified := false
+	for {
+		header, err := pr.archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: failed to read archive: %v", ErrPackCorrupt, err)
+		}
+		if verified {
+			return fmt.Errorf("%w: unexpected file %q after the archive checksum", ErrPackCorrupt, header.Name)
+		}
+		if header.Name == packChecksumName {
+			if err := pr.verifyChecksum(); err != nil {
+				return err
+			}
+			verified = true
+			continue
+		}
+		file, ok := expected[header.Name]
+		if !ok {
+			return fmt.Errorf("pack archive has unexpected file %q", header.Name)
+		}
+		delete(expected, header.Name)
+
+		out, err := open(header.Name, file)
+		if err != nil {
+			return err
+		}
+		if err := verifyPackFile(io.MultiWriter(out, pr.digest), pr.archive, file); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", header.Name, err)
+		}
+	}
+	for name := range expected {
+		return fmt.Errorf("%w: missing %q", ErrPackCorrupt, name)
+	}
+	if !verified && pr.manifest.Version >= 2 {
+		return fmt.Errorf("%w: missing archive checksum", ErrPackCorrupt)
+	}
+	return nil
+}
+
+// verifyChecksum compares the archive checksum entry with the digest of the
+// entries read before it
+func (pr *packReader) verifyChecksum() error {
+	recorded, err := io.ReadAll(io.LimitReader(pr.archive, 2*sha256.Size))
+	if err != nil {
+		return fmt.Errorf("%w: failed to read archive checksum: %v", ErrPackCorrupt, err)
+	}
+	if string(recorded) != hex.EncodeToString(pr.digest.Sum(nil)) {
+		return fmt.Errorf("%w: archive checksum mismatch (manifest or file data changed)", ErrPackCorrupt)
+	}
+	return nil
+}
+
+// Unpack restores the Pack archive at archivePath as a database at dataPath,
+// verifying every file against the manifest checksums (whole file and chunks)
+// and the archive checksum, and returns the packed configuration for opening
+// it with New
+// Existing files are never overwritten; on failure the files written so far
+// are removed, and corruption is reported as an error wrapping ErrPackCorrupt
+func Unpack(archivePath, dataPath string) (*Config, error) {
+	pr, err := openPack(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.closer.Close()
+
+	dir := filepath.Dir(dataPath)
+	var written []*os.File
+	err = pr.readFiles(func(name string, file PackFile) (io.Writer, error) {
+		var target string
+		switch {
+		case name == packDataName || strings.HasPrefix(name, packDataName+"."):
+			target = dataPath + strings.TrimPrefix(name, packDataName)
+		case strings.HasPrefix(name, packSegmentDir):
+			segment := strings.TrimPrefix(name, packSegmentDir)
+			if segment == "" || segment == ".." || filepath.Base(segment) != segment {
+				return nil, fmt.Errorf("pack archive has invalid segment name %q", name)
+			}
+			target = filepath.Join(dir, segment)
+		default:
+			return nil, fmt.Errorf("pack archive has unexpected file %q", name)
+		}
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", target, err)
+		}
+		written = append(written, out)
+		return out, nil
+	})
+	// Nothing is kept unless the whole archive checked out
+	for _, out := range written {
+		if err == nil {
+			if err = out.Sync(); err != nil {
+				err = fmt.Errorf("failed to sync %s: %w", out.Name(), err)
+			}
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		for _, out := range written {
+			os.Remove(out.Name())
+		}
+		return nil, err
+	}
+	return pr.manifest.Config(dataPath), nil
+}
+
+// verifyPackFile copies one packed file to out and checks it against the
+// manifest, chunk by chunk if the manifest has chunk hashes
+func verifyPackFile(out io.Writer, r io.Reader, file PackFile) error {
+	whole := sha256.New()
+	w := io.MultiWriter(out, whole)
+	var size int64
+	if file.ChunkSize > 0 {
+		for i := 0; ; i++ {
+			chunk := sha256.New()
+			n, err := io.CopyN(io.MultiWriter(w, chunk), r, file.ChunkSize)
+			size += n
+			if n > 0 && (i >= len(file.Chunks) || hex.EncodeToString(chunk.Sum(nil)) != file.Chunks[i]) {
+				return fmt.Errorf("%w: checksum mismatch in chunk %d (bytes %d-%d)", ErrPackCorrupt, i, size-n, size-1)
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		n, err := io.Copy(w, r)
+		if err != nil {
+			return err
+		}
+		size = n
+	}
+	if size != file.Size {
+		return fmt.Errorf("%w: size %d, expected %d", ErrPackCorrupt, size, file.Size)
+	}
+	if hex.EncodeToString(whole.Sum(nil)) != file.SHA256 {
+		return fmt.Errorf("%w: checksum mismatch", ErrPackCorrupt)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package veclite
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// createPackTestDB creates an HNSW database with 30 vectors of dimension 4,
+// metadata and a string key
+func createPackTestDB(t *testing.T) *VecLite {
+	t.Helper()
+	db, _ := createTestDB(t, "hnsw", smallVectors, smallHNSW)
+	insertSmallVectors(t, db, 30, func(i int) Metadata { return Metadata{"n": i} })
+	if _, err := db.InsertKey("doc-a", []float32{100, 1, 0, 0}); err != nil {
+		t.Fatalf("InsertKey failed: %v", err)
+	}
+	return db
+}
+
+func TestVecLite_PackUnpack(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		db := createPackTestDB(t)
+		archivePath := filepath.Join(t.TempDir(), "snapshot.vlpack")
+		if err := db.Pack(archivePath, PackOptions{Compress: compress}); err != nil {
+			t.Fatalf("Pack failed: %v", err)
+		}
+		// The database stays usable after packing
+		if err := db.Insert(99, []float32{99, 1, 0, 0}); err != nil {
+			t.Fatalf("Insert after Pack failed: %v", err)
+		}
+		db.Close()
+
+		manifest, err := ReadPackManifest(archivePath)
+		if err != nil {
+			t.Fatalf("ReadPackManifest failed: %v", err)
+		}
+		if manifest.Vectors != 31 || manifest.IndexType != "hnsw" || manifest.M != 8 {
+			t.Errorf("Unexpected manifest: %+v", manifest)
+		}
+		var names []string
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+		}
+		if got := strings.Join(names, ","); got != "data,data.graph,data.meta,data.keys" {
+			t.Errorf("Unexpected packed files: %s", got)
+		}
+
+		dataPath := filepath.Join(t.TempDir(), "edge.db")
+		config, err := Unpack(archivePath, dataPath)
+		if err != nil {
+			t.Fatalf("Unpack failed: %v", err)
+		}
+		restored, err := New(config)
+		if err != nil {
+			t.Fatalf("Opening unpacked database failed: %v", err)
+		}
+		if restored.Size() != 31 || restored.Stats().ServingIndex != "hnsw" {
+			t.Errorf("Expected 31 vectors served by hnsw, got %d (%s)", restored.Size(), restored.Stats().ServingIndex)
+		}
+		if results, err := restored.Search([]float32{7, 1, 0, 0}, 1); err != nil || results[0].ID != 7 || results[0].Metadata["n"] != float64(7) {
+			t.Errorf("Expected vector 7 with metadata, got %+v (err %v)", results, err)
+		}
+		if _, err := restored.GetKey("doc-a"); err != nil {
+			t.Errorf("GetKey failed: %v", err)
+		}
+		restored.Close()
+
+		// Existing files are never overwritten
+		if _, err := Unpack(archivePath, dataPath); err == nil {
+			t.Error("Expected Unpack over an existing database to fail")
+		}
+	}
+}
+
+func TestUnpack_Corrupt(t *testing.T) {
+	db := createPackTestDB(t)
+	archivePath := filepath.Join(t.TempDir(), "snapshot.vlpack")
+	if err := db.Pack(archivePath, PackOptions{}); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	db.Close()
+
+	// Flip a byte near the end of the archive, inside the last packed file
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	manifest, _ := ReadPackManifest(archivePath)
+	last := manifest.Files[len(manifest.Files)-1]
+	at := strings.LastIndex(string(data), last.Name) + 512
+	data[at] ^= 0xff
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if _, err := Unpack(archivePath, filepath.Join(dir, "edge.db")); !errors.Is(err, ErrPackCorrupt) || !strings.Contains(err.Error(), "chunk 0") {
+		t.Fatalf("Expected a chunk checksum error, got %v", err)
+	}
+	if _, err := VerifyPack(archivePath); !errors.Is(err, ErrPackCorrupt) {
+		t.Errorf("Expected VerifyPack to report the corruption, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected unpacked files to be removed on failure, found %d", len(entries))
+	}
+
+	if _, err := Unpack(filepath.Join(dir, "missing.vlpack"), filepath.Join(dir, "edge.db")); err == nil {
+		t.Error("Expected Unpack of a missing archive to fail")
+	}
+}
+
+func TestUnpack_ArchiveChecksum(t *testing.T) {
+	db := createPackTestDB(t)
+	archivePath := filepath.Join(t.TempDir(), "snapshot.vlpack")
+	if err := db.Pack(archivePath, PackOptions{}); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	db.Close()
+	manifest, err := VerifyPack(archivePath)
+	if err != nil {
+		t.Fatalf("VerifyPack failed: %v", err)
+	}
+	if manifest.Version != packVersion || len(manifest.Files[0].Chunks) != 1 {
+		t.Errorf("Expected a version %d manifest with chunk hashes, got %+v", packVersion, manifest)
+	}
+
+	// A changed manifest still parses, but no longer matches the archive checksum
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	tampered := strings.Replace(string(data), `"dimension": 4`, `"dimension": 5`, 1)
+	if tampered == string(data) {
+		t.Fatal("Expected the manifest to contain the dimension")
+	}
+	if err := os.WriteFile(archivePath, []byte(tampered), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := VerifyPack(archivePath); !errors.Is(err, ErrPackCorrupt) {
+		t.Errorf("Expected VerifyPack to detect the changed manifest, got %v", err)
+	}
+	dir := t.TempDir()
+	if _, err := Unpack(archivePath, filepath.Join(dir, "edge.db")); !errors.Is(err, ErrPackCorrupt) || !strings.Contains(err.Error(), "archive checksum") {
+		t.Fatalf("Expected an archive checksum error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected unpacked files to be removed on failure, found %d", len(entries))
+	}
+}
+
+func TestUnpack_Version1(t *testing.T) {
+	db := createPackTestDB(t)
+	db.Close()
+	sum, err := checksumFile(db.config.DataPath, nil)
+	if err != nil {
+		t.Fatalf("checksumFile failed: %v", err)
+	}
+	data, err := os.ReadFile(db.config.DataPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	// Archives of version 1 have neither chunk hashes nor an archive checksum
+	manifest := []byte(`{"format":"veclite-pack","version":1,"dimension":4,"index_type":"flat","files":[{"name":"data","size":` +
+		strconv.FormatInt(sum.Size, 10) + `,"sha256":"` + sum.SHA256 + `"}]}`)
+	archivePath := filepath.Join(t.TempDir(), "v1.vlpack")
+	out, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	archive := tar.NewWriter(out)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{packManifestName, manifest}, {packDataName, data}} {
+		if err := archive.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data))}); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		if _, err := archive.Write(entry.data); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	archive.Close()
+	out.Close()
+
+	config, err := Unpack(archivePath, filepath.Join(t.TempDir(), "edge.db"))
+	if err != nil {
+		t.Fatalf("Unpack of a version 1 archive failed: %v", err)
+	}
+	restored, err := New(config)
+	if err != nil {
+		t.Fatalf("Opening unpacked database failed: %v", err)
+	}
+	defer restored.Close()
+	if restored.Size() != 31 {
+		t.Errorf("Expected 31 vectors, got %d", restored.Size())
+	}
+}
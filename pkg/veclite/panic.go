@@ -0,0 +1,44 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrPanic is wrapped by errors returned when an operation panicked internally
+// The panic is recovered, the lock is released, and the database stays usable
+var ErrPanic = errors.New("internal panic")
+
+// PanicError carries the diagnostics of a recovered panic
+type PanicError struct {
+	Op        string // Operation that panicked (insert, search, delete, get)
+	IndexType string // Index type in use when the panic happened
+	Value     any    // Value passed to panic
+	Stack     []byte // Stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v in %s index: %v", e.Op, ErrPanic, e.IndexType, e.Value)
+}
+
+// Unwrap lets errors.Is(err, ErrPanic) match, as well as errors carried as the panic value
+func (e *PanicError) Unwrap() []error {
+	if err, ok := e.Value.(error); ok {
+		return []error{ErrPanic, err}
+	}
+	return []error{ErrPanic}
+}
+
+// recoverPanic converts a panic in the calling operation into a *PanicError
+// Must be deferred directly by the operation so recover() sees the panic
+func (v *VecLite) recoverPanic(op string, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Op:        op,
+			IndexType: v.config.IndexType,
+			Value:     r,
+			Stack:     debug.Stack(),
+		}
+	}
+}
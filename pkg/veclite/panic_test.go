@@ -0,0 +1,105 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// panickingIndex wraps a real index and panics on demand
+type panickingIndex struct {
+	index.Index
+	panicWith any
+}
+
+func (p *panickingIndex) Insert(id uint64, vector []float32) error {
+	if p.panicWith != nil {
+		panic(p.panicWith)
+	}
+	return p.Index.Insert(id, vector)
+}
+
+func (p *panickingIndex) SearchContext(ctx context.Context, query []float32, k int) ([]index.SearchResult, error) {
+	if p.panicWith != nil {
+		var neighbors []uint64
+		_ = neighbors[k] // Simulates a corrupted neighbor slice
+	}
+	return p.Index.SearchContext(ctx, query, k)
+}
+
+func (p *panickingIndex) Delete(id uint64) error {
+	if p.panicWith != nil {
+		panic(p.panicWith)
+	}
+	return p.Index.Delete(id)
+}
+
+func (p *panickingIndex) ReadVector(id uint64) ([]float32, error) {
+	if p.panicWith != nil {
+		panic(p.panicWith)
+	}
+	return p.Index.ReadVector(id)
+}
+
+func TestVecLite_PanicRecovery(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	sentinel := errors.New("corrupted node")
+	faulty := &panickingIndex{Index: db.index, panicWith: sentinel}
+	db.index = faulty
+
+	err := db.Insert(2, make([]float32, 128))
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("Expected *PanicError from Insert, got %v", err)
+	}
+	if panicErr.Op != "insert" || panicErr.IndexType != "flat" || len(panicErr.Stack) == 0 {
+		t.Errorf("Missing diagnostics: %+v", panicErr)
+	}
+	if !errors.Is(err, ErrPanic) || !errors.Is(err, sentinel) {
+		t.Errorf("Expected error to match ErrPanic and the panic value, got %v", err)
+	}
+
+	if _, err := db.Search(make([]float32, 128), 1); !errors.Is(err, ErrPanic) {
+		t.Errorf("Expected ErrPanic from Search, got %v", err)
+	}
+	if err := db.Delete(1); !errors.Is(err, ErrPanic) {
+		t.Errorf("Expected ErrPanic from Delete, got %v", err)
+	}
+	if _, err := db.Get(1); !errors.Is(err, ErrPanic) {
+		t.Errorf("Expected ErrPanic from Get, got %v", err)
+	}
+
+	// The locks were released and the database keeps serving
+	faulty.panicWith = nil
+	if err := db.Insert(2, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert after recovered panic failed: %v", err)
+	}
+	results, err := db.Search(make([]float32, 128), 2)
+	if err != nil || len(results) != 2 {
+		t.Errorf("Expected 2 results after recovery, got %v (%v)", results, err)
+	}
+}
+
+func TestVecLite_PanicRecovery_AfterHookSeesError(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	var hookErr error
+	db.config.AfterInsert = func(id uint64, vector []float32, err error) { hookErr = err }
+	db.index = &panickingIndex{Index: db.index, panicWith: "boom"}
+
+	if err := db.Insert(1, make([]float32, 128)); !errors.Is(err, ErrPanic) {
+		t.Fatalf("Expected ErrPanic, got %v", err)
+	}
+	if !errors.Is(hookErr, ErrPanic) {
+		t.Errorf("Expected AfterInsert to see ErrPanic, got %v", hookErr)
+	}
+}
@@ -0,0 +1,188 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/monishSR/veclite/pkg/vecmath"
+)
+
+// Transform is one step of a Pipeline
+// Implementations must be safe for concurrent use and must not modify the
+// vectors passed to Apply
+type Transform interface {
+	// InputDim is the dimension the transform takes; 0 = any dimension, which
+	// the output keeps
+	InputDim() int
+	// OutputDim is the dimension of the transformed vectors (ignored if
+	// InputDim is 0)
+	OutputDim() int
+	// Apply returns the transformed copy of v
+	Apply(v []float32) []float32
+}
+
+// Pipeline transforms vectors in order, e.g. normalize, then project with PCA,
+// then quantize; see Config.Pipeline
+type Pipeline []Transform
+
+// dims checks that each step takes the dimension the previous one outputs and
+// the last outputs dim, and returns the dimension the pipeline takes
+func (p Pipeline) dims(dim int) (int, error) {
+	in, current := 0, 0 // 0 = the input dimension, not fixed yet
+	for i, t := range p {
+		if t == nil {
+			return 0, fmt.Errorf("pipeline step %d is nil", i)
+		}
+		want := t.InputDim()
+		if want == 0 {
+			continue
+		}
+		if current == 0 {
+			in = want
+		} else if want != current {
+			return 0, fmt.Errorf("pipeline step %d takes dimension %d, the previous step outputs %d", i, want, current)
+		}
+		if current = t.OutputDim(); current <= 0 {
+			return 0, fmt.Errorf("pipeline step %d outputs dimension %d", i, current)
+		}
+	}
+	if current == 0 {
+		return dim, nil // Every step keeps the dimension
+	}
+	if current != dim {
+		return 0, fmt.Errorf("pipeline outputs dimension %d, configured dimension is %d", current, dim)
+	}
+	return in, nil
+}
+
+// apply runs v through every step
+func (p Pipeline) apply(v []float32) []float32 {
+	for _, t := range p {
+		v = t.Apply(v)
+	}
+	return v
+}
+
+// normalizeTransform scales vectors to unit length
+type normalizeTransform struct{}
+
+// Normalize returns a Transform scaling vectors to unit length (zero vectors
+// are kept), so L2 distances rank like cosine distances
+func Normalize() Transform {
+	return normalizeTransform{}
+}
+
+func (normalizeTransform) InputDim() int  { return 0 }
+func (normalizeTransform) OutputDim() int { return 0 }
+
+func (normalizeTransform) Apply(v []float32) []float32 {
+	out := append([]float32(nil), v...)
+	vecmath.NormalizeInPlace(out)
+	return out
+}
+
+// projectTransform multiplies centered vectors by a matrix
+type projectTransform struct {
+	components vecmath.Matrix
+	mean       []float32
+}
+
+// Project returns a Transform reducing vectors to one value per row of
+// components, the dot product of the row with the vector minus mean (nil =
+// no centering), e.g. the top principal components of a SampleMatrix sample for PCA
+// Vectors must have components.Dim values
+func Project(components vecmath.Matrix, mean []float32) (Transform, error) {
+	if components.Dim <= 0 || len(components.Data) == 0 || len(components.Data)%components.Dim != 0 {
+		return nil, errors.New("projection needs at least one component row of components.Dim values")
+	}
+	if mean != nil && len(mean) != components.Dim {
+		return nil, fmt.Errorf("projection mean has dimension %d, components take %d", len(mean), components.Dim)
+	}
+	data := append([]float32(nil), components.Data...)
+	return &projectTransform{components: vecmath.Matrix{Data: data, Dim: components.Dim}, mean: append([]float32(nil), mean...)}, nil
+}
+
+func (t *projectTransform) InputDim() int  { return t.components.Dim }
+func (t *projectTransform) OutputDim() int { return len(t.components.Data) / t.components.Dim }
+
+func (t *projectTransform) Apply(v []float32) []float32 {
+	if len(t.mean) > 0 {
+		centered := make([]float32, len(v))
+		for i := range v {
+			centered[i] = v[i] - t.mean[i]
+		}
+		v = centered
+	}
+	out := make([]float32, t.OutputDim())
+	for i := range out {
+		out[i] = vecmath.Dot(t.components.Data[i*t.components.Dim:(i+1)*t.components.Dim], v)
+	}
+	return out
+}
+
+// quantizeTransform snaps values to evenly spaced levels
+type quantizeTransform struct {
+	min, max float32
+	levels   int
+}
+
+// Quantize returns a Transform clamping every value to [min, max] and
+// rounding it to the nearest of levels evenly spaced values, e.g. 256 levels
+// to store exactly what a one-byte scalar quantizer can represent
+func Quantize(min, max float32, levels int) (Transform, error) {
+	if levels < 2 {
+		return nil, fmt.Errorf("quantization needs at least 2 levels, got %d", levels)
+	}
+	if !(max > min) || math.IsInf(float64(max-min), 0) {
+		return nil, fmt.Errorf("quantization range [%g, %g] is empty or not finite", min, max)
+	}
+	return &quantizeTransform{min: min, max: max, levels: levels}, nil
+}
+
+func (t *quantizeTransform) InputDim() int  { return 0 }
+func (t *quantizeTransform) OutputDim() int { return 0 }
+
+func (t *quantizeTransform) Apply(v []float32) []float32 {
+	step := (float64(t.max) - float64(t.min)) / float64(t.levels-1)
+	out := make([]float32, len(v))
+	for i, value := range v {
+		clamped := math.Min(math.Max(float64(value), float64(t.min)), float64(t.max))
+		level := math.Round((clamped - float64(t.min)) / step)
+		out[i] = float32(float64(t.min) + level*step)
+	}
+	return out
+}
+
+// checkInput returns a dimension error unless vector (what: "vector" or
+// "query") has the dimension Config.Pipeline takes
+func (v *VecLite) checkInput(what string, vector []float32) error {
+	if len(vector) != v.inputDim {
+		return &dimensionError{what, len(vector), v.inputDim}
+	}
+	return nil
+}
+
+// preprocess runs vector through Config.Pipeline; its dimension must have
+// been checked with checkInput
+func (v *VecLite) preprocess(vector []float32) []float32 {
+	if len(v.config.Pipeline) == 0 {
+		return vector
+	}
+	return v.config.Pipeline.apply(vector)
+}
+
+// Preprocess returns vector as Config.Pipeline transforms it before it is
+// stored or searched (vector itself without a pipeline)
+func (v *VecLite) Preprocess(vector []float32) ([]float32, error) {
+	if err := v.checkInput("vector", vector); err != nil {
+		return nil, err
+	}
+	return v.preprocess(vector), nil
+}
+
+// InputDimension returns the dimension of the vectors and queries passed to
+// VecLite: the dimension Config.Pipeline takes, or Dimension without one
+func (v *VecLite) InputDimension() int {
+	return v.inputDim
+}
@@ -0,0 +1,123 @@
+package veclite
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/vecmath"
+)
+
+func TestPipeline_Transforms(t *testing.T) {
+	normalized := Normalize().Apply([]float32{3, 4})
+	if math.Abs(float64(normalized[0])-0.6) > 1e-6 || math.Abs(float64(normalized[1])-0.8) > 1e-6 {
+		t.Fatalf("Expected [0.6 0.8], got %v", normalized)
+	}
+
+	project, err := Project(vecmath.Matrix{Data: []float32{1, 0, 0, 0, 1, 1}, Dim: 3}, []float32{1, 1, 1})
+	if err != nil {
+		t.Fatalf("Project failed: %v", err)
+	}
+	if got := project.Apply([]float32{2, 3, 4}); len(got) != 2 || got[0] != 1 || got[1] != 5 {
+		t.Fatalf("Expected [1 5], got %v", got)
+	}
+
+	quantize, err := Quantize(-1, 1, 5)
+	if err != nil {
+		t.Fatalf("Quantize failed: %v", err)
+	}
+	got := quantize.Apply([]float32{-3, -0.3, 0.2, 0.76, 9})
+	want := []float32{-1, -0.5, 0, 1, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	if _, err := Project(vecmath.Matrix{Data: []float32{1, 2, 3}, Dim: 2}, nil); err == nil {
+		t.Fatal("Expected a ragged projection to fail")
+	}
+	if _, err := Quantize(1, 1, 4); err == nil {
+		t.Fatal("Expected an empty quantization range to fail")
+	}
+}
+
+func TestPipeline_Dims(t *testing.T) {
+	project, _ := Project(vecmath.Matrix{Data: make([]float32, 8), Dim: 4}, nil) // 4 -> 2
+	quantize, _ := Quantize(0, 1, 256)
+
+	if in, err := (Pipeline{Normalize(), project, quantize}).dims(2); err != nil || in != 4 {
+		t.Fatalf("Expected input dimension 4, got %d (%v)", in, err)
+	}
+	if in, err := (Pipeline{Normalize()}).dims(3); err != nil || in != 3 {
+		t.Fatalf("Expected input dimension 3, got %d (%v)", in, err)
+	}
+	if _, err := (Pipeline{project}).dims(3); err == nil {
+		t.Fatal("Expected a mismatched output dimension to fail")
+	}
+	if _, err := (Pipeline{project, project}).dims(2); err == nil {
+		t.Fatal("Expected mismatched steps to fail")
+	}
+}
+
+func TestVecLite_Pipeline(t *testing.T) {
+	// Keeps the first two dimensions of unit-length vectors
+	project, err := Project(vecmath.Matrix{Data: []float32{1, 0, 0, 0, 1, 0}, Dim: 3}, nil)
+	if err != nil {
+		t.Fatalf("Project failed: %v", err)
+	}
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "pipeline.db")
+	config.Dimension = 2
+	config.Pipeline = Pipeline{Normalize(), project}
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if db.InputDimension() != 3 || db.Dimension() != 2 {
+		t.Fatalf("Expected dimensions 3 -> 2, got %d -> %d", db.InputDimension(), db.Dimension())
+	}
+
+	if err := db.Insert(1, []float32{10, 0, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.InsertBatch([]uint64{2}, [][]float32{{0, 5, 0}}); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if err := db.Insert(3, []float32{1, 1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Fatalf("Expected a dimension mismatch for a stored-dimension vector, got %v", err)
+	}
+
+	stored, err := db.Get(1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(stored) != 2 || stored[0] != 1 || stored[1] != 0 {
+		t.Fatalf("Expected the transformed vector [1 0], got %v", stored)
+	}
+
+	// An unnormalized query matches the normalized vectors
+	results, err := db.Search([]float32{0, 100, 0}, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 2 || results[0].Distance > 1e-6 {
+		t.Fatalf("Expected vector 2 at distance ~0, got %+v", results)
+	}
+	if _, err := db.Search([]float32{0, 1}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Fatalf("Expected a dimension mismatch for a stored-dimension query, got %v", err)
+	}
+
+	if query, err := db.Preprocess([]float32{0, 0, 3}); err != nil || query[0] != 0 || query[1] != 0 {
+		t.Fatalf("Expected [0 0], got %v (%v)", query, err)
+	}
+
+	project4, _ := Project(vecmath.Matrix{Data: make([]float32, 8), Dim: 4}, nil)
+	config.Pipeline = Pipeline{project4}
+	config.Dimension = 3
+	if _, err := New(config); err == nil {
+		t.Fatal("Expected New to reject a pipeline not ending at Dimension")
+	}
+}
@@ -0,0 +1,159 @@
+package veclite
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/monishSR/veclite/internal/profile"
+)
+
+// SearchCounters counts the work done by searches: distance computations,
+// candidates scored, vector reads, how many of those reads the storage cache
+// served and the bytes read from disk for the others
+type SearchCounters = profile.Counters
+
+// SearchStage is the time one search spent in one stage
+// Stages are "admission" (estimating the cost and waiting in the queue of
+// Config.QueryBudget), "lock" (waiting for the read lock), "index" (the index
+// search, with nested index stages such as "hnsw.layer0" or "ivf.scan", and
+// "plan" and "prefilter" for filtered searches), "cold" (scanning cold
+// segments) and "project"
+type SearchStage = profile.Stage
+
+// StageTiming aggregates one stage over the sampled searches
+type StageTiming struct {
+	Name  string
+	Count uint64        // Sampled searches that went through the stage
+	Total time.Duration // Sum of the stage durations
+	Max   time.Duration // Slowest occurrence
+}
+
+// Mean returns the mean duration of the stage
+func (t StageTiming) Mean() time.Duration {
+	if t.Count == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Count)
+}
+
+// ProfileStats reports the search counters and sampled stage timings in Stats
+type ProfileStats struct {
+	Searches   uint64         // Searches since open
+	Counters   SearchCounters // Work of all searches since open
+	SampleRate int            // Config.ProfileSampleRate
+	Sampled    uint64         // Searches with recorded stage timings
+	Stages     []StageTiming  // Stage timings of the sampled searches, in first-seen order
+}
+
+// Explanation describes how a search was executed, see Explain
+type Explanation struct {
+	TraceID      string         // SearchOptions.TraceID of the search
+	ServingIndex string         // Index that answered the search ("flat" while degraded)
+	Plan         QueryPlan      // How the filter, if any, was applied
+	Results      []SearchResult // The search results
+	Estimate     QueryCost      // Work estimated before the search, see Config.QueryBudget
+	Counters     SearchCounters // Work done by this search
+	Stages       []SearchStage  // Time per stage, in the order the stages ended
+	Duration     time.Duration  // Total time, including waiting for the lock
+}
+
+// searchProfiler maintains the search counters and aggregates the stage
+// timings of 1 in Config.ProfileSampleRate searches
+// The zero value is ready to use
+type searchProfiler struct {
+	searches   atomic.Uint64
+	distances  atomic.Uint64
+	candidates atomic.Uint64
+	reads      atomic.Uint64
+	cacheHits  atomic.Uint64
+	bytesRead  atomic.Uint64
+
+	mu      sync.Mutex
+	sampled uint64
+	stages  []StageTiming
+}
+
+// begin creates the profile of a new search, timed if it is picked for
+// sampling or always is set
+func (sp *searchProfiler) begin(sampleRate int, always bool) *profile.Profile {
+	n := sp.searches.Add(1)
+	return profile.New(always || (sampleRate > 0 && n%uint64(sampleRate) == 0))
+}
+
+// end adds the work of a finished search to the totals
+func (sp *searchProfiler) end(p *profile.Profile) {
+	sp.distances.Add(p.Distances)
+	sp.candidates.Add(p.Candidates)
+	sp.reads.Add(p.Reads)
+	sp.cacheHits.Add(p.CacheHits)
+	sp.bytesRead.Add(p.BytesRead)
+	if !p.Timed() {
+		return
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.sampled++
+	for _, stage := range p.Stages() {
+		timing := sp.stage(stage.Name)
+		timing.Count++
+		timing.Total += stage.Duration
+		timing.Max = max(timing.Max, stage.Duration)
+	}
+}
+
+// stage returns the aggregate of the named stage, adding it if needed
+// Note: Assumes sp.mu is held
+func (sp *searchProfiler) stage(name string) *StageTiming {
+	for i := range sp.stages {
+		if sp.stages[i].Name == name {
+			return &sp.stages[i]
+		}
+	}
+	sp.stages = append(sp.stages, StageTiming{Name: name})
+	return &sp.stages[len(sp.stages)-1]
+}
+
+// state returns the profiler state for Stats
+func (sp *searchProfiler) state(sampleRate int) ProfileStats {
+	stats := ProfileStats{
+		Searches: sp.searches.Load(),
+		Counters: SearchCounters{
+			Distances:  sp.distances.Load(),
+			Candidates: sp.candidates.Load(),
+			Reads:      sp.reads.Load(),
+			CacheHits:  sp.cacheHits.Load(),
+			BytesRead:  sp.bytesRead.Load(),
+		},
+		SampleRate: sampleRate,
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	stats.Sampled = sp.sampled
+	stats.Stages = append([]StageTiming(nil), sp.stages...)
+	return stats
+}
+
+// Explain runs a search like SearchWithOptions and reports how it was executed:
+// the serving index, the query plan, the work counters and the time spent in each stage
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) Explain(query []float32, k int, opts SearchOptions) (*Explanation, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.ExplainContext(ctx, query, k, opts)
+}
+
+// ExplainContext is Explain with a context, see SearchContext
+func (v *VecLite) ExplainContext(ctx context.Context, query []float32, k int, opts SearchOptions) (*Explanation, error) {
+	explain := &Explanation{TraceID: opts.TraceID}
+	start := time.Now()
+	results, err := v.search(ctx, query, k, opts, explain)
+	if err != nil {
+		return nil, err
+	}
+	explain.Results = results
+	explain.Duration = time.Since(start)
+	return explain, nil
+}
@@ -0,0 +1,119 @@
+package veclite
+
+import (
+	"testing"
+)
+
+// insertTestVectors inserts n vectors of dimension 128 with IDs 1..n
+func insertTestVectors(t *testing.T, db *VecLite, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		vector := make([]float32, 128)
+		for j := range vector {
+			vector[j] = float32(i) + float32(j)*0.001
+		}
+		if err := db.Insert(uint64(i), vector); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		insertTestVectors(t, db, 50)
+
+		query := make([]float32, 128)
+		explain, err := db.Explain(query, 5, DefaultSearchOptions())
+		if err != nil {
+			t.Fatalf("Explain failed: %v", err)
+		}
+		if explain.ServingIndex != indexType || len(explain.Results) == 0 {
+			t.Fatalf("Unexpected explanation: %+v", explain)
+		}
+		if explain.Counters.Distances == 0 || explain.Counters.Reads == 0 {
+			t.Errorf("Expected counted work, got %+v", explain.Counters)
+		}
+		names := make(map[string]bool)
+		for _, stage := range explain.Stages {
+			names[stage.Name] = true
+		}
+		if !names["lock"] || !names["index"] || !names["project"] {
+			t.Errorf("Missing database stages: %+v", explain.Stages)
+		}
+		if explain.Duration <= 0 {
+			t.Error("Expected a positive duration")
+		}
+	})
+}
+
+func TestExplain_InvalidQuery(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if _, err := db.Explain([]float32{1}, 5, DefaultSearchOptions()); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+}
+
+func TestStats_Profile(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	db.config.ProfileSampleRate = 2
+	insertTestVectors(t, db, 10)
+
+	query := make([]float32, 128)
+	for i := 0; i < 4; i++ {
+		if _, err := db.Search(query, 3); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+
+	stats := db.Stats().Profile
+	if stats.Searches != 4 || stats.Sampled != 2 || stats.SampleRate != 2 {
+		t.Errorf("Expected 4 searches with 2 sampled, got %+v", stats)
+	}
+	if stats.Counters.Distances != 40 || stats.Counters.Reads != 40 {
+		t.Errorf("Expected 40 distances and reads for 4 flat scans of 10, got %+v", stats.Counters)
+	}
+	var index *StageTiming
+	for i := range stats.Stages {
+		if stats.Stages[i].Name == "index" {
+			index = &stats.Stages[i]
+		}
+	}
+	if index == nil || index.Count != 2 || index.Max < index.Mean() {
+		t.Errorf("Unexpected index stage timing: %+v", stats.Stages)
+	}
+}
+
+func TestSearch_Cost(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		insertTestVectors(t, db, 50)
+		db.storage.TrimCache(0) // Reads go to disk
+
+		var cost SearchCounters
+		opts := DefaultSearchOptions()
+		opts.Cost = &cost
+		if _, err := db.SearchWithOptions(make([]float32, 128), 5, opts); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if cost.Distances == 0 || cost.Candidates == 0 || cost.Candidates > cost.Distances || cost.Reads == 0 {
+			t.Errorf("Expected counted work, got %+v", cost)
+		}
+		if cost.CacheHits == cost.Reads || cost.BytesRead == 0 {
+			t.Errorf("Expected cache misses reading bytes from disk, got %+v", cost)
+		}
+		if indexType == "flat" && cost.Candidates != 50 {
+			t.Errorf("Expected a flat scan to score all 50 vectors, got %+v", cost)
+		}
+
+		total := db.Stats().Profile.Counters
+		if total.Candidates != cost.Candidates || total.BytesRead != cost.BytesRead {
+			t.Errorf("Expected the totals to include the search, got %+v and %+v", total, cost)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package veclite
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Long operations reported through Config.Progress
+const (
+	OpBulkLoad = "bulk_load" // InsertBatch
+	OpReindex  = "reindex"   // Reindex, Retrain and Config.AutoReindex
+	OpCompact  = "compact"   // Compact and the compaction of Close
+	OpPack     = "pack"      // Pack
+	OpRebuild  = "rebuild"   // Graph rebuilds: RebuildIndex and degraded-mode rebuilds
+	OpRecovery = "recovery"  // Scan of the data file when the footer index is missing
+)
+
+// Progress is an event emitted by a long operation
+// Every operation reports Done == Total for each of its stages when the stage
+// completes, and Stats().Operations lists the operations running
+type Progress struct {
+	Op      string        // One of the Op constants
+	Stage   string        // Step of Op, e.g. "index" or "save" ("" for single-step operations)
+	Done    int64         // Units processed in the stage so far
+	Total   int64         // Units the stage will process (0 = not known yet)
+	Unit    string        // "vectors", "records" or "bytes"
+	Elapsed time.Duration // Since the operation started
+	ETA     time.Duration // Remaining time of the stage, estimated from its rate so far (0 = unknown)
+}
+
+// ProgressFunc receives Progress events
+// It runs on the goroutine doing the work, often with the write lock held, so
+// it must return quickly and must not call the database
+type ProgressFunc func(Progress)
+
+// Fraction returns the completed fraction of the stage (0 if Total is unknown)
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Done) / float64(p.Total)
+}
+
+// String formats the event for logs and CLIs, e.g.
+// "reindex index 512/1024 vectors (50%), ETA 3s"
+func (p Progress) String() string {
+	s := p.Op
+	if p.Stage != "" {
+		s += " " + p.Stage
+	}
+	if p.Total > 0 {
+		s += fmt.Sprintf(" %d/%d %s (%d%%)", p.Done, p.Total, p.Unit, p.Done*100/p.Total)
+	} else {
+		s += fmt.Sprintf(" %d %s", p.Done, p.Unit)
+	}
+	if p.ETA > 0 {
+		s += fmt.Sprintf(", ETA %v", p.ETA.Round(time.Second))
+	}
+	return s
+}
+
+// progressTracker emits the events of one operation
+// A nil tracker (no Config.Progress and no registry) ignores reports
+type progressTracker struct {
+	fn       ProgressFunc
+	registry *progressRegistry // Lists the operation in Stats (nil = not listed)
+	op       string
+	start    time.Time
+
+	mu         sync.Mutex // Guards the fields below against Stats readers
+	stage      string
+	stageStart time.Time
+	last       Progress
+}
+
+// report emits the progress of stage
+func (t *progressTracker) report(stage string, done, total int64, unit string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if stage != t.stage || t.stageStart.IsZero() {
+		t.stage, t.stageStart = stage, now
+	}
+	p := Progress{Op: t.op, Stage: stage, Done: done, Total: total, Unit: unit, Elapsed: now.Sub(t.start)}
+	if elapsed := now.Sub(t.stageStart); done > 0 && total > done && elapsed > 0 {
+		p.ETA = time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+	}
+	t.last = p
+	t.mu.Unlock()
+
+	if t.fn != nil {
+		t.fn(p) // Unlocked, so the callback may read Stats().Operations
+	}
+}
+
+// latest returns the last event reported
+func (t *progressTracker) latest() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// finish removes the operation from Stats().Operations
+func (t *progressTracker) finish() {
+	if t != nil && t.registry != nil {
+		t.registry.remove(t)
+	}
+}
+
+// newProgress starts tracking op for fn outside a database, e.g. RebuildIndex
+// (nil if fn is nil)
+func newProgress(fn ProgressFunc, op string) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, op: op, start: time.Now(), last: Progress{Op: op}}
+}
+
+// progressRegistry lists the running operations of a database
+type progressRegistry struct {
+	mu      sync.Mutex
+	running map[*progressTracker]struct{}
+}
+
+// remove drops a finished operation
+func (r *progressRegistry) remove(t *progressTracker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, t)
+}
+
+// operations returns the latest event of each running operation, oldest first
+func (r *progressRegistry) operations() []Progress {
+	r.mu.Lock()
+	trackers := make([]*progressTracker, 0, len(r.running))
+	for t := range r.running {
+		trackers = append(trackers, t)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(trackers, func(i, j int) bool { return trackers[i].start.Before(trackers[j].start) })
+	var ops []Progress
+	for _, t := range trackers {
+		p := t.latest()
+		p.Elapsed = time.Since(t.start)
+		ops = append(ops, p)
+	}
+	return ops
+}
+
+// trackProgress starts tracking op: its events go to Config.Progress and it
+// is listed in Stats().Operations until finish is called
+func (v *VecLite) trackProgress(op string) *progressTracker {
+	t := newProgress(v.config.Progress, op)
+	if t == nil {
+		t = &progressTracker{op: op, start: time.Now(), last: Progress{Op: op}}
+	}
+	t.registry = &v.progress
+	v.progress.mu.Lock()
+	defer v.progress.mu.Unlock()
+	if v.progress.running == nil {
+		v.progress.running = make(map[*progressTracker]struct{})
+	}
+	v.progress.running[t] = struct{}{}
+	return t
+}
+
+// progressWriter reports the bytes written through it, at most once per
+// progressBytes and when Done reaches Total
+type progressWriter struct {
+	tracker  *progressTracker
+	stage    string
+	done     int64
+	total    int64
+	reported int64
+}
+
+// progressBytes is how many bytes byte-counted stages process between reports
+const progressBytes = 1 << 20
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	if w.done-w.reported >= progressBytes || w.done == w.total {
+		w.reported = w.done
+		w.tracker.report(w.stage, w.done, w.total, "bytes")
+	}
+	return len(p), nil
+}
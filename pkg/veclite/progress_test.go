@@ -0,0 +1,169 @@
+package veclite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recordProgress returns a ProgressFunc collecting the events it receives
+func recordProgress(events *[]Progress) ProgressFunc {
+	return func(p Progress) { *events = append(*events, p) }
+}
+
+// lastEvent returns the last event of op and stage
+func lastEvent(events []Progress, op, stage string) (Progress, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Op == op && events[i].Stage == stage {
+			return events[i], true
+		}
+	}
+	return Progress{}, false
+}
+
+func TestProgress_String(t *testing.T) {
+	p := Progress{Op: OpReindex, Stage: "index", Done: 512, Total: 1024, Unit: "vectors", ETA: 2600 * time.Millisecond}
+	if got := p.String(); got != "reindex index 512/1024 vectors (50%), ETA 3s" {
+		t.Errorf("Unexpected string %q", got)
+	}
+	if p.Fraction() != 0.5 {
+		t.Errorf("Expected fraction 0.5, got %v", p.Fraction())
+	}
+	p = Progress{Op: OpCompact, Done: 10, Unit: "records"}
+	if got := p.String(); got != "compact 10 records" {
+		t.Errorf("Unexpected string %q", got)
+	}
+}
+
+func TestProgressTracker_ETA(t *testing.T) {
+	var events []Progress
+	tracker := newProgress(recordProgress(&events), OpBulkLoad)
+	tracker.report("index", 0, 100, "vectors")
+	time.Sleep(20 * time.Millisecond)
+	tracker.report("index", 50, 100, "vectors")
+	tracker.report("index", 100, 100, "vectors")
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].ETA != 0 {
+		t.Errorf("Expected no ETA before any progress, got %v", events[0].ETA)
+	}
+	if eta := events[1].ETA; eta < 15*time.Millisecond || eta > time.Second {
+		t.Errorf("Expected an ETA about the elapsed time at half way, got %v", eta)
+	}
+	if events[2].ETA != 0 || events[2].Elapsed < 20*time.Millisecond {
+		t.Errorf("Expected no ETA and the elapsed time when done, got %+v", events[2])
+	}
+
+	var nilTracker *progressTracker
+	nilTracker.report("index", 1, 2, "vectors") // Must not panic
+	nilTracker.finish()
+}
+
+func TestVecLite_Progress(t *testing.T) {
+	var events []Progress
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "progress.db")
+	config.Dimension = 4
+	config.IndexType = "hnsw"
+	config.M = 8
+	config.EfConstruction = 50
+	config.Progress = recordProgress(&events)
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Bulk loads report every bulkLoadChunk vectors while listed in Stats
+	n := bulkLoadChunk + 100
+	ids := make([]uint64, n)
+	vectors := make([][]float32, n)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+		vectors[i] = []float32{float32(i), 1, 0, 0}
+	}
+	var running []Progress
+	db.config.Progress = func(p Progress) {
+		events = append(events, p)
+		if p.Op == OpBulkLoad && p.Done == bulkLoadChunk {
+			running = db.progress.operations()
+		}
+	}
+	if err := db.InsertBatch(ids, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	var loaded []int64
+	for _, p := range events {
+		if p.Op == OpBulkLoad {
+			loaded = append(loaded, p.Done)
+		}
+	}
+	if len(loaded) != 3 || loaded[1] != bulkLoadChunk || loaded[2] != int64(n) {
+		t.Errorf("Expected bulk load progress 0, %d, %d, got %v", bulkLoadChunk, n, loaded)
+	}
+	if len(running) != 1 || running[0].Op != OpBulkLoad || running[0].Total != int64(n) {
+		t.Errorf("Expected the bulk load in the running operations, got %+v", running)
+	}
+	if ops := db.Stats().Operations; len(ops) != 0 {
+		t.Errorf("Expected no running operations afterwards, got %+v", ops)
+	}
+
+	if err := db.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if p, ok := lastEvent(events, OpReindex, "index"); !ok || p.Done != int64(n) || p.Total != int64(n) {
+		t.Errorf("Expected reindex to report all %d vectors indexed, got %+v", n, p)
+	}
+	if p, ok := lastEvent(events, OpReindex, "save"); !ok || p.Done != 1 {
+		t.Errorf("Expected reindex to report the save, got %+v", p)
+	}
+
+	for id := uint64(1); id <= 10; id++ {
+		if err := db.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if err := db.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if p, ok := lastEvent(events, OpCompact, ""); !ok || p.Done != int64(n-10) || p.Unit != "records" {
+		t.Errorf("Expected compaction to report %d records, got %+v", n-10, p)
+	}
+
+	archive := filepath.Join(t.TempDir(), "backup.vlpack")
+	if err := db.Pack(archive, PackOptions{}); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	checksummed, ok := lastEvent(events, OpPack, "checksum")
+	archived, ok2 := lastEvent(events, OpPack, "archive")
+	if !ok || !ok2 || checksummed.Done != checksummed.Total || archived.Done != archived.Total || archived.Total == 0 {
+		t.Errorf("Expected pack to report both stages complete, got %+v and %+v", checksummed, archived)
+	}
+	if ops := db.Stats().Operations; len(ops) != 0 {
+		t.Errorf("Expected no running operations afterwards, got %+v", ops)
+	}
+}
+
+func TestRebuildIndex_Progress(t *testing.T) {
+	db := createPackTestDB(t)
+	config := *db.config
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	os.Remove(config.DataPath + ".graph")
+
+	var events []Progress
+	config.Progress = recordProgress(&events)
+	if err := RebuildIndex(&config, nil); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if p, ok := lastEvent(events, OpRebuild, "index"); !ok || p.Done != 31 || p.Total != 31 {
+		t.Errorf("Expected the rebuild to report 31 vectors indexed, got %+v", p)
+	}
+	if p, ok := lastEvent(events, OpRebuild, "save"); !ok || p.Unit != "files" || p.Done != 1 {
+		t.Errorf("Expected the rebuild to report the save, got %+v", p)
+	}
+}
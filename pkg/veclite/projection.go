@@ -0,0 +1,78 @@
+package veclite
+
+import (
+	"context"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/index/hnsw"
+)
+
+// SearchOptions selects which fields of each search result are filled in
+// Leaving out vectors avoids copying dimension*4 bytes per hit into the response
+type SearchOptions struct {
+	IncludeVector       bool     // Fill SearchResult.Vector
+	IncludeScore        bool     // Fill SearchResult.Distance
+	IncludeMetadata     bool     // Fill SearchResult.Metadata with all keys
+	IncludeMetadataKeys []string // Fill SearchResult.Metadata with only these keys (overrides IncludeMetadata)
+	Filter              Filter   // Only return vectors whose metadata matches (nil = all vectors)
+	TraceID             string   // Request or trace ID recorded with the search in the query log, slow queries, Explain output and pprof labels
+	Reranker            Reranker // Reorders the over-fetched candidates before they are truncated to k (nil = rank by distance)
+	RerankCandidates    int      // Candidates fetched for the Reranker (0 = 4*k)
+
+	// SeedID starts HNSW traversal from this node instead of the entry point,
+	// e.g. the previous result of a session of related queries, cutting the
+	// hops to reach the query's neighborhood (0 = entry point; ignored by
+	// Flat and IVF and if the ID is not in the graph)
+	SeedID uint64
+
+	// Cost receives the work done by the search when it returns, e.g. to meter
+	// tenants or to tune k and ef from the client (nil = not reported)
+	// Filled on errors too, with the work done until the search stopped
+	Cost *SearchCounters
+
+	// Cursor is filled with the state of the search when it succeeds, for
+	// fetching further results with More (nil = not retained)
+	Cursor *SearchCursor
+}
+
+// DefaultSearchOptions returns options including every field, as used by Search
+func DefaultSearchOptions() SearchOptions {
+	return SearchOptions{
+		IncludeVector:   true,
+		IncludeScore:    true,
+		IncludeMetadata: true,
+	}
+}
+
+// indexContext returns ctx carrying the search seed of opts, if any, and
+// telling the index to skip copying vectors unless opts.IncludeVector is set
+func (opts SearchOptions) indexContext(ctx context.Context) context.Context {
+	if !opts.IncludeVector {
+		ctx = index.WithoutVectors(ctx)
+	}
+	if opts.SeedID == 0 {
+		return ctx
+	}
+	return hnsw.WithSeed(ctx, opts.SeedID)
+}
+
+// project strips the result fields not selected by opts and attaches metadata
+// and string keys
+// Note: Assumes read lock is already held
+func (v *VecLite) project(results []index.SearchResult, opts SearchOptions) {
+	withMetadata := opts.IncludeMetadata || opts.IncludeMetadataKeys != nil
+	v.keys.attach(results)
+	for i := range results {
+		if !opts.IncludeVector {
+			results[i].Vector = nil
+		}
+		if !opts.IncludeScore {
+			results[i].Distance = 0
+		}
+		if withMetadata {
+			if metadata, exists := v.metadata.Get(results[i].ID, opts.IncludeMetadataKeys); exists {
+				results[i].Metadata = metadata
+			}
+		}
+	}
+}
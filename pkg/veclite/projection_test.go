@@ -0,0 +1,99 @@
+package veclite
+
+import (
+	"os"
+	"testing"
+)
+
+func TestVecLite_SearchWithOptions(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+
+	for i := uint64(1); i <= 3; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.InsertWithMetadata(i, vec, Metadata{"title": "doc", "lang": "en"}); err != nil {
+			t.Fatalf("InsertWithMetadata failed: %v", err)
+		}
+	}
+	query := make([]float32, 128)
+	query[0] = 3
+
+	// Default Search returns every field
+	results, err := db.Search(query, 2)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results[1].Vector == nil || results[1].Distance == 0 || len(results[1].Metadata) != 2 {
+		t.Errorf("Expected all fields from Search, got %+v", results[1])
+	}
+
+	// IDs only
+	results, err = db.SearchWithOptions(query, 2, SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchWithOptions failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != 3 {
+		t.Fatalf("Unexpected results: %+v", results)
+	}
+	for _, r := range results {
+		if r.Vector != nil || r.Distance != 0 || r.Metadata != nil {
+			t.Errorf("Expected only IDs, got %+v", r)
+		}
+	}
+
+	// Score and a subset of metadata keys
+	results, err = db.SearchWithOptions(query, 2, SearchOptions{IncludeScore: true, IncludeMetadataKeys: []string{"title", "missing"}})
+	if err != nil {
+		t.Fatalf("SearchWithOptions failed: %v", err)
+	}
+	if results[1].Distance == 0 || results[1].Vector != nil {
+		t.Errorf("Expected score without vector, got %+v", results[1])
+	}
+	if len(results[1].Metadata) != 1 || results[1].Metadata["title"] != "doc" {
+		t.Errorf("Expected only the title key, got %v", results[1].Metadata)
+	}
+}
+
+func TestVecLite_SearchSeedID(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		for i := 1; i <= 50; i++ {
+			if err := db.Insert(uint64(i), testVector(i)); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		probeAllClusters(db)
+
+		// A session of related queries, each seeded with the previous result
+		var seed uint64
+		for i := 10; i <= 15; i++ {
+			opts := SearchOptions{IncludeScore: true, SeedID: seed}
+			results, err := db.SearchWithOptions(testVector(i), 3, opts)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) != 3 || results[0].ID != uint64(i) {
+				t.Fatalf("Expected ID %d first with seed %d, got %v", i, seed, results)
+			}
+			seed = results[0].ID
+		}
+
+		// Unknown seeds are ignored
+		results, err := db.SearchWithOptions(testVector(20), 1, SearchOptions{SeedID: 999})
+		if err != nil || len(results) != 1 || results[0].ID != 20 {
+			t.Errorf("Expected ID 20 with an unknown seed, got %v (%v)", results, err)
+		}
+
+		it, err := db.Candidates(testVector(30), 5, SearchOptions{SeedID: 29})
+		if err != nil {
+			t.Fatalf("Candidates failed: %v", err)
+		}
+		if !it.Next() || it.Candidate().ID != 30 {
+			t.Errorf("Expected ID 30 as the first seeded candidate")
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package veclite
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// QueryLogConfig enables the query log of searches
+// Every search (or a sample of them) is appended as one JSON line with its
+// options, latency and result IDs, so a workload can be replayed against a
+// rebuilt or retuned index with Replay or "veclite replay"
+type QueryLogConfig struct {
+	Path        string // Log file path (default: DataPath + ".queries")
+	FullVectors bool   // Record query vectors; without them entries only carry a hash and can't be replayed
+	SampleRate  int    // Record 1 in SampleRate searches (0 = every search)
+	MaxSize     int64  // Rotate once the log exceeds this many bytes (0 = never rotate)
+	MaxBackups  int    // Rotated files kept as Path.1 ... Path.N (0 = keep none)
+	Sync        bool   // fsync after every entry (slower, but survives power loss)
+}
+
+// QueryLogEntry is one line of the query log
+type QueryLogEntry struct {
+	Time    time.Time     `json:"time"`
+	TraceID string        `json:"trace_id,omitempty"` // SearchOptions.TraceID of the search
+	Hash    string        `json:"hash"`               // FNV-1a hash of the query vector, see QueryHash
+	Vector  []float32     `json:"vector,omitempty"`   // Query vector (QueryLogConfig.FullVectors only)
+	K       int           `json:"k"`
+	Filter  Filter        `json:"filter,omitempty"`
+	Latency time.Duration `json:"latency_ns"`
+	Results []uint64      `json:"results,omitempty"` // Result IDs, nearest first
+	Error   string        `json:"error,omitempty"`
+}
+
+// QueryHash returns the hash recorded for query in the query log, so logged
+// queries can be matched against known vectors without logging them in full
+func QueryHash(query []float32) string {
+	h := fnv.New64a()
+	var buf [4]byte
+	for _, value := range query {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(value))
+		h.Write(buf[:])
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// queryLog appends QueryLogEntry records to a rotating JSON-lines file
+type queryLog struct {
+	fullVectors bool
+	sampleRate  uint64
+	searches    atomic.Uint64 // Searches seen, for sampling
+	log         *rotatingLog
+}
+
+// openQueryLog opens (or creates) the query log for appending
+func openQueryLog(config QueryLogConfig) (*queryLog, error) {
+	log, err := openRotatingLog("query log", config.Path, config.MaxSize, config.MaxBackups, config.Sync)
+	if err != nil {
+		return nil, err
+	}
+	return &queryLog{fullVectors: config.FullVectors, sampleRate: uint64(max(config.SampleRate, 1)), log: log}, nil
+}
+
+// sample reports whether the next search should be recorded
+func (q *queryLog) sample() bool {
+	return (q.searches.Add(1)-1)%q.sampleRate == 0
+}
+
+// Record appends one search
+func (q *queryLog) Record(query []float32, k int, opts SearchOptions, latency time.Duration, results []SearchResult, searchErr error) error {
+	entry := QueryLogEntry{
+		Time:    time.Now().UTC(),
+		TraceID: opts.TraceID,
+		Hash:    QueryHash(query),
+		K:       k,
+		Filter:  opts.Filter,
+		Latency: latency,
+	}
+	if q.fullVectors {
+		entry.Vector = query
+	}
+	if searchErr != nil {
+		entry.Error = searchErr.Error()
+	}
+	for _, result := range results {
+		entry.Results = append(entry.Results, result.ID)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode query log entry: %w", err)
+	}
+	return q.log.append(append(line, '\n'))
+}
+
+// Close syncs and closes the active log file
+func (q *queryLog) Close() error {
+	return q.log.Close()
+}
+
+// ReadQueryLog reads all entries from one query log file, oldest first
+func ReadQueryLog(path string) ([]QueryLogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []QueryLogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20) // Lines with full vectors can be long
+	for line := 1; scanner.Scan(); line++ {
+		var entry QueryLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid query log entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReplayReport compares replayed queries with the logged ones
+type ReplayReport struct {
+	Queries    int            // Entries in the log
+	Replayed   int            // Entries searched again
+	Skipped    int            // Entries without a vector, of another dimension, or that failed when logged
+	Errors     int            // Replayed searches that failed
+	Identical  int            // Replayed searches returning the logged IDs in the same order
+	Overlap    float64        // Mean fraction of the logged result IDs returned again (1 = same result sets)
+	KendallTau float64        // Mean rank correlation of the result IDs returned again, see CompareResults
+	Logged     LatencySummary // Latency of the replayed entries when they were logged
+	Replay     LatencySummary // Latency of the replayed searches
+}
+
+// LatencySummary describes a latency distribution
+type LatencySummary struct {
+	Mean, P50, P95, P99, Max time.Duration
+}
+
+// summarizeLatencies computes the summary of latencies (sorted in place)
+func summarizeLatencies(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	sorted := make([]float64, len(latencies))
+	var total time.Duration
+	for i, latency := range latencies {
+		sorted[i] = float64(latency)
+		total += latency
+	}
+	return LatencySummary{
+		Mean: total / time.Duration(len(latencies)),
+		P50:  time.Duration(percentile(sorted, 0.50)),
+		P95:  time.Duration(percentile(sorted, 0.95)),
+		P99:  time.Duration(percentile(sorted, 0.99)),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// Replay runs the logged queries of entries against the database, one at a
+// time, and compares their results and latency with the log
+// Replayed searches are recorded in the query log if this database has one
+func (v *VecLite) Replay(ctx context.Context, entries []QueryLogEntry) (*ReplayReport, error) {
+	report := &ReplayReport{Queries: len(entries)}
+	var loggedLatencies, replayLatencies []time.Duration
+	var overlap, tau float64
+	opts := SearchOptions{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(entry.Vector) != v.inputDim || entry.K <= 0 || entry.Error != "" {
+			report.Skipped++
+			continue
+		}
+		opts.Filter = entry.Filter
+		opts.TraceID = entry.TraceID
+		start := time.Now()
+		results, err := v.SearchWithOptionsContext(ctx, entry.Vector, entry.K, opts)
+		latency := time.Since(start)
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+		report.Replayed++
+		loggedLatencies = append(loggedLatencies, entry.Latency)
+		replayLatencies = append(replayLatencies, latency)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+
+		logged := make([]SearchResult, len(entry.Results))
+		for i, id := range entry.Results {
+			logged[i] = SearchResult{ID: id}
+		}
+		comparison := CompareResults(logged, results)
+		if comparison.Identical {
+			report.Identical++
+		}
+		overlap += comparison.Overlap
+		tau += comparison.KendallTau
+	}
+	if report.Replayed == 0 && report.Queries > 0 {
+		return nil, errors.New("no replayable entries: the log must be recorded with FullVectors and the same dimension")
+	}
+	if answered := report.Replayed - report.Errors; answered > 0 {
+		report.Overlap = overlap / float64(answered)
+		report.KendallTau = tau / float64(answered)
+	}
+	report.Logged = summarizeLatencies(loggedLatencies)
+	report.Replay = summarizeLatencies(replayLatencies)
+	return report, nil
+}
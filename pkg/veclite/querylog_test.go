@@ -0,0 +1,171 @@
+package veclite
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newQueryLogTestDB creates a flat database with vectors 1..20 of dimension 4
+// logging queries with queryLog
+func newQueryLogTestDB(t *testing.T, queryLog *QueryLogConfig) (*VecLite, *Config) {
+	t.Helper()
+	db, _ := createTestDB(t, "flat", smallVectors, func(config *Config) {
+		config.QueryLog = queryLog
+	})
+	insertSmallVectors(t, db, 20, nil)
+	return db, db.config
+}
+
+func TestVecLite_QueryLog(t *testing.T) {
+	db, config := newQueryLogTestDB(t, &QueryLogConfig{FullVectors: true})
+
+	query := []float32{3, 1, 0, 0}
+	if _, err := db.Search(query, 2); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if _, err := db.Search([]float32{1, 2}, 2); err == nil {
+		t.Fatal("Expected dimension mismatch")
+	}
+	if _, err := db.Explain(query, 2, DefaultSearchOptions()); err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := ReadQueryLog(config.DataPath + ".queries")
+	if err != nil {
+		t.Fatalf("ReadQueryLog failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 logged search, got %d: %+v", len(entries), entries)
+	}
+	entry := entries[0]
+	if entry.K != 2 || entry.Hash != QueryHash(query) || len(entry.Vector) != 4 || entry.Latency <= 0 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if len(entry.Results) != 2 || entry.Results[0] != 3 {
+		t.Errorf("Expected results starting with 3, got %v", entry.Results)
+	}
+}
+
+func TestVecLite_QueryLog_HashOnlySampled(t *testing.T) {
+	db, config := newQueryLogTestDB(t, &QueryLogConfig{SampleRate: 3})
+	for i := 0; i < 7; i++ {
+		if _, err := db.Search([]float32{float32(i), 1, 0, 0}, 1); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := ReadQueryLog(config.DataPath + ".queries")
+	if err != nil {
+		t.Fatalf("ReadQueryLog failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected searches 1, 4 and 7 to be logged, got %d entries", len(entries))
+	}
+	if entries[0].Vector != nil || entries[1].Hash != QueryHash([]float32{3, 1, 0, 0}) {
+		t.Errorf("Expected hash-only entries, got %+v", entries[:2])
+	}
+
+	// Hashed entries can't be replayed
+	replayDB, _ := newQueryLogTestDB(t, nil)
+	defer replayDB.Close()
+	if _, err := replayDB.Replay(context.Background(), entries); err == nil {
+		t.Error("Expected replay of hash-only entries to fail")
+	}
+}
+
+func TestVecLite_Replay(t *testing.T) {
+	db, config := newQueryLogTestDB(t, &QueryLogConfig{FullVectors: true})
+	for i := 1; i <= 10; i++ {
+		if _, err := db.Search([]float32{float32(i) + 0.1, 1, 0, 0}, 3); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	entries, err := ReadQueryLog(config.DataPath + ".queries")
+	if err != nil {
+		t.Fatalf("ReadQueryLog failed: %v", err)
+	}
+	entries = append(entries, QueryLogEntry{Vector: []float32{1, 2}, K: 1})
+
+	config.QueryLog = nil
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	report, err := db.Replay(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if report.Queries != 11 || report.Replayed != 10 || report.Skipped != 1 || report.Errors != 0 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	if report.Overlap != 1 || report.Identical != 10 {
+		t.Errorf("Expected identical results on the same data, got overlap %v, %d identical", report.Overlap, report.Identical)
+	}
+	if report.Logged.P50 <= 0 || report.Replay.Max < report.Replay.P99 {
+		t.Errorf("Unexpected latency summaries: %+v / %+v", report.Logged, report.Replay)
+	}
+
+	// Deleting a logged result lowers the overlap
+	if err := db.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	report, err = db.Replay(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if report.Overlap >= 1 || report.Identical >= 10 {
+		t.Errorf("Expected lower overlap after a delete, got %v, %d identical", report.Overlap, report.Identical)
+	}
+}
+
+func TestSearchOptions_TraceID(t *testing.T) {
+	db, config := newQueryLogTestDB(t, &QueryLogConfig{})
+	db.config.SlowQueryThreshold = time.Nanosecond
+	var labels string
+	db.config.BeforeSearch = func(query []float32, k int) error {
+		labels = goroutineLabels(t)
+		return nil
+	}
+
+	opts := SearchOptions{TraceID: "req-42"}
+	if _, err := db.SearchWithOptions([]float32{3, 1, 0, 0}, 2, opts); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if !strings.Contains(labels, `"veclite.trace":"req-42"`) {
+		t.Error("Expected the trace ID as a pprof label during the search")
+	}
+	if slow := db.SlowQueries(); len(slow) != 1 || slow[0].TraceID != "req-42" {
+		t.Errorf("Expected the slow query to carry the trace ID, got %+v", slow)
+	}
+	explain, err := db.Explain([]float32{3, 1, 0, 0}, 2, opts)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.TraceID != "req-42" {
+		t.Errorf("Expected the trace ID in Explain output, got %q", explain.TraceID)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, err := ReadQueryLog(config.DataPath + ".queries")
+	if err != nil {
+		t.Fatalf("ReadQueryLog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].TraceID != "req-42" {
+		t.Errorf("Expected one logged search with the trace ID, got %+v", entries)
+	}
+}
@@ -0,0 +1,193 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// buildParams lists the parameters baked into the persisted index structure
+// Changing one requires a rebuild; query-time parameters (EfSearch, EntryPoints, PQSubspaces,
+// NProbe, RerankK) are applied directly on open
+var buildParams = map[index.IndexType][]string{
+	index.IndexTypeHNSW:  {"M", "EfConstruction"},
+	index.IndexTypeIVF:   {"NClusters"},
+	index.IndexTypeIVFPQ: {"NClusters"},
+}
+
+// paramsMismatch compares the build parameters loaded with the index against
+// the ones in config and describes any difference ("" if none)
+// Zero config values mean "use the default" and never mismatch
+func paramsMismatch(config *Config, idx index.Index) string {
+	p, ok := idx.(index.Parameterized)
+	if !ok {
+		return ""
+	}
+	loaded := p.Params()
+	configured := indexConfig(config)
+
+	var diffs []string
+	for _, name := range buildParams[index.IndexType(config.IndexType)] {
+		want, _ := configured[name].(int)
+		if want > 0 && want != loaded[name] {
+			diffs = append(diffs, fmt.Sprintf("%s: index %d, config %d", name, loaded[name], want))
+		}
+	}
+	sort.Strings(diffs)
+	return strings.Join(diffs, ", ")
+}
+
+// applyConfigParams reconciles a loaded index with config on open: query-time
+// parameters are applied, and differing build parameters either trigger a
+// rebuild (Config.AutoReindex) or are reported through Stats and a warning
+// Note: Assumes the database is not shared yet
+func (v *VecLite) applyConfigParams() error {
+	if p, ok := v.index.(index.Parameterized); ok {
+		p.SetSearchParams(indexConfig(v.config))
+	}
+
+	mismatch := paramsMismatch(v.config, v.index)
+	if mismatch == "" {
+		return nil
+	}
+	if v.config.AutoReindex {
+		return v.reindex()
+	}
+	fmt.Printf("Warning: %s index was built with different parameters (%s); call Reindex to apply them\n", v.config.IndexType, mismatch)
+	v.paramsMismatch = mismatch
+	return nil
+}
+
+// Reindex rebuilds the index from the vectors in storage using the parameters in
+// Config, e.g. after changing M, EfConstruction or NClusters for an existing database
+// Requires exclusive write lock - blocks all reads and writes while rebuilding
+func (v *VecLite) Reindex() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.degraded != nil {
+		return errors.New("reindex: degraded-mode rebuild in progress")
+	}
+	return v.reindex()
+}
+
+// reindex implements Reindex
+// Note: Assumes write lock is already held
+func (v *VecLite) reindex() error {
+	defer v.beginRewrite("reindex")()
+	tracker := v.trackProgress(OpReindex)
+	defer tracker.finish()
+	tracker.report("read", 0, 0, "vectors")
+	vectors, err := v.storage.ReadAllVectors()
+	if err != nil {
+		return fmt.Errorf("failed to read vectors for reindex: %w", err)
+	}
+	tracker.report("read", int64(len(vectors)), int64(len(vectors)), "vectors")
+
+	keep := v.keepDataID()
+	rebuilt, err := v.buildIndex(vectors, keep, nil, nil, func(done, total int) {
+		v.rewrite.progress(done, total)
+		tracker.report("index", int64(done), int64(total), "vectors")
+	})
+	if err != nil {
+		return err
+	}
+	v.index = rebuilt
+	v.paramsMismatch = ""
+	tracker.report("save", 0, 1, "files")
+	if err := v.saveSidecar(); err != nil {
+		return err
+	}
+	tracker.report("save", 1, 1, "files")
+	return v.dropCentroids(vectors, keep)
+}
+
+// dropCentroids deletes the records rejected by keep from storage
+// Centroid records of an old IVF file are index records, not data: they are
+// dropped only once the index built without them is in use and saved
+// Note: Assumes write lock is already held
+func (v *VecLite) dropCentroids(vectors map[uint64][]float32, keep func(id uint64) bool) error {
+	if keep == nil {
+		return nil
+	}
+	for id := range vectors {
+		if keep(id) {
+			continue
+		}
+		if err := v.storage.DeleteVector(id); err != nil {
+			return fmt.Errorf("failed to remove old centroid %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// RebuildProgress reports the progress of RebuildIndex: the current stage
+// ("footer", "index" or "save") and how many of total vectors it has processed
+// Config.Progress receives the same stages as OpRebuild events, with an ETA
+type RebuildProgress func(stage string, done, total int)
+
+// RebuildIndex regenerates the footer index and the index sidecar (.graph or .ivf)
+// of the database at config.DataPath from its data file alone, using the index
+// type and parameters in config
+// The database must not be open; progress may be nil
+func RebuildIndex(config *Config, progress RebuildProgress) error {
+	if config.Dimension <= 0 {
+		return errors.New("dimension must be greater than 0")
+	}
+	tracker := newProgress(config.Progress, OpRebuild)
+	legacy := progress
+	progress = func(stage string, done, total int) {
+		if legacy != nil {
+			legacy(stage, done, total)
+		}
+		unit := "vectors"
+		if stage == "save" {
+			unit = "files"
+		}
+		tracker.report(stage, int64(done), int64(total), unit)
+	}
+
+	store, err := storage.NewStorage(config.DataPath, config.Dimension, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	if err := store.Open(); err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	v := &VecLite{config: config, storage: store}
+	err = v.rebuildFromData(progress)
+	if closeErr := store.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write footer index: %w", closeErr)
+	}
+	return err
+}
+
+// rebuildFromData implements RebuildIndex on an opened storage
+func (v *VecLite) rebuildFromData(progress RebuildProgress) error {
+	progress("footer", 0, 0)
+	if err := v.storage.RebuildIndex(); err != nil {
+		return err
+	}
+	vectors, err := v.storage.ReadAllVectors()
+	if err != nil {
+		return fmt.Errorf("failed to read vectors: %w", err)
+	}
+	progress("footer", len(vectors), len(vectors))
+
+	built, err := v.buildIndex(vectors, nil, nil, nil, func(done, total int) { progress("index", done, total) })
+	if err != nil {
+		return err
+	}
+	v.index = built
+
+	progress("save", 0, 1)
+	if err := v.saveSidecar(); err != nil {
+		return err
+	}
+	progress("save", 1, 1)
+	return nil
+}
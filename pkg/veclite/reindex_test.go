@@ -0,0 +1,261 @@
+package veclite
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// createParamsDB creates a database with 50 vectors, closes it and returns its config
+func createParamsDB(t *testing.T, indexType string) (*Config, func()) {
+	tmpFile, err := os.CreateTemp("", "veclite_reindex_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	config := DefaultConfig()
+	config.DataPath = tmpFile.Name()
+	config.Dimension = 8
+	config.IndexType = indexType
+	config.M = 8
+	config.EfConstruction = 100
+	config.EfSearch = 50
+	config.NClusters = 4
+	config.NProbe = 4
+	cleanup := func() {
+		os.Remove(config.DataPath)
+		os.Remove(config.DataPath + ".graph")
+		os.Remove(config.DataPath + ".ivf")
+	}
+
+	db, err := New(config)
+	if err != nil {
+		cleanup()
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := uint64(1); i <= 50; i++ {
+		vec := make([]float32, 8)
+		vec[0] = float32(i)
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return config, cleanup
+}
+
+// indexParams returns the parameters in effect for the database's index
+func indexParams(t *testing.T, db *VecLite) map[string]int {
+	p, ok := db.index.(index.Parameterized)
+	if !ok {
+		t.Fatalf("Index %T does not expose parameters", db.index)
+	}
+	return p.Params()
+}
+
+func TestVecLite_ParamsMismatch_ExplicitReindex(t *testing.T) {
+	config, cleanup := createParamsDB(t, "hnsw")
+	defer cleanup()
+
+	config.M = 16
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+
+	stats := db.Stats()
+	if !strings.Contains(stats.ParamsMismatch, "M: index 8, config 16") {
+		t.Errorf("Expected M mismatch to be reported, got %q", stats.ParamsMismatch)
+	}
+	if m := indexParams(t, db)["M"]; m != 8 {
+		t.Errorf("Expected loaded M 8 before Reindex, got %d", m)
+	}
+
+	if err := db.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if stats := db.Stats(); stats.ParamsMismatch != "" || stats.Size != 50 {
+		t.Errorf("Unexpected stats after Reindex: %+v", stats)
+	}
+	if m := indexParams(t, db)["M"]; m != 16 {
+		t.Errorf("Expected M 16 after Reindex, got %d", m)
+	}
+	query := make([]float32, 8)
+	query[0] = 25
+	results, err := db.Search(query, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 25 {
+		t.Errorf("Expected nearest ID 25 after Reindex, got %v (%v)", results, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The rebuilt graph was saved with the new parameters
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	if stats := db.Stats(); stats.ParamsMismatch != "" {
+		t.Errorf("Expected no mismatch after reopening reindexed database, got %q", stats.ParamsMismatch)
+	}
+}
+
+func TestVecLite_ParamsMismatch_AutoReindex(t *testing.T) {
+	config, cleanup := createParamsDB(t, "ivf")
+	defer cleanup()
+
+	config.NClusters = 2
+	config.AutoReindex = true
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	if n := indexParams(t, db)["NClusters"]; n != 2 {
+		t.Errorf("Expected NClusters 2 after automatic reindex, got %d", n)
+	}
+	stats := db.Stats()
+	if stats.ParamsMismatch != "" || stats.Size != 50 {
+		t.Errorf("Unexpected stats after automatic reindex: %+v", stats)
+	}
+
+	results, err := db.Search(make([]float32, 8), 50)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, r := range results {
+		if r.ID < 1 || r.ID > 50 {
+			t.Errorf("Old centroid %d indexed as data", r.ID)
+		}
+	}
+}
+
+func TestVecLite_SearchParamsApplyWithoutReindex(t *testing.T) {
+	config, cleanup := createParamsDB(t, "hnsw")
+	defer cleanup()
+
+	config.EfSearch = 80
+	config.EntryPoints = 4
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	if stats := db.Stats(); stats.ParamsMismatch != "" {
+		t.Errorf("EfSearch change should not need a reindex, got %q", stats.ParamsMismatch)
+	}
+	if ef := indexParams(t, db)["EfSearch"]; ef != 80 {
+		t.Errorf("Expected EfSearch 80 from config, got %d", ef)
+	}
+	if n := indexParams(t, db)["EntryPoints"]; n != 4 {
+		t.Errorf("Expected EntryPoints 4 from config, got %d", n)
+	}
+}
+
+func TestVecLite_Reindex_Flat(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 5; i++ {
+		if err := db.Insert(i, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if db.Size() != 5 {
+		t.Errorf("Expected size 5 after Reindex, got %d", db.Size())
+	}
+}
+
+func TestRebuildIndex_IVF(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/rebuild.db"
+	config.Dimension = 4
+	config.IndexType = "ivf"
+	config.NClusters = 4
+	config.NProbe = 4
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for i := 1; i <= 50; i++ {
+		if err := db.Insert(uint64(i), []float32{float32(i), float32(i % 5), 0, 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := os.Remove(config.DataPath + ".ivf"); err != nil {
+		t.Fatalf("Failed to remove IVF sidecar: %v", err)
+	}
+
+	stages := map[string]bool{}
+	if err := RebuildIndex(config, func(stage string, done, total int) { stages[stage] = true }); err != nil {
+		t.Fatalf("RebuildIndex failed: %v", err)
+	}
+	if !stages["footer"] || !stages["index"] || !stages["save"] {
+		t.Errorf("Expected progress for every stage, got %v", stages)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Opening rebuilt database failed: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 50 {
+		t.Errorf("Expected 50 vectors after rebuild, got %d", db.Size())
+	}
+	results, err := db.Search([]float32{10, 0, 0, 1}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 10 {
+		t.Errorf("Expected ID 10, got %v (err %v)", results, err)
+	}
+}
+
+func TestVecLite_Reindex_IVFCentroids(t *testing.T) {
+	config, cleanup := createParamsDB(t, "ivf")
+	defer cleanup()
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	// Centroids live in the IVF file: storage holds the data vectors only and
+	// every ID is free for them
+	if n := len(db.storage.IDs()); n != 50 {
+		t.Fatalf("Expected 50 records, got %d", n)
+	}
+	vec := make([]float32, 8)
+	vec[0] = 1000
+	if err := db.Insert(^uint64(0), vec); err != nil {
+		t.Fatalf("Insert under the highest ID failed: %v", err)
+	}
+
+	db.config.NClusters = 2
+	if err := db.Reindex(); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if n := len(db.storage.IDs()); n != 51 {
+		t.Errorf("Expected 51 records after Reindex, got %d", n)
+	}
+	query := make([]float32, 8)
+	query[0] = 25
+	if results, err := db.Search(query, 1); err != nil || len(results) != 1 || results[0].ID != 25 {
+		t.Errorf("Expected nearest ID 25 after Reindex, got %v (%v)", results, err)
+	}
+	if results, err := db.Search(vec, 1); err != nil || len(results) != 1 || results[0].ID != ^uint64(0) {
+		t.Errorf("Expected the highest ID after Reindex, got %v (%v)", results, err)
+	}
+}
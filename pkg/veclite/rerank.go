@@ -0,0 +1,198 @@
+package veclite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/monishSR/veclite/internal/profile"
+)
+
+// defaultRerankFactor is the over-fetch factor of searches with a Reranker
+const defaultRerankFactor = 4
+
+// defaultRerankTimeout bounds a call of an HTTPReranker without its own Client
+const defaultRerankTimeout = 10 * time.Second
+
+// Reranker reorders the candidates of a search, nearest first by distance, for
+// two-stage retrieval (e.g. with a cross-encoder); the first k candidates it
+// returns are the search results, so it may also drop candidates
+// Candidates carry the fields selected by SearchOptions, so include the
+// metadata a reranker reads. It runs without holding the database lock
+type Reranker func(query []float32, candidates []SearchResult) []SearchResult
+
+// rerankCandidates returns how many candidates to fetch for the Reranker
+func (opts SearchOptions) rerankCandidates(k int) int {
+	if opts.RerankCandidates > 0 {
+		return max(opts.RerankCandidates, k)
+	}
+	return defaultRerankFactor * k
+}
+
+// rerank applies reranker to candidates and truncates the result to k
+func (v *VecLite) rerank(query []float32, k int, reranker Reranker, candidates []SearchResult, p *profile.Profile) (results []SearchResult, err error) {
+	defer p.Start("rerank")()
+	defer v.recoverPanic("rerank", &err)
+	results = reranker(query, candidates)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// HTTPReranker calls a rerank service over HTTP, in the request and response
+// format shared by the Cohere, Jina and Voyage rerank APIs: it posts
+// {"model", "query", "documents"} and reads scored document indexes from
+// "results", "data" or a top-level array (Text Embeddings Inference)
+type HTTPReranker struct {
+	URL     string       // Rerank endpoint, e.g. "http://localhost:8080/rerank"
+	Model   string       // Sent as "model" if set
+	Texts   bool         // Send the documents as "texts", as Text Embeddings Inference expects
+	Header  http.Header  // Extra request headers, e.g. Authorization
+	TextKey string       // Metadata key holding the text of each candidate (default: "text")
+	Client  *http.Client // HTTP client (default: one with a 10s timeout)
+}
+
+// rerankRequest is the body posted by HTTPReranker
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents,omitempty"`
+	Texts     []string `json:"texts,omitempty"`
+}
+
+// rerankScore is one scored document of a rerank response
+type rerankScore struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+// Rerank orders candidates by the relevance the service assigns their text
+// for queryText, most relevant first; candidates the service doesn't score
+// (e.g. beyond its top_n) are dropped
+func (r *HTTPReranker) Rerank(ctx context.Context, queryText string, candidates []SearchResult) ([]SearchResult, error) {
+	if r.URL == "" {
+		return nil, errors.New("rerank URL is required")
+	}
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+	textKey := r.TextKey
+	if textKey == "" {
+		textKey = "text"
+	}
+	documents := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		text, ok := candidate.Metadata[textKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("candidate %d has no %q metadata text; include it in the search options", candidate.ID, textKey)
+		}
+		documents[i] = text
+	}
+
+	request := rerankRequest{Model: r.Model, Query: queryText, Documents: documents}
+	if r.Texts {
+		request.Documents, request.Texts = nil, documents
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rerank request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rerank request: %w", err)
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultRerankTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call rerank service: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rerank response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rerank service returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	scores, err := parseRerankScores(data)
+	if err != nil {
+		return nil, err
+	}
+	type scored struct {
+		index int
+		score float64
+	}
+	ranked := make([]scored, 0, len(scores))
+	seen := make(map[int]bool, len(scores))
+	for _, s := range scores {
+		score := s.RelevanceScore
+		if score == nil {
+			score = s.Score
+		}
+		if s.Index < 0 || s.Index >= len(candidates) || score == nil || seen[s.Index] {
+			return nil, fmt.Errorf("invalid rerank response entry for document %d", s.Index)
+		}
+		seen[s.Index] = true
+		ranked = append(ranked, scored{index: s.Index, score: *score})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	results := make([]SearchResult, len(ranked))
+	for i, s := range ranked {
+		results[i] = candidates[s.index]
+	}
+	return results, nil
+}
+
+// parseRerankScores reads the scored documents of a rerank response
+func parseRerankScores(data []byte) ([]rerankScore, error) {
+	var scores []rerankScore
+	if err := json.Unmarshal(data, &scores); err == nil {
+		return scores, nil
+	}
+	var wrapped struct {
+		Results []rerankScore `json:"results"`
+		Data    []rerankScore `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("failed to decode rerank response: %w", err)
+	}
+	if wrapped.Results != nil {
+		return wrapped.Results, nil
+	}
+	if wrapped.Data != nil {
+		return wrapped.Data, nil
+	}
+	return nil, errors.New("rerank response has no results")
+}
+
+// Reranker returns a Reranker for SearchOptions that reranks by relevance to
+// queryText; if the service fails, a warning is printed and the candidates
+// keep their distance order
+func (r *HTTPReranker) Reranker(ctx context.Context, queryText string) Reranker {
+	return func(query []float32, candidates []SearchResult) []SearchResult {
+		results, err := r.Rerank(ctx, queryText, candidates)
+		if err != nil {
+			fmt.Printf("Warning: rerank failed, keeping distance order: %v\n", err)
+			return candidates
+		}
+		return results
+	}
+}
@@ -0,0 +1,115 @@
+package veclite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newRerankTestDB creates a flat database with vectors 1..20 whose "text"
+// metadata is "doc <id>"
+func newRerankTestDB(t *testing.T) *VecLite {
+	t.Helper()
+	db, cleanup := createTestDB(t, "flat", smallVectors)
+	t.Cleanup(cleanup)
+	insertSmallVectors(t, db, 20, func(i int) Metadata { return Metadata{"text": fmt.Sprintf("doc %d", i)} })
+	return db
+}
+
+func TestSearchOptions_Reranker(t *testing.T) {
+	db := newRerankTestDB(t)
+	query := make([]float32, 4)
+
+	var seen int
+	reverse := func(query []float32, candidates []SearchResult) []SearchResult {
+		seen = len(candidates)
+		reversed := make([]SearchResult, len(candidates))
+		for i, c := range candidates {
+			reversed[len(candidates)-1-i] = c
+		}
+		return reversed
+	}
+	results, err := db.SearchWithOptions(query, 3, SearchOptions{Reranker: reverse})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if seen != 12 || len(results) != 3 || results[0].ID != 12 {
+		t.Errorf("Expected 12 candidates reranked to 12, 11, 10, got %d candidates and %+v", seen, results)
+	}
+
+	if _, err := db.SearchWithOptions(query, 3, SearchOptions{Reranker: reverse, RerankCandidates: 5}); err != nil || seen != 5 {
+		t.Errorf("Expected 5 candidates, got %d (%v)", seen, err)
+	}
+
+	explain, err := db.Explain(query, 3, SearchOptions{Reranker: reverse})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if explain.Stages[len(explain.Stages)-1].Name != "rerank" {
+		t.Errorf("Expected a rerank stage last, got %+v", explain.Stages)
+	}
+
+	panics := func(query []float32, candidates []SearchResult) []SearchResult { panic("boom") }
+	var panicErr *PanicError
+	if _, err := db.SearchWithOptions(query, 3, SearchOptions{Reranker: panics}); !errors.As(err, &panicErr) || panicErr.Op != "rerank" {
+		t.Errorf("Expected a rerank PanicError, got %v", err)
+	}
+}
+
+func TestHTTPReranker(t *testing.T) {
+	db := newRerankTestDB(t)
+	query := make([]float32, 4)
+
+	var request rerankRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		request = rerankRequest{}
+		json.NewDecoder(r.Body).Decode(&request)
+		documents := append(request.Documents, request.Texts...)
+		// Scores prefer later documents: the reverse of the distance order
+		scores := make([]map[string]any, len(documents))
+		for i := range documents {
+			scores[i] = map[string]any{"index": i, "relevance_score": float64(i)}
+		}
+		if request.Texts != nil {
+			json.NewEncoder(w).Encode(scores)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"results": scores})
+	}))
+	defer server.Close()
+
+	reranker := &HTTPReranker{URL: server.URL, Model: "rerank-test", Header: http.Header{"Authorization": {"Bearer key"}}}
+	opts := SearchOptions{IncludeMetadata: true, Reranker: reranker.Reranker(context.Background(), "question")}
+	results, err := db.SearchWithOptions(query, 2, opts)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != 8 || results[1].ID != 7 {
+		t.Errorf("Expected results 8, 7 from the service, got %+v", results)
+	}
+	if request.Query != "question" || request.Model != "rerank-test" || len(request.Documents) != 8 || request.Documents[0] != "doc 1" {
+		t.Errorf("Unexpected rerank request: %+v", request)
+	}
+
+	reranker.Texts = true
+	if results, _ := db.SearchWithOptions(query, 2, opts); len(results) != 2 || results[0].ID != 8 || len(request.Texts) != 8 {
+		t.Errorf("Expected the Text Embeddings Inference format to work, got %+v", results)
+	}
+
+	// Failures keep the distance order
+	reranker.Header = nil
+	if results, _ := db.SearchWithOptions(query, 2, opts); len(results) != 2 || results[0].ID != 1 {
+		t.Errorf("Expected distance order after a failed rerank, got %+v", results)
+	}
+	if _, err := reranker.Rerank(context.Background(), "question", results); err == nil {
+		t.Error("Expected Rerank to report the failure")
+	}
+}
@@ -0,0 +1,152 @@
+package veclite
+
+import (
+	"sync"
+	"time"
+)
+
+// RewriteStatus reports a maintenance task that rewrites the database (Compact,
+// Reindex, Retrain or RetrainIndex)
+// Reindex and Retrain hold the write lock: the old generation keeps the
+// vectors it had when the task started, since no write can run until the task
+// ends. Compact copies the data file without it, so writes go on meanwhile
+// The new generation is being written
+type RewriteStatus struct {
+	Task    string    // "compact", "reindex" or "retrain"
+	Started time.Time // When the task started
+	Size    int       // Vectors in the old generation (what Size reports meanwhile)
+	Written int       // Vectors written to the new generation so far
+	Total   int       // Vectors the new generation will hold (0 until known)
+}
+
+// rewriteState tracks the running rewrite so Size and Stats can answer from
+// the snapshot taken when it started instead of waiting for the write lock
+type rewriteState struct {
+	mu      sync.Mutex
+	status  *RewriteStatus // nil = no rewrite running
+	stats   Stats          // Snapshot taken when the rewrite started
+	live    bool           // The rewrite doesn't hold the write lock (no snapshot)
+	started chan struct{}  // Closed by the next begin (nil = nobody waiting)
+}
+
+// begin records the start of a rewrite with the stats of the old generation
+// Called with the write lock held
+func (r *rewriteState) begin(task string, stats Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = &RewriteStatus{Task: task, Started: time.Now(), Size: stats.Size}
+	r.stats, r.live = stats, false
+	if r.started != nil {
+		close(r.started)
+		r.started = nil
+	}
+}
+
+// beginLive records the start of a rewrite that runs without the write lock
+// and returns the function ending it, unless a locked rewrite took over
+// Readers keep taking the read lock and find it in Stats.Rewrite
+func (r *rewriteState) beginLive(task string, size int) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := &RewriteStatus{Task: task, Started: time.Now(), Size: size}
+	r.status, r.stats, r.live = status, Stats{}, true
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.status == status {
+			r.status, r.live = nil, false
+		}
+	}
+}
+
+// current returns the rewrite running without the write lock (nil = none)
+func (r *rewriteState) current() *RewriteStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil || !r.live {
+		return nil
+	}
+	status := *r.status
+	return &status
+}
+
+// wait returns a channel closed by the next begin
+func (r *rewriteState) wait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started == nil {
+		r.started = make(chan struct{})
+	}
+	return r.started
+}
+
+// progress records how much of the new generation is written
+// No-op without a running rewrite (e.g. the compaction of Close)
+func (r *rewriteState) progress(written, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != nil {
+		r.status.Written, r.status.Total = written, total
+	}
+}
+
+// end clears the running rewrite
+func (r *rewriteState) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = nil
+	r.stats, r.live = Stats{}, false
+}
+
+// snapshot returns the stats of the old generation with the progress of the
+// running rewrite, or false if none holds the write lock
+func (r *rewriteState) snapshot() (Stats, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == nil || r.live {
+		return Stats{}, false
+	}
+	stats := r.stats
+	status := *r.status
+	stats.Rewrite = &status
+	return stats, true
+}
+
+// beginRewrite snapshots the stats before a rewrite and returns the function
+// ending it
+// Note: Assumes write lock is already held
+func (v *VecLite) beginRewrite(task string) func() {
+	v.rewrite.begin(task, v.statsLocked())
+	return v.rewrite.end
+}
+
+// rLockOrRewrite takes the read lock, unless a rewrite holds the write lock:
+// then it returns the snapshot of the old generation instead (locked = false)
+func (v *VecLite) rLockOrRewrite() (stats Stats, locked bool) {
+	for {
+		started := v.rewrite.wait()
+		if stats, ok := v.rewrite.snapshot(); ok {
+			return stats, false
+		}
+		if v.mu.TryRLock() {
+			return Stats{}, true
+		}
+
+		// Wait for the lock unless a rewrite takes it first, including one
+		// queued for the write lock ahead of this reader
+		acquired := make(chan struct{})
+		go func() {
+			v.mu.RLock()
+			close(acquired)
+		}()
+		select {
+		case <-acquired:
+			return Stats{}, true
+		case <-started:
+			go func() {
+				<-acquired
+				v.mu.RUnlock()
+			}()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package veclite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVecLite_StatsDuringCompaction(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/rewrite.db"
+	config.Dimension = 8
+	config.BackgroundThrottle = &ThrottleConfig{BytesPerSecond: 512 << 10}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	for i := uint64(1); i <= 3000; i++ {
+		if err := db.Insert(i, []float32{float32(i), 1, 0, 0, 0, 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := uint64(1); i <= 500; i++ {
+		if err := db.Delete(i); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	cold := make([]uint64, 0, 100)
+	for i := uint64(2901); i <= 3000; i++ {
+		cold = append(cold, i)
+	}
+	if _, err := db.Demote(cold); err != nil {
+		t.Fatalf("Demote failed: %v", err)
+	}
+	if size, stats := db.Size(), db.Stats(); size != 2500 || stats.Size != size || stats.Rewrite != nil {
+		t.Fatalf("Expected Size and Stats to agree on 2500 vectors across tiers, got %d and %+v", size, stats)
+	}
+
+	// The throttled compaction takes a while; Stats reports it meanwhile
+	done := make(chan error, 1)
+	go func() { done <- db.Compact() }()
+	var during Stats
+	for deadline := time.Now().Add(5 * time.Second); during.Rewrite == nil; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected Stats to report the running compaction")
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("Compaction finished before it was observed: %v", err)
+		default:
+		}
+		during = db.Stats()
+	}
+	start := time.Now()
+	size := db.Size()
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("Expected Size not to wait for the compaction, took %v", waited)
+	}
+	rewrite := during.Rewrite
+	if size != 2500 || during.Size != 2500 || rewrite.Task != "compact" || rewrite.Size != 2500 || rewrite.Started.IsZero() {
+		t.Errorf("Expected the old generation of 2500 vectors during compaction, got Size %d and %+v / %+v", size, during, rewrite)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if stats := db.Stats(); stats.Rewrite != nil || stats.Size != 2500 {
+		t.Errorf("Expected no rewrite and 2500 vectors after compaction, got %+v", stats)
+	}
+}
+
+func TestRewriteState_Progress(t *testing.T) {
+	var r rewriteState
+	r.progress(10, 20) // No rewrite running: ignored
+	if _, ok := r.snapshot(); ok {
+		t.Fatalf("Expected no snapshot without a rewrite")
+	}
+	started := r.wait()
+	r.begin("reindex", Stats{Size: 20, IndexType: "ivf"})
+	select {
+	case <-started:
+	default:
+		t.Errorf("Expected begin to wake waiting readers")
+	}
+	r.progress(5, 20)
+	stats, ok := r.snapshot()
+	if !ok || stats.IndexType != "ivf" || stats.Rewrite.Written != 5 || stats.Rewrite.Total != 20 || stats.Rewrite.Size != 20 {
+		t.Errorf("Unexpected snapshot: %+v / %+v", stats, stats.Rewrite)
+	}
+	r.end()
+	if _, ok := r.snapshot(); ok {
+		t.Errorf("Expected end to clear the snapshot")
+	}
+}
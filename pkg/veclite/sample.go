@@ -0,0 +1,107 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/monishSR/veclite/pkg/vecmath"
+)
+
+// SampleOptions configures SampleMatrix
+type SampleOptions struct {
+	Seed int64 // Seed of the random choice; the same seed picks the same vectors from the same data (0 = random)
+}
+
+// TrainingSample is a uniform random sample of stored vectors, e.g. to train
+// PQ codebooks, IVF centroids or a PCA projection outside the database
+type TrainingSample struct {
+	IDs     []uint64       // Sampled IDs in ascending order
+	Vectors vecmath.Matrix // Row i is the vector of IDs[i]
+	Total   int            // Vectors the sample was drawn from
+}
+
+// Sample returns a uniform random sample of up to n stored vectors with their
+// IDs, e.g. for drift detection, retraining or evaluation; the IDs are drawn
+// from the offset index and only the sampled vectors are read from disk
+// IDs that cannot be read are left out; see SampleMatrix for a reproducible
+// sample in one matrix that reports read errors
+// Uses read lock - allows concurrent reads
+func (v *VecLite) Sample(n int) ([]uint64, [][]float32) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ids, _ := v.reservoirSample(n, time.Now().UnixNano())
+	sampled := ids[:0]
+	vectors := make([][]float32, 0, len(ids))
+	for _, id := range ids {
+		vector, err := v.index.ReadVector(id)
+		if err != nil {
+			continue
+		}
+		sampled = append(sampled, id)
+		vectors = append(vectors, vector)
+	}
+	return sampled, vectors
+}
+
+// SampleMatrix draws n vectors uniformly at random and returns them as one
+// matrix, e.g. to train PQ codebooks, IVF centroids or a PCA projection; n
+// bounds the memory used however large the database is
+// Samples the hot tier: demoted vectors are quantized and left out
+// Uses read lock - allows concurrent reads
+func (v *VecLite) SampleMatrix(n int, opts SampleOptions) (*TrainingSample, error) {
+	if n <= 0 {
+		return nil, errors.New("sample size must be greater than 0")
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ids, total := v.reservoirSample(n, seed)
+	sample := &TrainingSample{
+		IDs:     ids,
+		Vectors: vecmath.Matrix{Data: make([]float32, 0, len(ids)*v.config.Dimension), Dim: v.config.Dimension},
+		Total:   total,
+	}
+	for _, id := range ids {
+		vector, err := v.index.ReadVector(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vector %d: %w", id, err)
+		}
+		if len(vector) != v.config.Dimension {
+			return nil, errors.New("vector dimension mismatch")
+		}
+		sample.Vectors.Data = append(sample.Vectors.Data, vector...)
+	}
+	return sample, nil
+}
+
+// reservoirSample picks up to n data IDs uniformly at random (reservoir
+// sampling over the offset index) and returns them in ascending order with the
+// total number of data IDs
+// Note: Assumes lock is already held
+func (v *VecLite) reservoirSample(n int, seed int64) ([]uint64, int) {
+	rng := rand.New(rand.NewSource(seed))
+	ids, total := v.sampleIDs(0)
+	reservoir := make([]uint64, 0, min(n, total))
+	for i, id := range ids {
+		if i < n {
+			reservoir = append(reservoir, id)
+		} else if j := rng.Int63n(int64(i) + 1); j < int64(n) {
+			reservoir[j] = id
+		}
+	}
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i] < reservoir[j] })
+	return reservoir, total
+}
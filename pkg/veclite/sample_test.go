@@ -0,0 +1,89 @@
+package veclite
+
+import (
+	"testing"
+)
+
+func TestVecLite_SampleMatrix(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if _, err := db.SampleMatrix(0, SampleOptions{}); err == nil {
+		t.Errorf("Expected an error for an empty sample size")
+	}
+	sample, err := db.SampleMatrix(10, SampleOptions{})
+	if err != nil || len(sample.IDs) != 0 || sample.Vectors.Rows() != 0 || sample.Total != 0 {
+		t.Fatalf("Expected an empty sample of an empty database, got %+v, %v", sample, err)
+	}
+
+	for i := uint64(1); i <= 500; i++ {
+		if err := db.Insert(i, testVector(int(i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	sample, err = db.SampleMatrix(50, SampleOptions{Seed: 7})
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	if len(sample.IDs) != 50 || sample.Vectors.Rows() != 50 || sample.Vectors.Dim != 128 || sample.Total != 500 {
+		t.Fatalf("Unexpected sample shape: %d IDs, %d rows of %d, total %d", len(sample.IDs), sample.Vectors.Rows(), sample.Vectors.Dim, sample.Total)
+	}
+	for i, id := range sample.IDs {
+		if i > 0 && id <= sample.IDs[i-1] {
+			t.Fatalf("Expected ascending unique IDs, got %v", sample.IDs)
+		}
+		want := testVector(int(id))
+		if row := sample.Vectors.Row(i); row[0] != want[0] || row[127] != want[127] {
+			t.Fatalf("Row %d does not hold the vector of ID %d", i, id)
+		}
+	}
+	if sample.IDs[len(sample.IDs)-1] <= 50 {
+		t.Errorf("Expected the sample to reach past the first 50 IDs, got %v", sample.IDs)
+	}
+
+	again, err := db.SampleMatrix(50, SampleOptions{Seed: 7})
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	for i := range sample.IDs {
+		if again.IDs[i] != sample.IDs[i] {
+			t.Fatalf("Expected the same seed to pick the same vectors")
+		}
+	}
+
+	all, err := db.SampleMatrix(1000, SampleOptions{})
+	if err != nil || len(all.IDs) != 500 {
+		t.Errorf("Expected every vector when n exceeds the size, got %d, %v", len(all.IDs), err)
+	}
+}
+
+func TestVecLite_Sample(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	if ids, vectors := db.Sample(5); len(ids) != 0 || len(vectors) != 0 {
+		t.Errorf("Expected no sample from an empty database, got %v", ids)
+	}
+	for i := uint64(1); i <= 100; i++ {
+		if err := db.Insert(i, testVector(int(i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	ids, vectors := db.Sample(20)
+	if len(ids) != 20 || len(vectors) != 20 {
+		t.Fatalf("Expected 20 sampled vectors, got %d IDs and %d vectors", len(ids), len(vectors))
+	}
+	for i, id := range ids {
+		if vectors[i][0] != testVector(int(id))[0] {
+			t.Errorf("Vector %d does not belong to ID %d", i, id)
+		}
+	}
+	if ids, _ := db.Sample(0); ids != nil {
+		t.Errorf("Expected no sample for n = 0, got %v", ids)
+	}
+	if ids, _ := db.Sample(500); len(ids) != 100 {
+		t.Errorf("Expected all 100 vectors, got %d", len(ids))
+	}
+}
@@ -0,0 +1,268 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/monishSR/veclite/internal/index/ivf"
+)
+
+// cursorPrefix versions the scroll cursor format
+const cursorPrefix = "v1:"
+
+// ScrollItem is one vector returned by a Scroll
+type ScrollItem struct {
+	ID       uint64
+	Vector   []float32
+	Metadata Metadata
+}
+
+// Scroll iterates over all vectors in ascending ID order as of the moment it was
+// created: vectors inserted, updated or deleted afterwards don't affect it
+// Writes that touch a vector the scroll has not returned yet keep the old value
+// aside for the scroll, so Close scrolls that are no longer needed
+// A Scroll is safe for concurrent use with writes to the database
+type Scroll struct {
+	db        *VecLite
+	batchSize int
+
+	mu        sync.Mutex
+	ids       []uint64              // Snapshot IDs, ascending
+	pos       int                   // Index of the next ID to return
+	preserved map[uint64]ScrollItem // Snapshot values of IDs changed since the snapshot
+	closed    bool
+}
+
+// scrollRegistry tracks open scrolls so writes can preserve snapshot values
+type scrollRegistry struct {
+	mu      sync.Mutex
+	scrolls map[*Scroll]struct{}
+}
+
+// Scroll starts a point-in-time scan over all vectors, batchSize vectors at a time
+func (v *VecLite) Scroll(batchSize int) (*Scroll, error) {
+	return v.ScrollFrom(batchSize, "")
+}
+
+// ScrollFrom resumes a scan after the position encoded in cursor (see Scroll.Cursor)
+// The resumed scan is a new snapshot starting at the next ID after the cursor,
+// so a migration job can persist the cursor and continue after a restart
+func (v *VecLite) ScrollFrom(batchSize int, cursor string) (*Scroll, error) {
+	if batchSize <= 0 {
+		return nil, errors.New("batch size must be greater than 0")
+	}
+	after, resume, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ids, _ := v.sampleIDs(0)
+	if resume {
+		ids = ids[sort.Search(len(ids), func(i int) bool { return ids[i] > after }):]
+	}
+
+	s := &Scroll{
+		db:        v,
+		batchSize: batchSize,
+		ids:       ids,
+		preserved: make(map[uint64]ScrollItem),
+	}
+	v.scrolls.mu.Lock()
+	if v.scrolls.scrolls == nil {
+		v.scrolls.scrolls = make(map[*Scroll]struct{})
+	}
+	v.scrolls.scrolls[s] = struct{}{}
+	v.scrolls.mu.Unlock()
+	return s, nil
+}
+
+// keepDataID filters out IDs storage holds for the index itself: the centroid
+// records of an IVF file of version 2 or earlier until the next save drops
+// them (nil if there are none)
+func (v *VecLite) keepDataID() func(id uint64) bool {
+	idx, ok := v.index.(*ivf.IVFIndex)
+	if !ok || len(idx.LegacyCentroidIDs()) == 0 {
+		return nil
+	}
+	legacy := make(map[uint64]bool)
+	for _, id := range idx.LegacyCentroidIDs() {
+		legacy[id] = true
+	}
+	return func(id uint64) bool { return !legacy[id] }
+}
+
+// Next returns the next batch, or io.EOF once every vector has been returned
+func (s *Scroll) Next() ([]ScrollItem, error) {
+	s.db.mu.RLock() // Writers are excluded, so unpreserved IDs still hold snapshot values
+	defer s.db.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errors.New("scroll is closed")
+	}
+	if s.pos >= len(s.ids) {
+		return nil, io.EOF
+	}
+
+	end := min(s.pos+s.batchSize, len(s.ids))
+	batch := make([]ScrollItem, 0, end-s.pos)
+	for _, id := range s.ids[s.pos:end] {
+		if item, exists := s.preserved[id]; exists {
+			batch = append(batch, item)
+			delete(s.preserved, id)
+			continue
+		}
+		item, err := s.db.snapshotItem(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vector %d for scroll: %w", id, err)
+		}
+		batch = append(batch, item)
+	}
+	s.pos = end
+	return batch, nil
+}
+
+// Cursor returns a token for resuming after the last returned batch with ScrollFrom
+func (s *Scroll) Cursor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pos == 0 {
+		return ""
+	}
+	return cursorPrefix + strconv.FormatUint(s.ids[s.pos-1], 10)
+}
+
+// Close releases the snapshot; writes stop preserving values for it
+func (s *Scroll) Close() {
+	s.db.scrolls.mu.Lock()
+	delete(s.db.scrolls.scrolls, s)
+	s.db.scrolls.mu.Unlock()
+
+	s.mu.Lock()
+	s.closed = true
+	s.preserved = nil
+	s.mu.Unlock()
+}
+
+// scanRangeBatch is how many vectors ScanRange reads per read lock
+const scanRangeBatch = 256
+
+// ScanRange calls fn for every vector with an ID from fromID to toID (inclusive)
+// in ascending ID order, e.g. for partial exports when IDs encode time or tenant
+// An error returned by fn stops the scan and is returned
+// The IDs are looked up in a sorted view of the storage index, and vectors are
+// read in batches under the read lock, which is not held while fn runs, so fn
+// may write to the database. Unlike Scroll the scan is not a snapshot: vectors
+// deleted before their batch is read are skipped. As with Scroll, vectors
+// demoted to the cold tier are not included
+func (v *VecLite) ScanRange(fromID, toID uint64, fn func(id uint64, vector []float32) error) error {
+	v.mu.RLock()
+	ids := v.storage.IDRange(fromID, toID)
+	if keep := v.keepDataID(); keep != nil {
+		kept := ids[:0]
+		for _, id := range ids {
+			if keep(id) {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+	}
+	v.mu.RUnlock()
+
+	for start := 0; start < len(ids); start += scanRangeBatch {
+		batch, err := v.readRange(ids[start:min(start+scanRangeBatch, len(ids))])
+		if err != nil {
+			return err
+		}
+		for _, item := range batch {
+			if err := fn(item.ID, item.Vector); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readRange reads the vectors of ids that still exist
+func (v *VecLite) readRange(ids []uint64) (items []ScrollItem, err error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	defer v.recoverPanic("scan_range", &err)
+
+	items = make([]ScrollItem, 0, len(ids))
+	for _, id := range ids {
+		vector, err := v.index.ReadVector(id)
+		if errors.Is(err, ErrNotFound) {
+			continue // Deleted since the IDs were looked up
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vector %d for range scan: %w", id, err)
+		}
+		// Copy: storage may hand out its cached slice
+		items = append(items, ScrollItem{ID: id, Vector: append([]float32(nil), vector...)})
+	}
+	return items, nil
+}
+
+// parseCursor decodes a cursor from Scroll.Cursor ("" = start from the beginning)
+func parseCursor(cursor string) (after uint64, resume bool, err error) {
+	if cursor == "" {
+		return 0, false, nil
+	}
+	if !strings.HasPrefix(cursor, cursorPrefix) {
+		return 0, false, fmt.Errorf("invalid scroll cursor %q", cursor)
+	}
+	after, err = strconv.ParseUint(strings.TrimPrefix(cursor, cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid scroll cursor %q: %w", cursor, err)
+	}
+	return after, true, nil
+}
+
+// snapshotItem reads the current vector and metadata of id
+// Note: Assumes lock is already held
+func (v *VecLite) snapshotItem(id uint64) (ScrollItem, error) {
+	vector, err := v.index.ReadVector(id)
+	if err != nil {
+		return ScrollItem{}, err
+	}
+	metadata, _ := v.metadata.Get(id, nil)
+	// Copy: storage may hand out its cached slice
+	return ScrollItem{ID: id, Vector: append([]float32(nil), vector...), Metadata: metadata}, nil
+}
+
+// preserveForScrolls keeps the current value of id aside for open scrolls that
+// have yet to return it; called before id is overwritten or deleted
+// Note: Assumes write lock is already held
+func (v *VecLite) preserveForScrolls(id uint64) {
+	v.scrolls.mu.Lock()
+	defer v.scrolls.mu.Unlock()
+
+	var item *ScrollItem
+	for s := range v.scrolls.scrolls {
+		s.mu.Lock()
+		i := sort.Search(len(s.ids), func(i int) bool { return s.ids[i] >= id })
+		pending := i >= s.pos && i < len(s.ids) && s.ids[i] == id
+		if _, done := s.preserved[id]; pending && !done {
+			if item == nil {
+				current, err := v.snapshotItem(id)
+				if err != nil {
+					s.mu.Unlock()
+					return // Not readable, nothing to preserve
+				}
+				item = &current
+			}
+			s.preserved[id] = *item
+		}
+		s.mu.Unlock()
+	}
+}
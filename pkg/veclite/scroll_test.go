@@ -0,0 +1,187 @@
+package veclite
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// drainScroll collects every remaining item of s
+func drainScroll(t *testing.T, s *Scroll) []ScrollItem {
+	t.Helper()
+	var items []ScrollItem
+	for {
+		batch, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			return items
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		items = append(items, batch...)
+	}
+}
+
+func TestVecLite_Scroll(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	defer os.Remove(db.config.DataPath + ".meta")
+
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.InsertWithMetadata(i, vec, Metadata{"n": int(i)}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	s, err := db.Scroll(3)
+	if err != nil {
+		t.Fatalf("Scroll failed: %v", err)
+	}
+	defer s.Close()
+
+	first, err := s.Next()
+	if err != nil || len(first) != 3 || first[0].ID != 1 || first[2].ID != 3 {
+		t.Fatalf("Unexpected first batch %v (err %v)", first, err)
+	}
+
+	// Writes after the snapshot must not show up in the scroll
+	updated := make([]float32, 128)
+	updated[0] = 100
+	if err := db.UpsertWithMetadata(5, updated, Metadata{"n": 100}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := db.Delete(7); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Insert(11, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	rest := drainScroll(t, s)
+	if len(rest) != 7 {
+		t.Fatalf("Expected 7 remaining items, got %d", len(rest))
+	}
+	for i, item := range rest {
+		want := uint64(i + 4)
+		if item.ID != want || item.Vector[0] != float32(want) || item.Metadata["n"] != int(want) {
+			t.Errorf("Item %d: expected snapshot value of %d, got ID %d vec[0]=%v metadata %v",
+				i, want, item.ID, item.Vector[0], item.Metadata)
+		}
+	}
+}
+
+func TestVecLite_ScrollFrom_Cursor(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 5; i++ {
+		if err := db.Insert(i, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	s, err := db.Scroll(2)
+	if err != nil {
+		t.Fatalf("Scroll failed: %v", err)
+	}
+	if s.Cursor() != "" {
+		t.Errorf("Expected empty cursor before the first batch, got %q", s.Cursor())
+	}
+	if _, err := s.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	cursor := s.Cursor()
+	s.Close()
+	if _, err := s.Next(); err == nil {
+		t.Error("Expected error from Next on closed scroll")
+	}
+
+	resumed, err := db.ScrollFrom(2, cursor)
+	if err != nil {
+		t.Fatalf("ScrollFrom failed: %v", err)
+	}
+	defer resumed.Close()
+	items := drainScroll(t, resumed)
+	if len(items) != 3 || items[0].ID != 3 || items[2].ID != 5 {
+		t.Errorf("Expected IDs 3..5 after resume, got %v", items)
+	}
+
+	if _, err := db.ScrollFrom(2, "garbage"); err == nil {
+		t.Error("Expected error for invalid cursor")
+	}
+	if _, err := db.Scroll(0); err == nil {
+		t.Error("Expected error for zero batch size")
+	}
+}
+
+func TestVecLite_Scroll_CloseUnregisters(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	s, err := db.Scroll(10)
+	if err != nil {
+		t.Fatalf("Scroll failed: %v", err)
+	}
+	if len(db.scrolls.scrolls) != 1 {
+		t.Fatalf("Expected 1 open scroll, got %d", len(db.scrolls.scrolls))
+	}
+	s.Close()
+	if len(db.scrolls.scrolls) != 0 {
+		t.Errorf("Expected no open scrolls after Close, got %d", len(db.scrolls.scrolls))
+	}
+	// Writes after Close no longer preserve values
+	if err := db.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if s.preserved != nil {
+		t.Error("Closed scroll still preserving values")
+	}
+}
+
+func TestVecLite_ScanRange(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		vec[0] = float32(i)
+		if err := db.Insert(i*10, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	if err := db.Delete(40); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	var ids []uint64
+	err := db.ScanRange(25, 60, func(id uint64, vector []float32) error {
+		if vector[0] != float32(id/10) {
+			t.Errorf("Vector %d: got %v", id, vector[0])
+		}
+		ids = append(ids, id)
+		return db.Insert(id+1000, vector) // fn may write to the database
+	})
+	if err != nil {
+		t.Fatalf("ScanRange failed: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != 30 || ids[1] != 50 || ids[2] != 60 {
+		t.Errorf("Expected [30 50 60], got %v", ids)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = db.ScanRange(0, ^uint64(0), func(id uint64, vector []float32) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Expected the scan to stop after 1 call with fn's error, got %d calls, %v", calls, err)
+	}
+}
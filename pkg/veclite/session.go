@@ -0,0 +1,152 @@
+package veclite
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// defaultSessionCacheSize is the number of query results a session keeps by default
+const defaultSessionCacheSize = 32
+
+// SessionOptions configures a SearchSession
+type SessionOptions struct {
+	Search    SearchOptions // Options of every search of the session (Cursor and Cost are managed by the session)
+	CacheSize int           // Results of this many recent queries are reused until the next write (0 = 32, negative = no cache)
+	NoSeed    bool          // Start HNSW searches from the entry point instead of the previous top result
+}
+
+// SessionStats reports the searches of a session and the work it saved
+type SessionStats struct {
+	Searches  int // Searches run through the session
+	CacheHits int // Searches answered from the session's result cache
+	Seeded    int // Searches started from the previous top result (see SearchOptions.SeedID)
+}
+
+// SearchSession runs a series of related queries, e.g. the turns of a
+// conversational RAG session, reusing work across them: each search starts
+// from the previous top result (HNSW), repeated queries are answered from a
+// result cache until the next write, and More continues the last search
+// Searches of one session run one at a time; a SearchSession is safe for
+// concurrent use
+type SearchSession struct {
+	db   *VecLite
+	opts SessionOptions
+
+	mu     sync.Mutex
+	seed   uint64       // Top result of the last search (0 = none)
+	cursor SearchCursor // State of the last search, for More
+	cache  *lru.Cache[string, sessionResults]
+	key    []byte // Scratch buffer for cache keys
+	stats  SessionStats
+}
+
+// sessionResults are cached results of one query
+type sessionResults struct {
+	gen     uint64 // Write generation the results were computed at
+	results []SearchResult
+}
+
+// NewSession starts a search session with opts
+func (v *VecLite) NewSession(opts SessionOptions) *SearchSession {
+	opts.Search.Cursor, opts.Search.Cost = nil, nil
+	s := &SearchSession{db: v, opts: opts}
+	size := opts.CacheSize
+	if size == 0 {
+		size = defaultSessionCacheSize
+	}
+	if size > 0 {
+		s.cache, _ = lru.New[string, sessionResults](size)
+	}
+	return s
+}
+
+// Search finds the k nearest neighbors to query within the session
+// Bounded by Config.DefaultSearchTimeout if set
+func (s *SearchSession) Search(query []float32, k int) ([]SearchResult, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), s.db.config.DefaultSearchTimeout)
+	defer cancel()
+	return s.SearchContext(ctx, query, k)
+}
+
+// SearchContext is Search with a context, see VecLite.SearchContext
+func (s *SearchSession) SearchContext(ctx context.Context, query []float32, k int) ([]SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Searches++
+
+	// Read before searching: a write during the search makes the entry stale
+	gen := s.db.visible.generation()
+	key := s.cacheKey(query, k)
+	if s.cache != nil {
+		if cached, ok := s.cache.Get(key); ok && cached.gen == gen {
+			s.stats.CacheHits++
+			s.cursor.start(s.db, query, s.opts.Search, nil, cached.results)
+			s.seedFrom(cached.results)
+			return cloneResults(cached.results), nil
+		}
+	}
+
+	opts := s.opts.Search
+	opts.Cursor = &s.cursor
+	if opts.SeedID == 0 && !s.opts.NoSeed && s.seed != 0 {
+		opts.SeedID = s.seed
+		s.stats.Seeded++
+	}
+	results, err := s.db.SearchWithOptionsContext(ctx, query, k, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.seedFrom(results)
+	if s.cache != nil {
+		s.cache.Add(key, sessionResults{gen: gen, results: cloneResults(results)})
+	}
+	return results, nil
+}
+
+// More returns up to additionalK further results of the last search of the
+// session, see VecLite.More
+func (s *SearchSession) More(additionalK int) ([]SearchResult, error) {
+	return s.db.More(&s.cursor, additionalK)
+}
+
+// Stats returns the searches of the session so far
+func (s *SearchSession) Stats() SessionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// seedFrom remembers the top result as the seed of the next search
+// Note: Assumes session lock is already held
+func (s *SearchSession) seedFrom(results []SearchResult) {
+	if len(results) > 0 {
+		s.seed = results[0].ID
+	}
+}
+
+// cacheKey encodes k and query as a result cache key
+// Note: Assumes session lock is already held
+func (s *SearchSession) cacheKey(query []float32, k int) string {
+	s.key = binary.LittleEndian.AppendUint64(s.key[:0], uint64(k))
+	for _, x := range query {
+		s.key = binary.LittleEndian.AppendUint32(s.key, math.Float32bits(x))
+	}
+	return string(s.key)
+}
+
+// cloneResults copies results and their vectors, so cached results are not
+// modified through the slices handed out
+func cloneResults(results []SearchResult) []SearchResult {
+	clone := make([]SearchResult, len(results))
+	for i, result := range results {
+		clone[i] = result
+		if result.Vector != nil {
+			clone[i].Vector = append([]float32(nil), result.Vector...)
+		}
+	}
+	return clone
+}
@@ -0,0 +1,90 @@
+package veclite
+
+import "testing"
+
+func TestSearchSession(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	for i := 1; i <= 50; i++ {
+		if err := db.Insert(uint64(i), testVector(i)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	session := db.NewSession(SessionOptions{Search: DefaultSearchOptions()})
+	first, err := session.Search(testVector(20), 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if first[0].ID != 20 {
+		t.Fatalf("Expected ID 20 first, got %v", first)
+	}
+	first[0].Vector[0] = -1 // Must not reach the cache
+
+	// A related query starts from the previous top result
+	related, err := session.Search(testVector(21), 3)
+	if err != nil || related[0].ID != 21 {
+		t.Fatalf("Expected ID 21 first, got %v (%v)", related, err)
+	}
+
+	// Repeating a query is answered from the cache
+	again, err := session.Search(testVector(20), 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if again[0].ID != 20 || again[0].Vector[0] != testVector(20)[0] {
+		t.Errorf("Expected the cached results of the first query, got %v", again[0])
+	}
+	if stats := session.Stats(); stats.Searches != 3 || stats.CacheHits != 1 || stats.Seeded != 1 {
+		t.Errorf("Expected 3 searches, 1 cache hit and 1 seeded search, got %+v", stats)
+	}
+
+	// More continues the last search, cached or not
+	more, err := session.More(2)
+	if err != nil || len(more) != 2 {
+		t.Fatalf("More failed: %v (%v)", more, err)
+	}
+	for _, result := range more {
+		for _, returned := range again {
+			if result.ID == returned.ID {
+				t.Errorf("More returned ID %d again", result.ID)
+			}
+		}
+	}
+
+	// A write invalidates the cache
+	closer := testVector(20)
+	closer[1] += 0.0001
+	if err := db.Insert(100, closer); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	results, err := session.Search(testVector(20), 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if stats := session.Stats(); stats.CacheHits != 1 {
+		t.Errorf("Expected the write to invalidate the cache, got %+v", stats)
+	}
+	if results[1].ID != 100 {
+		t.Errorf("Expected the new vector 100 second, got %v", results)
+	}
+}
+
+func TestSearchSession_NoCache(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, testVector(1)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	session := db.NewSession(SessionOptions{CacheSize: -1, NoSeed: true})
+	for i := 0; i < 3; i++ {
+		if _, err := session.Search(testVector(1), 1); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	if stats := session.Stats(); stats.Searches != 3 || stats.CacheHits != 0 || stats.Seeded != 0 {
+		t.Errorf("Expected 3 uncached, unseeded searches, got %+v", stats)
+	}
+}
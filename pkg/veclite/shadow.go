@@ -0,0 +1,170 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShadowInFlight is the default ShadowConfig.MaxInFlight
+const defaultShadowInFlight = 4
+
+// ShadowConfig controls A/B shadow querying, see SetShadow
+type ShadowConfig struct {
+	SampleRate  int                    // Shadow 1 in SampleRate searches (0 = every search)
+	MaxInFlight int                    // Shadow searches running at once; searches beyond are not shadowed (0 = 4)
+	OnCompare   func(ShadowComparison) // Called after every shadow search (optional)
+}
+
+// ShadowComparison compares one search with its shadow search
+// Score deltas are only set if the search included scores
+type ShadowComparison struct {
+	ResultComparison        // Shadow results compared with the primary ones (zero if Err is set)
+	TraceID          string // SearchOptions.TraceID of the primary search
+	K                int
+	PrimaryLatency   time.Duration // Latency of the search that was answered
+	ShadowLatency    time.Duration
+	Err              error // Shadow search error (the primary results were returned regardless)
+}
+
+// ShadowStats aggregates shadow comparisons since the shadow was attached
+type ShadowStats struct {
+	Compared       uint64        // Shadow searches that completed
+	Errors         uint64        // Shadow searches that failed
+	Dropped        uint64        // Sampled searches not shadowed because MaxInFlight were running
+	Identical      uint64        // Shadow searches returning the primary IDs in the same order
+	MeanOverlap    float64       // Mean ShadowComparison.Overlap
+	MeanKendallTau float64       // Mean ShadowComparison.KendallTau
+	PrimaryLatency time.Duration // Mean latency of the compared primary searches
+	ShadowLatency  time.Duration // Mean latency of the shadow searches
+	LatencyDelta   time.Duration // ShadowLatency - PrimaryLatency
+}
+
+// shadowRunner runs shadow searches in the background and aggregates them
+type shadowRunner struct {
+	db       *VecLite
+	config   ShadowConfig
+	slots    chan struct{} // One token per running shadow search
+	searches atomic.Uint64 // Searches seen, for sampling
+	wg       sync.WaitGroup
+
+	mu         sync.Mutex // Guards the fields below
+	stats      ShadowStats
+	overlapSum float64
+	tauSum     float64
+	primarySum time.Duration
+	shadowSum  time.Duration
+}
+
+// SetShadow attaches a shadow database: sampled searches are run against it as
+// well, in the background, and its results are compared with the returned ones
+// in Stats().Shadow and ShadowConfig.OnCompare. A nil shadow detaches the
+// current one after its running searches finish
+// The shadow is typically a copy of this database rebuilt or retuned with other
+// parameters; it is not closed by this database, and writes are not applied
+// to it (mirror them with Config.AfterInsert and AfterDelete to keep it in sync)
+func (v *VecLite) SetShadow(shadow *VecLite, config ShadowConfig) error {
+	var runner *shadowRunner
+	if shadow != nil {
+		if shadow == v {
+			return errors.New("a database can't shadow itself")
+		}
+		if shadow.config.Dimension != v.config.Dimension {
+			return fmt.Errorf("shadow dimension %d does not match configured dimension %d", shadow.config.Dimension, v.config.Dimension)
+		}
+		if config.SampleRate <= 0 {
+			config.SampleRate = 1
+		}
+		if config.MaxInFlight <= 0 {
+			config.MaxInFlight = defaultShadowInFlight
+		}
+		runner = &shadowRunner{db: shadow, config: config, slots: make(chan struct{}, config.MaxInFlight)}
+	}
+	if old := v.shadow.Swap(runner); old != nil {
+		old.wg.Wait()
+	}
+	return nil
+}
+
+// shadowStats returns the aggregated comparisons (nil = no shadow attached)
+func (v *VecLite) shadowStats() *ShadowStats {
+	runner := v.shadow.Load()
+	if runner == nil {
+		return nil
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	stats := runner.stats
+	return &stats
+}
+
+// run shadows one search answered with results in primaryLatency, unless it
+// is not sampled or MaxInFlight shadow searches are running
+func (r *shadowRunner) run(query []float32, k int, opts SearchOptions, results []SearchResult, primaryLatency time.Duration) {
+	if (r.searches.Add(1)-1)%uint64(r.config.SampleRate) != 0 {
+		return
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		r.mu.Lock()
+		r.stats.Dropped++
+		r.mu.Unlock()
+		return
+	}
+
+	query = append([]float32(nil), query...)
+	primary := make([]SearchResult, len(results))
+	for i, result := range results {
+		primary[i] = SearchResult{ID: result.ID, Distance: result.Distance}
+	}
+	if opts.Reranker == nil { // A Reranker may read any projected field
+		opts = SearchOptions{IncludeScore: opts.IncludeScore, Filter: opts.Filter, TraceID: opts.TraceID}
+	}
+	opts.Cost = nil // The shadow search is not the caller's cost
+	opts.Cursor = nil
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.slots }()
+
+		start := time.Now()
+		shadowResults, err := r.db.SearchWithOptionsContext(context.Background(), query, k, opts)
+		comparison := ShadowComparison{TraceID: opts.TraceID, K: k, PrimaryLatency: primaryLatency, ShadowLatency: time.Since(start), Err: err}
+		if err == nil {
+			comparison.ResultComparison = CompareResults(primary, shadowResults)
+		}
+		r.record(comparison)
+		if r.config.OnCompare != nil {
+			r.config.OnCompare(comparison)
+		}
+	}()
+}
+
+// record adds one comparison to the aggregated stats
+func (r *shadowRunner) record(c ShadowComparison) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.Err != nil {
+		r.stats.Errors++
+		return
+	}
+	r.stats.Compared++
+	if c.Identical {
+		r.stats.Identical++
+	}
+	r.overlapSum += c.Overlap
+	r.tauSum += c.KendallTau
+	r.primarySum += c.PrimaryLatency
+	r.shadowSum += c.ShadowLatency
+
+	n := r.stats.Compared
+	r.stats.MeanOverlap = r.overlapSum / float64(n)
+	r.stats.MeanKendallTau = r.tauSum / float64(n)
+	r.stats.PrimaryLatency = r.primarySum / time.Duration(n)
+	r.stats.ShadowLatency = r.shadowSum / time.Duration(n)
+	r.stats.LatencyDelta = r.stats.ShadowLatency - r.stats.PrimaryLatency
+}
@@ -0,0 +1,115 @@
+package veclite
+
+import (
+	"sync"
+	"testing"
+)
+
+// newShadowTestDB creates a database of the given index type with vectors 1..50
+// of dimension 4
+func newShadowTestDB(t *testing.T, indexType string) *VecLite {
+	t.Helper()
+	db, _ := createTestDB(t, indexType, smallVectors, func(config *Config) {
+		config.NClusters = 5
+		config.NProbe = 1
+	})
+	for i := 1; i <= 50; i++ {
+		if err := db.Insert(uint64(i), []float32{float32(i), float32(i % 7), 0, 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	return db
+}
+
+func TestVecLite_Shadow(t *testing.T) {
+	primary := newShadowTestDB(t, "flat")
+	defer primary.Close()
+	shadow := newShadowTestDB(t, "flat")
+	defer shadow.Close()
+
+	var mu sync.Mutex
+	var comparisons []ShadowComparison
+	err := primary.SetShadow(shadow, ShadowConfig{MaxInFlight: 20, OnCompare: func(c ShadowComparison) {
+		mu.Lock()
+		defer mu.Unlock()
+		comparisons = append(comparisons, c)
+	}})
+	if err != nil {
+		t.Fatalf("SetShadow failed: %v", err)
+	}
+
+	for i := 1; i <= 10; i++ {
+		results, err := primary.Search([]float32{float32(i) + 0.1, 0, 0, 1}, 3)
+		if err != nil || len(results) != 3 {
+			t.Fatalf("Search failed: %v (%d results)", err, len(results))
+		}
+	}
+	primary.shadow.Load().wg.Wait()
+
+	// Results missing from the shadow lower the overlap
+	if err := shadow.Delete(11); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := primary.Search([]float32{11, 4, 0, 1}, 3); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	// Detaching waits for running shadow searches
+	stats := primary.Stats().Shadow
+	if err := primary.SetShadow(nil, ShadowConfig{}); err != nil {
+		t.Fatalf("Detaching shadow failed: %v", err)
+	}
+	if primary.Stats().Shadow != nil {
+		t.Error("Expected no shadow stats after detaching")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(comparisons) != 11 {
+		t.Fatalf("Expected 11 comparisons, got %d", len(comparisons))
+	}
+	last := comparisons[10]
+	if stats == nil || stats.Dropped != 0 || stats.Compared > 11 {
+		t.Errorf("Unexpected shadow stats: %+v", stats)
+	}
+	identical := 0
+	for _, c := range comparisons {
+		if c.Err == nil && c.Overlap == 1 && c.RankMatches == 3 {
+			identical++
+		}
+	}
+	if identical != 10 || last.Overlap >= 1 || last.ShadowLatency <= 0 {
+		t.Errorf("Expected 10 identical comparisons and a lower overlap for the last, got %d, %+v", identical, last)
+	}
+}
+
+func TestVecLite_Shadow_Stats(t *testing.T) {
+	primary := newShadowTestDB(t, "flat")
+	defer primary.Close()
+	shadow := newShadowTestDB(t, "ivf")
+	defer shadow.Close()
+
+	if err := primary.SetShadow(primary, ShadowConfig{}); err == nil {
+		t.Error("Expected a database shadowing itself to be rejected")
+	}
+	if err := primary.SetShadow(shadow, ShadowConfig{SampleRate: 2, MaxInFlight: 1}); err != nil {
+		t.Fatalf("SetShadow failed: %v", err)
+	}
+	for i := 1; i <= 20; i++ {
+		if _, err := primary.Search([]float32{float32(i), 3, 0, 1}, 5); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+	runner := primary.shadow.Load()
+	runner.wg.Wait()
+
+	stats := primary.Stats().Shadow
+	if stats == nil || stats.Compared+stats.Dropped != 10 || stats.Errors != 0 {
+		t.Fatalf("Expected 10 sampled searches, got %+v", stats)
+	}
+	if stats.MeanOverlap <= 0 || stats.MeanOverlap > 1 || stats.ShadowLatency <= 0 {
+		t.Errorf("Unexpected aggregates: %+v", stats)
+	}
+	if stats.LatencyDelta != stats.ShadowLatency-stats.PrimaryLatency {
+		t.Errorf("Expected LatencyDelta to be the difference of the means, got %+v", stats)
+	}
+}
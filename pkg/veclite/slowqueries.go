@@ -0,0 +1,69 @@
+package veclite
+
+import (
+	"sync"
+	"time"
+)
+
+// Slow query log defaults
+const (
+	defaultSlowQueryThreshold = 100 * time.Millisecond
+	slowQueryCapacity         = 100 // Most recent slow searches kept
+)
+
+// SlowQuery is a search that took at least Config.SlowQueryThreshold
+type SlowQuery struct {
+	Time    time.Time // When the search finished
+	TraceID string    // SearchOptions.TraceID of the search
+	Query   []float32
+	K       int
+	Filter  Filter
+	Latency time.Duration
+	Results int    // Number of results returned
+	Error   string // Search error, if any
+}
+
+// slowQueryLog keeps the most recent slow searches in a ring
+type slowQueryLog struct {
+	mu      sync.Mutex
+	entries []SlowQuery // Ring of up to slowQueryCapacity entries
+	next    int         // Ring position of the next entry once full
+}
+
+// slowQueryThreshold returns the effective Config.SlowQueryThreshold (0 = disabled)
+func (v *VecLite) slowQueryThreshold() time.Duration {
+	switch threshold := v.config.SlowQueryThreshold; {
+	case threshold < 0:
+		return 0
+	case threshold == 0:
+		return defaultSlowQueryThreshold
+	default:
+		return threshold
+	}
+}
+
+// record adds a slow search, replacing the oldest once the ring is full
+func (l *slowQueryLog) record(q SlowQuery) {
+	q.Query = append([]float32(nil), q.Query...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < slowQueryCapacity {
+		l.entries = append(l.entries, q)
+		return
+	}
+	l.entries[l.next] = q
+	l.next = (l.next + 1) % slowQueryCapacity
+}
+
+// SlowQueries returns the most recent searches that took at least
+// Config.SlowQueryThreshold, newest first
+func (v *VecLite) SlowQueries() []SlowQuery {
+	l := &v.slowQueries
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	queries := make([]SlowQuery, 0, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		queries = append(queries, l.entries[(l.next+i)%len(l.entries)])
+	}
+	return queries
+}
@@ -0,0 +1,131 @@
+package veclite
+
+import (
+	"time"
+
+	"github.com/monishSR/veclite/internal/index"
+)
+
+// Stats is a snapshot of the database state
+type Stats struct {
+	Size           int    // Number of vectors, hot and cold (same as Size)
+	IndexType      string // Configured index type
+	ServingIndex   string // Index currently answering searches ("flat" while degraded)
+	Loading        bool   // Serving flat search while Config.LazyLoad loads the index in the background
+	Degraded       bool   // Serving flat search because the configured index failed to load
+	DegradedReason string // Load error that caused degraded mode
+	RebuildError   string // Set if the background rebuild failed; the database stays degraded
+	ParamsMismatch string // Build parameters that differ from Config until Reindex is called
+	PQTrainError   string // Set if the last background training of the PQ codebook failed
+
+	LastMaintenance  time.Time // When the last maintenance run finished (zero = never)
+	MaintenanceError string    // Error of the last maintenance run, if any
+
+	LastAutoSave  time.Time // When the last background save finished (zero = never)
+	AutoSaveError string    // Error of the last background save, if any
+
+	Throttle ThrottleState // Background throttle limits and activity (zero = unlimited)
+	Memory   MemoryStats   // Vector cache and memory releases, see ReleaseMemory
+
+	Tombstones TombstoneStats // Dead space in the data file and the compaction forecast
+
+	Operations []Progress // Long operations running, with their latest progress (oldest first)
+
+	PendingWrites int    // Insert/Delete calls waiting for or holding the write lock (0 if unbounded)
+	WriteStalls   uint64 // Writes rejected with ErrWriteStall since open
+
+	QueryBudget *QueryBudgetStats // Searches rejected or queued by Config.QueryBudget (nil = unlimited)
+
+	Profile ProfileStats // Search counters and sampled stage timings
+
+	Drift    *DriftReport  // Query drift against a cached corpus sample, recomputed every DriftWindow/10 queries (nil = disabled or unavailable)
+	Canaries *CanaryReport // Last canary run (nil = never ran)
+	Shadow   *ShadowStats  // Comparisons with the shadow database (nil = none attached, see SetShadow)
+
+	// Rewrite is set while Compact, Reindex or Retrain rewrites the database;
+	// during Reindex and Retrain the other fields are as of when it started,
+	// which is still the state of the database since they block writes until
+	// they end. Compact lets writes run and the fields are current
+	Rewrite *RewriteStatus
+}
+
+// Stats returns a snapshot of the database state
+// Uses read lock - allows concurrent reads; while a rewrite holds the write lock
+// it returns without waiting (see Stats.Rewrite)
+func (v *VecLite) Stats() Stats {
+	stats, locked := v.rLockOrRewrite()
+	if !locked {
+		stats.Operations = v.progress.operations()
+		return stats
+	}
+	defer v.mu.RUnlock()
+	return v.statsLocked()
+}
+
+// statsLocked implements Stats
+// Note: Assumes read or write lock is already held
+func (v *VecLite) statsLocked() Stats {
+	stats := Stats{
+		Size:           v.index.Size() + v.tiers.size(),
+		IndexType:      v.config.IndexType,
+		ServingIndex:   v.servingIndex(),
+		ParamsMismatch: v.paramsMismatch,
+		Throttle:       v.throttle.State(),
+		Memory:         v.memoryStats(),
+		Tombstones:     v.tombstoneStats(),
+		Profile:        v.profiler.state(v.config.ProfileSampleRate),
+		Rewrite:        v.rewrite.current(),
+	}
+	if trainer, ok := v.index.(index.PQTrainer); ok {
+		if err := trainer.PQError(); err != nil {
+			stats.PQTrainError = err.Error()
+		}
+	}
+	stats.PendingWrites, stats.WriteStalls = v.writes.state()
+	stats.QueryBudget = v.admission.state()
+	if v.degraded != nil {
+		if v.degraded.loading {
+			stats.Loading = true
+		} else {
+			stats.Degraded = true
+			stats.DegradedReason = v.degraded.reason.Error()
+		}
+		select {
+		case <-v.degraded.done:
+			if v.degraded.err != nil {
+				stats.RebuildError = v.degraded.err.Error()
+			}
+		default:
+		}
+	}
+	if runner := v.maintenance; runner != nil {
+		runner.mu.Lock()
+		stats.LastMaintenance = runner.lastRun
+		if runner.lastErr != nil {
+			stats.MaintenanceError = runner.lastErr.Error()
+		}
+		runner.mu.Unlock()
+	}
+	if saver := v.autoSaver; saver != nil {
+		saver.mu.Lock()
+		stats.LastAutoSave = saver.lastSave
+		if saver.lastErr != nil {
+			stats.AutoSaveError = saver.lastErr.Error()
+		}
+		saver.mu.Unlock()
+	}
+	stats.Drift, _ = v.driftReport(false) // Best effort: a failed corpus sample leaves Drift nil
+	stats.Canaries = v.lastCanaryReport()
+	stats.Shadow = v.shadowStats()
+	stats.Operations = v.progress.operations()
+	return stats
+}
+
+// servingIndex returns the index type answering searches ("flat" while degraded)
+// Note: Assumes read lock is already held
+func (v *VecLite) servingIndex() string {
+	if v.degraded != nil {
+		return "flat"
+	}
+	return v.config.IndexType
+}
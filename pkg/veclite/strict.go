@@ -0,0 +1,71 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Errors for API misuse that is accommodated silently unless Config.Strict is set
+var (
+	// ErrEmptyDatabase is returned, with no results, by searches of a database
+	// holding no vectors, e.g. queried before anything was inserted
+	ErrEmptyDatabase = errors.New("database is empty")
+	// ErrKTooLarge is returned by searches asking for more results than Size
+	ErrKTooLarge = errors.New("k exceeds the number of vectors")
+	// ErrReservedID is returned by inserts under an ID reserved for string keys
+	// (KeyedIDBase and above, see InsertKey)
+	ErrReservedID = errors.New("reserved ID")
+	// ErrNonFinite is returned for vectors and queries with NaN or infinite values
+	ErrNonFinite = errors.New("non-finite value")
+)
+
+// validate checks the dimension of vector (what: "vector" or "query") and,
+// under Config.Strict, that its values are finite
+func (v *VecLite) validate(what string, vector []float32) error {
+	if err := v.checkInput(what, vector); err != nil {
+		return err
+	}
+	if !v.config.Strict {
+		return nil
+	}
+	for i, value := range vector {
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return fmt.Errorf("%w: %s value %g at dimension %d", ErrNonFinite, what, value, i)
+		}
+	}
+	return nil
+}
+
+// validateInsert is validate for a vector inserted under id, which under
+// Config.Strict must not be reserved
+// IDs at or above KeyedIDBase are accepted once InsertKey assigned them to a key
+func (v *VecLite) validateInsert(id uint64, vector []float32) error {
+	if err := v.validate("vector", vector); err != nil {
+		return err
+	}
+	if !v.config.Strict {
+		return nil
+	}
+	if _, keyed := v.keys.key(id); id >= KeyedIDBase && !keyed {
+		return fmt.Errorf("%w: %d is in the range of string key IDs", ErrReservedID, id)
+	}
+	return nil
+}
+
+// checkK returns ErrEmptyDatabase or ErrKTooLarge under Config.Strict if a
+// search for k results cannot fill them
+// Note: Assumes lock is already held
+func (v *VecLite) checkK(k int) error {
+	if !v.config.Strict {
+		return nil
+	}
+	size := v.index.Size() + v.tiers.size()
+	if size == 0 {
+		return ErrEmptyDatabase
+	}
+	if k > size {
+		return fmt.Errorf("%w: k = %d, %d vectors stored", ErrKTooLarge, k, size)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package veclite
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestVecLite_Strict(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "strict.db")
+	config.Dimension = 2
+	config.Strict = true
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	if results, err := db.Search([]float32{0, 0}, 1); !errors.Is(err, ErrEmptyDatabase) || len(results) != 0 {
+		t.Fatalf("Expected ErrEmptyDatabase before any insert, got %v (%v)", results, err)
+	}
+
+	nan := float32(math.NaN())
+	if err := db.Insert(1, []float32{nan, 0}); !errors.Is(err, ErrNonFinite) {
+		t.Fatalf("Expected ErrNonFinite for a NaN vector, got %v", err)
+	}
+	if err := db.InsertBatch([]uint64{1}, [][]float32{{float32(math.Inf(1)), 0}}); !errors.Is(err, ErrNonFinite) {
+		t.Fatalf("Expected ErrNonFinite for an infinite vector, got %v", err)
+	}
+	if err := db.Insert(KeyedIDBase+5, []float32{1, 0}); !errors.Is(err, ErrReservedID) {
+		t.Fatalf("Expected ErrReservedID for a keyed ID, got %v", err)
+	}
+	var batch Batch
+	batch.Upsert(^uint64(0), []float32{1, 0})
+	if err := db.Apply(&batch); !errors.Is(err, ErrReservedID) {
+		t.Fatalf("Expected ErrReservedID in a batch, got %v", err)
+	}
+
+	if err := db.Insert(1, []float32{1, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	// IDs assigned to string keys stay usable
+	id, err := db.InsertKey("doc", []float32{0, 1})
+	if err != nil {
+		t.Fatalf("InsertKey failed: %v", err)
+	}
+	if err := db.Upsert(id, []float32{0, 2}); err != nil {
+		t.Fatalf("Upsert of a keyed ID failed: %v", err)
+	}
+
+	if _, err := db.Search([]float32{0, nan}, 1); !errors.Is(err, ErrNonFinite) {
+		t.Fatalf("Expected ErrNonFinite for a NaN query, got %v", err)
+	}
+	if _, err := db.Search([]float32{0, 0}, 3); !errors.Is(err, ErrKTooLarge) {
+		t.Fatalf("Expected ErrKTooLarge, got %v", err)
+	}
+	if results, err := db.Search([]float32{0, 0}, 2); err != nil || len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %v (%v)", results, err)
+	}
+}
+
+func TestVecLite_StrictDisabled(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if results, err := db.Search(make([]float32, 128), 5); err != nil || len(results) != 0 {
+		t.Fatalf("Expected no results and no error, got %v (%v)", results, err)
+	}
+	vec := make([]float32, 128)
+	vec[0] = float32(math.NaN())
+	if err := db.Insert(KeyedIDBase+5, vec); err != nil {
+		t.Fatalf("Expected the insert to be accommodated, got %v", err)
+	}
+	if results, err := db.Search(make([]float32, 128), 5); err != nil || len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %v (%v)", results, err)
+	}
+}
@@ -0,0 +1,320 @@
+package veclite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/segment"
+)
+
+// SegmentInfo describes a cold segment, see Demote
+type SegmentInfo struct {
+	Name    string // Segment file name, next to the data file
+	Vectors int    // Live vectors
+	Deleted int    // Vectors deleted or re-inserted since the segment was written
+	Bytes   int64  // Memory used by the quantized vectors
+}
+
+// coldTier holds the cold segments of a database: vectors moved out of the hot
+// storage and index by Demote, kept scalar-quantized (one byte per dimension)
+// in read-only segment files and searched by exact scan
+// The segment list is recorded in a JSON manifest (DataPath + ".cold")
+// Note: Mutated under the write lock and read under the read lock, like the index
+type coldTier struct {
+	manifestPath string
+	dim          int
+	next         int // Number of the next segment file
+	segments     []*coldSegment
+	dirty        bool // Deletions since the manifest was saved
+}
+
+// coldSegment is a loaded segment and the IDs removed from it
+type coldSegment struct {
+	name    string
+	seg     *segment.Segment
+	deleted map[uint64]struct{}
+}
+
+// tierManifest is the JSON form of the cold tier
+type tierManifest struct {
+	Next     int               `json:"next"`
+	Segments []manifestSegment `json:"segments"`
+}
+
+// manifestSegment is one segment of the manifest
+type manifestSegment struct {
+	File    string   `json:"file"`
+	Deleted []uint64 `json:"deleted,omitempty"`
+}
+
+// openColdTier loads the cold tier of the database at dataPath (empty if it has none)
+func openColdTier(dataPath string, dim int) (*coldTier, error) {
+	t := &coldTier{manifestPath: dataPath + ".cold", dim: dim}
+	data, err := os.ReadFile(t.manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cold tier manifest: %w", err)
+	}
+	var manifest tierManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid cold tier manifest: %w", err)
+	}
+
+	t.next = manifest.Next
+	dir := filepath.Dir(dataPath)
+	for _, entry := range manifest.Segments {
+		seg, err := segment.Open(filepath.Join(dir, entry.File), dim)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cold segment %s: %w", entry.File, err)
+		}
+		cold := &coldSegment{name: entry.File, seg: seg, deleted: make(map[uint64]struct{})}
+		for _, id := range entry.Deleted {
+			cold.deleted[id] = struct{}{}
+		}
+		t.segments = append(t.segments, cold)
+	}
+	return t, nil
+}
+
+// live reports whether id is a live vector of the segment
+func (c *coldSegment) live(id uint64) bool {
+	_, deleted := c.deleted[id]
+	return !deleted && c.seg.Contains(id)
+}
+
+// info describes the segment
+func (c *coldSegment) info() SegmentInfo {
+	return SegmentInfo{
+		Name:    c.name,
+		Vectors: c.seg.Len() - len(c.deleted),
+		Deleted: len(c.deleted),
+		Bytes:   c.seg.SizeBytes(),
+	}
+}
+
+// size returns the number of live cold vectors
+func (t *coldTier) size() int {
+	n := 0
+	for _, cold := range t.segments {
+		n += cold.seg.Len() - len(cold.deleted)
+	}
+	return n
+}
+
+// has reports whether id is a live cold vector
+func (t *coldTier) has(id uint64) bool {
+	for _, cold := range t.segments {
+		if cold.live(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the dequantized vector of a live cold id
+func (t *coldTier) get(id uint64) ([]float32, bool) {
+	for _, cold := range t.segments {
+		if cold.live(id) {
+			vec, err := cold.seg.Vector(id)
+			return vec, err == nil
+		}
+	}
+	return nil, false
+}
+
+// remove deletes id from the cold tier and reports whether it was there
+func (t *coldTier) remove(id uint64) bool {
+	for _, cold := range t.segments {
+		if cold.live(id) {
+			cold.deleted[id] = struct{}{}
+			t.dirty = true
+			return true
+		}
+	}
+	return false
+}
+
+// add writes vectors to a new segment and records it in the manifest
+func (t *coldTier) add(vectors map[uint64][]float32) (SegmentInfo, error) {
+	name := fmt.Sprintf("%s.%06d", filepath.Base(t.manifestPath), t.next)
+	seg, err := segment.Write(filepath.Join(filepath.Dir(t.manifestPath), name), t.dim, vectors)
+	if err != nil {
+		return SegmentInfo{}, err
+	}
+	cold := &coldSegment{name: name, seg: seg, deleted: make(map[uint64]struct{})}
+	t.segments = append(t.segments, cold)
+	t.next++
+	t.dirty = true
+	if err := t.save(); err != nil {
+		return SegmentInfo{}, err
+	}
+	return cold.info(), nil
+}
+
+// search returns the k live cold vectors nearest to query among those accepted
+// by keep (nil = all), merged across segments
+func (t *coldTier) search(ctx context.Context, query []float32, k int, keep func(id uint64) bool) ([]SearchResult, error) {
+	var results []SearchResult
+	for _, cold := range t.segments {
+		accept := func(id uint64) bool {
+			_, deleted := cold.deleted[id]
+			return !deleted && (keep == nil || keep(id))
+		}
+		found, err := cold.seg.Search(ctx, query, k, accept)
+		if err != nil {
+			return nil, err
+		}
+		results = mergeResults(results, found, k)
+	}
+	return results, nil
+}
+
+// save writes the manifest atomically if it changed, dropping the files of
+// segments whose vectors were all deleted
+func (t *coldTier) save() error {
+	if !t.dirty {
+		return nil
+	}
+	dir := filepath.Dir(t.manifestPath)
+	var manifest tierManifest
+	manifest.Next = t.next
+	kept := t.segments[:0]
+	var emptied []string
+	for _, cold := range t.segments {
+		if len(cold.deleted) == cold.seg.Len() {
+			emptied = append(emptied, cold.name)
+			continue
+		}
+		kept = append(kept, cold)
+		manifest.Segments = append(manifest.Segments, manifestSegment{File: cold.name, Deleted: sortedIDs(cold.deleted)})
+	}
+	t.segments = kept
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode cold tier manifest: %w", err)
+	}
+	tmpPath := t.manifestPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cold tier manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, t.manifestPath); err != nil {
+		return fmt.Errorf("failed to replace cold tier manifest: %w", err)
+	}
+	// Only unreferenced files are removed, so a crash leaves at worst an orphan
+	for _, name := range emptied {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to remove empty cold segment %s: %v\n", name, err)
+		}
+	}
+	t.dirty = false
+	return nil
+}
+
+// mergeResults merges two result lists sorted by distance into the k nearest
+func mergeResults(a, b []SearchResult, k int) []SearchResult {
+	merged := append(append(make([]SearchResult, 0, len(a)+len(b)), a...), b...)
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Distance != merged[j].Distance {
+			return merged[i].Distance < merged[j].Distance
+		}
+		return merged[i].ID < merged[j].ID
+	})
+	if len(merged) > k {
+		merged = merged[:k]
+	}
+	return merged
+}
+
+// Demote moves the given vectors to a new cold segment: they leave the hot
+// storage and index and are kept quantized to one byte per dimension, cutting
+// their memory and disk cost by about 4x
+// Cold vectors stay searchable (results are merged across tiers by distance
+// to their quantized values), readable with Get and deletable; inserting a
+// cold ID again makes it hot. Metadata is kept
+// Requires exclusive write lock - blocks all reads and writes while writing
+func (v *VecLite) Demote(ids []uint64) (SegmentInfo, error) {
+	if len(ids) == 0 {
+		return SegmentInfo{}, errors.New("demote: no IDs given")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.degraded != nil {
+		return SegmentInfo{}, errors.New("demote: degraded-mode rebuild in progress")
+	}
+
+	vectors := make(map[uint64][]float32, len(ids))
+	for _, id := range ids {
+		vec, err := v.index.ReadVector(id)
+		if err != nil {
+			return SegmentInfo{}, fmt.Errorf("demote: %w", err)
+		}
+		vectors[id] = vec
+	}
+	info, err := v.tiers.add(vectors)
+	if err != nil {
+		return SegmentInfo{}, fmt.Errorf("demote: %w", err)
+	}
+	// The segment is durable: a crash from here on leaves vectors in both tiers,
+	// which New resolves in favor of the hot copy
+	for _, id := range sortedIDs(vectors) {
+		v.preserveForScrolls(id)
+		if err := v.index.Delete(id); err != nil {
+			return info, fmt.Errorf("demote: failed to remove vector %d from the hot tier: %w", id, err)
+		}
+	}
+	v.visible.notify()
+	v.autoSaver.wrote(len(vectors))
+	return info, nil
+}
+
+// searchCold merges the k nearest cold vectors matching filter (nil = all) into
+// the hot results
+// Note: Assumes read lock is already held
+func (v *VecLite) searchCold(ctx context.Context, query []float32, k int, filter Filter, hot []SearchResult, p *profile.Profile) ([]SearchResult, error) {
+	defer p.Start("cold")()
+	var keep func(id uint64) bool
+	if len(filter) > 0 {
+		keep = func(id uint64) bool { return v.metadata.matches(id, filter) }
+	}
+	cold, err := v.tiers.search(profile.NewContext(ctx, p), query, k, keep)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("search: %w", err)
+		}
+		return nil, err
+	}
+	return mergeResults(hot, cold, k), nil
+}
+
+// Segments lists the cold segments created by Demote
+// Uses read lock - allows concurrent reads
+func (v *VecLite) Segments() []SegmentInfo {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	infos := make([]SegmentInfo, 0, len(v.tiers.segments))
+	for _, cold := range v.tiers.segments {
+		infos = append(infos, cold.info())
+	}
+	return infos
+}
+
+// dropShadowedCold removes cold copies of vectors that are also hot, left by a
+// crash during Demote or after re-inserting a cold ID
+// Note: Assumes the database is not shared yet
+func (v *VecLite) dropShadowedCold() {
+	if len(v.tiers.segments) == 0 {
+		return
+	}
+	for _, id := range v.storage.IDs() {
+		v.tiers.remove(id)
+	}
+}
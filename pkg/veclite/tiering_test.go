@@ -0,0 +1,204 @@
+package veclite
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testVector returns the vector insertTestVectors stores for id
+func testVector(id int) []float32 {
+	vector := make([]float32, 128)
+	for j := range vector {
+		vector[j] = float32(id) + float32(j)*0.001
+	}
+	return vector
+}
+
+// removeColdFiles removes the cold tier files of dataPath when the test ends
+func removeColdFiles(t *testing.T, dataPath string) {
+	t.Cleanup(func() {
+		files, _ := filepath.Glob(dataPath + ".cold*")
+		for _, file := range files {
+			os.Remove(file)
+		}
+	})
+}
+
+// demoteRange demotes IDs from..to
+func demoteRange(t *testing.T, db *VecLite, from, to int) SegmentInfo {
+	t.Helper()
+	var ids []uint64
+	for i := from; i <= to; i++ {
+		ids = append(ids, uint64(i))
+	}
+	info, err := db.Demote(ids)
+	if err != nil {
+		t.Fatalf("Demote failed: %v", err)
+	}
+	return info
+}
+
+func TestDemote_Search(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		insertTestVectors(t, db, 50)
+		probeAllClusters(db)
+
+		info := demoteRange(t, db, 1, 20)
+		removeColdFiles(t, db.config.DataPath)
+		if info.Vectors != 20 || info.Bytes == 0 {
+			t.Fatalf("Unexpected segment info: %+v", info)
+		}
+		if db.Size() != 50 || db.index.Size() != 30 {
+			t.Errorf("Expected 30 hot + 20 cold vectors, got %d total, %d hot", db.Size(), db.index.Size())
+		}
+
+		// Results from both tiers are merged by distance
+		results, err := db.Search(testVector(20), 3)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 3 || results[0].ID != 20 {
+			t.Fatalf("Expected cold vector 20 first, got %+v", results)
+		}
+		if results, err := db.Search(testVector(21), 1); err != nil || results[0].ID != 21 {
+			t.Errorf("Expected hot vector 21, got %+v, %v", results, err)
+		}
+
+		vec, err := db.Get(5)
+		if err != nil {
+			t.Fatalf("Get of a cold vector failed: %v", err)
+		}
+		if vec[0] < 4.9 || vec[0] > 5.1 {
+			t.Errorf("Expected about 5 for the quantized value, got %v", vec[0])
+		}
+	})
+}
+
+func TestDemote_DeleteAndReinsert(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	removeColdFiles(t, db.config.DataPath)
+	insertTestVectors(t, db, 10)
+	demoteRange(t, db, 1, 5)
+
+	if err := db.Delete(3); err != nil {
+		t.Fatalf("Delete of a cold vector failed: %v", err)
+	}
+	if _, err := db.Get(3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := db.Insert(4, testVector(40)); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for a cold vector, got %v", err)
+	}
+	if err := db.Upsert(4, testVector(40)); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if vec, err := db.Get(4); err != nil || vec[0] != 40 {
+		t.Errorf("Expected the re-inserted hot vector, got %v, %v", vec, err)
+	}
+	segments := db.Segments()
+	if len(segments) != 1 || segments[0].Vectors != 3 || segments[0].Deleted != 2 {
+		t.Errorf("Expected 3 live and 2 deleted cold vectors, got %+v", segments)
+	}
+	if db.Size() != 9 {
+		t.Errorf("Expected 9 vectors, got %d", db.Size())
+	}
+	if usage, err := db.DiskUsage(); err != nil || usage.Cold == 0 {
+		t.Errorf("Expected cold tier disk usage, got %+v, %v", usage, err)
+	}
+
+	if _, err := db.Demote([]uint64{999}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound demoting a missing vector, got %v", err)
+	}
+	if _, err := db.Demote(nil); err == nil {
+		t.Error("Expected an error demoting nothing")
+	}
+}
+
+func TestDemote_Persistence(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+	config := db.config
+	removeColdFiles(t, config.DataPath)
+	defer os.Remove(config.DataPath + ".meta")
+	for i := 1; i <= 10; i++ {
+		if err := db.InsertWithMetadata(uint64(i), testVector(i), Metadata{"tier": "any"}); err != nil {
+			t.Fatalf("InsertWithMetadata failed: %v", err)
+		}
+	}
+	demoteRange(t, db, 1, 4)
+	demoteRange(t, db, 5, 6)
+	if err := db.Delete(5); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Delete(6); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(config.DataPath + ".cold.000001"); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied segment file to be removed, got %v", err)
+	}
+
+	reopened, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != 8 || len(reopened.Segments()) != 1 {
+		t.Fatalf("Expected 8 vectors and 1 segment, got %d and %+v", reopened.Size(), reopened.Segments())
+	}
+
+	// Filters apply to cold vectors through their metadata
+	opts := DefaultSearchOptions()
+	opts.Filter = Filter{"tier": "any"}
+	results, err := reopened.SearchWithOptions(testVector(2), 2, opts)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != 2 || results[0].Metadata["tier"] != "any" {
+		t.Errorf("Expected cold vector 2 with metadata, got %+v", results)
+	}
+
+	report, err := Verify(config, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected cold metadata not to be reported as orphaned, got %+v", report.Issues)
+	}
+}
+
+func TestDemote_ShadowedOnOpen(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	config := db.config
+	removeColdFiles(t, config.DataPath)
+	insertTestVectors(t, db, 5)
+
+	// Simulate a crash during Demote: the segment exists, the hot copies too
+	tiers, err := openColdTier(config.DataPath, config.Dimension)
+	if err != nil {
+		t.Fatalf("openColdTier failed: %v", err)
+	}
+	if _, err := tiers.add(map[uint64][]float32{1: testVector(1), 2: testVector(2)}); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer reopened.Close()
+	if reopened.Size() != 5 {
+		t.Errorf("Expected hot copies to win, got size %d", reopened.Size())
+	}
+}
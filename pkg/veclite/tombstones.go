@@ -0,0 +1,117 @@
+package veclite
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Tombstone forecasting defaults
+const (
+	defaultCompactionThreshold = 0.5
+	tombstoneRateWindow        = time.Hour   // Period the tombstone rate is measured over
+	tombstoneSampleInterval    = time.Minute // Minimum spacing of rate samples
+)
+
+// TombstoneStats reports the dead space in the data file and forecasts when
+// it will reach Config.CompactionThreshold, so compaction can be scheduled
+// before it is needed
+type TombstoneStats struct {
+	DeadBytes int64   // Tombstoned or superseded records awaiting compaction
+	DeadRatio float64 // DeadBytes as a fraction of all vector records in the data file
+	Threshold float64 // Effective Config.CompactionThreshold
+	Created   uint64  // Records tombstoned or superseded since open
+	Rate      float64 // Records tombstoned or superseded per second over the last hour
+
+	// CompactionDue is set once DeadRatio has reached Threshold
+	CompactionDue bool
+
+	// CompactionETA forecasts when DeadRatio reaches Threshold if records keep being
+	// tombstoned at Rate while the live data stays the same size
+	// (0 = already due, or no records are being tombstoned)
+	CompactionETA time.Duration
+}
+
+// tombstoneSample is the cumulative tombstone count at a point in time
+type tombstoneSample struct {
+	at    time.Time
+	count uint64
+}
+
+// tombstoneTracker measures the rate at which records are tombstoned from
+// samples of the storage counter taken when Stats is read
+type tombstoneTracker struct {
+	mu      sync.Mutex
+	now     func() time.Time  // Clock (nil = time.Now, replaced in tests)
+	samples []tombstoneSample // Oldest first, spanning at most tombstoneRateWindow
+}
+
+// rate records count and returns the tombstones per second since the oldest
+// sample in the window (0 until time has passed since the first sample)
+func (t *tombstoneTracker) rate(count uint64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+
+	if n := len(t.samples); n == 0 || now.Sub(t.samples[n-1].at) >= tombstoneSampleInterval {
+		t.samples = append(t.samples, tombstoneSample{at: now, count: count})
+	}
+	// Keep one sample at or before the window start, so the rate spans the whole window
+	drop := 0
+	for drop+1 < len(t.samples) && now.Sub(t.samples[drop+1].at) >= tombstoneRateWindow {
+		drop++
+	}
+	t.samples = t.samples[drop:]
+
+	oldest := t.samples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 || count < oldest.count {
+		return 0
+	}
+	return float64(count-oldest.count) / elapsed
+}
+
+// compactionThreshold returns the effective Config.CompactionThreshold
+func (v *VecLite) compactionThreshold() float64 {
+	if v.config.CompactionThreshold <= 0 {
+		return defaultCompactionThreshold
+	}
+	return v.config.CompactionThreshold
+}
+
+// tombstoneStats computes the tombstone statistics and compaction forecast
+// A failure to read the data file size leaves them zero
+// Note: Assumes read or write lock is already held
+func (v *VecLite) tombstoneStats() TombstoneStats {
+	created := v.storage.DeadRecords()
+	stats := TombstoneStats{
+		Threshold: v.compactionThreshold(),
+		Created:   created,
+		Rate:      v.tombstones.rate(created),
+	}
+	u, err := v.storage.Usage()
+	if err != nil {
+		return stats
+	}
+	stats.DeadBytes = u.DeadBytes
+	if records := u.DeadBytes + u.LiveBytes; records > 0 {
+		stats.DeadRatio = float64(u.DeadBytes) / float64(records)
+	}
+	stats.CompactionDue = stats.DeadRatio >= stats.Threshold
+
+	// Dead bytes at which DeadBytes/(DeadBytes+LiveBytes) reaches the threshold
+	if stats.CompactionDue || stats.Threshold >= 1 || stats.Rate <= 0 || u.RecordBytes <= 0 {
+		return stats
+	}
+	target := stats.Threshold * float64(u.LiveBytes) / (1 - stats.Threshold)
+	seconds := (target - float64(u.DeadBytes)) / (stats.Rate * float64(u.RecordBytes))
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		stats.CompactionETA = time.Duration(math.MaxInt64)
+	} else {
+		stats.CompactionETA = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	}
+	return stats
+}
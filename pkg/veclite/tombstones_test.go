@@ -0,0 +1,80 @@
+package veclite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVecLite_TombstoneStats(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = t.TempDir() + "/tombstones.db"
+	config.Dimension = 4
+	config.CompactionThreshold = 0.25
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	db.tombstones.now = func() time.Time { return clock }
+
+	for id := uint64(1); id <= 30; id++ {
+		if err := db.Insert(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	stats := db.Stats().Tombstones
+	if stats.Created != 0 || stats.DeadBytes != 0 || stats.Rate != 0 || stats.CompactionETA != 0 || stats.CompactionDue {
+		t.Errorf("Expected no tombstones after inserts, got %+v", stats)
+	}
+	if stats.Threshold != 0.25 {
+		t.Errorf("Expected threshold 0.25, got %v", stats.Threshold)
+	}
+
+	// 3 deletes in 10 minutes: 27 live records, 3 dead, 0.3 tombstones per minute
+	clock = clock.Add(10 * time.Minute)
+	for id := uint64(1); id <= 3; id++ {
+		if err := db.Delete(id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	stats = db.Stats().Tombstones
+	if stats.Created != 3 || stats.DeadRatio != 0.1 || stats.CompactionDue {
+		t.Fatalf("Expected 3 tombstones at ratio 0.1, got %+v", stats)
+	}
+	// The ratio reaches 0.25 at 9 dead records: 6 more at 0.3 per minute
+	if stats.CompactionETA != 20*time.Minute {
+		t.Errorf("Expected a compaction ETA of 20m, got %v (%+v)", stats.CompactionETA, stats)
+	}
+
+	// Updates tombstone the records they supersede
+	clock = clock.Add(10 * time.Minute)
+	for id := uint64(4); id <= 9; id++ {
+		if err := db.Upsert(id, []float32{0, float32(id), 0, 0}); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+	}
+	stats = db.Stats().Tombstones
+	if stats.Created != 9 || !stats.CompactionDue || stats.CompactionETA != 0 {
+		t.Errorf("Expected compaction to be due after 9 tombstones, got %+v", stats)
+	}
+
+	// Compaction reclaims the dead space; the rate still reflects the last hour
+	if err := db.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	stats = db.Stats().Tombstones
+	if stats.DeadBytes != 0 || stats.CompactionDue || stats.Rate <= 0 || stats.CompactionETA <= 0 {
+		t.Errorf("Expected a forecast from zero dead space after compaction, got %+v", stats)
+	}
+
+	// Without tombstones in the last hour there is no forecast
+	clock = clock.Add(2 * time.Hour)
+	db.Stats()
+	clock = clock.Add(time.Minute)
+	if stats = db.Stats().Tombstones; stats.Rate != 0 || stats.CompactionETA != 0 {
+		t.Errorf("Expected no rate after an idle hour, got %+v", stats)
+	}
+}
@@ -1,38 +1,271 @@
 package veclite
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/monishSR/veclite/internal/fileinfo"
 	"github.com/monishSR/veclite/internal/index"
 	"github.com/monishSR/veclite/internal/index/hnsw"
 	"github.com/monishSR/veclite/internal/index/ivf"
+	"github.com/monishSR/veclite/internal/profile"
+	"github.com/monishSR/veclite/internal/singleflight"
 	"github.com/monishSR/veclite/internal/storage"
+	"github.com/monishSR/veclite/internal/throttle"
 )
 
 // VecLite represents the main embedded vector database instance
 type VecLite struct {
-	mu      sync.RWMutex // Read-write lock for thread safety
-	config  *Config
-	storage *storage.Storage
-	index   index.Index // Abstract index interface
+	mu       sync.RWMutex // Read-write lock for thread safety
+	config   *Config
+	name     string // Database name in pprof labels and expvar, see Config.Name
+	inputDim int    // Dimension of vectors and queries before Config.Pipeline
+	storage  *storage.Storage
+	index    index.Index // Abstract index interface
+	audit    *auditLog   // Optional audit log of mutations (nil = disabled)
+	queries  *queryLog   // Optional query log of searches (nil = disabled)
+
+	shadow atomic.Pointer[shadowRunner] // A/B shadow database, see SetShadow (nil = none)
+
+	compactMu sync.Mutex // Serializes Compact, which copies the data file without mu, with Close
+
+	degraded       *degradedState     // Non-nil while serving flat search in degraded mode
+	ready          <-chan struct{}    // Closed once the configured index serves searches, see Ready
+	paramsMismatch string             // Build parameters differing from Config, until Reindex
+	metadata       *metadataStore     // Per-vector metadata (.meta sidecar)
+	tiers          *coldTier          // Cold segments created by Demote
+	keys           *keyStore          // String keys of vectors inserted with InsertKey
+	kv             *kvStore           // Application key-value namespace, see KV
+	collections    *Collections       // Named collections with their own files, see CreateCollection
+	scrolls        scrollRegistry     // Open point-in-time scrolls
+	drift          *driftTracker      // Rolling query window for drift detection (nil = disabled)
+	canaries       canarySuite        // Registered canary queries and the last report
+	maintenance    *maintenanceRunner // Background maintenance scheduler (nil = disabled)
+	autoSaver      *autoSaver         // Background saves (nil = disabled)
+	throttle       *throttle.Throttle // Paces background work (nil = unlimited)
+	writes         *writeGate         // Bounds pending Insert/Delete calls (nil = unlimited)
+	admission      *admission         // Rejects or queues searches over Config.QueryBudget (nil = unlimited)
+	profiler       searchProfiler     // Search counters and sampled stage timings
+	slowQueries    slowQueryLog       // Recent searches slower than Config.SlowQueryThreshold
+	visible        visibility         // Wakes WaitForSeq callers when writes are applied
+	rewrite        rewriteState       // Running compaction or reindex, for Size and Stats
+	memory         memoryTracker      // Memory releases, for Stats
+	tombstones     tombstoneTracker   // Tombstone rate samples, for Stats
+	compactions    compactionHistory  // Work and time of past compactions, for CompactEstimate
+	progress       progressRegistry   // Running long operations, for Stats
+	compacting     *progressTracker   // Progress of the running compaction (guarded by compactMu)
+	memoryWatcher  *memoryWatcher     // Releases memory under pressure (nil = disabled)
+
+	loads singleflight.Group[uint64, []float32] // In-flight Config.Loader calls
 }
 
 // Config holds configuration for VecLite
 type Config struct {
 	DataPath       string
+	Name           string // Identifies the database in pprof labels and expvar (default: DataPath base name without extension)
 	Dimension      int
 	IndexType      string
 	MaxElements    int
-	M              int // HNSW parameter
-	EfConstruction int // HNSW parameter
-	EfSearch       int // HNSW parameter
-	NClusters      int // IVF parameter
-	NProbe         int // IVF parameter
-	CacheCapacity  int // LRU cache capacity (0 = disabled, default: 1000)
+	M              int  // HNSW parameter
+	EfConstruction int  // HNSW parameter
+	EfSearch       int  // HNSW parameter
+	EdgeWeights    bool // HNSW: persist edge distances in the graph file (~50% larger) for DumpGraph and RepairGraph
+	EntryPoints    int  // HNSW: start searches from the closest of this many top-level nodes (0 or 1 = the single entry point)
+	PQSubspaces    int  // HNSW and IVF-PQ: score search candidates with in-memory product-quantized codes of this many bytes per vector, re-ranking the results exactly (0 = disabled; IVF-PQ: one byte per 4 dimensions)
+	GraphJournal   bool // HNSW: save the nodes changed since the last save to a journal (.graph.journal) instead of rewriting the graph file, which is rewritten once the journal outgrows half of it
+	NClusters      int  // IVF parameter
+	NProbe         int  // IVF parameter
+	RerankK        int  // IVF-PQ: re-rank this many candidates, nearest by code, with their stored vectors (0 = 2 per result)
+	CacheCapacity  int  // LRU cache capacity (0 = disabled, default: 1000)
+	IOHints        bool // Advise the kernel about random vs sequential file access (Linux only)
+	StrictFloat64  bool // Reject float64 vectors and queries with values float32 can't represent exactly
+	Strict         bool // Turn API misuse into errors: NaN/Inf values (ErrNonFinite), reserved IDs (ErrReservedID), searching an empty database (ErrEmptyDatabase) or for more than Size results (ErrKTooLarge)
+	LocalityLayout bool // Reorder records by index locality (HNSW neighborhood / IVF cluster) on compaction
+
+	// RecordAlignment pads the records of the data file so every vector starts on a multiple
+	// of this many bytes, e.g. 8 or 64 for aligned SIMD loads over a mapped file (a power of
+	// two up to 4096, 0 = unaligned). Existing files keep their alignment until compacted.
+	RecordAlignment int
+
+	// FlatBlockSize keeps a bounding box (per-dimension minimum and maximum) of every block
+	// of this many records, so exact flat searches skip the blocks that cannot hold a result
+	// (0 = disabled). Boxes cost 8 bytes per dimension per block; they are built on open.
+	FlatBlockSize int
+
+	// Pivots keeps the distances from every vector to this many pivot vectors in memory (4
+	// bytes per pivot per vector), so flat searches and HNSW PQ re-ranking skip, without
+	// reading them, candidates that the triangle inequality places beyond the current k-th
+	// result (0 = disabled). Pivots are chosen from the stored vectors on open.
+	Pivots int
+
+	// Pipeline transforms every vector before it is stored and every query before it is
+	// searched, e.g. Normalize, Project and Quantize, so queries always match the stored
+	// vectors (nil = none). Dimension is the dimension the pipeline outputs; vectors and
+	// queries have the dimension it takes (see InputDimension). Hooks, the query log and
+	// rerankers see the vectors as passed in; Get returns them transformed.
+	Pipeline Pipeline
+
+	// DegradedMode keeps the database serving when the HNSW graph or IVF file can't be loaded:
+	// searches fall back to exact flat search over storage while the index is rebuilt in the
+	// background (see Stats().Degraded). Without it such a load failure fails New.
+	DegradedMode bool
+
+	// LazyLoad makes New return without loading the HNSW graph or IVF file: searches use
+	// exact flat search over storage (see Stats().Loading) until the index, loaded in the
+	// background, is swapped in; Ready reports when. A sidecar that fails to load is then
+	// rebuilt from storage as in DegradedMode.
+	LazyLoad bool
+
+	// AutoReindex rebuilds the index on open when M, EfConstruction or NClusters differ from
+	// the parameters the existing index was built with. Without it the old parameters stay in
+	// effect (with a warning and Stats().ParamsMismatch) until Reindex is called.
+	// EfSearch, EntryPoints, PQSubspaces, NProbe and RerankK are query-time parameters and always follow Config.
+	AutoReindex bool
+
+	// Recovery after an unclean shutdown: without a footer index, the data file is scanned
+	// to rebuild it, in ranges by RecoveryWorkers goroutines (0 = GOMAXPROCS). RecoveryProgress
+	// (optional) is called with the bytes scanned so far and the total during the scan
+	RecoveryWorkers  int
+	RecoveryProgress func(scanned, total int64)
+
+	// Progress (optional) receives the progress of long operations: bulk loads, reindexing,
+	// compaction, Pack, graph rebuilds and recovery (see ProgressFunc)
+	Progress ProgressFunc
+
+	// OpenFile opens the data file (default: os.OpenFile)
+	// Used to inject faults in tests, see the veclitetest package
+	OpenFile func(name string, flag int, perm os.FileMode) (File, error)
+
+	// Default deadlines for Search and Insert/Delete (0 = no deadline)
+	// Applied when callers use the non-context variants; the *Context variants use the caller's context
+	DefaultSearchTimeout time.Duration
+	DefaultWriteTimeout  time.Duration
+
+	// Write backpressure: at most MaxPendingWrites Insert/Delete calls may wait for
+	// or hold the write lock; further writes wait up to WriteStallTimeout for a slot
+	// and then fail with ErrWriteStall (0 = unlimited / fail immediately)
+	MaxPendingWrites  int
+	WriteStallTimeout time.Duration
+
+	// QueryBudget rejects or queues searches whose estimated work exceeds it
+	// (nil = unlimited); Explain reports the estimate next to the actual work
+	QueryBudget *QueryBudgetConfig
+
+	// Maintenance runs compaction, IVF retraining and graph repair in the background
+	// during the configured windows (nil = disabled)
+	Maintenance *MaintenanceConfig
+
+	// AutoSaveInterval and AutoSaveAfterWrites run Sync in the background every interval
+	// and once this many vectors were written or deleted since the last save, so a crash
+	// loses at most that much index work (0 = disabled; otherwise only Close and Sync save
+	// the HNSW graph or IVF structure). Each save holds the write lock; see GraphJournal
+	// to keep HNSW saves short
+	AutoSaveInterval    time.Duration
+	AutoSaveAfterWrites int
+
+	// CompactionThreshold is the fraction of dead (tombstoned or superseded) records in
+	// the data file at which compaction is considered due; Stats().Tombstones forecasts
+	// when it will be reached (0 = default 0.5)
+	CompactionThreshold float64
+
+	// BackgroundThrottle limits the I/O rate and CPU share of background work (compaction,
+	// retraining, rebuilds) so foreground queries keep their latency (nil = unlimited)
+	BackgroundThrottle *ThrottleConfig
+
+	// MemoryPressure evicts cached vectors and drops search scratch buffers when
+	// the process nears its memory limit (nil = disabled, see ReleaseMemory)
+	MemoryPressure *MemoryPressureConfig
+
+	// Loader fetches vectors missing on Get (nil = Get returns ErrNotFound)
+	// Loaded vectors are inserted, so VecLite acts as a persistent read-through
+	// cache; concurrent misses for the same ID share one Loader call
+	Loader func(id uint64) ([]float32, error)
+
+	// DriftWindow is the number of recent queries kept to detect drift between queries
+	// and stored vectors, see Drift and Stats().Drift (0 = disabled)
+	DriftWindow int
+
+	// PrefilterSelectivity is the largest fraction of vectors a SearchOptions.Filter may
+	// match to be searched exactly over the matching vectors; less selective filters
+	// search the index and drop non-matching results (0 = default 0.1)
+	PrefilterSelectivity float64
+
+	// ProfileSampleRate records per-stage timings for 1 in ProfileSampleRate searches,
+	// reported in Stats().Profile (0 = counters only); see also Explain
+	ProfileSampleRate int
+
+	// Expvar publishes counters (see ExpvarCounters) under the "veclite" expvar map,
+	// keyed by Name, e.g. for scraping /debug/vars
+	Expvar bool
+
+	// SlowQueryThreshold is the latency at which a search is kept in SlowQueries and
+	// shown by DebugHandler (0 = 100ms, negative = disabled)
+	SlowQueryThreshold time.Duration
+
+	// CanaryInterval runs the canaries registered with AddCanary in the background
+	// (0 = only when RunCanaries is called)
+	CanaryInterval time.Duration
+
+	// Audit enables an append-only audit log of inserts and deletes (nil = disabled)
+	Audit *AuditConfig
+
+	// QueryLog enables a log of searches that can be replayed with Replay (nil = disabled)
+	QueryLog *QueryLogConfig
+
+	// Operation hooks (all optional)
+	// Before hooks run before the lock is taken; a non-nil error rejects the operation.
+	// After hooks run once the lock is released and see the final error of every
+	// call, including rejections, so they can be used for auditing and metrics.
+	BeforeInsert func(id uint64, vector []float32) error
+	AfterInsert  func(id uint64, vector []float32, err error)
+	BeforeDelete func(id uint64) error
+	AfterDelete  func(id uint64, err error)
+	BeforeSearch func(query []float32, k int) error
+	AfterSearch  func(query []float32, k int, results []SearchResult, err error)
 }
 
+// ErrRejected is wrapped by errors returned when a Before hook rejects an operation
+var ErrRejected = errors.New("operation rejected by hook")
+
+// ErrAlreadyExists is wrapped by Insert errors for IDs that are already stored;
+// Upsert replaces the vector instead
+var ErrAlreadyExists = errors.New("already exists")
+
+// ErrDimensionMismatch is matched by errors for vectors and queries whose
+// length differs from Config.Dimension (the input dimension of Config.Pipeline)
+var ErrDimensionMismatch = errors.New("dimension mismatch")
+
+// dimensionError reports a vector or query (what) of dimension got
+type dimensionError struct {
+	what      string
+	got, want int
+}
+
+func (e *dimensionError) Error() string {
+	return fmt.Sprintf("%s dimension %d does not match configured dimension %d", e.what, e.got, e.want)
+}
+
+func (e *dimensionError) Is(target error) bool {
+	return target == ErrDimensionMismatch
+}
+
+// ThrottleConfig limits background work
+type ThrottleConfig struct {
+	BytesPerSecond int64   // Max background I/O rate (0 = unlimited)
+	CPUBudget      float64 // Fraction of one core background work may use, e.g. 0.25 (0 = unlimited)
+}
+
+// ThrottleState reports the background throttle in Stats
+type ThrottleState = throttle.State
+
+// File is the file abstraction used for the data file
+type File = storage.File
+
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -53,6 +286,10 @@ func New(config *Config) (*VecLite, error) {
 	if config.Dimension <= 0 {
 		return nil, errors.New("dimension must be greater than 0")
 	}
+	inputDim, err := config.Pipeline.dims(config.Dimension)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize storage with cache capacity
 	cacheCapacity := 1000 // Default
@@ -64,31 +301,182 @@ func New(config *Config) (*VecLite, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage: %w", err)
 	}
+	store.SetIOHints(config.IOHints)
+	if err := store.SetRecordAlignment(config.RecordAlignment); err != nil {
+		return nil, err
+	}
+	store.SetFileInfo(fileinfo.Param{Name: "index", Value: string(config.IndexType)})
+	var bgThrottle *throttle.Throttle
+	if config.BackgroundThrottle != nil {
+		bgThrottle = throttle.New(config.BackgroundThrottle.BytesPerSecond, config.BackgroundThrottle.CPUBudget)
+		store.SetThrottle(bgThrottle)
+	}
+	if config.OpenFile != nil {
+		store.SetOpenFile(config.OpenFile)
+	}
+	store.SetRebuildWorkers(config.RecoveryWorkers)
+	if recovery := newProgress(config.Progress, OpRecovery); config.RecoveryProgress != nil || recovery != nil {
+		store.SetRebuildProgress(func(scanned, total int64) {
+			if config.RecoveryProgress != nil {
+				config.RecoveryProgress(scanned, total)
+			}
+			recovery.report("", scanned, total, "bytes")
+		})
+	}
 	if err := store.Open(); err != nil {
 		return nil, fmt.Errorf("failed to open storage: %w", err)
 	}
+	if config.FlatBlockSize > 0 {
+		if err := store.EnableBlockBounds(config.FlatBlockSize); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	if config.Pivots > 0 {
+		if err := store.EnablePivots(config.Pivots); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	// Pass storage to index (indexes can use it or ignore it)
+	// With LazyLoad an existing index is loaded in the background instead
+	var idx index.Index
+	var loadErr error
+	lazy := canLoadLazily(config)
+	if !lazy {
+		idx, loadErr = index.NewIndex(index.IndexType(config.IndexType), config.Dimension, indexConfig(config), store)
+		if loadErr != nil && !canDegrade(config) {
+			store.Close()
+			return nil, fmt.Errorf("failed to create index: %w", loadErr)
+		}
+	}
+
+	metadata, err := openMetadataStore(config.DataPath+".meta", store.Seq())
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
 
-	// Initialize index based on config
+	tiers, err := openColdTier(config.DataPath, config.Dimension)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	keys, err := openKeyStore(config.DataPath + ".keys")
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	kv, err := openKVStore(config.DataPath+".kv", store.Seq())
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	var audit *auditLog
+	if config.Audit != nil {
+		auditConfig := *config.Audit
+		if auditConfig.Path == "" {
+			auditConfig.Path = config.DataPath + ".audit"
+		}
+		audit, err = openAuditLog(auditConfig)
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	var queries *queryLog
+	if config.QueryLog != nil {
+		queryConfig := *config.QueryLog
+		if queryConfig.Path == "" {
+			queryConfig.Path = config.DataPath + ".queries"
+		}
+		queries, err = openQueryLog(queryConfig)
+		if err != nil {
+			if audit != nil {
+				audit.Close()
+			}
+			store.Close()
+			return nil, err
+		}
+	}
+
+	v := &VecLite{
+		config:    config,
+		name:      databaseName(config),
+		inputDim:  inputDim,
+		storage:   store,
+		index:     idx,
+		ready:     alreadyReady,
+		audit:     audit,
+		queries:   queries,
+		metadata:  metadata,
+		tiers:     tiers,
+		keys:      keys,
+		kv:        kv,
+		throttle:  bgThrottle,
+		drift:     newDriftTracker(config.DriftWindow),
+		writes:    newWriteGate(config.MaxPendingWrites, config.WriteStallTimeout),
+		admission: newAdmission(config.QueryBudget),
+	}
+	v.collections = v.newCollections()
+	store.SetCompactProgress(v.compactProgress)
+	switch {
+	case lazy:
+		err = v.openLazy()
+	case loadErr != nil:
+		err = v.openDegraded(loadErr)
+	default:
+		err = v.applyConfigParams()
+	}
+	if err != nil {
+		if audit != nil {
+			audit.Close()
+		}
+		if queries != nil {
+			queries.Close()
+		}
+		store.Close()
+		return nil, err
+	}
+	v.dropShadowedCold()
+	if config.Expvar {
+		v.publishExpvar()
+	}
+	if config.Maintenance != nil {
+		v.startMaintenance(newMaintenanceRunner(*config.Maintenance))
+	}
+	if config.CanaryInterval > 0 {
+		v.startCanaries(config.CanaryInterval)
+	}
+	if saver := newAutoSaver(config); saver != nil {
+		v.startAutoSave(saver)
+	}
+	if config.MemoryPressure != nil {
+		v.startMemoryWatcher(newMemoryWatcher(*config.MemoryPressure))
+	}
+	return v, nil
+}
+
+// indexConfig builds the index parameter map from config
+func indexConfig(config *Config) map[string]any {
 	indexConfig := make(map[string]any)
 	indexConfig["M"] = config.M
 	indexConfig["MaxElements"] = config.MaxElements
 	indexConfig["EfConstruction"] = config.EfConstruction
 	indexConfig["EfSearch"] = config.EfSearch
+	indexConfig["EdgeWeights"] = config.EdgeWeights
+	indexConfig["EntryPoints"] = config.EntryPoints
+	indexConfig["PQSubspaces"] = config.PQSubspaces
+	indexConfig["GraphJournal"] = config.GraphJournal
 	indexConfig["NClusters"] = config.NClusters
 	indexConfig["NProbe"] = config.NProbe
-
-	// Pass storage to index (indexes can use it or ignore it)
-	idx, err := index.NewIndex(index.IndexType(config.IndexType), config.Dimension, indexConfig, store)
-	if err != nil {
-		store.Close()
-		return nil, fmt.Errorf("failed to create index: %w", err)
-	}
-
-	return &VecLite{
-		config:  config,
-		storage: store,
-		index:   idx,
-	}, nil
+	indexConfig["RerankK"] = config.RerankK
+	return indexConfig
 }
 
 // Open opens an existing VecLite database
@@ -103,95 +491,552 @@ func Open(dataPath string) (*VecLite, error) {
 // Close closes the database and flushes all data to disk
 // Requires exclusive lock to ensure no operations are in progress
 func (v *VecLite) Close() error {
+	v.stopRebuild() // Abandon a degraded-mode rebuild before taking the lock it needs
+	v.SetShadow(nil, ShadowConfig{})
+	if v.config.Expvar {
+		v.unpublishExpvar()
+	}
+	v.stopMaintenance()
+	v.stopCanaries()
+	v.stopAutoSave()
+	v.stopMemoryWatcher()
+	if err := v.collections.Close(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	// Shutdown is not background work: a Compact still copying the data file
+	// finishes unthrottled before the storage is closed
+	if v.storage != nil {
+		v.storage.SetThrottle(nil)
+	}
+	v.compactMu.Lock()
+	defer v.compactMu.Unlock()
+
 	v.mu.Lock() // Exclusive lock - wait for all operations to complete
 	defer v.mu.Unlock()
 
+	// A codebook training still running is saved with the index, not lost
+	if trainer, ok := v.index.(index.PQTrainer); ok {
+		_ = trainer.WaitPQ() // A failed training leaves the codebook as it was
+	}
+
 	// Save index structure if needed
-	if v.index != nil {
-		if v.config.IndexType == "hnsw" {
-			if hnswIndex, ok := v.index.(*hnsw.HNSWIndex); ok {
-				if err := hnswIndex.SaveGraph(); err != nil {
-					// Log error but continue with storage close
-					fmt.Printf("Warning: failed to save HNSW graph: %v\n", err)
-				}
-			}
-		} else if v.config.IndexType == "ivf" {
-			if ivfIndex, ok := v.index.(*ivf.IVFIndex); ok {
-				if err := ivfIndex.SaveIVF(); err != nil {
-					// Log error but continue with storage close
-					fmt.Printf("Warning: failed to save IVF index: %v\n", err)
-				}
-			}
+	if err := v.saveSidecar(); err != nil {
+		// Log error but continue with storage close
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := v.metadata.Save(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := v.tiers.save(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := v.keys.save(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := v.kv.save(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if v.audit != nil {
+		if err := v.audit.Close(); err != nil {
+			fmt.Printf("Warning: failed to close audit log: %v\n", err)
+		}
+	}
+
+	if v.queries != nil {
+		if err := v.queries.Close(); err != nil {
+			fmt.Printf("Warning: failed to close query log: %v\n", err)
 		}
 	}
 
 	if v.storage != nil {
+		// Compaction on close rewrites records in index locality order
+		if v.config.LocalityLayout {
+			if orderer, ok := v.index.(index.LocalityOrderer); ok {
+				v.storage.SetLayoutOrder(orderer.LocalityOrder())
+			}
+		}
 		if err := v.storage.Sync(); err != nil {
 			return err
 		}
+		v.compacting = v.trackProgress(OpCompact)
+		defer v.compacting.finish()
 		return v.storage.Close()
 	}
 	return nil
 }
 
+// Sync makes every write so far durable: the index structure, metadata, keys,
+// KV entries and cold tier manifest are saved and the data file is fsynced, as on Close
+// Requires exclusive write lock - blocks all reads and writes while saving
+func (v *VecLite) Sync() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.syncLocked()
+}
+
+// Flush makes the writes so far durable at the cost of a few fsyncs: the data
+// file and the metadata, key and KV journals are synced, while the index
+// structure and the cold tier manifest are left to Sync, Close and AutoSave
+// After a crash, the vectors, metadata, keys and KV entries written before the
+// last Flush are kept; as with AutoSaveInterval, the HNSW graph or IVF
+// structure lacks the vectors indexed since the last save until Reindex
+// Uses read lock - blocks writes, not reads, while syncing
+func (v *VecLite) Flush() error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if err := v.storage.Flush(); err != nil {
+		return fmt.Errorf("failed to sync storage: %w", err)
+	}
+	if err := v.metadata.flush(); err != nil {
+		return err
+	}
+	if err := v.keys.flush(); err != nil {
+		return err
+	}
+	return v.kv.flush()
+}
+
+// syncLocked implements Sync
+// Note: Assumes write lock is already held
+func (v *VecLite) syncLocked() error {
+	if err := v.saveSidecar(); err != nil {
+		return err
+	}
+	if err := v.metadata.Save(); err != nil {
+		return err
+	}
+	if err := v.tiers.save(); err != nil {
+		return err
+	}
+	if err := v.keys.save(); err != nil {
+		return err
+	}
+	if err := v.kv.save(); err != nil {
+		return err
+	}
+	if err := v.storage.Sync(); err != nil {
+		return fmt.Errorf("failed to sync storage: %w", err)
+	}
+	return nil
+}
+
+// saveSidecar persists the HNSW graph or IVF structure if the index has one
+// A degraded database serves a flat fallback and keeps the old file untouched
+// Note: Assumes write lock is already held
+func (v *VecLite) saveSidecar() error {
+	switch idx := v.index.(type) {
+	case *hnsw.HNSWIndex:
+		if err := idx.SaveGraph(); err != nil {
+			return fmt.Errorf("failed to save HNSW graph: %w", err)
+		}
+	case *ivf.IVFIndex:
+		if err := idx.SaveIVF(); err != nil {
+			return fmt.Errorf("failed to save IVF index: %w", err)
+		}
+	}
+	return nil
+}
+
 // Insert adds a vector with an ID to the database
+// Returns ErrAlreadyExists if the ID is stored, hot or cold; see Upsert
 // Requires exclusive write lock - blocks all reads and other writes
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
 func (v *VecLite) Insert(id uint64, vector []float32) error {
-	if len(vector) != v.config.Dimension {
-		return fmt.Errorf("vector dimension %d does not match configured dimension %d", len(vector), v.config.Dimension)
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.InsertContext(ctx, id, vector)
+}
+
+// InsertContext is Insert with a context bounding the wait for the write lock
+// Once the lock is held the write runs to completion so no partial record is left behind
+func (v *VecLite) InsertContext(ctx context.Context, id uint64, vector []float32) error {
+	return v.insert(ctx, id, vector, nil, false, false)
+}
+
+// Upsert adds a vector with an ID, replacing the vector if the ID is stored
+// A replaced vector is re-indexed: HNSW relinks the node and IVF reassigns it
+// to the nearest cluster. Existing metadata is kept
+// Runs the Insert hooks
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
+func (v *VecLite) Upsert(id uint64, vector []float32) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.UpsertContext(ctx, id, vector)
+}
+
+// UpsertContext is Upsert with a context bounding the wait for the write lock
+func (v *VecLite) UpsertContext(ctx context.Context, id uint64, vector []float32) error {
+	return v.insert(ctx, id, vector, nil, false, true)
+}
+
+// insert implements InsertContext, UpsertContext and their WithMetadata variants
+// The metadata is only replaced if setMetadata is true, and a stored ID only
+// if replace is true
+func (v *VecLite) insert(ctx context.Context, id uint64, vector []float32, metadata Metadata, setMetadata, replace bool) (err error) {
+	op := "insert"
+	if replace {
+		op = "upsert"
+	}
+	defer v.label(ctx, op)()
+	if hook := v.config.AfterInsert; hook != nil {
+		defer func() { hook(id, vector, err) }()
 	}
 
-	v.mu.Lock() // Exclusive write lock
+	if err := v.validateInsert(id, vector); err != nil {
+		return err
+	}
+	if hook := v.config.BeforeInsert; hook != nil {
+		if err := hook(id, vector); err != nil {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+	}
+	stored := v.preprocess(vector)
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	defer v.mu.Unlock()
+	defer v.recoverPanic(op, &err)
+
+	return v.insertLocked(id, stored, metadata, setMetadata, replace)
+}
 
+// insertLocked implements insert once the write lock is held
+// Note: Assumes write lock is already held
+func (v *VecLite) insertLocked(id uint64, vector []float32, metadata Metadata, setMetadata, replace bool) error {
+	if !replace && v.exists(id) {
+		return fmt.Errorf("vector %d %w", id, ErrAlreadyExists)
+	}
+	v.preserveForScrolls(id)
 	if err := v.index.Insert(id, vector); err != nil {
 		return err
 	}
+	v.tiers.remove(id) // The new hot vector supersedes a cold copy
+	var metadataErr error
+	if setMetadata {
+		metadataErr = v.metadata.Set(id, metadata, v.storage.Seq())
+	}
+	v.markDirty(id)
+	v.visible.notify()
+	v.autoSaver.wrote(1)
+	if err := v.recordAudit(AuditOpInsert, id); err != nil {
+		return err
+	}
+	if metadataErr != nil {
+		return fmt.Errorf("insert of vector %d applied but its metadata not recorded: %w", id, metadataErr)
+	}
 	return nil
 }
 
 // Search finds the k nearest neighbors to a query vector
 // Uses read lock - allows multiple concurrent searches
+// Bounded by Config.DefaultSearchTimeout if set
 func (v *VecLite) Search(query []float32, k int) ([]index.SearchResult, error) {
-	if len(query) != v.config.Dimension {
-		return nil, fmt.Errorf("query dimension %d does not match configured dimension %d", len(query), v.config.Dimension)
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.SearchContext(ctx, query, k)
+}
+
+// SearchContext is Search with a context bounding both the wait for the read
+// lock and the index traversal itself
+func (v *VecLite) SearchContext(ctx context.Context, query []float32, k int) ([]index.SearchResult, error) {
+	return v.SearchWithOptionsContext(ctx, query, k, DefaultSearchOptions())
+}
+
+// SearchWithOptions is Search returning only the result fields selected by opts
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) SearchWithOptions(query []float32, k int, opts SearchOptions) ([]index.SearchResult, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.SearchWithOptionsContext(ctx, query, k, opts)
+}
+
+// SearchWithOptionsContext is SearchContext returning only the result fields selected by opts
+func (v *VecLite) SearchWithOptionsContext(ctx context.Context, query []float32, k int, opts SearchOptions) ([]index.SearchResult, error) {
+	return v.search(ctx, query, k, opts, nil)
+}
+
+// search implements SearchWithOptionsContext and Explain
+// The search is profiled (see Config.ProfileSampleRate); if explain is non-nil
+// it is always timed and explain receives the serving index and the profile
+func (v *VecLite) search(ctx context.Context, query []float32, k int, opts SearchOptions, explain *Explanation) (results []index.SearchResult, err error) {
+	defer v.label(ctx, "search", LabelTrace, opts.TraceID)()
+	if hook := v.config.AfterSearch; hook != nil {
+		defer func() { hook(query, k, results, err) }()
+	}
+
+	if err := v.validate("query", query); err != nil {
+		return nil, err
 	}
 
 	if k <= 0 {
 		return nil, errors.New("k must be greater than 0")
 	}
+	if err := opts.Filter.validate(); err != nil {
+		return nil, err
+	}
+	if hook := v.config.BeforeSearch; hook != nil {
+		if err := hook(query, k); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+	}
+	// The caller's query is kept for hooks, logs, shadow searches, cursors and rerankers
+	transformed := v.preprocess(query)
+	v.drift.record(transformed)
+	start := time.Now()
+	if v.queries != nil && explain == nil && v.queries.sample() {
+		defer func() {
+			if logErr := v.queries.Record(query, k, opts, time.Since(start), results, err); logErr != nil {
+				fmt.Printf("Warning: %v%s\n", logErr, traceSuffix(opts.TraceID))
+			}
+		}()
+	}
+	if threshold := v.slowQueryThreshold(); threshold > 0 && explain == nil {
+		defer func() {
+			if latency := time.Since(start); latency >= threshold {
+				slow := SlowQuery{Time: time.Now(), TraceID: opts.TraceID, Query: query, K: k, Filter: opts.Filter, Latency: latency, Results: len(results)}
+				if err != nil {
+					slow.Error = err.Error()
+				}
+				v.slowQueries.record(slow)
+			}
+		}()
+	}
+	if shadow := v.shadow.Load(); shadow != nil && explain == nil {
+		defer func() {
+			if err == nil {
+				shadow.run(query, k, opts, results, time.Since(start))
+			}
+		}()
+	}
 
-	v.mu.RLock() // Shared read lock - multiple readers allowed
+	p := v.profiler.begin(v.config.ProfileSampleRate, explain != nil)
+	defer v.profiler.end(p)
+	if opts.Cost != nil {
+		defer func() { *opts.Cost = p.Counters }()
+	}
+	if explain != nil {
+		defer func() {
+			explain.Counters = p.Counters
+			explain.Stages = p.Stages()
+		}()
+	}
+	var frontier index.Frontier
+	if opts.Cursor != nil {
+		// Registered before the rerank, so the cursor sees the final results
+		defer func() {
+			if err == nil {
+				opts.Cursor.start(v, query, opts, frontier, results)
+			}
+		}()
+	}
+	fetch := k
+	if opts.Reranker != nil {
+		fetch = opts.rerankCandidates(k)
+		// Runs once the read lock is released, as rerankers may call out to a service
+		defer func() {
+			if err == nil {
+				results, err = v.rerank(query, k, opts.Reranker, results, p)
+			}
+		}()
+	}
+
+	if v.admission != nil {
+		endStage := p.Start("admission")
+		release, err := v.admitSearch(ctx, fetch, opts.Filter)
+		endStage()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	endStage := p.Start("lock")
+	if err := v.rLockContext(ctx); err != nil { // Shared read lock - multiple readers allowed
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	endStage()
 	defer v.mu.RUnlock()
+	defer v.recoverPanic("search", &err)
+	if err := v.checkK(k); err != nil {
+		return nil, err
+	}
+	if explain != nil {
+		explain.ServingIndex = v.servingIndex()
+		explain.Estimate = v.estimateCost(fetch, opts.Filter)
+	}
 
-	return v.index.Search(query, k)
+	endStage = p.Start("index")
+	plan := QueryPlan{Strategy: PlanIndex}
+	if len(opts.Filter) > 0 {
+		results, plan, err = v.searchFiltered(opts.indexContext(ctx), transformed, fetch, opts.Filter, p)
+	} else if searcher, ok := v.index.(index.FrontierSearcher); ok && opts.Cursor != nil && opts.Reranker == nil && len(v.tiers.segments) == 0 {
+		results, frontier, err = searcher.SearchFrontier(profile.NewContext(opts.indexContext(ctx), p), transformed, fetch)
+	} else {
+		results, err = v.index.SearchContext(profile.NewContext(opts.indexContext(ctx), p), transformed, fetch)
+	}
+	endStage()
+	if explain != nil {
+		explain.Plan = plan
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(v.tiers.segments) > 0 {
+		if results, err = v.searchCold(ctx, transformed, fetch, opts.Filter, results, p); err != nil {
+			return nil, err
+		}
+	}
+	defer p.Start("project")()
+	v.project(results, opts)
+	return results, nil
 }
 
 // Delete removes a vector by ID
 // Requires exclusive write lock - blocks all reads and other writes
+// Waiting for the lock is bounded by Config.DefaultWriteTimeout if set
 func (v *VecLite) Delete(id uint64) error {
-	v.mu.Lock() // Exclusive write lock
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultWriteTimeout)
+	defer cancel()
+	return v.DeleteContext(ctx, id)
+}
+
+// DeleteContext is Delete with a context bounding the wait for the write lock
+func (v *VecLite) DeleteContext(ctx context.Context, id uint64) (err error) {
+	defer v.label(ctx, "delete")()
+	if hook := v.config.AfterDelete; hook != nil {
+		defer func() { hook(id, err) }()
+	}
+	if hook := v.config.BeforeDelete; hook != nil {
+		if err := hook(id); err != nil {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+	}
+
+	if err := v.writes.acquire(ctx); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	defer v.writes.release()
+	if err := v.lockContext(ctx); err != nil { // Exclusive write lock
+		return fmt.Errorf("delete: %w", err)
+	}
 	defer v.mu.Unlock()
+	defer v.recoverPanic("delete", &err)
 
-	return v.index.Delete(id)
+	return v.deleteLocked(id)
 }
 
-// Get retrieves a vector by ID
+// deleteLocked implements DeleteContext once the write lock is held
+// Note: Assumes write lock is already held
+func (v *VecLite) deleteLocked(id uint64) error {
+	v.preserveForScrolls(id)
+	if !v.tiers.remove(id) {
+		if err := v.index.Delete(id); err != nil {
+			return err
+		}
+	}
+	metadataErr := v.metadata.Delete(id, v.storage.Seq())
+	keyErr := v.keys.remove(id)
+	v.markDirty(id)
+	v.visible.notify()
+	v.autoSaver.wrote(1)
+	if err := v.recordAudit(AuditOpDelete, id); err != nil {
+		return err
+	}
+	if keyErr != nil {
+		return fmt.Errorf("delete of vector %d applied but the release of its key not recorded: %w", id, keyErr)
+	}
+	if metadataErr != nil {
+		return fmt.Errorf("delete of vector %d applied but the removal of its metadata not recorded: %w", id, metadataErr)
+	}
+	return nil
+}
+
+// exists reports whether id is stored, hot or cold
+// Note: Assumes read or write lock is already held
+func (v *VecLite) exists(id uint64) bool {
+	return v.storage.Has(id) || v.tiers.has(id)
+}
+
+// recordAudit appends a mutation to the audit log if one is configured
+// Called with the write lock held so the log order matches the mutation order
+func (v *VecLite) recordAudit(op string, id uint64) error {
+	if v.audit == nil {
+		return nil
+	}
+	if err := v.audit.Record("", op, id); err != nil {
+		return fmt.Errorf("%s of vector %d applied but not audited: %w", op, id, err)
+	}
+	return nil
+}
+
+// Get retrieves a vector by ID, fetching it through Config.Loader if it is missing
 // Uses read lock - allows multiple concurrent reads
 func (v *VecLite) Get(id uint64) ([]float32, error) {
-	v.mu.RLock() // Shared read lock
+	return v.GetContext(context.Background(), id)
+}
+
+// GetContext is Get with a context bounding the wait for the read lock
+func (v *VecLite) GetContext(ctx context.Context, id uint64) ([]float32, error) {
+	defer v.label(ctx, "get")()
+	vector, err := v.getContext(ctx, id)
+	if err != nil && v.config.Loader != nil && errors.Is(err, ErrNotFound) {
+		return v.load(id)
+	}
+	return vector, err
+}
+
+// get reads a stored vector by ID
+func (v *VecLite) get(id uint64) ([]float32, error) {
+	return v.getContext(context.Background(), id)
+}
+
+// getContext is get with a context bounding the wait for the read lock
+func (v *VecLite) getContext(ctx context.Context, id uint64) (vector []float32, err error) {
+	if err := v.rLockContext(ctx); err != nil { // Shared read lock
+		return nil, fmt.Errorf("get: %w", err)
+	}
 	defer v.mu.RUnlock()
+	defer v.recoverPanic("get", &err)
+
+	vector, err = v.index.ReadVector(id)
+	if err != nil && errors.Is(err, ErrNotFound) {
+		if cold, ok := v.tiers.get(id); ok {
+			return cold, nil
+		}
+	}
+	return vector, err
+}
 
-	return v.index.ReadVector(id)
+// Dimension returns the configured vector dimension
+func (v *VecLite) Dimension() int {
+	return v.config.Dimension
 }
 
 // Size returns the number of vectors in the database
 // Uses read lock - allows concurrent reads
 func (v *VecLite) Size() int {
-	v.mu.RLock() // Shared read lock
+	stats, locked := v.rLockOrRewrite() // Shared read lock, or the size before a running rewrite
+	if !locked {
+		return stats.Size
+	}
 	defer v.mu.RUnlock()
 
-	return v.index.Size()
+	return v.index.Size() + v.tiers.size()
 }
 
 // SearchResult is an alias to index.SearchResult for convenience
@@ -1,22 +1,25 @@
 package veclite
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+
+	"github.com/monishSR/veclite/internal/index"
 )
 
 // createTestDB creates a temporary database for testing with specified index type
-func createTestDB(t *testing.T, indexType string) (*VecLite, func()) {
-	tmpFile, err := os.CreateTemp("", "veclite_test_*.db")
-	if err != nil {
-		t.Fatalf("Failed to create temp file: %v", err)
-	}
-	tmpFile.Close()
-
+// overrides (optional) adjust the configuration before the database is created;
+// the configuration is db.config
+func createTestDB(t *testing.T, indexType string, overrides ...func(config *Config)) (*VecLite, func()) {
+	t.Helper()
+	// The data file and every sidecar live in the test's directory, which the
+	// testing package removes
 	config := DefaultConfig()
-	config.DataPath = tmpFile.Name()
+	config.DataPath = filepath.Join(t.TempDir(), "veclite_test.db")
 	config.Dimension = 128
 	config.IndexType = indexType
 
@@ -27,32 +30,79 @@ func createTestDB(t *testing.T, indexType string) (*VecLite, func()) {
 		config.EfSearch = 50
 	}
 	// Set IVF parameters if needed
-	if indexType == "ivf" {
+	if indexType == "ivf" || indexType == "ivfpq" {
 		config.NClusters = 10
 		config.NProbe = 2
 	}
+	for _, override := range overrides {
+		override(config)
+	}
 
 	db, err := New(config)
 	if err != nil {
-		os.Remove(tmpFile.Name())
-		os.Remove(tmpFile.Name() + ".graph") // Clean up graph file if it exists
-		os.Remove(tmpFile.Name() + ".ivf")   // Clean up IVF file if it exists
 		t.Fatalf("Failed to create database with index type %s: %v", indexType, err)
 	}
 
 	cleanup := func() {
 		db.Close()
-		os.Remove(tmpFile.Name())
-		os.Remove(tmpFile.Name() + ".graph") // Clean up graph file for HNSW
-		os.Remove(tmpFile.Name() + ".ivf")   // Clean up IVF file for IVF
 	}
 
 	return db, cleanup
 }
 
+// smallVectors sets the dimension of a test database to 4, see insertSmallVectors
+func smallVectors(config *Config) {
+	config.Dimension = 4
+}
+
+// smallHNSW sets the HNSW parameters of a test database to M 8 and
+// EfConstruction 50
+func smallHNSW(config *Config) {
+	config.M = 8
+	config.EfConstruction = 50
+}
+
+// insertSmallVectors inserts {i, 1, 0, 0} under IDs 1..n, with metadata(i) if
+// metadata is not nil, into a database created with smallVectors
+func insertSmallVectors(t *testing.T, db *VecLite, n int, metadata func(i int) Metadata) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		vector := []float32{float32(i), 1, 0, 0}
+		var err error
+		if metadata != nil {
+			err = db.InsertWithMetadata(uint64(i), vector, metadata(i))
+		} else {
+			err = db.Insert(uint64(i), vector)
+		}
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+}
+
+// fileStart returns the size of the header and the file info record at the
+// start of the data file of db
+func fileStart(t *testing.T, db *VecLite) int64 {
+	t.Helper()
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	return usage.Header
+}
+
+// probeAllClusters makes an IVF database search every cluster, for tests about
+// other features whose sequential test vectors pull all later inserts into the
+// cluster of the last seed, so a few probes miss the neighbors of early IDs
+func probeAllClusters(db *VecLite) {
+	if p, ok := db.index.(index.Parameterized); ok && (db.config.IndexType == "ivf" || db.config.IndexType == "ivfpq") {
+		p.SetSearchParams(map[string]any{"NProbe": db.config.NClusters})
+	}
+}
+
 // runTestForAllIndexes runs a test function for all supported index types
 func runTestForAllIndexes(t *testing.T, testFunc func(t *testing.T, indexType string)) {
-	indexTypes := []string{"flat", "hnsw", "ivf"}
+	indexTypes := []string{"flat", "hnsw", "ivf", "ivfpq"}
 
 	for _, indexType := range indexTypes {
 		t.Run(indexType, func(t *testing.T) {
@@ -614,6 +664,49 @@ func TestVecLite_Insert_ErrorCases(t *testing.T) {
 	}
 }
 
+func TestVecLite_Upsert(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+		insertTestVectors(t, db, 50)
+		probeAllClusters(db)
+
+		// Insert rejects stored IDs and leaves them untouched
+		if err := db.Insert(1, testVector(60)); !errors.Is(err, ErrAlreadyExists) {
+			t.Fatalf("Expected ErrAlreadyExists, got %v", err)
+		}
+		if vec, err := db.Get(1); err != nil || vec[0] != 1 {
+			t.Errorf("Expected ID 1 to be unchanged, got %v, %v", vec, err)
+		}
+
+		// Upsert replaces the vector and re-indexes it
+		if err := db.UpsertWithMetadata(1, testVector(60), Metadata{"moved": true}); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		if db.Size() != 50 {
+			t.Errorf("Expected size 50 after upsert, got %d", db.Size())
+		}
+		if vec, err := db.Get(1); err != nil || vec[0] != 60 {
+			t.Errorf("Expected the upserted vector, got %v, %v", vec, err)
+		}
+		results, err := db.Search(testVector(60), 1)
+		if err != nil || len(results) != 1 || results[0].ID != 1 {
+			t.Errorf("Expected ID 1 at its new position, got %v, %v", results, err)
+		}
+		if metadata, _ := db.GetMetadata(1); metadata["moved"] != true {
+			t.Errorf("Expected the upserted metadata, got %v", metadata)
+		}
+
+		// Upsert of a new ID inserts it
+		if err := db.Upsert(51, testVector(51)); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		if db.Size() != 51 {
+			t.Errorf("Expected size 51, got %d", db.Size())
+		}
+	})
+}
+
 func TestVecLite_Search_ErrorCases(t *testing.T) {
 	db, cleanup := createTestDB(t, "flat")
 	defer cleanup()
@@ -782,3 +875,354 @@ func TestVecLite_Close_HNSW_SaveGraphError(t *testing.T) {
 	// Close might error due to storage already being closed, which is expected
 	_ = err
 }
+
+func TestVecLite_LocalityLayout(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		tmpFile, err := os.CreateTemp("", "veclite_layout_*.db")
+		if err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+		tmpFile.Close()
+		defer os.Remove(tmpFile.Name())
+		defer os.Remove(tmpFile.Name() + ".graph")
+		defer os.Remove(tmpFile.Name() + ".ivf")
+
+		config := DefaultConfig()
+		config.DataPath = tmpFile.Name()
+		config.Dimension = 16
+		config.IndexType = indexType
+		config.NClusters = 4
+		config.LocalityLayout = true
+
+		db, err := New(config)
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		for i := uint64(1); i <= 60; i++ {
+			vector := make([]float32, 16)
+			vector[i%16] = float32(i)
+			if err := db.Insert(i, vector); err != nil {
+				t.Fatalf("Failed to insert vector %d: %v", i, err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		db, err = New(config)
+		if err != nil {
+			t.Fatalf("Failed to reopen database: %v", err)
+		}
+		defer db.Close()
+
+		if db.Size() != 60 {
+			t.Errorf("Expected size 60 after reopen, got %d", db.Size())
+		}
+		for i := uint64(1); i <= 60; i++ {
+			vector, err := db.Get(i)
+			if err != nil {
+				t.Fatalf("Failed to get vector %d: %v", i, err)
+			}
+			if vector[i%16] != float32(i) {
+				t.Errorf("Vector %d has wrong contents after relayout", i)
+			}
+		}
+	})
+}
+
+func TestVecLite_RecordAlignment(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "aligned.db")
+	config.Dimension = 3
+	config.IndexType = "flat"
+	config.RecordAlignment = 48
+	if _, err := New(config); err == nil {
+		t.Fatal("Expected an alignment that is not a power of two to be rejected")
+	}
+
+	config.RecordAlignment = 64
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	for i := uint64(1); i <= 10; i++ {
+		if err := db.Insert(i, []float32{float32(i), 0, 1}); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	start := fileStart(t, db)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// Every 38-byte record is padded to 64 bytes
+	if info, err := os.Stat(config.DataPath); err != nil || (start+26)%64 != 0 || info.Size() != start+10*64+10*16+12 {
+		t.Errorf("Expected 10 padded records, got %v (%v)", info.Size(), err)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	results, err := db.Search([]float32{4, 0, 1}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 4 {
+		t.Errorf("Expected vector 4 after reopen, got %v (%v)", results, err)
+	}
+}
+
+func TestVecLite_FlatBlockSize(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "blocks.db")
+	config.Dimension = 2
+	config.IndexType = "flat"
+	config.FlatBlockSize = 10
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	for i := uint64(1); i <= 100; i++ {
+		if err := db.Insert(i, []float32{float32(i), 0}); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+
+	explain, err := db.Explain([]float32{42, 0}, 3, SearchOptions{})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(explain.Results) != 3 || explain.Results[0].ID != 42 || explain.Results[1].ID != 41 || explain.Results[2].ID != 43 {
+		t.Errorf("Expected vectors 42, 41, 43, got %v", explain.Results)
+	}
+	if explain.Counters.Distances > 20 {
+		t.Errorf("Expected at most 2 blocks to be scanned, computed %d distances", explain.Counters.Distances)
+	}
+}
+
+func TestVecLite_Pivots(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "pivots.db")
+	config.Dimension = 2
+	config.IndexType = "flat"
+	config.Pivots = 2
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	for i := uint64(1); i <= 100; i++ {
+		if err := db.Insert(i, []float32{float32(i), float32(i % 3)}); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+
+	explain, err := db.Explain([]float32{42, 0}, 3, SearchOptions{})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if len(explain.Results) != 3 || explain.Results[0].ID != 42 {
+		t.Errorf("Expected vector 42 first, got %v", explain.Results)
+	}
+	if explain.Counters.Distances > 20 {
+		t.Errorf("Expected pivots to skip most vectors, computed %d distances", explain.Counters.Distances)
+	}
+}
+
+func TestVecLite_GraphJournal(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "journal.db")
+	config.Dimension = 2
+	config.IndexType = "hnsw"
+	config.M = 8
+	config.EfConstruction = 50
+	config.EfSearch = 50
+	config.GraphJournal = true
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	for i := uint64(1); i <= 200; i++ {
+		if err := db.Insert(i, []float32{float32(i), float32(i % 7)}); err != nil {
+			t.Fatalf("Failed to insert vector %d: %v", i, err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The next close only journals the insert
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	if err := db.Insert(201, []float32{201, 0}); err != nil {
+		t.Fatalf("Failed to insert vector 201: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(config.DataPath + ".graph.journal"); err != nil {
+		t.Fatalf("Expected a graph journal: %v", err)
+	}
+
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 201 {
+		t.Errorf("Size = %d, want 201", db.Size())
+	}
+	results, err := db.Search([]float32{201, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 201 {
+		t.Errorf("Expected vector 201 from the journal, got %v (%v)", results, err)
+	}
+}
+
+func TestVecLite_RecoveryProgress(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	insertTestVectors(t, db, 50)
+	infoBytes := fileStart(t, db) - 32
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Drop the footer index, as after an unclean shutdown
+	info, err := os.Stat(db.config.DataPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.Truncate(db.config.DataPath, info.Size()-(50*16+12)); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	var calls int
+	var scanned, total int64
+	config := *db.config
+	config.RecoveryWorkers = 2
+	config.RecoveryProgress = func(done, all int64) {
+		calls++
+		scanned, total = done, all
+	}
+	reopened, err := New(&config)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Size() != 50 {
+		t.Errorf("Expected 50 vectors after recovery, got %d", reopened.Size())
+	}
+	recordSize := int64(26 + 128*4)
+	if want := infoBytes + 50*recordSize; calls == 0 || scanned != total || total != want {
+		t.Errorf("Expected progress to end at %d bytes, got %d/%d in %d calls", want, scanned, total, calls)
+	}
+}
+
+func TestVecLite_SearchTieBreaking(t *testing.T) {
+	runTestForAllIndexes(t, func(t *testing.T, indexType string) {
+		db, cleanup := createTestDB(t, indexType)
+		defer cleanup()
+
+		// Five copies of each of four vectors, inserted in shuffled ID order
+		for _, id := range []uint64{17, 3, 9, 12, 1, 20, 6, 14, 8, 2, 19, 11, 5, 15, 4, 10, 18, 7, 16, 13} {
+			vec := make([]float32, 128)
+			vec[0] = float32(id % 4)
+			if err := db.Insert(id, vec); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		query := make([]float32, 128)
+		query[0] = 1
+
+		first, err := db.Search(query, 7)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		// Equal distances are ordered by ascending ID
+		for i := 1; i < len(first); i++ {
+			if first[i].Distance == first[i-1].Distance && first[i].ID < first[i-1].ID {
+				t.Errorf("Expected ascending IDs among equal distances, got %d after %d", first[i].ID, first[i-1].ID)
+			}
+		}
+		for run := 0; run < 5; run++ {
+			again, _ := db.Search(query, 7)
+			for i := range first {
+				if again[i].ID != first[i].ID {
+					t.Fatalf("Run %d returned %d at %d, first run %d", run, again[i].ID, i, first[i].ID)
+				}
+			}
+		}
+		if indexType == "flat" && (first[0].ID != 1 || first[4].ID != 17 || first[5].ID != 2) {
+			t.Errorf("Expected 1, 5, 9, 13, 17 then 2, got %+v", first)
+		}
+	})
+}
+
+func TestVecLite_IVFPQ(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "ivfpq.db")
+	config.Dimension = 8
+	config.IndexType = "ivfpq"
+	config.NClusters = 8
+	config.NProbe = 8
+	config.PQSubspaces = 4
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	vectors := make([][]float32, 1500)
+	ids := make([]uint64, len(vectors))
+	for i := range vectors {
+		ids[i] = uint64(i + 1)
+		vectors[i] = make([]float32, 8)
+		for d := range vectors[i] {
+			vectors[i][d] = float32((i*7+d*13)%101) / 101
+		}
+	}
+	if err := db.InsertBatch(ids, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	// The codebook is trained in the background and swapped in by WaitPQ
+	if err := db.index.(index.PQTrainer).WaitPQ(); err != nil {
+		t.Fatalf("PQ training failed: %v", err)
+	}
+	if !db.index.(interface{ PQTrained() bool }).PQTrained() {
+		t.Fatal("Expected the IVF-PQ codebook to be trained")
+	}
+	if stats := db.Stats(); stats.PQTrainError != "" {
+		t.Errorf("Expected no training error, got %q", stats.PQTrainError)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(config.DataPath + ".ivfpq"); err != nil {
+		t.Fatalf("Expected an IVF-PQ file: %v", err)
+	}
+
+	// RerankK is applied on open
+	config.RerankK = 30
+	db, err = New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if got := db.index.(index.Parameterized).Params()["RerankK"]; got != 30 {
+		t.Errorf("Expected RerankK 30, got %d", got)
+	}
+	results, err := db.Search(vectors[42], 3)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != 43 || results[0].Distance != 0 {
+		t.Fatalf("Expected the query's own vector first, got %v", results)
+	}
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	if usage.PQ == 0 || usage.IVF == 0 {
+		t.Errorf("Expected IVF and PQ disk usage, got %+v", usage)
+	}
+}
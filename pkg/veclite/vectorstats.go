@@ -0,0 +1,162 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// normHistogramBuckets is the number of equal-width buckets in NormStats.Histogram
+const normHistogramBuckets = 10
+
+// VectorStats summarizes the stored vectors over a sample
+// Useful for catching embedding pipeline regressions (e.g. un-normalized or
+// all-zero batches) before they hurt search quality
+type VectorStats struct {
+	Total        int       // Vectors in the database
+	Sampled      int       // Vectors the statistics were computed over
+	ZeroFraction float64   // Fraction of sampled vectors that are all zeros
+	Norm         NormStats // L2 norm distribution
+	DimMean      []float64 // Per-dimension mean
+	DimVariance  []float64 // Per-dimension (population) variance
+}
+
+// NormStats describes the distribution of vector L2 norms
+type NormStats struct {
+	Min, Max, Mean, StdDev float64
+	P50, P90, P99          float64
+	Histogram              []HistogramBucket // Equal-width buckets from Min to Max
+}
+
+// HistogramBucket counts values in [Lower, Upper) (the last bucket includes Upper)
+type HistogramBucket struct {
+	Lower, Upper float64
+	Count        int
+}
+
+// VectorStats computes statistics over up to sampleSize vectors spread evenly
+// across the ID space (sampleSize <= 0 = all vectors)
+// Uses read lock - allows concurrent reads
+func (v *VecLite) VectorStats(sampleSize int) (*VectorStats, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	ids, total := v.sampleIDs(sampleSize)
+	stats := &VectorStats{
+		Total:       total,
+		DimMean:     make([]float64, v.config.Dimension),
+		DimVariance: make([]float64, v.config.Dimension),
+	}
+	if len(ids) == 0 {
+		return stats, nil
+	}
+
+	norms := make([]float64, 0, len(ids))
+	zeros := 0
+	for _, id := range ids {
+		vector, err := v.index.ReadVector(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vector %d: %w", id, err)
+		}
+		if len(vector) != len(stats.DimMean) {
+			return nil, errors.New("vector dimension mismatch")
+		}
+
+		// Welford's online update of per-dimension mean and variance
+		n := float64(len(norms) + 1)
+		var sumSquares float64
+		for d, x := range vector {
+			value := float64(x)
+			sumSquares += value * value
+			delta := value - stats.DimMean[d]
+			stats.DimMean[d] += delta / n
+			stats.DimVariance[d] += delta * (value - stats.DimMean[d])
+		}
+		norm := math.Sqrt(sumSquares)
+		if norm == 0 {
+			zeros++
+		}
+		norms = append(norms, norm)
+	}
+
+	for d := range stats.DimVariance {
+		stats.DimVariance[d] /= float64(len(norms))
+	}
+	stats.Sampled = len(norms)
+	stats.ZeroFraction = float64(zeros) / float64(len(norms))
+	stats.Norm = normStats(norms)
+	return stats, nil
+}
+
+// sampleIDs picks up to sampleSize data IDs spread evenly (and deterministically)
+// across the ID space, and returns them with the total number of data IDs
+// (sampleSize <= 0 = all)
+// Note: Assumes lock is already held
+func (v *VecLite) sampleIDs(sampleSize int) ([]uint64, int) {
+	ids := v.storage.IDs()
+	if keep := v.keepDataID(); keep != nil {
+		kept := ids[:0]
+		for _, id := range ids {
+			if keep(id) {
+				kept = append(kept, id)
+			}
+		}
+		ids = kept
+	}
+	if sampleSize <= 0 || sampleSize >= len(ids) {
+		return ids, len(ids)
+	}
+	sample := make([]uint64, sampleSize)
+	for i := range sample {
+		sample[i] = ids[i*len(ids)/sampleSize]
+	}
+	return sample, len(ids)
+}
+
+// normStats computes the distribution summary of norms (must be non-empty)
+func normStats(norms []float64) NormStats {
+	sort.Float64s(norms)
+	s := NormStats{
+		Min: norms[0],
+		Max: norms[len(norms)-1],
+		P50: percentile(norms, 0.50),
+		P90: percentile(norms, 0.90),
+		P99: percentile(norms, 0.99),
+	}
+
+	var sum float64
+	for _, norm := range norms {
+		sum += norm
+	}
+	s.Mean = sum / float64(len(norms))
+	var squares float64
+	for _, norm := range norms {
+		squares += (norm - s.Mean) * (norm - s.Mean)
+	}
+	s.StdDev = math.Sqrt(squares / float64(len(norms)))
+
+	width := (s.Max - s.Min) / normHistogramBuckets
+	if width == 0 { // All norms equal: a single bucket holds everything
+		s.Histogram = []HistogramBucket{{Lower: s.Min, Upper: s.Max, Count: len(norms)}}
+		return s
+	}
+	buckets := make([]HistogramBucket, normHistogramBuckets)
+	for i := range buckets {
+		buckets[i].Lower = s.Min + float64(i)*width
+		buckets[i].Upper = s.Min + float64(i+1)*width
+	}
+	buckets[len(buckets)-1].Upper = s.Max
+	for _, norm := range norms {
+		i := min(int((norm-s.Min)/width), len(buckets)-1)
+		buckets[i].Count++
+	}
+	s.Histogram = buckets
+	return s
+}
+
+// percentile returns the nearest-rank percentile p (0..1) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
@@ -0,0 +1,74 @@
+package veclite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVecLite_VectorStats(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	stats, err := db.VectorStats(0)
+	if err != nil {
+		t.Fatalf("VectorStats on empty database failed: %v", err)
+	}
+	if stats.Total != 0 || stats.Sampled != 0 {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+
+	// 8 unit vectors along dim 0, 2 zero vectors
+	for i := uint64(1); i <= 10; i++ {
+		vec := make([]float32, 128)
+		if i <= 8 {
+			vec[0] = 1
+		}
+		if err := db.Insert(i, vec); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	stats, err = db.VectorStats(0)
+	if err != nil {
+		t.Fatalf("VectorStats failed: %v", err)
+	}
+	if stats.Total != 10 || stats.Sampled != 10 {
+		t.Errorf("Expected 10 total and sampled, got %d/%d", stats.Total, stats.Sampled)
+	}
+	if math.Abs(stats.ZeroFraction-0.2) > 1e-9 {
+		t.Errorf("Expected zero fraction 0.2, got %v", stats.ZeroFraction)
+	}
+	if stats.Norm.Min != 0 || stats.Norm.Max != 1 || stats.Norm.P50 != 1 {
+		t.Errorf("Unexpected norm stats %+v", stats.Norm)
+	}
+	if math.Abs(stats.Norm.Mean-0.8) > 1e-9 || math.Abs(stats.Norm.StdDev-0.4) > 1e-9 {
+		t.Errorf("Expected norm mean 0.8 and stddev 0.4, got %v/%v", stats.Norm.Mean, stats.Norm.StdDev)
+	}
+	if math.Abs(stats.DimMean[0]-0.8) > 1e-9 || math.Abs(stats.DimVariance[0]-0.16) > 1e-9 {
+		t.Errorf("Expected dim 0 mean 0.8 and variance 0.16, got %v/%v", stats.DimMean[0], stats.DimVariance[0])
+	}
+	if stats.DimMean[1] != 0 || stats.DimVariance[1] != 0 {
+		t.Errorf("Expected zero mean/variance for dim 1, got %v/%v", stats.DimMean[1], stats.DimVariance[1])
+	}
+
+	histogram := stats.Norm.Histogram
+	if len(histogram) != normHistogramBuckets || histogram[0].Count != 2 || histogram[len(histogram)-1].Count != 8 {
+		t.Errorf("Unexpected histogram %+v", histogram)
+	}
+
+	// Sampling caps the number of vectors read
+	sampled, err := db.VectorStats(5)
+	if err != nil {
+		t.Fatalf("VectorStats with sample failed: %v", err)
+	}
+	if sampled.Total != 10 || sampled.Sampled != 5 {
+		t.Errorf("Expected 5 of 10 sampled, got %d of %d", sampled.Sampled, sampled.Total)
+	}
+}
+
+func TestNormStats_SingleValue(t *testing.T) {
+	s := normStats([]float64{1, 1, 1})
+	if len(s.Histogram) != 1 || s.Histogram[0].Count != 3 || s.StdDev != 0 {
+		t.Errorf("Expected a single bucket of 3 and zero stddev, got %+v", s)
+	}
+}
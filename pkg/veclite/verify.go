@@ -0,0 +1,287 @@
+package veclite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/monishSR/veclite/internal/index"
+	"github.com/monishSR/veclite/internal/index/hnsw"
+	"github.com/monishSR/veclite/internal/index/ivf"
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// VerifyOptions selects how thoroughly Verify checks a database
+type VerifyOptions struct {
+	Deep   bool // Read every record and check its framing, its checksum and the graph structure, not just the footer and sidecar IDs
+	Repair bool // Apply safe fixes for repairable issues
+}
+
+// VerifyReport is the result of Verify, shaped for JSON output
+type VerifyReport struct {
+	Path       string        `json:"path"`
+	Deep       bool          `json:"deep"`
+	OK         bool          `json:"ok"` // No issues left unrepaired
+	Dimension  int           `json:"dimension"`
+	Vectors    int           `json:"vectors"`              // Vectors in the footer index
+	Records    int           `json:"records,omitempty"`    // Records scanned (deep only)
+	Tombstones int           `json:"tombstones,omitempty"` // Tombstoned records (deep only)
+	Checksums  int           `json:"checksums,omitempty"`  // Records whose CRC32C checksum was validated (deep only)
+	Issues     []VerifyIssue `json:"issues"`
+}
+
+// VerifyIssue is one problem found by Verify
+type VerifyIssue struct {
+	Check      string `json:"check"` // footer, framing, record, checksum, graph, ivf or metadata
+	Message    string `json:"message"`
+	ID         uint64 `json:"id,omitempty"`
+	Repairable bool   `json:"repairable"`
+	Repaired   bool   `json:"repaired"`
+}
+
+// verifyRepair is the fix a repairable issue needs
+type verifyRepair int
+
+const (
+	repairNone     verifyRepair = iota
+	repairCompact               // Rewrite the data file and footer (happens on every repair)
+	repairFooter                // Rebuild the footer index by scanning the data section
+	repairGraph                 // Save the graph fixed by HNSWIndex.Repair
+	repairMetadata              // Drop metadata of vectors that no longer exist
+	repairSidecar               // Rebuild the index sidecar from the data file
+)
+
+// Verify checks the database at config.DataPath, which must not be open, for
+// inconsistencies between the data file, its footer index and the sidecars
+// Deep also validates the CRC32C checksum of every record of data files
+// written in version 5; older files gain checksums when they are compacted
+// Without opts.Repair the files are left untouched. With it, safe fixes are
+// applied: rebuilding the footer from the data section, dropping partial
+// records, repairing or rebuilding the index sidecar from the data file, and
+// dropping metadata of missing vectors. Non-finite vector values are reported
+// but never changed.
+func Verify(config *Config, opts VerifyOptions) (*VerifyReport, error) {
+	if config.Dimension <= 0 {
+		return nil, errors.New("dimension must be greater than 0")
+	}
+	scan, err := storage.Verify(config.DataPath, config.Dimension, opts.Deep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify data file: %w", err)
+	}
+
+	report := &VerifyReport{
+		Path:       config.DataPath,
+		Deep:       opts.Deep,
+		Dimension:  config.Dimension,
+		Vectors:    scan.Indexed,
+		Records:    scan.Records,
+		Tombstones: scan.Tombstones,
+		Checksums:  scan.Checksums,
+		Issues:     make([]VerifyIssue, 0),
+	}
+	var repairs []verifyRepair
+	issue := func(check, message string, id uint64, repair verifyRepair) {
+		report.Issues = append(report.Issues, VerifyIssue{Check: check, Message: message, ID: id, Repairable: repair != repairNone})
+		repairs = append(repairs, repair)
+	}
+	for _, p := range scan.Problems {
+		switch p.Kind {
+		case "footer":
+			issue(p.Kind, p.Message, p.ID, repairFooter)
+		case "framing":
+			issue(p.Kind, p.Message, p.ID, repairCompact)
+		default:
+			issue(p.Kind, p.Message, p.ID, repairNone)
+		}
+	}
+
+	store, err := storage.NewStorage(config.DataPath, config.Dimension, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	// Open repairs torn tails and upgrades old files, so only a repair uses it
+	open := store.OpenReadOnly
+	if opts.Repair {
+		open = store.Open
+	}
+	if err := open(); errors.Is(err, storage.ErrNeedsUpgrade) {
+		report.OK = len(report.Issues) == 0 // Reported by the scan; nothing else can be checked
+		return report, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	v := &VecLite{config: config, storage: store}
+	defer store.Discard() // No-op once closed by a repair
+
+	// Cross-check the index sidecar against the stored IDs
+	if err := v.verifyIndex(opts.Deep, issue); err != nil {
+		return nil, err
+	}
+
+	tiers, tierErr := openColdTier(config.DataPath, config.Dimension)
+	if tierErr != nil {
+		issue("cold", tierErr.Error(), 0, repairNone)
+	}
+	v.tiers = tiers
+
+	metadata, _, err := loadMetadataStore(config.DataPath+".meta", store.Seq()) // Unchanged unless repairing
+	if err != nil {
+		issue("metadata", err.Error(), 0, repairNone)
+	} else if tierErr == nil { // Without the cold IDs, their metadata would look orphaned
+		v.metadata = metadata
+		stored := v.storedIDs()
+		for _, id := range sortedIDs(metadata.entries) {
+			if !stored[id] {
+				issue("metadata", fmt.Sprintf("metadata for missing vector %d", id), id, repairMetadata)
+			}
+		}
+	}
+
+	if opts.Repair {
+		if err := v.applyRepairs(repairs); err != nil {
+			return nil, err
+		}
+		for i := range report.Issues {
+			report.Issues[i].Repaired = report.Issues[i].Repairable
+		}
+	}
+
+	report.OK = true
+	for _, issue := range report.Issues {
+		report.OK = report.OK && issue.Repaired
+	}
+	return report, nil
+}
+
+// verifyIndex loads the configured index sidecar and reports IDs it and storage
+// disagree on; in deep mode it also checks the HNSW graph structure
+// The loaded index is kept in v.index so a repair can save it
+func (v *VecLite) verifyIndex(deep bool, issue func(check, message string, id uint64, repair verifyRepair)) error {
+	var check string
+	var loaded index.Index
+	var err error
+	switch index.IndexType(v.config.IndexType) {
+	case index.IndexTypeHNSW:
+		check = "graph"
+		loaded, err = hnsw.OpenHNSWIndex(v.storage)
+	case index.IndexTypeIVF:
+		check = "ivf"
+		loaded, err = ivf.OpenIVFIndex(v.storage)
+	case index.IndexTypeIVFPQ:
+		check = "ivf"
+		loaded, err = ivf.OpenIVFPQIndex(v.storage)
+	default:
+		return nil // The flat index has no sidecar
+	}
+	if err != nil {
+		if _, statErr := os.Stat(sidecarPath(v.config)); errors.Is(statErr, os.ErrNotExist) && len(v.storage.IDs()) == 0 {
+			return nil // Empty database that was never closed
+		}
+		issue(check, fmt.Sprintf("sidecar unusable: %v", err), 0, repairSidecar)
+		return nil
+	}
+	v.index = loaded
+
+	orderer, ok := loaded.(index.LocalityOrderer)
+	if !ok {
+		return fmt.Errorf("index type %s cannot list its IDs", v.config.IndexType)
+	}
+	indexed := idSet(orderer.LocalityOrder())
+	stored := idSet(v.storage.IDs())
+	for _, id := range sortedIDs(indexed) {
+		if !stored[id] {
+			issue(check, fmt.Sprintf("indexed vector %d is missing from the data file", id), id, repairSidecar)
+		}
+	}
+	for _, id := range sortedIDs(stored) {
+		if !indexed[id] {
+			issue(check, fmt.Sprintf("stored vector %d is not indexed and cannot be found by search", id), id, repairSidecar)
+		}
+	}
+
+	if graph, isGraph := loaded.(*hnsw.HNSWIndex); isGraph && deep {
+		if fixes := graph.Repair(); fixes > 0 {
+			issue(check, fmt.Sprintf("graph has %d structural defects (dangling links, entry point, unreachable nodes)", fixes), 0, repairGraph)
+		}
+	}
+	return nil
+}
+
+// applyRepairs applies the fixes for the given repairs and closes storage
+func (v *VecLite) applyRepairs(repairs []verifyRepair) error {
+	needed := make(map[verifyRepair]bool)
+	for _, repair := range repairs {
+		needed[repair] = true
+	}
+
+	if needed[repairSidecar] {
+		// Rebuilds the footer too, and builds a fresh sidecar
+		if err := v.rebuildFromData(func(string, int, int) {}); err != nil {
+			return fmt.Errorf("failed to rebuild index: %w", err)
+		}
+	} else {
+		if needed[repairGraph] {
+			if err := v.saveSidecar(); err != nil { // Saves the graph fixed during verification
+				return err
+			}
+		}
+		if needed[repairFooter] {
+			if err := v.storage.RebuildIndex(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if needed[repairMetadata] && v.metadata != nil {
+		stored := v.storedIDs()
+		for id := range v.metadata.entries {
+			if !stored[id] {
+				if err := v.metadata.Delete(id, v.storage.Seq()); err != nil {
+					return err
+				}
+			}
+		}
+		if err := v.metadata.Save(); err != nil {
+			return err
+		}
+	}
+
+	// Closing compacts the data file, dropping partial records, and writes the footer
+	if err := v.storage.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite data file: %w", err)
+	}
+	return nil
+}
+
+// idSet converts ids to a set
+func idSet(ids []uint64) map[uint64]bool {
+	set := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// sortedIDs returns the keys of m in ascending order
+func sortedIDs[V any](m map[uint64]V) []uint64 {
+	ids := make([]uint64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// storedIDs returns the IDs of the vectors in the data file and the cold tier
+func (v *VecLite) storedIDs() map[uint64]bool {
+	stored := idSet(v.storage.IDs())
+	for _, cold := range v.tiers.segments {
+		for _, id := range cold.seg.IDs() {
+			if cold.live(id) {
+				stored[id] = true
+			}
+		}
+	}
+	return stored
+}
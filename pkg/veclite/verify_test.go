@@ -0,0 +1,190 @@
+package veclite
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/storage"
+)
+
+// createVerifyDB creates a closed HNSW database with 20 vectors of dimension 4
+func createVerifyDB(t *testing.T) *Config {
+	t.Helper()
+	db, _ := createTestDB(t, "hnsw", smallVectors, smallHNSW)
+	insertSmallVectors(t, db, 20, nil)
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return db.config
+}
+
+// issueChecks returns the checks of report's issues
+func issueChecks(report *VerifyReport) []string {
+	checks := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		checks = append(checks, issue.Check)
+	}
+	return checks
+}
+
+func TestVerify_Clean(t *testing.T) {
+	config := createVerifyDB(t)
+
+	report, err := Verify(config, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.OK || len(report.Issues) != 0 || report.Vectors != 20 || report.Records != 20 {
+		t.Errorf("Expected a clean report for 20 vectors, got %+v", report)
+	}
+}
+
+func TestVerify_RepairGraphAndMetadata(t *testing.T) {
+	config := createVerifyDB(t)
+	if err := os.WriteFile(config.DataPath+".graph", []byte("garbage"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt graph: %v", err)
+	}
+	if err := os.WriteFile(config.DataPath+".meta", []byte(`{"id":1,"metadata":{"a":1}}`+"\n"+`{"id":99,"metadata":{"a":2}}`+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	report, err := Verify(config, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	checks := issueChecks(report)
+	if report.OK || len(checks) != 2 || checks[0] != "graph" || checks[1] != "metadata" || report.Issues[1].ID != 99 {
+		t.Fatalf("Expected graph and metadata issues, got %+v", report.Issues)
+	}
+	if graph, _ := os.ReadFile(config.DataPath + ".graph"); string(graph) != "garbage" {
+		t.Error("Verify without repair modified the graph")
+	}
+
+	report, err = Verify(config, VerifyOptions{Deep: true, Repair: true})
+	if err != nil {
+		t.Fatalf("Verify with repair failed: %v", err)
+	}
+	if !report.OK || !report.Issues[0].Repaired || !report.Issues[1].Repaired {
+		t.Errorf("Expected all issues repaired, got %+v", report.Issues)
+	}
+
+	if again, err := Verify(config, VerifyOptions{Deep: true}); err != nil || !again.OK || len(again.Issues) != 0 {
+		t.Fatalf("Expected a clean database after repair, got %+v (err %v)", again, err)
+	}
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Opening repaired database failed: %v", err)
+	}
+	defer db.Close()
+	results, err := db.Search([]float32{5, 1, 0, 0}, 1)
+	if err != nil || len(results) != 1 || results[0].ID != 5 {
+		t.Errorf("Expected ID 5 from repaired graph, got %v (err %v)", results, err)
+	}
+	if _, err := db.GetMetadata(99); err == nil {
+		t.Error("Expected orphaned metadata to be dropped")
+	}
+	if metadata, err := db.GetMetadata(1); err != nil || metadata["a"] != float64(1) {
+		t.Errorf("Expected metadata of vector 1 to be kept, got %v (err %v)", metadata, err)
+	}
+}
+
+func TestVerify_NonFiniteNotRepairable(t *testing.T) {
+	config := createVerifyDB(t)
+	config.IndexType = "flat"
+	os.Remove(config.DataPath + ".graph")
+
+	data, err := os.ReadFile(config.DataPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	// Header and file info record, then the first record's header with checksum
+	first := 32 + 26 + int(binary.LittleEndian.Uint32(data[32+18:]))
+	binary.LittleEndian.PutUint32(data[first+26:], math.Float32bits(float32(math.Inf(1))))
+	if err := os.WriteFile(config.DataPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if quick, err := Verify(config, VerifyOptions{}); err != nil || !quick.OK {
+		t.Errorf("Quick verify does not read records, expected OK, got %+v (err %v)", quick, err)
+	}
+	report, err := Verify(config, VerifyOptions{Deep: true, Repair: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK || len(report.Issues) != 2 || report.Issues[0].Check != "checksum" || report.Issues[1].Check != "record" ||
+		report.Issues[0].Repairable || report.Issues[1].Repairable {
+		t.Errorf("Expected unrepairable checksum and record issues, got %+v", report.Issues)
+	}
+}
+
+func TestVerify_WithoutRepairLeavesFilesUntouched(t *testing.T) {
+	config := createVerifyDB(t)
+	file, err := os.OpenFile(config.DataPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if _, err := file.Write([]byte("garbage")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	file.Close()
+	before, err := os.ReadFile(config.DataPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	// Without a footer, opening the file would scan it and quarantine the tail
+	report, err := Verify(config, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK || report.Issues[0].Repaired {
+		t.Errorf("Expected unrepaired issues, got %+v", report.Issues)
+	}
+	if after, _ := os.ReadFile(config.DataPath); string(after) != string(before) {
+		t.Errorf("Verify without repair changed the data file from %d to %d bytes", len(before), len(after))
+	}
+	if _, err := os.Stat(config.DataPath + storage.QuarantineSuffix); !os.IsNotExist(err) {
+		t.Errorf("Verify without repair quarantined bytes: %v", err)
+	}
+}
+
+func TestVerify_CorruptedRecordSurvivesReopen(t *testing.T) {
+	config := createVerifyDB(t)
+
+	data, err := os.ReadFile(config.DataPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	// Header and file info record, then records of 26 + 16 bytes
+	fifth := 32 + 26 + int(binary.LittleEndian.Uint32(data[32+18:])) + 4*42
+	if id := binary.LittleEndian.Uint64(data[fifth:]); id != 5 {
+		t.Fatalf("Expected the record of vector 5 at offset %d, got ID %d", fifth, id)
+	}
+	data[fifth+26] ^= 0x01
+	if err := os.WriteFile(config.DataPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := db.Get(5); !errors.Is(err, storage.ErrChecksum) {
+		t.Errorf("Expected ErrChecksum from Get(5), got %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Close must not compact the corrupted bytes under a fresh checksum
+	report, err := Verify(config, VerifyOptions{Deep: true})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK || len(report.Issues) == 0 || report.Issues[0].Check != "checksum" {
+		t.Errorf("Expected the checksum issue to survive New and Close, got %+v", report.Issues)
+	}
+}
@@ -0,0 +1,108 @@
+package veclite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// visibility wakes WaitForSeq callers when writes are applied
+type visibility struct {
+	mu      sync.Mutex
+	changed chan struct{} // Closed by the next write (nil = nobody waiting)
+	gen     atomic.Uint64 // Incremented by every write, see generation
+}
+
+// wait returns a channel closed by the next notify
+func (w *visibility) wait() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.changed == nil {
+		w.changed = make(chan struct{})
+	}
+	return w.changed
+}
+
+// notify wakes the current waiters
+// Called with the write lock held: woken waiters read Seq once it is released
+func (w *visibility) notify() {
+	w.gen.Add(1)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.changed != nil {
+		close(w.changed)
+		w.changed = nil
+	}
+}
+
+// generation returns a number that changes with every write visible to
+// searches, including tier demotions, which don't advance Seq
+func (w *visibility) generation() uint64 {
+	return w.gen.Load()
+}
+
+// Seq returns the sequence number of the last write visible to searches
+// Sequence numbers increase with every insert and delete and survive reopening
+// and Pack/Unpack, so the Seq read after a write is a read-your-writes token:
+// another goroutine, or a replica restored from a snapshot, sees the write
+// once WaitForSeq with the token returns
+// Uses read lock - waits for a write in progress
+func (v *VecLite) Seq() uint64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.storage.Seq()
+}
+
+// Barrier waits until every write that completed or was waiting for the write
+// lock when Barrier was called is visible to searches, and returns the
+// sequence number of the last visible write
+// Insert, Delete and Apply are visible to searches as soon as they return,
+// including while an index is loading or rebuilt in the background; Barrier
+// orders a reader after writes issued concurrently by other goroutines
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) Barrier() (uint64, error) {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.BarrierContext(ctx)
+}
+
+// BarrierContext is Barrier with a context bounding the wait
+func (v *VecLite) BarrierContext(ctx context.Context) (uint64, error) {
+	// Waiting writers block new readers, so the read lock is only granted
+	// after the writes queued before it
+	if err := v.rLockContext(ctx); err != nil {
+		return 0, fmt.Errorf("barrier: %w", err)
+	}
+	defer v.mu.RUnlock()
+	return v.storage.Seq(), nil
+}
+
+// WaitForSeq waits until the write with sequence number seq (see Seq) is
+// visible to searches
+// Bounded by Config.DefaultSearchTimeout if set
+func (v *VecLite) WaitForSeq(seq uint64) error {
+	ctx, cancel := withDefaultTimeout(context.Background(), v.config.DefaultSearchTimeout)
+	defer cancel()
+	return v.WaitForSeqContext(ctx, seq)
+}
+
+// WaitForSeqContext is WaitForSeq with a context bounding the wait
+func (v *VecLite) WaitForSeqContext(ctx context.Context, seq uint64) error {
+	for {
+		changed := v.visible.wait()
+		if err := v.rLockContext(ctx); err != nil {
+			return fmt.Errorf("wait for seq %d: %w", seq, err)
+		}
+		current := v.storage.Seq()
+		v.mu.RUnlock()
+		if current >= seq {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for seq %d (visible: %d): %w", seq, current, ctx.Err())
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package veclite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVecLite_SeqAndBarrier(t *testing.T) {
+	db, cleanup := createTestDB(t, "hnsw")
+	defer cleanup()
+
+	start := db.Seq()
+	for id := uint64(1); id <= 3; id++ {
+		if err := db.Insert(id, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Delete(2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if seq := db.Seq(); seq != start+4 {
+		t.Errorf("Expected Seq %d after 4 writes, got %d", start+4, seq)
+	}
+
+	// A write waiting for the lock is visible once Barrier returns
+	db.mu.RLock()
+	inserted := make(chan error, 1)
+	go func() { inserted <- db.Insert(10, make([]float32, 128)) }()
+	time.Sleep(10 * time.Millisecond)
+	db.mu.RUnlock()
+	seq, err := db.Barrier()
+	if err != nil {
+		t.Fatalf("Barrier failed: %v", err)
+	}
+	if err := <-inserted; err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if seq != start+5 {
+		t.Errorf("Expected Barrier to wait for the queued insert (Seq %d), got %d", start+5, seq)
+	}
+	if _, err := db.Get(10); err != nil {
+		t.Errorf("Expected the queued insert to be visible after Barrier: %v", err)
+	}
+}
+
+func TestVecLite_WaitForSeq(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+
+	if err := db.Insert(1, make([]float32, 128)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	seq := db.Seq()
+	if err := db.WaitForSeq(seq); err != nil {
+		t.Errorf("Expected a visible seq to return immediately, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := db.WaitForSeqContext(ctx, seq+1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a future seq to time out, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- db.WaitForSeqContext(context.Background(), seq+2) }()
+	for id := uint64(2); id <= 3; id++ {
+		time.Sleep(5 * time.Millisecond)
+		if err := db.Insert(id, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitForSeq failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForSeq did not return after the write was applied")
+	}
+	results, err := db.Search(make([]float32, 128), 3)
+	if err != nil || len(results) != 3 {
+		t.Errorf("Expected the awaited writes in search results, got %d, %v", len(results), err)
+	}
+}
+
+func TestVecLite_SeqSurvivesPack(t *testing.T) {
+	db, cleanup := createTestDB(t, "flat")
+	defer cleanup()
+	for id := uint64(1); id <= 5; id++ {
+		if err := db.Insert(id, make([]float32, 128)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	seq := db.Seq()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "snapshot.pack")
+	if err := db.Pack(archive, PackOptions{}); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	manifest, err := ReadPackManifest(archive)
+	if err != nil {
+		t.Fatalf("ReadPackManifest failed: %v", err)
+	}
+	if manifest.Seq != seq {
+		t.Errorf("Expected manifest Seq %d, got %d", seq, manifest.Seq)
+	}
+	config, err := Unpack(archive, filepath.Join(dir, "restored.db"))
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	restored, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer restored.Close()
+	if err := restored.WaitForSeq(seq); err != nil {
+		t.Errorf("Expected the restored snapshot to contain seq %d: %v", seq, err)
+	}
+}
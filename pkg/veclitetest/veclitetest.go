@@ -0,0 +1,274 @@
+// Package veclitetest provides fault-injection helpers for testing how
+// applications built on VecLite behave when storage fails.
+//
+// A FaultFS is plugged into a database through Config.OpenFile:
+//
+//	fs := veclitetest.NewFaultFS(veclitetest.Faults{FailAfterBytes: 4096, TornWrites: true})
+//	config.OpenFile = fs.OpenFile
+//
+// It can fail writes after a byte budget (optionally leaving a torn,
+// partially-written record behind), fail or silently drop fsync, and simulate
+// a crash that rolls every file back to its last durable (synced) contents.
+package veclitetest
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+// ErrInjected is returned by operations that fail because of an injected fault
+var ErrInjected = errors.New("veclitetest: injected fault")
+
+// Faults configures which failures a FaultFS injects
+type Faults struct {
+	FailAfterBytes int64 // Fail writes once this many bytes have been written across all files (0 = never)
+	TornWrites     bool  // A failing write persists the prefix that fits in the budget before returning the error
+	DropSync       bool  // Sync reports success without making data durable (lost on Crash)
+	FailSync       bool  // Sync returns ErrInjected
+}
+
+// FaultFS opens files that inject the configured faults
+// Durability is tracked per path: a file's contents become durable when it is
+// opened for the first time and on every successful (non-dropped) Sync
+type FaultFS struct {
+	mu      sync.Mutex
+	faults  Faults
+	written int64                  // Bytes written through this FaultFS
+	open    map[*faultFile]bool    // Currently open files
+	durable map[string][]byte      // Last durable contents by path
+	perms   map[string]os.FileMode // File mode used to restore each path
+}
+
+// NewFaultFS creates a FaultFS injecting the given faults
+func NewFaultFS(faults Faults) *FaultFS {
+	return &FaultFS{
+		faults:  faults,
+		open:    make(map[*faultFile]bool),
+		durable: make(map[string][]byte),
+		perms:   make(map[string]os.FileMode),
+	}
+}
+
+// SetFaults replaces the injected faults; the write budget is not reset
+func (fs *FaultFS) SetFaults(faults Faults) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.faults = faults
+}
+
+// BytesWritten returns the number of bytes written through this FaultFS
+func (fs *FaultFS) BytesWritten() int64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.written
+}
+
+// OpenFile opens a file with the same contract as os.OpenFile
+// It has the signature expected by veclite.Config.OpenFile
+func (fs *FaultFS) OpenFile(name string, flag int, perm os.FileMode) (veclite.File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	// Whatever is on disk the first time a path is seen counts as durable
+	if _, tracked := fs.durable[name]; !tracked {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		fs.durable[name] = data
+		fs.perms[name] = perm
+	}
+
+	ff := &faultFile{file: f, fs: fs, name: name}
+	fs.open[ff] = true
+	return ff, nil
+}
+
+// Crash simulates a process crash followed by power loss: all open files are
+// closed (further use returns os.ErrClosed) and every file opened through this
+// FaultFS is rolled back to its last durable contents
+func (fs *FaultFS) Crash() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for ff := range fs.open {
+		ff.crashed.Store(true)
+		_ = ff.file.Close()
+	}
+	fs.open = make(map[*faultFile]bool)
+
+	for name, data := range fs.durable {
+		if err := os.WriteFile(name, data, fs.perms[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// faultFile wraps an *os.File and routes writes and syncs through its FaultFS
+// Methods are forwarded explicitly so no write path can bypass the faults
+type faultFile struct {
+	file    *os.File
+	fs      *FaultFS
+	name    string
+	crashed atomic.Bool // Set by Crash; all operations fail afterwards
+}
+
+// Write writes p, failing (possibly after a torn partial write) once the
+// FaultFS write budget is exhausted
+func (f *faultFile) Write(p []byte) (int, error) {
+	if f.crashed.Load() {
+		return 0, os.ErrClosed
+	}
+	allowed, torn := f.fs.reserve(len(p))
+	if allowed < len(p) {
+		n := 0
+		if torn && allowed > 0 {
+			n, _ = f.file.Write(p[:allowed])
+		}
+		f.fs.addWritten(n)
+		return n, ErrInjected
+	}
+	n, err := f.file.Write(p)
+	f.fs.addWritten(n)
+	return n, err
+}
+
+// WriteAt writes p at offset off, subject to the same faults as Write
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.crashed.Load() {
+		return 0, os.ErrClosed
+	}
+	allowed, torn := f.fs.reserve(len(p))
+	if allowed < len(p) {
+		n := 0
+		if torn && allowed > 0 {
+			n, _ = f.file.WriteAt(p[:allowed], off)
+		}
+		f.fs.addWritten(n)
+		return n, ErrInjected
+	}
+	n, err := f.file.WriteAt(p, off)
+	f.fs.addWritten(n)
+	return n, err
+}
+
+// Read reads from the file unless it was invalidated by Crash
+func (f *faultFile) Read(p []byte) (int, error) {
+	if f.crashed.Load() {
+		return 0, os.ErrClosed
+	}
+	return f.file.Read(p)
+}
+
+// ReadAt reads from the file at offset off unless it was invalidated by Crash
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.crashed.Load() {
+		return 0, os.ErrClosed
+	}
+	return f.file.ReadAt(p, off)
+}
+
+// Stat returns the file info unless the file was invalidated by Crash
+func (f *faultFile) Stat() (os.FileInfo, error) {
+	if f.crashed.Load() {
+		return nil, os.ErrClosed
+	}
+	return f.file.Stat()
+}
+
+// Fd returns the underlying file descriptor (used for kernel I/O hints)
+func (f *faultFile) Fd() uintptr {
+	return f.file.Fd()
+}
+
+// Seek seeks in the file unless it was invalidated by Crash
+func (f *faultFile) Seek(offset int64, whence int) (int64, error) {
+	if f.crashed.Load() {
+		return 0, os.ErrClosed
+	}
+	return f.file.Seek(offset, whence)
+}
+
+// Truncate changes the file size unless it was invalidated by Crash
+func (f *faultFile) Truncate(size int64) error {
+	if f.crashed.Load() {
+		return os.ErrClosed
+	}
+	return f.file.Truncate(size)
+}
+
+// Sync makes the current contents durable unless syncs are failed or dropped
+func (f *faultFile) Sync() error {
+	if f.crashed.Load() {
+		return os.ErrClosed
+	}
+
+	f.fs.mu.Lock()
+	faults := f.fs.faults
+	f.fs.mu.Unlock()
+
+	if faults.FailSync {
+		return ErrInjected
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+	if faults.DropSync {
+		return nil // Reported as durable, but Crash will discard it
+	}
+
+	// Snapshot the contents as the new durable state
+	data, err := os.ReadFile(f.name)
+	if err != nil {
+		return err
+	}
+	f.fs.mu.Lock()
+	f.fs.durable[f.name] = data
+	f.fs.mu.Unlock()
+	return nil
+}
+
+// Close closes the file; closing after Crash is a no-op
+func (f *faultFile) Close() error {
+	f.fs.mu.Lock()
+	delete(f.fs.open, f)
+	f.fs.mu.Unlock()
+
+	if f.crashed.Load() {
+		return nil
+	}
+	return f.file.Close()
+}
+
+// reserve returns how many of n bytes may be written within the write budget,
+// and whether a write exceeding the budget should be torn
+func (fs *FaultFS) reserve(n int) (int, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.faults.FailAfterBytes <= 0 {
+		return n, false
+	}
+	remaining := fs.faults.FailAfterBytes - fs.written
+	if remaining >= int64(n) {
+		return n, false
+	}
+	return int(max(remaining, 0)), fs.faults.TornWrites
+}
+
+// addWritten adds n to the FaultFS write counter
+func (fs *FaultFS) addWritten(n int) {
+	fs.mu.Lock()
+	fs.written += int64(n)
+	fs.mu.Unlock()
+}
@@ -0,0 +1,186 @@
+package veclitetest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monishSR/veclite/pkg/veclite"
+)
+
+func newConfig(t *testing.T, fs *FaultFS) *veclite.Config {
+	config := veclite.DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "faults.db")
+	config.Dimension = 4
+	config.IndexType = "flat"
+	config.OpenFile = fs.OpenFile
+	return config
+}
+
+// fileStart returns the size of the header and the file info record that
+// precede the first record of a new data file
+func fileStart(t *testing.T) int64 {
+	t.Helper()
+	db, err := veclite.New(newConfig(t, NewFaultFS(Faults{})))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+	if err := db.Insert(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	usage, err := db.DiskUsage()
+	if err != nil {
+		t.Fatalf("DiskUsage failed: %v", err)
+	}
+	return usage.Header
+}
+
+func TestFaultFS_FailAfterBytes(t *testing.T) {
+	start := fileStart(t)
+	fs := NewFaultFS(Faults{FailAfterBytes: start + 3*42}) // Header, file info and three 4-dim records
+	db, err := veclite.New(newConfig(t, fs))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var insertErr error
+	for id := uint64(1); id <= 5 && insertErr == nil; id++ {
+		insertErr = db.Insert(id, []float32{1, 2, 3, 4})
+	}
+	if !errors.Is(insertErr, ErrInjected) {
+		t.Fatalf("Expected ErrInjected after write budget, got %v", insertErr)
+	}
+	if got := fs.BytesWritten(); got != start+3*42 {
+		t.Errorf("Expected %d bytes written, got %d", start+3*42, got)
+	}
+	if err := fs.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+}
+
+func TestFaultFS_TornWrite(t *testing.T) {
+	start := fileStart(t)
+	fs := NewFaultFS(Faults{FailAfterBytes: start + 42 + 26 + 4, TornWrites: true})
+	config := newConfig(t, fs)
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := db.Insert(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Insert(2, []float32{5, 6, 7, 8}); !errors.Is(err, ErrInjected) {
+		t.Fatalf("Expected ErrInjected, got %v", err)
+	}
+
+	// The torn record's header and the first 4 bytes of its data made it to
+	// disk and were rolled back
+	info, err := os.Stat(config.DataPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() != start+42 {
+		t.Errorf("Expected the torn record to be rolled back to size %d, got %d", start+42, info.Size())
+	}
+
+	// The insert can be retried
+	fs.SetFaults(Faults{})
+	if err := db.Insert(2, []float32{5, 6, 7, 8}); err != nil {
+		t.Fatalf("Retried insert failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db, err = veclite.New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	if vec, err := db.Get(2); err != nil || vec[0] != 5 {
+		t.Errorf("Expected vector 2 after the retry, got %v (%v)", vec, err)
+	}
+}
+
+func TestFaultFS_CrashRollsBackToLastSync(t *testing.T) {
+	fs := NewFaultFS(Faults{})
+	config := newConfig(t, fs)
+
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for id := uint64(1); id <= 3; id++ {
+		if err := db.Insert(id, []float32{float32(id), 0, 0, 0}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil { // Close syncs: these three are durable
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = veclite.New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if err := db.Insert(4, []float32{4, 0, 0, 0}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := fs.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	db, err = veclite.New(config)
+	if err != nil {
+		t.Fatalf("Open after crash failed: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 3 {
+		t.Errorf("Expected 3 vectors after crash, got %d", db.Size())
+	}
+	if _, err := db.Get(4); err == nil {
+		t.Error("Expected unsynced vector 4 to be lost")
+	}
+}
+
+func TestFaultFS_SyncFaults(t *testing.T) {
+	fs := NewFaultFS(Faults{FailSync: true})
+	config := newConfig(t, fs)
+	db, err := veclite.New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := db.Insert(1, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Close(); !errors.Is(err, ErrInjected) {
+		t.Errorf("Expected Close to surface sync failure, got %v", err)
+	}
+
+	// A dropped sync reports success but does not survive a crash
+	fs.SetFaults(Faults{DropSync: true})
+	db, err = veclite.New(config)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if err := db.Insert(2, []float32{1, 2, 3, 4}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close with dropped sync failed: %v", err)
+	}
+	if err := fs.Crash(); err != nil {
+		t.Fatalf("Crash failed: %v", err)
+	}
+
+	fs.SetFaults(Faults{})
+	db, err = veclite.New(config)
+	if err != nil {
+		t.Fatalf("Open after crash failed: %v", err)
+	}
+	defer db.Close()
+	if db.Size() != 0 {
+		t.Errorf("Expected no durable vectors, got %d", db.Size())
+	}
+}
@@ -0,0 +1,119 @@
+// Package vecmath exposes the distance and normalization kernels VecLite's
+// indexes use, so applications can preprocess vectors (normalize embeddings,
+// assign them to their own centroids, dedupe near-identical rows) with exactly
+// the same math, and get bit-identical distances to the ones in search results
+//
+// The kernels are plain Go with no cgo or assembly; they share their
+// implementation with the indexes, so the two can never drift apart
+package vecmath
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+// DistanceFunc computes the distance between two vectors (smaller = closer)
+type DistanceFunc func(a, b []float32) float32
+
+// L2 returns the Euclidean distance between a and b, the distance all indexes
+// rank by (math.MaxFloat32 if the lengths differ)
+func L2(a, b []float32) float32 {
+	return vector.L2Distance(a, b)
+}
+
+// Cosine returns the cosine distance (1 - cosine similarity) between a and b
+// (1 if the lengths differ or either vector is zero)
+func Cosine(a, b []float32) float32 {
+	return vector.CosineDistance(a, b)
+}
+
+// Dot returns the dot product of a and b (0 if the lengths differ)
+func Dot(a, b []float32) float32 {
+	return vector.DotProduct(a, b)
+}
+
+// Norm returns the Euclidean length of v
+func Norm(v []float32) float32 {
+	return vector.Magnitude(v)
+}
+
+// Normalize returns a unit-length copy of v, or v itself if it is zero
+func Normalize(v []float32) []float32 {
+	return vector.Normalize(v)
+}
+
+// NormalizeInPlace scales v to unit length without allocating (zero vectors
+// are left alone); the result equals Normalize(v)
+func NormalizeInPlace(v []float32) {
+	mag := vector.Magnitude(v)
+	if mag == 0 {
+		return
+	}
+	for i := range v {
+		v[i] /= mag
+	}
+}
+
+// Matrix is a row-major matrix of vectors stored in one contiguous slice
+type Matrix struct {
+	Data []float32 // Rows × Dim values
+	Dim  int       // Values per row
+}
+
+// NewMatrix copies rows into a Matrix; all rows must have the same length
+func NewMatrix(rows [][]float32) (Matrix, error) {
+	if len(rows) == 0 {
+		return Matrix{}, nil
+	}
+	dim := len(rows[0])
+	data := make([]float32, 0, len(rows)*dim)
+	for i, row := range rows {
+		if len(row) != dim {
+			return Matrix{}, fmt.Errorf("vecmath: row %d has dimension %d, expected %d", i, len(row), dim)
+		}
+		data = append(data, row...)
+	}
+	return Matrix{Data: data, Dim: dim}, nil
+}
+
+// Rows returns the number of rows
+func (m Matrix) Rows() int {
+	if m.Dim <= 0 {
+		return 0
+	}
+	return len(m.Data) / m.Dim
+}
+
+// Row returns row i as a slice of Data (not a copy)
+func (m Matrix) Row(i int) []float32 {
+	return m.Data[i*m.Dim : (i+1)*m.Dim : (i+1)*m.Dim]
+}
+
+// Distances writes the distance from query to every row of m into dst, which
+// is grown if too short, and returns it
+func Distances(dst []float32, query []float32, m Matrix, dist DistanceFunc) []float32 {
+	rows := m.Rows()
+	if cap(dst) < rows {
+		dst = make([]float32, rows)
+	}
+	dst = dst[:rows]
+	for i := range dst {
+		dst[i] = dist(query, m.Row(i))
+	}
+	return dst
+}
+
+// ArgMin returns the row of m closest to query and its distance, the way IVF
+// assigns vectors to centroids: ties go to the lowest row
+// Returns -1 and math.MaxFloat32 if m has no rows
+func ArgMin(query []float32, m Matrix, dist DistanceFunc) (int, float32) {
+	best, bestDist := -1, float32(math.MaxFloat32)
+	for i, rows := 0, m.Rows(); i < rows; i++ {
+		if d := dist(query, m.Row(i)); best < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best, bestDist
+}
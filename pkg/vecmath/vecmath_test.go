@@ -0,0 +1,62 @@
+package vecmath
+
+import (
+	"math"
+	"testing"
+
+	"github.com/monishSR/veclite/internal/vector"
+)
+
+func TestKernels_MatchIndex(t *testing.T) {
+	a := []float32{0.3, -1.7, 2.25, 9.5}
+	b := []float32{-4.1, 0.02, 3.3, 1}
+
+	if L2(a, b) != vector.L2Distance(a, b) || Cosine(a, b) != vector.CosineDistance(a, b) ||
+		Dot(a, b) != vector.DotProduct(a, b) || Norm(a) != vector.Magnitude(a) {
+		t.Errorf("Expected kernels identical to the index")
+	}
+
+	want := vector.Normalize(a)
+	got := append([]float32(nil), a...)
+	NormalizeInPlace(got)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("NormalizeInPlace differs at %d: %v vs %v", i, got, want)
+		}
+	}
+	zero := []float32{0, 0}
+	NormalizeInPlace(zero)
+	if zero[0] != 0 || zero[1] != 0 {
+		t.Errorf("Expected zero vector unchanged, got %v", zero)
+	}
+}
+
+func TestArgMin(t *testing.T) {
+	m, err := NewMatrix([][]float32{{5, 5}, {1, 0}, {0, 1}, {1, 0}})
+	if err != nil {
+		t.Fatalf("NewMatrix failed: %v", err)
+	}
+	if m.Rows() != 4 || m.Row(2)[1] != 1 {
+		t.Fatalf("Unexpected matrix: %+v", m)
+	}
+
+	row, dist := ArgMin([]float32{1, 0.1}, m, L2)
+	if row != 1 || dist != L2([]float32{1, 0.1}, []float32{1, 0}) {
+		t.Errorf("Expected row 1 (lowest of the tied rows), got %d at %f", row, dist)
+	}
+	if row, _ := ArgMin([]float32{0, 3}, m, Cosine); row != 2 {
+		t.Errorf("Expected row 2 by cosine, got %d", row)
+	}
+	if row, dist := ArgMin([]float32{1, 1}, Matrix{Dim: 2}, L2); row != -1 || dist != math.MaxFloat32 {
+		t.Errorf("Expected no row in an empty matrix, got %d at %f", row, dist)
+	}
+
+	dists := Distances(nil, []float32{0, 0}, m, L2)
+	if len(dists) != 4 || dists[1] != 1 || math.Abs(float64(dists[0])-math.Sqrt(50)) > 1e-5 {
+		t.Errorf("Unexpected distances: %v", dists)
+	}
+
+	if _, err := NewMatrix([][]float32{{1, 2}, {3}}); err == nil {
+		t.Errorf("Expected an error for ragged rows")
+	}
+}
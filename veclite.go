@@ -0,0 +1,49 @@
+// Package veclite is the stable import path of VecLite
+//
+//	import "github.com/monishSR/veclite"
+//
+// It forwards the core API of pkg/veclite, where the implementation lives and
+// which may be split or moved as features are added; code importing this
+// package keeps building across such changes. Types are aliases, so values
+// are interchangeable with those of pkg/veclite and every method is available
+// here. Use pkg/veclite directly for the rest of the API
+package veclite
+
+import (
+	impl "github.com/monishSR/veclite/pkg/veclite"
+)
+
+// VecLite is an embedded vector database, see New
+type VecLite = impl.VecLite
+
+// Config configures a database, see DefaultConfig
+type Config = impl.Config
+
+// SearchResult is one result of a search: ID and distance to the query
+type SearchResult = impl.SearchResult
+
+// Batch is a list of writes applied together by VecLite.Apply
+type Batch = impl.Batch
+
+// Errors matched with errors.Is; they are the errors of pkg/veclite
+var (
+	ErrNotFound          = impl.ErrNotFound
+	ErrAlreadyExists     = impl.ErrAlreadyExists
+	ErrDimensionMismatch = impl.ErrDimensionMismatch
+	ErrNoMetadata        = impl.ErrNoMetadata
+)
+
+// DefaultConfig returns a default configuration
+func DefaultConfig() *Config {
+	return impl.DefaultConfig()
+}
+
+// New creates or opens the database described by config (nil = DefaultConfig)
+func New(config *Config) (*VecLite, error) {
+	return impl.New(config)
+}
+
+// Open opens the database at dataPath with the default configuration
+func Open(dataPath string) (*VecLite, error) {
+	return impl.Open(dataPath)
+}
@@ -0,0 +1,43 @@
+package veclite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	impl "github.com/monishSR/veclite/pkg/veclite"
+)
+
+func TestStablePath(t *testing.T) {
+	config := DefaultConfig()
+	config.DataPath = filepath.Join(t.TempDir(), "stable.db")
+	config.Dimension = 2
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer db.Close()
+
+	// Values are those of pkg/veclite
+	var _ *impl.VecLite = db
+	var batch Batch
+	batch.Upsert(1, []float32{1, 0})
+	batch.Upsert(2, []float32{0, 1})
+	if err := db.Apply(&batch); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	var results []SearchResult
+	if results, err = db.Search([]float32{1, 0.1}, 1); err != nil || len(results) != 1 || results[0].ID != 1 {
+		t.Fatalf("Expected vector 1, got %v (%v)", results, err)
+	}
+
+	if err := db.Insert(1, []float32{1, 0}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists, got %v", err)
+	}
+	if err := db.Insert(3, []float32{1}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := db.Get(99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}